@@ -376,6 +376,8 @@ func (a *API) RegisterRulerAPI(r *ruler.API, configAPIEnabled bool, buildInfoHan
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.CreateRuleGroup), true, true, "POST")
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), http.HandlerFunc(r.DeleteRuleGroup), true, true, "DELETE")
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.DeleteNamespace), true, true, "DELETE")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules-export"), http.HandlerFunc(r.ExportRules), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules-diff/{namespace}"), http.HandlerFunc(r.DiffRuleGroup), true, true, "POST")
 	}
 }
 
@@ -418,12 +420,20 @@ func (a *API) RegisterCompactor(c *compactor.MultitenantCompactor) {
 	a.RegisterRoute("/compactor/ring", http.HandlerFunc(c.RingHandler), false, true, "GET", "POST")
 	a.RegisterRoute("/api/v1/upload/block/{block}/start", http.HandlerFunc(c.StartBlockUpload), true, false, http.MethodPost)
 	a.RegisterRoute("/api/v1/upload/block/{block}/files", a.DisableServerHTTPTimeouts(http.HandlerFunc(c.UploadBlockFile)), true, false, http.MethodPost)
+	a.RegisterRoute("/api/v1/upload/block/{block}/files", http.HandlerFunc(c.GetBlockUploadFilesHandler), true, false, http.MethodGet)
 	a.RegisterRoute("/api/v1/upload/block/{block}/finish", http.HandlerFunc(c.FinishBlockUpload), true, false, http.MethodPost)
 	a.RegisterRoute("/api/v1/upload/block/{block}/check", http.HandlerFunc(c.GetBlockUploadStateHandler), true, false, http.MethodGet)
 	a.RegisterRoute("/compactor/delete_tenant", http.HandlerFunc(c.DeleteTenant), true, true, "POST")
 	a.RegisterRoute("/compactor/delete_tenant_status", http.HandlerFunc(c.DeleteTenantStatus), true, true, "GET")
 	a.RegisterRoute("/compactor/tenants", http.HandlerFunc(c.TenantsHandler), false, true, "GET")
 	a.RegisterRoute("/compactor/tenant/{tenant}/planned_jobs", http.HandlerFunc(c.PlannedJobsHandler), false, true, "GET")
+	a.RegisterRoute("/compactor/tenant/{tenant}/blocks_retention", http.HandlerFunc(c.BlocksRetentionHandler), false, true, "GET")
+	a.RegisterRoute("/compactor/tenant/{tenant}/retention_simulation", http.HandlerFunc(c.RetentionSimulationHandler), false, true, "GET")
+	a.RegisterRoute("/compactor/tenant/{tenant}/cleanup", http.HandlerFunc(c.TenantCleanupHandler), false, true, "POST")
+	a.RegisterRoute("/compactor/tenant/{tenant}/compaction_history", http.HandlerFunc(c.CompactionHistoryHandler), false, true, "GET")
+	a.RegisterRoute("/compactor/tenant/{tenant}/compaction_record", http.HandlerFunc(c.TenantCompactionRecordHandler), false, true, "GET")
+	a.RegisterRoute("/compactor/tenant/{tenant}/block/{block}/unmark_no_compact", http.HandlerFunc(c.UnmarkNoCompactHandler), false, true, "POST")
+	a.RegisterRoute("/compactor/cleanup_suppression", http.HandlerFunc(c.CleanupSuppressionHandler), false, true, "GET", "POST")
 }
 
 func (a *API) DisableServerHTTPTimeouts(next http.Handler) http.Handler {
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+type unmarkNoCompactResponse struct {
+	Tenant string `json:"tenant"`
+	Block  string `json:"block"`
+}
+
+// UnmarkNoCompactHandler removes the no-compaction marker of a block of a tenant, so that the block
+// is considered again by the next compaction planning. The marker is only removed if the tenant
+// is compacted by this compactor.
+func (c *MultitenantCompactor) UnmarkNoCompactHandler(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	tenantID := vars["tenant"]
+	if tenantID == "" {
+		http.Error(w, "tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	blockID, err := ulid.Parse(vars["block"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid block ID: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if c.State() != services.Running {
+		http.Error(w, "compactor is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	owned, err := c.shardingStrategy.compactorOwnsUser(tenantID)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to check compactor ownership of tenant", "user", tenantID, "err", err)
+		http.Error(w, fmt.Sprintf("failed to check compactor ownership of tenant: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if !owned {
+		http.Error(w, "this compactor doesn't compact the tenant", http.StatusMisdirectedRequest)
+		return
+	}
+
+	logger := log.With(c.logger, "user", tenantID, "block", blockID)
+	userBucket := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
+
+	// The no-compaction filter reads the global marker, so the block is stuck out of compaction
+	// if any of the block-local and global markers exists.
+	marked := false
+	for _, marker := range []string{path.Join(blockID.String(), block.NoCompactMarkFilename), block.NoCompactMarkFilepath(blockID)} {
+		exists, err := userBucket.Exists(req.Context(), marker)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to check the no-compaction marker of block", "err", err)
+			http.Error(w, fmt.Sprintf("failed to check the no-compaction marker of block: %s", err), http.StatusInternalServerError)
+			return
+		}
+		marked = marked || exists
+	}
+	if !marked {
+		http.Error(w, "block is not marked for no-compaction", http.StatusNotFound)
+		return
+	}
+
+	// The bucket client deletes the global marker too. A not found error is returned if only the global marker existed.
+	if err := block.DeleteNoCompactMarker(req.Context(), logger, userBucket, blockID); err != nil && !userBucket.IsObjNotFoundErr(errors.Cause(err)) {
+		level.Error(logger).Log("msg", "failed to delete the no-compaction marker of block", "err", err)
+		http.Error(w, fmt.Sprintf("failed to delete the no-compaction marker of block: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, unmarkNoCompactResponse{
+		Tenant: tenantID,
+		Block:  blockID.String(),
+	})
+}
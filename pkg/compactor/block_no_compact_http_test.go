@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestUnmarkNoCompactHandler(t *testing.T) {
+	const (
+		user         = "user-1"
+		disabledUser = "user-2"
+	)
+
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	cfg := prepareConfig(t)
+	cfg.DisabledTenants = []string{disabledUser}
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, cfg, bucketClient, newMockConfigProvider())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	userBucket := bucket.NewUserBucketClient(user, bucketClient, nil)
+	blockID := createTSDBBlock(t, bucketClient, user, 10, 20, 2, nil)
+
+	unmark := func(tenant, blockID string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		c.UnmarkNoCompactHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"tenant": tenant, "block": blockID}))
+		return resp
+	}
+
+	requireMarkersExist := func(t *testing.T, expected bool) {
+		for _, marker := range []string{path.Join(blockID.String(), block.NoCompactMarkFilename), block.NoCompactMarkFilepath(blockID)} {
+			exists, err := userBucket.Exists(context.Background(), marker)
+			require.NoError(t, err)
+			require.Equal(t, expected, exists, marker)
+		}
+	}
+
+	t.Run("block marked for no-compaction", func(t *testing.T) {
+		require.NoError(t, block.MarkForNoCompact(context.Background(), log.NewNopLogger(), userBucket, blockID, block.CriticalNoCompactReason, "details", prometheus.NewCounter(prometheus.CounterOpts{})))
+		requireMarkersExist(t, true)
+
+		resp := unmark(user, blockID.String())
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var body unmarkNoCompactResponse
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+		require.Equal(t, unmarkNoCompactResponse{Tenant: user, Block: blockID.String()}, body)
+		requireMarkersExist(t, false)
+	})
+
+	t.Run("block with the global marker only", func(t *testing.T) {
+		require.NoError(t, userBucket.Upload(context.Background(), block.NoCompactMarkFilepath(blockID), strings.NewReader("{}")))
+
+		resp := unmark(user, blockID.String())
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		requireMarkersExist(t, false)
+	})
+
+	t.Run("block not marked for no-compaction", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, unmark(user, blockID.String()).Code)
+		require.Equal(t, http.StatusNotFound, unmark(user, ulid.MustNew(1, nil).String()).Code)
+	})
+
+	t.Run("invalid block ID", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, unmark(user, "foo").Code)
+	})
+
+	t.Run("tenant not compacted by this compactor", func(t *testing.T) {
+		require.Equal(t, http.StatusMisdirectedRequest, unmark(disabledUser, blockID.String()).Code)
+	})
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -46,6 +47,7 @@ const (
 )
 
 var maxBlockUploadSizeBytesFormat = "block exceeds the maximum block size limit of %d bytes"
+var maxBlockUploadFilesFormat = "block exceeds the maximum number of files limit of %d"
 var rePath = regexp.MustCompile(`^(index|chunks/\d{6})$`)
 var errValidationCompleted = cancellation.NewErrorf("validation completed")
 
@@ -144,20 +146,20 @@ func (c *MultitenantCompactor) FinishBlockUpload(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if err := completeBlockFileChunks(ctx, logger, userBkt, blockID, m); err != nil {
+		writeBlockUploadError(err, "can't complete block files uploaded in chunks", logger, w, requestID)
+		return
+	}
+
 	if c.cfgProvider.CompactorBlockUploadValidationEnabled(tenantID) {
-		maxConcurrency := int64(c.compactorCfg.MaxBlockUploadValidationConcurrency)
-		currentValidations := c.blockUploadValidations.Inc()
+		validationDone, err := c.startBlockUploadValidation(tenantID)
 		decreaseActiveValidationsInDefer := true
 		defer func() {
 			if decreaseActiveValidationsInDefer {
-				c.blockUploadValidations.Dec()
+				validationDone()
 			}
 		}()
-		if maxConcurrency > 0 && currentValidations > maxConcurrency {
-			err := httpError{
-				message:    fmt.Sprintf("too many block upload validations in progress, limit is %d", maxConcurrency),
-				statusCode: http.StatusTooManyRequests,
-			}
+		if err != nil {
 			writeBlockUploadError(err, "max concurrency was hit", logger, w, requestID)
 			return
 		}
@@ -168,7 +170,7 @@ func (c *MultitenantCompactor) FinishBlockUpload(w http.ResponseWriter, r *http.
 		}
 		decreaseActiveValidationsInDefer = false
 		go c.validateAndCompleteBlockUpload(logger, tenantID, userBkt, blockID, m, func(ctx context.Context) error {
-			defer c.blockUploadValidations.Dec()
+			defer validationDone()
 			return c.validateBlock(ctx, logger, blockID, m, userBkt, tenantID)
 		})
 		level.Info(logger).Log("msg", "validation process started")
@@ -183,6 +185,48 @@ func (c *MultitenantCompactor) FinishBlockUpload(w http.ResponseWriter, r *http.
 	w.WriteHeader(http.StatusOK)
 }
 
+// startBlockUploadValidation registers a new block upload validation for the tenant, and returns a function to call
+// once the validation is done. The function must be called even if an error is returned, which happens when the new
+// validation exceeds either the global validation concurrency limit or the tenant's one, if any, which is checked
+// against the tenant's validations only.
+func (c *MultitenantCompactor) startBlockUploadValidation(tenantID string) (done func(), err error) {
+	globalValidations := c.blockUploadValidations.Inc()
+
+	c.tenantBlockUploadValidationsMtx.Lock()
+	if c.tenantBlockUploadValidations == nil {
+		c.tenantBlockUploadValidations = map[string]int64{}
+	}
+	c.tenantBlockUploadValidations[tenantID]++
+	tenantValidations := c.tenantBlockUploadValidations[tenantID]
+	c.tenantBlockUploadValidationsMtx.Unlock()
+
+	done = func() {
+		c.blockUploadValidations.Dec()
+
+		c.tenantBlockUploadValidationsMtx.Lock()
+		defer c.tenantBlockUploadValidationsMtx.Unlock()
+		if c.tenantBlockUploadValidations[tenantID]--; c.tenantBlockUploadValidations[tenantID] <= 0 {
+			delete(c.tenantBlockUploadValidations, tenantID)
+		}
+	}
+
+	if maxConcurrency := int64(c.compactorCfg.MaxBlockUploadValidationConcurrency); maxConcurrency > 0 && globalValidations > maxConcurrency {
+		return done, httpError{
+			message:    fmt.Sprintf("too many block upload validations in progress, limit is %d", maxConcurrency),
+			statusCode: http.StatusTooManyRequests,
+		}
+	}
+
+	if maxConcurrency := int64(c.cfgProvider.CompactorTenantBlockUploadValidationConcurrency(tenantID)); maxConcurrency > 0 && tenantValidations > maxConcurrency {
+		return done, httpError{
+			message:    fmt.Sprintf("too many block upload validations in progress for the tenant, limit is %d", maxConcurrency),
+			statusCode: http.StatusTooManyRequests,
+		}
+	}
+
+	return done, nil
+}
+
 // parseBlockUploadParameters parses common parameters from the request: block ID, tenant and checks if tenant has uploads enabled.
 func (c *MultitenantCompactor) parseBlockUploadParameters(r *http.Request) (ulid.ULID, string, error) {
 	blockID, err := ulid.Parse(mux.Vars(r)["block"])
@@ -249,7 +293,8 @@ func (c *MultitenantCompactor) createBlockUpload(ctx context.Context, meta *bloc
 	}
 
 	blockDuration := blockMaxTime.Sub(blockMinTime)
-	maxRange := c.compactorCfg.BlockRanges[len(c.compactorCfg.BlockRanges)-1]
+	blockRanges := c.blockRangesForUser(tenantID)
+	maxRange := blockRanges[len(blockRanges)-1]
 	if blockDuration > maxRange {
 		return httpError{
 			message:    fmt.Sprintf("block duration (%v) is larger than max configured compactor time range (%v)", model.Duration(blockDuration), model.Duration(maxRange)),
@@ -268,7 +313,9 @@ func (c *MultitenantCompactor) createBlockUpload(ctx context.Context, meta *bloc
 }
 
 // UploadBlockFile handles requests for uploading block files.
-// It takes the mandatory query parameter "path", specifying the file's destination path.
+// It takes the mandatory query parameter "path", specifying the file's destination path, and the optional
+// query parameter "offset". If the offset is set, the request body is a chunk of the file starting at the
+// given offset, allowing clients to upload a file in multiple requests and to resume a partial upload.
 func (c *MultitenantCompactor) UploadBlockFile(w http.ResponseWriter, r *http.Request) {
 	blockID, tenantID, err := c.parseBlockUploadParameters(r)
 	if err != nil {
@@ -311,6 +358,16 @@ func (c *MultitenantCompactor) UploadBlockFile(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	offset := int64(-1)
+	if o := r.URL.Query().Get("offset"); o != "" {
+		offset, err = strconv.ParseInt(o, 10, 64)
+		if err != nil || offset < 0 {
+			err := httpError{statusCode: http.StatusBadRequest, message: fmt.Sprintf("invalid offset: %q", o)}
+			writeBlockUploadError(err, "failed because offset is invalid", logger, w, requestID)
+			return
+		}
+	}
+
 	userBkt := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
 
 	m, _, err := c.checkBlockState(ctx, userBkt, blockID, true)
@@ -327,24 +384,34 @@ func (c *MultitenantCompactor) UploadBlockFile(w http.ResponseWriter, r *http.Re
 	}
 
 	// Check if file was specified in meta.json, and if it has expected size.
-	found := false
-	for _, f := range m.Thanos.Files {
+	var file *block.File
+	for i, f := range m.Thanos.Files {
 		if pth == f.RelPath {
-			found = true
+			file = &m.Thanos.Files[i]
 
-			if r.ContentLength >= 0 && r.ContentLength != f.SizeBytes {
+			if offset < 0 && r.ContentLength >= 0 && r.ContentLength != f.SizeBytes {
 				err := httpError{statusCode: http.StatusBadRequest, message: fmt.Sprintf("file size doesn't match %s", block.MetaFilename)}
 				writeBlockUploadError(err, "failed because file size didn't match", logger, w, requestID)
 				return
 			}
 		}
 	}
-	if !found {
+	if file == nil {
 		err := httpError{statusCode: http.StatusBadRequest, message: "unexpected file"}
 		writeBlockUploadError(err, "failed because file was not found", logger, w, requestID)
 		return
 	}
 
+	if offset >= 0 {
+		if err := c.uploadBlockFileChunk(ctx, logger, tenantID, userBkt, blockID, *file, offset, r); err != nil {
+			writeBlockUploadError(err, "failed uploading block file chunk", logger, w, requestID)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	dst := path.Join(blockID.String(), pth)
 
 	level.Debug(logger).Log("msg", "uploading block file to bucket", "destination", dst, "size", r.ContentLength)
@@ -487,6 +554,10 @@ func (c *MultitenantCompactor) sanitizeMeta(logger log.Logger, userID string, bl
 		return err.Error()
 	}
 
+	if err := c.validateMaximumBlockFiles(logger, meta.Thanos.Files, userID); err != nil {
+		return err.Error()
+	}
+
 	if meta.Version != block.TSDBVersion1 {
 		return fmt.Sprintf("version must be %d", block.TSDBVersion1)
 	}
@@ -633,6 +704,18 @@ func (c *MultitenantCompactor) validateMaximumBlockSize(logger log.Logger, files
 	return nil
 }
 
+// validateMaximumBlockFiles returns an error if the block declares more files than allowed for the user. The number of
+// files of the uploaded blocks is tracked by the cortex_block_upload_files_total metric.
+func (c *MultitenantCompactor) validateMaximumBlockFiles(logger log.Logger, files []block.File, userID string) error {
+	maxFiles := c.cfgProvider.CompactorBlockUploadMaxFiles(userID)
+	if maxFiles <= 0 || len(files) <= maxFiles {
+		return nil
+	}
+
+	level.Error(logger).Log("msg", "rejecting block upload for exceeding maximum number of files", "limit", maxFiles, "files", len(files))
+	return fmt.Errorf(maxBlockUploadFilesFormat, maxFiles)
+}
+
 type httpError struct {
 	message    string
 	statusCode int
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// uploadingPartsDirname is the name of the directory, inside the block directory, storing the chunks
+// of the block files uploaded in multiple requests, until the block upload is finished.
+const uploadingPartsDirname = "uploading-parts"
+
+// blockFilePart is a chunk of a block file, covering the byte range [start, end).
+type blockFilePart struct {
+	name       string
+	start, end int64
+}
+
+// blockFilePartName returns the object name of the chunk of the block file pth covering the byte range [start, end).
+func blockFilePartName(blockID ulid.ULID, pth string, start, end int64) string {
+	return path.Join(blockFilePartsDir(blockID, pth), fmt.Sprintf("%020d-%020d", start, end))
+}
+
+func blockFilePartsDir(blockID ulid.ULID, pth string) string {
+	return path.Join(blockID.String(), uploadingPartsDirname, pth)
+}
+
+// parseBlockFilePart parses the byte range of a chunk from its object name. The returned bool is false if
+// the object name isn't a valid chunk name.
+func parseBlockFilePart(name string) (blockFilePart, bool) {
+	base := path.Base(name)
+	if len(base) != 41 || base[20] != '-' {
+		return blockFilePart{}, false
+	}
+
+	start, err := strconv.ParseInt(base[:20], 10, 64)
+	if err != nil {
+		return blockFilePart{}, false
+	}
+	end, err := strconv.ParseInt(base[21:], 10, 64)
+	if err != nil || end <= start {
+		return blockFilePart{}, false
+	}
+
+	return blockFilePart{name: name, start: start, end: end}, true
+}
+
+// listBlockFileParts returns the chunks of the block file pth received so far which make up the contiguous
+// byte range starting at offset 0, sorted by offset, and the number of bytes they cover. Chunks overlapping
+// the range (e.g. uploaded twice by concurrent requests) are ignored.
+func listBlockFileParts(ctx context.Context, userBkt objstore.Bucket, blockID ulid.ULID, pth string) ([]blockFilePart, int64, error) {
+	var all []blockFilePart
+	err := userBkt.Iter(ctx, blockFilePartsDir(blockID, pth)+"/", func(name string) error {
+		if p, ok := parseBlockFilePart(name); ok {
+			all = append(all, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to list chunks of block file %s", pth)
+	}
+
+	slices.SortFunc(all, func(a, b blockFilePart) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(b.end, a.end))
+	})
+
+	var (
+		parts    []blockFilePart
+		received int64
+	)
+	for _, p := range all {
+		if p.start == received {
+			parts = append(parts, p)
+			received = p.end
+		}
+	}
+	return parts, received, nil
+}
+
+// uploadBlockFileChunk uploads a chunk of the block file pth, starting at the input offset. The offset must match
+// the number of bytes of the file received so far, so that clients can only append to a partially uploaded file.
+func (c *MultitenantCompactor) uploadBlockFileChunk(ctx context.Context, logger log.Logger, tenantID string, userBkt objstore.Bucket, blockID ulid.ULID, f block.File, offset int64, r *http.Request) error {
+	if r.ContentLength < 0 {
+		return httpError{statusCode: http.StatusBadRequest, message: "the size of file chunks must be known"}
+	}
+	if offset+r.ContentLength > f.SizeBytes {
+		return httpError{statusCode: http.StatusBadRequest, message: fmt.Sprintf("file chunk exceeds the file size in %s", block.MetaFilename)}
+	}
+
+	_, received, err := listBlockFileParts(ctx, userBkt, blockID, f.RelPath)
+	if err != nil {
+		return err
+	}
+	if offset != received {
+		return httpError{statusCode: http.StatusConflict, message: fmt.Sprintf("unexpected offset %d, %d bytes of the file have been received", offset, received)}
+	}
+
+	dst := blockFilePartName(blockID, f.RelPath, offset, offset+r.ContentLength)
+
+	level.Debug(logger).Log("msg", "uploading block file chunk to bucket", "destination", dst, "offset", offset, "size", r.ContentLength)
+	if err := userBkt.Upload(ctx, dst, bodyReader{r: r}); err != nil {
+		return errors.Wrapf(err, "failed uploading block file chunk to bucket: %s", dst)
+	}
+
+	if offset > 0 {
+		c.blockUploadResumedChunks.WithLabelValues(tenantID).Inc()
+	}
+
+	level.Debug(logger).Log("msg", "finished uploading block file chunk to bucket", "path", f.RelPath, "offset", offset)
+	return nil
+}
+
+// completeBlockFileChunks concatenates the chunks of the block files uploaded in multiple requests into the
+// final files, and removes the chunks. It fails if any file uploaded in chunks hasn't been fully received.
+func completeBlockFileChunks(ctx context.Context, logger log.Logger, userBkt objstore.Bucket, blockID ulid.ULID, meta *block.Meta) error {
+	for _, f := range meta.Thanos.Files {
+		if f.RelPath == block.MetaFilename {
+			continue
+		}
+
+		parts, received, err := listBlockFileParts(ctx, userBkt, blockID, f.RelPath)
+		if err != nil {
+			return err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		dst := path.Join(blockID.String(), f.RelPath)
+
+		if received < f.SizeBytes {
+			// The file may have been uploaded in full after some of its chunks were uploaded.
+			exists, err := userBkt.Exists(ctx, dst)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check existence of block file %s", f.RelPath)
+			}
+			if !exists {
+				return httpError{
+					statusCode: http.StatusBadRequest,
+					message:    fmt.Sprintf("file %s is incomplete, received %d of %d bytes", f.RelPath, received, f.SizeBytes),
+				}
+			}
+		} else {
+			level.Debug(logger).Log("msg", "concatenating block file chunks", "path", f.RelPath, "chunks", len(parts))
+			reader := &blockFilePartsReader{ctx: ctx, bkt: userBkt, parts: parts, size: f.SizeBytes}
+			err := userBkt.Upload(ctx, dst, reader)
+			_ = reader.Close()
+			if err != nil {
+				return errors.Wrapf(err, "failed to concatenate chunks of block file %s", f.RelPath)
+			}
+		}
+
+		if err := userBkt.Iter(ctx, blockFilePartsDir(blockID, f.RelPath)+"/", func(name string) error {
+			return userBkt.Delete(ctx, name)
+		}); err != nil {
+			// Leftover chunks get deleted together with the block, so we don't fail the upload.
+			level.Warn(logger).Log("msg", "failed to delete block file chunks", "path", f.RelPath, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// blockFilePartsReader reads the content of a block file from its chunks, opening them one at a time.
+type blockFilePartsReader struct {
+	ctx   context.Context
+	bkt   objstore.BucketReader
+	parts []blockFilePart
+	size  int64
+	cur   io.ReadCloser
+}
+
+// ObjectSize implements thanos.ObjectSizer.
+func (r *blockFilePartsReader) ObjectSize() (int64, error) {
+	return r.size, nil
+}
+
+// Read implements io.Reader.
+func (r *blockFilePartsReader) Read(b []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+
+			rc, err := r.bkt.Get(r.ctx, r.parts[0].name)
+			if err != nil {
+				return 0, err
+			}
+			r.cur = rc
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.cur.Read(b)
+		if errors.Is(err, io.EOF) {
+			_ = r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *blockFilePartsReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
+
+type blockUploadFilesResult struct {
+	Files []blockUploadFileState `json:"files"`
+}
+
+type blockUploadFileState struct {
+	Path          string `json:"path"`
+	SizeBytes     int64  `json:"size_bytes"`
+	ReceivedBytes int64  `json:"received_bytes"`
+}
+
+// GetBlockUploadFilesHandler returns, for each file of a block being uploaded, the number of bytes received so far.
+// Clients can use it to resume the upload of partially uploaded files from the received offset.
+func (c *MultitenantCompactor) GetBlockUploadFilesHandler(w http.ResponseWriter, r *http.Request) {
+	blockID, tenantID, err := c.parseBlockUploadParameters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	requestID := hexTimeNowNano()
+	logger := log.With(
+		util_log.WithContext(ctx, c.logger),
+		"feature", "block upload",
+		"block", blockID,
+		"operation", "get block files",
+		"request_id", requestID,
+	)
+
+	userBkt := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
+	m, _, err := c.checkBlockState(ctx, userBkt, blockID, true)
+	if err != nil {
+		writeBlockUploadError(err, "can't check block state", logger, w, requestID)
+		return
+	}
+
+	// This should not happen.
+	if m == nil {
+		err := httpError{statusCode: http.StatusInternalServerError, message: "internal error"}
+		writeBlockUploadError(err, "block meta is nil but err is also nil", logger, w, requestID)
+		return
+	}
+
+	res := blockUploadFilesResult{Files: make([]blockUploadFileState, 0, len(m.Thanos.Files))}
+	for _, f := range m.Thanos.Files {
+		if f.RelPath == block.MetaFilename {
+			continue
+		}
+
+		state := blockUploadFileState{Path: f.RelPath, SizeBytes: f.SizeBytes}
+
+		exists, err := userBkt.Exists(ctx, path.Join(blockID.String(), f.RelPath))
+		if err != nil {
+			writeBlockUploadError(err, "can't check block file existence", logger, w, requestID)
+			return
+		}
+		if exists {
+			state.ReceivedBytes = f.SizeBytes
+		} else if _, state.ReceivedBytes, err = listBlockFileParts(ctx, userBkt, blockID, f.RelPath); err != nil {
+			writeBlockUploadError(err, "can't list block file chunks", logger, w, requestID)
+			return
+		}
+
+		res.Files = append(res.Files, state)
+	}
+
+	util.WriteJSONResponse(w, res)
+}
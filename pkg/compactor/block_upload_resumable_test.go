@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/user"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/providers/filesystem"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestMultitenantCompactor_ResumableBlockUpload(t *testing.T) {
+	const (
+		tenantID = "test"
+		blockID  = "01G3FZ0JWJYJC0ZM6Y9778P6KD"
+	)
+
+	ctx := context.Background()
+	// The in-memory bucket can't be used, because it holds a lock while reading the uploaded content,
+	// and the content of files uploaded in chunks is read from the bucket itself.
+	bkt, err := filesystem.NewBucket(t.TempDir())
+	require.NoError(t, err)
+	marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID, uploadingMetaFilename), block.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			Version: block.TSDBVersion1,
+			ULID:    ulid.MustParse(blockID),
+		},
+		Thanos: block.ThanosMeta{
+			Labels: map[string]string{
+				mimir_tsdb.CompactorShardIDExternalLabel: "1_of_3",
+			},
+			Files: []block.File{
+				{RelPath: "index", SizeBytes: 1},
+				{RelPath: "chunks/000001", SizeBytes: 10},
+			},
+		},
+	})
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.blockUploadEnabled[tenantID] = true
+	c := &MultitenantCompactor{
+		logger:                   log.NewNopLogger(),
+		bucketClient:             bkt,
+		cfgProvider:              cfgProvider,
+		blockUploadBlocks:        promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		blockUploadBytes:         promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		blockUploadFiles:         promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		blockUploadResumedChunks: promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+	}
+
+	request := func(method, endpoint, content string) *http.Request {
+		r := httptest.NewRequest(method, fmt.Sprintf("/api/v1/upload/block/%s/%s", blockID, endpoint), strings.NewReader(content))
+		r = mux.SetURLVars(r, map[string]string{"block": blockID})
+		return r.WithContext(user.InjectOrgID(r.Context(), tenantID))
+	}
+
+	uploadChunk := func(pth string, offset int, content string) (int, string) {
+		w := httptest.NewRecorder()
+		c.UploadBlockFile(w, request(http.MethodPost, fmt.Sprintf("files?path=%s&offset=%d", url.QueryEscape(pth), offset), content))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	getFiles := func() string {
+		w := httptest.NewRecorder()
+		c.GetBlockUploadFilesHandler(w, request(http.MethodGet, "files", ""))
+		require.Equal(t, http.StatusOK, w.Code)
+		return strings.TrimSpace(w.Body.String())
+	}
+
+	finish := func() (int, string) {
+		w := httptest.NewRecorder()
+		c.FinishBlockUpload(w, request(http.MethodPost, "finish", ""))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	assert.JSONEq(t, `{"files":[
+		{"path":"index","size_bytes":1,"received_bytes":0},
+		{"path":"chunks/000001","size_bytes":10,"received_bytes":0}
+	]}`, getFiles())
+
+	code, _ := uploadChunk("chunks/000001", 0, "abcd")
+	require.Equal(t, http.StatusOK, code)
+
+	// Chunks must be uploaded in order.
+	code, body := uploadChunk("chunks/000001", 2, "cdef")
+	require.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "unexpected offset 2, 4 bytes of the file have been received", body)
+
+	assert.JSONEq(t, `{"files":[
+		{"path":"index","size_bytes":1,"received_bytes":0},
+		{"path":"chunks/000001","size_bytes":10,"received_bytes":4}
+	]}`, getFiles())
+
+	// The block upload can't be finished until all files uploaded in chunks are complete.
+	code, body = finish()
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "file chunks/000001 is incomplete, received 4 of 10 bytes", body)
+
+	code, body = uploadChunk("chunks/000001", 4, "efghijklmn")
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "file chunk exceeds the file size in meta.json", body)
+
+	code, _ = uploadChunk("chunks/000001", 4, "efghij")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1.0, promtest.ToFloat64(c.blockUploadResumedChunks.WithLabelValues(tenantID)))
+
+	w := httptest.NewRecorder()
+	c.UploadBlockFile(w, request(http.MethodPost, "files?path=index", "x"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.JSONEq(t, `{"files":[
+		{"path":"index","size_bytes":1,"received_bytes":1},
+		{"path":"chunks/000001","size_bytes":10,"received_bytes":10}
+	]}`, getFiles())
+
+	code, body = finish()
+	require.Equal(t, http.StatusOK, code, body)
+
+	exists, err := bkt.Exists(ctx, path.Join(tenantID, blockID, block.MetaFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	rdr, err := bkt.Get(ctx, path.Join(tenantID, blockID, "chunks/000001"))
+	require.NoError(t, err)
+	content, err := io.ReadAll(rdr)
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	assert.Equal(t, "abcdefghij", string(content))
+
+	// The chunks have been removed.
+	require.NoError(t, bkt.Iter(ctx, path.Join(tenantID, blockID, uploadingPartsDirname), func(name string) error {
+		return fmt.Errorf("unexpected object %s", name)
+	}, objstore.WithRecursiveIter()))
+}
+
+func TestParseBlockFilePart(t *testing.T) {
+	blockID := ulid.MustParse("01G3FZ0JWJYJC0ZM6Y9778P6KD")
+
+	p, ok := parseBlockFilePart(blockFilePartName(blockID, "chunks/000001", 10, 25))
+	require.True(t, ok)
+	assert.Equal(t, int64(10), p.start)
+	assert.Equal(t, int64(25), p.end)
+
+	for _, name := range []string{"", "00000000000000000010", "00000000000000000010-0000000000000000000a", "00000000000000000010-00000000000000000010"} {
+		_, ok := parseBlockFilePart(name)
+		assert.False(t, ok, name)
+	}
+}
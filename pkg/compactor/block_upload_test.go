@@ -149,6 +149,7 @@ func TestMultitenantCompactor_StartBlockUpload(t *testing.T) {
 		setUpBucketMock         func(bkt *bucket.ClientMock)
 		verifyUpload            func(*testing.T, *bucket.ClientMock)
 		maxBlockUploadSizeBytes int64
+		maxBlockUploadFiles     int
 	}{
 		{
 			name:          "missing tenant ID",
@@ -476,6 +477,15 @@ func TestMultitenantCompactor_StartBlockUpload(t *testing.T) {
 			maxBlockUploadSizeBytes: 1,
 			expBadRequest:           fmt.Sprintf(maxBlockUploadSizeBytesFormat, 1),
 		},
+		{
+			name:                "max block files exceeded",
+			tenantID:            tenantID,
+			blockID:             blockID,
+			setUpBucketMock:     setUpPartialBlock,
+			meta:                &validMeta,
+			maxBlockUploadFiles: 1,
+			expBadRequest:       fmt.Sprintf(maxBlockUploadFilesFormat, 1),
+		},
 		{
 			name:                   "block with too big time range",
 			tenantID:               tenantID,
@@ -627,6 +637,7 @@ func TestMultitenantCompactor_StartBlockUpload(t *testing.T) {
 			cfgProvider.userRetentionPeriods[tenantID] = tc.retention
 			cfgProvider.blockUploadEnabled[tenantID] = !tc.disableBlockUpload
 			cfgProvider.blockUploadMaxBlockSizeBytes[tenantID] = tc.maxBlockUploadSizeBytes
+			cfgProvider.blockUploadMaxFiles[tenantID] = tc.maxBlockUploadFiles
 			c := &MultitenantCompactor{
 				logger:       log.NewNopLogger(),
 				bucketClient: &bkt,
@@ -1252,10 +1263,12 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 		enableValidation       bool // should only be set to true for tests that fail before validation is started
 		maxConcurrency         int
 		setConcurrency         int64
+		tenantMaxConcurrency   int
+		setTenantConcurrency   int64
 		expBadRequest          string
 		expConflict            string
 		expNotFound            string
-		expTooManyRequests     bool
+		expTooManyRequests     string
 		expInternalServerError bool
 	}{
 		{
@@ -1341,7 +1354,28 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 			enableValidation:   true,
 			maxConcurrency:     2,
 			setConcurrency:     2,
-			expTooManyRequests: true,
+			expTooManyRequests: "too many block upload validations in progress, limit is 2",
+		},
+		{
+			name:                 "too many concurrent validations, even if the tenant has its own limit",
+			tenantID:             tenantID,
+			blockID:              blockID,
+			setUpBucket:          validSetup,
+			enableValidation:     true,
+			maxConcurrency:       2,
+			setConcurrency:       2,
+			tenantMaxConcurrency: 10,
+			expTooManyRequests:   "too many block upload validations in progress, limit is 2",
+		},
+		{
+			name:                 "too many concurrent validations for the tenant",
+			tenantID:             tenantID,
+			blockID:              blockID,
+			setUpBucket:          validSetup,
+			enableValidation:     true,
+			tenantMaxConcurrency: 2,
+			setTenantConcurrency: 2,
+			expTooManyRequests:   "too many block upload validations in progress for the tenant, limit is 2",
 		},
 	}
 	for _, tc := range testCases {
@@ -1358,6 +1392,7 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 			cfgProvider := newMockConfigProvider()
 			cfgProvider.blockUploadEnabled[tc.tenantID] = !tc.disableBlockUpload
 			cfgProvider.blockUploadValidationEnabled[tc.tenantID] = tc.enableValidation
+			cfgProvider.blockUploadValidationConcurrency[tc.tenantID] = tc.tenantMaxConcurrency
 			c := &MultitenantCompactor{
 				logger:       log.NewNopLogger(),
 				bucketClient: &injectedBkt,
@@ -1367,6 +1402,9 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 			if tc.setConcurrency > 0 {
 				c.blockUploadValidations.Add(tc.setConcurrency)
 			}
+			if tc.setTenantConcurrency > 0 {
+				c.tenantBlockUploadValidations = map[string]int64{tc.tenantID: tc.setTenantConcurrency}
+			}
 
 			c.compactorCfg.DataDir = t.TempDir()
 
@@ -1397,9 +1435,9 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 			case tc.expInternalServerError:
 				assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 				assert.Regexp(t, "internal server error \\(id [0-9a-f]{16}\\)\n", string(body))
-			case tc.expTooManyRequests:
+			case tc.expTooManyRequests != "":
 				assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
-				assert.Equal(t, "too many block upload validations in progress, limit is 2\n", string(body))
+				assert.Equal(t, tc.expTooManyRequests+"\n", string(body))
 			default:
 				assert.Equal(t, http.StatusOK, resp.StatusCode)
 				assert.Empty(t, string(body))
@@ -1411,6 +1449,56 @@ func TestMultitenantCompactor_FinishBlockUpload(t *testing.T) {
 	}
 }
 
+func TestMultitenantCompactor_StartBlockUploadValidation(t *testing.T) {
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.blockUploadValidationConcurrency["tenant-with-limit"] = 1
+
+	c := &MultitenantCompactor{cfgProvider: cfgProvider}
+	c.compactorCfg.MaxBlockUploadValidationConcurrency = 3
+
+	start := func(tenantID string) error {
+		done, err := c.startBlockUploadValidation(tenantID)
+		t.Cleanup(done)
+		return err
+	}
+
+	// The tenant's own limit is checked against the tenant's validations only.
+	err := start("tenant-with-limit")
+	require.NoError(t, err)
+	err = start("tenant-with-limit")
+	require.EqualError(t, err, "too many block upload validations in progress for the tenant, limit is 1")
+
+	// The global limit applies to all tenants, whether they have their own limit or not.
+	err = start("tenant-without-limit")
+	require.NoError(t, err)
+	err = start("tenant-without-limit")
+	require.EqualError(t, err, "too many block upload validations in progress, limit is 3")
+
+	// All validations are tracked by the global in-progress gauge, including the rejected ones until they're done.
+	assert.Equal(t, int64(4), c.blockUploadValidations.Load())
+
+	// The global limit also applies to the tenants with their own limit.
+	c.compactorCfg.MaxBlockUploadValidationConcurrency = 1
+	cfgProvider.blockUploadValidationConcurrency["other-tenant-with-limit"] = 10
+	err = start("other-tenant-with-limit")
+	require.EqualError(t, err, "too many block upload validations in progress, limit is 1")
+}
+
+func TestMultitenantCompactor_StartBlockUploadValidation_ShouldUntrackDoneValidations(t *testing.T) {
+	c := &MultitenantCompactor{cfgProvider: newMockConfigProvider()}
+
+	doneFirst, err := c.startBlockUploadValidation("tenant-1")
+	require.NoError(t, err)
+	doneSecond, err := c.startBlockUploadValidation("tenant-1")
+	require.NoError(t, err)
+
+	doneFirst()
+	doneSecond()
+
+	assert.Equal(t, int64(0), c.blockUploadValidations.Load())
+	assert.Empty(t, c.tenantBlockUploadValidations)
+}
+
 func TestMultitenantCompactor_ValidateAndComplete(t *testing.T) {
 	const tenantID = "test"
 	const blockID = "01G3FZ0JWJYJC0ZM6Y9778P6KD"
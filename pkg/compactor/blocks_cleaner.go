@@ -6,14 +6,19 @@
 package compactor
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/concurrency"
@@ -22,7 +27,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
@@ -42,15 +49,26 @@ const (
 )
 
 type BlocksCleanerConfig struct {
-	DeletionDelay                 time.Duration
-	CleanupInterval               time.Duration
-	CleanupConcurrency            int
-	TenantCleanupDelay            time.Duration // Delay before removing tenant deletion mark and "debug".
-	DeleteBlocksConcurrency       int
-	GetDeletionMarkersConcurrency int
-	UpdateBlocksConcurrency       int
-	NoBlocksFileCleanupEnabled    bool
-	CompactionBlockRanges         mimir_tsdb.DurationList // Used for estimating compaction jobs.
+	DeletionDelay                  time.Duration
+	CleanupInterval                time.Duration
+	CleanupConcurrency             int
+	TenantCleanupDelay             time.Duration // Delay before removing tenant deletion mark and "debug".
+	DeleteBlocksConcurrency        int
+	GetDeletionMarkersConcurrency  int
+	UpdateBlocksConcurrency        int
+	NoBlocksFileCleanupEnabled     bool
+	CompactionBlockRanges          mimir_tsdb.DurationList // Used for estimating compaction jobs.
+	RetentionSource                RetentionSource         // Optional. If set, takes precedence over the retention period of the config provider.
+	RetentionSourceCacheTTL        time.Duration           // How long the retention periods read from the RetentionSource are cached.
+	SupersededBlocksCleanupEnabled bool                    // Whether blocks fully included in other blocks are marked for deletion.
+	FutureBlocksTolerance          time.Duration           // Blocks with MinTime further than this in the future are marked for no-compaction. 0 to disable.
+	BlockSizeMetricsEnabled        bool                    // Whether the per-tenant block size distribution is tracked.
+	BlockLevelMetricsEnabled       bool                    // Whether the per-tenant number of blocks by compaction level is tracked.
+	UnchangedIndexWriteSkipPeriod  time.Duration           // Max period the write of an unchanged bucket index is skipped for. 0 to disable.
+	CleanupSuppressedFrom          time.Time               // Start of the window blocks deletion and retention are suppressed in. Zero for no start.
+	CleanupSuppressedUntil         time.Time               // End of the window blocks deletion and retention are suppressed in. Zero to disable the window.
+	MaxPartialBlocksPerCleanup     int                     // Max number of partial blocks of a tenant processed per cleanup, oldest first. 0 = no limit.
+	BucketOperations               *prometheus.CounterVec  // Optional. If set, the bucket operations of each tenant cleanup are counted.
 }
 
 type BlocksCleaner struct {
@@ -63,9 +81,23 @@ type BlocksCleaner struct {
 	usersScanner *mimir_tsdb.UsersScanner
 	singleFlight *concurrency.LimitedConcurrencySingleFlight
 
+	// Resolves the retention period from the configured RetentionSource. Nil if there's no RetentionSource.
+	retentionResolver *cachingRetentionResolver
+
 	// Keep track of the last owned users.
 	lastOwnedUsers []string
 
+	// Keep track of the bucket index last written for each tenant, to skip the write of unchanged bucket indexes.
+	writtenIndexesMx sync.Mutex
+	writtenIndexes   map[string]writtenIndex
+
+	// Keep track of the tenants warned about blocks with unknown number of series, to only warn once.
+	unknownSeriesWarnedMx sync.Mutex
+	unknownSeriesWarned   map[string]struct{}
+
+	// Whether blocks deletion and retention have been suppressed via the HTTP API, until the next restart.
+	suppressedByAPI atomic.Bool
+
 	// Metrics.
 	runsStarted                         prometheus.Counter
 	runsCompleted                       prometheus.Counter
@@ -75,22 +107,45 @@ type BlocksCleaner struct {
 	blocksFailedTotal                   prometheus.Counter
 	blocksMarkedForDeletion             prometheus.Counter
 	partialBlocksMarkedForDeletion      prometheus.Counter
+	seriesCapBlocksMarkedForDeletion    prometheus.Counter
+	partialBlocksDeferred               prometheus.Counter
+	supersededBlocksMarked              prometheus.Counter
+	noCompactBlocksReconciled           prometheus.Counter
+	futureBlocks                        *prometheus.CounterVec
+	retentionBacklogBlocks              *prometheus.GaugeVec
+	bytesMarkedForDeletion              *prometheus.GaugeVec
 	tenantBlocks                        *prometheus.GaugeVec
 	tenantMarkedBlocks                  *prometheus.GaugeVec
 	tenantPartialBlocks                 *prometheus.GaugeVec
 	tenantBucketIndexLastUpdate         *prometheus.GaugeVec
+	tenantBlockSizes                    *prometheus.HistogramVec
+	tenantBlocksByLevel                 *prometheus.GaugeVec
+	tenantBucketIndexReadDuration       *prometheus.HistogramVec
+	tenantOverlappingBlocks             *prometheus.GaugeVec
 	bucketIndexCompactionJobs           *prometheus.GaugeVec
 	bucketIndexCompactionPlanningErrors prometheus.Counter
+	bucketIndexWritesSkipped            prometheus.Counter
+	cleanupSuppressed                   prometheus.Gauge
+}
+
+// writtenIndex identifies a bucket index written by the blocks cleaner.
+type writtenIndex struct {
+	// hash is the hash of the bucket index content, excluding the updated-at timestamp.
+	hash      uint64
+	updatedAt int64
 }
 
 func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, ownUser func(userID string) (bool, error), cfgProvider ConfigProvider, logger log.Logger, reg prometheus.Registerer) *BlocksCleaner {
 	c := &BlocksCleaner{
-		cfg:          cfg,
-		bucketClient: bucketClient,
-		usersScanner: mimir_tsdb.NewUsersScanner(bucketClient, ownUser, logger),
-		cfgProvider:  cfgProvider,
-		singleFlight: concurrency.NewLimitedConcurrencySingleFlight(cfg.CleanupConcurrency),
-		logger:       log.With(logger, "component", "cleaner"),
+		cfg:            cfg,
+		bucketClient:   bucketClient,
+		usersScanner:   mimir_tsdb.NewUsersScanner(bucketClient, ownUser, logger),
+		cfgProvider:    cfgProvider,
+		singleFlight:   concurrency.NewLimitedConcurrencySingleFlight(cfg.CleanupConcurrency),
+		logger:         log.With(logger, "component", "cleaner"),
+		writtenIndexes: map[string]writtenIndex{},
+
+		unknownSeriesWarned: map[string]struct{}{},
 		runsStarted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name: "cortex_compactor_block_cleanup_started_total",
 			Help: "Total number of blocks cleanup runs started.",
@@ -120,11 +175,40 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, own
 			Help:        blocksMarkedForDeletionHelp,
 			ConstLabels: prometheus.Labels{"reason": "retention"},
 		}),
+		seriesCapBlocksMarkedForDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        blocksMarkedForDeletionName,
+			Help:        blocksMarkedForDeletionHelp,
+			ConstLabels: prometheus.Labels{"reason": "series_cap"},
+		}),
 		partialBlocksMarkedForDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name:        blocksMarkedForDeletionName,
 			Help:        blocksMarkedForDeletionHelp,
 			ConstLabels: prometheus.Labels{"reason": "partial"},
 		}),
+		partialBlocksDeferred: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_partial_blocks_deferred_total",
+			Help: "Total number of partial blocks not processed by the cleaner in a cleanup, because the tenant had more partial blocks than the max processed per cleanup.",
+		}),
+		noCompactBlocksReconciled: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_no_compact_blocks_reconciled_total",
+			Help: "Total number of blocks whose no-compaction state in the bucket index has been reconciled with the no-compact marks.",
+		}),
+		supersededBlocksMarked: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_superseded_blocks_marked_total",
+			Help: "Total number of blocks marked for deletion by the cleaner because fully included in other compacted blocks.",
+		}),
+		futureBlocks: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_future_blocks_total",
+			Help: "Total number of blocks marked for no-compaction by the cleaner because their min time is too far in the future.",
+		}, []string{"user"}),
+		retentionBacklogBlocks: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_compactor_retention_backlog_blocks",
+			Help: "Number of blocks in the bucket beyond the retention period but not deleted yet, either marked for deletion or not, as of the last blocks cleanup of the tenant. A value that stays high across cleanups signals that the retention isn't keeping pace.",
+		}, []string{"user"}),
+		bytesMarkedForDeletion: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_compactor_bytes_marked_for_deletion",
+			Help: "Total size of the blocks in the bucket marked for deletion but not deleted yet, as of the last blocks cleanup of the tenant. It's the storage to be reclaimed once the deletion delay has elapsed. Blocks whose size is unknown are not included.",
+		}, []string{"user"}),
 
 		// The following metrics don't have the "cortex_compactor" prefix because not strictly related to
 		// the compactor. They're just tracked by the compactor because it's the most logical place where these
@@ -145,6 +229,24 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, own
 			Name: "cortex_bucket_index_last_successful_update_timestamp_seconds",
 			Help: "Timestamp of the last successful update of a tenant's bucket index.",
 		}, []string{"user"}),
+		tenantBlockSizes: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_bucket_block_size_bytes",
+			Help:    "Size distribution of the blocks in the bucket, not marked for deletion, as of the last update of the tenant's bucket index. Blocks whose size is unknown are not included.",
+			Buckets: prometheus.ExponentialBuckets(1<<20, 4, 10), // 1MiB to 256GiB
+		}, []string{"user"}),
+		tenantBlocksByLevel: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_bucket_blocks_by_level_count",
+			Help: "Total number of blocks in the bucket by compaction level, as of the last update of the tenant's bucket index. Blocks whose compaction level is unknown are not included.",
+		}, []string{"user", "level"}),
+		tenantBucketIndexReadDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_bucket_index_read_duration_seconds",
+			Help:    "Time spent reading and parsing a tenant's bucket index.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12), // 10ms to ~20s
+		}, []string{"user"}),
+		tenantOverlappingBlocks: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_bucket_overlapping_blocks",
+			Help: "Number of blocks in the bucket, not marked for deletion, whose time range overlaps with another block of the same compactor shard, as of the last update of the tenant's bucket index. Overlapping blocks are expected until they're compacted together: a value that stays high signals a compaction backlog or an ingestion issue.",
+		}, []string{"user"}),
 
 		bucketIndexCompactionJobs: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "cortex_bucket_index_estimated_compaction_jobs",
@@ -154,6 +256,18 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, own
 			Name: "cortex_bucket_index_estimated_compaction_jobs_errors_total",
 			Help: "Total number of failed executions of compaction job estimation based on latest version of bucket index.",
 		}),
+		bucketIndexWritesSkipped: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_bucket_index_writes_skipped_total",
+			Help: "Total number of bucket index writes skipped by the blocks cleaner because the bucket index was unchanged since the last write.",
+		}),
+		cleanupSuppressed: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_cleanup_suppressed",
+			Help: "Whether blocks deletion and retention are suppressed in the blocks cleanup, either during the configured window or via the HTTP API (1) or not (0).",
+		}),
+	}
+
+	if cfg.RetentionSource != nil {
+		c.retentionResolver = newCachingRetentionResolver(cfg.RetentionSource, cfgProvider, cfg.RetentionSourceCacheTTL)
 	}
 
 	c.Service = services.NewTimerService(cfg.CleanupInterval, c.starting, c.ticker, c.stopping)
@@ -224,7 +338,7 @@ func (c *BlocksCleaner) runCleanup(ctx context.Context, users *ownedUsers, logge
 
 func (c *BlocksCleaner) instrumentBucketIndexUpdate(ctx context.Context, users []string) {
 	for _, userID := range users {
-		idx, err := bucketindex.ReadIndex(ctx, c.bucketClient, userID, c.cfgProvider, c.logger)
+		idx, err := c.readIndex(ctx, c.bucketClient, userID, c.logger)
 		if err != nil {
 			level.Error(c.logger).Log("msg", "failed to read bucket index", "user", userID, "err", err)
 			return
@@ -233,6 +347,30 @@ func (c *BlocksCleaner) instrumentBucketIndexUpdate(ctx context.Context, users [
 	}
 }
 
+// bucketClientForUser returns the bucket client used to clean up the tenant, counting the operations performed on
+// behalf of the tenant, if enabled.
+func (c *BlocksCleaner) bucketClientForUser(userID string) objstore.Bucket {
+	if c.cfg.BucketOperations == nil {
+		return c.bucketClient
+	}
+
+	bkt, ok := c.bucketClient.(objstore.InstrumentedBucket)
+	if !ok {
+		bkt = objstore.WithNoopInstr(c.bucketClient)
+	}
+	return newOperationsCountingBucket(bkt, c.cfg.BucketOperations, userID)
+}
+
+// readIndex reads and parses the bucket index of the tenant from the input bucket, tracking the time spent doing it.
+func (c *BlocksCleaner) readIndex(ctx context.Context, bkt objstore.Bucket, userID string, logger log.Logger) (*bucketindex.Index, error) {
+	startTime := time.Now()
+	idx, err := bucketindex.ReadIndex(ctx, bkt, userID, c.cfgProvider, logger)
+	if err == nil {
+		c.tenantBucketIndexReadDuration.WithLabelValues(userID).Observe(time.Since(startTime).Seconds())
+	}
+	return idx, err
+}
+
 func (c *BlocksCleaner) instrumentStartedCleanupRun(logger log.Logger) {
 	level.Info(logger).Log("msg", "started blocks cleanup and maintenance")
 	c.runsStarted.Inc()
@@ -279,6 +417,16 @@ func (c *BlocksCleaner) refreshOwnedUsers(ctx context.Context) (*ownedUsers, err
 			c.tenantBucketIndexLastUpdate.DeleteLabelValues(userID)
 			c.bucketIndexCompactionJobs.DeleteLabelValues(userID, string(stageSplit))
 			c.bucketIndexCompactionJobs.DeleteLabelValues(userID, string(stageMerge))
+			c.futureBlocks.DeleteLabelValues(userID)
+			c.retentionBacklogBlocks.DeleteLabelValues(userID)
+			c.bytesMarkedForDeletion.DeleteLabelValues(userID)
+			c.tenantBlockSizes.DeleteLabelValues(userID)
+			c.tenantBlocksByLevel.DeletePartialMatch(prometheus.Labels{"user": userID})
+			c.tenantBucketIndexReadDuration.DeleteLabelValues(userID)
+			c.tenantOverlappingBlocks.DeleteLabelValues(userID)
+			if c.cfg.BucketOperations != nil {
+				c.cfg.BucketOperations.DeletePartialMatch(prometheus.Labels{"user": userID})
+			}
 		}
 	}
 	c.lastOwnedUsers = allUsers
@@ -287,20 +435,57 @@ func (c *BlocksCleaner) refreshOwnedUsers(ctx context.Context) (*ownedUsers, err
 
 // cleanUsers must be concurrency-safe because some invocations may take longer and overlap with the next periodic invocation.
 func (c *BlocksCleaner) cleanUsers(ctx context.Context, users *ownedUsers, logger log.Logger) error {
+	if c.updateCleanupSuppressed(time.Now()) {
+		level.Warn(logger).Log("msg", "blocks deletion and retention are suppressed, the blocks cleanup only updates the bucket indexes and tenants marked for deletion are not deleted", "suppressed_by_api", c.suppressedByAPI.Load())
+	}
+
 	return c.singleFlight.ForEachNotInFlight(ctx, users.all, func(ctx context.Context, userID string) error {
 		userLogger := util_log.WithUserID(userID, logger)
 		if users.deleted[userID] {
+			if c.isCleanupSuppressed(time.Now()) {
+				level.Info(userLogger).Log("msg", "skipped deletion of tenant marked for deletion, because blocks deletion is suppressed")
+				return nil
+			}
 			return errors.Wrapf(c.deleteUserMarkedForDeletion(ctx, userID, userLogger), "failed to delete user marked for deletion: %s", userID)
 		}
 		return errors.Wrapf(c.cleanUser(ctx, userID, userLogger), "failed to delete blocks for user: %s", userID)
 	})
 }
 
+// cleanupTenant synchronously runs the blocks cleanup and maintenance of a single tenant, without waiting for
+// the next cleanup interval. It doesn't check whether this instance owns the tenant, and returns
+// errTenantCleanupInProgress if the tenant is already being cleaned up.
+func (c *BlocksCleaner) cleanupTenant(ctx context.Context, userID string) (cleanUserSummary, error) {
+	var (
+		summary cleanUserSummary
+		ran     bool
+	)
+
+	err := c.singleFlight.ForEachNotInFlight(ctx, []string{userID}, func(ctx context.Context, userID string) error {
+		ran = true
+
+		var err error
+		summary, err = c.cleanUserWithSummary(ctx, userID, util_log.WithUserID(userID, c.logger))
+		return err
+	})
+	if err != nil {
+		return summary, err
+	}
+	if !ran {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		return summary, errTenantCleanupInProgress
+	}
+
+	return summary, nil
+}
+
 // deleteRemainingData removes any additional files that may remain when a user has no blocks. Should only
 // be called when there no more blocks remaining.
-func (c *BlocksCleaner) deleteRemainingData(ctx context.Context, userBucket objstore.Bucket, userID string, userLogger log.Logger) error {
+func (c *BlocksCleaner) deleteRemainingData(ctx context.Context, bkt, userBucket objstore.Bucket, userID string, userLogger log.Logger) error {
 	// Delete bucket index
-	if err := bucketindex.DeleteIndex(ctx, c.bucketClient, userID, c.cfgProvider); err != nil {
+	if err := bucketindex.DeleteIndex(ctx, bkt, userID, c.cfgProvider); err != nil {
 		return errors.Wrap(err, "failed to delete bucket index file")
 	}
 	level.Info(userLogger).Log("msg", "deleted bucket index for tenant with no blocks remaining")
@@ -319,18 +504,24 @@ func (c *BlocksCleaner) deleteRemainingData(ctx context.Context, userBucket objs
 		level.Info(userLogger).Log("msg", "deleted files under "+block.DebugMetas+" for tenant with no blocks remaining", "count", deleted)
 	}
 
+	// Delete the tenant compaction record
+	if err := deleteTenantCompactionRecord(ctx, userBucket); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // deleteUserMarkedForDeletion removes blocks and remaining data for tenant marked for deletion.
 func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID string, userLogger log.Logger) error {
-	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+	bkt := c.bucketClientForUser(userID)
+	userBucket := bucket.NewUserBucketClient(userID, bkt, c.cfgProvider)
 
 	level.Info(userLogger).Log("msg", "deleting blocks for tenant marked for deletion")
 
 	// We immediately delete the bucket index, to signal to its consumers that
 	// the tenant has "no blocks" in the storage.
-	if err := bucketindex.DeleteIndex(ctx, c.bucketClient, userID, c.cfgProvider); err != nil {
+	if err := bucketindex.DeleteIndex(ctx, bkt, userID, c.cfgProvider); err != nil {
 		return err
 	}
 	c.tenantBucketIndexLastUpdate.DeleteLabelValues(userID)
@@ -380,12 +571,19 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 	c.tenantPartialBlocks.DeleteLabelValues(userID)
 	c.bucketIndexCompactionJobs.DeleteLabelValues(userID, string(stageSplit))
 	c.bucketIndexCompactionJobs.DeleteLabelValues(userID, string(stageMerge))
+	c.futureBlocks.DeleteLabelValues(userID)
+	c.retentionBacklogBlocks.DeleteLabelValues(userID)
+	c.bytesMarkedForDeletion.DeleteLabelValues(userID)
+	c.tenantBlockSizes.DeleteLabelValues(userID)
+	c.tenantBlocksByLevel.DeletePartialMatch(prometheus.Labels{"user": userID})
+	c.tenantBucketIndexReadDuration.DeleteLabelValues(userID)
+	c.tenantOverlappingBlocks.DeleteLabelValues(userID)
 
 	if deletedBlocks > 0 {
 		level.Info(userLogger).Log("msg", "deleted blocks for tenant marked for deletion", "deletedBlocks", deletedBlocks)
 	}
 
-	mark, err := mimir_tsdb.ReadTenantDeletionMark(ctx, c.bucketClient, userID, c.logger)
+	mark, err := mimir_tsdb.ReadTenantDeletionMark(ctx, bkt, userID, c.logger)
 	if err != nil {
 		return errors.Wrap(err, "failed to read tenant deletion mark")
 	}
@@ -399,7 +597,7 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 	if deletedBlocks > 0 || mark.FinishedTime == 0 {
 		level.Info(userLogger).Log("msg", "updating finished time in tenant deletion mark")
 		mark.FinishedTime = util.UnixSecondsFromTime(time.Now())
-		return errors.Wrap(mimir_tsdb.WriteTenantDeletionMark(ctx, c.bucketClient, userID, c.cfgProvider, mark), "failed to update tenant deletion mark")
+		return errors.Wrap(mimir_tsdb.WriteTenantDeletionMark(ctx, bkt, userID, c.cfgProvider, mark), "failed to update tenant deletion mark")
 	}
 
 	if time.Since(mark.FinishedTime.Time()) < c.cfg.TenantCleanupDelay {
@@ -415,6 +613,10 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 		level.Info(userLogger).Log("msg", "deleted files under "+block.DebugMetas+" for tenant marked for deletion", "count", deleted)
 	}
 
+	if err := deleteTenantCompactionRecord(ctx, userBucket); err != nil {
+		return err
+	}
+
 	// Tenant deletion mark file is inside Markers as well.
 	if deleted, err := bucket.DeletePrefix(ctx, userBucket, block.MarkersPathname, userLogger); err != nil {
 		return errors.Wrap(err, "failed to delete marker files")
@@ -425,8 +627,27 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 	return nil
 }
 
-func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, userLogger log.Logger) (returnErr error) {
-	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+// cleanUserSummary summarizes the outcome of the blocks cleanup and maintenance of a tenant.
+type cleanUserSummary struct {
+	// blocksMarkedForDeletion is the number of blocks marked for deletion because they were beyond
+	// the retention period, or because they were stale partial blocks.
+	blocksMarkedForDeletion int
+	// blocksDeleted is the number of blocks deleted because they were marked for deletion.
+	blocksDeleted int
+	// bucketIndexUpdatedAt is the time the bucket index in the storage was generated at. It's zero if no bucket
+	// index was written, because the tenant has no blocks left.
+	bucketIndexUpdatedAt time.Time
+}
+
+func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, userLogger log.Logger) error {
+	_, err := c.cleanUserWithSummary(ctx, userID, userLogger)
+	return err
+}
+
+func (c *BlocksCleaner) cleanUserWithSummary(ctx context.Context, userID string, userLogger log.Logger) (summary cleanUserSummary, returnErr error) {
+	// All the operations performed on behalf of the tenant, including the bucket index ones, are counted.
+	bkt := c.bucketClientForUser(userID)
+	userBucket := bucket.NewUserBucketClient(userID, bkt, c.cfgProvider)
 	startTime := time.Now()
 
 	level.Info(userLogger).Log("msg", "started blocks cleanup and maintenance")
@@ -439,38 +660,65 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, userLogger
 	}()
 
 	// Read the bucket index.
-	idx, err := bucketindex.ReadIndex(ctx, c.bucketClient, userID, c.cfgProvider, userLogger)
+	idx, err := c.readIndex(ctx, bkt, userID, userLogger)
 	if errors.Is(err, bucketindex.ErrIndexCorrupted) {
 		level.Warn(userLogger).Log("msg", "found a corrupted bucket index, recreating it")
 	} else if err != nil && !errors.Is(err, bucketindex.ErrIndexNotFound) {
-		return err
+		return summary, err
 	}
 
 	level.Info(userLogger).Log("msg", "fetched existing bucket index")
 
+	var storedIndexUpdatedAt int64
+	if idx != nil {
+		storedIndexUpdatedAt = idx.UpdatedAt
+	}
+
+	retention := c.retentionPeriod(ctx, userID, userLogger)
+
+	// Blocks deletion and retention are checked once per tenant, so that they're consistently skipped or not for the
+	// whole cleanup of the tenant.
+	suppressed := c.isCleanupSuppressed(time.Now())
+	if suppressed {
+		level.Info(userLogger).Log("msg", "skipping blocks deletion and retention, because they're suppressed")
+	}
+
 	// Mark blocks for future deletion based on the retention period for the user.
 	// Note doing this before UpdateIndex, so it reads in the deletion marks.
 	// The trade-off being that retention is not applied if the index has to be
 	// built, but this is rare.
-	if idx != nil {
+	if idx != nil && !suppressed {
 		// We do not want to stop the remaining work in the cleaner if an
 		// error occurs here. Errors are logged in the function.
-		retention := c.cfgProvider.CompactorBlocksRetentionPeriod(userID)
-		c.applyUserRetentionPeriod(ctx, idx, retention, userBucket, userLogger)
+		summary.blocksMarkedForDeletion += c.applyUserRetentionPeriod(ctx, idx, retention, userBucket, userLogger)
+		summary.blocksMarkedForDeletion += c.applyUserMaxSeries(ctx, userID, idx, c.cfgProvider.CompactorMaxSeries(userID), retention, userBucket, userLogger)
+
+		if c.cfg.SupersededBlocksCleanupEnabled {
+			summary.blocksMarkedForDeletion += c.markSupersededBlocks(ctx, idx, userBucket, userLogger)
+		}
+
+		if c.cfg.FutureBlocksTolerance > 0 {
+			c.markFutureBlocksForNoCompaction(ctx, idx, userID, userBucket, userLogger)
+		}
 	}
 
 	// Generate an updated in-memory version of the bucket index.
-	w := bucketindex.NewUpdater(c.bucketClient, userID, c.cfgProvider, c.cfg.GetDeletionMarkersConcurrency, c.cfg.UpdateBlocksConcurrency, userLogger)
+	w := bucketindex.NewUpdater(bkt, userID, c.cfgProvider, c.cfg.GetDeletionMarkersConcurrency, c.cfg.UpdateBlocksConcurrency, userLogger)
 	idx, partials, err := w.UpdateIndex(ctx, idx)
 	if err != nil {
-		return err
+		return summary, err
 	}
 
-	c.deleteBlocksMarkedForDeletion(ctx, idx, userBucket, userLogger)
+	// Best effort: on failure, the no-compaction state of the blocks in the index is left as is.
+	c.reconcileNoCompactMarks(ctx, idx, userBucket, userLogger)
+
+	if !suppressed {
+		summary.blocksDeleted += c.deleteBlocksMarkedForDeletion(ctx, idx, userBucket, userLogger)
+	}
 
 	// Partial blocks with a deletion mark can be cleaned up. This is a best effort, so we don't return
 	// error if the cleanup of partial blocks fail.
-	if len(partials) > 0 {
+	if len(partials) > 0 && !suppressed {
 		var partialDeletionCutoffTime time.Time // zero value, disabled.
 		if delay, valid := c.cfgProvider.CompactorPartialBlockDeletionDelay(userID); delay > 0 {
 			// enable cleanup of partial blocks without deletion marker
@@ -479,29 +727,46 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, userLogger
 			level.Warn(userLogger).Log("msg", "partial blocks deletion has been disabled for tenant because the delay has been set lower than the minimum value allowed", "minimum", validation.MinCompactorPartialBlockDeletionDelay)
 		}
 
-		c.cleanUserPartialBlocks(ctx, partials, idx, partialDeletionCutoffTime, userBucket, userLogger)
+		deleted, marked := c.cleanUserPartialBlocks(ctx, partials, idx, partialDeletionCutoffTime, userBucket, userLogger)
+		summary.blocksDeleted += deleted
+		summary.blocksMarkedForDeletion += marked
 		level.Info(userLogger).Log("msg", "cleaned up partial blocks", "partials", len(partials))
 	}
 
 	// If there are no more blocks, clean up any remaining files
 	// Otherwise upload the updated index to the storage.
-	if c.cfg.NoBlocksFileCleanupEnabled && len(idx.Blocks) == 0 {
-		if err := c.deleteRemainingData(ctx, userBucket, userID, userLogger); err != nil {
-			return err
+	if c.cfg.NoBlocksFileCleanupEnabled && c.cfgProvider.CompactorNoBlocksFileCleanupEnabled(userID) && len(idx.Blocks) == 0 && !suppressed {
+		if err := c.deleteRemainingData(ctx, bkt, userBucket, userID, userLogger); err != nil {
+			return summary, err
 		}
+		c.untrackWrittenIndex(userID)
 	} else {
-		if err := bucketindex.WriteIndex(ctx, c.bucketClient, userID, c.cfgProvider, idx); err != nil {
-			return err
+		if err := c.writeIndex(ctx, bkt, userID, idx, storedIndexUpdatedAt, userLogger); err != nil {
+			return summary, err
 		}
+		summary.bucketIndexUpdatedAt = idx.GetUpdatedAt()
 	}
 
 	c.tenantBlocks.WithLabelValues(userID).Set(float64(len(idx.Blocks)))
 	c.tenantMarkedBlocks.WithLabelValues(userID).Set(float64(len(idx.BlockDeletionMarks)))
 	c.tenantPartialBlocks.WithLabelValues(userID).Set(float64(len(partials)))
 	c.tenantBucketIndexLastUpdate.WithLabelValues(userID).Set(float64(idx.UpdatedAt))
+	c.retentionBacklogBlocks.WithLabelValues(userID).Set(float64(countBlocksOutsideRetentionPeriod(idx, retention)))
+	c.bytesMarkedForDeletion.WithLabelValues(userID).Set(float64(sumBlocksMarkedForDeletionSize(idx)))
+	c.tenantOverlappingBlocks.WithLabelValues(userID).Set(float64(countOverlappingBlocks(idx)))
+	if c.cfg.BlockSizeMetricsEnabled {
+		c.updateTenantBlockSizes(userID, idx)
+	}
+	if c.cfg.BlockLevelMetricsEnabled {
+		c.updateTenantBlocksByLevel(userID, idx)
+	}
 
 	// Compute pending compaction jobs based on current index.
-	jobs, err := estimateCompactionJobsFromBucketIndex(ctx, userID, userBucket, idx, c.cfg.CompactionBlockRanges, c.cfgProvider.CompactorSplitAndMergeShards(userID), c.cfgProvider.CompactorSplitGroups(userID))
+	blockRanges := c.cfg.CompactionBlockRanges
+	if tenantRanges := c.cfgProvider.CompactorTenantBlockRanges(userID); len(tenantRanges) > 0 {
+		blockRanges = tenantRanges
+	}
+	jobs, err := estimateCompactionJobsFromBucketIndex(ctx, userID, userBucket, idx, blockRanges, c.cfgProvider.CompactorSplitAndMergeShards(userID), c.cfgProvider.CompactorSplitGroups(userID), c.cfgProvider.CompactorRequiredGroupingLabels(userID))
 	if err != nil {
 		// When compactor is shutting down, we get context cancellation. There's no reason to report that as error.
 		if !errors.Is(err, context.Canceled) {
@@ -517,9 +782,67 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string, userLogger
 		c.bucketIndexCompactionJobs.WithLabelValues(userID, string(stageMerge)).Set(float64(mergeJobs))
 	}
 
+	return summary, nil
+}
+
+// writeIndex writes the updated bucket index of the tenant to the storage, unless it's unchanged since the last time
+// it was written and the write can be skipped according to UnchangedIndexWriteSkipPeriod. When the write is skipped,
+// the updated-at timestamp of the input index is reset to the one of the index in the storage, whose updated-at
+// timestamp is storedUpdatedAt.
+func (c *BlocksCleaner) writeIndex(ctx context.Context, bkt objstore.Bucket, userID string, idx *bucketindex.Index, storedUpdatedAt int64, userLogger log.Logger) error {
+	if c.cfg.UnchangedIndexWriteSkipPeriod <= 0 {
+		return bucketindex.WriteIndex(ctx, bkt, userID, c.cfgProvider, idx)
+	}
+
+	hash, err := indexContentHash(idx)
+	if err != nil {
+		// Never skip the write of an index which can't be hashed: the write is going to fail anyway.
+		c.untrackWrittenIndex(userID)
+		return bucketindex.WriteIndex(ctx, bkt, userID, c.cfgProvider, idx)
+	}
+
+	c.writtenIndexesMx.Lock()
+	last, ok := c.writtenIndexes[userID]
+	c.writtenIndexesMx.Unlock()
+
+	// The write is only skipped if the index in the storage is still the one last written by this blocks cleaner,
+	// and it's not older than the skip period.
+	if ok && last.hash == hash && last.updatedAt == storedUpdatedAt && time.Since(time.Unix(last.updatedAt, 0)) < c.cfg.UnchangedIndexWriteSkipPeriod {
+		c.bucketIndexWritesSkipped.Inc()
+		level.Info(userLogger).Log("msg", "skipped writing unchanged bucket index", "updated_at", time.Unix(last.updatedAt, 0).UTC().Format(time.RFC3339))
+		idx.UpdatedAt = last.updatedAt
+		return nil
+	}
+
+	if err := bucketindex.WriteIndex(ctx, bkt, userID, c.cfgProvider, idx); err != nil {
+		c.untrackWrittenIndex(userID)
+		return err
+	}
+
+	c.writtenIndexesMx.Lock()
+	c.writtenIndexes[userID] = writtenIndex{hash: hash, updatedAt: idx.UpdatedAt}
+	c.writtenIndexesMx.Unlock()
 	return nil
 }
 
+func (c *BlocksCleaner) untrackWrittenIndex(userID string) {
+	c.writtenIndexesMx.Lock()
+	delete(c.writtenIndexes, userID)
+	c.writtenIndexesMx.Unlock()
+}
+
+// indexContentHash returns the hash of the content of the input bucket index, excluding its updated-at timestamp.
+func indexContentHash(idx *bucketindex.Index) (uint64, error) {
+	content := *idx
+	content.UpdatedAt = 0
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0, err
+	}
+	return xxhash.Sum64(data), nil
+}
+
 func computeSplitAndMergeJobs(jobs []*Job) (splitJobs int, mergeJobs int) {
 	for _, j := range jobs {
 		if j.UseSplitting() {
@@ -531,8 +854,8 @@ func computeSplitAndMergeJobs(jobs []*Job) (splitJobs int, mergeJobs int) {
 	return splitJobs, mergeJobs
 }
 
-// Concurrently deletes blocks marked for deletion, and removes blocks from index.
-func (c *BlocksCleaner) deleteBlocksMarkedForDeletion(ctx context.Context, idx *bucketindex.Index, userBucket objstore.Bucket, userLogger log.Logger) {
+// Concurrently deletes blocks marked for deletion, and removes blocks from index. Returns the number of deleted blocks.
+func (c *BlocksCleaner) deleteBlocksMarkedForDeletion(ctx context.Context, idx *bucketindex.Index, userBucket objstore.Bucket, userLogger log.Logger) (deleted int) {
 	blocksToDelete := make([]ulid.ULID, 0, len(idx.BlockDeletionMarks))
 
 	// Collect blocks marked for deletion into buffered channel.
@@ -558,17 +881,21 @@ func (c *BlocksCleaner) deleteBlocksMarkedForDeletion(ctx context.Context, idx *
 		// Remove the block from the bucket index too.
 		mu.Lock()
 		idx.RemoveBlock(blockID)
+		deleted++
 		mu.Unlock()
 
 		c.blocksCleanedTotal.Inc()
 		level.Info(userLogger).Log("msg", "deleted block marked for deletion", "block", blockID)
 		return nil
 	})
+
+	return deleted
 }
 
 // cleanUserPartialBlocks deletes partial blocks which are safe to be deleted. The provided index is updated accordingly.
 // partialDeletionCutoffTime, if not zero, is used to find blocks without deletion marker that were last modified before this time. Such blocks will be marked for deletion.
-func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, idx *bucketindex.Index, partialDeletionCutoffTime time.Time, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
+// Returns the number of deleted partial blocks, and the number of partial blocks marked for deletion.
+func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, idx *bucketindex.Index, partialDeletionCutoffTime time.Time, userBucket objstore.InstrumentedBucket, userLogger log.Logger) (deleted, marked int) {
 	// Collect all blocks with missing meta.json or inconsistent deletion markers.
 	blocks := make([]ulid.ULID, 0, len(partials))
 
@@ -580,6 +907,16 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 		}
 	}
 
+	// Only process the oldest partial blocks if there are too many, leaving the others to the next cleanups.
+	if limit := c.cfg.MaxPartialBlocksPerCleanup; limit > 0 && len(blocks) > limit {
+		slices.SortFunc(blocks, func(a, b ulid.ULID) int { return a.Compare(b) })
+
+		deferred := len(blocks) - limit
+		blocks = blocks[:limit]
+		c.partialBlocksDeferred.Add(float64(deferred))
+		level.Info(userLogger).Log("msg", "deferred the cleanup of partial blocks to the next cleanups", "processed", limit, "deferred", deferred)
+	}
+
 	var mu sync.Mutex
 	var partialBlocksWithoutDeletionMarker []ulid.ULID
 
@@ -612,6 +949,7 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 		mu.Lock()
 		idx.RemoveBlock(blockID)
 		delete(partials, blockID)
+		deleted++
 		mu.Unlock()
 
 		c.blocksCleanedTotal.Inc()
@@ -631,17 +969,30 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 				level.Info(userLogger).Log("msg", "stale partial block found: marking block for deletion", "block", blockID, "last modified", lastModified)
 				if err := block.MarkForDeletion(ctx, userLogger, userBucket, blockID, "stale partial block", c.partialBlocksMarkedForDeletion); err != nil {
 					level.Warn(userLogger).Log("msg", "failed to mark partial block for deletion", "block", blockID, "err", err)
+				} else {
+					marked++
 				}
 			}
 		}
 	}
+
+	return deleted, marked
+}
+
+// retentionPeriod returns the blocks retention period of the tenant, read from the RetentionSource if configured.
+func (c *BlocksCleaner) retentionPeriod(ctx context.Context, userID string, userLogger log.Logger) time.Duration {
+	if c.retentionResolver == nil {
+		return c.cfgProvider.CompactorBlocksRetentionPeriod(userID)
+	}
+	return c.retentionResolver.retentionPeriod(ctx, userID, userLogger)
 }
 
 // applyUserRetentionPeriod marks blocks for deletion which have aged past the retention period.
-func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) {
+// Returns the number of blocks successfully marked for deletion.
+func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) (marked int) {
 	// The retention period of zero is a special value indicating to never delete.
 	if retention <= 0 {
-		return
+		return 0
 	}
 
 	blocks := listBlocksOutsideRetentionPeriod(idx, time.Now().Add(-retention))
@@ -652,9 +1003,120 @@ func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucke
 		level.Info(userLogger).Log("msg", "applied retention: marking block for deletion", "block", b.ID, "maxTime", b.MaxTime)
 		if err := block.MarkForDeletion(ctx, userLogger, userBucket, b.ID, fmt.Sprintf("block exceeding retention of %v", retention), c.blocksMarkedForDeletion); err != nil {
 			level.Warn(userLogger).Log("msg", "failed to mark block for deletion", "block", b.ID, "err", err)
+			continue
 		}
+		marked++
 	}
 	level.Info(userLogger).Log("msg", "marked blocks for deletion", "num_blocks", len(blocks), "retention", retention.String())
+
+	return marked
+}
+
+// applyUserMaxSeries marks for deletion the oldest blocks exceeding the max number of series of the tenant, counting
+// each series once per block. The blocks are kept newest first, until their series exceed the limit: that block and
+// all the older ones are marked, but the blocks of the newest time range are always kept. The blocks already marked
+// for deletion, or beyond the retention period and so just marked by applyUserRetentionPeriod, are not counted. Returns the number of blocks successfully marked for deletion.
+func (c *BlocksCleaner) applyUserMaxSeries(ctx context.Context, userID string, idx *bucketindex.Index, maxSeries int64, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) (marked int) {
+	if maxSeries <= 0 {
+		return 0
+	}
+
+	blocks, totalSeries, unknown := listBlocksExceedingMaxSeries(idx, uint64(maxSeries), retention, time.Now())
+	if c.shouldWarnUnknownSeries(userID, unknown) {
+		level.Warn(userLogger).Log("msg", "the number of series of some blocks is unknown, counting them as empty when applying max series", "blocks_with_unknown_series", unknown, "max_series", maxSeries)
+	}
+	if len(blocks) == 0 {
+		return 0
+	}
+
+	// Attempt to mark all blocks. It is not critical if a marking fails, as
+	// the cleaner will retry applying the limit in its next cycle.
+	for _, b := range blocks {
+		level.Info(userLogger).Log("msg", "applied max series: marking block for deletion", "block", b.ID, "maxTime", b.MaxTime, "series", b.NumSeries)
+		if err := block.MarkForDeletion(ctx, userLogger, userBucket, b.ID, fmt.Sprintf("block exceeding max series of %d", maxSeries), c.seriesCapBlocksMarkedForDeletion); err != nil {
+			level.Warn(userLogger).Log("msg", "failed to mark block for deletion", "block", b.ID, "err", err)
+			continue
+		}
+		marked++
+	}
+	level.Info(userLogger).Log("msg", "marked blocks exceeding max series for deletion", "num_blocks", len(blocks), "max_series", maxSeries, "total_series", totalSeries)
+
+	return marked
+}
+
+// listBlocksExceedingMaxSeries returns the blocks exceeding the max number of series, and the total number of series
+// of the blocks considered. Only the compacted blocks, not marked for deletion and within the retention period at the
+// input time, are considered: the blocks uploaded by the ingesters overlap with each other, because each series is
+// replicated to multiple ingesters, so their series can't be summed. The considered blocks are sorted from the newest
+// to the oldest one, and the blocks from the first one whose series exceed the limit are returned, except the blocks
+// of the newest time range, which are always kept. The blocks whose number of series is unknown are counted as empty,
+// and the number of such blocks is returned too.
+func listBlocksExceedingMaxSeries(idx *bucketindex.Index, maxSeries uint64, retention time.Duration, now time.Time) (result bucketindex.Blocks, totalSeries uint64, unknown int) {
+	marked := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, d := range idx.BlockDeletionMarks {
+		marked[d.ID] = struct{}{}
+	}
+
+	blocks := make(bucketindex.Blocks, 0, len(idx.Blocks))
+	for _, b := range idx.Blocks {
+		if b.CompactionLevel <= 1 {
+			continue
+		}
+		if _, isMarked := marked[b.ID]; isMarked {
+			continue
+		}
+		if retention > 0 && isBlockOutsideRetentionPeriod(b, now.Add(-retention)) {
+			continue
+		}
+		if !b.HasStats {
+			unknown++
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Newest blocks first. The blocks with the same time range, like the split blocks, are sorted by ID, so that
+	// the same blocks are kept on each run.
+	slices.SortFunc(blocks, func(a, b *bucketindex.Block) int {
+		if a.MaxTime != b.MaxTime {
+			return cmp.Compare(b.MaxTime, a.MaxTime)
+		}
+		if a.MinTime != b.MinTime {
+			return cmp.Compare(b.MinTime, a.MinTime)
+		}
+		return a.ID.Compare(b.ID)
+	})
+
+	// The newest time range is always kept, with all its split blocks, even if it exceeds the limit on its own:
+	// deleting it would leave the tenant without any compacted block.
+	newest := 0
+	for newest < len(blocks) && blocks[newest].MinTime == blocks[0].MinTime && blocks[newest].MaxTime == blocks[0].MaxTime {
+		newest++
+	}
+
+	for i, b := range blocks {
+		totalSeries += b.NumSeries
+		if totalSeries > maxSeries && result == nil {
+			result = blocks[max(i, newest):]
+		}
+	}
+	return result, totalSeries, unknown
+}
+
+// shouldWarnUnknownSeries returns whether to warn that the input number of blocks of the tenant have unknown number of
+// series. The warning is only due once, until the tenant has no more such blocks.
+func (c *BlocksCleaner) shouldWarnUnknownSeries(userID string, unknown int) bool {
+	c.unknownSeriesWarnedMx.Lock()
+	defer c.unknownSeriesWarnedMx.Unlock()
+
+	if unknown == 0 {
+		delete(c.unknownSeriesWarned, userID)
+		return false
+	}
+	if _, warned := c.unknownSeriesWarned[userID]; warned {
+		return false
+	}
+	c.unknownSeriesWarned[userID] = struct{}{}
+	return true
 }
 
 // listBlocksOutsideRetentionPeriod determines the blocks which have aged past
@@ -669,8 +1131,7 @@ func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Tim
 	}
 
 	for _, b := range idx.Blocks {
-		maxTime := time.Unix(b.MaxTime/1000, 0)
-		if maxTime.Before(threshold) {
+		if isBlockOutsideRetentionPeriod(b, threshold) {
 			if _, isMarked := marked[b.ID]; !isMarked {
 				result = append(result, b)
 			}
@@ -680,6 +1141,245 @@ func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Tim
 	return
 }
 
+// countBlocksOutsideRetentionPeriod returns the number of blocks in the index which have aged past the retention
+// period, whether they're marked for deletion or not.
+func countBlocksOutsideRetentionPeriod(idx *bucketindex.Index, retention time.Duration) (count int) {
+	// The retention period of zero is a special value indicating to never delete.
+	if retention <= 0 {
+		return 0
+	}
+
+	threshold := time.Now().Add(-retention)
+	for _, b := range idx.Blocks {
+		if isBlockOutsideRetentionPeriod(b, threshold) {
+			count++
+		}
+	}
+	return count
+}
+
+// sumBlocksMarkedForDeletionSize returns the total size of the blocks in the index marked for deletion. Blocks whose
+// size is unknown, and deletion marks of blocks not in the index, are not included.
+func sumBlocksMarkedForDeletionSize(idx *bucketindex.Index) (size int64) {
+	deletionMarks := idx.BlockDeletionMarks.GetULIDs()
+	marked := make(map[ulid.ULID]struct{}, len(deletionMarks))
+	for _, id := range deletionMarks {
+		marked[id] = struct{}{}
+	}
+
+	for _, b := range idx.Blocks {
+		if _, ok := marked[b.ID]; ok && b.SizeBytes > 0 {
+			size += b.SizeBytes
+		}
+	}
+	return size
+}
+
+// countOverlappingBlocks returns the number of blocks in the index, not marked for deletion, whose time range overlaps
+// with another block of the same compactor shard. Blocks of different compactor shards are expected to overlap, so
+// they're not compared with each other.
+func countOverlappingBlocks(idx *bucketindex.Index) (count int) {
+	deletionMarks := idx.BlockDeletionMarks.GetULIDs()
+	marked := make(map[ulid.ULID]struct{}, len(deletionMarks))
+	for _, id := range deletionMarks {
+		marked[id] = struct{}{}
+	}
+
+	metasByShard := map[string][]tsdb.BlockMeta{}
+	for _, b := range idx.Blocks {
+		if _, ok := marked[b.ID]; ok {
+			continue
+		}
+		metasByShard[b.CompactorShardID] = append(metasByShard[b.CompactorShardID], tsdb.BlockMeta{ULID: b.ID, MinTime: b.MinTime, MaxTime: b.MaxTime})
+	}
+
+	for _, metas := range metasByShard {
+		// tsdb.OverlappingBlocks requires the blocks to be sorted by min time.
+		slices.SortFunc(metas, func(a, b tsdb.BlockMeta) int {
+			return cmp.Compare(a.MinTime, b.MinTime)
+		})
+
+		// A block can belong to multiple overlapping groups, so blocks are deduplicated.
+		overlapping := map[ulid.ULID]struct{}{}
+		for _, group := range tsdb.OverlappingBlocks(metas) {
+			for _, m := range group {
+				overlapping[m.ULID] = struct{}{}
+			}
+		}
+		count += len(overlapping)
+	}
+	return count
+}
+
+// isBlockOutsideRetentionPeriod returns whether the block has aged past the specified retention threshold.
+func isBlockOutsideRetentionPeriod(b *bucketindex.Block, threshold time.Time) bool {
+	maxTime := time.Unix(b.MaxTime/1000, 0)
+	return maxTime.Before(threshold)
+}
+
+// markSupersededBlocks marks for deletion the blocks whose sources are fully included in other compacted blocks
+// not marked for deletion. The compactor marks the source blocks of a compaction for deletion right after uploading
+// the compacted blocks, so superseded blocks are normally left behind only when the compactor is interrupted in between.
+// The compactor garbage collects them with the same ShardAwareDeduplicateFilter, but only among the blocks within its
+// max lookback period, so the superseded blocks uploaded before it would never be deleted otherwise.
+// The blocks marked for no-compaction are neither considered superseded nor superseding, like the blocks whose sources
+// haven't been backfilled in the bucket index yet. Returns the number of blocks successfully marked for deletion.
+func (c *BlocksCleaner) markSupersededBlocks(ctx context.Context, idx *bucketindex.Index, userBucket objstore.Bucket, userLogger log.Logger) (marked int) {
+	noCompact, err := block.ListBlockNoCompactMarks(ctx, userBucket)
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to look for superseded blocks", "err", err)
+		return 0
+	}
+
+	deleted := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, id := range idx.BlockDeletionMarks.GetULIDs() {
+		deleted[id] = struct{}{}
+	}
+
+	metas := make(map[ulid.ULID]*block.Meta, len(idx.Blocks))
+	for _, b := range idx.Blocks {
+		if _, isDeleted := deleted[b.ID]; isDeleted {
+			continue
+		}
+		if _, isNoCompact := noCompact[b.ID]; isNoCompact {
+			continue
+		}
+		if b.Sources == nil {
+			continue
+		}
+		metas[b.ID] = b.ThanosMeta()
+	}
+
+	filter := NewShardAwareDeduplicateFilter()
+	if err := filter.Filter(ctx, metas, newNoopGaugeVec()); err != nil {
+		level.Warn(userLogger).Log("msg", "failed to look for superseded blocks", "err", err)
+		return 0
+	}
+
+	// Attempt to mark all blocks. It is not critical if a marking fails, as
+	// the cleaner will retry in its next cycle.
+	for _, id := range filter.DuplicateIDs() {
+		level.Info(userLogger).Log("msg", "marking superseded block for deletion", "block", id)
+		if err := block.MarkForDeletion(ctx, userLogger, userBucket, id, "block superseded by compacted blocks", c.supersededBlocksMarked); err != nil {
+			level.Warn(userLogger).Log("msg", "failed to mark superseded block for deletion", "block", id, "err", err)
+			continue
+		}
+		marked++
+	}
+	if marked > 0 {
+		level.Info(userLogger).Log("msg", "marked superseded blocks for deletion", "num_blocks", marked)
+	}
+
+	return marked
+}
+
+// updateTenantBlockSizes replaces the block size distribution of the tenant with the one of the blocks in the input
+// bucket index, not marked for deletion.
+func (c *BlocksCleaner) updateTenantBlockSizes(userID string, idx *bucketindex.Index) {
+	marked := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, d := range idx.BlockDeletionMarks {
+		marked[d.ID] = struct{}{}
+	}
+
+	// The histogram tracks the blocks currently in the bucket, so observations from previous runs are discarded.
+	c.tenantBlockSizes.DeleteLabelValues(userID)
+	sizes := c.tenantBlockSizes.WithLabelValues(userID)
+	for _, b := range idx.Blocks {
+		if _, isMarked := marked[b.ID]; isMarked || b.SizeBytes <= 0 {
+			continue
+		}
+		sizes.Observe(float64(b.SizeBytes))
+	}
+}
+
+// updateTenantBlocksByLevel replaces the number of blocks of the tenant by compaction level with the one of the
+// blocks in the input bucket index. Many blocks at the lowest levels signal that the compaction isn't keeping up.
+func (c *BlocksCleaner) updateTenantBlocksByLevel(userID string, idx *bucketindex.Index) {
+	counts := map[int]int{}
+	for _, b := range idx.Blocks {
+		// The compaction level isn't tracked in the bucket indexes written by older versions.
+		if b.CompactionLevel <= 0 {
+			continue
+		}
+		counts[b.CompactionLevel]++
+	}
+
+	// Levels without blocks anymore are removed.
+	c.tenantBlocksByLevel.DeletePartialMatch(prometheus.Labels{"user": userID})
+	for level, count := range counts {
+		c.tenantBlocksByLevel.WithLabelValues(userID, strconv.Itoa(level)).Set(float64(count))
+	}
+}
+
+// reconcileNoCompactMarks updates the no-compaction state of the blocks in the bucket index with the no-compact marks
+// in the bucket, so that the consumers reading only the bucket index know which blocks are excluded from compaction.
+func (c *BlocksCleaner) reconcileNoCompactMarks(ctx context.Context, idx *bucketindex.Index, userBucket objstore.Bucket, userLogger log.Logger) {
+	marked, err := block.ListBlockNoCompactMarks(ctx, userBucket)
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to reconcile the bucket index with the no-compact marks", "err", err)
+		return
+	}
+
+	reconciled := 0
+	for _, b := range idx.Blocks {
+		_, noCompact := marked[b.ID]
+		if b.NoCompact != noCompact {
+			b.NoCompact = noCompact
+			reconciled++
+		}
+	}
+
+	if reconciled > 0 {
+		c.noCompactBlocksReconciled.Add(float64(reconciled))
+		level.Info(userLogger).Log("msg", "reconciled the bucket index with the no-compact marks", "blocks", reconciled)
+	}
+}
+
+// markFutureBlocksForNoCompaction marks for no-compaction the blocks whose min time is further in the future than
+// the configured tolerance, which could be caused by clock skew or bad ingestion. Compacting such blocks would spread
+// the bad samples to the compacted blocks, so they're excluded from compaction until an operator investigates.
+func (c *BlocksCleaner) markFutureBlocksForNoCompaction(ctx context.Context, idx *bucketindex.Index, userID string, userBucket objstore.Bucket, userLogger log.Logger) {
+	threshold := time.Now().Add(c.cfg.FutureBlocksTolerance).UnixMilli()
+
+	for _, b := range listBlocksWithMinTimeAfter(idx, threshold) {
+		// Marking a block twice logs a warning, so we check whether it's already marked first.
+		exists, err := userBucket.Exists(ctx, path.Join(b.ID.String(), block.NoCompactMarkFilename))
+		if err != nil {
+			level.Warn(userLogger).Log("msg", "failed to check no-compaction mark of block with future timestamps", "block", b.ID, "err", err)
+			continue
+		}
+		if exists {
+			continue
+		}
+
+		level.Warn(userLogger).Log("msg", "found block with future timestamps: marking block for no-compaction", "block", b.ID, "minTime", b.MinTime, "maxTime", b.MaxTime)
+		details := fmt.Sprintf("block min time %d is more than %v in the future", b.MinTime, c.cfg.FutureBlocksTolerance)
+		if err := block.MarkForNoCompact(ctx, userLogger, userBucket, b.ID, block.FutureTimestampsNoCompactReason, details, c.futureBlocks.WithLabelValues(userID)); err != nil {
+			level.Warn(userLogger).Log("msg", "failed to mark block with future timestamps for no-compaction", "block", b.ID, "err", err)
+		}
+	}
+}
+
+// listBlocksWithMinTimeAfter returns the blocks, not marked for deletion, whose min time is after the
+// specified threshold (in milliseconds).
+func listBlocksWithMinTimeAfter(idx *bucketindex.Index, threshold int64) (result bucketindex.Blocks) {
+	marked := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, d := range idx.BlockDeletionMarks {
+		marked[d.ID] = struct{}{}
+	}
+
+	for _, b := range idx.Blocks {
+		if b.MinTime <= threshold {
+			continue
+		}
+		if _, isMarked := marked[b.ID]; !isMarked {
+			result = append(result, b)
+		}
+	}
+
+	return
+}
+
 var errStopIter = errors.New("stop iteration")
 
 // stalePartialBlockLastModifiedTime returns the most recent last modified time of a stale partial block, or the zero value of time.Time if the provided block wasn't a stale partial block
@@ -710,14 +1410,14 @@ func stalePartialBlockLastModifiedTime(ctx context.Context, blockID ulid.ULID, u
 	return lastModified, err
 }
 
-func estimateCompactionJobsFromBucketIndex(ctx context.Context, userID string, userBucket objstore.InstrumentedBucket, idx *bucketindex.Index, compactionBlockRanges mimir_tsdb.DurationList, mergeShards int, splitGroups int) ([]*Job, error) {
+func estimateCompactionJobsFromBucketIndex(ctx context.Context, userID string, userBucket objstore.InstrumentedBucket, idx *bucketindex.Index, compactionBlockRanges mimir_tsdb.DurationList, mergeShards int, splitGroups int, requiredGroupingLabels []string) ([]*Job, error) {
 	metas := ConvertBucketIndexToMetasForCompactionJobPlanning(idx)
 
 	// We need to pass this metric to MetadataFilters, but we don't need to report this value from BlocksCleaner.
 	synced := newNoopGaugeVec()
 
 	for _, f := range []block.MetadataFilter{
-		NewLabelRemoverFilter(compactionIgnoredLabels),
+		NewLabelRemoverFilter(compactionIgnoredLabelsExcept(requiredGroupingLabels)),
 		// We don't include ShardAwareDeduplicateFilter, because it relies on list of compaction sources, which are not present in the BucketIndex.
 		// We do include NoCompactionMarkFilter to avoid computing jobs from blocks that are marked for no-compaction.
 		NewNoCompactionMarkFilter(userBucket),
@@ -100,6 +100,7 @@ func testBlocksCleanerWithOptions(t *testing.T, options testBlocksCleanerOptions
 	require.NoError(t, tsdb.WriteTenantDeletionMark(context.Background(), bucketClient, "user-4", nil, user4Mark))
 	user4DebugMetaFile := path.Join("user-4", block.DebugMetas, "meta.json")
 	require.NoError(t, bucketClient.Upload(context.Background(), user4DebugMetaFile, strings.NewReader("some random content here")))
+	require.NoError(t, bucketClient.Upload(context.Background(), path.Join("user-4", TenantCompactionRecordPath), strings.NewReader("{}")))
 
 	cfg := BlocksCleanerConfig{
 		DeletionDelay:                 deletionDelay,
@@ -149,6 +150,7 @@ func testBlocksCleanerWithOptions(t *testing.T, options testBlocksCleanerOptions
 		// User-4 is removed fully.
 		{path: path.Join("user-4", tsdb.TenantDeletionMarkPath), expectedExists: options.user4FilesExist},
 		{path: path.Join("user-4", block.DebugMetas, "meta.json"), expectedExists: options.user4FilesExist},
+		{path: path.Join("user-4", TenantCompactionRecordPath), expectedExists: options.user4FilesExist},
 	} {
 		exists, err := bucketClient.Exists(ctx, tc.path)
 		require.NoError(t, err)
@@ -398,6 +400,12 @@ func TestBlocksCleaner_ShouldRemoveMetricsForTenantsNotBelongingAnymoreToTheShar
 		"cortex_bucket_index_estimated_compaction_jobs",
 	))
 
+	// The bucket indexes have been created by the first run, so they're read by the next one.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assert.Equal(t, 2, testutil.CollectAndCount(cleaner.tenantBucketIndexReadDuration))
+	assert.Equal(t, 2, testutil.CollectAndCount(cleaner.tenantOverlappingBlocks))
+	assert.Equal(t, 2, testutil.CollectAndCount(cleaner.bytesMarkedForDeletion))
+
 	// Override the users scanner to reconfigure it to only return a subset of users.
 	cleaner.usersScanner = tsdb.NewUsersScanner(bucketClient, func(userID string) (bool, error) { return userID == "user-1", nil }, logger)
 
@@ -427,6 +435,111 @@ func TestBlocksCleaner_ShouldRemoveMetricsForTenantsNotBelongingAnymoreToTheShar
 		"cortex_bucket_blocks_partials_count",
 		"cortex_bucket_index_estimated_compaction_jobs",
 	))
+	assert.Equal(t, 1, testutil.CollectAndCount(cleaner.tenantBucketIndexReadDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(cleaner.tenantOverlappingBlocks))
+	assert.Equal(t, 1, testutil.CollectAndCount(cleaner.bytesMarkedForDeletion))
+}
+
+func TestSumBlocksMarkedForDeletionSize(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+
+	for name, tc := range map[string]struct {
+		blocks        bucketindex.Blocks
+		deletionMarks bucketindex.BlockDeletionMarks
+		expected      int64
+	}{
+		"no blocks": {
+			expected: 0,
+		},
+		"no blocks marked for deletion": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, SizeBytes: 100},
+				{ID: block2, SizeBytes: 200},
+			},
+			expected: 0,
+		},
+		"blocks marked for deletion": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, SizeBytes: 100},
+				{ID: block2, SizeBytes: 200},
+				{ID: block3, SizeBytes: 400},
+			},
+			deletionMarks: bucketindex.BlockDeletionMarks{{ID: block1}, {ID: block3}},
+			expected:      500,
+		},
+		"blocks whose size is unknown and deletion marks of blocks not in the index are ignored": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, SizeBytes: 100},
+				{ID: block2},
+			},
+			deletionMarks: bucketindex.BlockDeletionMarks{{ID: block1}, {ID: block2}, {ID: block4}},
+			expected:      100,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			idx := &bucketindex.Index{Blocks: tc.blocks, BlockDeletionMarks: tc.deletionMarks}
+			assert.Equal(t, tc.expected, sumBlocksMarkedForDeletionSize(idx))
+		})
+	}
+}
+
+func TestCountOverlappingBlocks(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+	block5 := ulid.MustNew(5, nil)
+
+	for name, tc := range map[string]struct {
+		blocks        bucketindex.Blocks
+		deletionMarks bucketindex.BlockDeletionMarks
+		expected      int
+	}{
+		"no blocks": {
+			expected: 0,
+		},
+		"adjacent blocks don't overlap": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, MinTime: 10, MaxTime: 20},
+				{ID: block2, MinTime: 20, MaxTime: 30},
+			},
+			expected: 0,
+		},
+		"overlapping blocks, regardless of their order in the index": {
+			blocks: bucketindex.Blocks{
+				{ID: block3, MinTime: 40, MaxTime: 50},
+				{ID: block1, MinTime: 10, MaxTime: 30},
+				{ID: block2, MinTime: 20, MaxTime: 40},
+				{ID: block4, MinTime: 15, MaxTime: 25},
+			},
+			expected: 3,
+		},
+		"blocks of different compactor shards don't overlap": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, MinTime: 10, MaxTime: 30, CompactorShardID: "1_of_2"},
+				{ID: block2, MinTime: 10, MaxTime: 30, CompactorShardID: "2_of_2"},
+				{ID: block3, MinTime: 20, MaxTime: 40, CompactorShardID: "2_of_2"},
+			},
+			expected: 2,
+		},
+		"blocks marked for deletion are ignored": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, MinTime: 10, MaxTime: 30},
+				{ID: block2, MinTime: 20, MaxTime: 40},
+				{ID: block5, MinTime: 10, MaxTime: 40},
+			},
+			deletionMarks: bucketindex.BlockDeletionMarks{{ID: block5}},
+			expected:      2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			idx := &bucketindex.Index{Blocks: tc.blocks, BlockDeletionMarks: tc.deletionMarks}
+			assert.Equal(t, tc.expected, countOverlappingBlocks(idx))
+		})
+	}
 }
 
 func updateOwnershipFunc(c *BlocksCleaner, ownFunc func(user string) (bool, error)) {
@@ -605,10 +718,16 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+			# HELP cortex_compactor_retention_backlog_blocks Number of blocks in the bucket beyond the retention period but not deleted yet, either marked for deletion or not, as of the last blocks cleanup of the tenant. A value that stays high across cleanups signals that the retention isn't keeping pace.
+			# TYPE cortex_compactor_retention_backlog_blocks gauge
+			cortex_compactor_retention_backlog_blocks{user="user-1"} 0
+			cortex_compactor_retention_backlog_blocks{user="user-2"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
 			"cortex_compactor_blocks_marked_for_deletion_total",
+			"cortex_compactor_retention_backlog_blocks",
 		))
 	}
 
@@ -647,10 +766,16 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+			# HELP cortex_compactor_retention_backlog_blocks Number of blocks in the bucket beyond the retention period but not deleted yet, either marked for deletion or not, as of the last blocks cleanup of the tenant. A value that stays high across cleanups signals that the retention isn't keeping pace.
+			# TYPE cortex_compactor_retention_backlog_blocks gauge
+			cortex_compactor_retention_backlog_blocks{user="user-1"} 1
+			cortex_compactor_retention_backlog_blocks{user="user-2"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
 			"cortex_compactor_blocks_marked_for_deletion_total",
+			"cortex_compactor_retention_backlog_blocks",
 		))
 	}
 
@@ -686,10 +811,16 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+			# HELP cortex_compactor_retention_backlog_blocks Number of blocks in the bucket beyond the retention period but not deleted yet, either marked for deletion or not, as of the last blocks cleanup of the tenant. A value that stays high across cleanups signals that the retention isn't keeping pace.
+			# TYPE cortex_compactor_retention_backlog_blocks gauge
+			cortex_compactor_retention_backlog_blocks{user="user-1"} 0
+			cortex_compactor_retention_backlog_blocks{user="user-2"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
 			"cortex_compactor_blocks_marked_for_deletion_total",
+			"cortex_compactor_retention_backlog_blocks",
 		))
 	}
 
@@ -716,14 +847,612 @@ func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 3
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+			# HELP cortex_compactor_retention_backlog_blocks Number of blocks in the bucket beyond the retention period but not deleted yet, either marked for deletion or not, as of the last blocks cleanup of the tenant. A value that stays high across cleanups signals that the retention isn't keeping pace.
+			# TYPE cortex_compactor_retention_backlog_blocks gauge
+			cortex_compactor_retention_backlog_blocks{user="user-1"} 0
+			cortex_compactor_retention_backlog_blocks{user="user-2"} 0
 			`),
 			"cortex_bucket_blocks_count",
 			"cortex_bucket_blocks_marked_for_deletion_count",
 			"cortex_compactor_blocks_marked_for_deletion_total",
+			"cortex_compactor_retention_backlog_blocks",
 		))
 	}
 }
 
+func TestBlocksCleaner_ListBlocksExceedingMaxSeries(t *testing.T) {
+	now := time.Unix(10000, 0)
+	hour := time.Hour.Milliseconds()
+
+	block1 := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: now.UnixMilli() - 8*hour, MaxTime: now.UnixMilli() - 6*hour, NumSeries: 100, HasStats: true, CompactionLevel: 2}
+	block2 := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: now.UnixMilli() - 6*hour, MaxTime: now.UnixMilli() - 4*hour, NumSeries: 100, HasStats: true, CompactionLevel: 2}
+	block3 := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: now.UnixMilli() - 4*hour, MaxTime: now.UnixMilli() - 2*hour, NumSeries: 100, HasStats: true, CompactionLevel: 2}
+	block4 := &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: now.UnixMilli() - 2*hour, MaxTime: now.UnixMilli(), NumSeries: 50, HasStats: true, CompactionLevel: 2}
+	block5 := &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: now.UnixMilli() - 2*hour, MaxTime: now.UnixMilli(), NumSeries: 50, HasStats: true, CompactionLevel: 2}
+
+	// Blocks uploaded by the ingesters, replicating the same series.
+	ingesterBlock1 := &bucketindex.Block{ID: ulid.MustNew(6, nil), MinTime: now.UnixMilli() - 2*hour, MaxTime: now.UnixMilli(), NumSeries: 100, HasStats: true, CompactionLevel: 1}
+	ingesterBlock2 := &bucketindex.Block{ID: ulid.MustNew(7, nil), MinTime: now.UnixMilli() - 2*hour, MaxTime: now.UnixMilli(), NumSeries: 100, HasStats: true, CompactionLevel: 1}
+
+	// Older blocks split in two shards.
+	splitBlock1 := &bucketindex.Block{ID: ulid.MustNew(9, nil), MinTime: now.UnixMilli() - 12*hour, MaxTime: now.UnixMilli() - 10*hour, NumSeries: 50, HasStats: true, CompactionLevel: 2}
+	splitBlock2 := &bucketindex.Block{ID: ulid.MustNew(10, nil), MinTime: now.UnixMilli() - 12*hour, MaxTime: now.UnixMilli() - 10*hour, NumSeries: 50, HasStats: true, CompactionLevel: 2}
+
+	// Compacted block added to the index without the number of series.
+	unknownSeriesBlock := &bucketindex.Block{ID: ulid.MustNew(8, nil), MinTime: now.UnixMilli() - 10*hour, MaxTime: now.UnixMilli() - 8*hour, CompactionLevel: 2}
+
+	tests := map[string]struct {
+		blocks          bucketindex.Blocks
+		marks           bucketindex.BlockDeletionMarks
+		maxSeries       uint64
+		retention       time.Duration
+		expectedBlocks  []ulid.ULID
+		expectedSeries  uint64
+		expectedUnknown int
+	}{
+		"no blocks": {
+			maxSeries:      100,
+			expectedBlocks: []ulid.ULID{},
+		},
+		"blocks within max series": {
+			blocks:         bucketindex.Blocks{block1, block2, block3, block4, block5},
+			maxSeries:      400,
+			expectedBlocks: []ulid.ULID{},
+			expectedSeries: 400,
+		},
+		"oldest blocks exceeding max series": {
+			blocks:         bucketindex.Blocks{block1, block2, block3, block4, block5},
+			maxSeries:      250,
+			expectedBlocks: []ulid.ULID{block2.ID, block1.ID},
+			expectedSeries: 400,
+		},
+		"blocks with the same time range are sorted by ID": {
+			blocks:         bucketindex.Blocks{block4, splitBlock2, splitBlock1},
+			maxSeries:      100,
+			expectedBlocks: []ulid.ULID{splitBlock2.ID},
+			expectedSeries: 150,
+		},
+		"the newest block is kept even if exceeding max series on its own": {
+			blocks:         bucketindex.Blocks{block1, block2, block3},
+			maxSeries:      50,
+			expectedBlocks: []ulid.ULID{block2.ID, block1.ID},
+			expectedSeries: 300,
+		},
+		"the only block is kept even if exceeding max series": {
+			blocks:         bucketindex.Blocks{block3},
+			maxSeries:      50,
+			expectedBlocks: []ulid.ULID{},
+			expectedSeries: 100,
+		},
+		"the split blocks of the newest time range are all kept even if exceeding max series": {
+			blocks:         bucketindex.Blocks{block3, block5, block4},
+			maxSeries:      50,
+			expectedBlocks: []ulid.ULID{block3.ID},
+			expectedSeries: 200,
+		},
+		"blocks marked for deletion are not counted": {
+			blocks:         bucketindex.Blocks{block1, block2, block3, block4, block5},
+			marks:          bucketindex.BlockDeletionMarks{{ID: block3.ID}},
+			maxSeries:      250,
+			expectedBlocks: []ulid.ULID{block1.ID},
+			expectedSeries: 300,
+		},
+		"blocks outside the retention period are not counted": {
+			blocks:         bucketindex.Blocks{block1, block2, block3, block4, block5},
+			maxSeries:      250,
+			retention:      5 * time.Hour,
+			expectedBlocks: []ulid.ULID{block2.ID},
+			expectedSeries: 300,
+		},
+		"blocks uploaded by the ingesters are neither counted nor returned": {
+			blocks:         bucketindex.Blocks{block1, block2, block3, block4, block5, ingesterBlock1, ingesterBlock2},
+			maxSeries:      250,
+			expectedBlocks: []ulid.ULID{block2.ID, block1.ID},
+			expectedSeries: 400,
+		},
+		"blocks with unknown number of series are counted as empty": {
+			blocks:          bucketindex.Blocks{block1, block2, block3, block4, block5, unknownSeriesBlock},
+			maxSeries:       250,
+			expectedBlocks:  []ulid.ULID{block2.ID, block1.ID, unknownSeriesBlock.ID},
+			expectedSeries:  400,
+			expectedUnknown: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			idx := &bucketindex.Index{Blocks: testData.blocks, BlockDeletionMarks: testData.marks}
+
+			actualBlocks, actualSeries, actualUnknown := listBlocksExceedingMaxSeries(idx, testData.maxSeries, testData.retention, now)
+			assert.Equal(t, testData.expectedBlocks, actualBlocks.GetULIDs())
+			assert.Equal(t, testData.expectedSeries, actualSeries)
+			assert.Equal(t, testData.expectedUnknown, actualUnknown)
+		})
+	}
+}
+
+func TestBlocksCleaner_ShouldMarkBlocksExceedingMaxSeriesForDeletion(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	now := time.Now()
+	uploadMeta := func(userID string, minT, maxT int64, level int, numSeries uint64) ulid.ULID {
+		id := ulid.MustNew(ulid.Now(), rand.Reader)
+		meta := blockMeta(id.String(), minT, maxT, nil)
+		meta.Compaction.Level = level
+		meta.Stats.NumSeries = numSeries
+		marshalAndUploadJSON(t, bucketClient, path.Join(userID, id.String(), block.MetaFilename), meta)
+		return id
+	}
+
+	block1 := uploadMeta("user-1", tsOffset(now, -6), tsOffset(now, -4), 2, 10)
+	block2 := uploadMeta("user-1", tsOffset(now, -4), tsOffset(now, -2), 2, 10)
+	block3 := uploadMeta("user-1", tsOffset(now, -2), tsOffset(now, 0), 2, 10)
+	// The blocks uploaded by 3 ingesters, replicating the same series, aren't counted.
+	ingesterBlocks := []ulid.ULID{
+		uploadMeta("user-1", tsOffset(now, -2), tsOffset(now, 0), 1, 10),
+		uploadMeta("user-1", tsOffset(now, -2), tsOffset(now, 0), 1, 10),
+		uploadMeta("user-1", tsOffset(now, -2), tsOffset(now, 0), 1, 10),
+	}
+	block4 := uploadMeta("user-2", tsOffset(now, -6), tsOffset(now, -4), 2, 10)
+	// The empty blocks don't prevent the limit from being enforced.
+	block5 := uploadMeta("user-3", tsOffset(now, -8), tsOffset(now, -6), 2, 10)
+	block6 := uploadMeta("user-3", tsOffset(now, -6), tsOffset(now, -4), 2, 0)
+	block7 := uploadMeta("user-3", tsOffset(now, -4), tsOffset(now, -2), 2, 20)
+	// The newest block is kept even if exceeding the limit on its own.
+	block8 := uploadMeta("user-4", tsOffset(now, -4), tsOffset(now, -2), 2, 100)
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+	}
+
+	ctx := context.Background()
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.maxSeries["user-1"] = 25
+	cfgProvider.maxSeries["user-2"] = 25
+	cfgProvider.maxSeries["user-3"] = 25
+	cfgProvider.maxSeries["user-4"] = 25
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
+
+	// The max series are applied to the bucket index, which is built by the first cleanup.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	type expectation struct {
+		user         string
+		blockID      ulid.ULID
+		expectMarked bool
+	}
+	expectations := []expectation{
+		{user: "user-1", blockID: block1, expectMarked: true},
+		{user: "user-1", blockID: block2, expectMarked: false},
+		{user: "user-1", blockID: block3, expectMarked: false},
+		{user: "user-2", blockID: block4, expectMarked: false},
+		{user: "user-3", blockID: block5, expectMarked: true},
+		{user: "user-3", blockID: block6, expectMarked: false},
+		{user: "user-3", blockID: block7, expectMarked: false},
+		{user: "user-4", blockID: block8, expectMarked: false},
+	}
+	for _, id := range ingesterBlocks {
+		expectations = append(expectations, expectation{user: "user-1", blockID: id, expectMarked: false})
+	}
+
+	for _, tc := range expectations {
+		marked, err := bucketClient.Exists(ctx, path.Join(tc.user, tc.blockID.String(), block.DeletionMarkFilename))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectMarked, marked, "user: %s block: %s", tc.user, tc.blockID)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
+		# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
+		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 2
+		`),
+		"cortex_compactor_blocks_marked_for_deletion_total",
+	))
+
+	// Marking the block again, before the deletion occurs, should not mark any other block.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
+		# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
+		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 2
+		`),
+		"cortex_compactor_blocks_marked_for_deletion_total",
+	))
+}
+
+func TestBlocksCleaner_ShouldWarnUnknownSeriesOnce(t *testing.T) {
+	cleaner := NewBlocksCleaner(BlocksCleanerConfig{}, nil, tsdb.AllUsers, newMockConfigProvider(), log.NewNopLogger(), nil)
+
+	assert.False(t, cleaner.shouldWarnUnknownSeries("user-1", 0))
+	assert.True(t, cleaner.shouldWarnUnknownSeries("user-1", 1))
+	assert.False(t, cleaner.shouldWarnUnknownSeries("user-1", 2))
+	assert.True(t, cleaner.shouldWarnUnknownSeries("user-2", 1))
+
+	// Once the tenant has no more blocks with unknown series, the warning is due again.
+	assert.False(t, cleaner.shouldWarnUnknownSeries("user-1", 0))
+	assert.True(t, cleaner.shouldWarnUnknownSeries("user-1", 1))
+}
+
+func TestBlocksCleaner_ShouldMarkSupersededBlocksForDeletion(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+
+	uploadMeta := func(sources []ulid.ULID, shardID string) ulid.ULID {
+		id := ulid.MustNew(ulid.Now(), rand.Reader)
+		meta := blockMeta(id.String(), 10, 20, nil)
+		if len(sources) > 0 {
+			meta.Compaction.Level = 2
+			meta.Compaction.Sources = sources
+		}
+		if shardID != "" {
+			meta.Thanos.Labels = map[string]string{tsdb.CompactorShardIDExternalLabel: shardID}
+		}
+		marshalAndUploadJSON(t, bucketClient, path.Join(userID, id.String(), block.MetaFilename), meta)
+		return id
+	}
+
+	// Blocks 1 and 2 have been compacted into block 3, but not marked for deletion.
+	block1 := uploadMeta(nil, "")
+	block2 := uploadMeta(nil, "")
+	block3 := uploadMeta([]ulid.ULID{block1, block2}, "")
+
+	// Block 4 has been split into two shards, but only the first one has been uploaded.
+	block4 := uploadMeta(nil, "")
+	block5 := uploadMeta([]ulid.ULID{block4}, "1_of_2")
+
+	// Block 6 has been compacted into block 7, which is marked for deletion.
+	block6 := uploadMeta(nil, "")
+	block7 := uploadMeta([]ulid.ULID{block6}, "")
+	createDeletionMark(t, bucketClient, userID, block7, time.Now())
+
+	// Block 8 has been compacted into block 9, which is marked for no-compaction.
+	block8 := uploadMeta(nil, "")
+	block9 := uploadMeta([]ulid.ULID{block8}, "")
+
+	// Block 10, marked for no-compaction, has been compacted into block 11.
+	block10 := uploadMeta(nil, "")
+	block11 := uploadMeta([]ulid.ULID{block10}, "")
+
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	for _, id := range []ulid.ULID{block9, block10} {
+		require.NoError(t, block.MarkForNoCompact(ctx, log.NewNopLogger(), userBucket, id, block.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+	}
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:                  time.Hour,
+		CleanupInterval:                time.Minute,
+		CleanupConcurrency:             1,
+		DeleteBlocksConcurrency:        1,
+		GetDeletionMarkersConcurrency:  1,
+		SupersededBlocksCleanupEnabled: true,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	// The first run creates the bucket index, which superseded blocks are looked for in by the next runs.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	checkBlockDeletionMarker(t, userID, bucketClient, block1, true)
+	checkBlockDeletionMarker(t, userID, bucketClient, block2, true)
+	checkBlockDeletionMarker(t, userID, bucketClient, block3, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block4, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block5, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block6, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block8, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block9, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block10, false)
+	checkBlockDeletionMarker(t, userID, bucketClient, block11, false)
+
+	// Blocks already marked for deletion are not marked again.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_superseded_blocks_marked_total Total number of blocks marked for deletion by the cleaner because fully included in other compacted blocks.
+		# TYPE cortex_compactor_superseded_blocks_marked_total counter
+		cortex_compactor_superseded_blocks_marked_total 2
+	`), "cortex_compactor_superseded_blocks_marked_total"))
+}
+
+func TestBlocksCleaner_ShouldMarkFutureBlocksForNoCompaction(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+	now := time.Now()
+
+	uploadMeta := func(minT, maxT int64) ulid.ULID {
+		id := ulid.MustNew(ulid.Now(), rand.Reader)
+		marshalAndUploadJSON(t, bucketClient, path.Join(userID, id.String(), block.MetaFilename), blockMeta(id.String(), minT, maxT, nil))
+		return id
+	}
+
+	pastBlock := uploadMeta(tsOffset(now, -4), tsOffset(now, -2))
+	slightlySkewedBlock := uploadMeta(tsOffset(now, 1), tsOffset(now, 3))
+	futureBlock := uploadMeta(tsOffset(now, 48), tsOffset(now, 50))
+	deletedFutureBlock := uploadMeta(tsOffset(now, 48), tsOffset(now, 50))
+	createDeletionMark(t, bucketClient, userID, deletedFutureBlock, now)
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+		FutureBlocksTolerance:   24 * time.Hour,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	// The first run creates the bucket index, which future blocks are looked for in by the next runs.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	for id, expected := range map[ulid.ULID]bool{pastBlock: false, slightlySkewedBlock: false, futureBlock: true, deletedFutureBlock: false} {
+		exists, err := bucketClient.Exists(ctx, path.Join(userID, id.String(), block.NoCompactMarkFilename))
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, id)
+	}
+
+	// Blocks already marked for no-compaction are not marked again.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_future_blocks_total Total number of blocks marked for no-compaction by the cleaner because their min time is too far in the future.
+		# TYPE cortex_compactor_future_blocks_total counter
+		cortex_compactor_future_blocks_total{user="user-1"} 1
+	`), "cortex_compactor_future_blocks_total"))
+}
+
+func TestBlocksCleaner_ShouldTrackBlockSizes(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+
+	uploadMeta := func(files ...block.File) ulid.ULID {
+		id := ulid.MustNew(ulid.Now(), rand.Reader)
+		meta := blockMeta(id.String(), 10, 20, nil)
+		meta.Thanos.Files = files
+		marshalAndUploadJSON(t, bucketClient, path.Join(userID, id.String(), block.MetaFilename), meta)
+		return id
+	}
+
+	uploadMeta(block.File{RelPath: "index", SizeBytes: 1 << 20}, block.File{RelPath: "chunks/000001", SizeBytes: 1 << 20})
+	uploadMeta(block.File{RelPath: "index", SizeBytes: 10 << 20}, block.File{RelPath: "chunks/000001", SizeBytes: 90 << 20})
+	deleted := uploadMeta(block.File{RelPath: "index", SizeBytes: 1 << 20})
+	createDeletionMark(t, bucketClient, userID, deleted, time.Now())
+	// Blocks whose meta.json doesn't list the files have an unknown size.
+	uploadMeta()
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+		BlockSizeMetricsEnabled: true,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	// Block sizes are not accumulated across runs.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_block_size_bytes Size distribution of the blocks in the bucket, not marked for deletion, as of the last update of the tenant's bucket index. Blocks whose size is unknown are not included.
+		# TYPE cortex_bucket_block_size_bytes histogram
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="1.048576e+06"} 0
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="4.194304e+06"} 1
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="1.6777216e+07"} 1
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="6.7108864e+07"} 1
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="2.68435456e+08"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="1.073741824e+09"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="4.294967296e+09"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="1.7179869184e+10"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="6.8719476736e+10"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="2.74877906944e+11"} 2
+		cortex_bucket_block_size_bytes_bucket{user="user-1",le="+Inf"} 2
+		cortex_bucket_block_size_bytes_sum{user="user-1"} 1.06954752e+08
+		cortex_bucket_block_size_bytes_count{user="user-1"} 2
+	`), "cortex_bucket_block_size_bytes"))
+}
+
+func TestBlocksCleaner_ShouldReconcileBucketIndexWithNoCompactMarks(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+	logger := test.NewTestingLogger(t)
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+
+	block1 := createTSDBBlock(t, bucketClient, userID, 10, 20, 2, nil)
+	block2 := createTSDBBlock(t, bucketClient, userID, 20, 30, 2, nil)
+	require.NoError(t, block.MarkForNoCompact(ctx, logger, userBucket, block1, block.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	assertNoCompact := func(expected map[ulid.ULID]bool, expectedReconciled int) {
+		t.Helper()
+
+		require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+		idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+		require.NoError(t, err)
+		require.Len(t, idx.Blocks, len(expected))
+		for _, b := range idx.Blocks {
+			assert.Equal(t, expected[b.ID], b.NoCompact, b.ID.String())
+		}
+		assert.Equal(t, float64(expectedReconciled), testutil.ToFloat64(cleaner.noCompactBlocksReconciled))
+	}
+
+	assertNoCompact(map[ulid.ULID]bool{block1: true, block2: false}, 1)
+
+	// The blocks already reconciled aren't counted again.
+	assertNoCompact(map[ulid.ULID]bool{block1: true, block2: false}, 1)
+
+	// The no-compaction state follows the marks being added and removed.
+	require.NoError(t, block.MarkForNoCompact(ctx, logger, userBucket, block2, block.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+	require.NoError(t, block.DeleteNoCompactMarker(ctx, logger, userBucket, block1))
+	assertNoCompact(map[ulid.ULID]bool{block1: false, block2: true}, 3)
+}
+
+func TestBlocksCleaner_ShouldTrackBlocksByLevel(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+
+	uploadMeta := func(level int) ulid.ULID {
+		id := ulid.MustNew(ulid.Now(), rand.Reader)
+		meta := blockMeta(id.String(), 10, 20, nil)
+		meta.Compaction.Level = level
+		marshalAndUploadJSON(t, bucketClient, path.Join(userID, id.String(), block.MetaFilename), meta)
+		return id
+	}
+
+	uploadMeta(1)
+	uploadMeta(1)
+	level2 := uploadMeta(2)
+	uploadMeta(3)
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:            0,
+		CleanupInterval:          time.Minute,
+		CleanupConcurrency:       1,
+		DeleteBlocksConcurrency:  1,
+		BlockLevelMetricsEnabled: true,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_blocks_by_level_count Total number of blocks in the bucket by compaction level, as of the last update of the tenant's bucket index. Blocks whose compaction level is unknown are not included.
+		# TYPE cortex_bucket_blocks_by_level_count gauge
+		cortex_bucket_blocks_by_level_count{level="1",user="user-1"} 2
+		cortex_bucket_blocks_by_level_count{level="2",user="user-1"} 1
+		cortex_bucket_blocks_by_level_count{level="3",user="user-1"} 1
+	`), "cortex_bucket_blocks_by_level_count"))
+
+	// Levels without blocks anymore are removed.
+	createDeletionMark(t, bucketClient, userID, level2, time.Now().Add(-time.Hour))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_blocks_by_level_count Total number of blocks in the bucket by compaction level, as of the last update of the tenant's bucket index. Blocks whose compaction level is unknown are not included.
+		# TYPE cortex_bucket_blocks_by_level_count gauge
+		cortex_bucket_blocks_by_level_count{level="1",user="user-1"} 2
+		cortex_bucket_blocks_by_level_count{level="3",user="user-1"} 1
+	`), "cortex_bucket_blocks_by_level_count"))
+}
+
+func TestBlocksCleaner_ShouldSkipWritingUnchangedBucketIndex(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+
+	createTSDBBlock(t, bucketClient, userID, 10, 20, 2, nil)
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:                 time.Hour,
+		CleanupInterval:               time.Minute,
+		CleanupConcurrency:            1,
+		DeleteBlocksConcurrency:       1,
+		UnchangedIndexWriteSkipPeriod: time.Hour,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), logger, reg)
+
+	readIndex := func() *bucketindex.Index {
+		idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+		require.NoError(t, err)
+		return idx
+	}
+	requireSkippedWrites := func(expected int) {
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+			# HELP cortex_compactor_bucket_index_writes_skipped_total Total number of bucket index writes skipped by the blocks cleaner because the bucket index was unchanged since the last write.
+			# TYPE cortex_compactor_bucket_index_writes_skipped_total counter
+			cortex_compactor_bucket_index_writes_skipped_total %d
+		`, expected)), "cortex_compactor_bucket_index_writes_skipped_total"))
+	}
+
+	// The first write is never skipped.
+	require.NoError(t, cleaner.cleanUser(ctx, userID, logger))
+	requireSkippedWrites(0)
+	written := readIndex()
+	require.Len(t, written.Blocks, 1)
+
+	// The write of the unchanged index is skipped.
+	require.NoError(t, cleaner.cleanUser(ctx, userID, logger))
+	requireSkippedWrites(1)
+	require.Equal(t, written, readIndex())
+
+	// The index is written if its content changed.
+	createTSDBBlock(t, bucketClient, userID, 20, 30, 2, nil)
+	require.NoError(t, cleaner.cleanUser(ctx, userID, logger))
+	requireSkippedWrites(1)
+	require.Len(t, readIndex().Blocks, 2)
+
+	// The index is written if it has been written by someone else in the meanwhile.
+	overwritten := readIndex()
+	overwritten.UpdatedAt--
+	require.NoError(t, bucketindex.WriteIndex(ctx, bucketClient, userID, nil, overwritten))
+	require.NoError(t, cleaner.cleanUser(ctx, userID, logger))
+	requireSkippedWrites(1)
+	require.NotEqual(t, overwritten.UpdatedAt, readIndex().UpdatedAt)
+
+	// The index is written if the last write is older than the skip period.
+	old := readIndex()
+	old.UpdatedAt = time.Now().Add(-2 * time.Hour).Unix()
+	require.NoError(t, bucketindex.WriteIndex(ctx, bucketClient, userID, nil, old))
+	hash, err := indexContentHash(old)
+	require.NoError(t, err)
+	cleaner.writtenIndexes[userID] = writtenIndex{hash: hash, updatedAt: old.UpdatedAt}
+	require.NoError(t, cleaner.cleanUser(ctx, userID, logger))
+	requireSkippedWrites(1)
+	require.Greater(t, readIndex().UpdatedAt, old.UpdatedAt)
+}
+
 func checkBlock(t *testing.T, user string, bucketClient objstore.Bucket, blockID ulid.ULID, metaJSONExists bool, markedForDeletion bool) {
 	exists, err := bucketClient.Exists(context.Background(), path.Join(user, blockID.String(), block.MetaFilename))
 	require.NoError(t, err)
@@ -734,6 +1463,36 @@ func checkBlock(t *testing.T, user string, bucketClient objstore.Bucket, blockID
 	require.Equal(t, markedForDeletion, exists)
 }
 
+func TestBlocksCleaner_ShouldCountBucketIndexOperationsOfTheTenant(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+
+	createTSDBBlock(t, bucketClient, userID, 10, 20, 2, nil)
+
+	reg := prometheus.NewPedanticRegistry()
+	operations := newBucketOperationsMetric(reg)
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+		BucketOperations:        operations,
+	}
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), test.NewTestingLogger(t), reg)
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	// The bucket index has been written through the counting bucket, and its block meta read by the updater.
+	_, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, test.NewTestingLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(operations.WithLabelValues(userID, objstore.OpUpload)))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(operations.WithLabelValues(userID, objstore.OpGet)), 2.0)
+	assert.GreaterOrEqual(t, testutil.ToFloat64(operations.WithLabelValues(userID, objstore.OpIter)), 1.0)
+}
+
 func TestBlocksCleaner_ShouldCleanUpFilesWhenNoMoreBlocksRemain(t *testing.T) {
 	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
 	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
@@ -765,6 +1524,10 @@ func TestBlocksCleaner_ShouldCleanUpFilesWhenNoMoreBlocksRemain(t *testing.T) {
 	debugMetaFile := path.Join(userID, block.DebugMetas, "meta.json")
 	require.NoError(t, bucketClient.Upload(context.Background(), debugMetaFile, strings.NewReader("random content")))
 
+	// Create the tenant compaction record, which should be deleted too.
+	recordFile := path.Join(userID, TenantCompactionRecordPath)
+	require.NoError(t, bucketClient.Upload(context.Background(), recordFile, strings.NewReader("{}")))
+
 	cfg := BlocksCleanerConfig{
 		DeletionDelay:              deletionDelay,
 		CleanupInterval:            time.Minute,
@@ -780,7 +1543,7 @@ func TestBlocksCleaner_ShouldCleanUpFilesWhenNoMoreBlocksRemain(t *testing.T) {
 	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
 	require.NoError(t, cleaner.runCleanupWithErr(ctx))
 
-	// Check bucket index, markers and debug files have been deleted.
+	// Check bucket index, markers, debug files and the tenant compaction record have been deleted.
 	exists, err = bucketClient.Exists(ctx, blockDeletionMarkFile)
 	require.NoError(t, err)
 	assert.False(t, exists)
@@ -789,10 +1552,159 @@ func TestBlocksCleaner_ShouldCleanUpFilesWhenNoMoreBlocksRemain(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, exists)
 
+	exists, err = bucketClient.Exists(ctx, recordFile)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
 	_, err = bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
 	require.ErrorIs(t, err, bucketindex.ErrIndexNotFound)
 }
 
+func TestBlocksCleaner_ShouldNotCleanUpFilesWhenNoMoreBlocksRemainIfDisabledForTenant(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	const userID = "user-1"
+	ctx := context.Background()
+	now := time.Now()
+	deletionDelay := 12 * time.Hour
+
+	// Create a block and mark it for deletion at a time before the deletionDelay.
+	block1 := createTSDBBlock(t, bucketClient, userID, 10, 20, 2, nil)
+	createDeletionMark(t, bucketClient, userID, block1, now.Add(-deletionDelay).Add(-time.Hour))
+
+	// Create a debug file that would otherwise be deleted by the cleaner.
+	debugMetaFile := path.Join(userID, block.DebugMetas, "meta.json")
+	require.NoError(t, bucketClient.Upload(context.Background(), debugMetaFile, strings.NewReader("random content")))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:              deletionDelay,
+		CleanupInterval:            time.Minute,
+		CleanupConcurrency:         1,
+		DeleteBlocksConcurrency:    1,
+		NoBlocksFileCleanupEnabled: true,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.noBlocksFileCleanupEnabled[userID] = false
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+	// The block has been deleted, but the bucket index and debug files have been kept.
+	checkBlock(t, userID, bucketClient, block1, false, false)
+
+	exists, err := bucketClient.Exists(ctx, debugMetaFile)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+	require.NoError(t, err)
+	assert.Empty(t, idx.Blocks)
+}
+
+func TestBlocksCleaner_ShouldNotDeleteBlocksWhileCleanupSuppressed(t *testing.T) {
+	const userID = "user-1"
+	now := time.Now()
+	deletionDelay := 12 * time.Hour
+
+	tests := map[string]struct {
+		from, until       time.Time
+		suppressedByAPI   bool
+		expectSuppression bool
+	}{
+		"no window": {
+			expectSuppression: false,
+		},
+		"ongoing window": {
+			from:              now.Add(-time.Hour),
+			until:             now.Add(time.Hour),
+			expectSuppression: true,
+		},
+		"ongoing window without start": {
+			until:             now.Add(time.Hour),
+			expectSuppression: true,
+		},
+		"future window": {
+			from:              now.Add(time.Hour),
+			until:             now.Add(2 * time.Hour),
+			expectSuppression: false,
+		},
+		"past window": {
+			from:              now.Add(-2 * time.Hour),
+			until:             now.Add(-time.Hour),
+			expectSuppression: false,
+		},
+		"suppressed via the HTTP API": {
+			suppressedByAPI:   true,
+			expectSuppression: true,
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+			bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+			ctx := context.Background()
+
+			// Create a block marked for deletion at a time before the deletionDelay, and a block outside the retention.
+			block1 := createTSDBBlock(t, bucketClient, userID, 10, 20, 2, nil)
+			block2 := createTSDBBlock(t, bucketClient, userID, 20, 30, 2, nil)
+			createDeletionMark(t, bucketClient, userID, block1, now.Add(-deletionDelay).Add(-time.Hour))
+
+			cfg := BlocksCleanerConfig{
+				DeletionDelay:           deletionDelay,
+				CleanupInterval:         time.Minute,
+				CleanupConcurrency:      1,
+				DeleteBlocksConcurrency: 1,
+				CleanupSuppressedFrom:   testData.from,
+				CleanupSuppressedUntil:  testData.until,
+			}
+
+			logger := test.NewTestingLogger(t)
+			reg := prometheus.NewPedanticRegistry()
+			cfgProvider := newMockConfigProvider()
+			cfgProvider.userRetentionPeriods[userID] = time.Hour
+
+			cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
+			cleaner.suppressedByAPI.Store(testData.suppressedByAPI)
+
+			// The first cleanup builds the bucket index, the second one applies the retention.
+			require.NoError(t, cleaner.runCleanupWithErr(ctx))
+			require.NoError(t, cleaner.runCleanupWithErr(ctx))
+
+			if testData.expectSuppression {
+				checkBlock(t, userID, bucketClient, block1, true, true)
+				checkBlock(t, userID, bucketClient, block2, true, false)
+			} else {
+				checkBlock(t, userID, bucketClient, block1, false, false)
+				checkBlock(t, userID, bucketClient, block2, true, true)
+			}
+
+			// The bucket index is updated anyway.
+			idx, err := bucketindex.ReadIndex(ctx, bucketClient, userID, nil, logger)
+			require.NoError(t, err)
+			if testData.expectSuppression {
+				assert.ElementsMatch(t, []ulid.ULID{block1, block2}, idx.Blocks.GetULIDs())
+			} else {
+				assert.ElementsMatch(t, []ulid.ULID{block2}, idx.Blocks.GetULIDs())
+			}
+
+			expected := 0
+			if testData.expectSuppression {
+				expected = 1
+			}
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_compactor_cleanup_suppressed Whether blocks deletion and retention are suppressed in the blocks cleanup, either during the configured window or via the HTTP API (1) or not (0).
+				# TYPE cortex_compactor_cleanup_suppressed gauge
+				cortex_compactor_cleanup_suppressed %d
+			`, expected)), "cortex_compactor_cleanup_suppressed"))
+		})
+	}
+}
+
 func TestBlocksCleaner_ShouldRemovePartialBlocksOutsideDelayPeriod(t *testing.T) {
 	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
 	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
@@ -854,6 +1766,7 @@ func TestBlocksCleaner_ShouldRemovePartialBlocksOutsideDelayPeriod(t *testing.T)
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 1
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 			`),
 		"cortex_bucket_blocks_count",
 		"cortex_bucket_blocks_marked_for_deletion_count",
@@ -861,6 +1774,67 @@ func TestBlocksCleaner_ShouldRemovePartialBlocksOutsideDelayPeriod(t *testing.T)
 	))
 }
 
+func TestBlocksCleaner_ShouldLimitPartialBlocksProcessedPerCleanup(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	ctx := context.Background()
+	now := time.Now()
+	partials := make([]ulid.ULID, 0, 3)
+	for i := 0; i < 3; i++ {
+		blockID := createTSDBBlock(t, bucketClient, "user-1", tsOffset(now, -10+2*i), tsOffset(now, -8+2*i), 2, nil)
+		require.NoError(t, bucketClient.Delete(ctx, path.Join("user-1", blockID.String(), block.MetaFilename)))
+		partials = append(partials, blockID)
+	}
+	slices.SortFunc(partials, func(a, b ulid.ULID) int { return a.Compare(b) })
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:                 time.Hour,
+		CleanupInterval:               time.Minute,
+		CleanupConcurrency:            1,
+		DeleteBlocksConcurrency:       1,
+		GetDeletionMarkersConcurrency: 1,
+		MaxPartialBlocksPerCleanup:    2,
+	}
+
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userPartialBlockDelay["user-1"] = time.Nanosecond
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
+
+	// The first cleanup only marks for deletion the two oldest partial blocks.
+	require.NoError(t, cleaner.cleanUser(ctx, "user-1", logger))
+	checkBlock(t, "user-1", bucketClient, partials[0], false, true)
+	checkBlock(t, "user-1", bucketClient, partials[1], false, true)
+	checkBlock(t, "user-1", bucketClient, partials[2], false, false)
+
+	// The second cleanup deletes the partial blocks marked for deletion, and defers the last one again.
+	require.NoError(t, cleaner.cleanUser(ctx, "user-1", logger))
+	checkBlock(t, "user-1", bucketClient, partials[0], false, false)
+	checkBlock(t, "user-1", bucketClient, partials[1], false, false)
+	checkBlock(t, "user-1", bucketClient, partials[2], false, false)
+
+	// The third cleanup marks the last partial block for deletion.
+	require.NoError(t, cleaner.cleanUser(ctx, "user-1", logger))
+	checkBlock(t, "user-1", bucketClient, partials[2], false, true)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
+			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
+			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 3
+			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+			# HELP cortex_compactor_partial_blocks_deferred_total Total number of partial blocks not processed by the cleaner in a cleanup, because the tenant had more partial blocks than the max processed per cleanup.
+			# TYPE cortex_compactor_partial_blocks_deferred_total counter
+			cortex_compactor_partial_blocks_deferred_total 2
+			`),
+		"cortex_compactor_blocks_marked_for_deletion_total",
+		"cortex_compactor_partial_blocks_deferred_total",
+	))
+}
+
 func TestBlocksCleaner_ShouldRemovePartiallyDeletedBlocksWithMarkerOutsideDelayPeriod(t *testing.T) {
 
 	deletionDelay := time.Hour
@@ -918,6 +1892,7 @@ func TestBlocksCleaner_ShouldRemovePartiallyDeletedBlocksWithMarkerOutsideDelayP
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 1
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 			# HELP cortex_compactor_blocks_cleaned_total Total number of blocks deleted.
 			# TYPE cortex_compactor_blocks_cleaned_total counter
 			cortex_compactor_blocks_cleaned_total 0
@@ -999,6 +1974,7 @@ func TestBlocksCleaner_ShouldRemovePartiallyDeletedBlocksWithGlobalMarkerPresent
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 			# HELP cortex_compactor_blocks_cleaned_total Total number of blocks deleted.
 			# TYPE cortex_compactor_blocks_cleaned_total counter
 			cortex_compactor_blocks_cleaned_total 0
@@ -1082,6 +2058,7 @@ func TestBlocksCleaner_ShouldNotRemovePartialBlocksInsideDelayPeriod(t *testing.
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 			`),
 		"cortex_bucket_blocks_count",
 		"cortex_bucket_blocks_marked_for_deletion_count",
@@ -1140,6 +2117,7 @@ func TestBlocksCleaner_ShouldNotRemovePartialBlocksIfConfiguredDelayIsInvalid(t
 			# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
 			cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 			cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+			cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 			`),
 		"cortex_bucket_blocks_count",
 		"cortex_bucket_blocks_marked_for_deletion_count",
@@ -1216,9 +2194,10 @@ func TestComputeCompactionJobs(t *testing.T) {
 	require.NoError(t, block.MarkForNoCompact(context.Background(), log.NewNopLogger(), userBucket, blockMarkedForNoCompact, block.CriticalNoCompactReason, "testing", promauto.With(nil).NewCounter(prometheus.CounterOpts{})))
 
 	cases := map[string]struct {
-		blocks         bucketindex.Blocks
-		expectedSplits int
-		expectedMerges int
+		blocks                 bucketindex.Blocks
+		requiredGroupingLabels []string
+		expectedSplits         int
+		expectedMerges         int
 	}{
 		"standard": {
 			blocks: bucketindex.Blocks{
@@ -1279,12 +2258,53 @@ func TestComputeCompactionJobs(t *testing.T) {
 			expectedSplits: 0,
 			expectedMerges: 1,
 		},
+		"don't ignore deprecated labels required for grouping": {
+			blocks: bucketindex.Blocks{
+				// Blocks differing in a required grouping label must never be compacted together.
+				&bucketindex.Block{ID: ulid.MustNew(ulid.Now(), rand.Reader), MinTime: 5 * dayMS, MaxTime: 6 * dayMS,
+					Labels: map[string]string{
+						"honored_label":                        "12345",
+						tsdb.DeprecatedTenantIDExternalLabel:   "tenant1",
+						tsdb.DeprecatedIngesterIDExternalLabel: "ingester1",
+					},
+				},
+				&bucketindex.Block{ID: ulid.MustNew(ulid.Now(), rand.Reader), MinTime: 5 * dayMS, MaxTime: 6 * dayMS,
+					Labels: map[string]string{
+						"honored_label":                        "12345",
+						tsdb.DeprecatedTenantIDExternalLabel:   "tenant2",
+						tsdb.DeprecatedIngesterIDExternalLabel: "ingester2",
+					},
+				},
+			},
+			requiredGroupingLabels: []string{tsdb.DeprecatedTenantIDExternalLabel},
+			expectedSplits:         0,
+			expectedMerges:         0,
+		},
+		"group blocks matching in all required grouping labels": {
+			blocks: bucketindex.Blocks{
+				&bucketindex.Block{ID: ulid.MustNew(ulid.Now(), rand.Reader), MinTime: 5 * dayMS, MaxTime: 6 * dayMS,
+					Labels: map[string]string{
+						tsdb.DeprecatedTenantIDExternalLabel:   "tenant1",
+						tsdb.DeprecatedIngesterIDExternalLabel: "ingester1",
+					},
+				},
+				&bucketindex.Block{ID: ulid.MustNew(ulid.Now(), rand.Reader), MinTime: 5 * dayMS, MaxTime: 6 * dayMS,
+					Labels: map[string]string{
+						tsdb.DeprecatedTenantIDExternalLabel:   "tenant1",
+						tsdb.DeprecatedIngesterIDExternalLabel: "ingester2",
+					},
+				},
+			},
+			requiredGroupingLabels: []string{tsdb.DeprecatedTenantIDExternalLabel},
+			expectedSplits:         0,
+			expectedMerges:         1,
+		},
 	}
 
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
 			index := &bucketindex.Index{Blocks: c.blocks}
-			jobs, err := estimateCompactionJobsFromBucketIndex(context.Background(), user, userBucket, index, cfg.CompactionBlockRanges, 3, 0)
+			jobs, err := estimateCompactionJobsFromBucketIndex(context.Background(), user, userBucket, index, cfg.CompactionBlockRanges, 3, 0, c.requiredGroupingLabels)
 			require.NoError(t, err)
 			split, merge := computeSplitAndMergeJobs(jobs)
 			require.Equal(t, c.expectedSplits, split)
@@ -1696,35 +2716,65 @@ func (m *mockBucketFailure) Delete(ctx context.Context, name string) error {
 }
 
 type mockConfigProvider struct {
-	userRetentionPeriods         map[string]time.Duration
-	splitAndMergeShards          map[string]int
-	instancesShardSize           map[string]int
-	splitGroups                  map[string]int
-	blockUploadEnabled           map[string]bool
-	blockUploadValidationEnabled map[string]bool
-	blockUploadMaxBlockSizeBytes map[string]int64
-	userPartialBlockDelay        map[string]time.Duration
-	userPartialBlockDelayInvalid map[string]bool
-	verifyChunks                 map[string]bool
-	perTenantInMemoryCache       map[string]int
-	maxLookback                  map[string]time.Duration
-	maxPerBlockUploadConcurrency map[string]int
+	userRetentionPeriods                 map[string]time.Duration
+	splitAndMergeShards                  map[string]int
+	instancesShardSize                   map[string]int
+	splitGroups                          map[string]int
+	blockUploadEnabled                   map[string]bool
+	blockUploadValidationEnabled         map[string]bool
+	blockUploadValidationConcurrency     map[string]int
+	blockUploadMaxBlockSizeBytes         map[string]int64
+	blockUploadMaxFiles                  map[string]int
+	userPartialBlockDelay                map[string]time.Duration
+	userPartialBlockDelayInvalid         map[string]bool
+	verifyChunks                         map[string]bool
+	perTenantInMemoryCache               map[string]int
+	perTenantInMemoryShadowCache         map[string]int
+	maxLookback                          map[string]time.Duration
+	maxPerBlockUploadConcurrency         map[string]int
+	uploadSparseIndexHeaders             map[string]bool
+	requiredGroupingLabels               map[string][]string
+	tenantDiskQuotaBytes                 map[string]int64
+	tenantCompactionMemoryBytes          map[string]int64
+	maxBlockChunkSegmentSize             map[string]int64
+	compactedBlocksValidationConcurrency map[string]int
+	maxSeries                            map[string]int64
+	noBlocksFileCleanupEnabled           map[string]bool
+	logOverlappingBlocks                 map[string]bool
+	tenantCompactionRetries              map[string]int
+	tenantBlockRanges                    map[string]tsdb.DurationList
+	tenantSchedulingWindows              map[string]util.TimeWindows
 }
 
 func newMockConfigProvider() *mockConfigProvider {
 	return &mockConfigProvider{
-		userRetentionPeriods:         make(map[string]time.Duration),
-		splitAndMergeShards:          make(map[string]int),
-		splitGroups:                  make(map[string]int),
-		blockUploadEnabled:           make(map[string]bool),
-		blockUploadValidationEnabled: make(map[string]bool),
-		blockUploadMaxBlockSizeBytes: make(map[string]int64),
-		userPartialBlockDelay:        make(map[string]time.Duration),
-		userPartialBlockDelayInvalid: make(map[string]bool),
-		verifyChunks:                 make(map[string]bool),
-		perTenantInMemoryCache:       make(map[string]int),
-		maxLookback:                  make(map[string]time.Duration),
-		maxPerBlockUploadConcurrency: make(map[string]int),
+		userRetentionPeriods:                 make(map[string]time.Duration),
+		splitAndMergeShards:                  make(map[string]int),
+		splitGroups:                          make(map[string]int),
+		blockUploadEnabled:                   make(map[string]bool),
+		blockUploadValidationEnabled:         make(map[string]bool),
+		blockUploadValidationConcurrency:     make(map[string]int),
+		blockUploadMaxBlockSizeBytes:         make(map[string]int64),
+		blockUploadMaxFiles:                  make(map[string]int),
+		userPartialBlockDelay:                make(map[string]time.Duration),
+		userPartialBlockDelayInvalid:         make(map[string]bool),
+		verifyChunks:                         make(map[string]bool),
+		perTenantInMemoryCache:               make(map[string]int),
+		perTenantInMemoryShadowCache:         make(map[string]int),
+		maxLookback:                          make(map[string]time.Duration),
+		maxPerBlockUploadConcurrency:         make(map[string]int),
+		uploadSparseIndexHeaders:             make(map[string]bool),
+		requiredGroupingLabels:               make(map[string][]string),
+		tenantDiskQuotaBytes:                 make(map[string]int64),
+		tenantCompactionMemoryBytes:          make(map[string]int64),
+		maxBlockChunkSegmentSize:             make(map[string]int64),
+		compactedBlocksValidationConcurrency: make(map[string]int),
+		maxSeries:                            make(map[string]int64),
+		noBlocksFileCleanupEnabled:           make(map[string]bool),
+		logOverlappingBlocks:                 make(map[string]bool),
+		tenantCompactionRetries:              make(map[string]int),
+		tenantBlockRanges:                    make(map[string]tsdb.DurationList),
+		tenantSchedulingWindows:              make(map[string]util.TimeWindows),
 	}
 }
 
@@ -1764,6 +2814,10 @@ func (m *mockConfigProvider) CompactorBlockUploadValidationEnabled(tenantID stri
 	return m.blockUploadValidationEnabled[tenantID]
 }
 
+func (m *mockConfigProvider) CompactorTenantBlockUploadValidationConcurrency(tenantID string) int {
+	return m.blockUploadValidationConcurrency[tenantID]
+}
+
 func (m *mockConfigProvider) CompactorPartialBlockDeletionDelay(user string) (time.Duration, bool) {
 	return m.userPartialBlockDelay[user], !m.userPartialBlockDelayInvalid[user]
 }
@@ -1776,10 +2830,18 @@ func (m *mockConfigProvider) CompactorBlockUploadMaxBlockSizeBytes(user string)
 	return m.blockUploadMaxBlockSizeBytes[user]
 }
 
+func (m *mockConfigProvider) CompactorBlockUploadMaxFiles(user string) int {
+	return m.blockUploadMaxFiles[user]
+}
+
 func (m *mockConfigProvider) CompactorInMemoryTenantMetaCacheSize(userID string) int {
 	return m.perTenantInMemoryCache[userID]
 }
 
+func (m *mockConfigProvider) CompactorInMemoryTenantMetaCacheShadowSize(userID string) int {
+	return m.perTenantInMemoryShadowCache[userID]
+}
+
 func (m *mockConfigProvider) S3SSEType(string) string {
 	return ""
 }
@@ -1799,6 +2861,60 @@ func (m *mockConfigProvider) CompactorMaxPerBlockUploadConcurrency(userID string
 	return 1
 }
 
+func (m *mockConfigProvider) CompactorRequiredGroupingLabels(userID string) []string {
+	return m.requiredGroupingLabels[userID]
+}
+
+func (m *mockConfigProvider) CompactorTenantDiskQuotaBytes(userID string) int64 {
+	return m.tenantDiskQuotaBytes[userID]
+}
+
+func (m *mockConfigProvider) CompactorTenantCompactionMemoryBytes(userID string) int64 {
+	return m.tenantCompactionMemoryBytes[userID]
+}
+
+func (m *mockConfigProvider) CompactorMaxBlockChunkSegmentSize(userID string) int64 {
+	return m.maxBlockChunkSegmentSize[userID]
+}
+
+func (m *mockConfigProvider) CompactorCompactedBlocksValidationConcurrency(userID string) int {
+	return m.compactedBlocksValidationConcurrency[userID]
+}
+
+func (m *mockConfigProvider) CompactorMaxSeries(userID string) int64 {
+	return m.maxSeries[userID]
+}
+
+func (m *mockConfigProvider) CompactorNoBlocksFileCleanupEnabled(userID string) bool {
+	if result, ok := m.noBlocksFileCleanupEnabled[userID]; ok {
+		return result
+	}
+	return true
+}
+
+func (m *mockConfigProvider) CompactorLogOverlappingBlocks(userID string) bool {
+	if result, ok := m.logOverlappingBlocks[userID]; ok {
+		return result
+	}
+	return true
+}
+
+func (m *mockConfigProvider) CompactorTenantCompactionRetries(userID string) int {
+	return m.tenantCompactionRetries[userID]
+}
+
+func (m *mockConfigProvider) CompactorTenantBlockRanges(userID string) tsdb.DurationList {
+	return m.tenantBlockRanges[userID]
+}
+
+func (m *mockConfigProvider) CompactorTenantSchedulingWindows(userID string) util.TimeWindows {
+	return m.tenantSchedulingWindows[userID]
+}
+
+func (m *mockConfigProvider) CompactorUploadSparseIndexHeaders(userID string) bool {
+	return m.uploadSparseIndexHeaders[userID]
+}
+
 func (m *mockConfigProvider) CompactorMaxLookback(user string) time.Duration {
 	if result, ok := m.maxLookback[user]; ok {
 		return result
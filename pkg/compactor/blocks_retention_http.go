@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"cmp"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/prometheus/model/timestamp"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+//go:embed blocks_retention.gohtml
+var blocksRetentionHTML string
+var blocksRetentionTemplate = template.Must(template.New("webpage").Parse(blocksRetentionHTML))
+
+type blocksRetentionContent struct {
+	Now                string `json:"now"`
+	BucketIndexUpdated string `json:"bucket_index_updated"`
+
+	Tenant           string           `json:"tenant"`
+	Retention        string           `json:"retention"`
+	RetentionEnabled bool             `json:"retention_enabled"`
+	Blocks           []blockRetention `json:"blocks"`
+
+	OutsideRetentionCount int `json:"outside_retention_count"`
+}
+
+type blockRetention struct {
+	ID                ulid.ULID `json:"id"`
+	MinTime           string    `json:"min_time"`
+	MaxTime           string    `json:"max_time"`
+	Retention         string    `json:"retention"`
+	OutsideRetention  bool      `json:"outside_retention"`
+	MarkedForDeletion bool      `json:"marked_for_deletion"`
+}
+
+// BlocksRetentionHandler shows, for each block of a tenant in the bucket index, the retention period
+// applied to it by the blocks cleaner and whether the block is currently beyond it.
+func (c *MultitenantCompactor) BlocksRetentionHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		util.WriteTextResponse(w, "Tenant ID can't be empty")
+		return
+	}
+
+	idx, err := bucketindex.ReadIndex(req.Context(), c.bucketClient, tenantID, nil, c.logger)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to read bucket index for tenant while listing blocks retention", "user", tenantID, "err", err)
+		util.WriteTextResponse(w, "Failed to read bucket index for tenant")
+		return
+	}
+
+	now := time.Now()
+	retention := c.cfgProvider.CompactorBlocksRetentionPeriod(tenantID)
+	if c.blocksCleaner != nil {
+		retention = c.blocksCleaner.retentionPeriod(req.Context(), tenantID, util_log.WithUserID(tenantID, c.logger))
+	}
+	// The retention period of zero is a special value indicating to never delete.
+	retentionEnabled := retention > 0
+	threshold := now.Add(-retention)
+
+	marked := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, d := range idx.BlockDeletionMarks {
+		marked[d.ID] = struct{}{}
+	}
+
+	sorted := slices.Clone(idx.Blocks)
+	slices.SortFunc(sorted, func(a, b *bucketindex.Block) int {
+		if res := cmp.Compare(a.MaxTime, b.MaxTime); res != 0 {
+			return res
+		}
+		return a.ID.Compare(b.ID)
+	})
+
+	blocks := make([]blockRetention, 0, len(sorted))
+	outsideRetention := 0
+
+	for _, b := range sorted {
+		_, isMarked := marked[b.ID]
+		br := blockRetention{
+			ID:                b.ID,
+			MinTime:           formatTime(timestamp.Time(b.MinTime)),
+			MaxTime:           formatTime(timestamp.Time(b.MaxTime)),
+			OutsideRetention:  retentionEnabled && isBlockOutsideRetentionPeriod(b, threshold),
+			MarkedForDeletion: isMarked,
+		}
+
+		if retentionEnabled {
+			br.Retention = retention.String()
+		}
+
+		if br.OutsideRetention {
+			outsideRetention++
+		}
+
+		blocks = append(blocks, br)
+	}
+
+	util.RenderHTTPResponse(w, blocksRetentionContent{
+		Now:                   formatTime(now),
+		BucketIndexUpdated:    formatTime(idx.GetUpdatedAt()),
+		Tenant:                tenantID,
+		Retention:             retention.String(),
+		RetentionEnabled:      retentionEnabled,
+		Blocks:                blocks,
+		OutsideRetentionCount: outsideRetention,
+	}, blocksRetentionTemplate, req)
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestBlocksRetentionHandler(t *testing.T) {
+	const user = "testuser"
+
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods[user] = 24 * time.Hour
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, prepareConfig(t), bucketClient, cfgProvider)
+	c.bucketClient = bucketClient
+
+	now := time.Now()
+	oldBlock := ulid.MustNew(1, nil)
+	oldMarkedBlock := ulid.MustNew(2, nil)
+	recentBlock := ulid.MustNew(3, nil)
+
+	index := bucketindex.Index{
+		Blocks: bucketindex.Blocks{
+			&bucketindex.Block{ID: recentBlock, MinTime: now.Add(-2 * time.Hour).UnixMilli(), MaxTime: now.UnixMilli()},
+			&bucketindex.Block{ID: oldBlock, MinTime: now.Add(-50 * time.Hour).UnixMilli(), MaxTime: now.Add(-48 * time.Hour).UnixMilli()},
+			&bucketindex.Block{ID: oldMarkedBlock, MinTime: now.Add(-74 * time.Hour).UnixMilli(), MaxTime: now.Add(-72 * time.Hour).UnixMilli()},
+		},
+		BlockDeletionMarks: bucketindex.BlockDeletionMarks{
+			&bucketindex.BlockDeletionMark{ID: oldMarkedBlock, DeletionTime: now.Unix()},
+		},
+	}
+	require.NoError(t, bucketindex.WriteIndex(context.Background(), bucketClient, user, nil, &index))
+
+	// The handler must agree with the blocks the cleaner would mark for deletion.
+	expectedOutsideRetention := listBlocksOutsideRetentionPeriod(&index, now.Add(-24*time.Hour))
+	require.Len(t, expectedOutsideRetention, 1)
+	require.Equal(t, oldBlock, expectedOutsideRetention[0].ID)
+
+	t.Run("html", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.BlocksRetentionHandler(resp, mux.SetURLVars(&http.Request{}, map[string]string{"tenant": user}))
+
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Contains(t, resp.Body.String(), "Tenant retention period: 24h0m0s")
+		require.Contains(t, resp.Body.String(), "Blocks beyond retention: 2")
+		require.Contains(t, resp.Body.String(), "<td>"+recentBlock.String()+"</td>")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Accept", "application/json")
+
+		resp := httptest.NewRecorder()
+		c.BlocksRetentionHandler(resp, mux.SetURLVars(&http.Request{Header: headers}, map[string]string{"tenant": user}))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var content blocksRetentionContent
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &content))
+
+		require.Equal(t, user, content.Tenant)
+		require.True(t, content.RetentionEnabled)
+		require.Equal(t, 2, content.OutsideRetentionCount)
+
+		// Blocks are sorted by max time.
+		require.Len(t, content.Blocks, 3)
+		require.Equal(t, oldMarkedBlock, content.Blocks[0].ID)
+		require.True(t, content.Blocks[0].OutsideRetention)
+		require.True(t, content.Blocks[0].MarkedForDeletion)
+
+		require.Equal(t, oldBlock, content.Blocks[1].ID)
+		require.True(t, content.Blocks[1].OutsideRetention)
+		require.False(t, content.Blocks[1].MarkedForDeletion)
+
+		require.Equal(t, recentBlock, content.Blocks[2].ID)
+		require.False(t, content.Blocks[2].OutsideRetention)
+		require.Equal(t, "24h0m0s", content.Blocks[2].Retention)
+	})
+
+	t.Run("retention disabled", func(t *testing.T) {
+		cfgProvider.userRetentionPeriods[user] = 0
+
+		headers := http.Header{}
+		headers.Set("Accept", "application/json")
+
+		resp := httptest.NewRecorder()
+		c.BlocksRetentionHandler(resp, mux.SetURLVars(&http.Request{Header: headers}, map[string]string{"tenant": user}))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var content blocksRetentionContent
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &content))
+
+		require.False(t, content.RetentionEnabled)
+		require.Zero(t, content.OutsideRetentionCount)
+		for _, b := range content.Blocks {
+			require.False(t, b.OutsideRetention)
+			require.Empty(t, b.Retention)
+		}
+	})
+}
@@ -6,14 +6,18 @@
 package compactor
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-kit/log"
@@ -30,6 +34,11 @@ import (
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/filesystem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/grafana/mimir/pkg/storage/indexheader"
 	"github.com/grafana/mimir/pkg/storage/sharding"
@@ -37,6 +46,8 @@ import (
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 )
 
+var tracer = otel.Tracer("pkg/compactor")
+
 var errCompactionIterationCancelled = cancellation.NewErrorf("compaction iteration cancelled")
 var errCompactionIterationStopped = cancellation.NewErrorf("compaction iteration stopped")
 
@@ -260,7 +271,16 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 
 	blockCount := len(job.metasByMinTime)
 
+	ctx, jobSpan := tracer.Start(ctx, "BucketCompactor.runCompactionJob", trace.WithAttributes(
+		attribute.String("user", job.UserID()),
+		attribute.String("job_key", job.Key()),
+		attribute.String("job_type", jobType),
+		attribute.Int("block_count", blockCount),
+	))
+
 	defer func() {
+		endSpan(jobSpan, rerr)
+
 		elapsed := time.Since(jobBeginTime)
 
 		if rerr == nil {
@@ -310,7 +330,12 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 	// Once we have a plan we need to download the actual data.
 	downloadBegin := time.Now()
 
-	err = concurrency.ForEachJob(ctx, len(toCompact), c.blockSyncConcurrency, func(ctx context.Context, idx int) error {
+	// The symbol table of the compacted blocks is estimated as the sum of the symbol tables of the source blocks,
+	// which is an upper bound since symbols are shared between blocks.
+	var symbolTableSize atomic.Uint64
+
+	downloadCtx, downloadSpan := tracer.Start(ctx, "BucketCompactor.downloadBlocks", trace.WithAttributes(attribute.Int("block_count", len(toCompact))))
+	err = concurrency.ForEachJob(downloadCtx, len(toCompact), c.blockSyncConcurrency, func(ctx context.Context, idx int) error {
 		meta := toCompact[idx]
 
 		// Must be the same as in blocksToCompactDirs.
@@ -323,10 +348,12 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		// Ensure all source blocks are valid.
 		stats, err := block.GatherBlockHealthStats(ctx, jobLogger, bdir, meta.MinTime, meta.MaxTime, false)
 		if err != nil {
+			c.blockFailedToOpen(blockOpenFailureReason(err))
 			return errors.Wrapf(err, "gather index issues for block %s", bdir)
 		}
 
 		if err := stats.CriticalErr(); err != nil {
+			c.blockFailedToOpen(blockOpenFailurePersistent)
 			return criticalError(errors.Wrapf(err, "block with unhealthy index found %s; Compaction level %v; Labels: %v", bdir, meta.Compaction.Level, meta.Thanos.Labels), meta.ULID)
 		}
 
@@ -344,12 +371,20 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		if err := stats.OutOfOrderLabelsErr(); err != nil {
 			return errors.Wrapf(err, "block id %s", meta.ULID)
 		}
+
+		symbolTableSize.Add(stats.SymbolTableSize)
 		return nil
 	})
+	endSpan(downloadSpan, err)
 	if err != nil {
 		return false, nil, err
 	}
 
+	if c.maxJobSymbolTableSizeBytes > 0 && symbolTableSize.Load() > uint64(c.maxJobSymbolTableSizeBytes) {
+		c.metrics.symbolTableTooLarge.Inc()
+		return false, nil, errors.Errorf("the estimated symbol table size of the compaction job (%d bytes) exceeds the limit (%d bytes) configured with -compactor.max-job-symbol-table-size-bytes, blocks: %s", symbolTableSize.Load(), c.maxJobSymbolTableSizeBytes, toCompactStr)
+	}
+
 	blocksToCompactDirs := make([]string, len(toCompact))
 	for ix, meta := range toCompact {
 		blocksToCompactDirs[ix] = filepath.Join(subDir, meta.ULID.String())
@@ -358,13 +393,23 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 	elapsed := time.Since(downloadBegin)
 	level.Info(jobLogger).Log("msg", "downloaded and verified blocks; compacting blocks", "block_count", blockCount, "blocks", toCompactStr, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
 
+	// The source blocks are open while they're compacted.
+	releaseOpenBlocks, err := c.openBlocksLimiter.acquire(ctx, len(blocksToCompactDirs))
+	if err != nil {
+		return false, nil, errors.Wrap(err, "wait for the limit of open blocks")
+	}
+
 	compactionBegin := time.Now()
 
+	_, compactSpan := tracer.Start(ctx, "BucketCompactor.compactBlocks", trace.WithAttributes(attribute.Int("block_count", len(toCompact))))
 	if job.UseSplitting() {
 		compIDs, err = c.comp.CompactWithSplitting(subDir, blocksToCompactDirs, nil, uint64(job.SplittingShards()))
 	} else {
 		compIDs, err = c.comp.Compact(subDir, blocksToCompactDirs, nil)
 	}
+	releaseOpenBlocks()
+	compactSpan.SetAttributes(attribute.Int("new_block_count", len(compIDs)))
+	endSpan(compactSpan, err)
 	if err != nil {
 		return false, nil, errors.Wrapf(err, "compact blocks %s", toCompactStr)
 	}
@@ -397,7 +442,12 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 	uploadBlocksCount := len(blocksToUpload)
 
 	// update labels and verify all blocks
-	err = concurrency.ForEachJob(ctx, len(blocksToUpload), c.blockSyncConcurrency, func(ctx context.Context, idx int) error {
+	err = concurrency.ForEachJob(ctx, len(blocksToUpload), c.compactedBlocksValidationConcurrency(), func(ctx context.Context, idx int) error {
+		if c.validationsInProgress != nil {
+			c.validationsInProgress.Inc()
+			defer c.validationsInProgress.Dec()
+		}
+
 		blockToUpload := blocksToUpload[idx]
 		bdir := filepath.Join(subDir, blockToUpload.ulid.String())
 
@@ -460,11 +510,22 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 
 	// upload all blocks
 	c.metrics.blockUploadsStarted.Add(float64(uploadBlocksCount))
-	err = concurrency.ForEachJob(ctx, uploadBlocksCount, c.blockSyncConcurrency, func(ctx context.Context, idx int) error {
+	uploadCtx, uploadSpan := tracer.Start(ctx, "BucketCompactor.uploadBlocks", trace.WithAttributes(attribute.Int("block_count", uploadBlocksCount)))
+	err = concurrency.ForEachJob(uploadCtx, uploadBlocksCount, c.blockSyncConcurrency, func(ctx context.Context, idx int) error {
 		blockToUpload := blocksToUpload[idx]
 		bdir := filepath.Join(subDir, blockToUpload.ulid.String())
 		begin := time.Now()
 
+		blockSize, err := dirSize(bdir)
+		if err != nil {
+			return errors.Wrapf(err, "compute size of %s", blockToUpload.ulid)
+		}
+		release, err := c.uploadBytesLimiter.acquire(ctx, blockSize)
+		if err != nil {
+			return errors.Wrapf(err, "wait to upload %s", blockToUpload.ulid)
+		}
+		defer release()
+
 		opts := []objstore.UploadOption{
 			objstore.WithUploadConcurrency(c.maxPerBlockUploadConcurrency),
 		}
@@ -479,11 +540,15 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 			return errors.Wrapf(err, "upload of %s failed", blockToUpload.ulid)
 		}
 
+		c.blocksUploaded.Inc()
+		c.bytesUploaded.Add(blockSize)
+
 		elapsed := time.Since(begin)
 		c.metrics.blockUploadsDuration.WithLabelValues(jobType).Observe(elapsed.Seconds())
 		level.Info(jobLogger).Log("msg", "uploaded block", "result_block", blockToUpload.ulid, "duration", elapsed, "duration_ms", elapsed.Milliseconds(), "external_labels", labels.FromMap(blockToUpload.labels))
 		return nil
 	})
+	endSpan(uploadSpan, err)
 	if err != nil {
 		return false, nil, err
 	}
@@ -506,9 +571,31 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 			return false, nil, errors.Wrapf(err, "mark old block for deletion from bucket")
 		}
 	}
+
+	c.blocksCompacted.Add(int64(len(toCompact)))
+	c.bytesCompacted.Add(blocksSizeBytes(toCompact))
 	return true, compIDs, nil
 }
 
+// endSpan records the error, if any, in the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// blocksSizeBytes returns the total size of the input blocks, as listed in their meta.json.
+func blocksSizeBytes(metas []*block.Meta) int64 {
+	var size int64
+	for _, m := range metas {
+		for _, f := range m.Thanos.Files {
+			size += f.SizeBytes
+		}
+	}
+	return size
+}
+
 func prepareSparseIndexHeader(ctx context.Context, logger log.Logger, bkt objstore.InstrumentedBucketReader, dir string, id ulid.ULID, sampling int, cfg indexheader.Config) error {
 	// Calling NewStreamBinaryReader reads a block's index and writes a sparse-index-header to disk.
 	mets := indexheader.NewStreamBinaryReaderMetrics(nil)
@@ -629,6 +716,28 @@ func IsOutOfOrderChunkError(err error) (bool, OutOfOrderChunksError) {
 	return ok, outOfOrderChunksErr
 }
 
+const (
+	// blockOpenFailureTransient is the reason of the failures to open a block which may succeed if retried, because
+	// the block isn't necessarily broken, for example because of a canceled context or exhausted resources.
+	blockOpenFailureTransient = "transient"
+
+	// blockOpenFailurePersistent is the reason of the failures to open a block which will fail again if retried,
+	// because the block is corrupted.
+	blockOpenFailurePersistent = "persistent"
+)
+
+// blockOpenFailureReason returns the reason of the input error returned opening a block.
+func blockOpenFailureReason(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return blockOpenFailureTransient
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE), errors.Is(err, syscall.ENOMEM), errors.Is(err, syscall.EAGAIN):
+		return blockOpenFailureTransient
+	default:
+		return blockOpenFailurePersistent
+	}
+}
+
 // CriticalError is a type wrapper for block health critical errors.
 type CriticalError struct {
 	err error
@@ -735,6 +844,8 @@ type BucketCompactorMetrics struct {
 	blockUploadsStarted                      prometheus.Counter
 	blockUploadsFailed                       *prometheus.CounterVec
 	blockUploadsDuration                     *prometheus.HistogramVec
+	symbolTableTooLarge                      prometheus.Counter
+	jobsOwnershipLost                        prometheus.Counter
 }
 
 // NewBucketCompactorMetrics makes a new BucketCompactorMetrics.
@@ -752,6 +863,10 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 			Name: "cortex_compactor_group_compactions_failures_total",
 			Help: "Total number of failed group compactions.",
 		}),
+		jobsOwnershipLost: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_jobs_ownership_lost_total",
+			Help: "Total number of compaction jobs planned by the compactor but skipped right before running them, because they were no longer owned by the compactor.",
+		}),
 		groupCompactions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name: "cortex_compactor_group_compactions_total",
 			Help: "Total number of group compaction attempts that resulted in new block(s).",
@@ -774,7 +889,7 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 		}, []string{"type"}),
 		compactionJobBlocks: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
 			Name:                            "cortex_compactor_compaction_job_blocks",
-			Help:                            "Number of compacted blocks in successful compaction jobs.",
+			Help:                            "Number of source blocks compacted by successful compaction jobs, by job type (split or merge).",
 			Buckets:                         []float64{4, 8, 16, 24, 32, 40, 48, 56, 64, 96},
 			NativeHistogramBucketFactor:     1.1,
 			NativeHistogramMaxBucketNumber:  100,
@@ -814,6 +929,10 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 			NativeHistogramMaxBucketNumber:  100,
 			NativeHistogramMinResetDuration: 1 * time.Hour,
 		}, []string{"type"}),
+		symbolTableTooLarge: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_symbol_table_too_large_total",
+			Help: "Total number of compaction jobs failed because the estimated size of their symbol table exceeded -compactor.max-job-symbol-table-size-bytes.",
+		}),
 	}
 	bcm.blocksMarkedForNoCompact.WithLabelValues(block.OutOfOrderChunksNoCompactReason).Add(0)
 	bcm.blocksMarkedForNoCompact.WithLabelValues(block.CriticalNoCompactReason).Add(0)
@@ -821,6 +940,201 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 	return bcm
 }
 
+// openBlocksLimiter limits the number of source blocks open at the same time across all the compaction jobs run by
+// a compactor, and tracks them in the cortex_compactor_open_blocks metric. A nil limiter doesn't limit nor track them.
+type openBlocksLimiter struct {
+	maxOpenBlocks int64
+	sem           *semaphore.Weighted // nil if the number of open blocks is unlimited.
+	openBlocks    prometheus.Gauge
+}
+
+// newOpenBlocksLimiter returns an openBlocksLimiter allowing up to maxOpenBlocks open blocks. 0 means unlimited.
+func newOpenBlocksLimiter(maxOpenBlocks int, reg prometheus.Registerer) *openBlocksLimiter {
+	l := &openBlocksLimiter{
+		maxOpenBlocks: int64(maxOpenBlocks),
+		openBlocks: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_open_blocks",
+			Help: "Number of source blocks currently open by the compaction jobs.",
+		}),
+	}
+	if maxOpenBlocks > 0 {
+		l.sem = semaphore.NewWeighted(l.maxOpenBlocks)
+	}
+	return l
+}
+
+// acquire waits until the input number of blocks can be opened, or the context is canceled, and returns the function
+// to call once the blocks are closed. A job with more blocks than the limit waits for all the blocks open by the other
+// jobs to be closed, so that it can still run.
+func (l *openBlocksLimiter) acquire(ctx context.Context, blocks int) (release func(), _ error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	weight := int64(blocks)
+	if l.sem != nil {
+		weight = min(weight, l.maxOpenBlocks)
+		if err := l.sem.Acquire(ctx, weight); err != nil {
+			return nil, err
+		}
+	}
+
+	l.openBlocks.Add(float64(blocks))
+	return func() {
+		l.openBlocks.Sub(float64(blocks))
+		if l.sem != nil {
+			l.sem.Release(weight)
+		}
+	}, nil
+}
+
+// uploadBytesLimiter limits the total size of the blocks uploaded at the same time across all the compaction jobs run
+// by a compactor, and tracks it in the cortex_compactor_upload_inflight_bytes metric. A nil limiter doesn't limit nor
+// track them.
+type uploadBytesLimiter struct {
+	maxBytes      int64
+	sem           *semaphore.Weighted // nil if the size of the blocks uploaded is unlimited.
+	inflightBytes prometheus.Gauge
+}
+
+// newUploadBytesLimiter returns an uploadBytesLimiter allowing up to maxBytes of blocks uploaded at the same time.
+// 0 means unlimited.
+func newUploadBytesLimiter(maxBytes int64, reg prometheus.Registerer) *uploadBytesLimiter {
+	l := &uploadBytesLimiter{
+		maxBytes: maxBytes,
+		inflightBytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_upload_inflight_bytes",
+			Help: "Total size of the compacted blocks currently being uploaded.",
+		}),
+	}
+	if maxBytes > 0 {
+		l.sem = semaphore.NewWeighted(maxBytes)
+	}
+	return l
+}
+
+// acquire waits until a block of the input size can be uploaded, or the context is canceled, and returns the
+// function to call once the block is uploaded. A block larger than the limit waits for all the other uploads to
+// complete, so that it can still be uploaded.
+func (l *uploadBytesLimiter) acquire(ctx context.Context, bytes int64) (release func(), _ error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	weight := bytes
+	if l.sem != nil {
+		weight = min(weight, l.maxBytes)
+		if err := l.sem.Acquire(ctx, weight); err != nil {
+			return nil, err
+		}
+	}
+
+	l.inflightBytes.Add(float64(bytes))
+	return func() {
+		l.inflightBytes.Sub(float64(bytes))
+		if l.sem != nil {
+			l.sem.Release(weight)
+		}
+	}, nil
+}
+
+// estimatedJobMemoryBytesPerSeries is the estimated memory used to compact each series of a source block whose index
+// size is unknown.
+const estimatedJobMemoryBytesPerSeries = 1024
+
+// estimateJobMemoryBytes returns the estimated peak memory used to compact the job, computed as the sum of the index
+// sizes of its source blocks, since the symbols and series of the indexes drive the memory used while compacting.
+// The number of series is used for the source blocks whose index size is unknown.
+func estimateJobMemoryBytes(job *Job) int64 {
+	var bytes int64
+	for _, meta := range job.Metas() {
+		indexSize := int64(0)
+		for _, f := range meta.Thanos.Files {
+			if f.RelPath == block.IndexFilename {
+				indexSize = f.SizeBytes
+				break
+			}
+		}
+		if indexSize <= 0 {
+			indexSize = int64(meta.Stats.NumSeries) * estimatedJobMemoryBytesPerSeries
+		}
+		bytes += indexSize
+	}
+	return bytes
+}
+
+// jobsMemoryLimiter limits the estimated memory of the compaction jobs run at the same time, across all the tenants
+// compacted by a compactor and per tenant, and tracks it in the cortex_compactor_compaction_estimated_memory_bytes
+// metric. Unlike the other limiters, jobs exceeding the budget don't wait: they're deferred. A nil limiter doesn't
+// limit nor track them.
+type jobsMemoryLimiter struct {
+	maxBytes       int64                     // 0 if the memory of the jobs across all tenants is unlimited.
+	tenantMaxBytes func(userID string) int64 // 0 if the memory of the jobs of the tenant is unlimited.
+
+	mtx         sync.Mutex
+	bytes       int64
+	tenantBytes map[string]int64
+
+	estimatedBytes prometheus.Gauge
+	deferredJobs   prometheus.Counter
+}
+
+// newJobsMemoryLimiter returns a jobsMemoryLimiter allowing up to maxBytes of estimated memory for the jobs of all
+// tenants, and up to the value returned by tenantMaxBytes for the jobs of each tenant. 0 means unlimited.
+func newJobsMemoryLimiter(maxBytes int64, tenantMaxBytes func(userID string) int64, reg prometheus.Registerer) *jobsMemoryLimiter {
+	return &jobsMemoryLimiter{
+		maxBytes:       maxBytes,
+		tenantMaxBytes: tenantMaxBytes,
+		tenantBytes:    map[string]int64{},
+		estimatedBytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_compaction_estimated_memory_bytes",
+			Help: "Estimated memory used by the compaction jobs currently running.",
+		}),
+		deferredJobs: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_jobs_deferred_memory_total",
+			Help: "Total number of compaction jobs deferred because their estimated memory exceeded the available compaction memory budget.",
+		}),
+	}
+}
+
+// tryAcquire returns whether the job can start given the estimated memory of the jobs currently running, and the
+// function to call once it's done. A job exceeding a budget on its own can start once no other job is using it, so
+// that it can still run.
+func (l *jobsMemoryLimiter) tryAcquire(job *Job) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+
+	userID := job.UserID()
+	bytes := estimateJobMemoryBytes(job)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.maxBytes > 0 && l.bytes > 0 && l.bytes+bytes > l.maxBytes {
+		l.deferredJobs.Inc()
+		return nil, false
+	}
+	if tenantMaxBytes := l.tenantMaxBytes(userID); tenantMaxBytes > 0 && l.tenantBytes[userID] > 0 && l.tenantBytes[userID]+bytes > tenantMaxBytes {
+		l.deferredJobs.Inc()
+		return nil, false
+	}
+
+	l.bytes += bytes
+	l.tenantBytes[userID] += bytes
+	l.estimatedBytes.Add(float64(bytes))
+	return func() {
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+
+		l.bytes -= bytes
+		if l.tenantBytes[userID] -= bytes; l.tenantBytes[userID] <= 0 {
+			delete(l.tenantBytes, userID)
+		}
+		l.estimatedBytes.Sub(float64(bytes))
+	}, true
+}
+
 type ownCompactionJobFunc func(job *Job) (bool, error)
 
 // ownAllJobs is a ownCompactionJobFunc that always return true.
@@ -842,12 +1156,211 @@ type BucketCompactor struct {
 	uploadSparseIndexHeaders      bool
 	sparseIndexHeaderSamplingRate int
 	maxPerBlockUploadConcurrency  int
+	maxJobSymbolTableSizeBytes    int64
+	openBlocksLimiter             *openBlocksLimiter
+	uploadBytesLimiter            *uploadBytesLimiter
+	jobsMemoryLimiter             *jobsMemoryLimiter
 	sparseIndexHeaderconfig       indexheader.Config
 	ownJob                        ownCompactionJobFunc
 	sortJobs                      JobsOrderFunc
 	waitPeriod                    time.Duration
 	blockSyncConcurrency          int
 	metrics                       *BucketCompactorMetrics
+
+	// Number of compaction jobs run by Compact, and number and size of the blocks they compacted and uploaded.
+	jobsSucceeded   atomic.Int64
+	jobsFailed      atomic.Int64
+	blocksCompacted atomic.Int64
+	bytesCompacted  atomic.Int64
+	blocksUploaded  atomic.Int64
+	bytesUploaded   atomic.Int64
+
+	// Number of blocks found by the first sync of the metas run by Compact, or -1 if not synced yet.
+	blocksBeforeCompaction atomic.Int64
+
+	// Estimated number of compaction jobs run by Compact, and function called each time a job finishes.
+	jobsEstimate  atomic.Int64
+	onJobFinished func()
+
+	// Optional counter of the source blocks which failed to open, by reason.
+	blocksFailedToOpen *prometheus.CounterVec
+
+	// Max number of compacted blocks of a job validated concurrently (0 = blockSyncConcurrency), and optional
+	// gauge tracking the number of compacted blocks being validated.
+	validationConcurrency int
+	validationsInProgress prometheus.Gauge
+
+	// Blocks whose min time is further than this after the max time of the previous blocks are marked for
+	// no-compaction (0 = disabled), and optional counter of these blocks. The optional noCompactMarkedMetas returns
+	// the blocks already marked for no-compaction, which are taken into account as previous blocks.
+	anomalousBlocksGap   time.Duration
+	anomalousBlocks      prometheus.Counter
+	noCompactMarkedMetas func() map[ulid.ULID]*block.Meta
+
+	// Optional function returning whether the local disk usage of the tenant would exceed its disk quota once the
+	// input bytes are downloaded, and optional counter of the jobs deferred because of it. The size of the source
+	// blocks of the jobs running is reserved until they complete.
+	diskQuotaExceeded     func(downloadBytes int64) (exceeded bool, usage, quota int64)
+	diskQuotaDeferredJobs prometheus.Counter
+	diskReservedMtx       sync.Mutex
+	diskReservedBytes     int64
+}
+
+// compactionJobsCount is the number of compaction jobs run by a BucketCompactor.
+type compactionJobsCount struct {
+	succeeded int
+	failed    int
+
+	// Number and size of the source blocks compacted by the jobs.
+	blocks int
+	bytes  int64
+
+	// Number and size of the blocks uploaded by the jobs.
+	uploadedBlocks int
+	uploadedBytes  int64
+
+	// Number of blocks found before running any compaction job, or -1 if the metas haven't been synced.
+	blocksBefore int
+}
+
+// jobsCount returns the number of compaction jobs run so far by Compact.
+func (c *BucketCompactor) jobsCount() compactionJobsCount {
+	return compactionJobsCount{
+		succeeded:      int(c.jobsSucceeded.Load()),
+		failed:         int(c.jobsFailed.Load()),
+		blocks:         int(c.blocksCompacted.Load()),
+		bytes:          c.bytesCompacted.Load(),
+		uploadedBlocks: int(c.blocksUploaded.Load()),
+		uploadedBytes:  c.bytesUploaded.Load(),
+		blocksBefore:   c.blocksBefore(),
+	}
+}
+
+// blocksBefore returns the number of blocks found by Compact before running any compaction job, or -1 if
+// Compact didn't sync the metas yet.
+func (c *BucketCompactor) blocksBefore() int {
+	return int(c.blocksBeforeCompaction.Load())
+}
+
+// progress returns the ratio of the compaction jobs finished so far by Compact, successfully or not, to the
+// estimated number of compaction jobs, between 0 and 1.
+func (c *BucketCompactor) progress() float64 {
+	finished := c.jobsSucceeded.Load() + c.jobsFailed.Load()
+	total := max(c.jobsEstimate.Load(), finished)
+	if total == 0 {
+		return 0
+	}
+	return float64(finished) / float64(total)
+}
+
+// updateJobsEstimate updates the estimated number of compaction jobs run by Compact, given the number of jobs
+// pending after a planning. The estimate is the number of jobs planned at the beginning of Compact, revised up
+// when later plannings find more jobs than expected, for example merge jobs following split jobs.
+func (c *BucketCompactor) updateJobsEstimate(pending int) {
+	estimate := c.jobsSucceeded.Load() + c.jobsFailed.Load() + int64(pending)
+	if estimate > c.jobsEstimate.Load() {
+		c.jobsEstimate.Store(estimate)
+	}
+}
+
+// compactedBlocksValidationConcurrency returns the max number of compacted blocks of a job validated concurrently.
+func (c *BucketCompactor) compactedBlocksValidationConcurrency() int {
+	if c.validationConcurrency > 0 {
+		return c.validationConcurrency
+	}
+	return c.blockSyncConcurrency
+}
+
+// blockFailedToOpen is called each time a source block fails to open, with the reason of the failure.
+func (c *BucketCompactor) blockFailedToOpen(reason string) {
+	if c.blocksFailedToOpen != nil {
+		c.blocksFailedToOpen.WithLabelValues(reason).Inc()
+	}
+}
+
+// excludeAnomalousBlocks returns the input metas without the anomalous blocks, which are marked for no-compaction
+// so that they're not planned again until an operator reviews them. See findAnomalousBlocks.
+func (c *BucketCompactor) excludeAnomalousBlocks(ctx context.Context, metas map[ulid.ULID]*block.Meta) map[ulid.ULID]*block.Meta {
+	if c.anomalousBlocksGap <= 0 {
+		return metas
+	}
+
+	var noCompactMarked map[ulid.ULID]*block.Meta
+	if c.noCompactMarkedMetas != nil {
+		noCompactMarked = c.noCompactMarkedMetas()
+	}
+
+	anomalous := findAnomalousBlocks(metas, noCompactMarked, c.anomalousBlocksGap)
+	if len(anomalous) == 0 {
+		return metas
+	}
+
+	filtered := maps.Clone(metas)
+	for _, a := range anomalous {
+		m := a.meta
+
+		// The block is excluded even if marking it fails, and the marking is retried on the next compaction.
+		delete(filtered, m.ULID)
+		if c.anomalousBlocks != nil {
+			c.anomalousBlocks.Inc()
+		}
+
+		level.Warn(c.logger).Log("msg", "found anomalous block: marking block for no-compaction", "block", m.ULID, "minTime", m.MinTime, "maxTime", m.MaxTime, "previousMaxTime", a.previousMaxTime)
+		details := fmt.Sprintf("block min time %d is more than %v after the max time %d of the previous blocks", m.MinTime, c.anomalousBlocksGap, a.previousMaxTime)
+		if err := block.MarkForNoCompact(ctx, c.logger, c.bkt, m.ULID, block.AnomalousBlockNoCompactReason, details, c.metrics.blocksMarkedForNoCompact.WithLabelValues(block.AnomalousBlockNoCompactReason)); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to mark anomalous block for no-compaction", "block", m.ULID, "err", err)
+		}
+	}
+
+	return filtered
+}
+
+// anomalousBlock is a block found by findAnomalousBlocks.
+type anomalousBlock struct {
+	meta *block.Meta
+
+	// Max time of the blocks starting before the anomalous one.
+	previousMaxTime int64
+}
+
+// findAnomalousBlocks returns the blocks of metas whose min time is further than the gap after the max time of all
+// the blocks starting before them, of any compaction level, sorted by min time. The blocks already marked for
+// no-compaction are taken into account as previous blocks but are never returned, so that the blocks following an
+// anomalous one are compared to it and not found anomalous in turn. The oldest block is never anomalous.
+func findAnomalousBlocks(metas, noCompactMarked map[ulid.ULID]*block.Meta, gap time.Duration) []anomalousBlock {
+	all := make([]*block.Meta, 0, len(metas)+len(noCompactMarked))
+	for _, m := range metas {
+		all = append(all, m)
+	}
+	for id, m := range noCompactMarked {
+		if _, ok := metas[id]; !ok {
+			all = append(all, m)
+		}
+	}
+	slices.SortFunc(all, func(a, b *block.Meta) int {
+		return cmp.Or(cmp.Compare(a.MinTime, b.MinTime), a.ULID.Compare(b.ULID))
+	})
+
+	var (
+		anomalous       []anomalousBlock
+		previousMaxTime int64
+	)
+	for i, m := range all {
+		if _, ok := metas[m.ULID]; ok && i > 0 && m.MinTime > previousMaxTime+gap.Milliseconds() {
+			anomalous = append(anomalous, anomalousBlock{meta: m, previousMaxTime: previousMaxTime})
+		}
+		if i == 0 || m.MaxTime > previousMaxTime {
+			previousMaxTime = m.MaxTime
+		}
+	}
+	return anomalous
+}
+
+// jobFinished is called each time a compaction job finishes, successfully or not.
+func (c *BucketCompactor) jobFinished() {
+	if c.onJobFinished != nil {
+		c.onJobFinished()
+	}
 }
 
 // NewBucketCompactor creates a new bucket compactor.
@@ -870,6 +1383,10 @@ func NewBucketCompactor(
 	sparseIndexHeaderSamplingRate int,
 	sparseIndexHeaderconfig indexheader.Config,
 	maxPerBlockUploadConcurrency int,
+	maxJobSymbolTableSizeBytes int64,
+	openBlocksLimiter *openBlocksLimiter,
+	uploadBytesLimiter *uploadBytesLimiter,
+	jobsMemoryLimiter *jobsMemoryLimiter,
 ) (*BucketCompactor, error) {
 	if concurrency <= 0 {
 		return nil, errors.Errorf("invalid concurrency level (%d), concurrency level must be > 0", concurrency)
@@ -879,7 +1396,7 @@ func NewBucketCompactor(
 		return nil, errors.Errorf("invalid per block upload concurrency level (%d), concurrency must be > 0", maxPerBlockUploadConcurrency)
 	}
 
-	return &BucketCompactor{
+	c := &BucketCompactor{
 		logger:                        logger,
 		sy:                            sy,
 		grouper:                       grouper,
@@ -898,7 +1415,13 @@ func NewBucketCompactor(
 		sparseIndexHeaderSamplingRate: sparseIndexHeaderSamplingRate,
 		sparseIndexHeaderconfig:       sparseIndexHeaderconfig,
 		maxPerBlockUploadConcurrency:  maxPerBlockUploadConcurrency,
-	}, nil
+		maxJobSymbolTableSizeBytes:    maxJobSymbolTableSizeBytes,
+		openBlocksLimiter:             openBlocksLimiter,
+		uploadBytesLimiter:            uploadBytesLimiter,
+		jobsMemoryLimiter:             jobsMemoryLimiter,
+	}
+	c.blocksBeforeCompaction.Store(-1)
+	return c, nil
 }
 
 // Compact runs compaction over bucket.
@@ -930,6 +1453,10 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 			errChan                = make(chan error, c.concurrency)
 			finishedAllJobs        = true
 			mtx                    sync.Mutex
+
+			// Whether any job was deferred because of the compaction memory budget or the tenant's disk quota,
+			// and whether any job ran.
+			deferredJobs, ranJobs bool
 		)
 
 		defer workCtxCancel(errCompactionIterationCancelled)
@@ -949,14 +1476,40 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 						continue
 					} else if !ok {
 						level.Info(c.logger).Log("msg", "skipped compaction because job is not owned by the compactor instance anymore", "groupKey", g.Key())
+						c.metrics.jobsOwnershipLost.Inc()
+						continue
+					}
+
+					releaseDisk, ok := c.tryReserveDiskQuota(g)
+					if !ok {
+						mtx.Lock()
+						deferredJobs = true
+						mtx.Unlock()
+						continue
+					}
+
+					releaseMemory, ok := c.jobsMemoryLimiter.tryAcquire(g)
+					if !ok {
+						releaseDisk()
+						level.Info(c.logger).Log("msg", "deferred compaction because the estimated memory of the job exceeds the available compaction memory budget", "groupKey", g.Key())
+						mtx.Lock()
+						deferredJobs = true
+						mtx.Unlock()
 						continue
 					}
 
 					c.metrics.groupCompactionRunsStarted.Inc()
 
 					shouldRerunJob, compactedBlockIDs, err := c.runCompactionJob(workCtx, g)
+					releaseMemory()
+					releaseDisk()
+					mtx.Lock()
+					ranJobs = true
+					mtx.Unlock()
 					if err == nil {
 						c.metrics.groupCompactionRunsCompleted.Inc()
+						c.jobsSucceeded.Inc()
+						c.jobFinished()
 						if hasNonZeroULIDs(compactedBlockIDs) {
 							c.metrics.groupCompactions.Inc()
 						}
@@ -971,6 +1524,8 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 
 					// At this point the compaction has failed.
 					c.metrics.groupCompactionRunsFailed.Inc()
+					c.jobsFailed.Inc()
+					c.jobFinished()
 
 					if ok, issue347Err := isIssue347Error(err); ok {
 						if err := repairIssue347(workCtx, c.logger, c.bkt, c.sy.metrics.blocksMarkedForDeletion, issue347Err); err == nil {
@@ -1031,6 +1586,7 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 		if err := c.sy.SyncMetas(ctx); err != nil {
 			return errors.Wrap(err, "sync")
 		}
+		c.blocksBeforeCompaction.CompareAndSwap(-1, int64(len(c.sy.Metas())))
 
 		level.Info(c.logger).Log("msg", "start of GC")
 		// Blocks that were compacted are garbage collected after each Compaction.
@@ -1039,7 +1595,7 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 			return errors.Wrap(err, "blocks garbage collect")
 		}
 
-		jobs, err := c.grouper.Groups(c.sy.Metas())
+		jobs, err := c.grouper.Groups(c.excludeAnomalousBlocks(ctx, c.sy.Metas()))
 		if err != nil {
 			return errors.Wrap(err, "build compaction jobs")
 		}
@@ -1064,6 +1620,7 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 
 		// Sort jobs based on the configured ordering algorithm.
 		jobs = c.sortJobs(jobs)
+		c.updateJobsEstimate(len(jobs))
 
 		ignoreDirs := []string{}
 		for _, gr := range jobs {
@@ -1117,6 +1674,14 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 			return jobErrs.Err()
 		}
 
+		// The deferred jobs are run by the next iteration once the jobs run by this one have released their memory
+		// and disk. If no job ran, the memory budget is used by the jobs of other tenants, or the tenant's disk usage
+		// can't decrease: the deferred jobs are left to the next compaction, so that we don't keep planning while
+		// waiting for them.
+		if deferredJobs && ranJobs {
+			finishedAllJobs = false
+		}
+
 		if maxCompactionTimeReached || finishedAllJobs {
 			break
 		}
@@ -1125,6 +1690,40 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 	return nil
 }
 
+// tryReserveDiskQuota returns whether the job can start given the tenant's disk quota, and the function to call once
+// it's done. The size of the source blocks the job is about to download is added to the current local disk usage of
+// the tenant, along with the size of the source blocks of the jobs currently running, which may not be fully
+// downloaded yet.
+func (c *BucketCompactor) tryReserveDiskQuota(job *Job) (release func(), ok bool) {
+	if c.diskQuotaExceeded == nil {
+		return func() {}, true
+	}
+
+	var bytes int64
+	for _, meta := range job.Metas() {
+		bytes += meta.BlockBytes()
+	}
+
+	c.diskReservedMtx.Lock()
+	defer c.diskReservedMtx.Unlock()
+
+	if exceeded, usage, quota := c.diskQuotaExceeded(c.diskReservedBytes + bytes); exceeded {
+		level.Warn(c.logger).Log("msg", "deferred compaction because the local disk usage of the tenant would exceed its quota once the blocks of the job are downloaded", "groupKey", job.Key(), "usage_bytes", usage, "reserved_bytes", c.diskReservedBytes, "job_bytes", bytes, "quota_bytes", quota)
+		if c.diskQuotaDeferredJobs != nil {
+			c.diskQuotaDeferredJobs.Inc()
+		}
+		return nil, false
+	}
+
+	c.diskReservedBytes += bytes
+	return func() {
+		c.diskReservedMtx.Lock()
+		defer c.diskReservedMtx.Unlock()
+
+		c.diskReservedBytes -= bytes
+	}, true
+}
+
 // blockMaxTimeDeltas returns a slice of the difference between now and the MaxTime of each
 // block that will be compacted as part of the provided jobs, in seconds.
 func (c *BucketCompactor) blockMaxTimeDeltas(now time.Time, jobs []*Job) []float64 {
@@ -1177,8 +1776,9 @@ var _ block.MetadataFilter = &NoCompactionMarkFilter{}
 // NoCompactionMarkFilter is a block.Fetcher filter that finds all blocks with no-compact marker files, and optionally
 // removes them from synced metas.
 type NoCompactionMarkFilter struct {
-	bkt                objstore.InstrumentedBucketReader
-	noCompactMarkedMap map[ulid.ULID]struct{}
+	bkt                  objstore.InstrumentedBucketReader
+	noCompactMarkedMap   map[ulid.ULID]struct{}
+	noCompactMarkedMetas map[ulid.ULID]*block.Meta
 }
 
 // NewNoCompactionMarkFilter creates NoCompactionMarkFilter.
@@ -1194,10 +1794,17 @@ func (f *NoCompactionMarkFilter) NoCompactMarkedBlocks() map[ulid.ULID]struct{}
 	return f.noCompactMarkedMap
 }
 
+// NoCompactMarkedMetas returns the metas of the blocks that were marked for no compaction and removed from metas.
+// It is safe to call this method only after Filter has finished.
+func (f *NoCompactionMarkFilter) NoCompactMarkedMetas() map[ulid.ULID]*block.Meta {
+	return f.noCompactMarkedMetas
+}
+
 // Filter finds blocks that should not be compacted, and fills f.noCompactMarkedMap. If f.removeNoCompactBlocks is true,
 // blocks are also removed from metas. (Thanos version of the filter doesn't do removal).
 func (f *NoCompactionMarkFilter) Filter(ctx context.Context, metas map[ulid.ULID]*block.Meta, synced block.GaugeVec) error {
 	noCompactMarkedMap := make(map[ulid.ULID]struct{})
+	noCompactMarkedMetas := make(map[ulid.ULID]*block.Meta)
 
 	// Find all no-compact markers in the storage.
 	err := f.bkt.Iter(ctx, block.MarkersPathname+"/", func(name string) error {
@@ -1206,9 +1813,10 @@ func (f *NoCompactionMarkFilter) Filter(ctx context.Context, metas map[ulid.ULID
 		}
 
 		if blockID, ok := block.IsNoCompactMarkFilename(path.Base(name)); ok {
-			_, exists := metas[blockID]
+			m, exists := metas[blockID]
 			if exists {
 				noCompactMarkedMap[blockID] = struct{}{}
+				noCompactMarkedMetas[blockID] = m
 				synced.WithLabelValues(block.MarkedForNoCompactionMeta).Inc()
 
 				delete(metas, blockID)
@@ -1222,6 +1830,7 @@ func (f *NoCompactionMarkFilter) Filter(ctx context.Context, metas map[ulid.ULID
 	}
 
 	f.noCompactMarkedMap = noCompactMarkedMap
+	f.noCompactMarkedMetas = noCompactMarkedMetas
 	return nil
 }
 
@@ -36,6 +36,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/filesystem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/grafana/mimir/pkg/storage/indexheader"
@@ -176,6 +180,17 @@ func TestSyncer_GarbageCollect_e2e(t *testing.T) {
 	})
 }
 
+var spanExporter = tracetest.NewInMemoryExporter()
+
+func init() {
+	// Set a tracer provider with in memory span exporter so we can check the spans later.
+	otel.SetTracerProvider(
+		tracesdk.NewTracerProvider(
+			tracesdk.WithSpanProcessor(tracesdk.NewSimpleSpanProcessor(spanExporter)),
+		),
+	)
+}
+
 func TestGroupCompactE2E(t *testing.T) {
 	foreachStore(t, func(t *testing.T, bkt objstore.Bucket) {
 		// Use bucket with global markers to make sure that our custom filters work correctly.
@@ -244,7 +259,7 @@ func TestGroupCompactE2E(t *testing.T) {
 		metrics := NewBucketCompactorMetrics(blocksMarkedForDeletion, prometheus.NewPedanticRegistry())
 		cfg := indexheader.Config{VerifyOnLoad: true}
 		bComp, err := NewBucketCompactor(
-			logger, sy, grouper, planner, comp, dir, bkt, 2, true, ownAllJobs, sortJobsByNewestBlocksFirst, 0, 4, metrics, true, 32, cfg, 8,
+			logger, sy, grouper, planner, comp, dir, bkt, 2, true, ownAllJobs, sortJobsByNewestBlocksFirst, 0, 4, metrics, true, 32, cfg, 8, 0, nil, nil, nil,
 		)
 		require.NoError(t, err)
 
@@ -376,6 +391,20 @@ func TestGroupCompactE2E(t *testing.T) {
 		assert.Equal(t, 0.0, promtest.ToFloat64(metrics.groupCompactionRunsFailed))
 		assert.Equal(t, 3.0, promtest.ToFloat64(metrics.blockUploadsStarted))
 
+		// Each phase of the compaction jobs is traced as a child of the job span.
+		jobSpans := map[string]bool{}
+		for _, span := range spanExporter.GetSpans() {
+			if span.Name == "BucketCompactor.runCompactionJob" && slices.Contains(span.Attributes, attribute.String("user", "user-1")) {
+				jobSpans[span.SpanContext.SpanID().String()] = true
+			}
+		}
+		assert.NotEmpty(t, jobSpans)
+		for _, name := range []string{"BucketCompactor.downloadBlocks", "BucketCompactor.compactBlocks", "BucketCompactor.uploadBlocks"} {
+			assert.True(t, slices.ContainsFunc(spanExporter.GetSpans(), func(span tracetest.SpanStub) bool {
+				return span.Name == name && jobSpans[span.Parent.SpanID().String()]
+			}), "missing span %s", name)
+		}
+
 		_, err = os.Stat(dir)
 		assert.True(t, os.IsNotExist(err), "dir %s should be remove after compaction.", dir)
 
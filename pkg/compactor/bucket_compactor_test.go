@@ -8,12 +8,16 @@ package compactor
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -122,7 +126,7 @@ func TestBucketCompactor_FilterOwnJobs(t *testing.T) {
 	cfg := indexheader.Config{VerifyOnLoad: true}
 	for testName, testCase := range tests {
 		t.Run(testName, func(t *testing.T) {
-			bc, err := NewBucketCompactor(log.NewNopLogger(), nil, nil, nil, nil, "", nil, 2, false, testCase.ownJob, nil, 0, 4, m, false, 32, cfg, 8)
+			bc, err := NewBucketCompactor(log.NewNopLogger(), nil, nil, nil, nil, "", nil, 2, false, testCase.ownJob, nil, 0, 4, m, false, 32, cfg, 8, 0, nil, nil, nil)
 			require.NoError(t, err)
 
 			res, err := bc.filterOwnJobs(jobsFn())
@@ -159,13 +163,490 @@ func TestBlockMaxTimeDeltas(t *testing.T) {
 	metrics := NewBucketCompactorMetrics(promauto.With(nil).NewCounter(prometheus.CounterOpts{}), nil)
 	cfg := indexheader.Config{VerifyOnLoad: true}
 	now := time.UnixMilli(1500002900159)
-	bc, err := NewBucketCompactor(log.NewNopLogger(), nil, nil, nil, nil, "", nil, 2, false, nil, nil, 0, 4, metrics, true, 32, cfg, 8)
+	bc, err := NewBucketCompactor(log.NewNopLogger(), nil, nil, nil, nil, "", nil, 2, false, nil, nil, 0, 4, metrics, true, 32, cfg, 8, 0, nil, nil, nil)
 	require.NoError(t, err)
 
 	deltas := bc.blockMaxTimeDeltas(now, []*Job{j1, j2})
 	assert.Equal(t, []float64{100, 200, 100}, deltas)
 }
 
+func TestOpenBlocksLimiter(t *testing.T) {
+	t.Run("nil limiter", func(t *testing.T) {
+		var l *openBlocksLimiter
+		release, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		l := newOpenBlocksLimiter(0, nil)
+
+		release1, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		release2, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 200.0, testutil.ToFloat64(l.openBlocks))
+
+		release1()
+		release2()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.openBlocks))
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		l := newOpenBlocksLimiter(4, nil)
+
+		release1, err := l.acquire(context.Background(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, testutil.ToFloat64(l.openBlocks))
+
+		// The blocks of another job can't be opened until the ones of the first job are closed.
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = l.acquire(ctx, 2)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 3.0, testutil.ToFloat64(l.openBlocks))
+
+		release1()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.openBlocks))
+
+		// A job with more blocks than the limit can run once no other job has blocks open.
+		release2, err := l.acquire(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, testutil.ToFloat64(l.openBlocks))
+
+		ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = l.acquire(ctx, 1)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release2()
+		release3, err := l.acquire(context.Background(), 4)
+		require.NoError(t, err)
+		release3()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.openBlocks))
+	})
+}
+
+func TestUploadBytesLimiter(t *testing.T) {
+	t.Run("nil limiter", func(t *testing.T) {
+		var l *uploadBytesLimiter
+		release, err := l.acquire(context.Background(), 1<<30)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		l := newUploadBytesLimiter(0, nil)
+
+		release1, err := l.acquire(context.Background(), 1<<30)
+		require.NoError(t, err)
+		release2, err := l.acquire(context.Background(), 1<<30)
+		require.NoError(t, err)
+		assert.Equal(t, float64(2<<30), testutil.ToFloat64(l.inflightBytes))
+
+		release1()
+		release2()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.inflightBytes))
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		l := newUploadBytesLimiter(100, nil)
+
+		release1, err := l.acquire(context.Background(), 60)
+		require.NoError(t, err)
+		release2, err := l.acquire(context.Background(), 40)
+		require.NoError(t, err)
+		assert.Equal(t, 100.0, testutil.ToFloat64(l.inflightBytes))
+
+		// Another block can't be uploaded until enough of the budget is free.
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = l.acquire(ctx, 50)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release1()
+		release3, err := l.acquire(context.Background(), 50)
+		require.NoError(t, err)
+		assert.Equal(t, 90.0, testutil.ToFloat64(l.inflightBytes))
+		release2()
+		release3()
+
+		// A block larger than the limit can be uploaded once no other block is being uploaded.
+		release4, err := l.acquire(context.Background(), 1000)
+		require.NoError(t, err)
+		assert.Equal(t, 1000.0, testutil.ToFloat64(l.inflightBytes))
+
+		ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = l.acquire(ctx, 1)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release4()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.inflightBytes))
+	})
+}
+
+func TestEstimateJobMemoryBytes(t *testing.T) {
+	job := newJob("user-1", "key", labels.EmptyLabels(), 0, false, 0, "")
+	require.NoError(t, job.AppendMeta(&block.Meta{
+		BlockMeta: tsdb.BlockMeta{ULID: ulid.MustNew(1, nil), Stats: tsdb.BlockStats{NumSeries: 10}},
+		Thanos: block.ThanosMeta{Files: []block.File{
+			{RelPath: "chunks/000001", SizeBytes: 1000},
+			{RelPath: block.IndexFilename, SizeBytes: 300},
+		}},
+	}))
+	// The index size is unknown, so the memory is estimated from the number of series.
+	require.NoError(t, job.AppendMeta(&block.Meta{
+		BlockMeta: tsdb.BlockMeta{ULID: ulid.MustNew(2, nil), Stats: tsdb.BlockStats{NumSeries: 10}},
+	}))
+
+	assert.Equal(t, int64(300+10*estimatedJobMemoryBytesPerSeries), estimateJobMemoryBytes(job))
+}
+
+func TestJobsMemoryLimiter(t *testing.T) {
+	newJobWithIndexSize := func(userID string, indexSize int64) *Job {
+		job := newJob(userID, "key", labels.EmptyLabels(), 0, false, 0, "")
+		require.NoError(t, job.AppendMeta(&block.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: ulid.MustNew(1, nil)},
+			Thanos:    block.ThanosMeta{Files: []block.File{{RelPath: block.IndexFilename, SizeBytes: indexSize}}},
+		}))
+		return job
+	}
+
+	t.Run("nil limiter", func(t *testing.T) {
+		var l *jobsMemoryLimiter
+		release, ok := l.tryAcquire(newJobWithIndexSize("user-1", 1<<30))
+		require.True(t, ok)
+		release()
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		l := newJobsMemoryLimiter(0, func(string) int64 { return 0 }, nil)
+
+		release1, ok := l.tryAcquire(newJobWithIndexSize("user-1", 1<<30))
+		require.True(t, ok)
+		release2, ok := l.tryAcquire(newJobWithIndexSize("user-1", 1<<30))
+		require.True(t, ok)
+		assert.Equal(t, float64(2<<30), testutil.ToFloat64(l.estimatedBytes))
+
+		release1()
+		release2()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.estimatedBytes))
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.deferredJobs))
+	})
+
+	t.Run("limited across all tenants", func(t *testing.T) {
+		l := newJobsMemoryLimiter(100, func(string) int64 { return 0 }, nil)
+
+		release1, ok := l.tryAcquire(newJobWithIndexSize("user-1", 60))
+		require.True(t, ok)
+		release2, ok := l.tryAcquire(newJobWithIndexSize("user-2", 40))
+		require.True(t, ok)
+		assert.Equal(t, 100.0, testutil.ToFloat64(l.estimatedBytes))
+
+		// Another job is deferred until enough of the budget is free.
+		_, ok = l.tryAcquire(newJobWithIndexSize("user-3", 50))
+		require.False(t, ok)
+		assert.Equal(t, 1.0, testutil.ToFloat64(l.deferredJobs))
+
+		release1()
+		release3, ok := l.tryAcquire(newJobWithIndexSize("user-3", 50))
+		require.True(t, ok)
+		assert.Equal(t, 90.0, testutil.ToFloat64(l.estimatedBytes))
+		release2()
+		release3()
+
+		// A job larger than the budget runs once no other job is running.
+		release4, ok := l.tryAcquire(newJobWithIndexSize("user-1", 1000))
+		require.True(t, ok)
+		_, ok = l.tryAcquire(newJobWithIndexSize("user-2", 1))
+		require.False(t, ok)
+		assert.Equal(t, 2.0, testutil.ToFloat64(l.deferredJobs))
+
+		release4()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.estimatedBytes))
+	})
+
+	t.Run("limited per tenant", func(t *testing.T) {
+		l := newJobsMemoryLimiter(0, func(userID string) int64 {
+			if userID == "user-1" {
+				return 100
+			}
+			return 0
+		}, nil)
+
+		release1, ok := l.tryAcquire(newJobWithIndexSize("user-1", 60))
+		require.True(t, ok)
+
+		// Another job of the same tenant is deferred, but the jobs of other tenants aren't.
+		_, ok = l.tryAcquire(newJobWithIndexSize("user-1", 50))
+		require.False(t, ok)
+		release2, ok := l.tryAcquire(newJobWithIndexSize("user-2", 1000))
+		require.True(t, ok)
+		assert.Equal(t, 1.0, testutil.ToFloat64(l.deferredJobs))
+
+		release1()
+		release3, ok := l.tryAcquire(newJobWithIndexSize("user-1", 50))
+		require.True(t, ok)
+		assert.Equal(t, 1050.0, testutil.ToFloat64(l.estimatedBytes))
+
+		release2()
+		release3()
+		assert.Equal(t, 0.0, testutil.ToFloat64(l.estimatedBytes))
+		assert.Empty(t, l.tenantBytes)
+	})
+}
+
+func TestBucketCompactor_tryReserveDiskQuota(t *testing.T) {
+	newJobWithBlocksSize := func(sizes ...int64) *Job {
+		job := newJob("user-1", "key", labels.EmptyLabels(), 0, false, 0, "")
+		for i, size := range sizes {
+			require.NoError(t, job.AppendMeta(&block.Meta{
+				BlockMeta: tsdb.BlockMeta{ULID: ulid.MustNew(uint64(i+1), nil)},
+				Thanos: block.ThanosMeta{Files: []block.File{
+					{RelPath: "chunks/000001", SizeBytes: size / 2},
+					{RelPath: block.IndexFilename, SizeBytes: size - size/2},
+				}},
+			}))
+		}
+		return job
+	}
+
+	t.Run("no quota", func(t *testing.T) {
+		c := &BucketCompactor{logger: log.NewNopLogger()}
+
+		release, ok := c.tryReserveDiskQuota(newJobWithBlocksSize(1 << 30))
+		require.True(t, ok)
+		release()
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		const usage, quota = 20, 100
+
+		deferredJobs := prometheus.NewCounter(prometheus.CounterOpts{})
+		c := &BucketCompactor{
+			logger: log.NewNopLogger(),
+			diskQuotaExceeded: func(downloadBytes int64) (bool, int64, int64) {
+				return usage+downloadBytes > quota, usage, quota
+			},
+			diskQuotaDeferredJobs: deferredJobs,
+		}
+
+		// The size of all the source blocks of the job is taken into account.
+		_, ok := c.tryReserveDiskQuota(newJobWithBlocksSize(50, 40))
+		require.False(t, ok)
+		assert.Equal(t, 1.0, testutil.ToFloat64(deferredJobs))
+
+		release1, ok := c.tryReserveDiskQuota(newJobWithBlocksSize(30, 20))
+		require.True(t, ok)
+
+		// The blocks of the jobs running are taken into account until they complete.
+		_, ok = c.tryReserveDiskQuota(newJobWithBlocksSize(40))
+		require.False(t, ok)
+		assert.Equal(t, 2.0, testutil.ToFloat64(deferredJobs))
+		release2, ok := c.tryReserveDiskQuota(newJobWithBlocksSize(30))
+		require.True(t, ok)
+
+		release1()
+		release3, ok := c.tryReserveDiskQuota(newJobWithBlocksSize(40))
+		require.True(t, ok)
+
+		release2()
+		release3()
+		assert.Equal(t, int64(0), c.diskReservedBytes)
+	})
+}
+
+func TestBucketCompactor_progress(t *testing.T) {
+	c := &BucketCompactor{}
+	assert.Equal(t, 0.0, c.progress())
+
+	// 4 jobs planned at the beginning of the compaction.
+	c.updateJobsEstimate(4)
+	assert.Equal(t, 0.0, c.progress())
+
+	c.jobsSucceeded.Inc()
+	c.jobsFailed.Inc()
+	assert.Equal(t, 0.5, c.progress())
+
+	// The next planning finds fewer pending jobs than expected: the estimate isn't revised down.
+	c.updateJobsEstimate(1)
+	assert.Equal(t, 0.5, c.progress())
+
+	// The next planning finds more pending jobs than expected: the estimate is revised up.
+	c.updateJobsEstimate(6)
+	assert.Equal(t, 0.25, c.progress())
+
+	c.jobsSucceeded.Add(6)
+	assert.Equal(t, 1.0, c.progress())
+
+	// The ratio never exceeds 1.
+	c.jobsSucceeded.Inc()
+	assert.Equal(t, 1.0, c.progress())
+}
+
+func TestBucketCompactor_compactedBlocksValidationConcurrency(t *testing.T) {
+	c := &BucketCompactor{blockSyncConcurrency: 8}
+	assert.Equal(t, 8, c.compactedBlocksValidationConcurrency())
+
+	c.validationConcurrency = 2
+	assert.Equal(t, 2, c.compactedBlocksValidationConcurrency())
+}
+
+func TestBlockOpenFailureReason(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err      error
+		expected string
+	}{
+		"canceled context": {
+			err:      errors.Wrap(context.Canceled, "read index"),
+			expected: blockOpenFailureTransient,
+		},
+		"too many open files": {
+			err:      errors.Wrap(&os.PathError{Op: "open", Path: "index", Err: syscall.EMFILE}, "open index"),
+			expected: blockOpenFailureTransient,
+		},
+		"missing file": {
+			err:      errors.Wrap(&os.PathError{Op: "open", Path: "index", Err: syscall.ENOENT}, "open index"),
+			expected: blockOpenFailurePersistent,
+		},
+		"corrupted index": {
+			err:      errors.New("invalid magic number"),
+			expected: blockOpenFailurePersistent,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, blockOpenFailureReason(tc.err))
+		})
+	}
+}
+
+func TestFindAnomalousBlocks(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	compacted := func(id string, mint, maxt int64) *block.Meta {
+		m := blockMeta(id, mint, maxt, nil)
+		m.Compaction.Level = 2
+		return m
+	}
+
+	block1 := compacted("01DTVP434PA9VFXSW2JK000001", 0, day)
+	block2 := compacted("01DTVP434PA9VFXSW2JK000002", day, 2*day)
+	block3 := blockMeta("01DTVP434PA9VFXSW2JK000003", 2*day, 2*day+1000, nil)
+	block4 := blockMeta("01DTVP434PA9VFXSW2JK000004", 12*day, 12*day+1000, nil)
+	block5 := blockMeta("01DTVP434PA9VFXSW2JK000005", 20*day, 20*day+1000, nil)
+	block6 := blockMeta("01DTVP434PA9VFXSW2JK000006", 12*day+1000, 12*day+2000, nil)
+
+	for name, tc := range map[string]struct {
+		metas             []*block.Meta
+		noCompactMarked   []*block.Meta
+		gap               time.Duration
+		expectedAnomalous []anomalousBlock
+	}{
+		"no blocks": {
+			gap: 24 * time.Hour,
+		},
+		"the oldest block is never anomalous": {
+			metas: []*block.Meta{block5},
+			gap:   24 * time.Hour,
+		},
+		"no anomalous blocks": {
+			metas: []*block.Meta{block1, block2, block3, block4, block5},
+			gap:   30 * 24 * time.Hour,
+		},
+		"blocks starting further than the gap after the previous blocks": {
+			metas: []*block.Meta{block5, block4, block3, block2, block1},
+			gap:   7 * 24 * time.Hour,
+			expectedAnomalous: []anomalousBlock{
+				{meta: block4, previousMaxTime: 2*day + 1000},
+				{meta: block5, previousMaxTime: 12*day + 1000},
+			},
+		},
+		"blocks of any level are compared": {
+			metas: []*block.Meta{block3, block4, block5},
+			gap:   9 * 24 * time.Hour,
+			expectedAnomalous: []anomalousBlock{
+				{meta: block4, previousMaxTime: 2*day + 1000},
+			},
+		},
+		"blocks following an anomalous one are compared to it": {
+			metas: []*block.Meta{block1, block2, block3, block4, block6},
+			gap:   7 * 24 * time.Hour,
+			expectedAnomalous: []anomalousBlock{
+				{meta: block4, previousMaxTime: 2*day + 1000},
+			},
+		},
+		"blocks following a block marked for no-compaction are compared to it": {
+			metas:           []*block.Meta{block1, block2, block3, block6},
+			noCompactMarked: []*block.Meta{block4},
+			gap:             7 * 24 * time.Hour,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			metas := map[ulid.ULID]*block.Meta{}
+			for _, m := range tc.metas {
+				metas[m.ULID] = m
+			}
+			noCompactMarked := map[ulid.ULID]*block.Meta{}
+			for _, m := range tc.noCompactMarked {
+				noCompactMarked[m.ULID] = m
+			}
+
+			assert.Equal(t, tc.expectedAnomalous, findAnomalousBlocks(metas, noCompactMarked, tc.gap))
+		})
+	}
+}
+
+func TestBucketCompactor_excludeAnomalousBlocks(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	compacted := blockMeta("01DTVP434PA9VFXSW2JK000001", 0, day, nil)
+	compacted.Compaction.Level = 2
+	recent := blockMeta("01DTVP434PA9VFXSW2JK000002", day, day+1000, nil)
+	anomalous := blockMeta("01DTVP434PA9VFXSW2JK000003", 60*day, 60*day+1000, nil)
+
+	metas := map[ulid.ULID]*block.Meta{compacted.ULID: compacted, recent.ULID: recent, anomalous.ULID: anomalous}
+
+	for _, gap := range []time.Duration{0, 90 * 24 * time.Hour} {
+		c := &BucketCompactor{logger: log.NewNopLogger(), bkt: bkt, anomalousBlocksGap: gap}
+		assert.Equal(t, metas, c.excludeAnomalousBlocks(ctx, metas))
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	anomalousBlocks := promauto.With(reg).NewCounter(prometheus.CounterOpts{Name: "anomalous_blocks_total"})
+	c := &BucketCompactor{
+		logger:             log.NewNopLogger(),
+		bkt:                bkt,
+		metrics:            NewBucketCompactorMetrics(promauto.With(nil).NewCounter(prometheus.CounterOpts{}), nil),
+		anomalousBlocksGap: 30 * 24 * time.Hour,
+		anomalousBlocks:    anomalousBlocks,
+	}
+
+	filtered := c.excludeAnomalousBlocks(ctx, metas)
+	assert.Equal(t, map[ulid.ULID]*block.Meta{compacted.ULID: compacted, recent.ULID: recent}, filtered)
+	assert.Len(t, metas, 3, "the input metas must not be modified")
+	assert.Equal(t, 1.0, testutil.ToFloat64(anomalousBlocks))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.blocksMarkedForNoCompact.WithLabelValues(block.AnomalousBlockNoCompactReason)))
+
+	exists, err := bkt.Exists(ctx, path.Join(anomalous.ULID.String(), block.NoCompactMarkFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Once marked for no-compaction, the anomalous block is removed from the synced metas. The blocks following it
+	// are compared to it, and aren't anomalous.
+	next := blockMeta("01DTVP434PA9VFXSW2JK000004", 60*day+1000, 60*day+2000, nil)
+	metas = map[ulid.ULID]*block.Meta{compacted.ULID: compacted, recent.ULID: recent, next.ULID: next}
+	c.noCompactMarkedMetas = func() map[ulid.ULID]*block.Meta {
+		return map[ulid.ULID]*block.Meta{anomalous.ULID: anomalous}
+	}
+
+	assert.Equal(t, metas, c.excludeAnomalousBlocks(ctx, metas))
+	assert.Equal(t, 1.0, testutil.ToFloat64(anomalousBlocks))
+}
+
 func TestNoCompactionMarkFilter(t *testing.T) {
 	ctx := context.Background()
 	// Use bucket with global markers to make sure that our custom filters work correctly.
@@ -198,6 +679,10 @@ func TestNoCompactionMarkFilter(t *testing.T) {
 			require.Contains(t, f.NoCompactMarkedBlocks(), block2)
 			require.Contains(t, f.NoCompactMarkedBlocks(), block4)
 
+			require.Len(t, f.NoCompactMarkedMetas(), 2)
+			require.Equal(t, block2, f.NoCompactMarkedMetas()[block2].ULID)
+			require.Equal(t, block4, f.NoCompactMarkedMetas()[block4].ULID)
+
 			assert.Equal(t, 2.0, testutil.ToFloat64(synced.WithLabelValues(block.MarkedForNoCompactionMeta)))
 		},
 		"filter with deletion enabled, but canceled context": func(t *testing.T, synced block.GaugeVec) {
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+// newBucketOperationsMetric returns the counter of the bucket operations performed by the compactor on behalf
+// of each tenant, used to attribute the object storage costs to the tenants.
+func newBucketOperationsMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_compactor_bucket_operations_total",
+		Help: "Total number of bucket operations performed by the compactor on behalf of a tenant.",
+	}, []string{"user", "operation"})
+}
+
+// bucketOperationsCounters are the counters of the bucket operations of a tenant, resolved once to keep
+// counting the operations cheap.
+type bucketOperationsCounters struct {
+	iter, get, getRange, exists, attributes, upload, delete prometheus.Counter
+}
+
+func newBucketOperationsCounters(operations *prometheus.CounterVec, userID string) *bucketOperationsCounters {
+	return &bucketOperationsCounters{
+		iter:       operations.WithLabelValues(userID, objstore.OpIter),
+		get:        operations.WithLabelValues(userID, objstore.OpGet),
+		getRange:   operations.WithLabelValues(userID, objstore.OpGetRange),
+		exists:     operations.WithLabelValues(userID, objstore.OpExists),
+		attributes: operations.WithLabelValues(userID, objstore.OpAttributes),
+		upload:     operations.WithLabelValues(userID, objstore.OpUpload),
+		delete:     operations.WithLabelValues(userID, objstore.OpDelete),
+	}
+}
+
+// operationsCountingBucket wraps a tenant bucket client and counts the operations performed through it.
+// A nil operations counter disables the counting and the wrapped bucket is returned as is.
+type operationsCountingBucket struct {
+	objstore.InstrumentedBucket
+	counters *bucketOperationsCounters
+}
+
+func newOperationsCountingBucket(bkt objstore.InstrumentedBucket, operations *prometheus.CounterVec, userID string) objstore.InstrumentedBucket {
+	if operations == nil {
+		return bkt
+	}
+	return &operationsCountingBucket{InstrumentedBucket: bkt, counters: newBucketOperationsCounters(operations, userID)}
+}
+
+func (b *operationsCountingBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	b.counters.iter.Inc()
+	return b.InstrumentedBucket.Iter(ctx, dir, f, options...)
+}
+
+func (b *operationsCountingBucket) IterWithAttributes(ctx context.Context, dir string, f func(attrs objstore.IterObjectAttributes) error, options ...objstore.IterOption) error {
+	b.counters.iter.Inc()
+	return b.InstrumentedBucket.IterWithAttributes(ctx, dir, f, options...)
+}
+
+func (b *operationsCountingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	b.counters.get.Inc()
+	return b.InstrumentedBucket.Get(ctx, name)
+}
+
+func (b *operationsCountingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	b.counters.getRange.Inc()
+	return b.InstrumentedBucket.GetRange(ctx, name, off, length)
+}
+
+func (b *operationsCountingBucket) Exists(ctx context.Context, name string) (bool, error) {
+	b.counters.exists.Inc()
+	return b.InstrumentedBucket.Exists(ctx, name)
+}
+
+func (b *operationsCountingBucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	b.counters.attributes.Inc()
+	return b.InstrumentedBucket.Attributes(ctx, name)
+}
+
+func (b *operationsCountingBucket) Upload(ctx context.Context, name string, r io.Reader, opts ...objstore.ObjectUploadOption) error {
+	b.counters.upload.Inc()
+	return b.InstrumentedBucket.Upload(ctx, name, r, opts...)
+}
+
+func (b *operationsCountingBucket) Delete(ctx context.Context, name string) error {
+	b.counters.delete.Inc()
+	return b.InstrumentedBucket.Delete(ctx, name)
+}
+
+// ReaderWithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *operationsCountingBucket) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *operationsCountingBucket) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.InstrumentedBucket.WithExpectedErrs(fn).(objstore.InstrumentedBucket); ok {
+		return &operationsCountingBucket{InstrumentedBucket: ib, counters: b.counters}
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+func TestOperationsCountingBucket(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	operations := newBucketOperationsMetric(reg)
+	bkt := objstore.NewInMemBucket()
+
+	user1 := newOperationsCountingBucket(bucket.NewUserBucketClient("user-1", bkt, nil), operations, "user-1")
+	user2 := newOperationsCountingBucket(bucket.NewUserBucketClient("user-2", bkt, nil), operations, "user-2")
+
+	require.NoError(t, user1.Upload(ctx, "a", bytes.NewReader([]byte("a"))))
+	require.NoError(t, user1.Upload(ctx, "b", bytes.NewReader([]byte("b"))))
+	_, err := user1.Get(ctx, "a")
+	require.NoError(t, err)
+	_, err = user1.ReaderWithExpectedErrs(user1.IsObjNotFoundErr).Exists(ctx, "c")
+	require.NoError(t, err)
+	require.NoError(t, user1.WithExpectedErrs(user1.IsObjNotFoundErr).Delete(ctx, "b"))
+	require.NoError(t, user2.Iter(ctx, "", func(string) error { return nil }))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_bucket_operations_total Total number of bucket operations performed by the compactor on behalf of a tenant.
+		# TYPE cortex_compactor_bucket_operations_total counter
+		cortex_compactor_bucket_operations_total{operation="attributes",user="user-1"} 0
+		cortex_compactor_bucket_operations_total{operation="delete",user="user-1"} 1
+		cortex_compactor_bucket_operations_total{operation="exists",user="user-1"} 1
+		cortex_compactor_bucket_operations_total{operation="get",user="user-1"} 1
+		cortex_compactor_bucket_operations_total{operation="get_range",user="user-1"} 0
+		cortex_compactor_bucket_operations_total{operation="iter",user="user-1"} 0
+		cortex_compactor_bucket_operations_total{operation="upload",user="user-1"} 2
+		cortex_compactor_bucket_operations_total{operation="attributes",user="user-2"} 0
+		cortex_compactor_bucket_operations_total{operation="delete",user="user-2"} 0
+		cortex_compactor_bucket_operations_total{operation="exists",user="user-2"} 0
+		cortex_compactor_bucket_operations_total{operation="get",user="user-2"} 0
+		cortex_compactor_bucket_operations_total{operation="get_range",user="user-2"} 0
+		cortex_compactor_bucket_operations_total{operation="iter",user="user-2"} 1
+		cortex_compactor_bucket_operations_total{operation="upload",user="user-2"} 0
+	`), "cortex_compactor_bucket_operations_total"))
+
+	// Without the counter, the bucket isn't wrapped.
+	userBucket := bucket.NewUserBucketClient("user-1", bkt, nil)
+	require.Equal(t, userBucket, newOperationsCountingBucket(userBucket, nil, "user-1"))
+}
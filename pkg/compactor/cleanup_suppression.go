@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+type cleanupSuppressionResponse struct {
+	Suppressed      bool   `json:"suppressed"`
+	SuppressedByAPI bool   `json:"suppressed_by_api"`
+	WindowFrom      string `json:"window_from,omitempty"`
+	WindowUntil     string `json:"window_until,omitempty"`
+}
+
+// isCleanupSuppressed returns whether blocks deletion and retention are suppressed at the input time, either because
+// it's within the configured window or because they've been suppressed via the HTTP API.
+func (c *BlocksCleaner) isCleanupSuppressed(now time.Time) bool {
+	if c.suppressedByAPI.Load() {
+		return true
+	}
+	if c.cfg.CleanupSuppressedUntil.IsZero() || !now.Before(c.cfg.CleanupSuppressedUntil) {
+		return false
+	}
+	return c.cfg.CleanupSuppressedFrom.IsZero() || !now.Before(c.cfg.CleanupSuppressedFrom)
+}
+
+// updateCleanupSuppressed updates the cortex_compactor_cleanup_suppressed metric, and returns whether blocks deletion
+// and retention are suppressed at the input time.
+func (c *BlocksCleaner) updateCleanupSuppressed(now time.Time) bool {
+	suppressed := c.isCleanupSuppressed(now)
+	if suppressed {
+		c.cleanupSuppressed.Set(1)
+	} else {
+		c.cleanupSuppressed.Set(0)
+	}
+	return suppressed
+}
+
+// CleanupSuppressionHandler returns whether blocks deletion and retention are suppressed in the blocks cleanup of
+// this compactor. A POST request with the suppressed=<bool> parameter suppresses or resumes them until the next
+// restart, regardless of the window configured with -compactor.cleanup-suppressed-from and
+// -compactor.cleanup-suppressed-until. Bucket indexes are still updated while blocks deletion and retention are
+// suppressed.
+func (c *MultitenantCompactor) CleanupSuppressionHandler(w http.ResponseWriter, req *http.Request) {
+	if c.State() != services.Running {
+		http.Error(w, "compactor is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		suppressed, err := strconv.ParseBool(req.FormValue("suppressed"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid suppressed parameter: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		c.blocksCleaner.suppressedByAPI.Store(suppressed)
+		level.Info(c.logger).Log("msg", "blocks deletion and retention suppression updated via the HTTP API", "suppressed", suppressed)
+	}
+
+	cfg := c.blocksCleaner.cfg
+	resp := cleanupSuppressionResponse{
+		Suppressed:      c.blocksCleaner.updateCleanupSuppressed(time.Now()),
+		SuppressedByAPI: c.blocksCleaner.suppressedByAPI.Load(),
+	}
+	if !cfg.CleanupSuppressedFrom.IsZero() {
+		resp.WindowFrom = formatTime(cfg.CleanupSuppressedFrom)
+	}
+	if !cfg.CleanupSuppressedUntil.IsZero() {
+		resp.WindowUntil = formatTime(cfg.CleanupSuppressedUntil)
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestCleanupSuppressionHandler(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	until := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := prepareConfig(t)
+	require.NoError(t, cfg.CleanupSuppressedUntil.Set(until.Format(time.RFC3339)))
+
+	c, _, _, _, _ := prepare(t, cfg, bucketClient)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	send := func(t *testing.T, req *http.Request, expectedCode int) cleanupSuppressionResponse {
+		resp := httptest.NewRecorder()
+		c.CleanupSuppressionHandler(resp, req)
+		require.Equal(t, expectedCode, resp.Code, resp.Body.String())
+
+		var status cleanupSuppressionResponse
+		if expectedCode == http.StatusOK {
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+		}
+		return status
+	}
+	toggle := func(suppressed string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"suppressed": []string{suppressed}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	// The configured window is over.
+	require.Equal(t, cleanupSuppressionResponse{WindowUntil: "2020-01-01T00:00:00Z"}, send(t, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK))
+
+	require.Equal(t, cleanupSuppressionResponse{Suppressed: true, SuppressedByAPI: true, WindowUntil: "2020-01-01T00:00:00Z"}, send(t, toggle("true"), http.StatusOK))
+	require.Equal(t, cleanupSuppressionResponse{Suppressed: true, SuppressedByAPI: true, WindowUntil: "2020-01-01T00:00:00Z"}, send(t, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK))
+
+	send(t, toggle("maybe"), http.StatusBadRequest)
+	require.True(t, c.blocksCleaner.isCleanupSuppressed(time.Now()))
+
+	require.Equal(t, cleanupSuppressionResponse{WindowUntil: "2020-01-01T00:00:00Z"}, send(t, toggle("false"), http.StatusOK))
+	require.False(t, c.blocksCleaner.isCleanupSuppressed(time.Now()))
+}
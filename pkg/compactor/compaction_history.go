@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	compactionStatusSucceeded   = "succeeded"
+	compactionStatusFailed      = "failed"
+	compactionStatusInterrupted = "interrupted"
+	compactionStatusSkipped     = "skipped"
+)
+
+// compactionHistoryEntry describes a compaction of a tenant, including all its retries.
+type compactionHistoryEntry struct {
+	startedAt     time.Time
+	duration      time.Duration
+	status        string
+	attempts      int
+	jobsSucceeded int
+	jobsFailed    int
+	err           error
+}
+
+// newCompactionHistoryEntry returns the history entry of a compaction of a tenant started at startedAt,
+// and ended with the input error.
+func newCompactionHistoryEntry(startedAt time.Time, attempts int, jobs compactionJobsCount, err error) compactionHistoryEntry {
+	return compactionHistoryEntry{
+		startedAt:     startedAt,
+		duration:      time.Since(startedAt),
+		status:        compactionStatus(err),
+		attempts:      attempts,
+		jobsSucceeded: jobs.succeeded,
+		jobsFailed:    jobs.failed,
+		err:           err,
+	}
+}
+
+// compactionStatus returns the status of a compaction of a tenant ended with the input error.
+func compactionStatus(err error) string {
+	switch {
+	case err == nil:
+		return compactionStatusSucceeded
+	case errors.Is(err, errTenantLeaseUnavailable):
+		return compactionStatusSkipped
+	case errors.Is(err, context.Canceled):
+		return compactionStatusInterrupted
+	default:
+		return compactionStatusFailed
+	}
+}
+
+// compactionHistory keeps, for each tenant, the most recent compactions run by this compactor.
+// The history is kept in memory only, so it doesn't survive restarts.
+type compactionHistory struct {
+	size int
+
+	mtx     sync.Mutex
+	tenants map[string][]compactionHistoryEntry
+}
+
+func newCompactionHistory(size int) *compactionHistory {
+	return &compactionHistory{
+		size:    size,
+		tenants: map[string][]compactionHistoryEntry{},
+	}
+}
+
+// add adds an entry to the tenant history, evicting the oldest entry if the history is full.
+func (h *compactionHistory) add(userID string, entry compactionHistoryEntry) {
+	if h.size <= 0 {
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	entries := h.tenants[userID]
+	if len(entries) < h.size {
+		h.tenants[userID] = append(entries, entry)
+		return
+	}
+
+	copy(entries, entries[1:])
+	entries[len(entries)-1] = entry
+}
+
+// get returns the tenant history, most recent compaction first.
+func (h *compactionHistory) get(userID string) []compactionHistoryEntry {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	entries := slices.Clone(h.tenants[userID])
+	slices.Reverse(entries)
+	return entries
+}
+
+// retain removes the history of the tenants not in the input set.
+func (h *compactionHistory) retain(userIDs map[string]struct{}) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for userID := range h.tenants {
+		if _, ok := userIDs[userID]; !ok {
+			delete(h.tenants, userID)
+		}
+	}
+}
+
+type compactionHistoryResponse struct {
+	Tenant      string                   `json:"tenant"`
+	Compactions []compactionHistoryEvent `json:"compactions"`
+}
+
+type compactionHistoryEvent struct {
+	StartedAt     string  `json:"started_at"`
+	Duration      string  `json:"duration"`
+	DurationSecs  float64 `json:"duration_seconds"`
+	Status        string  `json:"status"`
+	Attempts      int     `json:"attempts"`
+	JobsSucceeded int     `json:"jobs_succeeded"`
+	JobsFailed    int     `json:"jobs_failed"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// CompactionHistoryHandler returns, as JSON, the most recent compactions of a tenant run by this compactor,
+// most recent first.
+func (c *MultitenantCompactor) CompactionHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		http.Error(w, "tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	entries := c.compactionHistory.get(tenantID)
+
+	resp := compactionHistoryResponse{
+		Tenant:      tenantID,
+		Compactions: make([]compactionHistoryEvent, 0, len(entries)),
+	}
+	for _, e := range entries {
+		event := compactionHistoryEvent{
+			StartedAt:     formatTime(e.startedAt),
+			Duration:      e.duration.String(),
+			DurationSecs:  e.duration.Seconds(),
+			Status:        e.status,
+			Attempts:      e.attempts,
+			JobsSucceeded: e.jobsSucceeded,
+			JobsFailed:    e.jobsFailed,
+		}
+		if e.err != nil {
+			event.Error = e.err.Error()
+		}
+		resp.Compactions = append(resp.Compactions, event)
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid/v2"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestCompactionHistory(t *testing.T) {
+	now := time.Now()
+	entryAt := func(offset int) compactionHistoryEntry {
+		return compactionHistoryEntry{startedAt: now.Add(time.Duration(offset) * time.Minute)}
+	}
+
+	t.Run("should keep the most recent entries of each tenant", func(t *testing.T) {
+		h := newCompactionHistory(2)
+		h.add("user-1", entryAt(1))
+		h.add("user-1", entryAt(2))
+		h.add("user-1", entryAt(3))
+		h.add("user-2", entryAt(4))
+
+		assert.Equal(t, []compactionHistoryEntry{entryAt(3), entryAt(2)}, h.get("user-1"))
+		assert.Equal(t, []compactionHistoryEntry{entryAt(4)}, h.get("user-2"))
+		assert.Empty(t, h.get("user-3"))
+	})
+
+	t.Run("should drop the history of tenants not retained", func(t *testing.T) {
+		h := newCompactionHistory(2)
+		h.add("user-1", entryAt(1))
+		h.add("user-2", entryAt(2))
+
+		h.retain(map[string]struct{}{"user-2": {}})
+
+		assert.Empty(t, h.get("user-1"))
+		assert.Equal(t, []compactionHistoryEntry{entryAt(2)}, h.get("user-2"))
+	})
+
+	t.Run("should keep no history if disabled", func(t *testing.T) {
+		h := newCompactionHistory(0)
+		h.add("user-1", entryAt(1))
+
+		assert.Empty(t, h.get("user-1"))
+	})
+}
+
+func TestNewCompactionHistoryEntry(t *testing.T) {
+	startedAt := time.Now().Add(-time.Minute)
+
+	for err, expectedStatus := range map[error]string{
+		nil:                       compactionStatusSucceeded,
+		errTenantLeaseUnavailable: compactionStatusSkipped,
+		context.Canceled:          compactionStatusInterrupted,
+		errors.New("failure"):     compactionStatusFailed,
+	} {
+		entry := newCompactionHistoryEntry(startedAt, 2, compactionJobsCount{succeeded: 3, failed: 1}, err)
+
+		assert.Equal(t, expectedStatus, entry.status)
+		assert.Equal(t, 2, entry.attempts)
+		assert.Equal(t, 3, entry.jobsSucceeded)
+		assert.Equal(t, 1, entry.jobsFailed)
+		assert.GreaterOrEqual(t, entry.duration, time.Minute)
+	}
+}
+
+func TestCompactionHistoryHandler(t *testing.T) {
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range []string{"user-1", "user-2"} {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	leasesKV, closer := consul.NewInMemoryClient(tenantLeasesCodec{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	// Another compactor is compacting user-1, so that it gets skipped.
+	require.NoError(t, newTenantLeaser(leasesKV, "other-compactor", 1, log.NewNopLogger()).acquire(context.Background(), "user-1"))
+
+	cfg := prepareConfig(t)
+	cfg.MaxConcurrentInstancesPerTenant = 1
+	cfg.tenantLeasesKVMock = leasesKV
+
+	c, _, tsdbPlanner, _, _ := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	history := func(t *testing.T, tenantID string) compactionHistoryResponse {
+		resp := httptest.NewRecorder()
+		c.CompactionHistoryHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"tenant": tenantID}))
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var res compactionHistoryResponse
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+		require.Equal(t, tenantID, res.Tenant)
+		return res
+	}
+
+	res := history(t, "user-1")
+	require.NotEmpty(t, res.Compactions)
+	assert.Equal(t, compactionStatusSkipped, res.Compactions[0].Status)
+	assert.Equal(t, 0, res.Compactions[0].Attempts)
+	assert.Equal(t, errTenantLeaseUnavailable.Error(), res.Compactions[0].Error)
+
+	res = history(t, "user-2")
+	require.NotEmpty(t, res.Compactions)
+	assert.Equal(t, compactionStatusSucceeded, res.Compactions[0].Status)
+	assert.Equal(t, 1, res.Compactions[0].Attempts)
+	assert.Empty(t, res.Compactions[0].Error)
+	assert.NotEmpty(t, res.Compactions[0].StartedAt)
+
+	res = history(t, "user-3")
+	assert.Empty(t, res.Compactions)
+}
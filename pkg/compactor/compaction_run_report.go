@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/util/atomicfs"
+)
+
+const (
+	runReportFilePrefix = "compaction-run-"
+	runReportFileSuffix = ".json"
+
+	// runReportTimeFormat is the format of the start time in the report file names. It sorts lexicographically
+	// in chronological order.
+	runReportTimeFormat = "20060102T150405.000Z"
+)
+
+// compactionRunReport summarizes a compaction run, which compacts all the tenants owned by the compactor.
+type compactionRunReport struct {
+	StartedAt         time.Time `json:"started_at"`
+	FinishedAt        time.Time `json:"finished_at"`
+	Status            string    `json:"status"`
+	DiscoveredTenants int       `json:"discovered_tenants"`
+	OwnedTenants      int       `json:"owned_tenants"`
+	SkippedTenants    int       `json:"skipped_tenants"`
+	SucceededTenants  int       `json:"succeeded_tenants"`
+	FailedTenants     int       `json:"failed_tenants"`
+	CompactedBlocks   int       `json:"compacted_blocks"`
+	CompactedBytes    int64     `json:"compacted_bytes"`
+}
+
+func runReportFilename(startedAt time.Time) string {
+	return runReportFilePrefix + startedAt.UTC().Format(runReportTimeFormat) + runReportFileSuffix
+}
+
+// writeRunReport writes the report to a file in dir, named after the start time of the run. If maxCount is
+// positive, the oldest reports in dir in excess of maxCount are deleted.
+func writeRunReport(dir string, report compactionRunReport, maxCount int) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrap(err, "create run report directory")
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal run report")
+	}
+
+	if err := atomicfs.CreateFile(filepath.Join(dir, runReportFilename(report.StartedAt)), bytes.NewReader(data)); err != nil {
+		return errors.Wrap(err, "write run report")
+	}
+
+	if maxCount <= 0 {
+		return nil
+	}
+	return deleteOldRunReports(dir, maxCount)
+}
+
+// deleteOldRunReports deletes the oldest reports in dir, keeping the most recent maxCount ones.
+func deleteOldRunReports(dir string, maxCount int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "list run reports")
+	}
+
+	// Entries are sorted by filename, so reports are sorted from the oldest to the most recent.
+	var reports []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), runReportFilePrefix) && strings.HasSuffix(e.Name(), runReportFileSuffix) {
+			reports = append(reports, e.Name())
+		}
+	}
+
+	for _, name := range reports[:max(0, len(reports)-maxCount)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return errors.Wrapf(err, "delete run report %s", name)
+		}
+	}
+	return nil
+}
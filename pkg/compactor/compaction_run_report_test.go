@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid/v2"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestWriteRunReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	startedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Unrelated files are never deleted.
+	require.NoError(t, os.MkdirAll(dir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0600))
+
+	for i := 0; i < 5; i++ {
+		report := compactionRunReport{
+			StartedAt:       startedAt.Add(time.Duration(i) * time.Hour),
+			FinishedAt:      startedAt.Add(time.Duration(i)*time.Hour + time.Minute),
+			Status:          compactionStatusSucceeded,
+			CompactedBlocks: i,
+		}
+		require.NoError(t, writeRunReport(dir, report, 3))
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{
+		"compaction-run-20240102T050405.000Z.json",
+		"compaction-run-20240102T060405.000Z.json",
+		"compaction-run-20240102T070405.000Z.json",
+		"other.json",
+	}, names)
+
+	data, err := os.ReadFile(filepath.Join(dir, "compaction-run-20240102T070405.000Z.json"))
+	require.NoError(t, err)
+
+	var report compactionRunReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, 4, report.CompactedBlocks)
+	assert.Equal(t, compactionStatusSucceeded, report.Status)
+	assert.True(t, startedAt.Add(4*time.Hour).Equal(report.StartedAt))
+
+	// All reports are kept if there's no max count.
+	require.NoError(t, writeRunReport(dir, compactionRunReport{StartedAt: startedAt.Add(5 * time.Hour)}, 0))
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
+
+func TestMultitenantCompactor_ShouldWriteRunReport(t *testing.T) {
+	t.Parallel()
+
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range []string{"user-1", "user-2"} {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	cfg := prepareConfig(t)
+	cfg.RunReportDir = t.TempDir()
+
+	c, _, tsdbPlanner, _, _ := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until a run has completed.
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	entries, err := os.ReadDir(cfg.RunReportDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasPrefix(entries[0].Name(), runReportFilePrefix))
+
+	data, err := os.ReadFile(filepath.Join(cfg.RunReportDir, entries[0].Name()))
+	require.NoError(t, err)
+
+	var report compactionRunReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, compactionStatusSucceeded, report.Status)
+	assert.Equal(t, 2, report.DiscoveredTenants)
+	assert.Equal(t, 2, report.OwnedTenants)
+	assert.Equal(t, 0, report.SkippedTenants)
+	assert.Equal(t, 2, report.SucceededTenants)
+	assert.Equal(t, 0, report.FailedTenants)
+	assert.Equal(t, 0, report.CompactedBlocks)
+	assert.False(t, report.FinishedAt.Before(report.StartedAt))
+}
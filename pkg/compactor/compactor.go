@@ -10,18 +10,20 @@ import (
 	"flag"
 	"fmt"
 	"hash/fnv"
+	"io/fs"
 	"math/rand"
 	"os"
-	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
@@ -36,8 +38,10 @@ import (
 	"github.com/grafana/mimir/pkg/storage/indexheader"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -57,6 +61,23 @@ var (
 	errInvalidMaxClosingBlocksConcurrency         = fmt.Errorf("invalid max-closing-blocks-concurrency value, must be positive")
 	errInvalidSymbolFlushersConcurrency           = fmt.Errorf("invalid symbols-flushers-concurrency value, must be positive")
 	errInvalidMaxBlockUploadValidationConcurrency = fmt.Errorf("invalid max-block-upload-validation-concurrency value, can't be negative")
+	errInvalidCompactionIntervalJitter            = fmt.Errorf("invalid compaction-interval-jitter value, must be in the range [0, 1)")
+	errInvalidCleanupIntervalJitter               = fmt.Errorf("invalid cleanup-interval-jitter value, must be in the range [0, 1)")
+	errInvalidMaxConcurrentInstancesPerTenant     = fmt.Errorf("invalid max-concurrent-instances-per-tenant value, can't be negative")
+	errInvalidCompactionHistorySize               = fmt.Errorf("invalid compaction-history-size value, can't be negative")
+	errInvalidRunReportMaxCount                   = fmt.Errorf("invalid run-report-max-count value, can't be negative")
+	errInvalidMaxJobSymbolTableSizeBytes          = fmt.Errorf("invalid max-job-symbol-table-size-bytes value, can't be negative")
+	errInvalidMaxOpenBlocksGlobal                 = fmt.Errorf("invalid max-open-blocks-global value, can't be negative")
+	errInvalidMaxUploadInflightBytes              = fmt.Errorf("invalid max-upload-inflight-bytes value, can't be negative")
+	errInvalidMaxCompactionMemoryBytes            = fmt.Errorf("invalid max-compaction-memory-bytes value, can't be negative")
+	errInvalidMaxPartialBlocksPerCleanup          = fmt.Errorf("invalid max-partial-blocks-per-cleanup value, can't be negative")
+	errInvalidCleanupSuppressionWindow            = fmt.Errorf("invalid cleanup suppression window, cleanup-suppressed-until must be set and after cleanup-suppressed-from")
+	errInvalidRingChangeRebalanceDelay            = fmt.Errorf("invalid ring-change-rebalance-delay value, can't be negative")
+	errInvalidTenantConcurrency                   = fmt.Errorf("invalid tenant-concurrency value, must be positive")
+	errInvalidBucketIndexMaxStalePeriod           = fmt.Errorf("invalid bucket-index-max-stale-period value, must be 0 or greater than cleanup-interval")
+	errInvalidBucketIndexUnchangedWriteSkipPeriod = fmt.Errorf("invalid bucket-index-unchanged-write-skip-period value, can't be negative and must be lower than bucket-index-max-stale-period minus cleanup-interval")
+	errMaxConcurrentInstancesPerTenantMemberlist  = fmt.Errorf("max-concurrent-instances-per-tenant requires the compactor ring to use consul, etcd or inmemory as KV store")
+	errCompactionRunInterrupted                   = errors.New("compaction run interrupted")
 	RingOp                                        = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, nil)
 
 	// compactionIgnoredLabels defines the external labels that compactor will
@@ -68,6 +89,23 @@ var (
 	}
 )
 
+// compactionIgnoredLabelsExcept returns compactionIgnoredLabels without the input required grouping labels.
+// Since the group key is computed from all the remaining external labels, keeping the required labels
+// guarantees blocks with different values for them are never grouped together.
+func compactionIgnoredLabelsExcept(requiredGroupingLabels []string) []string {
+	if len(requiredGroupingLabels) == 0 {
+		return compactionIgnoredLabels
+	}
+
+	ignored := make([]string, 0, len(compactionIgnoredLabels))
+	for _, l := range compactionIgnoredLabels {
+		if !slices.Contains(requiredGroupingLabels, l) {
+			ignored = append(ignored, l)
+		}
+	}
+	return ignored
+}
+
 // BlocksGrouperFactory builds and returns the grouper to use to compact a tenant's blocks.
 type BlocksGrouperFactory func(
 	ctx context.Context,
@@ -93,16 +131,59 @@ type Config struct {
 	MetaSyncConcurrency        int                     `yaml:"meta_sync_concurrency" category:"advanced"`
 	DataDir                    string                  `yaml:"data_dir"`
 	CompactionInterval         time.Duration           `yaml:"compaction_interval" category:"advanced"`
+	CompactionIntervalJitter   float64                 `yaml:"compaction_interval_jitter" category:"experimental"`
 	CompactionRetries          int                     `yaml:"compaction_retries" category:"advanced"`
 	CompactionConcurrency      int                     `yaml:"compaction_concurrency" category:"advanced"`
 	CompactionWaitPeriod       time.Duration           `yaml:"first_level_compaction_wait_period"`
 	CleanupInterval            time.Duration           `yaml:"cleanup_interval" category:"advanced"`
+	CleanupIntervalJitter      float64                 `yaml:"cleanup_interval_jitter" category:"experimental"`
 	CleanupConcurrency         int                     `yaml:"cleanup_concurrency" category:"advanced"`
 	DeletionDelay              time.Duration           `yaml:"deletion_delay" category:"advanced"`
 	TenantCleanupDelay         time.Duration           `yaml:"tenant_cleanup_delay" category:"advanced"`
 	MaxCompactionTime          time.Duration           `yaml:"max_compaction_time" category:"advanced"`
 	NoBlocksFileCleanupEnabled bool                    `yaml:"no_blocks_file_cleanup_enabled" category:"experimental"`
 
+	SupersededBlocksCleanupEnabled bool          `yaml:"superseded_blocks_cleanup_enabled" category:"experimental"`
+	FutureBlocksTolerance          time.Duration `yaml:"future_blocks_tolerance" category:"experimental"`
+	AnomalousBlocksGap             time.Duration `yaml:"anomalous_blocks_gap" category:"experimental"`
+	BlockSizeMetricsEnabled        bool          `yaml:"block_size_metrics_enabled" category:"experimental"`
+	BlockLevelMetricsEnabled       bool          `yaml:"block_level_metrics_enabled" category:"experimental"`
+
+	CleanupSuppressedFrom  flagext.Time `yaml:"cleanup_suppressed_from" category:"experimental"`
+	CleanupSuppressedUntil flagext.Time `yaml:"cleanup_suppressed_until" category:"experimental"`
+
+	MaxPartialBlocksPerCleanup int `yaml:"max_partial_blocks_per_cleanup" category:"experimental"`
+
+	TenantDataDirIsolationEnabled   bool `yaml:"tenant_data_dir_isolation_enabled" category:"experimental"`
+	MaxConcurrentInstancesPerTenant int  `yaml:"max_concurrent_instances_per_tenant" category:"experimental"`
+
+	ExternalRetentionEnabled  bool          `yaml:"external_retention_enabled" category:"experimental"`
+	ExternalRetentionCacheTTL time.Duration `yaml:"external_retention_cache_ttl" category:"experimental"`
+
+	CompactionHistorySize int `yaml:"compaction_history_size" category:"experimental"`
+
+	TenantCompactionRecordEnabled bool `yaml:"tenant_compaction_record_enabled" category:"experimental"`
+
+	TenantCompactionSummaryLogEnabled bool `yaml:"tenant_compaction_summary_log_enabled" category:"experimental"`
+
+	RunReportDir      string `yaml:"run_report_dir" category:"experimental"`
+	RunReportMaxCount int    `yaml:"run_report_max_count" category:"experimental"`
+
+	BucketIndexMaxStalePeriod           time.Duration `yaml:"bucket_index_max_stale_period" category:"experimental"`
+	BucketIndexUnchangedWriteSkipPeriod time.Duration `yaml:"bucket_index_unchanged_write_skip_period" category:"experimental"`
+
+	MaxJobSymbolTableSizeBytes int64 `yaml:"max_job_symbol_table_size_bytes" category:"experimental"`
+
+	MaxOpenBlocksGlobal int `yaml:"max_open_blocks_global" category:"experimental"`
+
+	MaxUploadInflightBytes int64 `yaml:"max_upload_inflight_bytes" category:"experimental"`
+
+	MaxCompactionMemoryBytes int64 `yaml:"max_compaction_memory_bytes" category:"experimental"`
+
+	RingChangeRebalanceDelay time.Duration `yaml:"ring_change_rebalance_delay" category:"experimental"`
+
+	TenantConcurrency int `yaml:"tenant_concurrency" category:"experimental"`
+
 	// Compactor concurrency options
 	MaxOpeningBlocksConcurrency         int `yaml:"max_opening_blocks_concurrency" category:"advanced"`          // Number of goroutines opening blocks before compaction.
 	MaxClosingBlocksConcurrency         int `yaml:"max_closing_blocks_concurrency" category:"advanced"`          // Max number of blocks that can be closed concurrently during split compaction. Note that closing of newly compacted block uses a lot of memory for writing index.
@@ -124,12 +205,21 @@ type Config struct {
 	retryMinBackoff time.Duration `yaml:"-"`
 	retryMaxBackoff time.Duration `yaml:"-"`
 
+	// Backoff used when the object storage throttles the users discovery.
+	// It's longer than the generic one to give the object storage time to recover.
+	throttledRetryMinBackoff time.Duration `yaml:"-"`
+	throttledRetryMaxBackoff time.Duration `yaml:"-"`
+
+	// Allow to override the KV client used to store the tenant leases in tests,
+	// given the mocked ring KV client is bound to the ring codec.
+	tenantLeasesKVMock kv.Client `yaml:"-"`
+
 	// Allow downstream projects to customise the blocks compactor.
 	BlocksGrouperFactory   BlocksGrouperFactory   `yaml:"-"`
 	BlocksCompactorFactory BlocksCompactorFactory `yaml:"-"`
+	BlocksRetentionSource  RetentionSource        `yaml:"-"`
 
-	// Allow compactor to upload sparse-index-header files
-	UploadSparseIndexHeaders       bool               `yaml:"upload_sparse_index_headers" category:"experimental"`
+	// Sparse-index-header files uploaded by the compactor, if enabled for the tenant.
 	SparseIndexHeadersSamplingRate int                `yaml:"-"`
 	SparseIndexHeadersConfig       indexheader.Config `yaml:"-"`
 }
@@ -141,17 +231,21 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.BlockRanges = mimir_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}
 	cfg.retryMinBackoff = 10 * time.Second
 	cfg.retryMaxBackoff = time.Minute
+	cfg.throttledRetryMinBackoff = 30 * time.Second
+	cfg.throttledRetryMaxBackoff = 5 * time.Minute
 
 	f.Var(&cfg.BlockRanges, "compactor.block-ranges", "List of compaction time ranges.")
 	f.IntVar(&cfg.BlockSyncConcurrency, "compactor.block-sync-concurrency", 8, "Number of Go routines to use when downloading blocks for compaction and uploading resulting blocks.")
 	f.IntVar(&cfg.MetaSyncConcurrency, "compactor.meta-sync-concurrency", 20, "Number of Go routines to use when syncing block meta files from the long term storage.")
 	f.StringVar(&cfg.DataDir, "compactor.data-dir", "./data-compactor/", "Directory to temporarily store blocks during compaction. This directory is not required to be persisted between restarts.")
 	f.DurationVar(&cfg.CompactionInterval, "compactor.compaction-interval", time.Hour, "The frequency at which the compaction runs")
+	f.Float64Var(&cfg.CompactionIntervalJitter, "compactor.compaction-interval-jitter", 0.05, "Jitter applied to the compaction interval, as a fraction of the interval. Higher values spread compaction runs of different compactors more evenly over time. The value must be in the range [0, 1).")
 	f.DurationVar(&cfg.MaxCompactionTime, "compactor.max-compaction-time", time.Hour, "Max time for starting compactions for a single tenant. After this time no new compactions for the tenant are started before next compaction cycle. This can help in multi-tenant environments to avoid single tenant using all compaction time, but also in single-tenant environments to force new discovery of blocks more often. 0 = disabled.")
 	f.IntVar(&cfg.CompactionRetries, "compactor.compaction-retries", 3, "How many times to retry a failed compaction within a single compaction run.")
 	f.IntVar(&cfg.CompactionConcurrency, "compactor.compaction-concurrency", 1, "Max number of concurrent compactions running.")
 	f.DurationVar(&cfg.CompactionWaitPeriod, "compactor.first-level-compaction-wait-period", 25*time.Minute, "How long the compactor waits before compacting first-level blocks that are uploaded by the ingesters. This configuration option allows for the reduction of cases where the compactor begins to compact blocks before all ingesters have uploaded their blocks to the storage.")
 	f.DurationVar(&cfg.CleanupInterval, "compactor.cleanup-interval", 15*time.Minute, "How frequently the compactor should run blocks cleanup and maintenance, as well as update the bucket index.")
+	f.Float64Var(&cfg.CleanupIntervalJitter, "compactor.cleanup-interval-jitter", 0.1, "Jitter applied to the cleanup interval, as a fraction of the interval. The value must be in the range [0, 1).")
 	f.IntVar(&cfg.CleanupConcurrency, "compactor.cleanup-concurrency", 20, "Max number of tenants for which blocks cleanup and maintenance should run concurrently.")
 	f.StringVar(&cfg.CompactionJobsOrder, "compactor.compaction-jobs-order", CompactionOrderOldestFirst, fmt.Sprintf("The sorting to use when deciding which compaction jobs should run first for a given tenant. Supported values are: %s.", strings.Join(CompactionOrders, ", ")))
 	f.DurationVar(&cfg.DeletionDelay, "compactor.deletion-delay", 12*time.Hour, "Time before a block marked for deletion is deleted from bucket. "+
@@ -159,7 +253,31 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 		"If 0, blocks will be deleted straight away. Note that deleting blocks immediately can cause query failures.")
 	f.DurationVar(&cfg.TenantCleanupDelay, "compactor.tenant-cleanup-delay", 6*time.Hour, "For tenants marked for deletion, this is the time between deletion of the last block, and doing final cleanup (marker files, debug files) of the tenant.")
 	f.BoolVar(&cfg.NoBlocksFileCleanupEnabled, "compactor.no-blocks-file-cleanup-enabled", false, "If enabled, will delete the bucket-index, markers and debug files in the tenant bucket when there are no blocks left in the index.")
-	f.BoolVar(&cfg.UploadSparseIndexHeaders, "compactor.upload-sparse-index-headers", false, "If enabled, the compactor constructs and uploads sparse index headers to object storage during each compaction cycle. This allows store-gateway instances to use the sparse headers from object storage instead of recreating them locally.")
+	f.BoolVar(&cfg.SupersededBlocksCleanupEnabled, "compactor.superseded-blocks-cleanup-enabled", false, "If enabled, the blocks cleaner marks for deletion the blocks fully included in other compacted blocks, which could be left behind when a compaction is interrupted before marking its source blocks for deletion. Unlike the compactor, the blocks cleaner also finds them among the blocks uploaded before -compactor.max-lookback. The blocks marked for no-compaction are never considered.")
+	f.DurationVar(&cfg.FutureBlocksTolerance, "compactor.future-blocks-tolerance", 7*24*time.Hour, "Blocks whose min time is further than this in the future, e.g. because of clock skew or bad ingestion, are marked for no-compaction by the blocks cleaner. 0 to disable.")
+	f.DurationVar(&cfg.AnomalousBlocksGap, "compactor.anomalous-blocks-gap", 0, "Blocks whose min time is further than this after the max time of all the tenant's blocks starting before them, which may indicate an ingestion anomaly, are marked for no-compaction during the compaction planning, so that they're not merged into the compacted blocks until an operator reviews them. 0 to disable.")
+	f.BoolVar(&cfg.BlockSizeMetricsEnabled, "compactor.block-size-metrics-enabled", false, "If enabled, the blocks cleaner exports the size distribution of each tenant's blocks as the cortex_bucket_block_size_bytes histogram, computed from the bucket index.")
+	f.BoolVar(&cfg.BlockLevelMetricsEnabled, "compactor.block-level-metrics-enabled", false, "If enabled, the blocks cleaner exports the number of each tenant's blocks by compaction level as the cortex_bucket_blocks_by_level_count gauge, computed from the bucket index.")
+	f.Var(&cfg.CleanupSuppressedFrom, "compactor.cleanup-suppressed-from", "Start of a maintenance window during which the blocks cleaner doesn't delete blocks nor tenants, and doesn't apply the retention, while still updating the bucket indexes. Supported formats: YYYY-MM-DD, YYYY-MM-DDTHH:MM, RFC3339. If not set, the window starts immediately. Requires -compactor.cleanup-suppressed-until.")
+	f.Var(&cfg.CleanupSuppressedUntil, "compactor.cleanup-suppressed-until", "End of the maintenance window during which the blocks cleaner doesn't delete blocks nor tenants, and doesn't apply the retention. Once the end is reached, the cleanup resumes automatically. Supported formats: YYYY-MM-DD, YYYY-MM-DDTHH:MM, RFC3339. 0 to disable the window.")
+	f.IntVar(&cfg.MaxPartialBlocksPerCleanup, "compactor.max-partial-blocks-per-cleanup", 0, "Maximum number of partial blocks of each tenant processed by the blocks cleaner per cleanup. If a tenant has more partial blocks, the oldest ones are processed first and the others are left to the next cleanups, bounding the object storage calls of each cleanup. 0 = no limit.")
+	f.BoolVar(&cfg.TenantDataDirIsolationEnabled, "compactor.tenant-data-dir-isolation-enabled", false, "If enabled, each tenant's compaction working files are stored in a dedicated sub-directory of -compactor.data-dir, and the per-tenant -compactor.tenant-disk-quota-bytes limit is enforced before running each compaction job of the tenant.")
+	f.IntVar(&cfg.MaxConcurrentInstancesPerTenant, "compactor.max-concurrent-instances-per-tenant", 0, "Max number of compactor instances that can concurrently compact the same tenant. Compactors coordinate through leases stored in the compactor ring KV store, which must be consul, etcd or inmemory. 0 = no limit.")
+	f.BoolVar(&cfg.ExternalRetentionEnabled, "compactor.external-retention-enabled", false, fmt.Sprintf("If enabled, the blocks cleaner reads the blocks retention period of each tenant from the %s object in the tenant's bucket prefix, when present, instead of -compactor.blocks-retention-period. Changes to the object take effect without a configuration reload.", TenantRetentionPath))
+	f.DurationVar(&cfg.ExternalRetentionCacheTTL, "compactor.external-retention-cache-ttl", time.Minute, "How long the blocks retention period read from the tenant's bucket prefix is cached.")
+	f.IntVar(&cfg.CompactionHistorySize, "compactor.compaction-history-size", 10, "Number of most recent compactions of each tenant kept in memory and exposed by the tenant compaction history API. 0 to disable.")
+	f.BoolVar(&cfg.TenantCompactionRecordEnabled, "compactor.tenant-compaction-record-enabled", false, fmt.Sprintf("If enabled, the compactor records its instance ID and the time in the %s object in the tenant's bucket prefix after each successful compaction of the tenant, and counts the tenants previously compacted by a different compactor, which may indicate an unstable compactor ring.", TenantCompactionRecordPath))
+	f.BoolVar(&cfg.TenantCompactionSummaryLogEnabled, "compactor.tenant-compaction-summary-log-enabled", false, "If enabled, the compactor logs a single summary line at the end of each attempt to compact a tenant, successful or not, with the number of blocks before and after the compaction, the number of compaction jobs run, the bytes read and written, the duration and the outcome.")
+	f.StringVar(&cfg.RunReportDir, "compactor.run-report-dir", "", "If set, the compactor writes a JSON report summarizing each compaction run to this directory, named after the start time of the run. The report includes the number of discovered, owned, skipped, succeeded and failed tenants, and the number and size of the compacted blocks.")
+	f.IntVar(&cfg.RunReportMaxCount, "compactor.run-report-max-count", 100, "Maximum number of compaction run reports kept in -compactor.run-report-dir. The oldest reports are deleted. 0 to keep all reports.")
+	f.DurationVar(&cfg.BucketIndexMaxStalePeriod, "compactor.bucket-index-max-stale-period", 0, "If the bucket index of a tenant has not been updated by the blocks cleaner for longer than this period, the compactor skips the tenant until the bucket index is updated again. Tenants without a bucket index are never skipped. Must be greater than -compactor.cleanup-interval. 0 to disable.")
+	f.DurationVar(&cfg.BucketIndexUnchangedWriteSkipPeriod, "compactor.bucket-index-unchanged-write-skip-period", 0, "If the bucket index of a tenant is unchanged since the blocks cleaner last wrote it, the blocks cleaner skips writing it again for up to this period, reducing the object storage writes for tenants without block changes. The bucket index is written at least once per period, so its updated-at timestamp can be older than this period plus -compactor.cleanup-interval: the period must be lower than the max stale period of the bucket index configured in queriers, store-gateways and compactors. 0 to disable.")
+	f.Int64Var(&cfg.MaxJobSymbolTableSizeBytes, "compactor.max-job-symbol-table-size-bytes", 0, "Maximum estimated size in bytes of the symbol table of a compaction job, computed as the sum of the symbol table sizes of its source blocks. Jobs exceeding it fail without being compacted, to protect the compactor from running out of memory while compacting tenants with a huge number of symbols. 0 = no limit.")
+	f.IntVar(&cfg.MaxOpenBlocksGlobal, "compactor.max-open-blocks-global", 0, "Maximum number of source blocks open at the same time across all the compaction jobs run concurrently by the compactor. Jobs wait for the blocks of other jobs to be closed before opening their own blocks, bounding the aggregate file descriptors and memory used by concurrent jobs. A job with more blocks than the limit runs once no other job has blocks open. 0 = no limit.")
+	f.Int64Var(&cfg.MaxUploadInflightBytes, "compactor.max-upload-inflight-bytes", 0, "Maximum total size of the compacted blocks uploaded at the same time across all the compaction jobs run concurrently by the compactor. Uploads wait for the other uploads to complete until enough of the budget is free, bounding the aggregate memory and bandwidth used by uploads of blocks of varying sizes. A block larger than the limit is uploaded once no other block is being uploaded. 0 = no limit.")
+	f.Int64Var(&cfg.MaxCompactionMemoryBytes, "compactor.max-compaction-memory-bytes", 0, "Maximum estimated memory of the compaction jobs run at the same time across all the tenants compacted by the compactor. The memory of a job is estimated from the index sizes of its source blocks. Jobs which would exceed the budget given the jobs currently running are deferred until the running jobs complete. A job larger than the budget runs once no other job is running. 0 = no limit.")
+	f.DurationVar(&cfg.RingChangeRebalanceDelay, "compactor.ring-change-rebalance-delay", 0, "If an instance leaves the compactor ring during a compaction run, the compactor waits this long for the ring to settle and then compacts, in the same run, the tenants it newly owns because of the change, instead of waiting for the next run. 0 to disable.")
+	f.IntVar(&cfg.TenantConcurrency, "compactor.tenant-concurrency", 1, "Max number of tenants compacted concurrently by each compactor. The compaction jobs of each tenant are still run up to -compactor.compaction-concurrency at a time. Compacting multiple tenants concurrently speeds up compactors owning many small tenants. When greater than 1, each tenant's blocks are compacted in a dedicated sub-directory of -compactor.data-dir.")
 
 	// compactor concurrency options
 	f.IntVar(&cfg.MaxOpeningBlocksConcurrency, "compactor.max-opening-blocks-concurrency", 1, "Number of goroutines opening blocks before compaction.")
@@ -172,21 +290,14 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.Var(&cfg.DisabledTenants, "compactor.disabled-tenants", "Comma separated list of tenants that cannot be compacted by the compactor. If specified, and the compactor would normally pick a given tenant for compaction (via -compactor.enabled-tenants or sharding), it will be ignored instead.")
 }
 
-func (cfg *Config) Validate(logger log.Logger) error {
-	// Mimir assumes that smaller blocks are eventually compacted to 24h blocks in
-	// various places on the read path (cache TTLs, query splitting). Warn when this
-	// isn't the case since it may affect performance.
-	if len(cfg.BlockRanges) > 0 {
-		if maxRange := slices.Max(cfg.BlockRanges); 24*time.Hour > maxRange {
-			level.Warn(logger).Log("msg", "Largest compactor block range is not 24h. This may result in degraded query performance", "range", maxRange)
-		}
+func (cfg *Config) Validate(limits validation.Limits, logger log.Logger) error {
+	if err := validateBlockRanges(cfg.BlockRanges, logger); err != nil {
+		return err
 	}
 
-	// Each block range period should be divisible by the previous one.
-	for i := 1; i < len(cfg.BlockRanges); i++ {
-		if cfg.BlockRanges[i]%cfg.BlockRanges[i-1] != 0 {
-			return errors.Errorf(errInvalidBlockRanges, cfg.BlockRanges[i].String(), cfg.BlockRanges[i-1].String())
-		}
+	// The default tenant block ranges can be set with a CLI flag, which isn't validated when parsed.
+	if err := validateBlockRanges(limits.CompactorTenantBlockRanges, logger); err != nil {
+		return err
 	}
 
 	if cfg.MaxOpeningBlocksConcurrency < 1 {
@@ -204,6 +315,54 @@ func (cfg *Config) Validate(logger log.Logger) error {
 	if !util.StringsContain(CompactionOrders, cfg.CompactionJobsOrder) {
 		return errInvalidCompactionOrder
 	}
+	if cfg.CompactionIntervalJitter < 0 || cfg.CompactionIntervalJitter >= 1 {
+		return errInvalidCompactionIntervalJitter
+	}
+	if cfg.CleanupIntervalJitter < 0 || cfg.CleanupIntervalJitter >= 1 {
+		return errInvalidCleanupIntervalJitter
+	}
+	if cfg.MaxConcurrentInstancesPerTenant < 0 {
+		return errInvalidMaxConcurrentInstancesPerTenant
+	}
+	if cfg.CompactionHistorySize < 0 {
+		return errInvalidCompactionHistorySize
+	}
+	if cfg.RunReportMaxCount < 0 {
+		return errInvalidRunReportMaxCount
+	}
+	if cfg.MaxJobSymbolTableSizeBytes < 0 {
+		return errInvalidMaxJobSymbolTableSizeBytes
+	}
+	if cfg.MaxOpenBlocksGlobal < 0 {
+		return errInvalidMaxOpenBlocksGlobal
+	}
+	if cfg.MaxUploadInflightBytes < 0 {
+		return errInvalidMaxUploadInflightBytes
+	}
+	if cfg.MaxCompactionMemoryBytes < 0 {
+		return errInvalidMaxCompactionMemoryBytes
+	}
+	if cfg.MaxPartialBlocksPerCleanup < 0 {
+		return errInvalidMaxPartialBlocksPerCleanup
+	}
+	if from, until := time.Time(cfg.CleanupSuppressedFrom), time.Time(cfg.CleanupSuppressedUntil); !from.IsZero() && (until.IsZero() || !until.After(from)) {
+		return errInvalidCleanupSuppressionWindow
+	}
+	if cfg.RingChangeRebalanceDelay < 0 {
+		return errInvalidRingChangeRebalanceDelay
+	}
+	if cfg.TenantConcurrency < 1 {
+		return errInvalidTenantConcurrency
+	}
+	if cfg.BucketIndexMaxStalePeriod < 0 || (cfg.BucketIndexMaxStalePeriod > 0 && cfg.BucketIndexMaxStalePeriod <= cfg.CleanupInterval) {
+		return errInvalidBucketIndexMaxStalePeriod
+	}
+	if cfg.BucketIndexUnchangedWriteSkipPeriod < 0 || (cfg.BucketIndexMaxStalePeriod > 0 && cfg.BucketIndexUnchangedWriteSkipPeriod > 0 && cfg.BucketIndexUnchangedWriteSkipPeriod+cfg.CleanupInterval >= cfg.BucketIndexMaxStalePeriod) {
+		return errInvalidBucketIndexUnchangedWriteSkipPeriod
+	}
+	if cfg.MaxConcurrentInstancesPerTenant > 0 && cfg.ShardingRing.Common.KVStore.Store == "memberlist" {
+		return errMaxConcurrentInstancesPerTenantMemberlist
+	}
 
 	return nil
 }
@@ -236,21 +395,102 @@ type ConfigProvider interface {
 	// CompactorBlockUploadValidationEnabled returns whether block upload validation is enabled for a given tenant.
 	CompactorBlockUploadValidationEnabled(tenantID string) bool
 
+	// CompactorTenantBlockUploadValidationConcurrency returns the max number of uploaded blocks of a given tenant
+	// that can be validated concurrently, in addition to the global -compactor.max-block-upload-validation-concurrency.
+	// 0 means no tenant limit.
+	CompactorTenantBlockUploadValidationConcurrency(tenantID string) int
+
 	// CompactorBlockUploadVerifyChunks returns whether chunk verification is enabled for a given tenant.
 	CompactorBlockUploadVerifyChunks(tenantID string) bool
 
 	// CompactorBlockUploadMaxBlockSizeBytes returns the maximum size in bytes of a block that is allowed to be uploaded or validated for a given user.
 	CompactorBlockUploadMaxBlockSizeBytes(userID string) int64
 
+	// CompactorBlockUploadMaxFiles returns the maximum number of files of a block that is allowed to be uploaded for a given user.
+	CompactorBlockUploadMaxFiles(userID string) int
+
 	// CompactorInMemoryTenantMetaCacheSize returns number of parsed *Meta objects that we can keep in memory for the user between compactions.
 	CompactorInMemoryTenantMetaCacheSize(userID string) int
 
+	// CompactorInMemoryTenantMetaCacheShadowSize returns the size of the cache whose hits and misses are tracked, without
+	// caching any parsed *Meta object, when the in-memory meta cache is disabled for the user.
+	CompactorInMemoryTenantMetaCacheShadowSize(userID string) int
+
 	// CompactorMaxLookback returns the duration of the compactor lookback period, blocks uploaded before the lookback period aren't
 	// considered in compactor cycles
 	CompactorMaxLookback(userID string) time.Duration
 
 	// CompactorMaxPerBlockUploadConcurrency returns the maximum number of TSDB files that can be uploaded concurrently for each block.
 	CompactorMaxPerBlockUploadConcurrency(userID string) int
+
+	// CompactorRequiredGroupingLabels returns the external labels that must always be taken into account when grouping
+	// blocks for compaction, so that blocks with different values for any of them are never compacted together.
+	CompactorRequiredGroupingLabels(userID string) []string
+
+	// CompactorTenantDiskQuotaBytes returns the maximum disk space the tenant's compaction working directory can use
+	// before the tenant's compaction jobs are deferred. It's only enforced when the tenant data directory isolation is
+	// enabled. 0 = no limit.
+	CompactorTenantDiskQuotaBytes(userID string) int64
+
+	// CompactorMaxBlockChunkSegmentSize returns the max size of the chunk segment files of the blocks compacted for
+	// the tenant. 0 = the TSDB default.
+	CompactorMaxBlockChunkSegmentSize(userID string) int64
+
+	// CompactorCompactedBlocksValidationConcurrency returns the max number of blocks output by a compaction job of the
+	// tenant that can be validated concurrently. 0 means the global -compactor.block-sync-concurrency applies.
+	CompactorCompactedBlocksValidationConcurrency(userID string) int
+
+	// CompactorMaxSeries returns the max number of series stored in the compacted blocks of the tenant, counting each
+	// series once per block. The oldest compacted blocks exceeding it are marked for deletion. 0 = no limit.
+	CompactorMaxSeries(userID string) int64
+
+	// CompactorTenantCompactionMemoryBytes returns the maximum estimated memory of the compaction jobs of the tenant
+	// run at the same time. Jobs exceeding it are deferred. 0 = no limit.
+	CompactorTenantCompactionMemoryBytes(userID string) int64
+
+	// CompactorNoBlocksFileCleanupEnabled returns whether the bucket index, markers and debug files of a given tenant can be
+	// deleted when the tenant has no blocks left. It's only honored when -compactor.no-blocks-file-cleanup-enabled is enabled.
+	CompactorNoBlocksFileCleanupEnabled(userID string) bool
+
+	// CompactorLogOverlappingBlocks returns whether the overlapping blocks found while compacting the blocks of a given
+	// tenant are logged. They're counted by a metric anyway.
+	CompactorLogOverlappingBlocks(userID string) bool
+
+	// CompactorTenantCompactionRetries returns how many times a failed compaction of a given tenant is retried within
+	// a single compaction run. 0 means -compactor.compaction-retries applies.
+	CompactorTenantCompactionRetries(userID string) int
+
+	// CompactorTenantBlockRanges returns the compaction time ranges of a given tenant. When empty, the global
+	// -compactor.block-ranges setting applies.
+	CompactorTenantBlockRanges(userID string) mimir_tsdb.DurationList
+
+	// CompactorTenantSchedulingWindows returns the daily time windows during which a given tenant is compacted.
+	// When empty, the tenant is compacted at any time.
+	CompactorTenantSchedulingWindows(userID string) util.TimeWindows
+
+	// CompactorUploadSparseIndexHeaders returns whether sparse index headers should be uploaded for a given tenant.
+	CompactorUploadSparseIndexHeaders(userID string) bool
+}
+
+// validateBlockRanges returns an error if the input compaction time ranges are invalid.
+func validateBlockRanges(ranges mimir_tsdb.DurationList, logger log.Logger) error {
+	// Mimir assumes that smaller blocks are eventually compacted to 24h blocks in
+	// various places on the read path (cache TTLs, query splitting). Warn when this
+	// isn't the case since it may affect performance.
+	if len(ranges) > 0 {
+		if maxRange := slices.Max(ranges); 24*time.Hour > maxRange {
+			level.Warn(logger).Log("msg", "Largest compactor block range is not 24h. This may result in degraded query performance", "range", maxRange)
+		}
+	}
+
+	// Each block range period should be divisible by the previous one.
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i]%ranges[i-1] != 0 {
+			return errors.Errorf(errInvalidBlockRanges, ranges[i].String(), ranges[i-1].String())
+		}
+	}
+
+	return nil
 }
 
 // MultitenantCompactor is a multi-tenant TSDB block compactor based on Thanos.
@@ -289,18 +529,35 @@ type MultitenantCompactor struct {
 	shardingStrategy shardingStrategy
 	jobsOrder        JobsOrderFunc
 
+	// Leases used to limit the number of compactors concurrently compacting the same tenant.
+	// Nil if there's no limit.
+	tenantLeaser *tenantLeaser
+
+	// Most recent compactions of each tenant, exposed via the tenant compaction history API.
+	compactionHistory *compactionHistory
+
 	// Metrics.
-	compactionRunsStarted          prometheus.Counter
-	compactionRunsCompleted        prometheus.Counter
-	compactionRunsErred            prometheus.Counter
-	compactionRunsShutdown         prometheus.Counter
-	compactionRunsLastSuccess      prometheus.Gauge
-	compactionRunDiscoveredTenants prometheus.Gauge
-	compactionRunSkippedTenants    prometheus.Gauge
-	compactionRunSucceededTenants  prometheus.Gauge
-	compactionRunFailedTenants     prometheus.Gauge
-	compactionRunInterval          prometheus.Gauge
-	blocksMarkedForDeletion        prometheus.Counter
+	compactionRunsStarted            prometheus.Counter
+	tenantInstanceChanges            prometheus.Counter
+	compactionRunsCompleted          prometheus.Counter
+	compactionRunsErred              prometheus.Counter
+	compactionRunsShutdown           prometheus.Counter
+	compactionRunsLastSuccess        prometheus.Gauge
+	compactionRunDiscoveredTenants   prometheus.Gauge
+	compactionRunSkippedTenants      prometheus.Gauge
+	tenantsSkipped                   *prometheus.CounterVec
+	bucketOperations                 *prometheus.CounterVec
+	compactionRunSucceededTenants    prometheus.Gauge
+	compactionRunFailedTenants       prometheus.Gauge
+	compactionRunInterval            prometheus.Gauge
+	blocksMarkedForDeletion          prometheus.Counter
+	userDiscoveryThrottled           prometheus.Counter
+	jobsRebalanced                   prometheus.Counter
+	jobsDeferredDiskQuota            prometheus.Counter
+	tenantCompactionProgress         *prometheus.GaugeVec
+	tenantCompactedBlocksValidations *prometheus.GaugeVec
+	blocksFailedToOpen               *prometheus.CounterVec
+	anomalousBlocks                  *prometheus.CounterVec
 
 	// outOfSpace is a separate metric for out-of-space errors because this is a common issue which often requires an operator to investigate,
 	// so alerts need to be able to treat it with higher priority than other compaction errors.
@@ -309,17 +566,32 @@ type MultitenantCompactor struct {
 	// Metrics shared across all BucketCompactor instances.
 	bucketCompactorMetrics *BucketCompactorMetrics
 
+	// Limiter of the blocks open by the compaction jobs, shared across all BucketCompactor instances.
+	openBlocksLimiter *openBlocksLimiter
+
+	// Limiter of the size of the blocks uploaded by the compaction jobs, shared across all BucketCompactor instances.
+	uploadBytesLimiter *uploadBytesLimiter
+
+	// Limiter of the estimated memory of the compaction jobs, shared across all BucketCompactor instances.
+	jobsMemoryLimiter *jobsMemoryLimiter
+
 	// TSDB syncer metrics
 	syncerMetrics *aggregatedSyncerMetrics
 
 	// Block upload metrics
-	blockUploadBlocks      *prometheus.CounterVec
-	blockUploadBytes       *prometheus.CounterVec
-	blockUploadFiles       *prometheus.CounterVec
-	blockUploadValidations atomic.Int64
+	blockUploadBlocks        *prometheus.CounterVec
+	blockUploadBytes         *prometheus.CounterVec
+	blockUploadFiles         *prometheus.CounterVec
+	blockUploadResumedChunks *prometheus.CounterVec
+	blockUploadValidations   atomic.Int64
+
+	// Number of block upload validations in progress, by tenant.
+	tenantBlockUploadValidationsMtx sync.Mutex
+	tenantBlockUploadValidations    map[string]int64
 
 	// Per-tenant meta caches that are passed to MetaFetcher.
-	metaCaches map[string]*block.MetaCache
+	metaCachesMtx sync.Mutex
+	metaCaches    map[string]*block.MetaCache
 }
 
 // NewMultitenantCompactor makes a new MultitenantCompactor.
@@ -366,6 +638,7 @@ func newMultitenantCompactor(
 		blocksGrouperFactory:   blocksGrouperFactory,
 		blocksCompactorFactory: blocksCompactorFactory,
 		metaCaches:             map[string]*block.MetaCache{},
+		compactionHistory:      newCompactionHistory(compactorCfg.CompactionHistorySize),
 
 		compactionRunsStarted: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
 			Name: "cortex_compactor_runs_started_total",
@@ -397,6 +670,11 @@ func newMultitenantCompactor(
 			Name: "cortex_compactor_tenants_skipped",
 			Help: "Number of tenants skipped during the current compaction run. Reset to 0 when compactor is idle.",
 		}),
+		tenantsSkipped: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_tenants_skipped_total",
+			Help: "Total number of times a tenant owned by this compactor has been skipped during a compaction run.",
+		}, []string{"reason"}),
+		bucketOperations: newBucketOperationsMetric(registerer),
 		compactionRunSucceededTenants: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
 			Name: "cortex_compactor_tenants_processing_succeeded",
 			Help: "Number of tenants successfully processed during the current compaction run. Reset to 0 when compactor is idle.",
@@ -418,6 +696,38 @@ func newMultitenantCompactor(
 			Help:        blocksMarkedForDeletionHelp,
 			ConstLabels: prometheus.Labels{"reason": "compaction"},
 		}),
+		userDiscoveryThrottled: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_user_discovery_throttled_total",
+			Help: "Total number of times the users discovery has been throttled by the object storage.",
+		}),
+		tenantInstanceChanges: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_tenant_instance_changes_total",
+			Help: "Total number of tenants successfully compacted by this compactor whose previous successful compaction has been run by a different compactor.",
+		}),
+		jobsRebalanced: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_jobs_rebalanced_total",
+			Help: "Total number of tenants compacted in the same compaction run in which they became owned by this compactor, because another compactor left the ring.",
+		}),
+		jobsDeferredDiskQuota: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_jobs_deferred_disk_quota_total",
+			Help: "Total number of compaction jobs deferred because the local disk usage of the tenant would exceed its disk quota once the blocks of the job are downloaded.",
+		}),
+		tenantCompactionProgress: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_compactor_tenant_compaction_progress_ratio",
+			Help: "Ratio of the compaction jobs finished to the estimated number of compaction jobs of the tenant being compacted, between 0 and 1. The series is removed once the tenant's compaction finishes.",
+		}, []string{"user"}),
+		tenantCompactedBlocksValidations: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_compactor_tenant_compacted_blocks_validations_in_progress",
+			Help: "Number of blocks output by the compaction jobs of the tenant being validated before being uploaded. The series is removed once the tenant's compaction finishes.",
+		}, []string{"user"}),
+		blocksFailedToOpen: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_failed_to_open_total",
+			Help: "Total number of source blocks of the compaction jobs of the tenant which failed to open, because they're corrupted (persistent) or because of a failure which may not happen again (transient).",
+		}, []string{"user", "reason"}),
+		anomalousBlocks: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_anomalous_blocks_total",
+			Help: "Total number of blocks marked for no-compaction during the compaction planning because their min time is too far after the max time of the tenant's newest compacted block.",
+		}, []string{"user"}),
 		blockUploadBlocks: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_block_upload_api_blocks_total",
 			Help: "Total number of blocks successfully uploaded and validated using the block upload API.",
@@ -430,6 +740,10 @@ func newMultitenantCompactor(
 			Name: "cortex_block_upload_api_files_total",
 			Help: "Total number of files from successfully uploaded and validated blocks using block upload API.",
 		}, []string{"user"}),
+		blockUploadResumedChunks: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_block_upload_api_resumed_chunks_total",
+			Help: "Total number of block file chunks uploaded using block upload API at a non-zero offset, resuming a partially uploaded file.",
+		}, []string{"user"}),
 	}
 
 	promauto.With(registerer).NewGaugeFunc(prometheus.GaugeOpts{
@@ -440,6 +754,9 @@ func newMultitenantCompactor(
 	})
 
 	c.bucketCompactorMetrics = NewBucketCompactorMetrics(c.blocksMarkedForDeletion, registerer)
+	c.openBlocksLimiter = newOpenBlocksLimiter(compactorCfg.MaxOpenBlocksGlobal, registerer)
+	c.uploadBytesLimiter = newUploadBytesLimiter(compactorCfg.MaxUploadInflightBytes, registerer)
+	c.jobsMemoryLimiter = newJobsMemoryLimiter(compactorCfg.MaxCompactionMemoryBytes, cfgProvider.CompactorTenantCompactionMemoryBytes, registerer)
 
 	if len(compactorCfg.EnabledTenants) > 0 {
 		level.Info(c.logger).Log("msg", "compactor using enabled users", "enabled", compactorCfg.EnabledTenants)
@@ -529,20 +846,48 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 		}
 	}
 
+	if c.compactorCfg.MaxConcurrentInstancesPerTenant > 0 {
+		leasesKV := c.compactorCfg.tenantLeasesKVMock
+		if leasesKV == nil {
+			leasesKV, err = kv.NewClient(c.compactorCfg.ShardingRing.Common.KVStore, tenantLeasesCodec{}, kv.RegistererWithKVName(prometheus.WrapRegistererWithPrefix("cortex_", c.registerer), "compactor-tenant-leases"), c.logger)
+			if err != nil {
+				c.ringSubservices.StopAsync()
+				return errors.Wrap(err, "failed to initialize the KV store of the tenant leases")
+			}
+		}
+		c.tenantLeaser = newTenantLeaser(leasesKV, c.ringLifecycler.GetInstanceID(), c.compactorCfg.MaxConcurrentInstancesPerTenant, c.logger)
+	}
+
 	allowedTenants := util.NewAllowList(c.compactorCfg.EnabledTenants, c.compactorCfg.DisabledTenants)
 	c.shardingStrategy = newSplitAndMergeShardingStrategy(allowedTenants, c.ring, c.ringLifecycler, c.cfgProvider)
 
+	retentionSource := c.compactorCfg.BlocksRetentionSource
+	if retentionSource == nil && c.compactorCfg.ExternalRetentionEnabled {
+		retentionSource = NewBucketRetentionSource(c.bucketClient, c.cfgProvider)
+	}
+
 	// Create the blocks cleaner (service).
 	c.blocksCleaner = NewBlocksCleaner(BlocksCleanerConfig{
-		DeletionDelay:                 c.compactorCfg.DeletionDelay,
-		CleanupInterval:               util.DurationWithJitter(c.compactorCfg.CleanupInterval, 0.1),
-		CleanupConcurrency:            c.compactorCfg.CleanupConcurrency,
-		TenantCleanupDelay:            c.compactorCfg.TenantCleanupDelay,
-		DeleteBlocksConcurrency:       defaultDeleteBlocksConcurrency,
-		GetDeletionMarkersConcurrency: defaultGetDeletionMarkersConcurrency,
-		UpdateBlocksConcurrency:       c.compactorCfg.UpdateBlocksConcurrency,
-		NoBlocksFileCleanupEnabled:    c.compactorCfg.NoBlocksFileCleanupEnabled,
-		CompactionBlockRanges:         c.compactorCfg.BlockRanges,
+		DeletionDelay:                  c.compactorCfg.DeletionDelay,
+		CleanupInterval:                util.DurationWithJitter(c.compactorCfg.CleanupInterval, c.compactorCfg.CleanupIntervalJitter),
+		CleanupConcurrency:             c.compactorCfg.CleanupConcurrency,
+		TenantCleanupDelay:             c.compactorCfg.TenantCleanupDelay,
+		DeleteBlocksConcurrency:        defaultDeleteBlocksConcurrency,
+		GetDeletionMarkersConcurrency:  defaultGetDeletionMarkersConcurrency,
+		UpdateBlocksConcurrency:        c.compactorCfg.UpdateBlocksConcurrency,
+		NoBlocksFileCleanupEnabled:     c.compactorCfg.NoBlocksFileCleanupEnabled,
+		CompactionBlockRanges:          c.compactorCfg.BlockRanges,
+		RetentionSource:                retentionSource,
+		RetentionSourceCacheTTL:        c.compactorCfg.ExternalRetentionCacheTTL,
+		SupersededBlocksCleanupEnabled: c.compactorCfg.SupersededBlocksCleanupEnabled,
+		FutureBlocksTolerance:          c.compactorCfg.FutureBlocksTolerance,
+		BlockSizeMetricsEnabled:        c.compactorCfg.BlockSizeMetricsEnabled,
+		BlockLevelMetricsEnabled:       c.compactorCfg.BlockLevelMetricsEnabled,
+		UnchangedIndexWriteSkipPeriod:  c.compactorCfg.BucketIndexUnchangedWriteSkipPeriod,
+		CleanupSuppressedFrom:          time.Time(c.compactorCfg.CleanupSuppressedFrom),
+		CleanupSuppressedUntil:         time.Time(c.compactorCfg.CleanupSuppressedUntil),
+		MaxPartialBlocksPerCleanup:     c.compactorCfg.MaxPartialBlocksPerCleanup,
+		BucketOperations:               c.bucketOperations,
 	}, c.bucketClient, c.shardingStrategy.blocksCleanerOwnsUser, c.cfgProvider, c.parentLogger, c.registerer)
 
 	// Start blocks cleaner asynchronously, don't wait until initial cleanup is finished.
@@ -600,7 +945,7 @@ func (c *MultitenantCompactor) running(ctx context.Context) error {
 	// Run an initial compaction before starting the interval.
 	c.compactUsers(ctx)
 
-	ticker := time.NewTicker(util.DurationWithJitter(c.compactorCfg.CompactionInterval, 0.05))
+	ticker := time.NewTicker(util.DurationWithJitter(c.compactorCfg.CompactionInterval, c.compactorCfg.CompactionIntervalJitter))
 	defer ticker.Stop()
 
 	for {
@@ -618,6 +963,7 @@ func (c *MultitenantCompactor) running(ctx context.Context) error {
 func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 	succeeded := false
 	compactionErrorCount := 0
+	report := compactionRunReport{StartedAt: time.Now()}
 
 	c.compactionRunsStarted.Inc()
 
@@ -625,10 +971,20 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 		if succeeded && compactionErrorCount == 0 {
 			c.compactionRunsCompleted.Inc()
 			c.compactionRunsLastSuccess.SetToCurrentTime()
+			report.Status = compactionStatusSucceeded
 		} else if compactionErrorCount == 0 {
 			c.compactionRunsShutdown.Inc()
+			report.Status = compactionStatusInterrupted
 		} else {
 			c.compactionRunsErred.Inc()
+			report.Status = compactionStatusFailed
+		}
+
+		if c.compactorCfg.RunReportDir != "" {
+			report.FinishedAt = time.Now()
+			if err := writeRunReport(c.compactorCfg.RunReportDir, report, c.compactorCfg.RunReportMaxCount); err != nil {
+				level.Warn(c.logger).Log("msg", "failed to write compaction run report", "dir", c.compactorCfg.RunReportDir, "err", err)
+			}
 		}
 
 		// Reset progress metrics once done.
@@ -638,6 +994,12 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 		c.compactionRunFailedTenants.Set(0)
 	}()
 
+	// Snapshot the ring instances, to detect instances leaving the ring during the compaction run.
+	var instancesAtStart map[string]struct{}
+	if c.compactorCfg.RingChangeRebalanceDelay > 0 && c.ring != nil {
+		instancesAtStart = healthyRingInstances(c.ring)
+	}
+
 	level.Info(c.logger).Log("msg", "discovering users from bucket")
 	users, err := c.discoverUsersWithRetries(ctx)
 	if err != nil {
@@ -650,6 +1012,7 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 
 	level.Info(c.logger).Log("msg", "discovered users from bucket", "users", len(users))
 	c.compactionRunDiscoveredTenants.Set(float64(len(users)))
+	report.DiscoveredTenants = len(users)
 
 	// When starting multiple compactor replicas nearly at the same time, running in a cluster with
 	// a large number of tenants, we may end up in a situation where the 1st user is compacted by
@@ -659,60 +1022,159 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 	})
 
 	// Keep track of users owned by this shard, so that we can delete the local files for all other users.
-	ownedUsers := map[string]struct{}{}
-	for _, userID := range users {
-		// Ensure the context has not been canceled (ie. compactor shutdown has been triggered).
-		if ctx.Err() != nil {
-			level.Info(c.logger).Log("msg", "interrupting compaction of user blocks", "err", err)
-			return
-		}
+	var (
+		// Protects the following variables, and the report, which are updated by the users compacted concurrently.
+		runMtx        sync.Mutex
+		ownedUsers    = map[string]struct{}{}
+		notOwnedUsers []string
+	)
 
-		// Ensure the user ID belongs to our shard.
-		if owned, err := c.shardingStrategy.compactorOwnsUser(userID); err != nil {
-			c.compactionRunSkippedTenants.Inc()
-			level.Warn(c.logger).Log("msg", "unable to check if user is owned by this shard", "user", userID, "err", err)
-			continue
-		} else if !owned {
-			c.compactionRunSkippedTenants.Inc()
-			level.Debug(c.logger).Log("msg", "skipping user because it is not owned by this shard", "user", userID)
-			continue
-		}
+	skipUser := func() {
+		runMtx.Lock()
+		defer runMtx.Unlock()
 
+		c.compactionRunSkippedTenants.Inc()
+		report.SkippedTenants++
+	}
+
+	// compactOwnedUser compacts the blocks of a user owned by this shard, and returns false if the compaction run
+	// has been interrupted by a shutdown.
+	compactOwnedUser := func(ctx context.Context, userID string) bool {
+		runMtx.Lock()
 		ownedUsers[userID] = struct{}{}
+		report.OwnedTenants++
+		runMtx.Unlock()
 
 		if markedForDeletion, err := mimir_tsdb.TenantDeletionMarkExists(ctx, c.bucketClient, userID); err != nil {
-			c.compactionRunSkippedTenants.Inc()
+			skipUser()
 			level.Warn(c.logger).Log("msg", "unable to check if user is marked for deletion", "user", userID, "err", err)
-			continue
+			return true
 		} else if markedForDeletion {
-			c.compactionRunSkippedTenants.Inc()
+			skipUser()
 			level.Debug(c.logger).Log("msg", "skipping user because it is marked for deletion", "user", userID)
-			continue
+			return true
+		}
+
+		if windows := c.cfgProvider.CompactorTenantSchedulingWindows(userID); len(windows) > 0 && !windows.Contains(time.Now()) {
+			skipUser()
+			c.tenantsSkipped.WithLabelValues(skipReasonOutsideWindow).Inc()
+			level.Info(c.logger).Log("msg", "skipping user because the current time is outside its compaction scheduling windows", "user", userID, "windows", windows.String())
+			return true
+		}
+
+		if stale, updatedAt := c.bucketIndexStale(ctx, userID); stale {
+			skipUser()
+			c.tenantsSkipped.WithLabelValues(skipReasonIndexStale).Inc()
+			level.Warn(c.logger).Log("msg", "skipping user because its bucket index is stale", "user", userID, "bucket_index_updated_at", updatedAt)
+			return true
 		}
 
 		level.Info(c.logger).Log("msg", "starting compaction of user blocks", "user", userID)
 
-		if err = c.compactUserWithRetries(ctx, userID); err != nil {
+		jobs, err := c.compactUserWithRetries(ctx, userID)
+
+		runMtx.Lock()
+		defer runMtx.Unlock()
+
+		report.CompactedBlocks += jobs.blocks
+		report.CompactedBytes += jobs.bytes
+
+		if err != nil {
 			switch {
+			case errors.Is(err, errTenantLeaseUnavailable):
+				c.compactionRunSkippedTenants.Inc()
+				report.SkippedTenants++
+				c.tenantsSkipped.WithLabelValues(skipReasonFleetConcurrency).Inc()
+				level.Info(c.logger).Log("msg", "skipping user because the max number of compactors concurrently compacting it has been reached", "user", userID)
+				return true
 			case errors.Is(err, context.Canceled):
 				// We don't want to count shutdowns as failed compactions because we will pick up with the rest of the compaction after the restart.
 				level.Info(c.logger).Log("msg", "compaction for user was interrupted by a shutdown", "user", userID)
-				return
+				return false
 			case errors.Is(err, syscall.ENOSPC):
 				c.outOfSpace.Inc()
 				fallthrough
 			default:
 				c.compactionRunFailedTenants.Inc()
+				report.FailedTenants++
 				compactionErrorCount++
 				level.Error(c.logger).Log("msg", "failed to compact user blocks", "user", userID, "err", err)
 			}
-			continue
+			return true
 		}
 
 		c.compactionRunSucceededTenants.Inc()
+		report.SucceededTenants++
 		level.Info(c.logger).Log("msg", "successfully compacted user blocks", "user", userID)
+		return true
+	}
+
+	// Users are compacted concurrently, up to -compactor.tenant-concurrency at a time. The compaction run is interrupted
+	// as soon as the compaction of any user is interrupted by a shutdown.
+	err = concurrency.ForEachJob(ctx, len(users), c.compactorCfg.TenantConcurrency, func(ctx context.Context, idx int) error {
+		userID := users[idx]
+
+		// Ensure the context has not been canceled (ie. compactor shutdown has been triggered).
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Ensure the user ID belongs to our shard.
+		if owned, err := c.shardingStrategy.compactorOwnsUser(userID); err != nil {
+			skipUser()
+			level.Warn(c.logger).Log("msg", "unable to check if user is owned by this shard", "user", userID, "err", err)
+			return nil
+		} else if !owned {
+			skipUser()
+			runMtx.Lock()
+			notOwnedUsers = append(notOwnedUsers, userID)
+			runMtx.Unlock()
+			level.Debug(c.logger).Log("msg", "skipping user because it is not owned by this shard", "user", userID)
+			return nil
+		}
+
+		if !compactOwnedUser(ctx, userID) {
+			return errCompactionRunInterrupted
+		}
+		return nil
+	})
+	if err != nil {
+		level.Info(c.logger).Log("msg", "interrupting compaction of user blocks", "err", err)
+		return
 	}
 
+	// Compact the users owned by instances which left the ring during the compaction run, instead of waiting for the next run.
+	if instancesAtStart != nil && len(notOwnedUsers) > 0 {
+		rebalancedUsers := c.usersOwnedAfterRingChange(ctx, instancesAtStart, notOwnedUsers)
+
+		err = concurrency.ForEachJob(ctx, len(rebalancedUsers), c.compactorCfg.TenantConcurrency, func(ctx context.Context, idx int) error {
+			userID := rebalancedUsers[idx]
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// The user has already been counted as skipped, because it wasn't owned.
+			runMtx.Lock()
+			c.compactionRunSkippedTenants.Dec()
+			report.SkippedTenants--
+			runMtx.Unlock()
+			c.jobsRebalanced.Inc()
+			level.Info(c.logger).Log("msg", "compacting user newly owned by this shard after an instance left the ring", "user", userID)
+
+			if !compactOwnedUser(ctx, userID) {
+				return errCompactionRunInterrupted
+			}
+			return nil
+		})
+		if err != nil {
+			level.Info(c.logger).Log("msg", "interrupting compaction of user blocks", "err", err)
+			return
+		}
+	}
+
+	// Drop the compaction history of tenants not owned anymore.
+	c.compactionHistory.retain(ownedUsers)
+
 	// Delete local files for unowned tenants, if there are any. This cleans up
 	// leftover local files for tenants that belong to different compactors now,
 	// or have been deleted completely.
@@ -721,83 +1183,197 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 			continue
 		}
 
-		dir := c.metaSyncDirForUser(userID)
-		s, err := os.Stat(dir)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				level.Warn(c.logger).Log("msg", "failed to stat local directory with user data", "dir", dir, "err", err)
-			}
+		c.removeLocalUserDir(filepath.Join(c.compactorCfg.DataDir, compactorMetaPrefix+userID))
+	}
+	for userID := range c.listTenantsWithIsolatedDataDirectories() {
+		if _, owned := ownedUsers[userID]; owned {
 			continue
 		}
 
-		if s.IsDir() {
-			err := os.RemoveAll(dir)
-			if err == nil {
-				level.Info(c.logger).Log("msg", "deleted directory for user not owned by this shard", "dir", dir)
-			} else {
-				level.Warn(c.logger).Log("msg", "failed to delete directory for user not owned by this shard", "dir", dir, "err", err)
-			}
-		}
+		c.removeLocalUserDir(c.tenantDataDirForUser(userID))
 	}
 
 	succeeded = true
 }
 
-func (c *MultitenantCompactor) compactUserWithRetries(ctx context.Context, userID string) error {
-	var lastErr error
+// usersOwnedAfterRingChange returns the input users, not owned by this shard when checked earlier in the compaction run,
+// which are owned by this shard now because instances left the ring since the input snapshot of the ring instances
+// was taken. If any left, the ownership is checked again after -compactor.ring-change-rebalance-delay, so that the
+// users aren't rebalanced while the ring is still changing, e.g. during a rollout.
+func (c *MultitenantCompactor) usersOwnedAfterRingChange(ctx context.Context, instancesAtStart map[string]struct{}, notOwnedUsers []string) []string {
+	if !ringInstancesLeft(instancesAtStart, healthyRingInstances(c.ring)) {
+		return nil
+	}
+
+	level.Info(c.logger).Log("msg", "instances left the ring during the compaction run, waiting before checking the ownership of the users again", "delay", c.compactorCfg.RingChangeRebalanceDelay)
+	select {
+	case <-time.After(c.compactorCfg.RingChangeRebalanceDelay):
+	case <-ctx.Done():
+		return nil
+	}
+
+	var owned []string
+	for _, userID := range notOwnedUsers {
+		if ok, err := c.shardingStrategy.compactorOwnsUser(userID); err == nil && ok {
+			owned = append(owned, userID)
+		}
+	}
+	return owned
+}
+
+// healthyRingInstances returns the IDs of the healthy instances of the ring, or nil if they can't be read.
+func healthyRingInstances(r ring.ReadRing) map[string]struct{} {
+	rs, err := r.GetAllHealthy(RingOp)
+	if err != nil {
+		return nil
+	}
+
+	instances := make(map[string]struct{}, len(rs.Instances))
+	for _, instance := range rs.Instances {
+		instances[instance.Id] = struct{}{}
+	}
+	return instances
+}
+
+// ringInstancesLeft returns whether any of the instances before isn't in the instances after.
+func ringInstancesLeft(before, after map[string]struct{}) bool {
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compactUserWithRetries compacts the blocks of the tenant, retrying on failure, and returns the compaction jobs
+// run across all attempts.
+func (c *MultitenantCompactor) compactUserWithRetries(ctx context.Context, userID string) (jobs compactionJobsCount, lastErr error) {
+	var (
+		startedAt = time.Now()
+		attempts  int
+	)
+
+	defer func() {
+		c.compactionHistory.add(userID, newCompactionHistoryEntry(startedAt, attempts, jobs, lastErr))
+	}()
+
+	if c.tenantLeaser != nil {
+		if err := c.tenantLeaser.acquire(ctx, userID); err != nil {
+			if errors.Is(err, errTenantLeaseUnavailable) {
+				return jobs, err
+			}
+			return jobs, errors.Wrap(err, "failed to acquire the lease to compact the tenant")
+		}
+
+		release := c.tenantLeaser.keepAlive(ctx, userID)
+		defer release()
+	}
+
+	maxRetries := c.compactorCfg.CompactionRetries
+	if tenantRetries := c.cfgProvider.CompactorTenantCompactionRetries(userID); tenantRetries > 0 {
+		maxRetries = tenantRetries
+	}
 
 	retries := backoff.New(ctx, backoff.Config{
 		MinBackoff: c.compactorCfg.retryMinBackoff,
 		MaxBackoff: c.compactorCfg.retryMaxBackoff,
-		MaxRetries: c.compactorCfg.CompactionRetries,
+		MaxRetries: maxRetries,
 	})
 
 	for retries.Ongoing() {
-		lastErr = c.compactUser(ctx, userID)
+		var attemptJobs compactionJobsCount
+
+		attempts++
+		attemptJobs, lastErr = c.compactUser(ctx, userID)
+		jobs.succeeded += attemptJobs.succeeded
+		jobs.failed += attemptJobs.failed
+		jobs.blocks += attemptJobs.blocks
+		jobs.bytes += attemptJobs.bytes
+		jobs.uploadedBlocks += attemptJobs.uploadedBlocks
+		jobs.uploadedBytes += attemptJobs.uploadedBytes
 		if lastErr == nil {
-			return nil
+			// Tenants without blocks aren't recorded, to not keep alive the prefix of a tenant being deleted.
+			if c.compactorCfg.TenantCompactionRecordEnabled && attemptJobs.blocksBefore > 0 {
+				c.recordTenantCompaction(ctx, userID)
+			}
+			return jobs, nil
 		}
 
 		retries.Wait()
 	}
 
-	return lastErr
+	return jobs, lastErr
 }
 
-func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) error {
-	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) (jobs compactionJobsCount, err error) {
+	userBucket := newOperationsCountingBucket(bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider), c.bucketOperations, userID)
 	userLogger := util_log.WithUserID(userID, c.logger)
 
+	var compactor *BucketCompactor
+	if c.compactorCfg.TenantCompactionSummaryLogEnabled {
+		startedAt := time.Now()
+		defer func() {
+			blocksBefore := -1
+			if compactor != nil {
+				blocksBefore = compactor.blocksBefore()
+			}
+			logTenantCompactionSummary(userLogger, startedAt, blocksBefore, jobs, err)
+		}()
+	}
+
 	reg := prometheus.NewRegistry()
 	defer c.syncerMetrics.gatherThanosSyncerMetrics(reg, userLogger)
 
+	// The tenant can override the compaction time ranges: in this case, the grouper and the planner
+	// are built with the tenant ones. The overrides have been validated when loaded.
+	cfg := c.compactorCfg
+	planner := c.blocksPlanner
+	if tenantRanges := c.cfgProvider.CompactorTenantBlockRanges(userID); len(tenantRanges) > 0 {
+		cfg.BlockRanges = tenantRanges
+		planner = NewSplitAndMergePlanner(tenantRanges.ToMilliseconds())
+	}
+
 	// Filters out duplicate blocks that can be formed from two or more overlapping
 	// blocks that fully submatch the source blocks of the older blocks.
 	deduplicateBlocksFilter := NewShardAwareDeduplicateFilter()
 
+	// Removes blocks that should not be compacted due to being marked so.
+	noCompactMarkFilter := NewNoCompactionMarkFilter(userBucket)
+
 	// List of filters to apply (order matters).
 	fetcherFilters := []block.MetadataFilter{
-		NewLabelRemoverFilter(compactionIgnoredLabels),
+		NewLabelRemoverFilter(compactionIgnoredLabelsExcept(c.cfgProvider.CompactorRequiredGroupingLabels(userID))),
 		deduplicateBlocksFilter,
-		// removes blocks that should not be compacted due to being marked so.
-		NewNoCompactionMarkFilter(userBucket),
+		noCompactMarkFilter,
 	}
 
 	var metaCache *block.MetaCache
 	metaCacheSize := c.cfgProvider.CompactorInMemoryTenantMetaCacheSize(userID)
+	// When the cache is disabled, a shadow cache may track the hits and misses the cache would have had.
+	shadowMetaCache := false
+	if metaCacheSize == 0 {
+		metaCacheSize = c.cfgProvider.CompactorInMemoryTenantMetaCacheShadowSize(userID)
+		shadowMetaCache = metaCacheSize > 0
+	}
+	c.metaCachesMtx.Lock()
 	if metaCacheSize == 0 {
 		delete(c.metaCaches, userID)
 	} else {
 		metaCache = c.metaCaches[userID]
-		if metaCache == nil || metaCache.MaxSize() != metaCacheSize {
+		if metaCache == nil || metaCache.MaxSize() != metaCacheSize || metaCache.Shadow() != shadowMetaCache {
 			// We use min compaction level equal to configured block ranges.
 			// Blocks created by ingesters start with compaction level 1. When blocks are first compacted (blockRanges[0], possibly split-compaction), compaction level will be 2.
 			// Higher the compaction level, higher chance of finding the same block over and over, and that's where cache helps the most.
 			// Blocks with 64 sources take at least 1 KiB of memory (each source = 16 bytes). Blocks with many sources are more expensive to reparse over and over again.
-			metaCache = block.NewMetaCache(metaCacheSize, len(c.compactorCfg.BlockRanges), 64)
+			if shadowMetaCache {
+				metaCache = block.NewShadowMetaCache(metaCacheSize, len(cfg.BlockRanges), 64)
+			} else {
+				metaCache = block.NewMetaCache(metaCacheSize, len(cfg.BlockRanges), 64)
+			}
 			c.metaCaches[userID] = metaCache
 		}
 	}
+	c.metaCachesMtx.Unlock()
 
 	// Disable maxLookback (set to 0s) when block upload is enabled, block upload enabled implies there will be blocks
 	// beyond the lookback period, we don't want the compactor to skip these
@@ -817,7 +1393,7 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		maxLookback,
 	)
 	if err != nil {
-		return err
+		return compactionJobsCount{}, err
 	}
 
 	syncer, err := newMetaSyncer(
@@ -829,16 +1405,21 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		c.blocksMarkedForDeletion,
 	)
 	if err != nil {
-		return errors.Wrap(err, "failed to create syncer")
+		return compactionJobsCount{}, errors.Wrap(err, "failed to create syncer")
 	}
 
-	compactor, err := NewBucketCompactor(
+	blocksCompactor, err := c.blocksCompactorForUser(userID)
+	if err != nil {
+		return compactionJobsCount{}, err
+	}
+
+	compactor, err = NewBucketCompactor(
 		userLogger,
 		syncer,
-		c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, userLogger, reg),
-		c.blocksPlanner,
-		c.blocksCompactor,
-		path.Join(c.compactorCfg.DataDir, "compact"),
+		c.blocksGrouperFactory(ctx, cfg, c.cfgProvider, userID, userLogger, reg),
+		planner,
+		blocksCompactor,
+		c.compactDirForUser(userID),
 		userBucket,
 		c.compactorCfg.CompactionConcurrency,
 		true, // Skip unhealthy blocks, and mark them for no-compaction.
@@ -847,24 +1428,118 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		c.compactorCfg.CompactionWaitPeriod,
 		c.compactorCfg.BlockSyncConcurrency,
 		c.bucketCompactorMetrics,
-		c.compactorCfg.UploadSparseIndexHeaders,
+		c.cfgProvider.CompactorUploadSparseIndexHeaders(userID),
 		c.compactorCfg.SparseIndexHeadersSamplingRate,
 		c.compactorCfg.SparseIndexHeadersConfig,
 		c.cfgProvider.CompactorMaxPerBlockUploadConcurrency(userID),
+		c.compactorCfg.MaxJobSymbolTableSizeBytes,
+		c.openBlocksLimiter,
+		c.uploadBytesLimiter,
+		c.jobsMemoryLimiter,
 	)
 	if err != nil {
-		return errors.Wrap(err, "failed to create bucket compactor")
+		return compactionJobsCount{}, errors.Wrap(err, "failed to create bucket compactor")
+	}
+
+	// Track the progress of the tenant's compaction while it's running.
+	progress := c.tenantCompactionProgress.WithLabelValues(userID)
+	progress.Set(0)
+	compactor.onJobFinished = func() { progress.Set(compactor.progress()) }
+	defer c.tenantCompactionProgress.DeleteLabelValues(userID)
+
+	compactor.validationConcurrency = c.cfgProvider.CompactorCompactedBlocksValidationConcurrency(userID)
+	compactor.validationsInProgress = c.tenantCompactedBlocksValidations.WithLabelValues(userID)
+	defer c.tenantCompactedBlocksValidations.DeleteLabelValues(userID)
+
+	compactor.blocksFailedToOpen = c.blocksFailedToOpen.MustCurryWith(prometheus.Labels{"user": userID})
+
+	compactor.anomalousBlocksGap = c.compactorCfg.AnomalousBlocksGap
+	compactor.anomalousBlocks = c.anomalousBlocks.WithLabelValues(userID)
+	compactor.noCompactMarkedMetas = noCompactMarkFilter.NoCompactMarkedMetas
+
+	if c.compactorCfg.TenantDataDirIsolationEnabled {
+		compactor.diskQuotaExceeded = func(downloadBytes int64) (bool, int64, int64) {
+			return c.tenantDiskQuotaExceeded(userID, downloadBytes)
+		}
+		compactor.diskQuotaDeferredJobs = c.jobsDeferredDiskQuota
 	}
 
 	if err := compactor.Compact(ctx, c.compactorCfg.MaxCompactionTime); err != nil {
-		return errors.Wrap(err, "compaction")
+		return compactor.jobsCount(), errors.Wrap(err, "compaction")
 	}
 
-	if metaCache != nil {
+	if metaCache != nil && metaCache.Shadow() {
+		items, _, hits, misses := metaCache.Stats()
+		hitRatio := 0.0
+		if hits+misses > 0 {
+			hitRatio = float64(hits) / float64(hits+misses)
+		}
+		level.Info(userLogger).Log("msg", "per-user meta cache shadow stats after compacting user", "items", items, "hits", hits, "misses", misses, "hit_ratio", hitRatio)
+	} else if metaCache != nil {
 		items, size, hits, misses := metaCache.Stats()
 		level.Info(userLogger).Log("msg", "per-user meta cache stats after compacting user", "items", items, "bytes_size", size, "hits", hits, "misses", misses)
 	}
-	return nil
+	return compactor.jobsCount(), nil
+}
+
+// logTenantCompactionSummary logs a single line summarizing an attempt to compact a tenant started at startedAt,
+// and ended with the input error. blocksBefore is the number of blocks found before running any compaction job, or
+// -1 if the attempt failed before finding them, in which case the number of blocks before and after is not logged.
+func logTenantCompactionSummary(logger log.Logger, startedAt time.Time, blocksBefore int, jobs compactionJobsCount, err error) {
+	elapsed := time.Since(startedAt)
+	keyvals := []any{"msg", "tenant compaction summary"}
+	if blocksBefore >= 0 {
+		keyvals = append(keyvals, "blocks_before", blocksBefore, "blocks_after", blocksBefore-jobs.blocks+jobs.uploadedBlocks)
+	}
+	keyvals = append(keyvals,
+		"jobs_succeeded", jobs.succeeded,
+		"jobs_failed", jobs.failed,
+		"bytes_read", jobs.bytes,
+		"bytes_written", jobs.uploadedBytes,
+		"duration", elapsed,
+		"duration_ms", elapsed.Milliseconds(),
+		"outcome", compactionStatus(err),
+	)
+
+	if err != nil {
+		level.Warn(logger).Log(append(keyvals, "err", err)...)
+		return
+	}
+	level.Info(logger).Log(keyvals...)
+}
+
+// blocksCompactorForUser returns the compactor of the blocks of the tenant, writing chunk segment files up to the
+// size configured for the tenant, if any, and logging the overlapping blocks only if enabled for the tenant, if
+// supported by the compactor.
+func (c *MultitenantCompactor) blocksCompactorForUser(userID string) (Compactor, error) {
+	opts := tenantCompactorOptions{
+		maxBlockChunkSegmentSize: c.cfgProvider.CompactorMaxBlockChunkSegmentSize(userID),
+		logOverlappingBlocks:     c.cfgProvider.CompactorLogOverlappingBlocks(userID),
+	}
+	if opts.maxBlockChunkSegmentSize <= 0 && opts.logOverlappingBlocks {
+		return c.blocksCompactor, nil
+	}
+
+	compactor, ok := c.blocksCompactor.(tenantOptionsCompactor)
+	if !ok {
+		level.Warn(c.logger).Log("msg", "the blocks compactor doesn't support custom tenant options, using the default ones", "user", userID, "max_block_chunk_segment_size", opts.maxBlockChunkSegmentSize, "log_overlapping_blocks", opts.logOverlappingBlocks)
+		return c.blocksCompactor, nil
+	}
+
+	blocksCompactor, err := compactor.withTenantOptions(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create blocks compactor")
+	}
+	return blocksCompactor, nil
+}
+
+// blockRangesForUser returns the compaction time ranges of the tenant, falling back to -compactor.block-ranges
+// when the tenant doesn't override them.
+func (c *MultitenantCompactor) blockRangesForUser(userID string) mimir_tsdb.DurationList {
+	if tenantRanges := c.cfgProvider.CompactorTenantBlockRanges(userID); len(tenantRanges) > 0 {
+		return tenantRanges
+	}
+	return c.compactorCfg.BlockRanges
 }
 
 func (c *MultitenantCompactor) discoverUsersWithRetries(ctx context.Context) ([]string, error) {
@@ -876,7 +1551,15 @@ func (c *MultitenantCompactor) discoverUsersWithRetries(ctx context.Context) ([]
 		MaxRetries: c.compactorCfg.CompactionRetries,
 	})
 
-	for retries.Ongoing() {
+	// Throttling errors are retried with a longer backoff, and don't consume the retries
+	// of other errors, so that the object storage rate limiting doesn't fail the whole run.
+	throttledRetries := backoff.New(ctx, backoff.Config{
+		MinBackoff: c.compactorCfg.throttledRetryMinBackoff,
+		MaxBackoff: c.compactorCfg.throttledRetryMaxBackoff,
+		MaxRetries: c.compactorCfg.CompactionRetries,
+	})
+
+	for retries.Ongoing() && throttledRetries.Ongoing() {
 		var users []string
 
 		users, lastErr = c.discoverUsers(ctx)
@@ -884,6 +1567,13 @@ func (c *MultitenantCompactor) discoverUsersWithRetries(ctx context.Context) ([]
 			return users, nil
 		}
 
+		if bucket.IsThrottlingErr(lastErr) {
+			c.userDiscoveryThrottled.Inc()
+			level.Warn(c.logger).Log("msg", "users discovery has been throttled by the object storage, retrying with a longer backoff", "err", lastErr)
+			throttledRetries.Wait()
+			continue
+		}
+
 		retries.Wait()
 	}
 
@@ -899,6 +1589,9 @@ type shardingStrategy interface {
 	compactorOwnsUser(userID string) (bool, error)
 	// blocksCleanerOwnsUser must be concurrency-safe
 	blocksCleanerOwnsUser(userID string) (bool, error)
+	// instanceOwningBlocksCleanerForUser returns the instance running the blocks cleaner for the user, based on ring.
+	// It ignores per-instance allowed tenants.
+	instanceOwningBlocksCleanerForUser(userID string) (ring.InstanceDesc, error)
 	ownJob(job *Job) (bool, error)
 	// instanceOwningJob returns instance owning the job based on ring. It ignores per-instance allowed tenants.
 	instanceOwningJob(job *Job) (ring.InstanceDesc, error)
@@ -935,6 +1628,21 @@ func (s *splitAndMergeShardingStrategy) blocksCleanerOwnsUser(userID string) (bo
 	return instanceOwnsTokenInRing(r, s.ringLifecycler.GetInstanceAddr(), userID)
 }
 
+func (s *splitAndMergeShardingStrategy) instanceOwningBlocksCleanerForUser(userID string) (ring.InstanceDesc, error) {
+	r := s.ring.ShuffleShard(userID, s.configProvider.CompactorTenantShardSize(userID))
+
+	rs, err := instancesForKey(r, userID)
+	if err != nil {
+		return ring.InstanceDesc{}, err
+	}
+
+	if len(rs.Instances) != 1 {
+		return ring.InstanceDesc{}, fmt.Errorf("unexpected number of compactors in the shard (expected 1, got %d)", len(rs.Instances))
+	}
+
+	return rs.Instances[0], nil
+}
+
 // ALL compactors should plan jobs for all users.
 func (s *splitAndMergeShardingStrategy) compactorOwnsUser(userID string) (bool, error) {
 	if !s.allowedTenants.IsAllowed(userID) {
@@ -996,16 +1704,138 @@ func instanceOwnsTokenInRing(r ring.ReadRing, instanceAddr string, key string) (
 	return rs.Instances[0].Addr == instanceAddr, nil
 }
 
-const compactorMetaPrefix = "compactor-meta-"
+const (
+	compactorMetaPrefix = "compactor-meta-"
+
+	// tenantsDataDirName is the sub directory of the data dir containing the per-tenant
+	// working directories, when the tenant data directory isolation is enabled.
+	tenantsDataDirName = "tenants"
+
+	skipReasonFleetConcurrency = "fleet_concurrency"
+	skipReasonIndexStale       = "index_stale"
+	skipReasonOutsideWindow    = "outside_window"
+)
 
 // metaSyncDirForUser returns directory to store cached meta files.
 // The fetcher stores cached metas in the "meta-syncer/" sub directory,
 // but we prefix it with "compactor-meta-" in order to guarantee no clashing with
 // the directory used by the Thanos Syncer, whatever is the user ID.
 func (c *MultitenantCompactor) metaSyncDirForUser(userID string) string {
+	if c.compactorCfg.TenantDataDirIsolationEnabled {
+		return filepath.Join(c.tenantDataDirForUser(userID), "meta")
+	}
 	return filepath.Join(c.compactorCfg.DataDir, compactorMetaPrefix+userID)
 }
 
+// compactDirForUser returns the directory used to download and compact the user's blocks. The directory is
+// dedicated to the user when tenants are compacted concurrently, because the bucket compactor deletes the content of
+// its directory which doesn't belong to its own jobs.
+func (c *MultitenantCompactor) compactDirForUser(userID string) string {
+	if c.compactorCfg.TenantDataDirIsolationEnabled || c.compactorCfg.TenantConcurrency > 1 {
+		return filepath.Join(c.tenantDataDirForUser(userID), "compact")
+	}
+	return filepath.Join(c.compactorCfg.DataDir, "compact")
+}
+
+// tenantDataDirForUser returns the directory containing all the local files of the user,
+// when the tenant data directory isolation is enabled.
+func (c *MultitenantCompactor) tenantDataDirForUser(userID string) string {
+	return filepath.Join(c.compactorCfg.DataDir, tenantsDataDirName, userID)
+}
+
+// tenantDiskQuotaExceeded returns whether the local disk usage of the user would be greater than the user's disk
+// quota once the input bytes are downloaded. The quota is only enforced when the tenant data directory isolation
+// is enabled.
+func (c *MultitenantCompactor) tenantDiskQuotaExceeded(userID string, downloadBytes int64) (exceeded bool, usage, quota int64) {
+	if !c.compactorCfg.TenantDataDirIsolationEnabled {
+		return false, 0, 0
+	}
+
+	quota = c.cfgProvider.CompactorTenantDiskQuotaBytes(userID)
+	if quota <= 0 {
+		return false, 0, quota
+	}
+
+	usage, err := dirSize(c.tenantDataDirForUser(userID))
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to compute local disk usage of user", "user", userID, "err", err)
+		return false, usage, quota
+	}
+
+	return usage+downloadBytes > quota, usage, quota
+}
+
+// bucketIndexStale returns whether the bucket index of the user hasn't been updated for longer than the configured
+// max stale period, and when it was last updated. A missing bucket index isn't considered stale, because the blocks
+// cleaner may not have created it yet.
+func (c *MultitenantCompactor) bucketIndexStale(ctx context.Context, userID string) (stale bool, updatedAt time.Time) {
+	if c.compactorCfg.BucketIndexMaxStalePeriod <= 0 {
+		return false, time.Time{}
+	}
+
+	idx, err := bucketindex.ReadIndex(ctx, c.bucketClient, userID, c.cfgProvider, c.logger)
+	if errors.Is(err, bucketindex.ErrIndexNotFound) {
+		return false, time.Time{}
+	}
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to read bucket index of user to check its staleness", "user", userID, "err", err)
+		return false, time.Time{}
+	}
+
+	updatedAt = idx.GetUpdatedAt()
+	return time.Since(updatedAt) > c.compactorCfg.BucketIndexMaxStalePeriod, updatedAt
+}
+
+// dirSize returns the total size of the regular files in the input directory. A non-existing
+// directory has size 0.
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// removeLocalUserDir deletes the input directory, containing the local files of a user not owned by this shard.
+func (c *MultitenantCompactor) removeLocalUserDir(dir string) {
+	s, err := os.Stat(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(c.logger).Log("msg", "failed to stat local directory with user data", "dir", dir, "err", err)
+		}
+		return
+	}
+
+	if s.IsDir() {
+		err := os.RemoveAll(dir)
+		if err == nil {
+			level.Info(c.logger).Log("msg", "deleted directory for user not owned by this shard", "dir", dir)
+		} else {
+			level.Warn(c.logger).Log("msg", "failed to delete directory for user not owned by this shard", "dir", dir, "err", err)
+		}
+	}
+}
+
 // This function returns tenants with meta sync directories found on local disk. On error, it returns nil map.
 func (c *MultitenantCompactor) listTenantsWithMetaSyncDirectories() map[string]struct{} {
 	result := map[string]struct{}{}
@@ -1029,3 +1859,24 @@ func (c *MultitenantCompactor) listTenantsWithMetaSyncDirectories() map[string]s
 
 	return result
 }
+
+// listTenantsWithIsolatedDataDirectories returns tenants with a dedicated data directory found on local disk.
+// On error, it returns nil map.
+func (c *MultitenantCompactor) listTenantsWithIsolatedDataDirectories() map[string]struct{} {
+	result := map[string]struct{}{}
+
+	files, err := os.ReadDir(filepath.Join(c.compactorCfg.DataDir, tenantsDataDirName))
+	if err != nil {
+		return nil
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		result[f.Name()] = struct{}{}
+	}
+
+	return result
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -23,11 +24,13 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/kv/consul"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/test"
 	"github.com/grafana/regexp"
+	"github.com/minio/minio-go/v7"
 	"github.com/oklog/ulid/v2"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -45,6 +48,8 @@ import (
 	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util"
 	testutil "github.com/grafana/mimir/pkg/util/test"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
@@ -89,8 +94,9 @@ func TestConfig_ShouldSupportCliFlags(t *testing.T) {
 
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
-		setup    func(cfg *Config)
-		expected string
+		setup       func(cfg *Config)
+		setupLimits func(limits *validation.Limits)
+		expected    string
 	}{
 		"should pass with the default config": {
 			setup:    func(*Config) {},
@@ -108,6 +114,20 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expected: errors.Errorf(errInvalidBlockRanges, 30*time.Hour, 24*time.Hour).Error(),
 		},
+		"should pass with divisible tenant block range periods": {
+			setup: func(*Config) {},
+			setupLimits: func(limits *validation.Limits) {
+				limits.CompactorTenantBlockRanges = mimir_tsdb.DurationList{2 * time.Hour, 12 * time.Hour}
+			},
+			expected: "",
+		},
+		"should fail with non divisible tenant block range periods": {
+			setup: func(*Config) {},
+			setupLimits: func(limits *validation.Limits) {
+				limits.CompactorTenantBlockRanges = mimir_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 18 * time.Hour}
+			},
+			expected: errors.Errorf(errInvalidBlockRanges, 18*time.Hour, 12*time.Hour).Error(),
+		},
 		"should fail on unknown compaction jobs order": {
 			setup: func(cfg *Config) {
 				cfg.CompactionJobsOrder = "everything-is-important"
@@ -126,6 +146,94 @@ func TestConfig_Validate(t *testing.T) {
 			setup:    func(cfg *Config) { cfg.SymbolsFlushersConcurrency = 0 },
 			expected: errInvalidSymbolFlushersConcurrency.Error(),
 		},
+		"should pass with zero compaction interval jitter": {
+			setup:    func(cfg *Config) { cfg.CompactionIntervalJitter = 0 },
+			expected: "",
+		},
+		"should fail on negative compaction interval jitter": {
+			setup:    func(cfg *Config) { cfg.CompactionIntervalJitter = -0.1 },
+			expected: errInvalidCompactionIntervalJitter.Error(),
+		},
+		"should fail on compaction interval jitter equal to 1": {
+			setup:    func(cfg *Config) { cfg.CompactionIntervalJitter = 1 },
+			expected: errInvalidCompactionIntervalJitter.Error(),
+		},
+		"should fail on cleanup interval jitter greater than 1": {
+			setup:    func(cfg *Config) { cfg.CleanupIntervalJitter = 1.5 },
+			expected: errInvalidCleanupIntervalJitter.Error(),
+		},
+		"should fail on negative max concurrent instances per tenant": {
+			setup:    func(cfg *Config) { cfg.MaxConcurrentInstancesPerTenant = -1 },
+			expected: errInvalidMaxConcurrentInstancesPerTenant.Error(),
+		},
+		"should fail on negative compaction history size": {
+			setup:    func(cfg *Config) { cfg.CompactionHistorySize = -1 },
+			expected: errInvalidCompactionHistorySize.Error(),
+		},
+		"should fail on negative max partial blocks per cleanup": {
+			setup:    func(cfg *Config) { cfg.MaxPartialBlocksPerCleanup = -1 },
+			expected: errInvalidMaxPartialBlocksPerCleanup.Error(),
+		},
+		"should fail on non-positive tenant concurrency": {
+			setup:    func(cfg *Config) { cfg.TenantConcurrency = 0 },
+			expected: errInvalidTenantConcurrency.Error(),
+		},
+		"should fail on negative bucket index max stale period": {
+			setup:    func(cfg *Config) { cfg.BucketIndexMaxStalePeriod = -time.Minute },
+			expected: errInvalidBucketIndexMaxStalePeriod.Error(),
+		},
+		"should fail on bucket index max stale period not greater than cleanup interval": {
+			setup: func(cfg *Config) {
+				cfg.CleanupInterval = time.Hour
+				cfg.BucketIndexMaxStalePeriod = time.Hour
+			},
+			expected: errInvalidBucketIndexMaxStalePeriod.Error(),
+		},
+		"should fail on negative bucket index unchanged write skip period": {
+			setup:    func(cfg *Config) { cfg.BucketIndexUnchangedWriteSkipPeriod = -time.Minute },
+			expected: errInvalidBucketIndexUnchangedWriteSkipPeriod.Error(),
+		},
+		"should fail on bucket index unchanged write skip period letting the bucket index become stale": {
+			setup: func(cfg *Config) {
+				cfg.CleanupInterval = 15 * time.Minute
+				cfg.BucketIndexMaxStalePeriod = time.Hour
+				cfg.BucketIndexUnchangedWriteSkipPeriod = 45 * time.Minute
+			},
+			expected: errInvalidBucketIndexUnchangedWriteSkipPeriod.Error(),
+		},
+		"should fail on cleanup suppression window without end": {
+			setup: func(cfg *Config) {
+				cfg.CleanupSuppressedFrom = flagext.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			},
+			expected: errInvalidCleanupSuppressionWindow.Error(),
+		},
+		"should fail on cleanup suppression window ending before its start": {
+			setup: func(cfg *Config) {
+				cfg.CleanupSuppressedFrom = flagext.Time(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+				cfg.CleanupSuppressedUntil = flagext.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			},
+			expected: errInvalidCleanupSuppressionWindow.Error(),
+		},
+		"should pass on cleanup suppression window without start": {
+			setup: func(cfg *Config) {
+				cfg.CleanupSuppressedUntil = flagext.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			},
+			expected: "",
+		},
+		"should fail on max concurrent instances per tenant with memberlist KV store": {
+			setup: func(cfg *Config) {
+				cfg.MaxConcurrentInstancesPerTenant = 1
+				cfg.ShardingRing.Common.KVStore.Store = "memberlist"
+			},
+			expected: errMaxConcurrentInstancesPerTenantMemberlist.Error(),
+		},
+		"should pass on max concurrent instances per tenant with consul KV store": {
+			setup: func(cfg *Config) {
+				cfg.MaxConcurrentInstancesPerTenant = 1
+				cfg.ShardingRing.Common.KVStore.Store = "consul"
+			},
+			expected: "",
+		},
 	}
 
 	for testName, testData := range tests {
@@ -135,7 +243,13 @@ func TestConfig_Validate(t *testing.T) {
 			flagext.DefaultValues(cfg)
 			testData.setup(cfg)
 
-			if actualErr := cfg.Validate(logger); testData.expected != "" {
+			limits := validation.Limits{}
+			flagext.DefaultValues(&limits)
+			if testData.setupLimits != nil {
+				testData.setupLimits(&limits)
+			}
+
+			if actualErr := cfg.Validate(limits, logger); testData.expected != "" {
 				assert.EqualError(t, actualErr, testData.expected)
 			} else {
 				assert.NoError(t, actualErr)
@@ -244,6 +358,7 @@ func TestMultitenantCompactor_ShouldDoNothingOnNoUserBlocks(t *testing.T) {
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 
 		# TYPE cortex_compactor_block_cleanup_started_total counter
 		# HELP cortex_compactor_block_cleanup_started_total Total number of blocks cleanup runs started.
@@ -385,6 +500,7 @@ func TestMultitenantCompactor_ShouldRetryCompactionOnFailureWhileDiscoveringUser
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 
 		# TYPE cortex_compactor_block_cleanup_started_total counter
 		# HELP cortex_compactor_block_cleanup_started_total Total number of blocks cleanup runs started.
@@ -420,6 +536,73 @@ func TestMultitenantCompactor_ShouldRetryCompactionOnFailureWhileDiscoveringUser
 	))
 }
 
+func TestMultitenantCompactor_ShouldRetryOnThrottlingWhileDiscoveringUsersFromBucket(t *testing.T) {
+	t.Parallel()
+
+	throttlingErr := minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}
+
+	tests := map[string]struct {
+		errs                  []error
+		expectedUsers         []string
+		expectedErr           error
+		expectedIterCalls     int
+		expectedThrottleCount int
+	}{
+		"should not consume the generic retries on throttling": {
+			errs:                  []error{throttlingErr, errors.New("failed to iterate the bucket"), throttlingErr, errors.New("failed to iterate the bucket")},
+			expectedUsers:         []string{"user-1"},
+			expectedIterCalls:     5,
+			expectedThrottleCount: 2,
+		},
+		"should give up once the throttling retries are exhausted": {
+			errs:                  []error{throttlingErr, throttlingErr, throttlingErr},
+			expectedErr:           throttlingErr,
+			expectedIterCalls:     3,
+			expectedThrottleCount: 3,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			inmem := objstore.NewInMemBucket()
+			require.NoError(t, inmem.Upload(context.Background(), path.Join("user-1", "01FS51A7GQ1RQWV35DBVYQM4KF", block.MetaFilename), strings.NewReader("{}")))
+
+			bkt := &errorInjectedBucketClient{Bucket: inmem, errs: testData.errs}
+
+			c, _, _, _, _ := prepare(t, prepareConfig(t), bkt)
+			c.bucketClient = bkt
+
+			users, err := c.discoverUsersWithRetries(context.Background())
+			if testData.expectedErr != nil {
+				require.ErrorIs(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, testData.expectedUsers, users)
+			assert.Equal(t, testData.expectedIterCalls, bkt.calls)
+			assert.Equal(t, float64(testData.expectedThrottleCount), prom_testutil.ToFloat64(c.userDiscoveryThrottled))
+		})
+	}
+}
+
+// errorInjectedBucketClient returns the configured errors, in order, from the first Iter() calls.
+type errorInjectedBucketClient struct {
+	objstore.Bucket
+
+	errs  []error
+	calls int
+}
+
+func (b *errorInjectedBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	b.calls++
+	if b.calls <= len(b.errs) {
+		return b.errs[b.calls-1]
+	}
+	return b.Bucket.Iter(ctx, dir, f, options...)
+}
+
 func TestMultitenantCompactor_ShouldIncrementCompactionErrorIfFailedToCompactASingleTenant(t *testing.T) {
 	t.Parallel()
 
@@ -650,6 +833,7 @@ func TestMultitenantCompactor_ShouldIterateOverUsersAndRunCompaction(t *testing.
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 
 		# TYPE cortex_compactor_block_cleanup_started_total counter
 		# HELP cortex_compactor_block_cleanup_started_total Total number of blocks cleanup runs started.
@@ -836,6 +1020,7 @@ func TestMultitenantCompactor_ShouldNotCompactBlocksMarkedForDeletion(t *testing
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 
 		# TYPE cortex_compactor_block_cleanup_started_total counter
 		# HELP cortex_compactor_block_cleanup_started_total Total number of blocks cleanup runs started.
@@ -996,6 +1181,7 @@ func TestMultitenantCompactor_ShouldNotCompactBlocksForUsersMarkedForDeletion(t
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 
 		# TYPE cortex_compactor_block_cleanup_started_total counter
 		# HELP cortex_compactor_block_cleanup_started_total Total number of blocks cleanup runs started.
@@ -1128,6 +1314,7 @@ func TestMultitenantCompactor_ShouldCompactAllUsersOnShardingEnabledButOnlyOneIn
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 	`),
 		"cortex_compactor_runs_started_total",
 		"cortex_compactor_runs_completed_total",
@@ -1222,6 +1409,131 @@ func TestMultitenantCompactor_ShouldCompactOnlyUsersOwnedByTheInstanceOnSharding
 	}
 }
 
+func TestMultitenantCompactor_ShouldCompactUsersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	numUsers := 10
+
+	userIDs := make([]string, 0, numUsers)
+	for i := 1; i <= numUsers; i++ {
+		userIDs = append(userIDs, fmt.Sprintf("user-%d", i))
+	}
+
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range userIDs {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	cfg := prepareConfig(t)
+	cfg.TenantConcurrency = 4
+
+	c, _, tsdbPlanner, logs, registry := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	test.Poll(t, 10*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	for _, userID := range userIDs {
+		assert.Contains(t, logs.String(), fmt.Sprintf(`level=info component=compactor msg="successfully compacted user blocks" user=%s`, userID))
+	}
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(registry, strings.NewReader(`
+		# HELP cortex_compactor_runs_failed_total Total number of compaction runs failed.
+		# TYPE cortex_compactor_runs_failed_total counter
+		cortex_compactor_runs_failed_total{reason="error"} 0
+		cortex_compactor_runs_failed_total{reason="shutdown"} 0
+	`), "cortex_compactor_runs_failed_total"))
+}
+
+func TestMultitenantCompactor_ShouldPickUpUsersOwnedAfterAnInstanceLeftTheRing(t *testing.T) {
+	t.Parallel()
+
+	numUsers := 20
+
+	userIDs := make([]string, 0, numUsers)
+	for i := 1; i <= numUsers; i++ {
+		userIDs = append(userIDs, fmt.Sprintf("user-%d", i))
+	}
+
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range userIDs {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	// Create a shared KV Store
+	kvstore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	// Create two compactors
+	var compactors []*MultitenantCompactor
+
+	for i := 1; i <= 2; i++ {
+		cfg := prepareConfig(t)
+		cfg.CompactionInterval = 10 * time.Minute // We will only call compaction manually.
+		cfg.RingChangeRebalanceDelay = 100 * time.Millisecond
+
+		cfg.ShardingRing.Common.InstanceID = fmt.Sprintf("compactor-%d", i)
+		cfg.ShardingRing.Common.InstanceAddr = fmt.Sprintf("127.0.0.%d", i)
+		cfg.ShardingRing.Common.KVStore.Mock = kvstore
+
+		var limits validation.Limits
+		flagext.DefaultValues(&limits)
+		limits.CompactorTenantShardSize = 1 // Each tenant will belong to single compactor only.
+		overrides := validation.NewOverrides(limits, nil)
+
+		c, _, tsdbPlanner, _, _ := prepareWithConfigProvider(t, cfg, inmem, overrides)
+		compactors = append(compactors, c)
+
+		tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+	}
+
+	c1, c2 := compactors[0], compactors[1]
+	for _, c := range compactors {
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	}
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c1))
+	})
+
+	// Wait until both compactors see each other in the ring.
+	test.Poll(t, 10*time.Second, 2, func() interface{} {
+		return len(healthyRingInstances(c1.ring))
+	})
+	instancesAtStart := healthyRingInstances(c1.ring)
+
+	var notOwnedUsers []string
+	for _, userID := range userIDs {
+		owned, err := c1.shardingStrategy.compactorOwnsUser(userID)
+		require.NoError(t, err)
+		if !owned {
+			notOwnedUsers = append(notOwnedUsers, userID)
+		}
+	}
+	require.NotEmpty(t, notOwnedUsers)
+
+	// No instance has left the ring, so there are no users to pick up.
+	assert.Empty(t, c1.usersOwnedAfterRingChange(context.Background(), instancesAtStart, notOwnedUsers))
+
+	// Stop the second compactor, which leaves the ring.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c2))
+	test.Poll(t, 10*time.Second, false, func() interface{} {
+		return c1.ring.HasInstance("compactor-2")
+	})
+
+	// The users owned by the second compactor are now owned by the first one.
+	assert.ElementsMatch(t, notOwnedUsers, c1.usersOwnedAfterRingChange(context.Background(), instancesAtStart, notOwnedUsers))
+}
+
 func TestMultitenantCompactor_ShouldFailWithInvalidTSDBCompactOutput(t *testing.T) {
 	const user = "user-1"
 
@@ -1435,6 +1747,7 @@ func TestMultitenantCompactor_ShouldSkipCompactionForJobsNoMoreOwnedAfterPlannin
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 	`),
 		"cortex_compactor_runs_started_total",
 		"cortex_compactor_runs_completed_total",
@@ -1545,6 +1858,7 @@ func TestMultitenantCompactor_ShouldSkipCompactionForJobsWithFirstLevelCompactio
 		cortex_compactor_blocks_marked_for_deletion_total{reason="compaction"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
 		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
 	`),
 		"cortex_compactor_runs_started_total",
 		"cortex_compactor_runs_completed_total",
@@ -1735,6 +2049,8 @@ func prepareConfig(t *testing.T) Config {
 
 	compactorCfg.retryMinBackoff = 0
 	compactorCfg.retryMaxBackoff = 0
+	compactorCfg.throttledRetryMinBackoff = 0
+	compactorCfg.throttledRetryMaxBackoff = 0
 
 	// Use settings that ensure things will be done concurrently, verifying ordering assumptions.
 	// Helps to expose bugs such as https://github.com/prometheus/prometheus/pull/10108
@@ -2010,6 +2326,265 @@ func TestMultitenantCompactor_DeleteLocalSyncFiles(t *testing.T) {
 	require.Equal(t, numUsers, c1Users+c2Users)
 }
 
+func TestMultitenantCompactor_TenantDataDirIsolation(t *testing.T) {
+	t.Parallel()
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.tenantDiskQuotaBytes["user-1"] = 10
+	cfgProvider.tenantDiskQuotaBytes["user-2"] = 100
+
+	for _, isolationEnabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("isolation enabled: %t", isolationEnabled), func(t *testing.T) {
+			cfg := prepareConfig(t)
+			cfg.TenantDataDirIsolationEnabled = isolationEnabled
+
+			c, _, _, _, _ := prepareWithConfigProvider(t, cfg, &bucket.ClientMock{}, cfgProvider)
+
+			for _, userID := range []string{"user-1", "user-2", "user-3"} {
+				require.NoError(t, os.MkdirAll(c.metaSyncDirForUser(userID), os.ModePerm))
+				require.NoError(t, os.MkdirAll(c.compactDirForUser(userID), os.ModePerm))
+				require.NoError(t, os.WriteFile(filepath.Join(c.compactDirForUser(userID), "file"), make([]byte, 20), 0o600))
+			}
+
+			if isolationEnabled {
+				assert.Equal(t, filepath.Join(c.compactorCfg.DataDir, "tenants", "user-1", "meta"), c.metaSyncDirForUser("user-1"))
+				assert.Equal(t, filepath.Join(c.compactorCfg.DataDir, "tenants", "user-1", "compact"), c.compactDirForUser("user-1"))
+				assert.Len(t, c.listTenantsWithIsolatedDataDirectories(), 3)
+				assert.Empty(t, c.listTenantsWithMetaSyncDirectories())
+			} else {
+				assert.Equal(t, filepath.Join(c.compactorCfg.DataDir, "compactor-meta-user-1"), c.metaSyncDirForUser("user-1"))
+				assert.Equal(t, filepath.Join(c.compactorCfg.DataDir, "compact"), c.compactDirForUser("user-1"))
+				assert.Empty(t, c.listTenantsWithIsolatedDataDirectories())
+				assert.Len(t, c.listTenantsWithMetaSyncDirectories(), 3)
+			}
+
+			// The quota is only enforced when the isolation is enabled, given the
+			// disk usage can't be attributed to a tenant otherwise.
+			exceeded, usage, quota := c.tenantDiskQuotaExceeded("user-1", 0)
+			assert.Equal(t, isolationEnabled, exceeded)
+			if isolationEnabled {
+				assert.Equal(t, int64(20), usage)
+				assert.Equal(t, int64(10), quota)
+			}
+
+			// The bytes about to be downloaded are added to the current disk usage.
+			exceeded, _, _ = c.tenantDiskQuotaExceeded("user-2", 80)
+			assert.False(t, exceeded)
+			exceeded, _, _ = c.tenantDiskQuotaExceeded("user-2", 81)
+			assert.Equal(t, isolationEnabled, exceeded)
+
+			// No quota configured for the tenant.
+			exceeded, _, _ = c.tenantDiskQuotaExceeded("user-3", 1<<30)
+			assert.False(t, exceeded)
+		})
+	}
+}
+
+func TestMultitenantCompactor_ShouldSkipTenantsOnMaxConcurrentInstancesPerTenantReached(t *testing.T) {
+	t.Parallel()
+
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range []string{"user-1", "user-2"} {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	leasesKV, closer := consul.NewInMemoryClient(tenantLeasesCodec{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	// Another compactor is compacting user-1.
+	require.NoError(t, newTenantLeaser(leasesKV, "other-compactor", 1, log.NewNopLogger()).acquire(context.Background(), "user-1"))
+
+	cfg := prepareConfig(t)
+	cfg.MaxConcurrentInstancesPerTenant = 1
+	cfg.tenantLeasesKVMock = leasesKV
+
+	c, _, tsdbPlanner, logs, registry := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until a run has completed.
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	// Only user-2 has been compacted.
+	assert.Contains(t, logs.String(), `msg="skipping user because the max number of compactors concurrently compacting it has been reached" user=user-1`)
+	assert.Contains(t, logs.String(), `msg="successfully compacted user blocks" user=user-2`)
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(registry, strings.NewReader(`
+		# HELP cortex_compactor_tenants_skipped_total Total number of times a tenant owned by this compactor has been skipped during a compaction run.
+		# TYPE cortex_compactor_tenants_skipped_total counter
+		cortex_compactor_tenants_skipped_total{reason="fleet_concurrency"} 1
+	`), "cortex_compactor_tenants_skipped_total"))
+
+	// The lease acquired to compact user-2 has been released.
+	val, err := kv.PrefixClient(leasesKV, tenantLeasesKeyPrefix).Get(context.Background(), "user-2")
+	require.NoError(t, err)
+	assert.Empty(t, val.(*tenantLeases).Holders)
+}
+
+func TestMultitenantCompactor_ShouldSkipTenantsOutsideSchedulingWindows(t *testing.T) {
+	t.Parallel()
+
+	inmem := objstore.NewInMemBucket()
+	for _, userID := range []string{"user-1", "user-2", "user-3"} {
+		id, err := ulid.New(ulid.Now(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, inmem.Upload(context.Background(), userID+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+	}
+
+	now := time.Now().UTC()
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	cfgProvider := newMockConfigProvider()
+	// user-1 can only be compacted a few hours from now, user-2 now, and user-3 at any time.
+	cfgProvider.tenantSchedulingWindows["user-1"] = util.TimeWindows{{Start: (sinceMidnight + 2*time.Hour) % (24 * time.Hour), End: (sinceMidnight + 3*time.Hour) % (24 * time.Hour)}}
+	cfgProvider.tenantSchedulingWindows["user-2"] = util.TimeWindows{{Start: (sinceMidnight + 23*time.Hour) % (24 * time.Hour), End: (sinceMidnight + time.Hour) % (24 * time.Hour)}}
+
+	c, _, tsdbPlanner, logs, registry := prepareWithConfigProvider(t, prepareConfig(t), inmem, cfgProvider)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until a run has completed.
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	assert.Contains(t, logs.String(), `msg="skipping user because the current time is outside its compaction scheduling windows" user=user-1`)
+	assert.Contains(t, logs.String(), `msg="successfully compacted user blocks" user=user-2`)
+	assert.Contains(t, logs.String(), `msg="successfully compacted user blocks" user=user-3`)
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(registry, strings.NewReader(`
+		# HELP cortex_compactor_tenants_skipped_total Total number of times a tenant owned by this compactor has been skipped during a compaction run.
+		# TYPE cortex_compactor_tenants_skipped_total counter
+		cortex_compactor_tenants_skipped_total{reason="outside_window"} 1
+	`), "cortex_compactor_tenants_skipped_total"))
+}
+
+func TestMultitenantCompactor_BucketIndexStale(t *testing.T) {
+	bucketClient := objstore.NewInMemBucket()
+
+	now := time.Now()
+	require.NoError(t, bucketindex.WriteIndex(context.Background(), bucketClient, "user-1", nil, &bucketindex.Index{Version: bucketindex.IndexVersion1, UpdatedAt: now.Add(-3 * time.Hour).Unix()}))
+	require.NoError(t, bucketindex.WriteIndex(context.Background(), bucketClient, "user-2", nil, &bucketindex.Index{Version: bucketindex.IndexVersion1, UpdatedAt: now.Add(-time.Hour).Unix()}))
+
+	tests := map[string]struct {
+		maxStalePeriod time.Duration
+		userID         string
+		expectedStale  bool
+	}{
+		"should not consider the bucket index stale if the check is disabled": {
+			maxStalePeriod: 0,
+			userID:         "user-1",
+			expectedStale:  false,
+		},
+		"should consider the bucket index stale if not updated within the max stale period": {
+			maxStalePeriod: 2 * time.Hour,
+			userID:         "user-1",
+			expectedStale:  true,
+		},
+		"should not consider the bucket index stale if updated within the max stale period": {
+			maxStalePeriod: 2 * time.Hour,
+			userID:         "user-2",
+			expectedStale:  false,
+		},
+		"should not consider a missing bucket index stale": {
+			maxStalePeriod: 2 * time.Hour,
+			userID:         "user-3",
+			expectedStale:  false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := prepareConfig(t)
+			cfg.BucketIndexMaxStalePeriod = testData.maxStalePeriod
+
+			c, _, _, _, _ := prepare(t, cfg, bucketClient)
+			c.bucketClient = bucketClient
+
+			stale, _ := c.bucketIndexStale(context.Background(), testData.userID)
+			assert.Equal(t, testData.expectedStale, stale)
+		})
+	}
+}
+
+func TestMultitenantCompactor_ShouldApplyPerTenantCompactionRetries(t *testing.T) {
+	// Listing the bucket always fails, so that every compaction attempt fails.
+	bucketClient := &bucket.ErrorInjectedBucketClient{
+		Bucket: objstore.NewInMemBucket(),
+		Injector: func(op bucket.Operation, _ string) error {
+			if op == bucket.OpIter {
+				return errors.New("injected error")
+			}
+			return nil
+		},
+	}
+
+	cfg := prepareConfig(t)
+	cfg.CompactionRetries = 3
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.tenantCompactionRetries["user-1"] = 1
+	cfgProvider.tenantCompactionRetries["user-2"] = 5
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, cfg, bucketClient, cfgProvider)
+	c.bucketClient = bucketClient
+	c.shardingStrategy = newSplitAndMergeShardingStrategy(nil, nil, nil, cfgProvider)
+
+	for userID, expectedAttempts := range map[string]int{"user-1": 1, "user-2": 5, "user-3": 3} {
+		_, err := c.compactUserWithRetries(context.Background(), userID)
+		require.Error(t, err)
+
+		history := c.compactionHistory.get(userID)
+		require.Len(t, history, 1)
+		assert.Equal(t, expectedAttempts, history[0].attempts, userID)
+	}
+}
+
+func TestMultitenantCompactor_ShouldApplyPerTenantBlockRanges(t *testing.T) {
+	bucketClient := objstore.NewInMemBucket()
+
+	cfg := prepareConfig(t)
+	cfg.BlockRanges = mimir_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.tenantBlockRanges["user-1"] = mimir_tsdb.DurationList{1 * time.Hour, 4 * time.Hour}
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, cfg, bucketClient, cfgProvider)
+	c.bucketClient = bucketClient
+	c.shardingStrategy = newSplitAndMergeShardingStrategy(nil, nil, nil, cfgProvider)
+
+	groupersRanges := map[string]mimir_tsdb.DurationList{}
+	c.blocksGrouperFactory = func(ctx context.Context, cfg Config, cfgProvider ConfigProvider, userID string, logger log.Logger, reg prometheus.Registerer) Grouper {
+		groupersRanges[userID] = cfg.BlockRanges
+		return splitAndMergeGrouperFactory(ctx, cfg, cfgProvider, userID, logger, reg)
+	}
+
+	_, err := c.compactUser(context.Background(), "user-1")
+	require.NoError(t, err)
+	_, err = c.compactUser(context.Background(), "user-3")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]mimir_tsdb.DurationList{
+		"user-1": {1 * time.Hour, 4 * time.Hour},
+		"user-3": cfg.BlockRanges,
+	}, groupersRanges)
+
+	assert.Equal(t, mimir_tsdb.DurationList{1 * time.Hour, 4 * time.Hour}, c.blockRangesForUser("user-1"))
+	assert.Equal(t, cfg.BlockRanges, c.blockRangesForUser("user-3"))
+}
+
 func TestMultitenantCompactor_ShouldFailCompactionOnTimeout(t *testing.T) {
 	t.Parallel()
 
@@ -2351,6 +2926,10 @@ func TestMultitenantCompactor_CriticalIssue(t *testing.T) {
 	`),
 		"cortex_compactor_blocks_marked_for_no_compaction_total",
 	))
+
+	// The unhealthy block is reported as failed to open, at least once because both blocks are unhealthy.
+	assert.GreaterOrEqual(t, prom_testutil.ToFloat64(c.blocksFailedToOpen.WithLabelValues(user, blockOpenFailurePersistent)), 1.0)
+	assert.Equal(t, 0.0, prom_testutil.ToFloat64(c.blocksFailedToOpen.WithLabelValues(user, blockOpenFailureTransient)))
 }
 
 type bucketWithMockedAttributes struct {
@@ -2373,3 +2952,72 @@ func must[T any](v T, err error) T {
 	}
 	return v
 }
+
+func TestMultitenantCompactor_ShouldLogTenantCompactionSummary(t *testing.T) {
+	const user = "user-1"
+
+	inmem := objstore.NewInMemBucket()
+	id, err := ulid.New(ulid.Now(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, inmem.Upload(context.Background(), user+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+
+	cfg := prepareConfig(t)
+	cfg.TenantCompactionSummaryLogEnabled = true
+
+	c, _, tsdbPlanner, logs, _ := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	summaries := summaryLogLines(logs.String())
+	require.NotEmpty(t, summaries)
+	assert.Contains(t, summaries[0], "user=user-1")
+	assert.Contains(t, summaries[0], "blocks_before=1 blocks_after=1")
+	assert.Contains(t, summaries[0], "bytes_read=0 bytes_written=0")
+	assert.Contains(t, summaries[0], "outcome=succeeded")
+}
+
+func TestLogTenantCompactionSummary(t *testing.T) {
+	t.Run("successful compaction", func(t *testing.T) {
+		logs := &concurrency.SyncBuffer{}
+		jobs := compactionJobsCount{succeeded: 3, failed: 1, blocks: 4, bytes: 400, uploadedBlocks: 2, uploadedBytes: 300}
+
+		logTenantCompactionSummary(log.NewLogfmtLogger(logs), time.Now(), 10, jobs, nil)
+
+		summaries := summaryLogLines(logs.String())
+		require.Len(t, summaries, 1)
+		assert.Contains(t, summaries[0], "level=info")
+		assert.Contains(t, summaries[0], "blocks_before=10 blocks_after=8 jobs_succeeded=3 jobs_failed=1 bytes_read=400 bytes_written=300")
+		assert.Contains(t, summaries[0], "outcome=succeeded")
+		assert.NotContains(t, summaries[0], "err=")
+	})
+
+	t.Run("compaction failed before finding the blocks", func(t *testing.T) {
+		logs := &concurrency.SyncBuffer{}
+
+		logTenantCompactionSummary(log.NewLogfmtLogger(logs), time.Now(), -1, compactionJobsCount{}, errors.New("sync failed"))
+
+		summaries := summaryLogLines(logs.String())
+		require.Len(t, summaries, 1)
+		assert.Contains(t, summaries[0], "level=warn")
+		assert.NotContains(t, summaries[0], "blocks_before")
+		assert.Contains(t, summaries[0], `outcome=failed err="sync failed"`)
+	})
+}
+
+func summaryLogLines(logs string) []string {
+	var lines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.Contains(line, `msg="tenant compaction summary"`) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/objstore/providers/filesystem"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/indexheader"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+const (
+	// replayBucketDir is the directory, within the replay output directory, of the local bucket
+	// holding the copy of the source blocks and the compacted blocks.
+	replayBucketDir = "bucket"
+
+	// replayCompactDir is the directory, within the replay output directory, used as compaction work directory.
+	replayCompactDir = "compact"
+)
+
+// ReplayJobConfig holds the configuration of a compaction job replayed by ReplayCompactionJob.
+type ReplayJobConfig struct {
+	// UserID is the tenant the source blocks belong to.
+	UserID string
+
+	// BlockIDs are the IDs of the source blocks to compact together.
+	BlockIDs []ulid.ULID
+
+	// SplitShards is the number of shards the compacted block is split into. Source blocks are merged
+	// without splitting if 0.
+	SplitShards uint32
+
+	// OutputDir is the local directory where the source blocks are downloaded and the compacted blocks are written.
+	OutputDir string
+
+	// BlockSyncConcurrency is the number of blocks downloaded concurrently.
+	BlockSyncConcurrency int
+}
+
+// replayPlanner is a Planner which plans all the blocks of the job, bypassing the compaction planning.
+type replayPlanner struct{}
+
+func (replayPlanner) Plan(_ context.Context, metasByMinTime []*block.Meta) ([]*block.Meta, error) {
+	return metasByMinTime, nil
+}
+
+// ReplayCompactionJob runs a single compaction job of the given blocks of a tenant in isolation, to reproduce a
+// compaction failure. The source blocks are copied from the bucket to a local bucket in the output directory, and
+// the job is run against the local bucket with the same machinery used by BucketCompactor, but without planning,
+// retries, or any change to the source bucket. The compacted blocks are written to the local bucket, and their IDs
+// are returned.
+func ReplayCompactionJob(ctx context.Context, cfg ReplayJobConfig, bkt objstore.Bucket, comp Compactor, logger log.Logger) ([]ulid.ULID, error) {
+	if cfg.UserID == "" {
+		return nil, errors.New("no tenant specified")
+	}
+	if len(cfg.BlockIDs) == 0 {
+		return nil, errors.New("no blocks specified")
+	}
+	if cfg.BlockSyncConcurrency <= 0 {
+		cfg.BlockSyncConcurrency = 1
+	}
+
+	logger = log.With(logger, "user", cfg.UserID)
+	userBucket := bucket.NewUserBucketClient(cfg.UserID, bkt, nil)
+
+	bucketDir := filepath.Join(cfg.OutputDir, replayBucketDir)
+	if err := os.MkdirAll(bucketDir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create replay bucket dir")
+	}
+	localBucket, err := filesystem.NewBucket(bucketDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "create replay bucket")
+	}
+
+	// Copy the source blocks to the local bucket, so that the compaction job never changes the source bucket.
+	// The copies are kept once the job has run, so that they can be inspected if the job fails.
+	metas := make([]*block.Meta, len(cfg.BlockIDs))
+	err = concurrency.ForEachJob(ctx, len(cfg.BlockIDs), cfg.BlockSyncConcurrency, func(ctx context.Context, idx int) error {
+		blockID := cfg.BlockIDs[idx]
+		if err := block.Download(ctx, logger, userBucket, blockID, filepath.Join(bucketDir, blockID.String())); err != nil {
+			return errors.Wrapf(err, "download block %s", blockID)
+		}
+
+		meta, err := block.ReadMetaFromDir(filepath.Join(bucketDir, blockID.String()))
+		if err != nil {
+			return errors.Wrapf(err, "read meta of block %s", blockID)
+		}
+		metas[idx] = meta
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, meta := range metas {
+		level.Info(logger).Log("msg", "source block", "block", meta.ULID, "minTime", meta.MinTime, "maxTime", meta.MaxTime,
+			"level", meta.Compaction.Level, "series", meta.Stats.NumSeries, "samples", meta.Stats.NumSamples, "chunks", meta.Stats.NumChunks,
+			"resolution", meta.Thanos.Downsample.Resolution, "labels", labels.FromMap(meta.Thanos.Labels), "sources", len(meta.Compaction.Sources))
+	}
+
+	lbls := labels.FromMap(metas[0].Thanos.Labels)
+	resolution := metas[0].Thanos.Downsample.Resolution
+	job := newJob(cfg.UserID, defaultGroupKey(resolution, lbls), lbls, resolution, cfg.SplitShards > 0, cfg.SplitShards, "")
+	for _, meta := range metas {
+		if err := job.AppendMeta(meta); err != nil {
+			return nil, errors.Wrapf(err, "add block %s to the compaction job", meta.ULID)
+		}
+	}
+
+	compactor, err := NewBucketCompactor(
+		logger,
+		nil, // The syncer is only used to plan the jobs.
+		nil, // The grouper is only used to plan the jobs.
+		replayPlanner{},
+		comp,
+		filepath.Join(cfg.OutputDir, replayCompactDir),
+		localBucket,
+		1,
+		false,
+		ownAllJobs,
+		nil,
+		0,
+		cfg.BlockSyncConcurrency,
+		NewBucketCompactorMetrics(prometheus.NewCounter(prometheus.CounterOpts{}), nil),
+		false,
+		0,
+		indexheader.Config{},
+		1,
+		0,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create bucket compactor")
+	}
+
+	_, compIDs, err := compactor.runCompactionJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	return compIDs, nil
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/common/promslog"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestReplayCompactionJob(t *testing.T) {
+	const user = "user-1"
+
+	ctx := context.Background()
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	first := createTSDBBlock(t, bkt, user, 10, 20, 2, nil)
+	second := createTSDBBlock(t, bkt, user, 20, 30, 2, nil)
+	other := createTSDBBlock(t, bkt, user, 30, 40, 2, nil)
+
+	comp, err := tsdb.NewLeveledCompactor(ctx, nil, promslog.NewNopLogger(), []int64{100}, nil, nil)
+	require.NoError(t, err)
+
+	t.Run("merge", func(t *testing.T) {
+		outputDir := t.TempDir()
+		compIDs, err := ReplayCompactionJob(ctx, ReplayJobConfig{UserID: user, BlockIDs: []ulid.ULID{first, second}, OutputDir: outputDir}, bkt, comp, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Len(t, compIDs, 1)
+
+		meta, err := block.ReadMetaFromDir(filepath.Join(outputDir, replayBucketDir, compIDs[0].String()))
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), meta.MinTime)
+		assert.Equal(t, int64(30), meta.MaxTime)
+		assert.ElementsMatch(t, []ulid.ULID{first, second}, meta.Compaction.Sources)
+
+		// The source bucket is left untouched.
+		for _, id := range []ulid.ULID{first, second, other} {
+			exists, err := bkt.Exists(ctx, path.Join(user, id.String(), block.DeletionMarkFilename))
+			require.NoError(t, err)
+			assert.False(t, exists)
+		}
+		exists, err := bkt.Exists(ctx, path.Join(user, compIDs[0].String(), block.MetaFilename))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		outputDir := t.TempDir()
+		compIDs, err := ReplayCompactionJob(ctx, ReplayJobConfig{UserID: user, BlockIDs: []ulid.ULID{first, second}, SplitShards: 2, OutputDir: outputDir}, bkt, comp, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Len(t, compIDs, 2)
+	})
+
+	t.Run("block not found", func(t *testing.T) {
+		_, err := ReplayCompactionJob(ctx, ReplayJobConfig{UserID: user, BlockIDs: []ulid.ULID{first, ulid.MustNew(1, nil)}, OutputDir: t.TempDir()}, bkt, comp, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("no blocks", func(t *testing.T) {
+		_, err := ReplayCompactionJob(ctx, ReplayJobConfig{UserID: user, OutputDir: t.TempDir()}, bkt, comp, log.NewNopLogger())
+		require.EqualError(t, err, "no blocks specified")
+	})
+}
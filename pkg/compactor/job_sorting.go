@@ -11,9 +11,11 @@ import (
 const (
 	CompactionOrderOldestFirst = "smallest-range-oldest-blocks-first"
 	CompactionOrderNewestFirst = "newest-blocks-first"
+	CompactionOrderSplitFirst  = "split-first"
+	CompactionOrderMergeFirst  = "merge-first"
 )
 
-var CompactionOrders = []string{CompactionOrderOldestFirst, CompactionOrderNewestFirst}
+var CompactionOrders = []string{CompactionOrderOldestFirst, CompactionOrderNewestFirst, CompactionOrderSplitFirst, CompactionOrderMergeFirst}
 
 type JobsOrderFunc func(jobs []*Job) []*Job
 
@@ -24,6 +26,10 @@ func GetJobsOrderFunction(name string) JobsOrderFunc {
 		return sortJobsByNewestBlocksFirst
 	case CompactionOrderOldestFirst:
 		return sortJobsBySmallestRangeOldestBlocksFirst
+	case CompactionOrderSplitFirst:
+		return sortJobsByStage(true, sortJobsByNewestBlocksFirst)
+	case CompactionOrderMergeFirst:
+		return sortJobsByStage(false, sortJobsByNewestBlocksFirst)
 	default:
 		return nil
 	}
@@ -93,3 +99,25 @@ func sortJobsByNewestBlocksFirst(jobs []*Job) []*Job {
 
 	return jobs
 }
+
+// sortJobsByStage returns a JobsOrderFunc sorting the input jobs with the time ordering function, and then
+// grouping all split jobs ahead of merge jobs (or vice versa, if splitFirst is false), preserving the time
+// ordering within each group. Running split jobs first unblocks the merge jobs of the same time range, while
+// running merge jobs first favours reducing the number of blocks of the time ranges already split.
+func sortJobsByStage(splitFirst bool, timeOrder JobsOrderFunc) JobsOrderFunc {
+	return func(jobs []*Job) []*Job {
+		jobs = timeOrder(jobs)
+
+		slices.SortStableFunc(jobs, func(a, b *Job) int {
+			if a.UseSplitting() == b.UseSplitting() {
+				return 0
+			}
+			if a.UseSplitting() == splitFirst {
+				return -1
+			}
+			return 1
+		})
+
+		return jobs
+	}
+}
@@ -132,6 +132,62 @@ func TestSortJobsByNewestBlocksFirst(t *testing.T) {
 	}
 }
 
+func TestSortJobsByStage(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+	block5 := ulid.MustNew(5, nil)
+	block6 := ulid.MustNew(6, nil)
+
+	input := func() []*Job {
+		return []*Job{
+			{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block1, 10, 20)}, useSplitting: true},
+			{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block2, 10, 20), mockMetaWithMinMax(block3, 20, 30)}},
+			{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block4, 40, 60)}, useSplitting: true},
+			{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block5, 40, 60), mockMetaWithMinMax(block6, 60, 80)}},
+		}
+	}
+
+	tests := map[string]struct {
+		order    string
+		input    []*Job
+		expected []*Job
+	}{
+		"should do nothing on empty input": {
+			order:    CompactionOrderSplitFirst,
+			input:    nil,
+			expected: nil,
+		},
+		"should sort split jobs first, and then by newest blocks first": {
+			order: CompactionOrderSplitFirst,
+			input: input(),
+			expected: []*Job{
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block4, 40, 60)}, useSplitting: true},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block1, 10, 20)}, useSplitting: true},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block5, 40, 60), mockMetaWithMinMax(block6, 60, 80)}},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block2, 10, 20), mockMetaWithMinMax(block3, 20, 30)}},
+			},
+		},
+		"should sort merge jobs first, and then by newest blocks first": {
+			order: CompactionOrderMergeFirst,
+			input: input(),
+			expected: []*Job{
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block5, 40, 60), mockMetaWithMinMax(block6, 60, 80)}},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block2, 10, 20), mockMetaWithMinMax(block3, 20, 30)}},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block4, 40, 60)}, useSplitting: true},
+				{metasByMinTime: []*block.Meta{mockMetaWithMinMax(block1, 10, 20)}, useSplitting: true},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, GetJobsOrderFunction(testData.order)(testData.input))
+		})
+	}
+}
+
 func mockMetaWithMinMax(id ulid.ULID, minTime, maxTime int64) *block.Meta {
 	return &block.Meta{
 		BlockMeta: tsdb.BlockMeta{
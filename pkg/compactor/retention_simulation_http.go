@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+type retentionSimulationResponse struct {
+	Tenant             string `json:"tenant"`
+	At                 string `json:"at"`
+	BucketIndexUpdated string `json:"bucket_index_updated"`
+	Retention          string `json:"retention"`
+	RetentionEnabled   bool   `json:"retention_enabled"`
+
+	// The blocks currently in the bucket index remaining, and deleted because either beyond the retention period
+	// or already marked for deletion, at the simulated time. The sizes don't include the blocks of unknown size.
+	RemainingBlocks    int   `json:"remaining_blocks"`
+	RemainingSizeBytes int64 `json:"remaining_size_bytes"`
+	DeletedBlocks      int   `json:"deleted_blocks"`
+	DeletedSizeBytes   int64 `json:"deleted_size_bytes"`
+}
+
+// RetentionSimulationHandler returns, as JSON, how many blocks of a tenant currently in the bucket index would
+// remain and be deleted at a future time, given by the "at" parameter, if the current retention period of the
+// tenant was applied at that time. Blocks uploaded in the meantime aren't taken into account.
+func (c *MultitenantCompactor) RetentionSimulationHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		http.Error(w, "tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	atMillis, err := util.ParseTime(req.FormValue("at"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid at parameter: %v", err), http.StatusBadRequest)
+		return
+	}
+	at := util.TimeFromMillis(atMillis)
+	if at.Before(now) {
+		http.Error(w, "the at parameter must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	idx, err := bucketindex.ReadIndex(req.Context(), c.bucketClient, tenantID, nil, c.logger)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to read bucket index for tenant while simulating retention", "user", tenantID, "err", err)
+		http.Error(w, "failed to read bucket index for tenant", http.StatusInternalServerError)
+		return
+	}
+
+	retention := c.cfgProvider.CompactorBlocksRetentionPeriod(tenantID)
+	if c.blocksCleaner != nil {
+		retention = c.blocksCleaner.retentionPeriod(req.Context(), tenantID, util_log.WithUserID(tenantID, c.logger))
+	}
+
+	resp := retentionSimulationResponse{
+		Tenant:             tenantID,
+		At:                 formatTime(at),
+		BucketIndexUpdated: formatTime(idx.GetUpdatedAt()),
+		Retention:          retention.String(),
+		// The retention period of zero is a special value indicating to never delete.
+		RetentionEnabled: retention > 0,
+	}
+
+	// Blocks already marked for deletion are deleted regardless of the retention.
+	deleted := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, d := range idx.BlockDeletionMarks {
+		deleted[d.ID] = struct{}{}
+	}
+	if resp.RetentionEnabled {
+		for _, b := range listBlocksOutsideRetentionPeriod(idx, at.Add(-retention)) {
+			deleted[b.ID] = struct{}{}
+		}
+	}
+
+	for _, b := range idx.Blocks {
+		if _, ok := deleted[b.ID]; ok {
+			resp.DeletedBlocks++
+			resp.DeletedSizeBytes += b.SizeBytes
+			continue
+		}
+		resp.RemainingBlocks++
+		resp.RemainingSizeBytes += b.SizeBytes
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
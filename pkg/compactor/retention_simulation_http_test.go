@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestRetentionSimulationHandler(t *testing.T) {
+	const user = "testuser"
+
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods[user] = 24 * time.Hour
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, prepareConfig(t), bucketClient, cfgProvider)
+	c.bucketClient = bucketClient
+
+	now := time.Now()
+	index := bucketindex.Index{
+		Blocks: bucketindex.Blocks{
+			&bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: now.Add(-74 * time.Hour).UnixMilli(), MaxTime: now.Add(-72 * time.Hour).UnixMilli(), SizeBytes: 1},
+			&bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: now.Add(-50 * time.Hour).UnixMilli(), MaxTime: now.Add(-48 * time.Hour).UnixMilli(), SizeBytes: 10},
+			&bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: now.Add(-14 * time.Hour).UnixMilli(), MaxTime: now.Add(-12 * time.Hour).UnixMilli(), SizeBytes: 100},
+			&bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: now.Add(-2 * time.Hour).UnixMilli(), MaxTime: now.UnixMilli(), SizeBytes: 1000},
+		},
+		BlockDeletionMarks: bucketindex.BlockDeletionMarks{
+			&bucketindex.BlockDeletionMark{ID: ulid.MustNew(1, nil), DeletionTime: now.Unix()},
+		},
+	}
+	require.NoError(t, bucketindex.WriteIndex(context.Background(), bucketClient, user, nil, &index))
+
+	simulate := func(at string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/compactor/tenant/"+user+"/retention_simulation?at="+at, nil)
+		c.RetentionSimulationHandler(resp, mux.SetURLVars(req, map[string]string{"tenant": user}))
+		return resp
+	}
+
+	for name, tc := range map[string]struct {
+		at                 time.Time
+		retention          time.Duration
+		expectedRemaining  int
+		expectedRemainingB int64
+		expectedDeleted    int
+		expectedDeletedB   int64
+	}{
+		"one hour from now": {
+			at:                 now.Add(time.Hour),
+			retention:          24 * time.Hour,
+			expectedRemaining:  2,
+			expectedRemainingB: 1100,
+			expectedDeleted:    2,
+			expectedDeletedB:   11,
+		},
+		"one day from now": {
+			at:                 now.Add(24 * time.Hour),
+			retention:          24 * time.Hour,
+			expectedRemaining:  1,
+			expectedRemainingB: 1000,
+			expectedDeleted:    3,
+			expectedDeletedB:   111,
+		},
+		"retention disabled": {
+			at:                 now.Add(24 * time.Hour),
+			expectedRemaining:  3,
+			expectedRemainingB: 1110,
+			expectedDeleted:    1,
+			expectedDeletedB:   1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfgProvider.userRetentionPeriods[user] = tc.retention
+
+			resp := simulate(strconv.FormatInt(tc.at.Unix(), 10))
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content retentionSimulationResponse
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &content))
+			assert.Equal(t, user, content.Tenant)
+			assert.Equal(t, formatTime(tc.at), content.At)
+			assert.Equal(t, tc.retention > 0, content.RetentionEnabled)
+			assert.Equal(t, tc.expectedRemaining, content.RemainingBlocks)
+			assert.Equal(t, tc.expectedRemainingB, content.RemainingSizeBytes)
+			assert.Equal(t, tc.expectedDeleted, content.DeletedBlocks)
+			assert.Equal(t, tc.expectedDeletedB, content.DeletedSizeBytes)
+		})
+	}
+
+	t.Run("missing time", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, simulate("").Code)
+	})
+
+	t.Run("time in the past", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, simulate(now.Add(-time.Hour).Format(time.RFC3339)).Code)
+	})
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+// TenantRetentionPath is the path of the object holding the blocks retention period of a tenant,
+// relative to the tenant prefix in the bucket.
+const TenantRetentionPath = "retention.json"
+
+// RetentionSource provides the blocks retention period of tenants from a source external to the ConfigProvider,
+// so that retention changes take effect without a configuration reload.
+type RetentionSource interface {
+	// BlocksRetentionPeriod returns the blocks retention period of the tenant. The returned bool is false
+	// if the source has no retention period configured for the tenant.
+	BlocksRetentionPeriod(ctx context.Context, userID string) (time.Duration, bool, error)
+}
+
+// TenantRetention is the content of the TenantRetentionPath object.
+type TenantRetention struct {
+	// RetentionPeriod is the blocks retention period of the tenant. 0 to disable retention.
+	RetentionPeriod model.Duration `json:"retention_period"`
+}
+
+// BucketRetentionSource is a RetentionSource reading the retention period of each tenant from
+// the TenantRetentionPath object in the tenant prefix of the bucket.
+type BucketRetentionSource struct {
+	bkt         objstore.Bucket
+	cfgProvider bucket.TenantConfigProvider
+}
+
+func NewBucketRetentionSource(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider) *BucketRetentionSource {
+	return &BucketRetentionSource{
+		bkt:         bkt,
+		cfgProvider: cfgProvider,
+	}
+}
+
+func (s *BucketRetentionSource) BlocksRetentionPeriod(ctx context.Context, userID string) (time.Duration, bool, error) {
+	userBucket := bucket.NewUserBucketClient(userID, s.bkt, s.cfgProvider)
+
+	r, err := userBucket.Get(ctx, TenantRetentionPath)
+	if err != nil {
+		if userBucket.IsObjNotFoundErr(err) {
+			return 0, false, nil
+		}
+		return 0, false, errors.Wrapf(err, "failed to read tenant retention object: %s", TenantRetentionPath)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "failed to read tenant retention object: %s", TenantRetentionPath)
+	}
+
+	retention := TenantRetention{}
+	if err := json.Unmarshal(data, &retention); err != nil {
+		return 0, false, errors.Wrapf(err, "failed to decode tenant retention object: %s", TenantRetentionPath)
+	}
+	if retention.RetentionPeriod < 0 {
+		return 0, false, errors.Errorf("invalid negative retention period in tenant retention object: %s", TenantRetentionPath)
+	}
+
+	return time.Duration(retention.RetentionPeriod), true, nil
+}
+
+// cachingRetentionResolver resolves the blocks retention period of tenants, preferring the one provided by the
+// RetentionSource, cached for a short time, over the one provided by the ConfigProvider.
+type cachingRetentionResolver struct {
+	source      RetentionSource
+	cfgProvider ConfigProvider
+	ttl         time.Duration
+
+	mtx     sync.Mutex
+	entries map[string]cachedRetention
+}
+
+type cachedRetention struct {
+	retention time.Duration
+	found     bool
+	expiresAt time.Time
+}
+
+func newCachingRetentionResolver(source RetentionSource, cfgProvider ConfigProvider, ttl time.Duration) *cachingRetentionResolver {
+	return &cachingRetentionResolver{
+		source:      source,
+		cfgProvider: cfgProvider,
+		ttl:         ttl,
+		entries:     map[string]cachedRetention{},
+	}
+}
+
+// retentionPeriod returns the blocks retention period of the tenant. It falls back to the ConfigProvider if the
+// RetentionSource has no retention period for the tenant, or it can't be reached.
+func (r *cachingRetentionResolver) retentionPeriod(ctx context.Context, userID string, logger log.Logger) time.Duration {
+	now := time.Now()
+
+	r.mtx.Lock()
+	entry, ok := r.entries[userID]
+	r.mtx.Unlock()
+
+	if !ok || !now.Before(entry.expiresAt) {
+		retention, found, err := r.source.BlocksRetentionPeriod(ctx, userID)
+		if err != nil {
+			// Errors are not cached, so that the source is queried again on the next cleanup.
+			level.Warn(logger).Log("msg", "failed to read the tenant retention period from the external source, falling back to the configured one", "err", err)
+			return r.cfgProvider.CompactorBlocksRetentionPeriod(userID)
+		}
+
+		entry = cachedRetention{retention: retention, found: found, expiresAt: now.Add(r.ttl)}
+
+		r.mtx.Lock()
+		r.entries[userID] = entry
+		r.mtx.Unlock()
+	}
+
+	if !entry.found {
+		return r.cfgProvider.CompactorBlocksRetentionPeriod(userID)
+	}
+	return entry.retention
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestBucketRetentionSource(t *testing.T) {
+	ctx := context.Background()
+
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-1", TenantRetentionPath), strings.NewReader(`{"retention_period": "7d"}`)))
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-2", TenantRetentionPath), strings.NewReader(`{"retention_period": "0s"}`)))
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-3", TenantRetentionPath), strings.NewReader(`{"retention_period": 1`)))
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-4", TenantRetentionPath), strings.NewReader(`{"retention_period": "-1d"}`)))
+
+	source := NewBucketRetentionSource(bkt, nil)
+
+	tests := map[string]struct {
+		userID            string
+		expectedRetention time.Duration
+		expectedFound     bool
+		expectedErr       bool
+	}{
+		"should read the retention period of the tenant": {
+			userID:            "user-1",
+			expectedRetention: 7 * 24 * time.Hour,
+			expectedFound:     true,
+		},
+		"should read a disabled retention period": {
+			userID:            "user-2",
+			expectedRetention: 0,
+			expectedFound:     true,
+		},
+		"should fail on malformed object": {
+			userID:      "user-3",
+			expectedErr: true,
+		},
+		"should fail on negative retention period": {
+			userID:      "user-4",
+			expectedErr: true,
+		},
+		"should return not found if the tenant has no retention object": {
+			userID:        "user-5",
+			expectedFound: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			retention, found, err := source.BlocksRetentionPeriod(ctx, testData.userID)
+			if testData.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedFound, found)
+			assert.Equal(t, testData.expectedRetention, retention)
+		})
+	}
+}
+
+func TestCachingRetentionResolver(t *testing.T) {
+	ctx := context.Background()
+	logger := test.NewTestingLogger(t)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods["user-1"] = time.Hour
+	cfgProvider.userRetentionPeriods["user-2"] = 2 * time.Hour
+
+	source := &mockRetentionSource{retentions: map[string]time.Duration{"user-1": 24 * time.Hour}}
+
+	t.Run("should prefer the retention period of the source and cache it", func(t *testing.T) {
+		resolver := newCachingRetentionResolver(source, cfgProvider, time.Hour)
+		source.calls = 0
+
+		assert.Equal(t, 24*time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+		assert.Equal(t, 24*time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+		assert.Equal(t, 1, source.calls)
+	})
+
+	t.Run("should fall back to the config provider if the source has no retention period for the tenant", func(t *testing.T) {
+		resolver := newCachingRetentionResolver(source, cfgProvider, time.Hour)
+		source.calls = 0
+
+		assert.Equal(t, 2*time.Hour, resolver.retentionPeriod(ctx, "user-2", logger))
+		assert.Equal(t, 2*time.Hour, resolver.retentionPeriod(ctx, "user-2", logger))
+		assert.Equal(t, 1, source.calls)
+	})
+
+	t.Run("should refresh the retention period once the cached one expires", func(t *testing.T) {
+		resolver := newCachingRetentionResolver(source, cfgProvider, 0)
+		source.calls = 0
+
+		assert.Equal(t, 24*time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+		assert.Equal(t, 24*time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+		assert.Equal(t, 2, source.calls)
+	})
+
+	t.Run("should fall back to the config provider if the source can't be reached", func(t *testing.T) {
+		failing := &mockRetentionSource{err: errors.New("unreachable")}
+		resolver := newCachingRetentionResolver(failing, cfgProvider, time.Hour)
+
+		assert.Equal(t, time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+
+		// Errors are not cached.
+		assert.Equal(t, time.Hour, resolver.retentionPeriod(ctx, "user-1", logger))
+		assert.Equal(t, 2, failing.calls)
+	})
+}
+
+func TestBlocksCleaner_ShouldApplyRetentionPeriodFromRetentionSource(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	now := time.Now()
+	block1 := createTSDBBlock(t, bucketClient, "user-1", tsOffset(now, -10), tsOffset(now, -8), 2, nil)
+	block2 := createTSDBBlock(t, bucketClient, "user-1", tsOffset(now, -8), tsOffset(now, -6), 2, nil)
+
+	ctx := context.Background()
+	logger := test.NewTestingLogger(t)
+	reg := prometheus.NewPedanticRegistry()
+	cfgProvider := newMockConfigProvider()
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+		RetentionSource:         NewBucketRetentionSource(bucketClient, cfgProvider),
+		RetentionSourceCacheTTL: 0,
+	}
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, cfgProvider, logger, reg)
+
+	assertBlockMarked := func(blockID string, expectMarked bool) {
+		exists, err := bucketClient.Exists(ctx, path.Join("user-1", blockID, block.DeletionMarkFilename))
+		require.NoError(t, err)
+		assert.Equal(t, expectMarked, exists)
+	}
+
+	// The retention period is disabled in the config provider, and there's no retention object.
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assertBlockMarked(block1.String(), false)
+	assertBlockMarked(block2.String(), false)
+
+	// The retention object takes effect on the next cleanup.
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-1", TenantRetentionPath), strings.NewReader(`{"retention_period": "7h"}`)))
+
+	require.NoError(t, cleaner.runCleanupWithErr(ctx))
+	assertBlockMarked(block1.String(), true)
+	assertBlockMarked(block2.String(), false)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_blocks_marked_for_deletion_total Total number of blocks marked for deletion in compactor.
+		# TYPE cortex_compactor_blocks_marked_for_deletion_total counter
+		cortex_compactor_blocks_marked_for_deletion_total{reason="partial"} 0
+		cortex_compactor_blocks_marked_for_deletion_total{reason="retention"} 1
+		cortex_compactor_blocks_marked_for_deletion_total{reason="series_cap"} 0
+	`), "cortex_compactor_blocks_marked_for_deletion_total"))
+}
+
+type mockRetentionSource struct {
+	retentions map[string]time.Duration
+	err        error
+	calls      int
+}
+
+func (m *mockRetentionSource) BlocksRetentionPeriod(_ context.Context, userID string) (time.Duration, bool, error) {
+	m.calls++
+	if m.err != nil {
+		return 0, false, m.err
+	}
+
+	retention, ok := m.retentions[userID]
+	return retention, ok, nil
+}
@@ -4,6 +4,8 @@ package compactor
 
 import (
 	"context"
+	"log/slog"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -23,22 +25,134 @@ func splitAndMergeGrouperFactory(_ context.Context, cfg Config, cfgProvider Conf
 
 func splitAndMergeCompactorFactory(ctx context.Context, cfg Config, logger log.Logger, reg prometheus.Registerer) (Compactor, Planner, error) {
 	// We don't need to customise the TSDB compactor so we're just using the Prometheus one.
-	compactor, err := tsdb.NewLeveledCompactor(ctx, reg, util_log.SlogFromGoKit(logger), cfg.BlockRanges.ToMilliseconds(), nil, nil)
-	if err != nil {
-		return nil, nil, err
-	}
-
 	opts := tsdb.DefaultLeveledCompactorConcurrencyOptions()
 	opts.MaxOpeningBlocks = cfg.MaxOpeningBlocksConcurrency
 	opts.MaxClosingBlocks = cfg.MaxClosingBlocksConcurrency
 	opts.SymbolsFlushersCount = cfg.SymbolsFlushersConcurrency
 
-	compactor.SetConcurrencyOptions(opts)
+	compactor, err := newLeveledCompactor(ctx, util_log.SlogFromGoKit(logger), cfg.BlockRanges.ToMilliseconds(), opts, tsdb.NewCompactorMetrics(reg))
+	if err != nil {
+		return nil, nil, err
+	}
 
 	planner := NewSplitAndMergePlanner(cfg.BlockRanges.ToMilliseconds())
 	return compactor, planner, nil
 }
 
+// tenantCompactorOptions are the options of the blocks compactor which can be customised per tenant.
+type tenantCompactorOptions struct {
+	// Max size of the chunk segment files of the compacted blocks, or 0 to use the default one.
+	maxBlockChunkSegmentSize int64
+
+	// Whether the overlapping blocks found while compacting are logged.
+	logOverlappingBlocks bool
+}
+
+// defaultTenantCompactorOptions are the options of the tenants which don't customise the blocks compactor.
+var defaultTenantCompactorOptions = tenantCompactorOptions{logOverlappingBlocks: true}
+
+// tenantOptionsCompactor is a Compactor which can compact blocks with custom tenant options.
+type tenantOptionsCompactor interface {
+	// withTenantOptions returns a Compactor compacting blocks with the input options.
+	withTenantOptions(opts tenantCompactorOptions) (Compactor, error)
+}
+
+// leveledCompactor is the TSDB leveled compactor using the default tenant options, which creates the compactors
+// using other tenant options on demand, sharing its concurrency options and metrics.
+type leveledCompactor struct {
+	*tsdb.LeveledCompactor
+
+	ctx             context.Context
+	logger          *slog.Logger
+	ranges          []int64
+	concurrencyOpts tsdb.LeveledCompactorConcurrencyOptions
+	metrics         *tsdb.CompactorMetrics
+
+	mtx       sync.Mutex
+	byOptions map[tenantCompactorOptions]*tsdb.LeveledCompactor
+}
+
+func newLeveledCompactor(ctx context.Context, logger *slog.Logger, ranges []int64, concurrencyOpts tsdb.LeveledCompactorConcurrencyOptions, metrics *tsdb.CompactorMetrics) (*leveledCompactor, error) {
+	c := &leveledCompactor{
+		ctx:             ctx,
+		logger:          logger,
+		ranges:          ranges,
+		concurrencyOpts: concurrencyOpts,
+		metrics:         metrics,
+		byOptions:       map[tenantCompactorOptions]*tsdb.LeveledCompactor{},
+	}
+
+	var err error
+	c.LeveledCompactor, err = c.newTSDBCompactor(defaultTenantCompactorOptions)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *leveledCompactor) newTSDBCompactor(opts tenantCompactorOptions) (*tsdb.LeveledCompactor, error) {
+	logger := c.logger
+	if !opts.logOverlappingBlocks {
+		logger = slog.New(overlappingBlocksLogFilter{Handler: logger.Handler()})
+	}
+
+	compactor, err := tsdb.NewLeveledCompactorWithOptions(c.ctx, nil, logger, c.ranges, nil, tsdb.LeveledCompactorOptions{
+		MaxBlockChunkSegmentSize:    opts.maxBlockChunkSegmentSize,
+		EnableOverlappingCompaction: true,
+		Metrics:                     c.metrics,
+	})
+	if err != nil {
+		return nil, err
+	}
+	compactor.SetConcurrencyOptions(c.concurrencyOpts)
+	return compactor, nil
+}
+
+func (c *leveledCompactor) withTenantOptions(opts tenantCompactorOptions) (Compactor, error) {
+	if opts.maxBlockChunkSegmentSize < 0 {
+		opts.maxBlockChunkSegmentSize = 0
+	}
+	if opts == defaultTenantCompactorOptions {
+		return c, nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if compactor, ok := c.byOptions[opts]; ok {
+		return compactor, nil
+	}
+	compactor, err := c.newTSDBCompactor(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.byOptions[opts] = compactor
+	return compactor, nil
+}
+
+// overlappingBlocksLogMsg is the message logged by the TSDB compactor when the blocks of a compaction overlap.
+const overlappingBlocksLogMsg = "Found overlapping blocks during compaction"
+
+// overlappingBlocksLogFilter is a slog.Handler dropping the logs of the overlapping blocks found by the TSDB compactor.
+type overlappingBlocksLogFilter struct {
+	slog.Handler
+}
+
+func (h overlappingBlocksLogFilter) Handle(ctx context.Context, r slog.Record) error {
+	if r.Message == overlappingBlocksLogMsg {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h overlappingBlocksLogFilter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return overlappingBlocksLogFilter{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h overlappingBlocksLogFilter) WithGroup(name string) slog.Handler {
+	return overlappingBlocksLogFilter{Handler: h.Handler.WithGroup(name)}
+}
+
 // configureSplitAndMergeCompactor updates the provided configuration injecting the split-and-merge compactor.
 func configureSplitAndMergeCompactor(cfg *Config) {
 	cfg.BlocksGrouperFactory = splitAndMergeGrouperFactory
@@ -3,9 +3,13 @@
 package compactor
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -682,6 +686,85 @@ func TestMultitenantCompactor_ShouldSupportSplitAndMergeCompactor(t *testing.T)
 	}
 }
 
+func TestMultitenantCompactor_ShouldCompactBlocksOfMultipleUsersConcurrently(t *testing.T) {
+	const (
+		numUsers   = 4
+		numSeries  = 100
+		blockRange = 2 * time.Hour
+	)
+
+	storageDir := t.TempDir()
+	fetcherDir := t.TempDir()
+
+	storageCfg := mimir_tsdb.BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	storageCfg.Bucket.Backend = bucket.Filesystem
+	storageCfg.Bucket.Filesystem.Directory = storageDir
+
+	compactorCfg := prepareConfig(t)
+	compactorCfg.DataDir = t.TempDir()
+	compactorCfg.BlockRanges = mimir_tsdb.DurationList{blockRange, 2 * blockRange}
+	compactorCfg.TenantConcurrency = numUsers
+
+	logger := log.NewNopLogger()
+	reg := prometheus.NewPedanticRegistry()
+	ctx := context.Background()
+
+	bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "test", logger, nil)
+	require.NoError(t, err)
+
+	// Each user has two blocks which are expected to be merged together.
+	userIDs := make([]string, 0, numUsers)
+	expectedSources := map[string][]ulid.ULID{}
+	for i := 1; i <= numUsers; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		userIDs = append(userIDs, userID)
+
+		block1 := createTSDBBlock(t, bucketClient, userID, blockRange.Milliseconds(), blockRange.Milliseconds()+blockRange.Milliseconds()/2, numSeries, nil)
+		block2 := createTSDBBlock(t, bucketClient, userID, blockRange.Milliseconds()+blockRange.Milliseconds()/2, 2*blockRange.Milliseconds(), numSeries, nil)
+		expectedSources[userID] = []ulid.ULID{block1, block2}
+		slices.SortFunc(expectedSources[userID], func(a, b ulid.ULID) int { return a.Compare(b) })
+	}
+
+	c, err := NewMultitenantCompactor(compactorCfg, storageCfg, newMockConfigProvider(), logger, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, c))
+	})
+
+	// Wait until the first compaction run completed.
+	test.Poll(t, 30*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_compactor_runs_completed_total Total number of compaction runs successfully completed.
+			# TYPE cortex_compactor_runs_completed_total counter
+			cortex_compactor_runs_completed_total 1
+		`), "cortex_compactor_runs_completed_total")
+	})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_runs_failed_total Total number of compaction runs failed.
+		# TYPE cortex_compactor_runs_failed_total counter
+		cortex_compactor_runs_failed_total{reason="error"} 0
+		cortex_compactor_runs_failed_total{reason="shutdown"} 0
+	`), "cortex_compactor_runs_failed_total"))
+
+	for _, userID := range userIDs {
+		userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+		fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, filepath.Join(fetcherDir, userID), nil, nil, nil, 0)
+		require.NoError(t, err)
+		metas, partials, err := fetcher.FetchWithoutMarkedForDeletion(ctx)
+		require.NoError(t, err)
+		require.Empty(t, partials)
+
+		actual := convertMetasMapToSlice(metas)
+		require.Len(t, actual, 1, userID)
+		assert.Equal(t, blockRange.Milliseconds(), actual[0].MinTime, userID)
+		assert.Equal(t, 2*blockRange.Milliseconds(), actual[0].MaxTime, userID)
+		assert.Equal(t, expectedSources[userID], actual[0].Compaction.Sources, userID)
+	}
+}
+
 func TestMultitenantCompactor_ShouldGuaranteeSeriesShardingConsistencyOverTheTime(t *testing.T) {
 	const (
 		userID     = "user-1"
@@ -803,6 +886,59 @@ func TestMultitenantCompactor_ShouldGuaranteeSeriesShardingConsistencyOverTheTim
 	}
 }
 
+func TestMultitenantCompactor_ShouldNotCompactJobsExceedingMaxSymbolTableSize(t *testing.T) {
+	const (
+		userID     = "user-1"
+		blockRange = 2 * time.Hour
+	)
+
+	blockRangeMillis := blockRange.Milliseconds()
+
+	storageCfg := mimir_tsdb.BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	storageCfg.Bucket.Backend = bucket.Filesystem
+	storageCfg.Bucket.Filesystem.Directory = t.TempDir()
+
+	compactorCfg := prepareConfig(t)
+	compactorCfg.DataDir = t.TempDir()
+	compactorCfg.BlockRanges = mimir_tsdb.DurationList{blockRange}
+	compactorCfg.MaxJobSymbolTableSizeBytes = 1
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.splitAndMergeShards[userID] = 2
+
+	logger := log.NewNopLogger()
+	reg := prometheus.NewPedanticRegistry()
+	ctx := context.Background()
+
+	bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "test", logger, nil)
+	require.NoError(t, err)
+
+	// Create a TSDB block in the storage, which would be split if it wasn't for its symbol table size.
+	blockID := createTSDBBlock(t, bucketClient, userID, blockRangeMillis, 2*blockRangeMillis, 10, nil)
+
+	c, err := NewMultitenantCompactor(compactorCfg, storageCfg, cfgProvider, logger, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until the compaction job has been rejected.
+	test.Poll(t, 15*time.Second, true, func() interface{} {
+		return testutil.ToFloat64(c.bucketCompactorMetrics.symbolTableTooLarge) > 0
+	})
+
+	// The source block has not been compacted.
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, t.TempDir(), nil, nil, nil, 0)
+	require.NoError(t, err)
+	metas, _, err := fetcher.FetchWithoutMarkedForDeletion(ctx)
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	require.Contains(t, metas, blockID)
+}
+
 func convertMetasMapToSlice(metas map[ulid.ULID]*block.Meta) []*block.Meta {
 	var out []*block.Meta
 	for _, m := range metas {
@@ -810,3 +946,91 @@ func convertMetasMapToSlice(metas map[ulid.ULID]*block.Meta) []*block.Meta {
 	}
 	return out
 }
+
+func TestMultitenantCompactor_blocksCompactorForUser(t *testing.T) {
+	cfg := prepareConfig(t)
+	blocksCompactor, _, err := splitAndMergeCompactorFactory(context.Background(), cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.maxBlockChunkSegmentSize["user-2"] = 16 * 1024 * 1024
+	cfgProvider.maxBlockChunkSegmentSize["user-3"] = 16 * 1024 * 1024
+	cfgProvider.maxBlockChunkSegmentSize["user-4"] = 32 * 1024 * 1024
+	cfgProvider.logOverlappingBlocks["user-5"] = false
+	cfgProvider.logOverlappingBlocks["user-6"] = false
+	cfgProvider.maxBlockChunkSegmentSize["user-6"] = 16 * 1024 * 1024
+
+	c := &MultitenantCompactor{cfgProvider: cfgProvider, blocksCompactor: blocksCompactor, logger: log.NewNopLogger()}
+
+	// The tenants without a custom max chunk segment size use the default compactor.
+	user1, err := c.blocksCompactorForUser("user-1")
+	require.NoError(t, err)
+	assert.Same(t, blocksCompactor, user1)
+
+	// The tenants with the same max chunk segment size share the same compactor.
+	user2, err := c.blocksCompactorForUser("user-2")
+	require.NoError(t, err)
+	user3, err := c.blocksCompactorForUser("user-3")
+	require.NoError(t, err)
+	user4, err := c.blocksCompactorForUser("user-4")
+	require.NoError(t, err)
+	assert.Same(t, user2, user3)
+	assert.NotSame(t, user2, user4)
+	assert.NotSame(t, blocksCompactor.(*leveledCompactor).LeveledCompactor, user2)
+
+	// The tenants not logging the overlapping blocks use a different compactor.
+	user5, err := c.blocksCompactorForUser("user-5")
+	require.NoError(t, err)
+	user6, err := c.blocksCompactorForUser("user-6")
+	require.NoError(t, err)
+	assert.NotSame(t, blocksCompactor.(*leveledCompactor).LeveledCompactor, user5)
+	assert.NotSame(t, user5, user6)
+	assert.NotSame(t, user2, user6)
+
+	// Compactors not supporting custom tenant options are used for all tenants.
+	c.blocksCompactor = &tsdbCompactorMock{}
+	user2, err = c.blocksCompactorForUser("user-2")
+	require.NoError(t, err)
+	assert.Same(t, c.blocksCompactor, user2)
+}
+
+func TestOverlappingBlocksLogFilter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(overlappingBlocksLogFilter{Handler: slog.NewTextHandler(buf, nil)}).With("component", "tsdb")
+
+	logger.Info(overlappingBlocksLogMsg)
+	logger.Info("compact blocks", "count", 2)
+
+	assert.NotContains(t, buf.String(), overlappingBlocksLogMsg)
+	assert.Contains(t, buf.String(), "compact blocks")
+	assert.Contains(t, buf.String(), "component=tsdb")
+}
+
+func TestLeveledCompactor_OverlappingBlocksLog(t *testing.T) {
+	series := []labels.Labels{labels.FromStrings("series", "1"), labels.FromStrings("series", "2")}
+
+	for _, logOverlappingBlocks := range []bool{true, false} {
+		t.Run(fmt.Sprintf("log overlapping blocks: %t", logOverlappingBlocks), func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			block1, _ := createBlock(ctx, t, dir, blockgenSpec{series: series, numFloatSamples: 10, mint: 0, maxt: 2000})
+			block2, _ := createBlock(ctx, t, dir, blockgenSpec{series: series, numFloatSamples: 10, mint: 1000, maxt: 3000})
+
+			buf := &bytes.Buffer{}
+			compactor, err := newLeveledCompactor(ctx, slog.New(slog.NewTextHandler(buf, nil)), []int64{2 * time.Hour.Milliseconds()}, tsdb.DefaultLeveledCompactorConcurrencyOptions(), tsdb.NewCompactorMetrics(nil))
+			require.NoError(t, err)
+			tenantCompactor, err := compactor.withTenantOptions(tenantCompactorOptions{logOverlappingBlocks: logOverlappingBlocks})
+			require.NoError(t, err)
+
+			_, err = tenantCompactor.Compact(dir, []string{filepath.Join(dir, block1.String()), filepath.Join(dir, block2.String())}, nil)
+			require.NoError(t, err)
+
+			// The message logged by the TSDB compactor must still match the one dropped by the filter.
+			if logOverlappingBlocks {
+				assert.Contains(t, buf.String(), overlappingBlocksLogMsg)
+			} else {
+				assert.NotContains(t, buf.String(), overlappingBlocksLogMsg)
+			}
+		})
+	}
+}
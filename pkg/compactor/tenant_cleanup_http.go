@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+var errTenantCleanupInProgress = errors.New("blocks cleanup of the tenant is already in progress")
+
+type tenantCleanupResponse struct {
+	Tenant                  string `json:"tenant"`
+	BlocksMarkedForDeletion int    `json:"blocks_marked_for_deletion"`
+	BlocksDeleted           int    `json:"blocks_deleted"`
+	BucketIndexUpdatedAt    string `json:"bucket_index_updated_at,omitempty"`
+}
+
+// TenantCleanupHandler synchronously runs the blocks cleanup and maintenance (retention, bucket index update,
+// deletion of blocks marked for deletion) of a tenant, without waiting for the next cleanup interval.
+// The cleanup only runs if this compactor is the one running the blocks cleaner for the tenant.
+func (c *MultitenantCompactor) TenantCleanupHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		http.Error(w, "tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if c.State() != services.Running {
+		http.Error(w, "compactor is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	owned, err := c.shardingStrategy.blocksCleanerOwnsUser(tenantID)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to check blocks cleaner ownership of tenant", "user", tenantID, "err", err)
+		http.Error(w, fmt.Sprintf("failed to check blocks cleaner ownership of tenant: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if !owned {
+		owner, err := c.shardingStrategy.instanceOwningBlocksCleanerForUser(tenantID)
+		if err != nil {
+			http.Error(w, "this compactor doesn't run the blocks cleanup of the tenant, and the compactor running it could not be found", http.StatusMisdirectedRequest)
+			return
+		}
+
+		if owner.Addr == c.ringLifecycler.GetInstanceAddr() {
+			// The tenant is owned by this compactor in the ring, but it's not in the allowed tenants.
+			http.Error(w, "the tenant is not enabled on this compactor", http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, fmt.Sprintf("this compactor doesn't run the blocks cleanup of the tenant, send the request to compactor %s (%s)", owner.Id, owner.Addr), http.StatusMisdirectedRequest)
+		return
+	}
+
+	deleted, err := mimir_tsdb.TenantDeletionMarkExists(req.Context(), c.bucketClient, tenantID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to check tenant deletion mark: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if deleted {
+		http.Error(w, "tenant is marked for deletion", http.StatusConflict)
+		return
+	}
+
+	level.Info(c.logger).Log("msg", "running blocks cleanup of tenant on demand", "user", tenantID)
+
+	summary, err := c.blocksCleaner.cleanupTenant(req.Context(), tenantID)
+	if errors.Is(err, errTenantCleanupInProgress) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("blocks cleanup of tenant failed: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp := tenantCleanupResponse{
+		Tenant:                  tenantID,
+		BlocksMarkedForDeletion: summary.blocksMarkedForDeletion,
+		BlocksDeleted:           summary.blocksDeleted,
+	}
+	if !summary.bucketIndexUpdatedAt.IsZero() {
+		resp.BucketIndexUpdatedAt = formatTime(summary.bucketIndexUpdatedAt)
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestTenantCleanupHandler(t *testing.T) {
+	const (
+		user         = "user-1"
+		disabledUser = "user-2"
+	)
+
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = block.BucketWithGlobalMarkers(bucketClient)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods[user] = 24 * time.Hour
+
+	cfg := prepareConfig(t)
+	cfg.DeletionDelay = 0
+	cfg.DisabledTenants = []string{disabledUser}
+
+	c, _, _, _, _ := prepareWithConfigProvider(t, cfg, bucketClient, cfgProvider)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+	require.NoError(t, c.blocksCleaner.AwaitRunning(context.Background()))
+
+	// Create the blocks after the initial cleanup has run, so that only the cleanup triggered
+	// via the handler can apply the retention.
+	oldBlock := createTSDBBlock(t, bucketClient, user, 10, 20, 2, nil)
+	recentBlock := createTSDBBlock(t, bucketClient, user, time.Now().Add(-time.Hour).UnixMilli(), time.Now().UnixMilli(), 2, nil)
+
+	cleanup := func(t *testing.T) tenantCleanupResponse {
+		resp := httptest.NewRecorder()
+		c.TenantCleanupHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"tenant": user}))
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var summary tenantCleanupResponse
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &summary))
+		require.Equal(t, user, summary.Tenant)
+		require.NotEmpty(t, summary.BucketIndexUpdatedAt)
+		return summary
+	}
+
+	t.Run("cleanup of an owned tenant", func(t *testing.T) {
+		// The first cleanup builds the bucket index. The retention isn't applied when there's no bucket index yet.
+		summary := cleanup(t)
+		require.Zero(t, summary.BlocksMarkedForDeletion)
+		require.Zero(t, summary.BlocksDeleted)
+
+		summary = cleanup(t)
+		require.Equal(t, 1, summary.BlocksMarkedForDeletion)
+		require.Equal(t, 1, summary.BlocksDeleted)
+
+		idx, err := bucketindex.ReadIndex(context.Background(), bucketClient, user, nil, c.logger)
+		require.NoError(t, err)
+		require.Equal(t, []ulid.ULID{recentBlock}, idx.Blocks.GetULIDs())
+
+		exists, err := bucketClient.Exists(context.Background(), user+"/"+oldBlock.String()+"/meta.json")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("tenant not enabled on this compactor", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.TenantCleanupHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"tenant": disabledUser}))
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("cleanup already in progress", func(t *testing.T) {
+		c.blocksCleaner.singleFlight.ForEachNotInFlight(context.Background(), []string{user}, func(context.Context, string) error { //nolint:errcheck
+			resp := httptest.NewRecorder()
+			c.TenantCleanupHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"tenant": user}))
+			require.Equal(t, http.StatusConflict, resp.Code)
+			require.Contains(t, resp.Body.String(), errTenantCleanupInProgress.Error())
+			return nil
+		})
+	})
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// TenantCompactionRecordPath is the path of the object recording which compactor last successfully compacted
+// a tenant, relative to the tenant prefix in the bucket.
+const TenantCompactionRecordPath = "compactor-last-compaction.json"
+
+// TenantCompactionRecord is the content of the TenantCompactionRecordPath object.
+type TenantCompactionRecord struct {
+	// InstanceID is the ID of the compactor which last successfully compacted the tenant.
+	InstanceID string `json:"instance_id"`
+
+	// CompactedAt is when the tenant has been successfully compacted.
+	CompactedAt time.Time `json:"compacted_at"`
+}
+
+// readTenantCompactionRecord returns the compaction record of the tenant, or nil if the tenant has none.
+func readTenantCompactionRecord(ctx context.Context, userBucket objstore.InstrumentedBucket) (*TenantCompactionRecord, error) {
+	r, err := userBucket.ReaderWithExpectedErrs(userBucket.IsObjNotFoundErr).Get(ctx, TenantCompactionRecordPath)
+	if err != nil {
+		if userBucket.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read tenant compaction record: %s", TenantCompactionRecordPath)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tenant compaction record: %s", TenantCompactionRecordPath)
+	}
+
+	record := &TenantCompactionRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode tenant compaction record: %s", TenantCompactionRecordPath)
+	}
+	return record, nil
+}
+
+// writeTenantCompactionRecord writes the compaction record of the tenant.
+func writeTenantCompactionRecord(ctx context.Context, userBucket objstore.InstrumentedBucket, record TenantCompactionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode tenant compaction record")
+	}
+	return errors.Wrapf(userBucket.Upload(ctx, TenantCompactionRecordPath, bytes.NewReader(data)), "failed to write tenant compaction record: %s", TenantCompactionRecordPath)
+}
+
+// deleteTenantCompactionRecord deletes the compaction record of the tenant, if any.
+func deleteTenantCompactionRecord(ctx context.Context, userBucket objstore.Bucket) error {
+	if err := userBucket.Delete(ctx, TenantCompactionRecordPath); err != nil && !userBucket.IsObjNotFoundErr(err) {
+		return errors.Wrapf(err, "failed to delete tenant compaction record: %s", TenantCompactionRecordPath)
+	}
+	return nil
+}
+
+// recordTenantCompaction records that the tenant has been successfully compacted by this compactor, tracking
+// whether the previous successful compaction of the tenant has been run by a different compactor. Errors are
+// logged but not returned, because the record is only used for debugging.
+func (c *MultitenantCompactor) recordTenantCompaction(ctx context.Context, userID string) {
+	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+	userLogger := util_log.WithUserID(userID, c.logger)
+	instanceID := c.ringLifecycler.GetInstanceID()
+
+	previous, err := readTenantCompactionRecord(ctx, userBucket)
+	if err != nil {
+		level.Warn(userLogger).Log("msg", "failed to read the tenant compaction record", "err", err)
+	} else if previous != nil && previous.InstanceID != instanceID {
+		c.tenantInstanceChanges.Inc()
+		level.Info(userLogger).Log("msg", "tenant previously compacted by a different compactor", "previous_instance", previous.InstanceID, "previous_compacted_at", previous.CompactedAt)
+	}
+
+	record := TenantCompactionRecord{InstanceID: instanceID, CompactedAt: time.Now().UTC()}
+	if err := writeTenantCompactionRecord(ctx, userBucket, record); err != nil {
+		level.Warn(userLogger).Log("msg", "failed to write the tenant compaction record", "err", err)
+	}
+}
+
+// TenantCompactionRecordHandler returns, as JSON, which compactor last successfully compacted the tenant and when.
+func (c *MultitenantCompactor) TenantCompactionRecordHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		http.Error(w, "tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	record, err := readTenantCompactionRecord(req.Context(), bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider))
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to read the tenant compaction record", "user", tenantID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if record == nil {
+		http.Error(w, "the tenant has no compaction record", http.StatusNotFound)
+		return
+	}
+
+	util.WriteJSONResponse(w, record)
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid/v2"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestMultitenantCompactor_ShouldRecordTenantCompaction(t *testing.T) {
+	const user = "user-1"
+
+	inmem := objstore.NewInMemBucket()
+	id, err := ulid.New(ulid.Now(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, inmem.Upload(context.Background(), user+"/"+id.String()+"/meta.json", strings.NewReader(mockBlockMetaJSON(id.String()))))
+
+	cfg := prepareConfig(t)
+	cfg.TenantCompactionRecordEnabled = true
+
+	c, _, tsdbPlanner, _, _ := prepare(t, cfg, inmem)
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*block.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	instanceID := c.ringLifecycler.GetInstanceID()
+	userBucket := bucket.NewUserBucketClient(user, inmem, nil)
+
+	getRecord := func(t *testing.T, tenantID string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		c.TenantCompactionRecordHandler(resp, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"tenant": tenantID}))
+		return resp
+	}
+
+	t.Run("the successful compaction is recorded", func(t *testing.T) {
+		resp := getRecord(t, user)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var record TenantCompactionRecord
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &record))
+		assert.Equal(t, instanceID, record.InstanceID)
+		assert.False(t, record.CompactedAt.IsZero())
+
+		// The same compactor compacted the tenant in the previous cycle, if any.
+		assert.Equal(t, 0.0, prom_testutil.ToFloat64(c.tenantInstanceChanges))
+	})
+
+	t.Run("a change of the compacting instance is tracked", func(t *testing.T) {
+		previous := TenantCompactionRecord{InstanceID: "other-compactor", CompactedAt: time.Now().Add(-time.Hour).UTC()}
+		require.NoError(t, writeTenantCompactionRecord(context.Background(), userBucket, previous))
+
+		_, err := c.compactUserWithRetries(context.Background(), user)
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, prom_testutil.ToFloat64(c.tenantInstanceChanges))
+
+		record, err := readTenantCompactionRecord(context.Background(), userBucket)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, instanceID, record.InstanceID)
+
+		// Compacting the tenant again with the same compactor isn't a change.
+		_, err = c.compactUserWithRetries(context.Background(), user)
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, prom_testutil.ToFloat64(c.tenantInstanceChanges))
+	})
+
+	t.Run("tenant without blocks isn't recorded", func(t *testing.T) {
+		_, err := c.compactUserWithRetries(context.Background(), "user-without-blocks")
+		require.NoError(t, err)
+
+		record, err := readTenantCompactionRecord(context.Background(), bucket.NewUserBucketClient("user-without-blocks", inmem, nil))
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("tenant without compaction record", func(t *testing.T) {
+		resp := getRecord(t, "user-2")
+		require.Equal(t, http.StatusNotFound, resp.Code, resp.Body.String())
+	})
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/pkg/errors"
+)
+
+const (
+	// tenantLeasesKeyPrefix is the prefix of the KV store keys holding the tenant leases.
+	tenantLeasesKeyPrefix = "compactor-tenant-leases/"
+
+	// tenantLeaseTTL is how long a lease is valid if not renewed. It protects from leases
+	// never released because of a compactor crash.
+	tenantLeaseTTL = 5 * time.Minute
+
+	// tenantLeaseReleaseTimeout is the max time spent releasing a lease, even if the
+	// compaction has been interrupted.
+	tenantLeaseReleaseTimeout = 10 * time.Second
+)
+
+var errTenantLeaseUnavailable = errors.New("max number of compactors concurrently compacting the tenant reached")
+
+// tenantLeases is the value stored in the KV store for each tenant: the compactors currently
+// holding a lease to compact the tenant.
+type tenantLeases struct {
+	Holders []tenantLeaseHolder `json:"holders"`
+}
+
+type tenantLeaseHolder struct {
+	InstanceID string `json:"instance_id"`
+	// ExpiresAt is the lease expiration, in milliseconds since epoch.
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// removeExpired removes the leases expired at the input time.
+func (l *tenantLeases) removeExpired(now time.Time) {
+	l.Holders = slices.DeleteFunc(l.Holders, func(h tenantLeaseHolder) bool {
+		return h.ExpiresAt <= now.UnixMilli()
+	})
+}
+
+// tenantLeasesCodec encodes tenantLeases to JSON.
+type tenantLeasesCodec struct{}
+
+func (tenantLeasesCodec) CodecID() string {
+	return "compactorTenantLeases"
+}
+
+func (tenantLeasesCodec) Decode(data []byte) (interface{}, error) {
+	out := &tenantLeases{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (tenantLeasesCodec) Encode(in interface{}) ([]byte, error) {
+	leases, ok := in.(*tenantLeases)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T", in)
+	}
+	return json.Marshal(leases)
+}
+
+// tenantLeaser coordinates compactors through the KV store, so that at most maxHolders compactors
+// concurrently compact the same tenant.
+type tenantLeaser struct {
+	kv         kv.Client
+	instanceID string
+	maxHolders int
+	ttl        time.Duration
+	logger     log.Logger
+}
+
+func newTenantLeaser(client kv.Client, instanceID string, maxHolders int, logger log.Logger) *tenantLeaser {
+	return &tenantLeaser{
+		kv:         kv.PrefixClient(client, tenantLeasesKeyPrefix),
+		instanceID: instanceID,
+		maxHolders: maxHolders,
+		ttl:        tenantLeaseTTL,
+		logger:     logger,
+	}
+}
+
+// acquire acquires or renews the lease to compact the tenant. It returns errTenantLeaseUnavailable
+// if the max number of compactors holding a lease for the tenant has been reached.
+func (l *tenantLeaser) acquire(ctx context.Context, userID string) error {
+	return l.kv.CAS(ctx, userID, func(in interface{}) (out interface{}, retry bool, err error) {
+		leases := &tenantLeases{}
+		if in != nil {
+			leases = in.(*tenantLeases)
+		}
+
+		now := time.Now()
+		leases.removeExpired(now)
+
+		expiresAt := now.Add(l.ttl).UnixMilli()
+		if idx := l.holderIndex(leases); idx >= 0 {
+			leases.Holders[idx].ExpiresAt = expiresAt
+			return leases, true, nil
+		}
+
+		if len(leases.Holders) >= l.maxHolders {
+			return nil, false, errTenantLeaseUnavailable
+		}
+
+		leases.Holders = append(leases.Holders, tenantLeaseHolder{InstanceID: l.instanceID, ExpiresAt: expiresAt})
+		return leases, true, nil
+	})
+}
+
+// release releases the lease to compact the tenant, if held.
+func (l *tenantLeaser) release(ctx context.Context, userID string) error {
+	return l.kv.CAS(ctx, userID, func(in interface{}) (out interface{}, retry bool, err error) {
+		if in == nil {
+			return nil, false, nil
+		}
+
+		leases := in.(*tenantLeases)
+		idx := l.holderIndex(leases)
+		if idx < 0 {
+			return nil, false, nil
+		}
+
+		leases.Holders = slices.Delete(leases.Holders, idx, idx+1)
+		leases.removeExpired(time.Now())
+		return leases, true, nil
+	})
+}
+
+// keepAlive periodically renews the lease to compact the tenant until the returned function is called.
+// The returned function also releases the lease.
+func (l *tenantLeaser) keepAlive(ctx context.Context, userID string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(l.ttl / 5)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.acquire(ctx, userID); err != nil && ctx.Err() == nil {
+					level.Warn(l.logger).Log("msg", "failed to renew the lease to compact the tenant", "user", userID, "err", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		// Release the lease even if the compaction has been interrupted.
+		releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), tenantLeaseReleaseTimeout)
+		defer releaseCancel()
+
+		if err := l.release(releaseCtx, userID); err != nil {
+			level.Warn(l.logger).Log("msg", "failed to release the lease to compact the tenant", "user", userID, "err", err)
+		}
+	}
+}
+
+func (l *tenantLeaser) holderIndex(leases *tenantLeases) int {
+	return slices.IndexFunc(leases.Holders, func(h tenantLeaseHolder) bool {
+		return h.InstanceID == l.instanceID
+	})
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantLeaser(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	client, closer := consul.NewInMemoryClient(tenantLeasesCodec{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	leaser1 := newTenantLeaser(client, "compactor-1", 2, log.NewNopLogger())
+	leaser2 := newTenantLeaser(client, "compactor-2", 2, log.NewNopLogger())
+	leaser3 := newTenantLeaser(client, "compactor-3", 2, log.NewNopLogger())
+
+	getHolders := func() []string {
+		val, err := kv.PrefixClient(client, tenantLeasesKeyPrefix).Get(ctx, userID)
+		require.NoError(t, err)
+
+		var holders []string
+		for _, h := range val.(*tenantLeases).Holders {
+			holders = append(holders, h.InstanceID)
+		}
+		return holders
+	}
+
+	require.NoError(t, leaser1.acquire(ctx, userID))
+	require.NoError(t, leaser2.acquire(ctx, userID))
+	assert.Equal(t, []string{"compactor-1", "compactor-2"}, getHolders())
+
+	// The max number of holders has been reached.
+	require.ErrorIs(t, leaser3.acquire(ctx, userID), errTenantLeaseUnavailable)
+
+	// Renewing a lease doesn't take another slot.
+	require.NoError(t, leaser1.acquire(ctx, userID))
+	assert.Equal(t, []string{"compactor-1", "compactor-2"}, getHolders())
+
+	// Leases of other tenants are independent.
+	require.NoError(t, leaser3.acquire(ctx, "user-2"))
+
+	// Releasing a lease frees a slot.
+	require.NoError(t, leaser1.release(ctx, userID))
+	assert.Equal(t, []string{"compactor-2"}, getHolders())
+	require.NoError(t, leaser3.acquire(ctx, userID))
+	assert.Equal(t, []string{"compactor-2", "compactor-3"}, getHolders())
+
+	// Releasing a lease not held is a no-op.
+	require.NoError(t, leaser1.release(ctx, userID))
+	require.NoError(t, leaser1.release(ctx, "user-3"))
+	assert.Equal(t, []string{"compactor-2", "compactor-3"}, getHolders())
+}
+
+func TestTenantLeaser_ShouldIgnoreExpiredLeases(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	client, closer := consul.NewInMemoryClient(tenantLeasesCodec{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	leaser1 := newTenantLeaser(client, "compactor-1", 1, log.NewNopLogger())
+	leaser1.ttl = -time.Second // Acquired leases are already expired.
+	leaser2 := newTenantLeaser(client, "compactor-2", 1, log.NewNopLogger())
+
+	require.NoError(t, leaser1.acquire(ctx, userID))
+	require.NoError(t, leaser2.acquire(ctx, userID))
+
+	val, err := kv.PrefixClient(client, tenantLeasesKeyPrefix).Get(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, val.(*tenantLeases).Holders, 1)
+	assert.Equal(t, "compactor-2", val.(*tenantLeases).Holders[0].InstanceID)
+}
+
+func TestTenantLeaser_KeepAlive(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	client, closer := consul.NewInMemoryClient(tenantLeasesCodec{}, log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	leaser := newTenantLeaser(client, "compactor-1", 1, log.NewNopLogger())
+	leaser.ttl = 500 * time.Millisecond
+
+	require.NoError(t, leaser.acquire(ctx, userID))
+	release := leaser.keepAlive(ctx, userID)
+
+	// The lease is renewed, so it's still held after its TTL.
+	time.Sleep(2 * leaser.ttl)
+	other := newTenantLeaser(client, "compactor-2", 1, log.NewNopLogger())
+	require.ErrorIs(t, other.acquire(ctx, userID), errTenantLeaseUnavailable)
+
+	// The lease is released once the keep alive is stopped.
+	release()
+	require.NoError(t, other.acquire(ctx, userID))
+}
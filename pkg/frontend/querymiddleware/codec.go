@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"slices"
@@ -54,13 +55,20 @@ var (
 
 	// List of HTTP headers to propagate when a Prometheus request is encoded into a HTTP request.
 	// api.ReadConsistencyHeader is propagated as HTTP header -> Request.Context -> Request.Header, so there's no need to explicitly propagate it here.
-	codecPropagateHeadersMetrics = []string{compat.ForceFallbackHeaderName, chunkinfologger.ChunkInfoLoggingHeader, api.ReadConsistencyOffsetsHeader, querier.FilterQueryablesHeader}
+	codecPropagateHeadersMetrics = []string{compat.ForceFallbackHeaderName, chunkinfologger.ChunkInfoLoggingHeader, api.ReadConsistencyOffsetsHeader, querier.FilterQueryablesHeader, servedByRequestHeader, dataSourceSplitRequestHeader}
 	// api.ReadConsistencyHeader is propagated as HTTP header -> Request.Context -> Request.Header, so there's no need to explicitly propagate it here.
 	codecPropagateHeadersLabels = []string{api.ReadConsistencyOffsetsHeader, querier.FilterQueryablesHeader}
 )
 
 const maxResolutionPoints = 11000
 
+const (
+	// defaultMaxPropagatedHeaders and defaultMaxPropagatedHeaderValues are the default limits of the headers propagated
+	// to the downstream requests, generous enough to never be reached by legit requests.
+	defaultMaxPropagatedHeaders      = 32
+	defaultMaxPropagatedHeaderValues = 16
+)
+
 const (
 	// statusSuccess Prometheus success result.
 	statusSuccess = "success"
@@ -69,6 +77,15 @@ const (
 
 	totalShardsControlHeader = "Sharding-Control"
 
+	// maxQueryParallelismHeader carries the max number of sub-queries a querier should run in parallel for the
+	// request. The querier's own limit applies if the header is missing.
+	maxQueryParallelismHeader = "X-Mimir-Max-Query-Parallelism"
+
+	queryMinTHeader = "X-Mimir-Query-Min-T"
+	queryMaxTHeader = "X-Mimir-Query-Max-T"
+
+	legacyBlockFormatHeader = "X-Mimir-Legacy-Block-Format"
+
 	operationEncode = "encode"
 	operationDecode = "decode"
 
@@ -76,10 +93,23 @@ const (
 	formatProtobuf = "protobuf"
 )
 
+// decodedResponseSizeRatios is the estimated ratio between the in-memory size of a decoded query response and its
+// encoded size, for each format. Protobuf payloads are compact and expand when decoded, while JSON payloads spell
+// out timestamps and values as text, which take less space once decoded. These are rough estimates: they can be
+// refined comparing the payload sizes tracked by cortex_frontend_query_response_codec_payload_bytes with the heap
+// usage of the decoded responses.
+var decodedResponseSizeRatios = map[string]float64{
+	formatJSON:     0.75,
+	formatProtobuf: 2.5,
+}
+
 // Merger is used by middlewares making multiple requests to merge back all responses into a single one.
 type Merger interface {
 	// MergeResponse merges responses from multiple requests into a single Response
 	MergeResponse(...Response) (Response, error)
+	// MergeResponseForRequest merges the responses to the requests the input request has been split into,
+	// into the response to the input request.
+	MergeResponseForRequest(MetricsQueryRequest, ...Response) (Response, error)
 }
 
 // MetricsQueryRequest represents an instant or query range request that can be process by middlewares.
@@ -137,7 +167,9 @@ type MetricsQueryRequest interface {
 	// WithStats returns a copy of the current request with the provided value for the "stats" parameter.
 	//
 	// This value is passed to the querier to enable per-step statistics collection,
-	// which are exposed via querier.Stats. Currently, only the value "all" has an effect in Mimir.
+	// which are exposed via querier.Stats. Currently, only the value "all" has an effect on queriers in Mimir.
+	// The value "samples" makes the query-frontend return the total number of samples processed by the
+	// query in a response header, without collecting the per-step statistics.
 	// Note: unlike Prometheus, Mimir does not return query stats in the response body if stats is set.
 	WithStats(string) (MetricsQueryRequest, error)
 }
@@ -187,8 +219,13 @@ type Response interface {
 }
 
 type codecMetrics struct {
-	duration *prometheus.HistogramVec
-	size     *prometheus.HistogramVec
+	duration             *prometheus.HistogramVec
+	size                 *prometheus.HistogramVec
+	legacyBlockResponses prometheus.Counter
+	responseSamples      *prometheus.HistogramVec
+	responseHistograms   *prometheus.HistogramVec
+	formatMismatches     *prometheus.CounterVec
+	largeResponses       *prometheus.CounterVec
 }
 
 func newCodecMetrics(registerer prometheus.Registerer) *codecMetrics {
@@ -209,6 +246,28 @@ func newCodecMetrics(registerer prometheus.Registerer) *codecMetrics {
 			Help:    "Total size of query result payloads, in bytes.",
 			Buckets: prometheus.ExponentialBucketsRange(1*kb, 512*mb, 10),
 		}, []string{"operation", "format"}),
+		legacyBlockResponses: factory.NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_legacy_block_responses_total",
+			Help: "Total number of decoded query responses annotated as served from a legacy block format.",
+		}),
+		responseSamples: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_frontend_query_response_samples",
+			Help:    "Total number of float samples across all series of the decoded query responses.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 12),
+		}, []string{"operation"}),
+		responseHistograms: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_frontend_query_response_histograms",
+			Help:    "Total number of native histogram samples across all series of the decoded query responses.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 12),
+		}, []string{"operation"}),
+		formatMismatches: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_response_format_mismatches_total",
+			Help: "Total number of query responses decoded with a formatter not matching their content type.",
+		}, []string{"content_type_format", "decoded_format"}),
+		largeResponses: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_large_response_total",
+			Help: "Total number of query responses decoded or encoded with a size exceeding the configured warning threshold.",
+		}, []string{"operation"}),
 	}
 }
 
@@ -218,6 +277,53 @@ type Codec struct {
 	lookbackDelta                                   time.Duration
 	preferredQueryResultResponseFormat              string
 	propagateHeadersMetrics, propagateHeadersLabels []string
+	cacheKeyHeaders                                 []string
+	logger                                          log.Logger
+	formatters                                      []formatter
+
+	codecOptions
+}
+
+// codecOptions are the optional behaviours of a Codec, configured with the CodecOption.
+type codecOptions struct {
+	emptyResultAsNull            bool
+	validateStepAlignment        bool
+	sortedMatrixMerge            bool
+	queryTimeRangeHeaders        bool
+	instantQueryTimeParamAlias   string
+	defaultReadConsistency       string
+	legacyBlockFormatInfo        string
+	validateUTF8Labels           bool
+	sortSeriesLabels             bool
+	dropStaleMarkers             bool
+	deprecatedFunctions          map[string]struct{}
+	deprecatedFunctionsMode      string
+	outOfOrderSamplesMode        string
+	maxPropagatedHeaders         int
+	maxPropagatedHeaderValues    int
+	maxQueryTimeout              time.Duration
+	queryCostEstimateHeader      bool
+	serverTimingHeader           bool
+	jsonFloats                   jsonFloatFormatting
+	instantQueriesAsRangeQueries bool
+	formatterFallback            bool
+	deprecationWarnings          map[string]string
+	strictQueryParams            bool
+	responseSizeWarnThreshold    int
+	canonicalQueries             bool
+	maxLabelMatcherSets          int
+	mergedSeriesLimit            bool
+	cacheKeyIgnoredHeaders       map[string]struct{}
+}
+
+// CodecOption configures optional behaviours of a Codec.
+type CodecOption func(c *Codec)
+
+// WithLogger configures the logger of the warnings logged by the Codec. Defaults to no logging.
+func WithLogger(logger log.Logger) CodecOption {
+	return func(c *Codec) {
+		c.logger = logger
+	}
 }
 
 type formatter interface {
@@ -231,11 +337,10 @@ type formatter interface {
 	ContentType() v1.MIMEType
 }
 
-var jsonFormatterInstance = jsonFormatter{}
-
-var knownFormats = []formatter{
-	jsonFormatterInstance,
-	protobufFormatter{},
+// queryResponseMetadataDecoder is implemented by formatters that can decode a query response without
+// decoding the samples of its series.
+type queryResponseMetadataDecoder interface {
+	DecodeQueryResponseMetadata([]byte) (*PrometheusResponse, error)
 }
 
 func NewCodec(
@@ -243,18 +348,37 @@ func NewCodec(
 	lookbackDelta time.Duration,
 	queryResultResponseFormat string,
 	propagateHeaders []string,
+	opts ...CodecOption,
 ) Codec {
-	return Codec{
+	c := Codec{
 		metrics:                            newCodecMetrics(registerer),
 		lookbackDelta:                      lookbackDelta,
 		preferredQueryResultResponseFormat: queryResultResponseFormat,
 		propagateHeadersMetrics:            append(codecPropagateHeadersMetrics, propagateHeaders...),
 		propagateHeadersLabels:             append(codecPropagateHeadersLabels, propagateHeaders...),
+		cacheKeyHeaders:                    canonicalHeaderKeys(propagateHeaders),
+		logger:                             log.NewNopLogger(),
+		codecOptions: codecOptions{
+			maxPropagatedHeaders:      defaultMaxPropagatedHeaders,
+			maxPropagatedHeaderValues: defaultMaxPropagatedHeaderValues,
+		},
 	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	// The JSON formatter must be the first one, because it's the default when the client doesn't express a preference.
+	c.formatters = []formatter{
+		jsonFormatter{emptyResultAsNull: c.emptyResultAsNull, floats: c.jsonFloats},
+		protobufFormatter{},
+	}
+
+	return c
 }
 
 // MergeResponse merges responses from multiple requests into a single Response
-func (Codec) MergeResponse(responses ...Response) (Response, error) {
+func (c Codec) MergeResponse(responses ...Response) (Response, error) {
 	if len(responses) == 0 {
 		return newEmptyPrometheusResponse(), nil
 	}
@@ -316,7 +440,7 @@ func (Codec) MergeResponse(responses ...Response) (Response, error) {
 			Status: statusSuccess,
 			Data: &PrometheusData{
 				ResultType: model.ValMatrix.String(),
-				Result:     matrixMerge(promResponses),
+				Result:     c.mergeMatrices(promResponses),
 			},
 			Warnings: promWarnings,
 			Infos:    promInfos,
@@ -329,6 +453,40 @@ func (Codec) MergeResponse(responses ...Response) (Response, error) {
 	}, nil
 }
 
+// SplitRangeQueryByInterval splits the input range query into subrequests, each one covering the steps of the
+// original query falling into a single interval, aligned to the Unix epoch. The last subrequest may be extended
+// by one step into the next interval when this saves a subrequest. The start, end and minT/maxT of each
+// subrequest are recalculated so that all its steps are steps of the original query, and the subrequests
+// together cover all the steps of the original query.
+func (c Codec) SplitRangeQueryByInterval(req MetricsQueryRequest, interval time.Duration) ([]MetricsQueryRequest, error) {
+	if req.GetStep() <= 0 {
+		return nil, apierror.New(apierror.TypeBadData, "only range queries can be split by interval")
+	}
+	if interval <= 0 {
+		return nil, apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid split interval %s, it must be greater than zero", interval))
+	}
+
+	return splitQueryByInterval(req, interval)
+}
+
+// EstimateResponseSize returns the estimated in-memory size, in bytes, of a query response with the given content
+// length, encoded in the given format, once decoded. Responses in an unknown format are estimated to take as much
+// memory as their encoded size. Returns -1 if the content length is unknown (negative).
+//
+// The estimate is intended to be used to reject or queue requests likely to produce huge responses before
+// decoding them, and it's not a replacement for the limits on the actual size of responses.
+func (c Codec) EstimateResponseSize(contentLength int64, format string) int64 {
+	if contentLength < 0 {
+		return -1
+	}
+
+	ratio, ok := decodedResponseSizeRatios[format]
+	if !ok {
+		return contentLength
+	}
+	return int64(float64(contentLength) * ratio)
+}
+
 // DecodeMetricsQueryRequest decodes a MetricsQueryRequest from an http request.
 func (c Codec) DecodeMetricsQueryRequest(_ context.Context, r *http.Request) (MetricsQueryRequest, error) {
 	switch {
@@ -347,24 +505,56 @@ func (c Codec) decodeRangeQueryRequest(r *http.Request) (MetricsQueryRequest, er
 		return nil, apierror.New(apierror.TypeBadData, err.Error())
 	}
 
+	if err := c.validateRangeQueryParams(reqValues); err != nil {
+		return nil, err
+	}
+
 	start, end, step, err := DecodeRangeQueryTimeParams(&reqValues)
 	if err != nil {
 		return nil, err
 	}
 
+	// The fill and reduce parameters are applied when encoding the response, but they're validated upfront.
+	if _, err := decodeFillParam(reqValues); err != nil {
+		return nil, err
+	}
+	if _, err := decodeReduceParam(reqValues); err != nil {
+		return nil, err
+	}
+
+	limit, err := decodeLimitParam(reqValues)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := c.decodeTimeoutParam(reqValues)
+	if err != nil {
+		return nil, err
+	}
+
 	query := reqValues.Get("query")
 	queryExpr, err := parser.ParseExpr(query)
 	if err != nil {
 		return nil, DecorateWithParamName(err, "query")
 	}
+	if err := c.validateDeprecatedFunctions(queryExpr); err != nil {
+		return nil, err
+	}
+	queryExpr = c.canonicalizeQueryExpr(queryExpr)
 
 	var options Options
 	decodeOptions(r, &options)
 
-	stats := reqValues.Get("stats")
+	stats, err := decodeStatsParam(reqValues)
+	if err != nil {
+		return nil, err
+	}
+
 	req := NewPrometheusRangeQueryRequest(
 		r.URL.Path, httpHeadersToProm(r.Header), start, end, step, c.lookbackDelta, queryExpr, options, nil, stats,
 	)
+	req.limit = limit
+	req.timeout = timeout
 	return req, nil
 }
 
@@ -374,9 +564,23 @@ func (c Codec) decodeInstantQueryRequest(r *http.Request) (MetricsQueryRequest,
 		return nil, apierror.New(apierror.TypeBadData, err.Error())
 	}
 
-	time, err := DecodeInstantQueryTimeParams(&reqValues)
+	if err := c.validateInstantQueryParams(reqValues); err != nil {
+		return nil, err
+	}
+
+	time, err := c.decodeInstantQueryTime(&reqValues)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := decodeLimitParam(reqValues)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := c.decodeTimeoutParam(reqValues)
 	if err != nil {
-		return nil, DecorateWithParamName(err, "time")
+		return nil, err
 	}
 
 	query := reqValues.Get("query")
@@ -384,18 +588,36 @@ func (c Codec) decodeInstantQueryRequest(r *http.Request) (MetricsQueryRequest,
 	if err != nil {
 		return nil, DecorateWithParamName(err, "query")
 	}
+	if err := c.validateDeprecatedFunctions(queryExpr); err != nil {
+		return nil, err
+	}
+	queryExpr = c.canonicalizeQueryExpr(queryExpr)
 
 	var options Options
 	decodeOptions(r, &options)
 
-	stats := reqValues.Get("stats")
+	stats, err := decodeStatsParam(reqValues)
+	if err != nil {
+		return nil, err
+	}
 
 	req := NewPrometheusInstantQueryRequest(
 		r.URL.Path, httpHeadersToProm(r.Header), time, c.lookbackDelta, queryExpr, options, nil, stats,
 	)
+	req.limit = limit
+	req.timeout = timeout
 	return req, nil
 }
 
+// canonicalHeaderKeys returns the canonical form of the input header names.
+func canonicalHeaderKeys(headers []string) []string {
+	canonical := make([]string, 0, len(headers))
+	for _, h := range headers {
+		canonical = append(canonical, http.CanonicalHeaderKey(h))
+	}
+	return canonical
+}
+
 func httpHeadersToProm(httpH http.Header) []*PrometheusHeader {
 	if len(httpH) == 0 {
 		return nil
@@ -411,7 +633,7 @@ func httpHeadersToProm(httpH http.Header) []*PrometheusHeader {
 }
 
 // DecodeLabelsSeriesQueryRequest decodes a LabelsSeriesQueryRequest from an http request.
-func (Codec) DecodeLabelsSeriesQueryRequest(_ context.Context, r *http.Request) (LabelsSeriesQueryRequest, error) {
+func (c Codec) DecodeLabelsSeriesQueryRequest(_ context.Context, r *http.Request) (LabelsSeriesQueryRequest, error) {
 	if !IsLabelsQuery(r.URL.Path) && !IsSeriesQuery(r.URL.Path) {
 		return nil, fmt.Errorf("unknown labels or series query API endpoint %s", r.URL.Path)
 	}
@@ -428,6 +650,9 @@ func (Codec) DecodeLabelsSeriesQueryRequest(_ context.Context, r *http.Request)
 	}
 
 	labelMatcherSets := reqValues["match[]"]
+	if err := c.validateLabelMatcherSets(labelMatcherSets); err != nil {
+		return nil, err
+	}
 
 	limit := uint64(0) // 0 means unlimited
 	if limitStr := reqValues.Get("limit"); limitStr != "" {
@@ -439,6 +664,11 @@ func (Codec) DecodeLabelsSeriesQueryRequest(_ context.Context, r *http.Request)
 	headers := httpHeadersToProm(r.Header)
 
 	if IsSeriesQuery(r.URL.Path) {
+		maxSeriesAge, err := decodeMaxSeriesAge(reqValues, r.Header)
+		if err != nil {
+			return nil, err
+		}
+
 		return &PrometheusSeriesQueryRequest{
 			Path:             r.URL.Path,
 			Headers:          headers,
@@ -446,6 +676,7 @@ func (Codec) DecodeLabelsSeriesQueryRequest(_ context.Context, r *http.Request)
 			End:              end,
 			LabelMatcherSets: labelMatcherSets,
 			Limit:            limit,
+			MaxSeriesAge:     maxSeriesAge,
 		}, nil
 	}
 	if IsLabelNamesQuery(r.URL.Path) {
@@ -654,6 +885,7 @@ func decodeQueryMinMaxTime(queryExpr parser.Expr, start, end, step int64, lookba
 
 func decodeOptions(r *http.Request, opts *Options) {
 	opts.CacheDisabled = decodeCacheDisabledOption(r)
+	opts.AllowPartialResponse = decodeAllowPartialResponseOption(r)
 
 	for _, value := range r.Header.Values(totalShardsControlHeader) {
 		shards, err := strconv.ParseInt(value, 10, 32)
@@ -665,6 +897,14 @@ func decodeOptions(r *http.Request, opts *Options) {
 			opts.ShardingDisabled = true
 		}
 	}
+
+	for _, value := range r.Header.Values(maxQueryParallelismHeader) {
+		parallelism, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parallelism < 1 {
+			continue
+		}
+		opts.MaxQueryParallelism = int32(parallelism)
+	}
 }
 
 func decodeCacheDisabledOption(r *http.Request) bool {
@@ -679,9 +919,13 @@ func decodeCacheDisabledOption(r *http.Request) bool {
 
 // EncodeMetricsQueryRequest encodes a MetricsQueryRequest into an http request.
 func (c Codec) EncodeMetricsQueryRequest(ctx context.Context, r MetricsQueryRequest) (*http.Request, error) {
-	var u *url.URL
+	var (
+		u         *url.URL
+		costInput queryCostEstimateInput
+	)
 	switch r := r.(type) {
 	case *PrometheusRangeQueryRequest:
+		costInput = queryCostEstimateInput{expr: r.queryExpr, start: r.start, end: r.end, step: r.step, minT: r.minT, maxT: r.maxT}
 		values := url.Values{
 			"start": []string{encodeTime(r.GetStart())},
 			"end":   []string{encodeTime(r.GetEnd())},
@@ -691,11 +935,15 @@ func (c Codec) EncodeMetricsQueryRequest(ctx context.Context, r MetricsQueryRequ
 		if s := r.GetStats(); s != "" {
 			values["stats"] = []string{s}
 		}
+		if t := r.GetTimeout(); t > 0 {
+			values[timeoutParam] = []string{encodeDurationMs(t.Milliseconds())}
+		}
 		u = &url.URL{
 			Path:     r.GetPath(),
 			RawQuery: values.Encode(),
 		}
 	case *PrometheusInstantQueryRequest:
+		costInput = queryCostEstimateInput{expr: r.queryExpr, start: r.time, end: r.time, minT: r.minT, maxT: r.maxT}
 		values := url.Values{
 			"time":  []string{encodeTime(r.GetTime())},
 			"query": []string{r.GetQuery()},
@@ -703,6 +951,9 @@ func (c Codec) EncodeMetricsQueryRequest(ctx context.Context, r MetricsQueryRequ
 		if s := r.GetStats(); s != "" {
 			values["stats"] = []string{s}
 		}
+		if t := r.GetTimeout(); t > 0 {
+			values[timeoutParam] = []string{encodeDurationMs(t.Milliseconds())}
+		}
 		u = &url.URL{
 			Path:     r.GetPath(),
 			RawQuery: values.Encode(),
@@ -731,21 +982,14 @@ func (c Codec) EncodeMetricsQueryRequest(ctx context.Context, r MetricsQueryRequ
 		return nil, fmt.Errorf("unknown query result response format '%s'", c.preferredQueryResultResponseFormat)
 	}
 
-	if level, ok := api.ReadConsistencyLevelFromContext(ctx); ok {
+	if level, ok := c.readConsistencyLevel(ctx); ok {
 		req.Header.Add(api.ReadConsistencyHeader, level)
 	}
 
 	// Propagate allowed HTTP headers.
-	for _, h := range r.GetHeaders() {
-		if !slices.Contains(c.propagateHeadersMetrics, h.Name) {
-			continue
-		}
+	c.propagateHeaders(ctx, req, r.GetHeaders(), c.propagateHeadersMetrics)
 
-		for _, v := range h.Values {
-			// There should only be one value, but add all of them for completeness.
-			req.Header.Add(h.Name, v)
-		}
-	}
+	c.setQueryCostEstimateHeader(req, costInput)
 
 	// Inject auth from context.
 	if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
@@ -837,20 +1081,16 @@ func (c Codec) EncodeLabelsSeriesQueryRequest(ctx context.Context, req LabelsSer
 		return nil, fmt.Errorf("unknown query result response format '%s'", c.preferredQueryResultResponseFormat)
 	}
 
-	if level, ok := api.ReadConsistencyLevelFromContext(ctx); ok {
+	if level, ok := c.readConsistencyLevel(ctx); ok {
 		r.Header.Add(api.ReadConsistencyHeader, level)
 	}
 
 	// Propagate allowed HTTP headers.
-	for _, h := range req.GetHeaders() {
-		if !slices.Contains(c.propagateHeadersLabels, h.Name) {
-			continue
-		}
+	c.propagateHeaders(ctx, r, req.GetHeaders(), c.propagateHeadersLabels)
 
-		for _, v := range h.Values {
-			// There should only be one value, but add all of them for completeness.
-			r.Header.Add(h.Name, v)
-		}
+	// Encode the max series age after propagating the headers, so that it takes precedence over a propagated header.
+	if seriesReq, ok := req.(*PrometheusSeriesQueryRequest); ok {
+		encodeMaxSeriesAge(r, seriesReq.GetMaxSeriesAge())
 	}
 
 	// Inject auth from context.
@@ -871,12 +1111,94 @@ func encodeOptions(req *http.Request, o Options) {
 	if o.TotalShards > 0 {
 		req.Header.Set(totalShardsControlHeader, strconv.Itoa(int(o.TotalShards)))
 	}
+	if o.AllowPartialResponse {
+		req.Header.Set(partialResponseControlHeader, "true")
+	}
+	if o.MaxQueryParallelism > 0 {
+		req.Header.Set(maxQueryParallelismHeader, strconv.Itoa(int(o.MaxQueryParallelism)))
+	}
 }
 
 // DecodeMetricsQueryResponse decodes a Response from an http response.
 // The original request is also passed as a parameter this is useful for implementation that needs the request
 // to merge result or build the result correctly.
-func (c Codec) DecodeMetricsQueryResponse(ctx context.Context, r *http.Response, _ MetricsQueryRequest, logger log.Logger) (Response, error) {
+func (c Codec) DecodeMetricsQueryResponse(ctx context.Context, r *http.Response, req MetricsQueryRequest, logger log.Logger) (Response, error) {
+	resp, err := c.decodeMetricsQueryResponse(ctx, r, logger, func(f formatter, buf []byte) (*PrometheusResponse, error) {
+		resp, err := f.DecodeQueryResponse(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.validateStepAlignment {
+			if err := validateStepAlignment(req, resp); err != nil {
+				return nil, err
+			}
+		}
+
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.observeResponseSamples(req, resp)
+	return resp, nil
+}
+
+// observeResponseSamples tracks the total number of float samples and native histograms of the decoded response.
+func (c Codec) observeResponseSamples(req MetricsQueryRequest, resp Response) {
+	promResp, ok := resp.GetPrometheusResponse()
+	if !ok || promResp.Data == nil {
+		return
+	}
+
+	var samples, histograms int
+	for _, series := range promResp.Data.Result {
+		samples += len(series.Samples)
+		histograms += len(series.Histograms)
+	}
+
+	op := queryTypeOther
+	switch req.(type) {
+	case *PrometheusRangeQueryRequest:
+		op = queryTypeRange
+	case *PrometheusInstantQueryRequest:
+		op = queryTypeInstant
+	}
+	c.metrics.responseSamples.WithLabelValues(op).Observe(float64(samples))
+	c.metrics.responseHistograms.WithLabelValues(op).Observe(float64(histograms))
+}
+
+// DecodeMetricsQueryResponseMetadata decodes a Response from an http response like DecodeMetricsQueryResponse,
+// but only decodes the labels of each series in vector and matrix results: the returned series have no float
+// or histogram samples. This is useful for callers that only need the result metadata, such as the number of
+// series and their labels, because it avoids the cost of decoding samples that would be discarded anyway.
+//
+// Decoding samples is only skipped for protobuf responses. Responses in other formats are fully decoded,
+// and their samples are dropped afterwards.
+func (c Codec) DecodeMetricsQueryResponseMetadata(ctx context.Context, r *http.Response, _ MetricsQueryRequest, logger log.Logger) (Response, error) {
+	return c.decodeMetricsQueryResponse(ctx, r, logger, func(f formatter, buf []byte) (*PrometheusResponse, error) {
+		if d, ok := f.(queryResponseMetadataDecoder); ok {
+			return d.DecodeQueryResponseMetadata(buf)
+		}
+
+		resp, err := f.DecodeQueryResponse(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Data != nil && (resp.Data.ResultType == model.ValVector.String() || resp.Data.ResultType == model.ValMatrix.String()) {
+			for i := range resp.Data.Result {
+				resp.Data.Result[i].Samples = nil
+				resp.Data.Result[i].Histograms = nil
+			}
+		}
+
+		return resp, nil
+	})
+}
+
+func (c Codec) decodeMetricsQueryResponse(ctx context.Context, r *http.Response, logger log.Logger, decode func(f formatter, buf []byte) (*PrometheusResponse, error)) (Response, error) {
 	spanlog := spanlogger.FromContext(ctx, logger)
 	buf, err := readResponseBody(r)
 	if err != nil {
@@ -910,24 +1232,56 @@ func (c Codec) DecodeMetricsQueryResponse(ctx context.Context, r *http.Response,
 		}
 	}
 
-	formatter := findFormatter(contentType)
+	formatter := c.findFormatter(contentType)
 	if formatter == nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "unknown response content type '%v'", contentType)
 	}
 
 	start := time.Now()
-	resp, err := formatter.DecodeQueryResponse(buf)
+	resp, formatter, err := c.decodeWithFormatterFallback(formatter, buf, decode, spanlog)
 	if err != nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "error decoding response: %v", err)
 	}
 
-	c.metrics.duration.WithLabelValues(operationDecode, formatter.Name()).Observe(time.Since(start).Seconds())
+	decodeDuration := time.Since(start)
+	c.metrics.duration.WithLabelValues(operationDecode, formatter.Name()).Observe(decodeDuration.Seconds())
 	c.metrics.size.WithLabelValues(operationDecode, formatter.Name()).Observe(float64(len(buf)))
+	observeServerTimingsDecode(ctx, decodeDuration, len(buf))
 
 	if resp.Status == statusError {
 		return nil, apierror.New(apierror.Type(resp.ErrorType), resp.Error)
 	}
 
+	if c.validateUTF8Labels {
+		if err := validateUTF8QueryResponseLabels(resp); err != nil {
+			return nil, apierror.Newf(apierror.TypeInternal, "invalid response: %v", err)
+		}
+	}
+
+	if c.sortSeriesLabels {
+		sortQueryResponseLabels(resp)
+	}
+
+	if err := c.checkSamplesOrder(resp, spanlog); err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "invalid response: %v", err)
+	}
+
+	if c.hasLegacyBlockFormatInfo(resp.Infos) {
+		c.metrics.legacyBlockResponses.Inc()
+	}
+
+	resp.Infos = normalizeServedByAnnotations(resp.Infos)
+	resp.Infos = normalizeDataSourceSplitAnnotations(resp.Infos)
+
+	if c.isLargeResponse(len(buf)) {
+		c.metrics.largeResponses.WithLabelValues(operationDecode).Inc()
+		resp, _ = c.addLargeResponseInfo(resp)
+	}
+
+	if failed := partialResponseFailures(r.Header); len(failed) > 0 {
+		resp.Warnings = append(resp.Warnings, partialResponseWarning(failed))
+	}
+
 	for h, hv := range r.Header {
 		resp.Headers = append(resp.Headers, &PrometheusHeader{Name: h, Values: hv})
 	}
@@ -971,7 +1325,7 @@ func (c Codec) DecodeLabelsSeriesQueryResponse(ctx context.Context, r *http.Resp
 		}
 	}
 
-	formatter := findFormatter(contentType)
+	formatter := c.findFormatter(contentType)
 	if formatter == nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "unknown response content type '%v'", contentType)
 	}
@@ -994,6 +1348,12 @@ func (c Codec) DecodeLabelsSeriesQueryResponse(ctx context.Context, r *http.Resp
 			return nil, apierror.New(apierror.Type(resp.ErrorType), resp.Error)
 		}
 
+		if c.validateUTF8Labels {
+			if err := validateUTF8LabelsResponse(resp); err != nil {
+				return nil, apierror.Newf(apierror.TypeInternal, "invalid response: %v", err)
+			}
+		}
+
 		for h, hv := range r.Header {
 			resp.Headers = append(resp.Headers, &PrometheusHeader{Name: h, Values: hv})
 		}
@@ -1012,6 +1372,12 @@ func (c Codec) DecodeLabelsSeriesQueryResponse(ctx context.Context, r *http.Resp
 			return nil, apierror.New(apierror.Type(resp.ErrorType), resp.Error)
 		}
 
+		if c.validateUTF8Labels {
+			if err := validateUTF8SeriesResponse(resp); err != nil {
+				return nil, apierror.Newf(apierror.TypeInternal, "invalid response: %v", err)
+			}
+		}
+
 		for h, hv := range r.Header {
 			resp.Headers = append(resp.Headers, &PrometheusHeader{Name: h, Values: hv})
 		}
@@ -1023,13 +1389,23 @@ func (c Codec) DecodeLabelsSeriesQueryResponse(ctx context.Context, r *http.Resp
 	return response, nil
 }
 
-func findFormatter(contentType string) formatter {
-	for _, f := range knownFormats {
+func (c Codec) findFormatter(contentType string) formatter {
+	for _, f := range c.formatters {
 		if f.ContentType().String() == contentType {
 			return f
 		}
 	}
 
+	// JSON responses followed by a metadata object, or using the columnar layout, are selected by content type
+	// parameters, so that standard responses are always decoded as a single object in the row layout.
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && mediaType == jsonMimeType {
+		trailingMetadata := params[jsonTrailingMetadataParam] == jsonTrailingMetadataValue
+		columnar := params[jsonLayoutParam] == jsonLayoutColumnar
+		if trailingMetadata || columnar {
+			return jsonFormatter{emptyResultAsNull: c.emptyResultAsNull, trailingMetadata: trailingMetadata, columnar: columnar}
+		}
+	}
+
 	return nil
 }
 
@@ -1046,17 +1422,70 @@ func (c Codec) EncodeMetricsQueryResponse(ctx context.Context, req *http.Request
 		sp.SetAttributes(attribute.Int("series", len(a.Data.Result)))
 	}
 
-	selectedContentType, formatter := c.negotiateContentType(req.Header.Get("Accept"))
+	a, err := fillRangeQueryResponseGaps(req, a)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = reduceRangeQueryResponse(req, a)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = truncateQueryResponseToLimit(req, a)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = c.addDeprecatedFunctionsWarnings(req, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sharding explanations, the blocks or store-gateways which served the query and the data source split
+	// are only surfaced as headers, so they're removed from the infos.
+	infos, shardingExplanations := extractShardingExplanations(a.Infos)
+	infos, servedBy := extractServedBy(infos)
+	infos, dataSourceSplit := extractDataSourceSplit(infos)
+	if shardingExplanations != nil || servedBy != nil || dataSourceSplit != nil {
+		withoutExplanations := *a
+		withoutExplanations.Infos = infos
+		a = &withoutExplanations
+	}
+
+	selectedContentType, formatter := c.negotiateQueryResultContentType(req.Header.Get("Accept"))
 	if formatter == nil {
 		return nil, apierror.New(apierror.TypeNotAcceptable, "none of the content types in the Accept header are supported")
 	}
 
+	if _, ok := formatter.(sseFormatter); ok && !isSSESupportedResult(a) {
+		return nil, apierror.Newf(apierror.TypeNotAcceptable, "only matrix and vector results can be encoded as %s", sseMimeType)
+	}
+
+	a, err = c.addDeprecationWarnings(req, formatter, a)
+	if err != nil {
+		return nil, err
+	}
+
 	start := time.Now()
 	b, err := formatter.EncodeQueryResponse(a)
 	if err != nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "error encoding response: %v", err)
 	}
 
+	if c.isLargeResponse(len(b)) {
+		c.metrics.largeResponses.WithLabelValues(operationEncode).Inc()
+
+		// The response size is only known once encoded, so it's encoded again with the info, unless already added
+		// when decoding it.
+		if annotated, ok := c.addLargeResponseInfo(a); ok {
+			a = annotated
+			if b, err = formatter.EncodeQueryResponse(a); err != nil {
+				return nil, apierror.Newf(apierror.TypeInternal, "error encoding response: %v", err)
+			}
+		}
+	}
+
 	encodeDuration := time.Since(start)
 	c.metrics.duration.WithLabelValues(operationEncode, formatter.Name()).Observe(encodeDuration.Seconds())
 	c.metrics.size.WithLabelValues(operationEncode, formatter.Name()).Observe(float64(len(b)))
@@ -1076,6 +1505,22 @@ func (c Codec) EncodeMetricsQueryResponse(ctx context.Context, req *http.Request
 		StatusCode:    http.StatusOK,
 		ContentLength: int64(len(b)),
 	}
+	if err := addSamplesProcessedHeader(ctx, req, resp.Header); err != nil {
+		return nil, err
+	}
+	if c.hasLegacyBlockFormatInfo(a.Infos) {
+		resp.Header.Set(legacyBlockFormatHeader, "true")
+	}
+	for _, explanation := range shardingExplanations {
+		resp.Header.Add(shardingInfoHeader, explanation)
+	}
+	if len(servedBy) > 0 && isServedByRequested(req) {
+		resp.Header.Set(servedByHeader, strings.Join(servedBy, ","))
+	}
+	if dataSourceSplit != nil && isDataSourceSplitRequested(req) {
+		resp.Header.Set(dataSourceSplitHeader, dataSourceSplit.headerValue())
+	}
+	c.setServerTimingHeader(ctx, resp.Header, encodeDuration, len(b))
 	return &resp, nil
 }
 
@@ -1153,13 +1598,33 @@ func (c Codec) EncodeLabelsSeriesQueryResponse(ctx context.Context, req *http.Re
 	return &resp, nil
 }
 
-func (Codec) negotiateContentType(acceptHeader string) (string, formatter) {
+// negotiateQueryResultContentType is like negotiateContentType, but also selects the JSON columnar layout and the
+// Server-Sent Events, which are only supported by query results, if the client prefers them. Server-Sent Events are
+// only selected if explicitly accepted, not by wildcards.
+func (c Codec) negotiateQueryResultContentType(acceptHeader string) (string, formatter) {
+	for _, clause := range goautoneg.ParseAccept(acceptHeader) {
+		if clause.Type == "application" && clause.SubType == "json" && clause.Params[jsonLayoutParam] == jsonLayoutColumnar {
+			return jsonColumnarMimeType, jsonFormatter{emptyResultAsNull: c.emptyResultAsNull, columnar: true, floats: c.jsonFloats}
+		}
+		if clause.Type == "text" && clause.SubType == "event-stream" {
+			return sseMimeType, sseFormatter{}
+		}
+		// Any other supported clause takes precedence over the columnar layout if preferred by the client.
+		if _, f := c.negotiateContentType(clause.Type + "/" + clause.SubType); f != nil {
+			break
+		}
+	}
+
+	return c.negotiateContentType(acceptHeader)
+}
+
+func (c Codec) negotiateContentType(acceptHeader string) (string, formatter) {
 	if acceptHeader == "" {
-		return jsonMimeType, jsonFormatterInstance
+		return jsonMimeType, c.formatters[0]
 	}
 
 	for _, clause := range goautoneg.ParseAccept(acceptHeader) {
-		for _, formatter := range knownFormats {
+		for _, formatter := range c.formatters {
 			if formatter.ContentType().Satisfies(clause) {
 				return formatter.ContentType().String(), formatter
 			}
@@ -1169,7 +1634,7 @@ func (Codec) negotiateContentType(acceptHeader string) (string, formatter) {
 	return "", nil
 }
 
-func matrixMerge(resps []*PrometheusResponse) []SampleStream {
+func matrixMerge(resps []*PrometheusResponse, dropStaleMarkers bool) []SampleStream {
 	output := map[string]*SampleStream{}
 	for _, resp := range resps {
 		if resp.Data == nil {
@@ -1183,33 +1648,7 @@ func matrixMerge(resps []*PrometheusResponse) []SampleStream {
 					Labels: stream.Labels,
 				}
 			}
-			// We need to make sure we don't repeat samples. This causes some visualisations to be broken in Grafana.
-			// The prometheus API is inclusive of start and end timestamps.
-			if len(existing.Samples) > 0 && len(stream.Samples) > 0 {
-				existingEndTs := existing.Samples[len(existing.Samples)-1].TimestampMs
-				if existingEndTs == stream.Samples[0].TimestampMs {
-					// Typically this the cases where only 1 sample point overlap,
-					// so optimize with simple code.
-					stream.Samples = stream.Samples[1:]
-				} else if existingEndTs > stream.Samples[0].TimestampMs {
-					// Overlap might be big, use heavier algorithm to remove overlap.
-					stream.Samples = sliceFloatSamples(stream.Samples, existingEndTs)
-				} // else there is no overlap, yay!
-			}
-			existing.Samples = append(existing.Samples, stream.Samples...)
-
-			if len(existing.Histograms) > 0 && len(stream.Histograms) > 0 {
-				existingEndTs := existing.Histograms[len(existing.Histograms)-1].TimestampMs
-				if existingEndTs == stream.Histograms[0].TimestampMs {
-					// Typically this the cases where only 1 sample point overlap,
-					// so optimize with simple code.
-					stream.Histograms = stream.Histograms[1:]
-				} else if existingEndTs > stream.Histograms[0].TimestampMs {
-					// Overlap might be big, use heavier algorithm to remove overlap.
-					stream.Histograms = sliceHistogramSamples(stream.Histograms, existingEndTs)
-				} // else there is no overlap, yay!
-			}
-			existing.Histograms = append(existing.Histograms, stream.Histograms...)
+			appendSampleStream(existing, stream, dropStaleMarkers)
 
 			output[metric] = existing
 		}
@@ -1229,6 +1668,51 @@ func matrixMerge(resps []*PrometheusResponse) []SampleStream {
 	return result
 }
 
+func (c Codec) mergeMatrices(resps []*PrometheusResponse) []SampleStream {
+	if c.sortedMatrixMerge {
+		return sortedMatrixMerge(resps, c.dropStaleMarkers)
+	}
+	return matrixMerge(resps, c.dropStaleMarkers)
+}
+
+// appendSampleStream appends the samples of stream to existing, skipping the samples overlapping with the
+// ones already in existing. If dropStaleMarkers is true, the samples which are stale markers are skipped too.
+func appendSampleStream(existing *SampleStream, stream SampleStream, dropStaleMarkers bool) {
+	// We need to make sure we don't repeat samples. This causes some visualisations to be broken in Grafana.
+	// The prometheus API is inclusive of start and end timestamps.
+	if len(existing.Samples) > 0 && len(stream.Samples) > 0 {
+		existingEndTs := existing.Samples[len(existing.Samples)-1].TimestampMs
+		if existingEndTs == stream.Samples[0].TimestampMs {
+			// Typically this the cases where only 1 sample point overlap,
+			// so optimize with simple code.
+			stream.Samples = stream.Samples[1:]
+		} else if existingEndTs > stream.Samples[0].TimestampMs {
+			// Overlap might be big, use heavier algorithm to remove overlap.
+			stream.Samples = sliceFloatSamples(stream.Samples, existingEndTs)
+		} // else there is no overlap, yay!
+	}
+	if dropStaleMarkers {
+		stream.Samples = dropStaleFloatSamples(stream.Samples)
+	}
+	existing.Samples = append(existing.Samples, stream.Samples...)
+
+	if len(existing.Histograms) > 0 && len(stream.Histograms) > 0 {
+		existingEndTs := existing.Histograms[len(existing.Histograms)-1].TimestampMs
+		if existingEndTs == stream.Histograms[0].TimestampMs {
+			// Typically this the cases where only 1 sample point overlap,
+			// so optimize with simple code.
+			stream.Histograms = stream.Histograms[1:]
+		} else if existingEndTs > stream.Histograms[0].TimestampMs {
+			// Overlap might be big, use heavier algorithm to remove overlap.
+			stream.Histograms = sliceHistogramSamples(stream.Histograms, existingEndTs)
+		} // else there is no overlap, yay!
+	}
+	if dropStaleMarkers {
+		stream.Histograms = dropStaleHistogramSamples(stream.Histograms)
+	}
+	existing.Histograms = append(existing.Histograms, stream.Histograms...)
+}
+
 // sliceFloatSamples assumes given samples are sorted by timestamp in ascending order and
 // return a sub slice whose first element's is the smallest timestamp that is strictly
 // bigger than the given minTs. Empty slice is returned if minTs is bigger than all the
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// WithCacheKeyIgnoredHeaders configures the request headers ignored by CacheKey, so that the requests only differing
+// by these headers share the same cached results. Header names are case-insensitive. Defaults to no ignored headers.
+func WithCacheKeyIgnoredHeaders(headers []string) CodecOption {
+	return func(c *Codec) {
+		c.cacheKeyIgnoredHeaders = make(map[string]struct{}, len(headers))
+		for _, h := range headers {
+			c.cacheKeyIgnoredHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+}
+
+// CacheKey returns the part of the results cache key identifying the input request, other than its tenant, step and
+// time range. It's made of the query and of the values of the extra headers propagated downstream, which can change
+// the result of the query, except the headers configured with WithCacheKeyIgnoredHeaders. The query stats parameter
+// doesn't change the result of the query, so it's never part of the key. When the request has no extra propagated
+// header, the key is the query, like the keys of the requests cached before the headers were taken into account.
+func (c Codec) CacheKey(req MetricsQueryRequest) string {
+	b := strings.Builder{}
+	b.WriteString(req.GetQuery())
+
+	// The headers of the decoded requests are sorted by name, so the key doesn't depend on their order.
+	for _, h := range req.GetHeaders() {
+		name := http.CanonicalHeaderKey(h.Name)
+		if !slices.Contains(c.cacheKeyHeaders, name) {
+			continue
+		}
+		if _, ignored := c.cacheKeyIgnoredHeaders[name]; ignored {
+			continue
+		}
+
+		b.WriteString(":")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(h.Values, ","))
+	}
+
+	return b.String()
+}
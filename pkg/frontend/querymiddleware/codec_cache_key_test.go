@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_CacheKey(t *testing.T) {
+	expr, err := parser.ParseExpr("up")
+	require.NoError(t, err)
+
+	newRequest := func(headers []*PrometheusHeader, stats string) MetricsQueryRequest {
+		return NewPrometheusRangeQueryRequest("/api/v1/query_range", headers, 10_000, 70_000, 20_000, 0, expr, Options{}, nil, stats)
+	}
+
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, []string{"X-Tenant-Region", "x-dashboard-uid"}, WithCacheKeyIgnoredHeaders([]string{"x-dashboard-uid"}))
+	key := codec.CacheKey(newRequest([]*PrometheusHeader{{Name: "X-Tenant-Region", Values: []string{"eu"}}}, ""))
+	assert.Equal(t, "up:X-Tenant-Region=eu", key)
+
+	t.Run("should be the query if the request has no extra propagated header", func(t *testing.T) {
+		assert.Equal(t, "up", codec.CacheKey(newRequest(nil, "")))
+		assert.Equal(t, "up", newTestCodec().CacheKey(newRequest([]*PrometheusHeader{{Name: "X-Tenant-Region", Values: []string{"eu"}}}, "")))
+	})
+
+	t.Run("should ignore the query stats", func(t *testing.T) {
+		assert.Equal(t, key, codec.CacheKey(newRequest([]*PrometheusHeader{{Name: "X-Tenant-Region", Values: []string{"eu"}}}, "all")))
+	})
+
+	t.Run("should ignore the headers not propagated downstream", func(t *testing.T) {
+		assert.Equal(t, key, codec.CacheKey(newRequest([]*PrometheusHeader{
+			{Name: "Accept", Values: []string{"application/json"}},
+			{Name: "X-Tenant-Region", Values: []string{"eu"}},
+		}, "")))
+	})
+
+	t.Run("should ignore the configured headers, whatever their case", func(t *testing.T) {
+		assert.Equal(t, key, codec.CacheKey(newRequest([]*PrometheusHeader{
+			{Name: "X-Dashboard-Uid", Values: []string{"abc"}},
+			{Name: "X-Tenant-Region", Values: []string{"eu"}},
+		}, "")))
+	})
+
+	t.Run("should change with the values of the extra propagated headers", func(t *testing.T) {
+		assert.NotEqual(t, key, codec.CacheKey(newRequest([]*PrometheusHeader{{Name: "X-Tenant-Region", Values: []string{"us"}}}, "")))
+	})
+}
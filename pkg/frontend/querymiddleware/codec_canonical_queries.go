@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// WithCanonicalQueries enables the canonicalization of the query of the decoded metrics query requests: the
+// parentheses not affecting the evaluation of the query are removed, and the query sent downstream and used in the
+// results cache keys is the canonical string form of the parsed query, so that the queries only differing by their
+// formatting share the same cache entries. Clients reading the query back, like in the query stats, see the canonical
+// query. The query is always parsed from the original string, so that parse errors refer to the original query.
+// Defaults to false.
+func WithCanonicalQueries(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.canonicalQueries = enabled
+	}
+}
+
+// canonicalizeQueryExpr returns the input query expression without the redundant parentheses, if the canonicalization
+// of queries is enabled. The returned expression is parsed again from its string form, so that its position ranges
+// refer to the canonical query. The input expression may be modified.
+func (c Codec) canonicalizeQueryExpr(expr parser.Expr) parser.Expr {
+	if !c.canonicalQueries {
+		return expr
+	}
+
+	canonical, err := parser.ParseExpr(removeRedundantParens(expr, true).String())
+	if err != nil {
+		// Should never happen, but the original query is always valid.
+		return expr
+	}
+	return canonical
+}
+
+// removeRedundantParens returns the input expression without the parentheses which don't affect its evaluation.
+// delimited is whether the expression is already delimited, like the whole query or a function argument, in which
+// case its own parentheses are redundant. The input expression is modified.
+func removeRedundantParens(expr parser.Expr, delimited bool) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.ParenExpr:
+		inner := removeRedundantParens(e.Expr, true)
+		if delimited || isAtomicExpr(inner) {
+			return inner
+		}
+		e.Expr = inner
+	case *parser.BinaryExpr:
+		e.LHS = removeRedundantParens(e.LHS, false)
+		e.RHS = removeRedundantParens(e.RHS, false)
+	case *parser.UnaryExpr:
+		e.Expr = removeRedundantParens(e.Expr, false)
+	case *parser.SubqueryExpr:
+		e.Expr = removeRedundantParens(e.Expr, false)
+	case *parser.StepInvariantExpr:
+		e.Expr = removeRedundantParens(e.Expr, delimited)
+	case *parser.Call:
+		for i, arg := range e.Args {
+			e.Args[i] = removeRedundantParens(arg, true)
+		}
+	case *parser.AggregateExpr:
+		e.Expr = removeRedundantParens(e.Expr, true)
+		if e.Param != nil {
+			e.Param = removeRedundantParens(e.Param, true)
+		}
+	}
+	return expr
+}
+
+// isAtomicExpr returns whether the input expression is evaluated the same regardless of the operators around it, so
+// that parentheses around it are redundant. Negative numbers aren't, because the unary minus binds less tightly than
+// the power operator.
+func isAtomicExpr(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.VectorSelector, *parser.MatrixSelector, *parser.StringLiteral, *parser.Call, *parser.AggregateExpr, *parser.ParenExpr:
+		return true
+	case *parser.NumberLiteral:
+		return e.Val >= 0
+	default:
+		return false
+	}
+}
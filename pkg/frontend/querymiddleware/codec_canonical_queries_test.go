@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeMetricsQueryRequest_CanonicalQueries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		queries           []string
+		expectedCanonical string
+	}{
+		"whitespace": {
+			queries:           []string{`sum by (job) (rate(up{job="a"}[5m]))`, `sum  by(job)(rate( up{ job = "a" }[5m] ))`, "sum\nby (job)\n(\n  rate(up{job=\"a\"}[5m])\n)"},
+			expectedCanonical: `sum by (job) (rate(up{job="a"}[5m]))`,
+		},
+		"redundant parentheses around the whole query": {
+			queries:           []string{`up + 1`, `(up + 1)`, `((up + 1))`},
+			expectedCanonical: `up + 1`,
+		},
+		"redundant parentheses around atomic expressions": {
+			queries:           []string{`rate(up[5m]) * 2`, `(rate(up[5m])) * (2)`, `((rate(up[5m]))) * 2`},
+			expectedCanonical: `rate(up[5m]) * 2`,
+		},
+		"redundant parentheses around function and aggregation arguments": {
+			queries:           []string{`sum(rate(up[5m]) / 2)`, `sum((rate(up[5m]) / 2))`, `(sum(((rate(up[5m])) / 2)))`},
+			expectedCanonical: `sum(rate(up[5m]) / 2)`,
+		},
+		"redundant nested parentheses": {
+			queries:           []string{`(up + 1) * 2`, `((up + 1)) * 2`, `(((up + 1))) * (2)`},
+			expectedCanonical: `(up + 1) * 2`,
+		},
+		"parentheses affecting the precedence are kept": {
+			queries:           []string{`(up - 1) / (up + 1)`},
+			expectedCanonical: `(up - 1) / (up + 1)`,
+		},
+		"parentheses around negative numbers are kept": {
+			queries:           []string{`(-1) ^ 2`},
+			expectedCanonical: `(-1) ^ 2`,
+		},
+		"parentheses of subqueries of non atomic expressions are kept": {
+			queries:           []string{`max_over_time((up + 1)[10m:1m])`, `max_over_time(((up + 1))[10m:1m])`},
+			expectedCanonical: `max_over_time((up + 1)[10m:1m])`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, path := range []string{"/api/v1/query_range?start=0&end=180&step=60&query=", "/api/v1/query?time=180&query="} {
+				for _, query := range tc.queries {
+					req := httptest.NewRequest(http.MethodGet, path+url.QueryEscape(query), nil)
+
+					codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithCanonicalQueries(true))
+					decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedCanonical, decoded.GetQuery(), "query: %s", query)
+
+					// The canonical query is sent downstream.
+					encoded, err := codec.EncodeMetricsQueryRequest(user.InjectOrgID(context.Background(), "user-1"), decoded)
+					require.NoError(t, err)
+					require.NoError(t, encoded.ParseForm())
+					assert.Equal(t, tc.expectedCanonical, encoded.Form.Get("query"))
+				}
+			}
+		})
+	}
+
+	t.Run("canonicalization disabled", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+		decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/api/v1/query?time=180&query="+url.QueryEscape(`((rate(up[5m]))) * (2)`), nil))
+		require.NoError(t, err)
+		assert.Equal(t, `((rate(up[5m]))) * (2)`, decoded.GetQuery())
+	})
+
+	t.Run("parse errors refer to the original query", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithCanonicalQueries(true))
+		_, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/api/v1/query?time=180&query="+url.QueryEscape(`((up)) +`), nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1:9")
+	})
+}
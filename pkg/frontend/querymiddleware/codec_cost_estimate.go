@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// queryCostEstimateHeader is the header of the downstream metrics query requests holding the static cost estimate
+// of the query. See estimateQueryCost.
+const queryCostEstimateHeader = "X-Mimir-Query-Cost-Estimate"
+
+// WithQueryCostEstimateHeader controls whether the encoded metrics query requests include the X-Mimir-Query-Cost-Estimate
+// header, holding a cheap static estimate of the cost of the query, which downstream components can use for admission
+// and prioritization. The estimate doesn't change how the query is executed. Defaults to disabled.
+func WithQueryCostEstimateHeader(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.queryCostEstimateHeader = enabled
+	}
+}
+
+// setQueryCostEstimateHeader sets the header with the cost estimate of the input query on the request, if enabled.
+// The header isn't set if the query hasn't been parsed.
+func (c Codec) setQueryCostEstimateHeader(req *http.Request, in queryCostEstimateInput) {
+	if !c.queryCostEstimateHeader || in.expr == nil {
+		return
+	}
+	req.Header.Set(queryCostEstimateHeader, strconv.FormatUint(estimateQueryCost(in), 10))
+}
+
+// queryCostEstimateInput holds the properties of a metrics query which its cost is estimated from.
+type queryCostEstimateInput struct {
+	expr parser.Expr
+	// start, end and step of the query in milliseconds. The step is 0 for instant queries.
+	start, end, step int64
+	// minT and maxT are the min and max timestamps in milliseconds of the data queried.
+	minT, maxT int64
+}
+
+// estimateQueryCost returns a unitless static estimate of the cost of a query, growing with the number of series
+// selectors, the number of evaluation steps and the time range of the data queried (in hours, rounded up). It's
+// computed without accessing any data, so it only allows to compare queries relative to each other.
+func estimateQueryCost(in queryCostEstimateInput) uint64 {
+	selectors := uint64(0)
+	parser.Inspect(in.expr, func(node parser.Node, _ []parser.Node) error {
+		if _, ok := node.(*parser.VectorSelector); ok {
+			selectors++
+		}
+		return nil
+	})
+
+	steps := uint64(1)
+	if in.step > 0 && in.end > in.start {
+		steps += uint64((in.end - in.start) / in.step)
+	}
+
+	spanHours := uint64(1)
+	if in.maxT > in.minT {
+		spanHours = uint64(math.Ceil(float64(in.maxT-in.minT) / float64(hourMs)))
+	}
+
+	return saturatingMul(saturatingMul(selectors, steps), spanHours)
+}
+
+// hourMs is the number of milliseconds in an hour.
+const hourMs = int64(3600 * 1000)
+
+// saturatingMul returns a*b, or math.MaxUint64 if it overflows.
+func saturatingMul(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
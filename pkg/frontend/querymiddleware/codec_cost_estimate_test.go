@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_EncodeMetricsQueryRequest_QueryCostEstimateHeader(t *testing.T) {
+	for path, expected := range map[string]string{
+		// 1 selector, 1 step, 5m of data (lookback delta included) rounded up to 1h.
+		"/api/v1/query?query=foo&time=3600": "1",
+		// 2 selectors, 1 step, 2h of data.
+		"/api/v1/query?query=sum(rate(foo[2h]))/sum(rate(bar[1h]))&time=7200": "4",
+		// 1 selector, 61 steps, 1h5m of data rounded up to 2h.
+		"/api/v1/query_range?query=foo&start=3600&end=7200&step=60": "122",
+		// 2 selectors, 13 steps, 2h of data.
+		"/api/v1/query_range?query=foo+or+rate(bar[1h])&start=3600&end=7200&step=300": "52",
+	} {
+		t.Run(path, func(t *testing.T) {
+			for _, enabled := range []bool{false, true} {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil, WithQueryCostEstimateHeader(enabled))
+
+				decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, path, nil))
+				require.NoError(t, err)
+
+				encoded, err := codec.EncodeMetricsQueryRequest(user.InjectOrgID(context.Background(), "user-1"), decoded)
+				require.NoError(t, err)
+				if enabled {
+					assert.Equal(t, expected, encoded.Header.Get(queryCostEstimateHeader))
+				} else {
+					assert.Empty(t, encoded.Header.Values(queryCostEstimateHeader))
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateQueryCost(t *testing.T) {
+	expr, err := parser.ParseExpr("foo")
+	require.NoError(t, err)
+
+	// The time range of the data queried is accounted at least as 1h, even if empty.
+	assert.Equal(t, uint64(1), estimateQueryCost(queryCostEstimateInput{expr: expr}))
+
+	// The estimate saturates instead of overflowing.
+	assert.Equal(t, uint64(math.MaxUint64), estimateQueryCost(queryCostEstimateInput{expr: expr, start: 0, end: math.MaxInt64, step: 1, minT: math.MinInt64 / 2, maxT: math.MaxInt64 / 2}))
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// dataSourceSplitRequestHeader is the debug request header asking to return which fraction of the query result
+	// came from the ingesters and from the store-gateways. It's propagated to the queriers, which only annotate their
+	// responses when it's set.
+	dataSourceSplitRequestHeader = "X-Mimir-Debug-Data-Source-Split"
+
+	// dataSourceSplitHeader is the response header holding the fractions of the query result which came from the
+	// ingesters and from the store-gateways, when requested with the X-Mimir-Debug-Data-Source-Split header.
+	dataSourceSplitHeader = "X-Mimir-Data-Source-Split"
+
+	// dataSourceSplitAnnotationPrefix is the prefix of the info annotations added by the queriers to report the
+	// number of samples fetched from each data source, in the "ingesters=<samples>,store-gateways=<samples>" format.
+	dataSourceSplitAnnotationPrefix = "data source split: "
+
+	dataSourceIngesters     = "ingesters"
+	dataSourceStoreGateways = "store-gateways"
+)
+
+// dataSourceSplit is the number of samples of a query result fetched from each data source.
+type dataSourceSplit struct {
+	ingesters, storeGateways uint64
+}
+
+func (s dataSourceSplit) annotation() string {
+	return fmt.Sprintf("%s%s=%d,%s=%d", dataSourceSplitAnnotationPrefix, dataSourceIngesters, s.ingesters, dataSourceStoreGateways, s.storeGateways)
+}
+
+// headerValue returns the fraction of the samples fetched from each data source.
+func (s dataSourceSplit) headerValue() string {
+	var ingesters, storeGateways float64
+	if total := s.ingesters + s.storeGateways; total > 0 {
+		ingesters = float64(s.ingesters) / float64(total)
+		storeGateways = float64(s.storeGateways) / float64(total)
+	}
+	return fmt.Sprintf("%s=%s,%s=%s", dataSourceIngesters, strconv.FormatFloat(ingesters, 'f', -1, 64), dataSourceStoreGateways, strconv.FormatFloat(storeGateways, 'f', -1, 64))
+}
+
+// normalizeDataSourceSplitAnnotations returns the input infos with the annotations reporting the number of samples
+// fetched from each data source replaced by a single annotation summing them, so that the annotations of the
+// responses to split and sharded queries merge cheaply. The input infos are not modified.
+func normalizeDataSourceSplitAnnotations(infos []string) []string {
+	remaining, split := extractDataSourceSplit(infos)
+	if split == nil {
+		return infos
+	}
+	return append(remaining, split.annotation())
+}
+
+// extractDataSourceSplit returns the input infos without the annotations reporting the number of samples fetched
+// from each data source, and the sum of them, or nil if there's no such annotation. Unknown data sources and
+// malformed counts are ignored. The input infos are not modified.
+func extractDataSourceSplit(infos []string) (remaining []string, split *dataSourceSplit) {
+	for _, info := range infos {
+		if !strings.HasPrefix(info, dataSourceSplitAnnotationPrefix) {
+			remaining = append(remaining, info)
+			continue
+		}
+		if split == nil {
+			split = &dataSourceSplit{}
+		}
+		for _, s := range strings.Split(strings.TrimPrefix(info, dataSourceSplitAnnotationPrefix), ",") {
+			source, countStr, ok := strings.Cut(strings.TrimSpace(s), "=")
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseUint(countStr, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch source {
+			case dataSourceIngesters:
+				split.ingesters += count
+			case dataSourceStoreGateways:
+				split.storeGateways += count
+			}
+		}
+	}
+	if split == nil {
+		return infos, nil
+	}
+	return remaining, split
+}
+
+// isDataSourceSplitRequested returns whether the request asks to return which fraction of the query result came
+// from each data source.
+func isDataSourceSplitRequested(r *http.Request) bool {
+	requested, err := strconv.ParseBool(r.Header.Get(dataSourceSplitRequestHeader))
+	return err == nil && requested
+}
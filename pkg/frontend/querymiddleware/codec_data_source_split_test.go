@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_DataSourceSplit(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	body := `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["data source split: ingesters=10,store-gateways=20","some info","data source split: ingesters=5, store-gateways=15, unknown=100"]}`
+	httpResponse := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+	}
+
+	resp, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	promResp, ok := resp.GetPrometheusResponse()
+	require.True(t, ok)
+	assert.Equal(t, []string{"some info", "data source split: ingesters=15,store-gateways=35"}, promResp.Infos)
+}
+
+func TestCodec_EncodeMetricsQueryResponse_DataSourceSplitHeader(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	for name, tc := range map[string]struct {
+		infos          []string
+		requested      string
+		expectedHeader []string
+	}{
+		"not requested": {
+			infos: []string{"data source split: ingesters=1,store-gateways=3"},
+		},
+		"requested": {
+			infos:          []string{"data source split: ingesters=1,store-gateways=3"},
+			requested:      "true",
+			expectedHeader: []string{"ingesters=0.25,store-gateways=0.75"},
+		},
+		"requested, with no samples": {
+			infos:          []string{"data source split: ingesters=0,store-gateways=0"},
+			requested:      "true",
+			expectedHeader: []string{"ingesters=0,store-gateways=0"},
+		},
+		"requested with an invalid value": {
+			infos:     []string{"data source split: ingesters=1,store-gateways=3"},
+			requested: "yes",
+		},
+		"requested, but no annotation in the response": {
+			requested: "true",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := &PrometheusResponse{
+				Status: statusSuccess,
+				Data:   &PrometheusData{ResultType: "vector", Result: []SampleStream{}},
+				Infos:  append([]string{"some info"}, tc.infos...),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil)
+			req.Header.Set("Accept", jsonMimeType)
+			if tc.requested != "" {
+				req.Header.Set(dataSourceSplitRequestHeader, tc.requested)
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedHeader, encoded.Header.Values(dataSourceSplitHeader))
+
+			// The annotations are never returned in the response body.
+			body, err := io.ReadAll(encoded.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["some info"]}`, string(body))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// DeprecatedFunctionsModeReject rejects the queries using a deprecated function with a bad data error.
+	DeprecatedFunctionsModeReject = "reject"
+	// DeprecatedFunctionsModeWarn executes the queries using a deprecated function, adding a warning to their response.
+	DeprecatedFunctionsModeWarn = "warn"
+)
+
+// WithDeprecatedFunctions configures the PromQL functions which are deprecated, and how the metrics queries using
+// them are handled: with DeprecatedFunctionsModeReject the decoding of the request fails, with DeprecatedFunctionsModeWarn
+// the query is executed and a warning is added to the encoded response. Defaults to no deprecated functions.
+func WithDeprecatedFunctions(functions []string, mode string) CodecOption {
+	return func(c *Codec) {
+		c.deprecatedFunctions = make(map[string]struct{}, len(functions))
+		for _, name := range functions {
+			c.deprecatedFunctions[name] = struct{}{}
+		}
+		c.deprecatedFunctionsMode = mode
+	}
+}
+
+// containedDeprecatedFunctions returns the sorted names of the deprecated functions used in the query.
+func (c Codec) containedDeprecatedFunctions(expr parser.Expr) []string {
+	if len(c.deprecatedFunctions) == 0 {
+		return nil
+	}
+
+	found := map[string]struct{}{}
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if call, ok := node.(*parser.Call); ok {
+			if _, deprecated := c.deprecatedFunctions[call.Func.Name]; deprecated {
+				found[call.Func.Name] = struct{}{}
+			}
+		}
+		return nil
+	})
+	return slices.Sorted(maps.Keys(found))
+}
+
+// validateDeprecatedFunctions returns an error if the query uses a deprecated function and such queries are rejected.
+func (c Codec) validateDeprecatedFunctions(expr parser.Expr) error {
+	if c.deprecatedFunctionsMode != DeprecatedFunctionsModeReject {
+		return nil
+	}
+
+	if names := c.containedDeprecatedFunctions(expr); len(names) > 0 {
+		err := fmt.Errorf("the query uses deprecated functions: %s", strings.Join(names, ", "))
+		return apierror.New(apierror.TypeBadData, DecorateWithParamName(err, "query").Error())
+	}
+	return nil
+}
+
+// addDeprecatedFunctionsWarnings returns the response to the input metrics query request with a warning for each
+// deprecated function used in the query, if they're configured to be warned on. The query is parsed again, like the
+// fill parameter is, because the decoded request isn't available when encoding the response. The input response is
+// not modified.
+func (c Codec) addDeprecatedFunctionsWarnings(r *http.Request, resp *PrometheusResponse) (*PrometheusResponse, error) {
+	if c.deprecatedFunctionsMode != DeprecatedFunctionsModeWarn || len(c.deprecatedFunctions) == 0 || r.URL == nil {
+		return resp, nil
+	}
+
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	queryExpr, err := parser.ParseExpr(reqValues.Get("query"))
+	if err != nil {
+		return nil, DecorateWithParamName(err, "query")
+	}
+
+	names := c.containedDeprecatedFunctions(queryExpr)
+	if len(names) == 0 {
+		return resp, nil
+	}
+
+	warned := *resp
+	warned.Warnings = slices.Clone(resp.Warnings)
+	for _, name := range names {
+		warned.Warnings = append(warned.Warnings, fmt.Sprintf("PromQL function %q is deprecated and may be removed in a future release", name))
+	}
+	return &warned, nil
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestCodec_DeprecatedFunctions(t *testing.T) {
+	deprecated := []string{"resets", "label_replace"}
+
+	for name, tc := range map[string]struct {
+		mode             string
+		query            string
+		expectedErr      string
+		expectedWarnings []string
+	}{
+		"reject mode, no deprecated function": {
+			mode:  DeprecatedFunctionsModeReject,
+			query: `rate(foo[5m])`,
+		},
+		"reject mode, deprecated function": {
+			mode:        DeprecatedFunctionsModeReject,
+			query:       `sum(label_replace(resets(foo[5m]), "a", "b", "c", "d"))`,
+			expectedErr: "the query uses deprecated functions: label_replace, resets",
+		},
+		"warn mode, no deprecated function": {
+			mode:  DeprecatedFunctionsModeWarn,
+			query: `rate(foo[5m])`,
+		},
+		"warn mode, deprecated function": {
+			mode:  DeprecatedFunctionsModeWarn,
+			query: `label_replace(foo, "a", "b", "c", "d") + label_replace(bar, "a", "b", "c", "d")`,
+			expectedWarnings: []string{
+				"existing warning",
+				`PromQL function "label_replace" is deprecated and may be removed in a future release`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithDeprecatedFunctions(deprecated, tc.mode))
+
+			for _, path := range []string{
+				"/api/v1/query_range?start=0&end=60&step=60&query=" + url.QueryEscape(tc.query),
+				"/api/v1/query?time=60&query=" + url.QueryEscape(tc.query),
+			} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+				if tc.expectedErr != "" {
+					require.Error(t, err)
+					assert.True(t, apierror.IsAPIError(err))
+					assert.Contains(t, err.Error(), tc.expectedErr)
+					continue
+				}
+				require.NoError(t, err)
+
+				resp := &PrometheusResponse{
+					Status:   statusSuccess,
+					Data:     &PrometheusData{ResultType: model.ValMatrix.String(), Result: []SampleStream{}},
+					Warnings: []string{"existing warning"},
+				}
+				req.Header.Set("Accept", jsonMimeType)
+				encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+				require.NoError(t, err)
+
+				decoded, err := codec.DecodeMetricsQueryResponse(context.Background(), encoded, nil, nil)
+				require.NoError(t, err)
+				expectedWarnings := tc.expectedWarnings
+				if expectedWarnings == nil {
+					expectedWarnings = []string{"existing warning"}
+				}
+				assert.Equal(t, expectedWarnings, decoded.(*PrometheusResponse).Warnings)
+
+				// The input response is not modified.
+				assert.Equal(t, []string{"existing warning"}, resp.Warnings)
+			}
+		})
+	}
+}
+
+func TestCodec_DeprecatedFunctions_Disabled(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), time.Minute, formatJSON, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?time=60&query="+url.QueryEscape(`resets(foo[5m])`), nil)
+	_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+	require.NoError(t, err)
+}
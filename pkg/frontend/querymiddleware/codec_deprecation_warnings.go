@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// DeprecatedFeatureUnixTimeParams is used by the metrics queries whose start, end or time parameter is a Unix
+	// timestamp rather than an RFC3339 timestamp.
+	DeprecatedFeatureUnixTimeParams = "unix_time_params"
+	// DeprecatedFeatureJSONResponse is used by the metrics queries whose response is encoded as JSON, because the
+	// client doesn't accept protobuf.
+	DeprecatedFeatureJSONResponse = "json_response"
+)
+
+// deprecatedFeatures are the deprecated features which can be configured a warning with WithDeprecationWarnings.
+var deprecatedFeatures = []string{DeprecatedFeatureUnixTimeParams, DeprecatedFeatureJSONResponse}
+
+// validateDeprecationWarning returns an error if the input deprecated feature is unknown.
+func validateDeprecationWarning(feature, _ string) error {
+	if !slices.Contains(deprecatedFeatures, feature) {
+		return fmt.Errorf("unknown deprecated feature '%s'. Supported values: %v", feature, deprecatedFeatures)
+	}
+	return nil
+}
+
+// WithDeprecationWarnings configures the warning added to the responses to the metrics queries using each deprecated
+// feature, keyed by the feature, for example DeprecatedFeatureUnixTimeParams. It gives operators a channel to nudge
+// clients toward the supported features before removing the deprecated ones, without changing the response data.
+// Unknown features are ignored. Defaults to no warnings.
+func WithDeprecationWarnings(warnings map[string]string) CodecOption {
+	return func(c *Codec) {
+		c.deprecationWarnings = warnings
+	}
+}
+
+// addDeprecationWarnings returns the response to the input metrics query request, encoded with the input formatter,
+// with the configured warning for each deprecated feature used by the request. The input response is not modified.
+func (c Codec) addDeprecationWarnings(r *http.Request, f formatter, resp *PrometheusResponse) (*PrometheusResponse, error) {
+	if len(c.deprecationWarnings) == 0 || r.URL == nil {
+		return resp, nil
+	}
+
+	var warnings []string
+	if warning, ok := c.deprecationWarnings[DeprecatedFeatureUnixTimeParams]; ok {
+		reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+		if err != nil {
+			return nil, apierror.New(apierror.TypeBadData, err.Error())
+		}
+		if usesUnixTimeParams(reqValues.Get) {
+			warnings = append(warnings, warning)
+		}
+	}
+	if warning, ok := c.deprecationWarnings[DeprecatedFeatureJSONResponse]; ok && f.Name() == formatJSON {
+		warnings = append(warnings, warning)
+	}
+	if len(warnings) == 0 {
+		return resp, nil
+	}
+
+	warned := *resp
+	warned.Warnings = append(slices.Clone(resp.Warnings), warnings...)
+	return &warned, nil
+}
+
+// usesUnixTimeParams returns whether any of the start, end and time parameters is a Unix timestamp.
+func usesUnixTimeParams(get func(string) string) bool {
+	for _, name := range []string{"start", "end", "time"} {
+		if _, err := strconv.ParseFloat(get(name), 64); err == nil {
+			return true
+		}
+	}
+	return false
+}
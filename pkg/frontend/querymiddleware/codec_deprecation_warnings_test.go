@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_DeprecationWarnings(t *testing.T) {
+	const (
+		unixTimeWarning = "Unix timestamps are deprecated, use RFC3339 timestamps instead"
+		jsonWarning     = "JSON responses are deprecated, accept protobuf instead"
+	)
+
+	for name, tc := range map[string]struct {
+		warnings         map[string]string
+		path             string
+		accept           string
+		expectedWarnings []string
+	}{
+		"no warnings configured": {
+			path:   "/api/v1/query_range?query=up&start=0&end=60&step=60",
+			accept: jsonMimeType,
+		},
+		"range query with Unix timestamps": {
+			warnings: map[string]string{DeprecatedFeatureUnixTimeParams: unixTimeWarning},
+			path:     "/api/v1/query_range?query=up&start=0&end=60&step=60",
+			accept:   jsonMimeType,
+			expectedWarnings: []string{
+				unixTimeWarning,
+			},
+		},
+		"instant query with Unix timestamp": {
+			warnings:         map[string]string{DeprecatedFeatureUnixTimeParams: unixTimeWarning},
+			path:             "/api/v1/query?query=up&time=60.5",
+			accept:           jsonMimeType,
+			expectedWarnings: []string{unixTimeWarning},
+		},
+		"query with RFC3339 timestamps": {
+			warnings: map[string]string{DeprecatedFeatureUnixTimeParams: unixTimeWarning},
+			path:     "/api/v1/query_range?query=up&start=1970-01-01T00:00:00Z&end=1970-01-01T00:01:00Z&step=60",
+			accept:   jsonMimeType,
+		},
+		"JSON response": {
+			warnings:         map[string]string{DeprecatedFeatureUnixTimeParams: unixTimeWarning, DeprecatedFeatureJSONResponse: jsonWarning},
+			path:             "/api/v1/query?query=up&time=60",
+			accept:           jsonMimeType,
+			expectedWarnings: []string{unixTimeWarning, jsonWarning},
+		},
+		"protobuf response": {
+			warnings: map[string]string{DeprecatedFeatureJSONResponse: jsonWarning},
+			path:     "/api/v1/query?query=up",
+			accept:   mimirpb.QueryResponseMimeType,
+		},
+		"unknown feature": {
+			warnings: map[string]string{"unknown": "unknown feature"},
+			path:     "/api/v1/query?query=up&time=60",
+			accept:   jsonMimeType,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithDeprecationWarnings(tc.warnings))
+
+			resp := &PrometheusResponse{
+				Status:   statusSuccess,
+				Data:     &PrometheusData{ResultType: model.ValVector.String(), Result: []SampleStream{}},
+				Warnings: []string{"existing warning"},
+			}
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept", tc.accept)
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+			require.NoError(t, err)
+
+			decoded, err := codec.DecodeMetricsQueryResponse(context.Background(), encoded, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, append([]string{"existing warning"}, tc.expectedWarnings...), decoded.(*PrometheusResponse).Warnings)
+
+			// The input response is not modified.
+			assert.Equal(t, []string{"existing warning"}, resp.Warnings)
+		})
+	}
+}
+
+func TestValidateDeprecationWarning(t *testing.T) {
+	require.NoError(t, validateDeprecationWarning(DeprecatedFeatureUnixTimeParams, "Unix timestamps are deprecated"))
+	require.NoError(t, validateDeprecationWarning(DeprecatedFeatureJSONResponse, "JSON responses are deprecated"))
+	require.EqualError(t, validateDeprecationWarning("unknown", "deprecated"), "unknown deprecated feature 'unknown'. Supported values: [unix_time_params json_response]")
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+// WithEmptyResultAsNull controls whether an empty query result is serialized as null rather than
+// an empty array when encoding JSON responses. Defaults to false, which matches Prometheus.
+func WithEmptyResultAsNull(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.emptyResultAsNull = enabled
+	}
+}
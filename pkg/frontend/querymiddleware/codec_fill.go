@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// fillParam is the range query parameter selecting how the gaps in the series of the response are filled.
+	fillParam = "fill"
+
+	// fillNone leaves the gaps in the series unaltered. This is the default.
+	fillNone = "none"
+	// fillNull fills the gaps in the series with NaN samples.
+	fillNull = "null"
+	// fillLast fills the gaps in the series with the last known value of the series.
+	fillLast = "last"
+)
+
+var fillModes = []string{fillNone, fillNull, fillLast}
+
+// decodeFillParam returns the gap-filling mode requested in the input values, or an error if it's not supported.
+func decodeFillParam(values url.Values) (string, error) {
+	fill := values.Get(fillParam)
+	if fill == "" {
+		return fillNone, nil
+	}
+	if !slices.Contains(fillModes, fill) {
+		return "", apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter %q: unsupported value %q, supported values are: %s", fillParam, fill, strings.Join(fillModes, ", ")))
+	}
+	return fill, nil
+}
+
+// fillRangeQueryResponseGaps returns the response to the input range query request with the gaps in its series
+// filled as requested by the fill parameter. The expected samples are the ones at every step between the start and
+// end of the request. Gaps are filled after the response has been fully merged, so it doesn't affect the results
+// cache nor the queriers. The input response is not modified.
+func fillRangeQueryResponseGaps(r *http.Request, resp *PrometheusResponse) (*PrometheusResponse, error) {
+	if r.URL == nil || !IsRangeQuery(r.URL.Path) || resp.Data == nil || resp.Data.ResultType != model.ValMatrix.String() {
+		return resp, nil
+	}
+
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	fill, err := decodeFillParam(reqValues)
+	if err != nil || fill == fillNone {
+		return resp, err
+	}
+
+	start, end, step, err := DecodeRangeQueryTimeParams(&reqValues)
+	if err != nil {
+		return nil, err
+	}
+
+	filledData := *resp.Data
+	filledData.Result = make([]SampleStream, len(resp.Data.Result))
+	for i, series := range resp.Data.Result {
+		// Series with native histograms are left unaltered, to not mix float samples into them.
+		if len(series.Histograms) == 0 {
+			series.Samples = fillSeriesGaps(series.Samples, start, end, step, fill)
+		}
+		filledData.Result[i] = series
+	}
+
+	filled := *resp
+	filled.Data = &filledData
+	return &filled, nil
+}
+
+// fillSeriesGaps returns the input samples, sorted by timestamp, with a sample at every step between start and end
+// (both inclusive). Missing samples are filled according to the fill mode: with fillLast, the gaps before the first
+// sample of the series are not filled, since there's no last known value.
+func fillSeriesGaps(samples []mimirpb.Sample, start, end, step int64, fill string) []mimirpb.Sample {
+	filled := make([]mimirpb.Sample, 0, max(len(samples), int((end-start)/step)+1))
+
+	var (
+		idx     int
+		last    float64
+		hasLast bool
+	)
+	appendSample := func(s mimirpb.Sample) {
+		filled = append(filled, s)
+		last, hasLast = s.Value, true
+	}
+
+	for ts := start; ts <= end; ts += step {
+		// Keep the samples not aligned to the steps, if any.
+		for ; idx < len(samples) && samples[idx].TimestampMs < ts; idx++ {
+			appendSample(samples[idx])
+		}
+
+		if idx < len(samples) && samples[idx].TimestampMs == ts {
+			appendSample(samples[idx])
+			idx++
+			continue
+		}
+
+		switch {
+		case fill == fillNull:
+			filled = append(filled, mimirpb.Sample{TimestampMs: ts, Value: math.NaN()})
+		case fill == fillLast && hasLast:
+			filled = append(filled, mimirpb.Sample{TimestampMs: ts, Value: last})
+		}
+	}
+
+	return append(filled, samples[idx:]...)
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestFillSeriesGaps(t *testing.T) {
+	const (
+		start = int64(10_000)
+		end   = int64(50_000)
+		step  = int64(10_000)
+	)
+
+	samples := []mimirpb.Sample{
+		{TimestampMs: 20_000, Value: 2},
+		{TimestampMs: 40_000, Value: 4},
+	}
+
+	for name, tc := range map[string]struct {
+		fill     string
+		samples  []mimirpb.Sample
+		expected []mimirpb.Sample
+	}{
+		"fill=null": {
+			fill:    fillNull,
+			samples: samples,
+			expected: []mimirpb.Sample{
+				{TimestampMs: 10_000, Value: math.NaN()},
+				{TimestampMs: 20_000, Value: 2},
+				{TimestampMs: 30_000, Value: math.NaN()},
+				{TimestampMs: 40_000, Value: 4},
+				{TimestampMs: 50_000, Value: math.NaN()},
+			},
+		},
+		"fill=last": {
+			fill:    fillLast,
+			samples: samples,
+			expected: []mimirpb.Sample{
+				{TimestampMs: 20_000, Value: 2},
+				{TimestampMs: 30_000, Value: 2},
+				{TimestampMs: 40_000, Value: 4},
+				{TimestampMs: 50_000, Value: 4},
+			},
+		},
+		"fill=null with no samples": {
+			fill: fillNull,
+			expected: []mimirpb.Sample{
+				{TimestampMs: 10_000, Value: math.NaN()},
+				{TimestampMs: 20_000, Value: math.NaN()},
+				{TimestampMs: 30_000, Value: math.NaN()},
+				{TimestampMs: 40_000, Value: math.NaN()},
+				{TimestampMs: 50_000, Value: math.NaN()},
+			},
+		},
+		"fill=last keeps samples not aligned to the steps": {
+			fill:    fillLast,
+			samples: []mimirpb.Sample{{TimestampMs: 15_000, Value: 1}, {TimestampMs: 60_000, Value: 6}},
+			expected: []mimirpb.Sample{
+				{TimestampMs: 15_000, Value: 1},
+				{TimestampMs: 20_000, Value: 1},
+				{TimestampMs: 30_000, Value: 1},
+				{TimestampMs: 40_000, Value: 1},
+				{TimestampMs: 50_000, Value: 1},
+				{TimestampMs: 60_000, Value: 6},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actual := fillSeriesGaps(tc.samples, start, end, step, tc.fill)
+			requireEqualSamples(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_Fill(t *testing.T) {
+	codec := newTestCodec()
+
+	newResponse := func() *PrometheusResponse {
+		return &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result: []SampleStream{
+					{
+						Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}},
+						Samples: []mimirpb.Sample{{TimestampMs: 0, Value: 1}, {TimestampMs: 120_000, Value: 3}},
+					},
+					{
+						Labels:     []mimirpb.LabelAdapter{{Name: "__name__", Value: "bar"}},
+						Histograms: []mimirpb.FloatHistogramPair{{TimestampMs: 60_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}}},
+					},
+				},
+			},
+		}
+	}
+
+	encode := func(t *testing.T, path string) string {
+		resp := newResponse()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", jsonMimeType)
+
+		encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+		require.NoError(t, err)
+		body, err := io.ReadAll(encoded.Body)
+		require.NoError(t, err)
+		require.NoError(t, encoded.Body.Close())
+
+		// The input response is not modified.
+		assert.Equal(t, newResponse(), resp)
+		return string(body)
+	}
+
+	const (
+		fooGapless = `{"metric":{"__name__":"foo"},"values":[[0,"1"],[120,"3"]]}`
+		bar        = `{"metric":{"__name__":"bar"},"histograms":[[60,{"count":"1","sum":"1","buckets":[]}]]}`
+	)
+
+	for name, tc := range map[string]struct {
+		path     string
+		expected string
+	}{
+		"fill not set": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60",
+			expected: fooGapless,
+		},
+		"fill=none": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&fill=none",
+			expected: fooGapless,
+		},
+		"fill=null": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&fill=null",
+			expected: `{"metric":{"__name__":"foo"},"values":[[0,"1"],[60,"NaN"],[120,"3"],[180,"NaN"]]}`,
+		},
+		"fill=last": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&fill=last",
+			expected: `{"metric":{"__name__":"foo"},"values":[[0,"1"],[60,"1"],[120,"3"],[180,"3"]]}`,
+		},
+		"fill is ignored for instant queries": {
+			path:     "/api/v1/query?query=foo&time=180&fill=last",
+			expected: fooGapless,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.JSONEq(t, `{"status":"success","data":{"resultType":"matrix","result":[`+tc.expected+`,`+bar+`]}}`, encode(t, tc.path))
+		})
+	}
+}
+
+func TestCodec_DecodeMetricsQueryRequest_InvalidFill(t *testing.T) {
+	codec := newTestCodec()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=foo&start=0&end=180&step=60&fill=previous", nil)
+	_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+	require.Error(t, err)
+	assert.True(t, apierror.IsAPIError(err))
+	assert.Contains(t, err.Error(), `invalid parameter "fill"`)
+
+	for _, fill := range fillModes {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=foo&start=0&end=180&step=60&fill="+fill, nil)
+		_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+		require.NoError(t, err, fill)
+	}
+}
+
+// requireEqualSamples is like require.Equal, but considers NaN values equal.
+func requireEqualSamples(t *testing.T, expected, actual []mimirpb.Sample) {
+	t.Helper()
+
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].TimestampMs, actual[i].TimestampMs, "sample %d", i)
+		if math.IsNaN(expected[i].Value) {
+			require.True(t, math.IsNaN(actual[i].Value), "sample %d: expected NaN, got %v", i, actual[i].Value)
+			continue
+		}
+		require.Equal(t, expected[i].Value, actual[i].Value, "sample %d", i)
+	}
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// WithFormatterFallback enables the decoding of the metrics query responses with the other known formatters, in
+// order, when the decoding with the formatter matching the response content type fails. This recovers from
+// downstreams setting the wrong content type, at the cost of the additional decoding attempts when a response
+// is actually malformed. Defaults to false.
+func WithFormatterFallback(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.formatterFallback = enabled
+	}
+}
+
+// decodeWithFormatterFallback decodes buf with the input formatter and, if it fails and the fallback is enabled,
+// with the other formatters. It returns the decoded response and the formatter which succeeded, or the error of
+// the input formatter if all of them failed.
+func (c Codec) decodeWithFormatterFallback(f formatter, buf []byte, decode func(f formatter, buf []byte) (*PrometheusResponse, error), logger log.Logger) (*PrometheusResponse, formatter, error) {
+	resp, err := decode(f, buf)
+	if err == nil || !c.formatterFallback {
+		return resp, f, err
+	}
+
+	for _, fallback := range c.formatters {
+		if fallback.Name() == f.Name() {
+			continue
+		}
+
+		fallbackResp, fallbackErr := decode(fallback, buf)
+		if fallbackErr != nil {
+			continue
+		}
+
+		level.Warn(logger).Log("msg", "decoded query response with a formatter not matching its content type", "content_type_format", f.Name(), "decoded_format", fallback.Name(), "err", err)
+		c.metrics.formatMismatches.WithLabelValues(f.Name(), fallback.Name()).Inc()
+		return fallbackResp, fallback, nil
+	}
+
+	return nil, f, err
+}
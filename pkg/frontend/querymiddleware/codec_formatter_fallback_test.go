@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_FormatterFallback(t *testing.T) {
+	expected := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{{
+				Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "up"}},
+				Samples: []mimirpb.Sample{{TimestampMs: 60000, Value: 1}},
+			}},
+		},
+	}
+
+	jsonBody, err := jsonFormatter{}.EncodeQueryResponse(expected)
+	require.NoError(t, err)
+	protobufBody, err := protobufFormatter{}.EncodeQueryResponse(expected)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		body                []byte
+		contentType         string
+		expectedContentType string
+		expectedDecoded     string
+	}{
+		"protobuf labelled as JSON": {
+			body:                protobufBody,
+			contentType:         jsonMimeType,
+			expectedContentType: formatJSON,
+			expectedDecoded:     formatProtobuf,
+		},
+		"JSON labelled as protobuf": {
+			body:                jsonBody,
+			contentType:         mimirpb.QueryResponseMimeType,
+			expectedContentType: formatProtobuf,
+			expectedDecoded:     formatJSON,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			newResponse := func() *http.Response {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{tc.contentType}},
+					Body:       io.NopCloser(bytes.NewReader(tc.body)),
+				}
+			}
+			req := &PrometheusInstantQueryRequest{}
+
+			t.Run("fallback disabled", func(t *testing.T) {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+				_, err := codec.DecodeMetricsQueryResponse(context.Background(), newResponse(), req, log.NewNopLogger())
+				require.Error(t, err)
+			})
+
+			t.Run("fallback enabled", func(t *testing.T) {
+				reg := prometheus.NewPedanticRegistry()
+				codec := NewCodec(reg, 0, formatJSON, nil, WithFormatterFallback(true))
+
+				resp, err := codec.DecodeMetricsQueryResponse(context.Background(), newResponse(), req, log.NewNopLogger())
+				require.NoError(t, err)
+
+				promResp, ok := resp.GetPrometheusResponse()
+				require.True(t, ok)
+				assert.Equal(t, expected.Data, promResp.Data)
+
+				require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+					# HELP cortex_frontend_query_response_format_mismatches_total Total number of query responses decoded with a formatter not matching their content type.
+					# TYPE cortex_frontend_query_response_format_mismatches_total counter
+					cortex_frontend_query_response_format_mismatches_total{content_type_format="`+tc.expectedContentType+`",decoded_format="`+tc.expectedDecoded+`"} 1
+				`), "cortex_frontend_query_response_format_mismatches_total"))
+			})
+		})
+	}
+
+	t.Run("malformed response", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithFormatterFallback(true))
+
+		_, err := codec.DecodeMetricsQueryResponse(context.Background(), &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{jsonMimeType}},
+			Body:       io.NopCloser(strings.NewReader(`{"status":`)),
+		}, &PrometheusInstantQueryRequest{}, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
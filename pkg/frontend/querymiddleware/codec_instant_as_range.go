@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+// instantAsRangeQueryStep is the step, in milliseconds, of the range queries equivalent to instant queries. Their
+// start and end are the same, so the step doesn't change the result, but it must be positive and the start must be
+// aligned to it for the results to be cached.
+const instantAsRangeQueryStep = 1
+
+// WithInstantQueriesAsRangeQueries enables InstantQueryAsRangeQuery, which converts instant queries to equivalent
+// single-step range queries, so that they can be cached like range queries. Defaults to false.
+func WithInstantQueriesAsRangeQueries(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.instantQueriesAsRangeQueries = enabled
+	}
+}
+
+// InstantQueryAsRangeQuery returns the range query whose start and end are the time of the input instant query,
+// evaluated in a single step, and true, if enabled with WithInstantQueriesAsRangeQueries. The result of the range
+// query can be converted back to the result of the instant query with RangeQueryResponseAsInstantQueryResponse.
+// Only the instant queries returning an instant vector can be converted: false is returned for the others, for
+// example the queries returning a scalar, a string or a range vector.
+func (c Codec) InstantQueryAsRangeQuery(req *PrometheusInstantQueryRequest) (*PrometheusRangeQueryRequest, bool) {
+	if !c.instantQueriesAsRangeQueries || !isInstantQueryConvertibleToRangeQuery(req) {
+		return nil, false
+	}
+
+	rangeReq := NewPrometheusRangeQueryRequest(
+		strings.TrimSuffix(req.GetPath(), instantQueryPathSuffix)+queryRangePathSuffix,
+		req.GetHeaders(),
+		req.GetTime(),
+		req.GetTime(),
+		instantAsRangeQueryStep,
+		req.GetLookbackDelta(),
+		req.queryExpr,
+		req.GetOptions(),
+		req.GetHints(),
+		req.GetStats(),
+	)
+	rangeReq.id = req.GetID()
+	rangeReq.limit = req.GetLimit()
+	rangeReq.timeout = req.GetTimeout()
+	return rangeReq, true
+}
+
+// isInstantQueryConvertibleToRangeQuery returns whether the instant query has the same result as the single-step
+// range query at its time. Range queries always return a matrix, so only the instant queries returning an instant
+// vector can be converted, and back.
+func isInstantQueryConvertibleToRangeQuery(req *PrometheusInstantQueryRequest) bool {
+	return req.queryExpr != nil && req.queryExpr.Type() == parser.ValueTypeVector && strings.HasSuffix(req.GetPath(), instantQueryPathSuffix)
+}
+
+// RangeQueryResponseAsInstantQueryResponse converts the response to a range query returned by
+// InstantQueryAsRangeQuery to the response to the original instant query, collapsing the single step of each
+// series to an instant vector. An error is returned if the response isn't a matrix or has more than one step.
+func (c Codec) RangeQueryResponseAsInstantQueryResponse(resp Response) (Response, error) {
+	promResp, ok := resp.GetPrometheusResponse()
+	if !ok {
+		return nil, apierror.New(apierror.TypeInternal, "invalid response format")
+	}
+	if promResp.Data == nil || promResp.Data.ResultType != model.ValMatrix.String() {
+		return nil, apierror.New(apierror.TypeInternal, "the response to a range query equivalent to an instant query must be a matrix")
+	}
+
+	vector := make([]SampleStream, 0, len(promResp.Data.Result))
+	for _, series := range promResp.Data.Result {
+		switch points := len(series.Samples) + len(series.Histograms); {
+		case points == 0:
+			// Series without a point at the single step aren't part of the instant vector.
+			continue
+		case points > 1:
+			return nil, apierror.New(apierror.TypeInternal, fmt.Sprintf("the response to a range query equivalent to an instant query has %d points for a series, only one is expected", points))
+		}
+		vector = append(vector, series)
+	}
+
+	return &PrometheusResponse{
+		Status:   promResp.Status,
+		Data:     &PrometheusData{ResultType: model.ValVector.String(), Result: vector},
+		Headers:  promResp.Headers,
+		Warnings: promResp.Warnings,
+		Infos:    promResp.Infos,
+	}, nil
+}
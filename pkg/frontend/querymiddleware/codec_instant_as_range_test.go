@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_InstantQueryAsRangeQuery(t *testing.T) {
+	newInstantRequest := func(t *testing.T, query string) *PrometheusInstantQueryRequest {
+		expr, err := parser.ParseExpr(query)
+		require.NoError(t, err)
+		return NewPrometheusInstantQueryRequest("/prometheus/api/v1/query", nil, 60000, 5*time.Minute, expr, Options{}, nil, statsAll)
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+		_, ok := codec.InstantQueryAsRangeQuery(newInstantRequest(t, "up"))
+		assert.False(t, ok)
+	})
+
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithInstantQueriesAsRangeQueries(true))
+
+	for query, expectedOK := range map[string]bool{
+		`up`:                          true,
+		`sum by (job) (rate(up[5m]))`: true,
+		`up[5m]`:                      false,
+		`scalar(up)`:                  false,
+		`1`:                           false,
+		`"foo"`:                       false,
+	} {
+		t.Run(query, func(t *testing.T) {
+			instantReq := newInstantRequest(t, query)
+			instantReq.id = 3
+			instantReq.limit = 10
+			instantReq.timeout = time.Minute
+
+			rangeReq, ok := codec.InstantQueryAsRangeQuery(instantReq)
+			require.Equal(t, expectedOK, ok)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, "/prometheus/api/v1/query_range", rangeReq.GetPath())
+			assert.Equal(t, int64(60000), rangeReq.GetStart())
+			assert.Equal(t, int64(60000), rangeReq.GetEnd())
+			assert.Equal(t, int64(instantAsRangeQueryStep), rangeReq.GetStep())
+			assert.Equal(t, 5*time.Minute, rangeReq.GetLookbackDelta())
+			assert.Equal(t, instantReq.GetQuery(), rangeReq.GetQuery())
+			assert.Equal(t, statsAll, rangeReq.GetStats())
+			assert.Equal(t, int64(3), rangeReq.GetID())
+			assert.Equal(t, 10, rangeReq.GetLimit())
+			assert.Equal(t, time.Minute, rangeReq.GetTimeout())
+		})
+	}
+}
+
+func TestCodec_RangeQueryResponseAsInstantQueryResponse(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithInstantQueriesAsRangeQueries(true))
+
+	series := func(name string, samples ...mimirpb.Sample) SampleStream {
+		return SampleStream{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: name}}, Samples: samples}
+	}
+
+	t.Run("single step matrix", func(t *testing.T) {
+		resp, err := codec.RangeQueryResponseAsInstantQueryResponse(&PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result: []SampleStream{
+					series("a", mimirpb.Sample{TimestampMs: 60000, Value: 1}),
+					series("b"),
+					series("c", mimirpb.Sample{TimestampMs: 60000, Value: 3}),
+				},
+			},
+			Warnings: []string{"warning"},
+			Infos:    []string{"info"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValVector.String(),
+				Result: []SampleStream{
+					series("a", mimirpb.Sample{TimestampMs: 60000, Value: 1}),
+					series("c", mimirpb.Sample{TimestampMs: 60000, Value: 3}),
+				},
+			},
+			Warnings: []string{"warning"},
+			Infos:    []string{"info"},
+		}, resp)
+	})
+
+	t.Run("multiple steps", func(t *testing.T) {
+		_, err := codec.RangeQueryResponseAsInstantQueryResponse(&PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result:     []SampleStream{series("a", mimirpb.Sample{TimestampMs: 0, Value: 1}, mimirpb.Sample{TimestampMs: 1, Value: 2})},
+			},
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("not a matrix", func(t *testing.T) {
+		_, err := codec.RangeQueryResponseAsInstantQueryResponse(&PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: model.ValVector.String()},
+		})
+		require.Error(t, err)
+	})
+}
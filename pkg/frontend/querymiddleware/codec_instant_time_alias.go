@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/url"
+)
+
+// WithInstantQueryTimeParamAlias configures an alias of the instant query "time" parameter, read when the request
+// has no "time" parameter. When both are set, "time" wins. This is a compatibility shim for legacy clients sending
+// the evaluation time under a non-standard parameter name (e.g. "ts"), and shouldn't be relied upon otherwise.
+// Defaults to no alias.
+func WithInstantQueryTimeParamAlias(alias string) CodecOption {
+	return func(c *Codec) {
+		c.instantQueryTimeParamAlias = alias
+	}
+}
+
+// decodeInstantQueryTime decodes the instant query time, falling back to the parameter configured with
+// WithInstantQueryTimeParamAlias if the request has no "time" parameter.
+func (c Codec) decodeInstantQueryTime(reqValues *url.Values) (int64, error) {
+	alias := c.instantQueryTimeParamAlias
+	if alias == "" || reqValues.Get("time") != "" || reqValues.Get(alias) == "" {
+		time, err := DecodeInstantQueryTimeParams(reqValues)
+		if err != nil {
+			return 0, DecorateWithParamName(err, "time")
+		}
+		return time, nil
+	}
+
+	time, err := PromTimeParamDecoder{alias, RFC3339OrUnixMS, false, nil}.Decode(reqValues)
+	if err != nil {
+		return 0, DecorateWithParamName(err, alias)
+	}
+	return time, nil
+}
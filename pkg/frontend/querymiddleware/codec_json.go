@@ -6,24 +6,130 @@
 package querymiddleware
 
 import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"unsafe"
+
+	"github.com/prometheus/common/model"
 	v1 "github.com/prometheus/prometheus/web/api/v1"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
 )
 
-const jsonMimeType = "application/json"
+const (
+	jsonMimeType = "application/json"
+
+	// jsonTrailingMetadataParam is the content type parameter set by downstreams appending a metadata object,
+	// separated by a newline, after the JSON query response. For example: "application/json; metadata=trailing".
+	jsonTrailingMetadataParam = "metadata"
+	jsonTrailingMetadataValue = "trailing"
+
+	// jsonLayoutParam is the content type parameter selecting the layout of the series of a JSON matrix result.
+	// The columnar layout encodes each series as {"metric": {...}, "timestamps": [...], "values": [...]} instead of
+	// {"metric": {...}, "values": [[<timestamp>, <value>], ...]}. For example: "application/json; layout=columnar".
+	jsonLayoutParam      = "layout"
+	jsonLayoutColumnar   = "columnar"
+	jsonColumnarMimeType = jsonMimeType + "; " + jsonLayoutParam + "=" + jsonLayoutColumnar
+)
+
+type jsonFormatter struct {
+	// emptyResultAsNull controls whether an empty matrix or vector result is encoded as null instead of [].
+	emptyResultAsNull bool
+
+	// trailingMetadata controls whether decoded query responses may be followed by a metadata object.
+	trailingMetadata bool
+
+	// columnar controls whether the series of matrix results are encoded and decoded in the columnar layout.
+	columnar bool
+
+	// floats is the formatting of the float sample values of the encoded query responses.
+	floats jsonFloatFormatting
+}
 
-type jsonFormatter struct{}
+// jsonTrailingMetadata is the metadata object which may follow a JSON query response. Its warnings and infos
+// are added to the response ones. Other fields, such as stats, are ignored.
+type jsonTrailingMetadata struct {
+	Warnings []string `json:"warnings,omitempty"`
+	Infos    []string `json:"infos,omitempty"`
+}
 
 func (j jsonFormatter) EncodeQueryResponse(resp *PrometheusResponse) ([]byte, error) {
-	return json.Marshal(resp)
+	if resp.Data != nil && len(resp.Data.Result) == 0 && (resp.Data.ResultType == model.ValMatrix.String() || resp.Data.ResultType == model.ValVector.String()) {
+		// Do not modify the input response, because it may be shared (e.g. cached).
+		data := *resp.Data
+		if j.emptyResultAsNull {
+			data.Result = nil
+		} else {
+			data.Result = []SampleStream{}
+		}
+
+		copied := *resp
+		copied.Data = &data
+		resp = &copied
+	}
+
+	api := j.floats.api()
+	if j.columnar {
+		return api.Marshal(columnarPrometheusResponse{PrometheusResponse: resp, Data: (*columnarPrometheusData)(resp.Data)})
+	}
+
+	return api.Marshal(resp)
 }
 
 func (j jsonFormatter) DecodeQueryResponse(buf []byte) (*PrometheusResponse, error) {
+	if j.trailingMetadata {
+		return j.decodeQueryResponseWithTrailingMetadata(buf)
+	}
+
 	var resp PrometheusResponse
+	if err := j.unmarshalQueryResponse(func(v any) error { return json.Unmarshal(buf, v) }, &resp); err != nil {
+		return nil, err
+	}
 
-	if err := json.Unmarshal(buf, &resp); err != nil {
+	return &resp, nil
+}
+
+// unmarshalQueryResponse decodes a query response into resp with the input decode function, in the layout
+// configured for the formatter.
+func (j jsonFormatter) unmarshalQueryResponse(decode func(v any) error, resp *PrometheusResponse) error {
+	if !j.columnar {
+		return decode(resp)
+	}
+
+	columnar := columnarPrometheusResponse{PrometheusResponse: resp}
+	if err := decode(&columnar); err != nil {
+		return err
+	}
+	resp.Data = (*PrometheusData)(columnar.Data)
+	return nil
+}
+
+// decodeQueryResponseWithTrailingMetadata decodes the first JSON object in buf as the query response and, if
+// present, the second one as its jsonTrailingMetadata.
+func (j jsonFormatter) decodeQueryResponseWithTrailingMetadata(buf []byte) (*PrometheusResponse, error) {
+	var (
+		resp    PrometheusResponse
+		decoder = json.NewDecoder(bytes.NewReader(buf))
+	)
+
+	if err := j.unmarshalQueryResponse(decoder.Decode, &resp); err != nil {
 		return nil, err
 	}
+	if !decoder.More() {
+		return &resp, nil
+	}
 
+	var metadata jsonTrailingMetadata
+	if err := decoder.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("decoding trailing metadata: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected content after trailing metadata")
+	}
+
+	resp.Warnings = append(resp.Warnings, metadata.Warnings...)
+	resp.Infos = append(resp.Infos, metadata.Infos...)
 	return &resp, nil
 }
 
@@ -62,3 +168,127 @@ func (j jsonFormatter) Name() string {
 func (j jsonFormatter) ContentType() v1.MIMEType {
 	return v1.MIMEType{Type: "application", SubType: "json"}
 }
+
+// columnarPrometheusResponse is a PrometheusResponse whose matrix result is encoded in the columnar layout.
+type columnarPrometheusResponse struct {
+	*PrometheusResponse
+
+	// Data shadows the data of the embedded PrometheusResponse.
+	Data *columnarPrometheusData `json:"data,omitempty"`
+}
+
+// columnarPrometheusData is a PrometheusData whose matrix result is encoded in the columnar layout.
+// Other result types are encoded like PrometheusData ones.
+type columnarPrometheusData PrometheusData
+
+func (d *columnarPrometheusData) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Type   model.ValueType    `json:"resultType"`
+		Result stdjson.RawMessage `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Type != model.ValMatrix {
+		return (*PrometheusData)(d).UnmarshalJSON(b)
+	}
+
+	var css []columnarSampleStream
+	if err := json.Unmarshal(v.Result, &css); err != nil {
+		return err
+	}
+	d.ResultType = v.Type.String()
+	d.Result = fromColumnarSampleStreams(css)
+	return nil
+}
+
+func (d *columnarPrometheusData) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(d)
+}
+
+func (d *columnarPrometheusData) jsonValue() (any, error) {
+	if d == nil || d.ResultType != model.ValMatrix.String() {
+		return (*PrometheusData)(d).jsonValue()
+	}
+
+	return struct {
+		Type   model.ValueType        `json:"resultType"`
+		Result []columnarSampleStream `json:"result"`
+	}{
+		Type:   model.ValMatrix,
+		Result: asColumnarSampleStreams(d.Result),
+	}, nil
+}
+
+// asColumnarSampleStreams converts a slice of SampleStream into a slice of columnarSampleStream.
+// This can be done as columnarSampleStream is defined as a SampleStream.
+func asColumnarSampleStreams(ss []SampleStream) []columnarSampleStream {
+	return *(*[]columnarSampleStream)(unsafe.Pointer(&ss))
+}
+
+// fromColumnarSampleStreams is the inverse of asColumnarSampleStreams.
+func fromColumnarSampleStreams(css []columnarSampleStream) []SampleStream {
+	return *(*[]SampleStream)(unsafe.Pointer(&css))
+}
+
+// columnarSampleStream is a SampleStream encoded in the columnar layout: the timestamps and the values of the
+// float samples are encoded as two separate arrays of the same length. Native histograms are encoded like
+// SampleStream ones.
+type columnarSampleStream SampleStream
+
+func (cs *columnarSampleStream) UnmarshalJSON(b []byte) error {
+	var stream struct {
+		Metric     model.Metric                  `json:"metric"`
+		Timestamps []model.Time                  `json:"timestamps"`
+		Values     []jsonSampleValue             `json:"values"`
+		Histograms []mimirpb.SampleHistogramPair `json:"histograms"`
+	}
+	if err := json.Unmarshal(b, &stream); err != nil {
+		return err
+	}
+	if len(stream.Timestamps) != len(stream.Values) {
+		return fmt.Errorf("columnar sample stream has %d timestamps but %d values", len(stream.Timestamps), len(stream.Values))
+	}
+	if len(stream.Histograms) > 0 {
+		return fmt.Errorf("cannot unmarshal native histograms from JSON, but stream contains %d histograms", len(stream.Histograms))
+	}
+
+	*cs = columnarSampleStream{Labels: mimirpb.FromMetricsToLabelAdapters(stream.Metric)}
+	if len(stream.Timestamps) > 0 {
+		cs.Samples = make([]mimirpb.Sample, len(stream.Timestamps))
+		for i, ts := range stream.Timestamps {
+			cs.Samples[i] = mimirpb.Sample{TimestampMs: int64(ts), Value: float64(stream.Values[i])}
+		}
+	}
+	return nil
+}
+
+func (cs columnarSampleStream) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(cs)
+}
+
+func (cs columnarSampleStream) jsonValue() (any, error) {
+	var timestamps []model.Time
+	var values []model.SampleValue
+	if len(cs.Samples) > 0 {
+		timestamps = make([]model.Time, len(cs.Samples))
+		values = make([]model.SampleValue, len(cs.Samples))
+	}
+	for i, s := range cs.Samples {
+		timestamps[i] = model.Time(s.TimestampMs)
+		values[i] = model.SampleValue(s.Value)
+	}
+
+	return struct {
+		Metric     model.Metric                  `json:"metric"`
+		Timestamps []model.Time                  `json:"timestamps,omitempty"`
+		Values     []model.SampleValue           `json:"values,omitempty"`
+		Histograms []mimirpb.SampleHistogramPair `json:"histograms,omitempty"`
+	}{
+		Metric:     mimirpb.FromLabelAdaptersToMetric(cs.Labels),
+		Timestamps: timestamps,
+		Values:     values,
+		Histograms: toSampleHistogramPairs(cs.Histograms),
+	}, nil
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/modern-go/reflect2"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/util/jsonutil"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+const (
+	// JSONFloatFormatAuto encodes the float sample values of JSON query responses like Prometheus does:
+	// in decimal notation, unless the exponent is very small or very large.
+	JSONFloatFormatAuto = "auto"
+	// JSONFloatFormatDecimal encodes the float sample values of JSON query responses in decimal notation.
+	JSONFloatFormatDecimal = "decimal"
+	// JSONFloatFormatScientific encodes the float sample values of JSON query responses in scientific notation.
+	JSONFloatFormatScientific = "scientific"
+)
+
+// jsonFloatFormats are the supported notations of the float sample values of the encoded JSON query responses.
+var jsonFloatFormats = []string{JSONFloatFormatAuto, JSONFloatFormatDecimal, JSONFloatFormatScientific}
+
+// WithJSONFloatFormat configures the notation of the float sample values of the encoded JSON query responses: one of
+// JSONFloatFormatAuto, JSONFloatFormatDecimal or JSONFloatFormatScientific. Values are always encoded with the minimum
+// number of digits needed to decode them exactly, whatever the notation. Special values (NaN and infinities) aren't
+// affected. It allows to work around clients mis-parsing one of the notations. Defaults to JSONFloatFormatAuto.
+func WithJSONFloatFormat(format string) CodecOption {
+	return func(c *Codec) {
+		switch format {
+		case JSONFloatFormatDecimal:
+			c.jsonFloats.format = 'f'
+		case JSONFloatFormatScientific:
+			c.jsonFloats.format = 'e'
+		default:
+			c.jsonFloats.format = 0
+		}
+	}
+}
+
+// jsonFloatFormatting is the formatting of the float sample values of the encoded JSON query responses.
+type jsonFloatFormatting struct {
+	// format is the strconv.FormatFloat format of the values, or 0 to encode them like Prometheus does.
+	// See WithJSONFloatFormat.
+	format byte
+	// nonFinite is the representation of the NaN and infinite values, or "" to encode them like Prometheus does.
+	// See WithJSONNonFiniteFloats.
+	nonFinite string
+}
+
+// jsonFloatFormattingAPIs caches the jsoniter configurations of the non-default float formattings.
+var jsonFloatFormattingAPIs sync.Map // map[jsonFloatFormatting]jsoniter.API
+
+// api returns the jsoniter configuration encoding the float sample values with the formatting f. It's the package
+// json configuration, with an extension encoding the mimirpb.Sample, model.SamplePair and model.SampleValue values
+// with f.
+func (f jsonFloatFormatting) api() jsoniter.API {
+	if f == (jsonFloatFormatting{}) {
+		return json
+	}
+
+	if api, ok := jsonFloatFormattingAPIs.Load(f); ok {
+		return api.(jsoniter.API)
+	}
+
+	api := jsonConfig.Froze()
+	api.RegisterExtension(&jsonFloatFormattingExtension{formatting: f})
+	actual, _ := jsonFloatFormattingAPIs.LoadOrStore(f, api)
+	return actual.(jsoniter.API)
+}
+
+// writeFloat writes the float sample value v to the stream.
+func (f jsonFloatFormatting) writeFloat(v float64, stream *jsoniter.Stream) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		stream.SetBuffer(appendNonFiniteFloat(stream.Buffer(), v, f.nonFinite))
+		return
+	}
+
+	format := f.format
+	if format == 0 {
+		// Like Prometheus does, see jsonutil.MarshalFloat.
+		format = 'f'
+		if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	// The smallest precision needed to represent the value exactly is used, so that no precision is lost.
+	buf := append(stream.Buffer(), '"')
+	buf = strconv.AppendFloat(buf, v, format, -1, 64)
+	stream.SetBuffer(append(buf, '"'))
+}
+
+// jsonValuer is implemented by the query response types encoded as another value. Their MarshalJSON encode it with
+// the package json configuration, which would bypass the float formatting of the other configurations, so these
+// encode it themselves.
+type jsonValuer interface {
+	jsonValue() (any, error)
+}
+
+// marshalJSONValue encodes the value of v with the package json configuration.
+func marshalJSONValue(v jsonValuer) ([]byte, error) {
+	value, err := v.jsonValue()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+var (
+	jsonValuerType  = reflect.TypeOf((*jsonValuer)(nil)).Elem()
+	sampleType      = reflect.TypeOf(mimirpb.Sample{})
+	samplePairType  = reflect.TypeOf(model.SamplePair{})
+	sampleValueType = reflect.TypeOf(model.SampleValue(0))
+)
+
+// jsonFloatFormattingExtension is the jsoniter extension of the configuration returned by jsonFloatFormatting.api.
+type jsonFloatFormattingExtension struct {
+	jsoniter.DummyExtension
+
+	formatting jsonFloatFormatting
+}
+
+func (e *jsonFloatFormattingExtension) CreateEncoder(typ reflect2.Type) jsoniter.ValEncoder {
+	f := e.formatting
+
+	switch t := typ.Type1(); {
+	case t == sampleType:
+		return jsonEncoderFunc(func(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+			s := (*mimirpb.Sample)(ptr)
+			stream.WriteArrayStart()
+			jsonutil.MarshalTimestamp(s.TimestampMs, stream)
+			stream.WriteMore()
+			f.writeFloat(s.Value, stream)
+			stream.WriteArrayEnd()
+		})
+
+	case t == samplePairType:
+		return jsonEncoderFunc(func(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+			p := (*model.SamplePair)(ptr)
+			stream.WriteArrayStart()
+			stream.WriteRaw(p.Timestamp.String())
+			stream.WriteMore()
+			f.writeFloat(float64(p.Value), stream)
+			stream.WriteArrayEnd()
+		})
+
+	case t == sampleValueType:
+		return jsonEncoderFunc(func(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+			f.writeFloat(*(*float64)(ptr), stream)
+		})
+
+	case t.Implements(jsonValuerType):
+		return jsonValuerEncoder{typ: typ}
+
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(jsonValuerType):
+		// Like jsoniter does for the json.Marshaler implemented by the pointer type.
+		return jsonValuerEncoder{typ: reflect2.PtrTo(typ), addr: true}
+
+	default:
+		return nil
+	}
+}
+
+// jsonEncoderFunc is a jsoniter.ValEncoder of values never empty.
+type jsonEncoderFunc func(ptr unsafe.Pointer, stream *jsoniter.Stream)
+
+func (fn jsonEncoderFunc) Encode(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+	fn(ptr, stream)
+}
+
+func (fn jsonEncoderFunc) IsEmpty(unsafe.Pointer) bool {
+	return false
+}
+
+// jsonValuerEncoder is the jsoniter.ValEncoder of a jsonValuer type, encoding its value with the stream configuration.
+type jsonValuerEncoder struct {
+	typ reflect2.Type
+	// addr is true if typ is the pointer to the encoded type.
+	addr bool
+}
+
+func (e jsonValuerEncoder) Encode(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+	if e.addr {
+		elem := ptr
+		ptr = unsafe.Pointer(&elem)
+	}
+
+	value, err := e.typ.UnsafeIndirect(ptr).(jsonValuer).jsonValue()
+	if err != nil {
+		stream.Error = err
+		return
+	}
+	stream.WriteVal(value)
+}
+
+func (e jsonValuerEncoder) IsEmpty(ptr unsafe.Pointer) bool {
+	return !e.addr && e.typ.Kind() == reflect.Pointer && *(*unsafe.Pointer)(ptr) == nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// JSONNonFiniteFloatsPrometheus encodes the NaN and infinite float sample values of JSON query responses like
+	// Prometheus does: as the "NaN", "+Inf" and "-Inf" strings.
+	JSONNonFiniteFloatsPrometheus = "prometheus"
+	// JSONNonFiniteFloatsJavaScript encodes the NaN and infinite float sample values of JSON query responses as the
+	// "NaN", "Infinity" and "-Infinity" strings, which JavaScript's Number() parses.
+	JSONNonFiniteFloatsJavaScript = "javascript"
+	// JSONNonFiniteFloatsNull encodes the NaN and infinite float sample values of JSON query responses as null.
+	// The infinities can't be told apart from NaN once encoded, so they're decoded as NaN.
+	JSONNonFiniteFloatsNull = "null"
+)
+
+// jsonNonFiniteFloatsModes are the supported representations of the NaN and infinite float sample values of the
+// encoded JSON query responses.
+var jsonNonFiniteFloatsModes = []string{JSONNonFiniteFloatsPrometheus, JSONNonFiniteFloatsJavaScript, JSONNonFiniteFloatsNull}
+
+// WithJSONNonFiniteFloats configures the representation of the NaN and infinite float sample values of the encoded
+// JSON query responses, which standard JSON numbers can't represent: one of JSONNonFiniteFloatsPrometheus,
+// JSONNonFiniteFloatsJavaScript or JSONNonFiniteFloatsNull. It allows to match the expectations of clients not
+// parsing the Prometheus representation. Decoded JSON query responses are accepted in any of the representations.
+// Defaults to JSONNonFiniteFloatsPrometheus.
+func WithJSONNonFiniteFloats(mode string) CodecOption {
+	return func(c *Codec) {
+		switch mode {
+		case JSONNonFiniteFloatsJavaScript, JSONNonFiniteFloatsNull:
+			c.jsonFloats.nonFinite = mode
+		default:
+			c.jsonFloats.nonFinite = ""
+		}
+	}
+}
+
+// appendNonFiniteFloat appends the JSON representation of the input NaN or infinite value in the input mode,
+// one of the JSONNonFiniteFloats* constants, or "" for JSONNonFiniteFloatsPrometheus.
+func appendNonFiniteFloat(b []byte, v float64, mode string) []byte {
+	switch mode {
+	case JSONNonFiniteFloatsNull:
+		return append(b, "null"...)
+	case JSONNonFiniteFloatsJavaScript:
+		switch {
+		case math.IsInf(v, 1):
+			return append(b, `"Infinity"`...)
+		case math.IsInf(v, -1):
+			return append(b, `"-Infinity"`...)
+		default:
+			return append(b, `"NaN"`...)
+		}
+	default:
+		switch {
+		case math.IsInf(v, 1):
+			return append(b, `"+Inf"`...)
+		case math.IsInf(v, -1):
+			return append(b, `"-Inf"`...)
+		default:
+			return append(b, `"NaN"`...)
+		}
+	}
+}
+
+// jsonSampleValue is a float sample value decoded from a JSON string, like model.SampleValue, or from null, which is
+// decoded as NaN (see JSONNonFiniteFloatsNull).
+type jsonSampleValue float64
+
+func (v *jsonSampleValue) UnmarshalJSON(b []byte) error {
+	// The decoder decodes null raw messages as empty ones.
+	if len(b) == 0 || string(b) == "null" {
+		*v = jsonSampleValue(math.NaN())
+		return nil
+	}
+
+	var sv model.SampleValue
+	if err := sv.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	*v = jsonSampleValue(sv)
+	return nil
+}
+
+// jsonSamplePair is a [<timestamp>, <value>] float sample decoded from JSON, whose value is a jsonSampleValue.
+type jsonSamplePair struct {
+	Timestamp model.Time
+	Value     jsonSampleValue
+}
+
+func (p *jsonSamplePair) UnmarshalJSON(b []byte) error {
+	// The elements are decoded explicitly, because the decoder skips the Unmarshaler of null array elements.
+	var v []stdjson.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("sample should have 2 elements, got %d", len(v))
+	}
+	if err := p.Timestamp.UnmarshalJSON(v[0]); err != nil {
+		return err
+	}
+	return p.Value.UnmarshalJSON(v[1])
+}
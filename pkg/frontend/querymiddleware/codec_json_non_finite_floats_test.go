@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_JSONEncoding_NonFiniteFloats(t *testing.T) {
+	labels := []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}
+	samples := []mimirpb.Sample{{TimestampMs: 0, Value: 1.5}, {TimestampMs: 1000, Value: math.NaN()}, {TimestampMs: 2000, Value: math.Inf(1)}, {TimestampMs: 3000, Value: math.Inf(-1)}}
+
+	matrix := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValMatrix.String(),
+			Result:     []SampleStream{{Labels: labels, Samples: samples}},
+		},
+	}
+	vector := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "nan"}}, Samples: samples[1:2]},
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "pos"}}, Samples: samples[2:3]},
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "neg"}}, Samples: samples[3:4]},
+			},
+		},
+	}
+	scalar := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValScalar.String(),
+			Result:     []SampleStream{{Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: math.Inf(-1)}}}},
+		},
+	}
+
+	for _, tc := range []struct {
+		mode           string
+		accept         string
+		response       *PrometheusResponse
+		expectedResult string
+	}{
+		{
+			mode:           JSONNonFiniteFloatsPrometheus,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"1.5"],[1,"NaN"],[2,"+Inf"],[3,"-Inf"]]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsJavaScript,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"1.5"],[1,"NaN"],[2,"Infinity"],[3,"-Infinity"]]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsNull,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"1.5"],[1,null],[2,null],[3,null]]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsJavaScript,
+			accept:         "application/json; layout=columnar",
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"timestamps":[0,1,2,3],"values":["1.5","NaN","Infinity","-Infinity"]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsNull,
+			accept:         "application/json; layout=columnar",
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"timestamps":[0,1,2,3],"values":["1.5",null,null,null]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsPrometheus,
+			response:       vector,
+			expectedResult: `[{"metric":{"foo":"nan"},"value":[1,"NaN"]},{"metric":{"foo":"pos"},"value":[2,"+Inf"]},{"metric":{"foo":"neg"},"value":[3,"-Inf"]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsJavaScript,
+			response:       vector,
+			expectedResult: `[{"metric":{"foo":"nan"},"value":[1,"NaN"]},{"metric":{"foo":"pos"},"value":[2,"Infinity"]},{"metric":{"foo":"neg"},"value":[3,"-Infinity"]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsNull,
+			response:       vector,
+			expectedResult: `[{"metric":{"foo":"nan"},"value":[1,null]},{"metric":{"foo":"pos"},"value":[2,null]},{"metric":{"foo":"neg"},"value":[3,null]}]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsPrometheus,
+			response:       scalar,
+			expectedResult: `[1,"-Inf"]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsJavaScript,
+			response:       scalar,
+			expectedResult: `[1,"-Infinity"]`,
+		},
+		{
+			mode:           JSONNonFiniteFloatsNull,
+			response:       scalar,
+			expectedResult: `[1,null]`,
+		},
+	} {
+		t.Run(fmt.Sprintf("mode=%s, result=%s, accept=%q", tc.mode, tc.response.Data.ResultType, tc.accept), func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithJSONNonFiniteFloats(tc.mode))
+			httpRequest := &http.Request{
+				Header: http.Header{"Accept": []string{tc.accept}},
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), httpRequest, tc.response)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, encoded.StatusCode)
+
+			encodedJSON, err := readResponseBody(encoded)
+			require.NoError(t, err)
+			require.JSONEq(t, fmt.Sprintf(`{"status":"success","data":{"resultType":%q,"result":%s}}`, tc.response.Data.ResultType, tc.expectedResult), string(encodedJSON))
+
+			// The values must be decoded exactly, except the infinities encoded as null, which are decoded as NaN.
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{encoded.Header.Get("Content-Type")}},
+				Body:          io.NopCloser(bytes.NewBuffer(encodedJSON)),
+				ContentLength: int64(len(encodedJSON)),
+			}
+			decoded, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+			require.NoError(t, err)
+
+			actual := decoded.(*PrometheusResponse).Data.Result
+			require.Len(t, actual, len(tc.response.Data.Result))
+			for i, expected := range tc.response.Data.Result {
+				require.Len(t, actual[i].Samples, len(expected.Samples))
+				for j, s := range expected.Samples {
+					require.Equal(t, s.TimestampMs, actual[i].Samples[j].TimestampMs)
+					switch {
+					case math.IsNaN(s.Value), math.IsInf(s.Value, 0) && tc.mode == JSONNonFiniteFloatsNull:
+						require.True(t, math.IsNaN(actual[i].Samples[j].Value))
+					default:
+						require.Equal(t, s.Value, actual[i].Samples[j].Value)
+					}
+				}
+			}
+		})
+	}
+}
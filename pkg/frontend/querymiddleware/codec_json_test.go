@@ -9,9 +9,12 @@ package querymiddleware
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -252,6 +255,81 @@ func requireEqualHttpResponse(t *testing.T, expected, actual *http.Response) {
 	// No need to reset the bodies since they're typically not used after this comparison
 }
 
+func TestCodec_JSONResponse_TrailingMetadata(t *testing.T) {
+	const (
+		body     = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up"},"value":[1,"1"]}]},"warnings":["warning 1"]}`
+		metadata = `{"warnings":["warning 2"],"infos":["info 1"],"stats":{"samples":{"totalQueryableSamples":1}}}`
+	)
+
+	expectedResult := []SampleStream{{
+		Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "up"}},
+		Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+	}}
+
+	for _, tc := range []struct {
+		name             string
+		contentType      string
+		body             string
+		expectedWarnings []string
+		expectedInfos    []string
+		expectedErr      string
+	}{
+		{
+			name:             "single object",
+			contentType:      "application/json",
+			body:             body,
+			expectedWarnings: []string{"warning 1"},
+		},
+		{
+			name:             "single object with trailing metadata parameter",
+			contentType:      "application/json; metadata=trailing",
+			body:             body,
+			expectedWarnings: []string{"warning 1"},
+		},
+		{
+			name:             "dual object with trailing metadata parameter",
+			contentType:      "application/json; metadata=trailing",
+			body:             body + "\n" + metadata + "\n",
+			expectedWarnings: []string{"warning 1", "warning 2"},
+			expectedInfos:    []string{"info 1"},
+		},
+		{
+			name:        "dual object without trailing metadata parameter",
+			contentType: "application/json",
+			body:        body + "\n" + metadata,
+			expectedErr: "error decoding response",
+		},
+		{
+			name:        "more than two objects",
+			contentType: "application/json; metadata=trailing",
+			body:        body + "\n" + metadata + "\n" + metadata,
+			expectedErr: "unexpected content after trailing metadata",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil)
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{tc.contentType}},
+				Body:          io.NopCloser(bytes.NewBufferString(tc.body)),
+				ContentLength: int64(len(tc.body)),
+			}
+
+			resp, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			promResp := resp.(*PrometheusResponse)
+			require.Equal(t, expectedResult, promResp.Data.Result)
+			require.Equal(t, tc.expectedWarnings, promResp.Warnings)
+			require.Equal(t, tc.expectedInfos, promResp.Infos)
+		})
+	}
+}
+
 func TestCodec_JSONResponse_Labels(t *testing.T) {
 	headers := http.Header{"Content-Type": []string{"application/json"}}
 	expectedRespHeaders := []*PrometheusHeader{
@@ -508,6 +586,290 @@ func TestCodec_JSONEncoding_Metrics(t *testing.T) {
 	}
 }
 
+func TestCodec_JSONEncoding_EmptyResult(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		resultType        model.ValueType
+		result            []SampleStream
+		emptyResultAsNull bool
+		expectedJSON      string
+	}{
+		{
+			name:         "nil matrix result encoded as empty array by default",
+			resultType:   model.ValMatrix,
+			result:       nil,
+			expectedJSON: `{"status": "success", "data": {"resultType": "matrix", "result": []}}`,
+		},
+		{
+			name:         "empty vector result encoded as empty array by default",
+			resultType:   model.ValVector,
+			result:       []SampleStream{},
+			expectedJSON: `{"status": "success", "data": {"resultType": "vector", "result": []}}`,
+		},
+		{
+			name:              "empty matrix result encoded as null when enabled",
+			resultType:        model.ValMatrix,
+			result:            []SampleStream{},
+			emptyResultAsNull: true,
+			expectedJSON:      `{"status": "success", "data": {"resultType": "matrix", "result": null}}`,
+		},
+		{
+			name:              "nil vector result encoded as null when enabled",
+			resultType:        model.ValVector,
+			result:            nil,
+			emptyResultAsNull: true,
+			expectedJSON:      `{"status": "success", "data": {"resultType": "vector", "result": null}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithEmptyResultAsNull(tc.emptyResultAsNull))
+			httpRequest := &http.Request{
+				Header: http.Header{"Accept": []string{jsonMimeType}},
+			}
+			response := &PrometheusResponse{
+				Status: statusSuccess,
+				Data: &PrometheusData{
+					ResultType: tc.resultType.String(),
+					Result:     tc.result,
+				},
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), httpRequest, response)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, encoded.StatusCode)
+
+			encodedJSON, err := readResponseBody(encoded)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expectedJSON, string(encodedJSON))
+
+			// The input response must not be modified.
+			require.Equal(t, tc.result, response.Data.Result)
+		})
+	}
+}
+
+func TestCodec_JSONEncoding_ColumnarLayout(t *testing.T) {
+	matrix := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValMatrix.String(),
+			Result: []SampleStream{
+				{
+					Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+					Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}, {TimestampMs: 2000, Value: 2.5}},
+				},
+				{
+					Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "baz"}},
+					Samples: []mimirpb.Sample{{TimestampMs: 1500, Value: 3}},
+				},
+			},
+		},
+		Warnings: []string{"warning"},
+	}
+	vector := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{{
+				Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+				Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+			}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name                string
+		accept              string
+		response            *PrometheusResponse
+		expectedContentType string
+		expectedJSON        string
+	}{
+		{
+			name:                "matrix in row layout",
+			accept:              jsonMimeType,
+			response:            matrix,
+			expectedContentType: jsonMimeType,
+			expectedJSON:        `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2.5"]]},{"metric":{"foo":"baz"},"values":[[1.5,"3"]]}]},"warnings":["warning"]}`,
+		},
+		{
+			name:                "matrix in columnar layout",
+			accept:              "application/json; layout=columnar",
+			response:            matrix,
+			expectedContentType: jsonColumnarMimeType,
+			expectedJSON:        `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"timestamps":[1,2],"values":["1","2.5"]},{"metric":{"foo":"baz"},"timestamps":[1.5],"values":["3"]}]},"warnings":["warning"]}`,
+		},
+		{
+			name:                "vector in columnar layout",
+			accept:              "application/json; layout=columnar",
+			response:            vector,
+			expectedContentType: jsonColumnarMimeType,
+			expectedJSON:        `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"1"]}]}}`,
+		},
+		{
+			name:                "columnar layout not preferred by the client",
+			accept:              "application/json;q=0.9, application/json; layout=columnar;q=0.5",
+			response:            matrix,
+			expectedContentType: jsonMimeType,
+			expectedJSON:        `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2.5"]]},{"metric":{"foo":"baz"},"values":[[1.5,"3"]]}]},"warnings":["warning"]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil)
+			httpRequest := &http.Request{
+				Header: http.Header{"Accept": []string{tc.accept}},
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), httpRequest, tc.response)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, encoded.StatusCode)
+			require.Equal(t, tc.expectedContentType, encoded.Header.Get("Content-Type"))
+
+			encodedJSON, err := readResponseBody(encoded)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expectedJSON, string(encodedJSON))
+
+			// The response must round-trip in the selected layout.
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{tc.expectedContentType}},
+				Body:          io.NopCloser(bytes.NewBuffer(encodedJSON)),
+				ContentLength: int64(len(encodedJSON)),
+			}
+			decoded, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+			require.NoError(t, err)
+			promResp := decoded.(*PrometheusResponse)
+			require.Equal(t, tc.response.Data, promResp.Data)
+			require.Equal(t, tc.response.Warnings, promResp.Warnings)
+		})
+	}
+}
+
+func TestCodec_JSONResponse_ColumnarLayoutInvalid(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"timestamps":[1,2],"values":["1"]}]}}`
+
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil)
+	httpResponse := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": []string{jsonColumnarMimeType}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+	}
+
+	_, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+	require.ErrorContains(t, err, "columnar sample stream has 2 timestamps but 1 values")
+}
+
+func TestCodec_JSONEncoding_FloatFormat(t *testing.T) {
+	values := []float64{0, 123.456, -1e-10, 1.5e25, math.SmallestNonzeroFloat64, math.MaxFloat64, math.NaN(), math.Inf(1), math.Inf(-1)}
+	samples := make([]mimirpb.Sample, 0, len(values))
+	for i, v := range values {
+		samples = append(samples, mimirpb.Sample{TimestampMs: int64(i) * 1000, Value: v})
+	}
+
+	matrix := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValMatrix.String(),
+			Result:     []SampleStream{{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}, Samples: samples}},
+		},
+	}
+	vector := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}, Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1e-10}}},
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "baz"}}, Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1.5e25}}},
+			},
+		},
+	}
+	scalar := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValScalar.String(),
+			Result:     []SampleStream{{Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1e-10}}}},
+		},
+	}
+
+	for _, tc := range []struct {
+		format         string
+		accept         string
+		response       *PrometheusResponse
+		expectedResult string
+	}{
+		{
+			format:         JSONFloatFormatAuto,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"0"],[1,"123.456"],[2,"-1e-10"],[3,"1.5e+25"],[4,"5e-324"],[5,"1.7976931348623157e+308"],[6,"NaN"],[7,"+Inf"],[8,"-Inf"]]}]`,
+		},
+		{
+			format:         JSONFloatFormatScientific,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"0e+00"],[1,"1.23456e+02"],[2,"-1e-10"],[3,"1.5e+25"],[4,"5e-324"],[5,"1.7976931348623157e+308"],[6,"NaN"],[7,"+Inf"],[8,"-Inf"]]}]`,
+		},
+		{
+			format:         JSONFloatFormatDecimal,
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"values":[[0,"0"],[1,"123.456"],[2,"-0.0000000001"],[3,"15000000000000000000000000"],[4,"0.` + strings.Repeat("0", 323) + `5"],[5,"179769313486231570000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"],[6,"NaN"],[7,"+Inf"],[8,"-Inf"]]}]`,
+		},
+		{
+			format:         JSONFloatFormatScientific,
+			accept:         "application/json; layout=columnar",
+			response:       matrix,
+			expectedResult: `[{"metric":{"foo":"bar"},"timestamps":[0,1,2,3,4,5,6,7,8],"values":["0e+00","1.23456e+02","-1e-10","1.5e+25","5e-324","1.7976931348623157e+308","NaN","+Inf","-Inf"]}]`,
+		},
+		{
+			format:         JSONFloatFormatDecimal,
+			response:       vector,
+			expectedResult: `[{"metric":{"foo":"bar"},"value":[1,"0.0000000001"]},{"metric":{"foo":"baz"},"value":[1,"15000000000000000000000000"]}]`,
+		},
+		{
+			format:         JSONFloatFormatDecimal,
+			response:       scalar,
+			expectedResult: `[1,"0.0000000001"]`,
+		},
+	} {
+		t.Run(fmt.Sprintf("format=%s, result=%s, accept=%q", tc.format, tc.response.Data.ResultType, tc.accept), func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithJSONFloatFormat(tc.format))
+			httpRequest := &http.Request{
+				Header: http.Header{"Accept": []string{tc.accept}},
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), httpRequest, tc.response)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, encoded.StatusCode)
+
+			encodedJSON, err := readResponseBody(encoded)
+			require.NoError(t, err)
+			require.JSONEq(t, fmt.Sprintf(`{"status":"success","data":{"resultType":%q,"result":%s}}`, tc.response.Data.ResultType, tc.expectedResult), string(encodedJSON))
+
+			// The values must be decoded exactly, whatever the notation.
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{encoded.Header.Get("Content-Type")}},
+				Body:          io.NopCloser(bytes.NewBuffer(encodedJSON)),
+				ContentLength: int64(len(encodedJSON)),
+			}
+			decoded, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+			require.NoError(t, err)
+
+			actual := decoded.(*PrometheusResponse).Data.Result
+			require.Len(t, actual, len(tc.response.Data.Result))
+			for i, expected := range tc.response.Data.Result {
+				require.Len(t, actual[i].Samples, len(expected.Samples))
+				for j, s := range expected.Samples {
+					require.Equal(t, s.TimestampMs, actual[i].Samples[j].TimestampMs)
+					if math.IsNaN(s.Value) {
+						require.True(t, math.IsNaN(actual[i].Samples[j].Value))
+					} else {
+						require.Equal(t, s.Value, actual[i].Samples[j].Value)
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestCodec_JSONEncoding_Labels(t *testing.T) {
 	for _, tc := range []struct {
 		name             string
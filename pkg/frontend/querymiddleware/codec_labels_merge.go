@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// MergeLabelsResponses merges the responses to the shards of a label names or label values request into a single
+// Response. Label names or values are deduplicated and sorted, and truncated to the limit of the original request,
+// if any, adding the same warning as Prometheus when truncated. Warnings and infos are deduplicated.
+func (c Codec) MergeLabelsResponses(req LabelsSeriesQueryRequest, responses ...Response) (Response, error) {
+	values := map[string]struct{}{}
+	notes := newResponseNotes()
+
+	for _, res := range responses {
+		lr, ok := res.(*PrometheusLabelsResponse)
+		if !ok {
+			return nil, fmt.Errorf("error invalid response type: %T, expected a labels response", res)
+		}
+		if lr.Status != statusSuccess {
+			return nil, fmt.Errorf("can't merge an unsuccessful response")
+		}
+
+		for _, v := range lr.Data {
+			values[v] = struct{}{}
+		}
+		notes.add(lr.Warnings, lr.Infos)
+	}
+
+	data := make([]string, 0, len(values))
+	for v := range values {
+		data = append(data, v)
+	}
+	slices.Sort(data)
+
+	if limit := req.GetLimit(); limit > 0 && uint64(len(data)) > limit {
+		data = data[:limit]
+		notes.add([]string{limitTruncatedWarning}, nil)
+	}
+
+	warnings, infos := notes.sorted()
+	return &PrometheusLabelsResponse{
+		Status:   statusSuccess,
+		Data:     data,
+		Warnings: warnings,
+		Infos:    infos,
+	}, nil
+}
+
+// MergeSeriesResponses merges the responses to the shards of a series request into a single Response. Series are
+// deduplicated and sorted by labels, and truncated to the limit of the original request, if any, adding the same
+// warning as Prometheus when truncated. Warnings and infos are deduplicated.
+func (c Codec) MergeSeriesResponses(req LabelsSeriesQueryRequest, responses ...Response) (Response, error) {
+	seen := map[string]struct{}{}
+	var series []labels.Labels
+	notes := newResponseNotes()
+
+	for _, res := range responses {
+		sr, ok := res.(*PrometheusSeriesResponse)
+		if !ok {
+			return nil, fmt.Errorf("error invalid response type: %T, expected a series response", res)
+		}
+		if sr.Status != statusSuccess {
+			return nil, fmt.Errorf("can't merge an unsuccessful response")
+		}
+
+		for _, d := range sr.Data {
+			ls := labels.FromMap(d)
+			key := ls.String()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			series = append(series, ls)
+		}
+		notes.add(sr.Warnings, sr.Infos)
+	}
+
+	slices.SortFunc(series, labels.Compare)
+
+	if limit := req.GetLimit(); limit > 0 && uint64(len(series)) > limit {
+		series = series[:limit]
+		notes.add([]string{limitTruncatedWarning}, nil)
+	}
+
+	data := make([]SeriesData, 0, len(series))
+	for _, ls := range series {
+		data = append(data, ls.Map())
+	}
+
+	warnings, infos := notes.sorted()
+	return &PrometheusSeriesResponse{
+		Status:   statusSuccess,
+		Data:     data,
+		Warnings: warnings,
+		Infos:    infos,
+	}, nil
+}
+
+// responseNotes deduplicates the warnings and infos of merged responses.
+type responseNotes struct {
+	warnings map[string]struct{}
+	infos    map[string]struct{}
+}
+
+func newResponseNotes() responseNotes {
+	return responseNotes{warnings: map[string]struct{}{}, infos: map[string]struct{}{}}
+}
+
+func (n responseNotes) add(warnings, infos []string) {
+	for _, w := range warnings {
+		n.warnings[w] = struct{}{}
+	}
+	for _, i := range infos {
+		n.infos[i] = struct{}{}
+	}
+}
+
+// sorted returns the deduplicated warnings and infos, sorted, or nil if there are none.
+func (n responseNotes) sorted() (warnings, infos []string) {
+	return sortedKeys(n.warnings), sortedKeys(n.infos)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
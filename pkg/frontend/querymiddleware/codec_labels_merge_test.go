@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_MergeLabelsResponses(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	shards := []Response{
+		&PrometheusLabelsResponse{Status: statusSuccess, Data: []string{"c", "a"}, Warnings: []string{"warning 1"}},
+		&PrometheusLabelsResponse{Status: statusSuccess, Data: []string{"b", "c"}, Warnings: []string{"warning 1", "warning 2"}, Infos: []string{"info"}},
+		&PrometheusLabelsResponse{Status: statusSuccess, Data: []string{}},
+	}
+
+	for name, tc := range map[string]struct {
+		limit    uint64
+		expected *PrometheusLabelsResponse
+	}{
+		"no limit": {
+			expected: &PrometheusLabelsResponse{Status: statusSuccess, Data: []string{"a", "b", "c"}, Warnings: []string{"warning 1", "warning 2"}, Infos: []string{"info"}},
+		},
+		"limit not reached": {
+			limit:    3,
+			expected: &PrometheusLabelsResponse{Status: statusSuccess, Data: []string{"a", "b", "c"}, Warnings: []string{"warning 1", "warning 2"}, Infos: []string{"info"}},
+		},
+		"limit reached": {
+			limit:    2,
+			expected: &PrometheusLabelsResponse{Status: statusSuccess, Data: []string{"a", "b"}, Warnings: []string{limitTruncatedWarning, "warning 1", "warning 2"}, Infos: []string{"info"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			merged, err := codec.MergeLabelsResponses(&PrometheusLabelValuesQueryRequest{Limit: tc.limit}, shards...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, merged)
+		})
+	}
+
+	t.Run("no responses", func(t *testing.T) {
+		merged, err := codec.MergeLabelsResponses(&PrometheusLabelNamesQueryRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, &PrometheusLabelsResponse{Status: statusSuccess, Data: []string{}}, merged)
+	})
+
+	t.Run("unsuccessful response", func(t *testing.T) {
+		_, err := codec.MergeLabelsResponses(&PrometheusLabelNamesQueryRequest{}, shards[0], &PrometheusLabelsResponse{Status: statusError})
+		require.Error(t, err)
+	})
+
+	t.Run("series response", func(t *testing.T) {
+		_, err := codec.MergeLabelsResponses(&PrometheusLabelNamesQueryRequest{}, shards[0], &PrometheusSeriesResponse{Status: statusSuccess})
+		require.Error(t, err)
+	})
+}
+
+func TestCodec_MergeSeriesResponses(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	shards := []Response{
+		&PrometheusSeriesResponse{Status: statusSuccess, Data: []SeriesData{
+			{"__name__": "up", "job": "b"},
+			{"__name__": "up", "job": "a"},
+		}},
+		&PrometheusSeriesResponse{Status: statusSuccess, Data: []SeriesData{
+			{"__name__": "up", "job": "a"},
+			{"__name__": "go_goroutines", "job": "a"},
+		}, Infos: []string{"info"}},
+	}
+
+	for name, tc := range map[string]struct {
+		limit    uint64
+		expected *PrometheusSeriesResponse
+	}{
+		"no limit": {
+			expected: &PrometheusSeriesResponse{Status: statusSuccess, Data: []SeriesData{
+				{"__name__": "go_goroutines", "job": "a"},
+				{"__name__": "up", "job": "a"},
+				{"__name__": "up", "job": "b"},
+			}, Infos: []string{"info"}},
+		},
+		"limit reached": {
+			limit: 2,
+			expected: &PrometheusSeriesResponse{Status: statusSuccess, Data: []SeriesData{
+				{"__name__": "go_goroutines", "job": "a"},
+				{"__name__": "up", "job": "a"},
+			}, Warnings: []string{limitTruncatedWarning}, Infos: []string{"info"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			merged, err := codec.MergeSeriesResponses(&PrometheusSeriesQueryRequest{Limit: tc.limit}, shards...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, merged)
+		})
+	}
+
+	t.Run("unsuccessful response", func(t *testing.T) {
+		_, err := codec.MergeSeriesResponses(&PrometheusSeriesQueryRequest{}, shards[0], &PrometheusSeriesResponse{Status: statusError})
+		require.Error(t, err)
+	})
+
+	t.Run("labels response", func(t *testing.T) {
+		_, err := codec.MergeSeriesResponses(&PrometheusSeriesQueryRequest{}, shards[0], &PrometheusLabelsResponse{Status: statusSuccess})
+		require.Error(t, err)
+	})
+}
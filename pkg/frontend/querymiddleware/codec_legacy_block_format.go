@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"strings"
+)
+
+// WithLegacyBlockFormatInfo configures the info annotation added by queriers to the responses served from a legacy
+// block format. Decoded responses carrying the annotation are counted in cortex_frontend_legacy_block_responses_total,
+// and the encoded responses carrying it include the X-Mimir-Legacy-Block-Format header. The annotation itself is
+// passed through unaltered. Defaults to none, which disables the detection.
+func WithLegacyBlockFormatInfo(info string) CodecOption {
+	return func(c *Codec) {
+		c.legacyBlockFormatInfo = info
+	}
+}
+
+// hasLegacyBlockFormatInfo returns whether the input infos include the annotation configured with
+// WithLegacyBlockFormatInfo.
+func (c Codec) hasLegacyBlockFormatInfo(infos []string) bool {
+	if c.legacyBlockFormatInfo == "" {
+		return false
+	}
+	for _, info := range infos {
+		if strings.Contains(info, c.legacyBlockFormatInfo) {
+			return true
+		}
+	}
+	return false
+}
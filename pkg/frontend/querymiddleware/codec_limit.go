@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// limitParam is the query parameter capping the number of series returned by range and instant queries.
+	limitParam = "limit"
+
+	// limitTruncatedWarning is the warning added to the responses truncated because of the limit parameter.
+	// It's the same warning returned by Prometheus.
+	limitTruncatedWarning = "results truncated due to limit"
+)
+
+// decodeLimitParam returns the max number of series requested in the input values, 0 if unlimited, or an error
+// if it's not a non-negative integer.
+func decodeLimitParam(values url.Values) (int, error) {
+	s := values.Get(limitParam)
+	if s == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err == nil && limit < 0 {
+		err = errors.New("limit must be non-negative")
+	}
+	if err != nil {
+		return 0, apierror.New(apierror.TypeBadData, DecorateWithParamName(err, limitParam).Error())
+	}
+	return limit, nil
+}
+
+// truncateQueryResponseToLimit returns the response to the input metrics query request with its series truncated
+// to the limit parameter, and a warning added if any series has been removed. The response is truncated after it
+// has been fully merged, so that the results of the split and sharded queries are never truncated. The input response
+// is not modified.
+func truncateQueryResponseToLimit(r *http.Request, resp *PrometheusResponse) (*PrometheusResponse, error) {
+	if r.URL == nil || resp.Data == nil {
+		return resp, nil
+	}
+	if resultType := resp.Data.ResultType; resultType != model.ValMatrix.String() && resultType != model.ValVector.String() {
+		return resp, nil
+	}
+
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	limit, err := decodeLimitParam(reqValues)
+	if err != nil || limit == 0 || len(resp.Data.Result) <= limit {
+		return resp, err
+	}
+
+	truncatedData := *resp.Data
+	truncatedData.Result = resp.Data.Result[:limit:limit]
+
+	truncated := *resp
+	truncated.Data = &truncatedData
+	truncated.Warnings = append(slices.Clone(resp.Warnings), limitTruncatedWarning)
+	return &truncated, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_DecodeMetricsQueryRequest_Limit(t *testing.T) {
+	codec := newTestCodec()
+
+	for _, path := range []string{
+		"/api/v1/query_range?query=foo&start=0&end=180&step=60",
+		"/api/v1/query?query=foo&time=180",
+	} {
+		t.Run(path, func(t *testing.T) {
+			for limit, expected := range map[string]int{"": 0, "0": 0, "10": 10} {
+				req := httptest.NewRequest(http.MethodGet, path+"&limit="+limit, nil)
+				decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+				require.NoError(t, err)
+				assert.Equal(t, expected, decoded.(interface{ GetLimit() int }).GetLimit())
+
+				// The limit isn't sent downstream, so that the downstream and cached results are never truncated.
+				encoded, err := codec.EncodeMetricsQueryRequest(user.InjectOrgID(context.Background(), "user-1"), decoded)
+				require.NoError(t, err)
+				assert.False(t, encoded.URL.Query().Has("limit"))
+
+				// The limit isn't propagated to the requests derived with a different time range or query.
+				derived, err := decoded.WithStartEnd(decoded.GetStart(), decoded.GetEnd())
+				require.NoError(t, err)
+				assert.Equal(t, 0, derived.(interface{ GetLimit() int }).GetLimit())
+				derived, err = decoded.WithQuery("bar")
+				require.NoError(t, err)
+				assert.Equal(t, 0, derived.(interface{ GetLimit() int }).GetLimit())
+			}
+
+			for _, limit := range []string{"-1", "foo"} {
+				req := httptest.NewRequest(http.MethodGet, path+"&limit="+limit, nil)
+				_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+				require.Error(t, err)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.Contains(t, err.Error(), `invalid parameter "limit"`)
+			}
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_Limit(t *testing.T) {
+	codec := newTestCodec()
+
+	newResponse := func(resultType model.ValueType) *PrometheusResponse {
+		return &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: resultType.String(),
+				Result: []SampleStream{
+					{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: "a"}}, Samples: []mimirpb.Sample{{TimestampMs: 0, Value: 1}}},
+					{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: "b"}}, Samples: []mimirpb.Sample{{TimestampMs: 0, Value: 2}}},
+				},
+			},
+		}
+	}
+
+	encode := func(t *testing.T, path string, resultType model.ValueType) string {
+		resp := newResponse(resultType)
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", jsonMimeType)
+
+		encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+		require.NoError(t, err)
+		body, err := io.ReadAll(encoded.Body)
+		require.NoError(t, err)
+		require.NoError(t, encoded.Body.Close())
+
+		// The input response is not modified.
+		assert.Equal(t, newResponse(resultType), resp)
+		return string(body)
+	}
+
+	const (
+		matrixA = `{"metric":{"__name__":"a"},"values":[[0,"1"]]}`
+		matrixB = `{"metric":{"__name__":"b"},"values":[[0,"2"]]}`
+		vectorA = `{"metric":{"__name__":"a"},"value":[0,"1"]}`
+	)
+
+	for name, tc := range map[string]struct {
+		path       string
+		resultType model.ValueType
+		expected   string
+	}{
+		"range query, limit not set": {
+			path:       "/api/v1/query_range?query=foo&start=0&end=0&step=60",
+			resultType: model.ValMatrix,
+			expected:   `{"status":"success","data":{"resultType":"matrix","result":[` + matrixA + `,` + matrixB + `]}}`,
+		},
+		"range query, limit not exceeded": {
+			path:       "/api/v1/query_range?query=foo&start=0&end=0&step=60&limit=2",
+			resultType: model.ValMatrix,
+			expected:   `{"status":"success","data":{"resultType":"matrix","result":[` + matrixA + `,` + matrixB + `]}}`,
+		},
+		"range query, limit exceeded": {
+			path:       "/api/v1/query_range?query=foo&start=0&end=0&step=60&limit=1",
+			resultType: model.ValMatrix,
+			expected:   `{"status":"success","data":{"resultType":"matrix","result":[` + matrixA + `]},"warnings":["results truncated due to limit"]}`,
+		},
+		"instant query, limit exceeded": {
+			path:       "/api/v1/query?query=foo&time=0&limit=1",
+			resultType: model.ValVector,
+			expected:   `{"status":"success","data":{"resultType":"vector","result":[` + vectorA + `]},"warnings":["results truncated due to limit"]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.JSONEq(t, tc.expected, encode(t, tc.path, tc.resultType))
+		})
+	}
+}
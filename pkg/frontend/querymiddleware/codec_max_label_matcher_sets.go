@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+// WithMaxLabelMatcherSets configures the max number of match[] parameters of label names, label values and series
+// requests. Requests with more matcher sets are rejected, to protect the queriers from label and series queries
+// selecting the series with an excessive number of selectors. Defaults to 0, which disables it.
+func WithMaxLabelMatcherSets(maxSets int) CodecOption {
+	return func(c *Codec) {
+		c.maxLabelMatcherSets = maxSets
+	}
+}
+
+// validateLabelMatcherSets returns an error if the input label matcher sets exceed the max configured with
+// WithMaxLabelMatcherSets.
+func (c Codec) validateLabelMatcherSets(labelMatcherSets []string) error {
+	if c.maxLabelMatcherSets <= 0 || len(labelMatcherSets) <= c.maxLabelMatcherSets {
+		return nil
+	}
+	return apierror.New(apierror.TypeBadData, fmt.Sprintf("the request has %d match[] parameters, exceeding the max of %d", len(labelMatcherSets), c.maxLabelMatcherSets))
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestCodec_DecodeLabelsSeriesQueryRequest_MaxLabelMatcherSets(t *testing.T) {
+	matchers := func(n int) string {
+		params := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			params = append(params, "match[]={job=\"a\"}")
+		}
+		return strings.Join(params, "&")
+	}
+
+	for _, path := range []string{
+		"/api/v1/labels",
+		"/api/v1/label/job/values",
+		"/api/v1/series",
+	} {
+		t.Run(path, func(t *testing.T) {
+			t.Run("unlimited by default", func(t *testing.T) {
+				codec := newTestCodec()
+				req := httptest.NewRequest(http.MethodGet, path+"?"+matchers(100), nil)
+				decoded, err := codec.DecodeLabelsSeriesQueryRequest(context.Background(), req)
+				require.NoError(t, err)
+				assert.Len(t, decoded.GetLabelMatcherSets(), 100)
+			})
+
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithMaxLabelMatcherSets(2))
+
+			t.Run("within the limit", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, path+"?"+matchers(2), nil)
+				decoded, err := codec.DecodeLabelsSeriesQueryRequest(context.Background(), req)
+				require.NoError(t, err)
+				assert.Len(t, decoded.GetLabelMatcherSets(), 2)
+			})
+
+			t.Run("exceeding the limit", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, path+"?"+matchers(3), nil)
+				_, err := codec.DecodeLabelsSeriesQueryRequest(context.Background(), req)
+				require.Error(t, err)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.Contains(t, err.Error(), "the request has 3 match[] parameters, exceeding the max of 2")
+			})
+		})
+	}
+}
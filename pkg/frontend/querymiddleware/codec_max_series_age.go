@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// maxSeriesAgeParam is the query parameter of series requests asking to only return the series active within
+	// the given period before the end of the request.
+	maxSeriesAgeParam = "max_series_age"
+
+	// maxSeriesAgeHeader is the header carrying the max series age of series requests sent to queriers.
+	maxSeriesAgeHeader = "X-Mimir-Max-Series-Age"
+)
+
+// decodeMaxSeriesAge returns the max series age requested with the max_series_age parameter in the input values or,
+// if not set, with the X-Mimir-Max-Series-Age header, so that encoded series requests can be decoded again.
+// Returns 0 if not set, or an error if it's not a positive duration.
+func decodeMaxSeriesAge(values url.Values, header http.Header) (time.Duration, error) {
+	s := values.Get(maxSeriesAgeParam)
+	if s == "" {
+		s = header.Get(maxSeriesAgeHeader)
+	}
+	if s == "" {
+		return 0, nil
+	}
+
+	maxAgeMs, err := util.ParseDurationMS(s)
+	if err == nil && maxAgeMs <= 0 {
+		err = errors.New("max series age must be positive")
+	}
+	if err != nil {
+		return 0, apierror.New(apierror.TypeBadData, DecorateWithParamName(err, maxSeriesAgeParam).Error())
+	}
+	return time.Duration(maxAgeMs) * time.Millisecond, nil
+}
+
+// encodeMaxSeriesAge sets the X-Mimir-Max-Series-Age header of the input request, if the max series age is set.
+func encodeMaxSeriesAge(r *http.Request, maxAge time.Duration) {
+	if maxAge > 0 {
+		r.Header.Set(maxSeriesAgeHeader, encodeDurationMs(maxAge.Milliseconds()))
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestCodec_LabelsSeriesQueryRequest_MaxSeriesAge(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	for maxSeriesAge, expected := range map[string]struct {
+		decoded time.Duration
+		encoded string
+	}{
+		"":     {decoded: 0},
+		"300":  {decoded: 5 * time.Minute, encoded: "300"},
+		"1.5":  {decoded: 1500 * time.Millisecond, encoded: "1.5"},
+		"1h":   {decoded: time.Hour, encoded: "3600"},
+		"90m":  {decoded: 90 * time.Minute, encoded: "5400"},
+		"0.25": {decoded: 250 * time.Millisecond, encoded: "0.25"},
+	} {
+		t.Run(maxSeriesAge, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/series?match[]=up&start=0&end=3600&max_series_age="+maxSeriesAge, nil)
+			decoded, err := codec.DecodeLabelsSeriesQueryRequest(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, expected.decoded, decoded.(*PrometheusSeriesQueryRequest).GetMaxSeriesAge())
+
+			// The max series age is sent to queriers in a header.
+			encoded, err := codec.EncodeLabelsSeriesQueryRequest(ctx, decoded)
+			require.NoError(t, err)
+			assert.Equal(t, expected.encoded, encoded.Header.Get(maxSeriesAgeHeader))
+			assert.False(t, encoded.URL.Query().Has(maxSeriesAgeParam))
+
+			// The encoded request must round-trip.
+			roundTripped, err := codec.DecodeLabelsSeriesQueryRequest(ctx, encoded)
+			require.NoError(t, err)
+			assert.Equal(t, expected.decoded, roundTripped.(*PrometheusSeriesQueryRequest).GetMaxSeriesAge())
+		})
+	}
+
+	t.Run("the parameter takes precedence over the header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/series?match[]=up&max_series_age=1m", nil)
+		req.Header.Set(maxSeriesAgeHeader, "3600")
+		decoded, err := codec.DecodeLabelsSeriesQueryRequest(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, decoded.(*PrometheusSeriesQueryRequest).GetMaxSeriesAge())
+	})
+
+	t.Run("labels requests ignore the max series age", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/labels?max_series_age=1m", nil)
+		decoded, err := codec.DecodeLabelsSeriesQueryRequest(ctx, req)
+		require.NoError(t, err)
+
+		encoded, err := codec.EncodeLabelsSeriesQueryRequest(ctx, decoded)
+		require.NoError(t, err)
+		assert.Empty(t, encoded.Header.Get(maxSeriesAgeHeader))
+	})
+
+	for _, maxSeriesAge := range []string{"0", "-1m", "foo"} {
+		t.Run("invalid "+maxSeriesAge, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/series?match[]=up&max_series_age="+maxSeriesAge, nil)
+			_, err := codec.DecodeLabelsSeriesQueryRequest(ctx, req)
+			require.Error(t, err)
+			assert.True(t, apierror.IsAPIError(err))
+			assert.Contains(t, err.Error(), maxSeriesAgeParam)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"slices"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithMergedSeriesLimit controls whether the response merged from the responses of the split queries of a metrics
+// query request is truncated to the limit parameter of the request. The limit isn't propagated to the split queries,
+// so together they can return more series than the limit. When enabled and truncation occurs, the series with the
+// lowest label sets are kept, so the result is deterministic, and a warning is added. Defaults to disabled, in which
+// case the response is only truncated when it's encoded, keeping the first series in the merged order.
+func WithMergedSeriesLimit(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.mergedSeriesLimit = enabled
+	}
+}
+
+// MergeResponseForRequest merges the input responses to the split queries of the input request, like MergeResponse,
+// and truncates the merged series to the limit of the request if enabled with WithMergedSeriesLimit.
+func (c Codec) MergeResponseForRequest(req MetricsQueryRequest, responses ...Response) (Response, error) {
+	merged, err := c.MergeResponse(responses...)
+	if err != nil || !c.mergedSeriesLimit {
+		return merged, err
+	}
+
+	limitedReq, ok := req.(interface{ GetLimit() int })
+	if !ok || limitedReq.GetLimit() <= 0 {
+		return merged, nil
+	}
+
+	// The merged response has been built by MergeResponse, so it can be modified in place.
+	if resp, ok := merged.GetPrometheusResponse(); ok {
+		truncateMergedSeriesToLimit(resp, limitedReq.GetLimit())
+	}
+	return merged, nil
+}
+
+// truncateMergedSeriesToLimit truncates the series of the input response to the ones with the lowest limit label
+// sets, and adds a warning, if it has more series than the limit.
+func truncateMergedSeriesToLimit(resp *PrometheusResponse, limit int) {
+	if resp.Data == nil || len(resp.Data.Result) <= limit {
+		return
+	}
+
+	slices.SortFunc(resp.Data.Result, func(a, b SampleStream) int {
+		return mimirpb.CompareLabelAdapters(a.Labels, b.Labels)
+	})
+	resp.Data.Result = resp.Data.Result[:limit:limit]
+
+	if !slices.Contains(resp.Warnings, limitTruncatedWarning) {
+		resp.Warnings = append(resp.Warnings, limitTruncatedWarning)
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_MergeResponseForRequest_MergedSeriesLimit(t *testing.T) {
+	series := func(name string, ts int64) SampleStream {
+		return SampleStream{
+			Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: name}},
+			Samples: []mimirpb.Sample{{TimestampMs: ts, Value: 1}},
+		}
+	}
+
+	// The responses of two split queries, each one within the limit, but exceeding it together.
+	newResponses := func() []Response {
+		return []Response{
+			&PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: model.ValMatrix.String(), Result: []SampleStream{series("c", 0), series("d", 0)}}},
+			&PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: model.ValMatrix.String(), Result: []SampleStream{series("a", 60_000), series("c", 60_000)}}},
+		}
+	}
+
+	seriesNames := func(resp Response) []string {
+		pr, ok := resp.GetPrometheusResponse()
+		require.True(t, ok)
+
+		names := make([]string, 0, len(pr.Data.Result))
+		for _, s := range pr.Data.Result {
+			names = append(names, s.Labels[0].Value)
+		}
+		return names
+	}
+
+	for name, tc := range map[string]struct {
+		enabled          bool
+		path             string
+		expectedSeries   []string
+		expectedWarnings []string
+	}{
+		"disabled": {
+			enabled:        false,
+			path:           "/api/v1/query_range?query=foo&start=0&end=60&step=60&limit=2",
+			expectedSeries: []string{"a", "c", "d"},
+		},
+		"enabled, request without limit": {
+			enabled:        true,
+			path:           "/api/v1/query_range?query=foo&start=0&end=60&step=60",
+			expectedSeries: []string{"a", "c", "d"},
+		},
+		"enabled, merged series within the limit": {
+			enabled:        true,
+			path:           "/api/v1/query_range?query=foo&start=0&end=60&step=60&limit=3",
+			expectedSeries: []string{"a", "c", "d"},
+		},
+		"enabled, merged series exceeding the limit": {
+			enabled:          true,
+			path:             "/api/v1/query_range?query=foo&start=0&end=60&step=60&limit=2",
+			expectedSeries:   []string{"a", "c"},
+			expectedWarnings: []string{limitTruncatedWarning},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithMergedSeriesLimit(tc.enabled))
+			req, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.NoError(t, err)
+
+			merged, err := codec.MergeResponseForRequest(req, newResponses()...)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedSeries, seriesNames(merged))
+
+			pr, _ := merged.GetPrometheusResponse()
+			assert.Equal(t, tc.expectedWarnings, pr.Warnings)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// partialResponseControlHeader is the request header allowing the querier to return partial results,
+	// instead of failing the whole query, when some shards or stores fail.
+	partialResponseControlHeader = "X-Mimir-Allow-Partial-Response"
+
+	// partialResponseFailedHeader is the response header listing the shards or stores which failed,
+	// and whose results are missing from a partial response.
+	partialResponseFailedHeader = "X-Mimir-Partial-Response-Failed"
+)
+
+func decodeAllowPartialResponseOption(r *http.Request) bool {
+	allow := false
+	for _, value := range r.Header.Values(partialResponseControlHeader) {
+		if v, err := strconv.ParseBool(value); err == nil {
+			allow = v
+		}
+	}
+	return allow
+}
+
+// partialResponseFailures returns the shards or stores which failed, as listed in the headers of the response.
+// It returns nil if the response is not a partial response.
+func partialResponseFailures(h http.Header) []string {
+	var failed []string
+	for _, value := range h.Values(partialResponseFailedHeader) {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				failed = append(failed, name)
+			}
+		}
+	}
+	return failed
+}
+
+func partialResponseWarning(failed []string) string {
+	return fmt.Sprintf("partial response: the results of the following shards or stores are missing because they failed: %s", strings.Join(failed, ", "))
+}
+
+// isPartialResponse returns whether the response is missing the results of some shards or stores which failed.
+func isPartialResponse(r Response) bool {
+	for _, hv := range r.GetHeaders() {
+		if hv.GetName() == partialResponseFailedHeader {
+			return true
+		}
+	}
+	return false
+}
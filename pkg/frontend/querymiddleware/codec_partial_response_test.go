@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_PartialResponse(t *testing.T) {
+	codec := newTestCodec()
+
+	newHTTPResponse := func(header http.Header) *http.Response {
+		body := []byte(`{"status":"success","data":{"resultType":"vector","result":[]},"warnings":["existing warning"]}`)
+		header.Set("Content-Type", jsonMimeType)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewBuffer(body)),
+			ContentLength: int64(len(body)),
+		}
+	}
+
+	t.Run("complete response", func(t *testing.T) {
+		resp, err := codec.DecodeMetricsQueryResponse(context.Background(), newHTTPResponse(http.Header{}), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"existing warning"}, resp.(*PrometheusResponse).Warnings)
+		assert.True(t, isResponseCachable(resp))
+	})
+
+	t.Run("partial response", func(t *testing.T) {
+		header := http.Header{}
+		header.Add(partialResponseFailedHeader, "store-gateway-1, store-gateway-2")
+		header.Add(partialResponseFailedHeader, "shard-3")
+
+		resp, err := codec.DecodeMetricsQueryResponse(context.Background(), newHTTPResponse(header), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"existing warning",
+			"partial response: the results of the following shards or stores are missing because they failed: store-gateway-1, store-gateway-2, shard-3",
+		}, resp.(*PrometheusResponse).Warnings)
+		assert.False(t, isResponseCachable(resp))
+	})
+}
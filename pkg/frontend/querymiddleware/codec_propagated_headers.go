@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// WithPropagatedHeadersLimits configures the max number of allowed headers propagated from a request to its downstream
+// request, and the max number of values propagated for each of them, so that a client sending many values for an allowed
+// header can't bloat the downstream requests. The headers and values exceeding the limits are dropped, and a warning is
+// logged. A limit of 0 disables it. Defaults to 32 headers and 16 values per header.
+func WithPropagatedHeadersLimits(maxHeaders, maxValuesPerHeader int) CodecOption {
+	return func(c *Codec) {
+		c.maxPropagatedHeaders = maxHeaders
+		c.maxPropagatedHeaderValues = maxValuesPerHeader
+	}
+}
+
+// propagateHeaders adds the values of the allowed headers to the downstream request, within the limits
+// configured with WithPropagatedHeadersLimits.
+func (c Codec) propagateHeaders(ctx context.Context, req *http.Request, headers []*PrometheusHeader, allowed []string) {
+	propagated := 0
+	for _, h := range headers {
+		if !slices.Contains(allowed, h.Name) {
+			continue
+		}
+
+		if c.maxPropagatedHeaders > 0 && propagated >= c.maxPropagatedHeaders {
+			level.Warn(spanlogger.FromContext(ctx, c.logger)).Log("msg", "not propagating header because the max number of propagated headers has been reached", "header", h.Name, "limit", c.maxPropagatedHeaders)
+			continue
+		}
+		propagated++
+
+		values := h.Values
+		if c.maxPropagatedHeaderValues > 0 && len(values) > c.maxPropagatedHeaderValues {
+			level.Warn(spanlogger.FromContext(ctx, c.logger)).Log("msg", "truncating the values of propagated header because the max number of values per header has been reached", "header", h.Name, "values", len(values), "limit", c.maxPropagatedHeaderValues)
+			values = values[:c.maxPropagatedHeaderValues]
+		}
+
+		for _, v := range values {
+			// There should only be one value, but add all of them for completeness.
+			req.Header.Add(h.Name, v)
+		}
+	}
+}
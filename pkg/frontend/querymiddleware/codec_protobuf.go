@@ -8,6 +8,7 @@ import (
 
 	"github.com/prometheus/common/model"
 	v1 "github.com/prometheus/prometheus/web/api/v1"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 )
@@ -326,6 +327,279 @@ func (f protobufFormatter) decodeMatrixData(data *mimirpb.MatrixData) (*Promethe
 	}, nil
 }
 
+// Field numbers of the mimirpb.QueryResponse message and its nested messages, used when decoding
+// a response without decoding its samples.
+const (
+	queryResponseStatusField    protowire.Number = 1
+	queryResponseErrorTypeField protowire.Number = 2
+	queryResponseErrorField     protowire.Number = 3
+	queryResponseStringField    protowire.Number = 4
+	queryResponseVectorField    protowire.Number = 5
+	queryResponseScalarField    protowire.Number = 6
+	queryResponseMatrixField    protowire.Number = 7
+	queryResponseWarningsField  protowire.Number = 8
+	queryResponseInfosField     protowire.Number = 9
+
+	vectorDataSamplesField    protowire.Number = 1
+	vectorDataHistogramsField protowire.Number = 2
+	matrixDataSeriesField     protowire.Number = 1
+
+	// The metric is the first field of VectorSample, VectorHistogram and MatrixSeries.
+	seriesMetricField protowire.Number = 1
+)
+
+// DecodeQueryResponseMetadata decodes buf like DecodeQueryResponse, but skips the float and histogram
+// samples of vector and matrix results without decoding them: only the labels of each series are returned.
+func (f protobufFormatter) DecodeQueryResponseMetadata(buf []byte) (*PrometheusResponse, error) {
+	var (
+		resp      mimirpb.QueryResponse
+		dataField protowire.Number
+		dataBuf   []byte
+	)
+
+	err := forEachProtobufField(buf, func(field protobufField) error {
+		switch field.num {
+		case queryResponseStatusField:
+			if err := field.expectType(protowire.VarintType); err != nil {
+				return err
+			}
+			resp.Status = mimirpb.QueryStatus(field.varint)
+		case queryResponseErrorTypeField:
+			if err := field.expectType(protowire.VarintType); err != nil {
+				return err
+			}
+			resp.ErrorType = mimirpb.QueryErrorType(field.varint)
+		case queryResponseErrorField:
+			if err := field.expectType(protowire.BytesType); err != nil {
+				return err
+			}
+			resp.Error = string(field.bytes)
+		case queryResponseStringField, queryResponseVectorField, queryResponseScalarField, queryResponseMatrixField:
+			if err := field.expectType(protowire.BytesType); err != nil {
+				return err
+			}
+			// The data fields are part of a oneof: the last one wins.
+			dataField, dataBuf = field.num, field.bytes
+		case queryResponseWarningsField:
+			if err := field.expectType(protowire.BytesType); err != nil {
+				return err
+			}
+			resp.Warnings = append(resp.Warnings, string(field.bytes))
+		case queryResponseInfosField:
+			if err := field.expectType(protowire.BytesType); err != nil {
+				return err
+			}
+			resp.Infos = append(resp.Infos, string(field.bytes))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := resp.Status.ToPrometheusString()
+	if err != nil {
+		return nil, err
+	}
+
+	errorType, err := resp.ErrorType.ToPrometheusString()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.decodeDataMetadata(resp.Status, dataField, dataBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrometheusResponse{
+		Status:    status,
+		ErrorType: errorType,
+		Error:     resp.Error,
+		Data:      data,
+		Warnings:  resp.Warnings,
+		Infos:     resp.Infos,
+	}, nil
+}
+
+func (f protobufFormatter) decodeDataMetadata(status mimirpb.QueryStatus, dataField protowire.Number, buf []byte) (*PrometheusData, error) {
+	switch dataField {
+	case 0:
+		if status != mimirpb.QUERY_STATUS_SUCCESS {
+			return nil, nil
+		}
+
+		return nil, errors.New("received unexpected nil query response data")
+	case queryResponseStringField:
+		// String and scalar results have a single value, so there's nothing to gain by skipping it.
+		var data mimirpb.StringData
+		if err := data.Unmarshal(buf); err != nil {
+			return nil, err
+		}
+		return f.decodeStringData(&data), nil
+	case queryResponseScalarField:
+		var data mimirpb.ScalarData
+		if err := data.Unmarshal(buf); err != nil {
+			return nil, err
+		}
+		return f.decodeScalarData(&data), nil
+	case queryResponseVectorField:
+		return f.decodeVectorDataMetadata(buf)
+	case queryResponseMatrixField:
+		return f.decodeMatrixDataMetadata(buf)
+	default:
+		return nil, fmt.Errorf("unknown query response data field: %d", dataField)
+	}
+}
+
+func (f protobufFormatter) decodeVectorDataMetadata(buf []byte) (*PrometheusData, error) {
+	var floats, histograms []SampleStream
+
+	err := forEachProtobufField(buf, func(field protobufField) error {
+		if field.num != vectorDataSamplesField && field.num != vectorDataHistogramsField {
+			return nil
+		}
+
+		if err := field.expectType(protowire.BytesType); err != nil {
+			return err
+		}
+
+		l, err := decodeSeriesLabels(field.bytes)
+		if err != nil {
+			return err
+		}
+
+		// Float samples come before histograms, regardless of their order in the payload, like in decodeVectorData.
+		if field.num == vectorDataSamplesField {
+			floats = append(floats, SampleStream{Labels: l})
+		} else {
+			histograms = append(histograms, SampleStream{Labels: l})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]SampleStream, 0, len(floats)+len(histograms))
+	streams = append(streams, floats...)
+	streams = append(streams, histograms...)
+
+	return &PrometheusData{
+		ResultType: model.ValVector.String(),
+		Result:     streams,
+	}, nil
+}
+
+func (f protobufFormatter) decodeMatrixDataMetadata(buf []byte) (*PrometheusData, error) {
+	streams := []SampleStream{}
+
+	err := forEachProtobufField(buf, func(field protobufField) error {
+		if field.num != matrixDataSeriesField {
+			return nil
+		}
+
+		if err := field.expectType(protowire.BytesType); err != nil {
+			return err
+		}
+
+		l, err := decodeSeriesLabels(field.bytes)
+		if err != nil {
+			return err
+		}
+
+		streams = append(streams, SampleStream{Labels: l})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrometheusData{
+		ResultType: model.ValMatrix.String(),
+		Result:     streams,
+	}, nil
+}
+
+// decodeSeriesLabels decodes the labels of a protobuf-encoded VectorSample, VectorHistogram or MatrixSeries,
+// skipping all other fields.
+func decodeSeriesLabels(buf []byte) ([]mimirpb.LabelAdapter, error) {
+	var metric []string
+
+	err := forEachProtobufField(buf, func(field protobufField) error {
+		if field.num != seriesMetricField {
+			return nil
+		}
+
+		if err := field.expectType(protowire.BytesType); err != nil {
+			return err
+		}
+
+		metric = append(metric, string(field.bytes))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return labelsFromStringArray(metric)
+}
+
+// protobufField is a single field of a protobuf-encoded message.
+type protobufField struct {
+	num protowire.Number
+	typ protowire.Type
+
+	// varint is the value of the field if it's a varint.
+	varint uint64
+	// bytes is the value of the field if it's length-delimited. It references the encoded message.
+	bytes []byte
+}
+
+func (f protobufField) expectType(typ protowire.Type) error {
+	if f.typ != typ {
+		return fmt.Errorf("proto: wrong wireType = %d for field %d", f.typ, f.num)
+	}
+
+	return nil
+}
+
+// forEachProtobufField calls fn for each field of the protobuf-encoded message in buf, in the order they appear.
+// The values of varint and length-delimited fields are decoded, values of other types are skipped.
+func forEachProtobufField(buf []byte, fn func(field protobufField) error) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		field := protobufField{num: num, typ: typ}
+
+		switch typ {
+		case protowire.VarintType:
+			field.varint, n = protowire.ConsumeVarint(buf)
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(buf)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (f protobufFormatter) EncodeLabelsResponse(*PrometheusLabelsResponse) ([]byte, error) {
 	return nil, errors.New("protobuf labels encoding is not supported")
 }
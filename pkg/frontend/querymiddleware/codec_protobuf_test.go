@@ -5,6 +5,7 @@ package querymiddleware
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"testing"
@@ -666,6 +667,69 @@ func TestProtobufFormat_DecodeResponse(t *testing.T) {
 	}
 }
 
+func TestProtobufFormat_DecodeResponseMetadata(t *testing.T) {
+	headers := http.Header{"Content-Type": []string{mimirpb.QueryResponseMimeType}}
+
+	for _, tc := range protobufCodecScenarios {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatProtobuf, nil)
+
+			body, err := tc.payload.Marshal()
+			require.NoError(t, err)
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        headers,
+				Body:          io.NopCloser(bytes.NewBuffer(body)),
+				ContentLength: int64(len(body)),
+			}
+			decoded, err := codec.DecodeMetricsQueryResponseMetadata(context.Background(), httpResponse, nil, log.NewNopLogger())
+			if err != nil || tc.expectedDecodingError != nil {
+				require.Equal(t, tc.expectedDecodingError, err)
+				return
+			}
+
+			require.Equal(t, withoutVectorAndMatrixSamples(tc.response), decoded)
+		})
+	}
+}
+
+func TestCodec_DecodeMetricsQueryResponseMetadata_JSON(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil)
+	body := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"]]}]}}`
+
+	httpResponse := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+	}
+	decoded, err := codec.DecodeMetricsQueryResponseMetadata(context.Background(), httpResponse, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	resp, ok := decoded.GetPrometheusResponse()
+	require.True(t, ok)
+	require.Equal(t, &PrometheusData{
+		ResultType: model.ValMatrix.String(),
+		Result:     []SampleStream{{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}}},
+	}, resp.Data)
+}
+
+// withoutVectorAndMatrixSamples returns a copy of resp without the samples of vector and matrix results.
+func withoutVectorAndMatrixSamples(resp *PrometheusResponse) *PrometheusResponse {
+	if resp.Data == nil || (resp.Data.ResultType != model.ValVector.String() && resp.Data.ResultType != model.ValMatrix.String()) {
+		return resp
+	}
+
+	streams := make([]SampleStream, len(resp.Data.Result))
+	for i, s := range resp.Data.Result {
+		streams[i] = SampleStream{Labels: s.Labels}
+	}
+
+	stripped := *resp
+	stripped.Data = &PrometheusData{ResultType: resp.Data.ResultType, Result: streams}
+	return &stripped
+}
+
 func TestProtobufFormat_EncodeResponse(t *testing.T) {
 	for _, tc := range protobufCodecScenarios {
 		if tc.response == nil {
@@ -737,6 +801,58 @@ func BenchmarkProtobufFormat_DecodeResponse(b *testing.B) {
 	}
 }
 
+func BenchmarkProtobufFormat_DecodeResponseMetadata(b *testing.B) {
+	const (
+		numSeries           = 1000
+		numSamplesPerSeries = 1000
+	)
+
+	series := make([]mimirpb.MatrixSeries, numSeries)
+	for i := range series {
+		samples := make([]mimirpb.Sample, numSamplesPerSeries)
+		for j := range samples {
+			samples[j] = mimirpb.Sample{TimestampMs: int64(j) * 15_000, Value: float64(j)}
+		}
+
+		series[i] = mimirpb.MatrixSeries{
+			Metric:  []string{"__name__", "up", "instance", fmt.Sprintf("instance-%d", i), "job", "test"},
+			Samples: samples,
+		}
+	}
+
+	payload := mimirpb.QueryResponse{
+		Status: mimirpb.QUERY_STATUS_SUCCESS,
+		Data:   &mimirpb.QueryResponse_Matrix{Matrix: &mimirpb.MatrixData{Series: series}},
+	}
+	body, err := payload.Marshal()
+	require.NoError(b, err)
+
+	headers := http.Header{"Content-Type": []string{mimirpb.QueryResponseMimeType}}
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatProtobuf, nil)
+
+	for name, decode := range map[string]func(context.Context, *http.Response, MetricsQueryRequest, log.Logger) (Response, error){
+		"full":          codec.DecodeMetricsQueryResponse,
+		"metadata only": codec.DecodeMetricsQueryResponseMetadata,
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				httpResponse := &http.Response{
+					StatusCode:    200,
+					Header:        headers,
+					Body:          io.NopCloser(bytes.NewBuffer(body)),
+					ContentLength: int64(len(body)),
+				}
+
+				if _, err := decode(context.Background(), httpResponse, nil, log.NewNopLogger()); err != nil {
+					require.NoError(b, err)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkProtobufFormat_EncodeResponse(b *testing.B) {
 	reg := prometheus.NewPedanticRegistry()
 	codec := NewCodec(reg, 0*time.Minute, formatProtobuf, nil)
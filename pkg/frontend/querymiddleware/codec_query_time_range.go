@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// WithQueryTimeRangeHeaders controls whether the responses to metrics queries include the X-Mimir-Query-Min-T and
+// X-Mimir-Query-Max-T headers, holding the min and max time (in milliseconds) of the data queried by the originating
+// request, accounting for offsets, range selectors and the lookback delta. Defaults to false.
+func WithQueryTimeRangeHeaders(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.queryTimeRangeHeaders = enabled
+	}
+}
+
+// addQueryTimeRangeHeaders adds the headers holding the min and max time of the data queried by the request to
+// the response headers, if enabled with WithQueryTimeRangeHeaders.
+func (c Codec) addQueryTimeRangeHeaders(h http.Header, req MetricsQueryRequest) {
+	if !c.queryTimeRangeHeaders {
+		return
+	}
+
+	h.Set(queryMinTHeader, strconv.FormatInt(req.GetMinT(), 10))
+	h.Set(queryMaxTHeader, strconv.FormatInt(req.GetMaxT(), 10))
+}
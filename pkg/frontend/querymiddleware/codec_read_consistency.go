@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+
+	"github.com/grafana/mimir/pkg/querier/api"
+)
+
+// WithDefaultReadConsistency configures the read consistency level (one of api.ReadConsistencies) set on the
+// encoded metrics, labels and series requests when the context doesn't specify one. The level specified in the
+// context always takes precedence. Defaults to none, which leaves the level to the downstream default.
+func WithDefaultReadConsistency(level string) CodecOption {
+	return func(c *Codec) {
+		c.defaultReadConsistency = level
+	}
+}
+
+// readConsistencyLevel returns the read consistency level to set on requests encoded by the codec: the one in the
+// context if any, otherwise the one configured with WithDefaultReadConsistency.
+func (c Codec) readConsistencyLevel(ctx context.Context) (string, bool) {
+	if level, ok := api.ReadConsistencyLevelFromContext(ctx); ok {
+		return level, true
+	}
+	return c.defaultReadConsistency, c.defaultReadConsistency != ""
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// reduceParam is the range query parameter selecting how the series of the response are reduced.
+	reduceParam = "reduce"
+
+	// reduceNone leaves the series unaltered. This is the default.
+	reduceNone = "none"
+	// reduceLast reduces each series to its latest sample.
+	reduceLast = "last"
+)
+
+var reduceModes = []string{reduceNone, reduceLast}
+
+// decodeReduceParam returns the reduction mode requested in the input values, or an error if it's not supported.
+func decodeReduceParam(values url.Values) (string, error) {
+	reduce := values.Get(reduceParam)
+	if reduce == "" {
+		return reduceNone, nil
+	}
+	if !slices.Contains(reduceModes, reduce) {
+		return "", apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter %q: unsupported value %q, supported values are: %s", reduceParam, reduce, strings.Join(reduceModes, ", ")))
+	}
+	return reduce, nil
+}
+
+// reduceRangeQueryResponse returns the response to the input range query request with its series reduced as
+// requested by the reduce parameter, for the clients which only render the latest value of each series. Series are
+// reduced after the response has been fully merged, and after its gaps have been filled, so it doesn't affect the
+// results cache nor the queriers. The input response is not modified.
+func reduceRangeQueryResponse(r *http.Request, resp *PrometheusResponse) (*PrometheusResponse, error) {
+	if r.URL == nil || !IsRangeQuery(r.URL.Path) || resp.Data == nil || resp.Data.ResultType != model.ValMatrix.String() {
+		return resp, nil
+	}
+
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	reduce, err := decodeReduceParam(reqValues)
+	if err != nil || reduce == reduceNone {
+		return resp, err
+	}
+
+	reducedData := *resp.Data
+	reducedData.Result = make([]SampleStream, len(resp.Data.Result))
+	for i, series := range resp.Data.Result {
+		reducedData.Result[i] = reduceSeriesToLast(series)
+	}
+
+	reduced := *resp
+	reduced.Data = &reducedData
+	return &reduced, nil
+}
+
+// reduceSeriesToLast returns the input series with only its latest float or histogram sample. The samples are
+// expected to be sorted by timestamp. Series without samples are returned unaltered.
+func reduceSeriesToLast(series SampleStream) SampleStream {
+	lastFloat, lastHistogram := len(series.Samples)-1, len(series.Histograms)-1
+	switch {
+	case lastFloat < 0 && lastHistogram < 0:
+		return series
+	case lastHistogram < 0 || (lastFloat >= 0 && series.Samples[lastFloat].TimestampMs >= series.Histograms[lastHistogram].TimestampMs):
+		series.Samples = series.Samples[lastFloat:]
+		series.Histograms = nil
+	default:
+		series.Samples = nil
+		series.Histograms = series.Histograms[lastHistogram:]
+	}
+	return series
+}
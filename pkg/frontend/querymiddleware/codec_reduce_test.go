@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestReduceSeriesToLast(t *testing.T) {
+	labels := []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}}
+	histogram := func(ts int64) mimirpb.FloatHistogramPair {
+		return mimirpb.FloatHistogramPair{TimestampMs: ts, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: float64(ts)}}
+	}
+
+	for name, tc := range map[string]struct {
+		series   SampleStream
+		expected SampleStream
+	}{
+		"empty series": {
+			series:   SampleStream{Labels: labels},
+			expected: SampleStream{Labels: labels},
+		},
+		"single sample series": {
+			series:   SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 10, Value: 1}}},
+			expected: SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 10, Value: 1}}},
+		},
+		"float samples": {
+			series:   SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 10, Value: 1}, {TimestampMs: 20, Value: 2}, {TimestampMs: 30, Value: 3}}},
+			expected: SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 30, Value: 3}}},
+		},
+		"histogram samples": {
+			series:   SampleStream{Labels: labels, Histograms: []mimirpb.FloatHistogramPair{histogram(10), histogram(20)}},
+			expected: SampleStream{Labels: labels, Histograms: []mimirpb.FloatHistogramPair{histogram(20)}},
+		},
+		"float sample later than histogram samples": {
+			series:   SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 10, Value: 1}, {TimestampMs: 40, Value: 4}}, Histograms: []mimirpb.FloatHistogramPair{histogram(20), histogram(30)}},
+			expected: SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 40, Value: 4}}},
+		},
+		"histogram sample later than float samples": {
+			series:   SampleStream{Labels: labels, Samples: []mimirpb.Sample{{TimestampMs: 10, Value: 1}, {TimestampMs: 20, Value: 2}}, Histograms: []mimirpb.FloatHistogramPair{histogram(30)}},
+			expected: SampleStream{Labels: labels, Histograms: []mimirpb.FloatHistogramPair{histogram(30)}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, reduceSeriesToLast(tc.series))
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_Reduce(t *testing.T) {
+	codec := newTestCodec()
+
+	newResponse := func() *PrometheusResponse {
+		return &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result: []SampleStream{
+					{
+						Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}},
+						Samples: []mimirpb.Sample{{TimestampMs: 0, Value: 1}, {TimestampMs: 60_000, Value: 2}, {TimestampMs: 120_000, Value: 3}},
+					},
+					{
+						Labels:     []mimirpb.LabelAdapter{{Name: "__name__", Value: "bar"}},
+						Histograms: []mimirpb.FloatHistogramPair{{TimestampMs: 60_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}}, {TimestampMs: 120_000, Histogram: &mimirpb.FloatHistogram{Count: 2, Sum: 2}}},
+					},
+				},
+			},
+		}
+	}
+
+	encode := func(t *testing.T, path string) string {
+		resp := newResponse()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", jsonMimeType)
+
+		encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+		require.NoError(t, err)
+		body, err := io.ReadAll(encoded.Body)
+		require.NoError(t, err)
+		require.NoError(t, encoded.Body.Close())
+
+		// The input response is not modified.
+		assert.Equal(t, newResponse(), resp)
+		return string(body)
+	}
+
+	const (
+		unreduced = `{"metric":{"__name__":"foo"},"values":[[0,"1"],[60,"2"],[120,"3"]]},{"metric":{"__name__":"bar"},"histograms":[[60,{"count":"1","sum":"1","buckets":[]}],[120,{"count":"2","sum":"2","buckets":[]}]]}`
+		reduced   = `{"metric":{"__name__":"foo"},"values":[[120,"3"]]},{"metric":{"__name__":"bar"},"histograms":[[120,{"count":"2","sum":"2","buckets":[]}]]}`
+	)
+
+	for name, tc := range map[string]struct {
+		path     string
+		expected string
+	}{
+		"reduce not set": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60",
+			expected: unreduced,
+		},
+		"reduce=none": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&reduce=none",
+			expected: unreduced,
+		},
+		"reduce=last": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&reduce=last",
+			expected: reduced,
+		},
+		"reduce=last is applied after filling the gaps": {
+			path:     "/api/v1/query_range?query=foo&start=0&end=180&step=60&reduce=last&fill=last",
+			expected: `{"metric":{"__name__":"foo"},"values":[[180,"3"]]},{"metric":{"__name__":"bar"},"histograms":[[120,{"count":"2","sum":"2","buckets":[]}]]}`,
+		},
+		"reduce is ignored for instant queries": {
+			path:     "/api/v1/query?query=foo&time=180&reduce=last",
+			expected: unreduced,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.JSONEq(t, `{"status":"success","data":{"resultType":"matrix","result":[`+tc.expected+`]}}`, encode(t, tc.path))
+		})
+	}
+}
+
+func TestCodec_DecodeMetricsQueryRequest_InvalidReduce(t *testing.T) {
+	codec := newTestCodec()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=foo&start=0&end=180&step=60&reduce=first", nil)
+	_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+	require.Error(t, err)
+	assert.True(t, apierror.IsAPIError(err))
+	assert.Contains(t, err.Error(), `invalid parameter "reduce"`)
+
+	for _, reduce := range reduceModes {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=foo&start=0&end=180&step=60&reduce="+reduce, nil)
+		_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+		require.NoError(t, err, reduce)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// largeResponseInfoPrefix is the prefix of the info annotation added to the metrics query responses larger than the
+// threshold configured with WithResponseSizeWarnThreshold.
+const largeResponseInfoPrefix = "large response: "
+
+// WithResponseSizeWarnThreshold configures the size, in bytes, above which a decoded or encoded metrics query
+// response is annotated with an info and counted by the cortex_frontend_large_response_total metric. It gives
+// operators an early warning of the growing response sizes, before they hit hard limits. 0 disables it.
+// Defaults to 0.
+func WithResponseSizeWarnThreshold(bytes int) CodecOption {
+	return func(c *Codec) {
+		c.responseSizeWarnThreshold = bytes
+	}
+}
+
+// isLargeResponse returns whether a response of the input size, in bytes, exceeds the threshold configured with
+// WithResponseSizeWarnThreshold.
+func (c Codec) isLargeResponse(size int) bool {
+	return c.responseSizeWarnThreshold > 0 && size > c.responseSizeWarnThreshold
+}
+
+// largeResponseInfo returns the info annotation added to the responses exceeding the threshold. It doesn't include
+// the actual response size, so that the annotations of the responses to split and sharded queries are deduplicated
+// when merged.
+func (c Codec) largeResponseInfo() string {
+	return fmt.Sprintf("%sthe response size exceeds the warning threshold of %d bytes", largeResponseInfoPrefix, c.responseSizeWarnThreshold)
+}
+
+// addLargeResponseInfo returns the input response annotated with the large response info, if it's not already.
+// The input response is not modified.
+func (c Codec) addLargeResponseInfo(resp *PrometheusResponse) (*PrometheusResponse, bool) {
+	if slices.ContainsFunc(resp.Infos, func(info string) bool { return strings.HasPrefix(info, largeResponseInfoPrefix) }) {
+		return resp, false
+	}
+
+	annotated := *resp
+	annotated.Infos = append(slices.Clone(resp.Infos), c.largeResponseInfo())
+	return &annotated, true
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_ResponseSizeWarnThreshold(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["some info"]}`
+
+	for name, tc := range map[string]struct {
+		threshold     int
+		expectedInfos []string
+		expectedCount int
+	}{
+		"disabled": {
+			expectedInfos: []string{"some info"},
+		},
+		"below the threshold": {
+			threshold:     100,
+			expectedInfos: []string{"some info"},
+		},
+		"above the threshold": {
+			threshold:     50,
+			expectedInfos: []string{"some info", "large response: the response size exceeds the warning threshold of 50 bytes"},
+			expectedCount: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			codec := NewCodec(reg, 0, formatJSON, nil, WithResponseSizeWarnThreshold(tc.threshold))
+
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{"application/json"}},
+				Body:          io.NopCloser(bytes.NewBufferString(body)),
+				ContentLength: int64(len(body)),
+			}
+
+			resp, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+			require.NoError(t, err)
+
+			promResp, ok := resp.GetPrometheusResponse()
+			require.True(t, ok)
+			assert.Equal(t, tc.expectedInfos, promResp.Infos)
+			assert.Equal(t, float64(tc.expectedCount), testutil.ToFloat64(codec.metrics.largeResponses.WithLabelValues(operationDecode)))
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_ResponseSizeWarnThreshold(t *testing.T) {
+	const threshold = 100
+
+	for name, tc := range map[string]struct {
+		infos        []string
+		expectedBody string
+		expectLarge  bool
+	}{
+		"below the threshold": {
+			infos:        []string{"some info"},
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["some info"]}`,
+		},
+		"above the threshold": {
+			infos:        []string{strings.Repeat("x", threshold)},
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["` + strings.Repeat("x", threshold) + `","large response: the response size exceeds the warning threshold of 100 bytes"]}`,
+			expectLarge:  true,
+		},
+		"above the threshold, already annotated when decoded": {
+			infos:        []string{strings.Repeat("x", threshold), "large response: the response size exceeds the warning threshold of 100 bytes"},
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["` + strings.Repeat("x", threshold) + `","large response: the response size exceeds the warning threshold of 100 bytes"]}`,
+			expectLarge:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithResponseSizeWarnThreshold(threshold))
+
+			resp := &PrometheusResponse{
+				Status: statusSuccess,
+				Data:   &PrometheusData{ResultType: "vector", Result: []SampleStream{}},
+				Infos:  tc.infos,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil)
+			req.Header.Set("Accept", jsonMimeType)
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(encoded.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+			assert.Equal(t, int64(len(body)), encoded.ContentLength)
+
+			// The input response is not modified.
+			assert.Equal(t, tc.infos, resp.Infos)
+
+			expectedCount := 0.0
+			if tc.expectLarge {
+				expectedCount = 1
+			}
+			assert.Equal(t, expectedCount, testutil.ToFloat64(codec.metrics.largeResponses.WithLabelValues(operationEncode)))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+const (
+	// OutOfOrderSamplesModeReject fails the decoding of the responses with out-of-order samples with an internal error.
+	OutOfOrderSamplesModeReject = "reject"
+	// OutOfOrderSamplesModeRepair sorts the out-of-order samples of the decoded responses by timestamp.
+	OutOfOrderSamplesModeRepair = "repair"
+)
+
+// WithOutOfOrderSamplesMode configures how the metrics query responses whose series have samples not strictly sorted
+// by timestamp are decoded: with OutOfOrderSamplesModeReject the decoding fails, with OutOfOrderSamplesModeRepair
+// the samples are sorted by timestamp, keeping the first of the samples with the same timestamp. Responses are merged
+// assuming sorted samples, so out-of-order samples signal a bug downstream: the repair mode is meant to work around a
+// known issue only. Defaults to OutOfOrderSamplesModeReject.
+func WithOutOfOrderSamplesMode(mode string) CodecOption {
+	return func(c *Codec) {
+		c.outOfOrderSamplesMode = mode
+	}
+}
+
+// checkSamplesOrder checks that the float and histogram samples of each series in resp are strictly sorted by
+// timestamp. Depending on the configured mode, it returns an error describing the first out-of-order sample found,
+// or sorts the out-of-order samples.
+func (c Codec) checkSamplesOrder(resp *PrometheusResponse, logger log.Logger) error {
+	if resp.Data == nil {
+		return nil
+	}
+
+	repair := c.outOfOrderSamplesMode == OutOfOrderSamplesModeRepair
+	repaired := 0
+	for i := range resp.Data.Result {
+		series := &resp.Data.Result[i]
+
+		floatsIdx := firstOutOfOrder(series.Samples, sampleTimestamp)
+		histogramsIdx := firstOutOfOrder(series.Histograms, histogramTimestamp)
+		if floatsIdx < 0 && histogramsIdx < 0 {
+			continue
+		}
+
+		if !repair {
+			if floatsIdx >= 0 {
+				return fmt.Errorf("sample at timestamp %d of series %s is out of order, previous sample is at timestamp %d", series.Samples[floatsIdx].TimestampMs, mimirpb.FromLabelAdaptersToString(series.Labels), series.Samples[floatsIdx-1].TimestampMs)
+			}
+			return fmt.Errorf("histogram at timestamp %d of series %s is out of order, previous histogram is at timestamp %d", series.Histograms[histogramsIdx].TimestampMs, mimirpb.FromLabelAdaptersToString(series.Labels), series.Histograms[histogramsIdx-1].TimestampMs)
+		}
+
+		if floatsIdx >= 0 {
+			series.Samples = sortByTimestamp(series.Samples, sampleTimestamp)
+		}
+		if histogramsIdx >= 0 {
+			series.Histograms = sortByTimestamp(series.Histograms, histogramTimestamp)
+		}
+		repaired++
+	}
+
+	if repaired > 0 {
+		level.Warn(logger).Log("msg", "repaired series with out-of-order samples in query response", "series", repaired)
+	}
+	return nil
+}
+
+func sampleTimestamp(s mimirpb.Sample) int64                { return s.TimestampMs }
+func histogramTimestamp(h mimirpb.FloatHistogramPair) int64 { return h.TimestampMs }
+
+// firstOutOfOrder returns the index of the first sample whose timestamp isn't greater than the previous one, or -1
+// if the samples are strictly sorted by timestamp.
+func firstOutOfOrder[S any](samples []S, timestamp func(S) int64) int {
+	for i := 1; i < len(samples); i++ {
+		if timestamp(samples[i]) <= timestamp(samples[i-1]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortByTimestamp sorts the samples by timestamp, keeping the first of the samples with the same timestamp.
+func sortByTimestamp[S any](samples []S, timestamp func(S) int64) []S {
+	slices.SortStableFunc(samples, func(a, b S) int {
+		return cmp.Compare(timestamp(a), timestamp(b))
+	})
+	return slices.CompactFunc(samples, func(a, b S) bool {
+		return timestamp(a) == timestamp(b)
+	})
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_OutOfOrderSamples(t *testing.T) {
+	// Native histograms can't be decoded from JSON, so use protobuf for them.
+	histogramPayload := mimirpb.QueryResponse{
+		Status: mimirpb.QUERY_STATUS_SUCCESS,
+		Data: &mimirpb.QueryResponse_Matrix{Matrix: &mimirpb.MatrixData{Series: []mimirpb.MatrixSeries{{
+			Metric: []string{"foo", "bar"},
+			Histograms: []mimirpb.FloatHistogramPair{
+				{TimestampMs: 20_000, Histogram: &mimirpb.FloatHistogram{Count: 2, Sum: 2}},
+				{TimestampMs: 10_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}},
+			},
+		}}}},
+	}
+	histogramBody, err := histogramPayload.Marshal()
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		body        string
+		contentType string
+
+		expectedRejectError string
+		expectedSamples     []mimirpb.Sample
+		expectedHistograms  []mimirpb.FloatHistogramPair
+	}{
+		"sorted samples": {
+			body:            `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[10,"1"],[20,"2"],[30,"3"]]}]}}`,
+			expectedSamples: []mimirpb.Sample{{TimestampMs: 10_000, Value: 1}, {TimestampMs: 20_000, Value: 2}, {TimestampMs: 30_000, Value: 3}},
+		},
+		"out-of-order float samples": {
+			body:                `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[10,"1"],[30,"3"],[20,"2"]]}]}}`,
+			expectedRejectError: `invalid response: sample at timestamp 20000 of series {foo="bar"} is out of order, previous sample is at timestamp 30000`,
+			expectedSamples:     []mimirpb.Sample{{TimestampMs: 10_000, Value: 1}, {TimestampMs: 20_000, Value: 2}, {TimestampMs: 30_000, Value: 3}},
+		},
+		"float samples with the same timestamp": {
+			body:                `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[20,"2"],[10,"1"],[20,"4"]]}]}}`,
+			expectedRejectError: `invalid response: sample at timestamp 10000 of series {foo="bar"} is out of order, previous sample is at timestamp 20000`,
+			expectedSamples:     []mimirpb.Sample{{TimestampMs: 10_000, Value: 1}, {TimestampMs: 20_000, Value: 2}},
+		},
+		"out-of-order histogram samples": {
+			body:                string(histogramBody),
+			contentType:         mimirpb.QueryResponseMimeType,
+			expectedRejectError: `invalid response: histogram at timestamp 10000 of series {foo="bar"} is out of order, previous histogram is at timestamp 20000`,
+			expectedHistograms: []mimirpb.FloatHistogramPair{
+				{TimestampMs: 10_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}},
+				{TimestampMs: 20_000, Histogram: &mimirpb.FloatHistogram{Count: 2, Sum: 2}},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, mode := range []string{"", OutOfOrderSamplesModeReject, OutOfOrderSamplesModeRepair} {
+				t.Run("mode="+mode, func(t *testing.T) {
+					codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithOutOfOrderSamplesMode(mode))
+
+					contentType := tc.contentType
+					if contentType == "" {
+						contentType = "application/json"
+					}
+					httpResponse := &http.Response{
+						StatusCode:    200,
+						Header:        http.Header{"Content-Type": []string{contentType}},
+						Body:          io.NopCloser(bytes.NewBufferString(tc.body)),
+						ContentLength: int64(len(tc.body)),
+					}
+
+					resp, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+					if mode != OutOfOrderSamplesModeRepair && tc.expectedRejectError != "" {
+						require.EqualError(t, err, tc.expectedRejectError)
+						return
+					}
+					require.NoError(t, err)
+
+					promResp, ok := resp.GetPrometheusResponse()
+					require.True(t, ok)
+					require.Len(t, promResp.Data.Result, 1)
+					assert.Equal(t, tc.expectedSamples, promResp.Data.Result[0].Samples)
+					assert.Equal(t, tc.expectedHistograms, promResp.Data.Result[0].Histograms)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// ExtractSelectors returns the distinct vector selectors referenced by the query of the input request, in the order
+// they appear in the query. Each selector is serialized as its set of label matchers sorted by label name, including
+// the metric name matcher and without any range, offset or @ modifier, so that the same series selected with different
+// modifiers or notations are returned once. Selectors in subqueries and function arguments are included.
+func (c Codec) ExtractSelectors(req MetricsQueryRequest) ([]string, error) {
+	expr, err := parser.ParseExpr(req.GetQuery())
+	if err != nil {
+		return nil, DecorateWithParamName(err, "query")
+	}
+
+	return extractSelectors(expr), nil
+}
+
+// extractSelectors returns the distinct vector selectors of the input expression, serialized as their label matchers.
+func extractSelectors(expr parser.Expr) []string {
+	var selectors []string
+	seen := map[string]struct{}{}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		selector := serializeMatchers(vs.LabelMatchers)
+		if _, ok := seen[selector]; ok {
+			return nil
+		}
+		seen[selector] = struct{}{}
+		selectors = append(selectors, selector)
+		return nil
+	})
+
+	return selectors
+}
+
+// serializeMatchers returns the input label matchers sorted by label name, type and value, in the selector notation.
+func serializeMatchers(matchers []*labels.Matcher) string {
+	sorted := slices.Clone(matchers)
+	slices.SortFunc(sorted, func(a, b *labels.Matcher) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type), cmp.Compare(a.Value, b.Value))
+	})
+
+	return (&parser.VectorSelector{LabelMatchers: sorted}).String()
+}
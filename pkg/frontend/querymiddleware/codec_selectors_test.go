@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_ExtractSelectors(t *testing.T) {
+	for query, expected := range map[string][]string{
+		`vector(1)`:    nil,
+		`foo`:          {`{__name__="foo"}`},
+		`foo{job="a"}`: {`{__name__="foo",job="a"}`},
+		`sum by (job) (rate(foo{job="a"}[5m])) / sum by (job) (rate(bar{job=~"a|b"}[5m]))`: {`{__name__="foo",job="a"}`, `{__name__="bar",job=~"a|b"}`},
+		// The same matchers written with different notations or orders are returned once.
+		`foo{job="a", env="b"} + {env="b", __name__="foo", job="a"}`: {`{__name__="foo",env="b",job="a"}`},
+		// The same series selected with different modifiers are returned once.
+		`foo offset 1h - foo @ 100 + rate(foo[5m])`: {`{__name__="foo"}`},
+		// Selectors in subqueries.
+		`max_over_time(rate(foo{job="a"}[5m])[1h:1m])`: {`{__name__="foo",job="a"}`},
+		`max_over_time((foo + bar)[1h:])`:              {`{__name__="foo"}`, `{__name__="bar"}`},
+		// Selectors in function arguments.
+		`histogram_quantile(0.99, sum by (le) (rate(foo_bucket[5m])))`:           {`{__name__="foo_bucket"}`},
+		`label_replace(foo, "dst", "$1", "src", "(.*)") or absent(bar{job="a"})`: {`{__name__="foo"}`, `{__name__="bar",job="a"}`},
+		`clamp_max(foo, scalar(bar))`:                                            {`{__name__="foo"}`, `{__name__="bar"}`},
+		`topk(scalar(count(baz)), foo)`:                                          {`{__name__="foo"}`, `{__name__="baz"}`},
+	} {
+		t.Run(query, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil)
+
+			for _, path := range []string{
+				"/api/v1/query?time=3600&query=" + url.QueryEscape(query),
+				"/api/v1/query_range?start=3600&end=7200&step=60&query=" + url.QueryEscape(query),
+			} {
+				req, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, path, nil))
+				require.NoError(t, err)
+
+				actual, err := codec.ExtractSelectors(req)
+				require.NoError(t, err)
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// servedByRequestHeader is the debug request header asking to return the blocks or store-gateways which
+	// contributed to the query result. It's propagated to the queriers, which only annotate their responses when
+	// it's set.
+	servedByRequestHeader = "X-Mimir-Debug-Served-By"
+
+	// servedByHeader is the response header listing, comma-separated, the blocks or store-gateways which contributed
+	// to the query result, when requested with the X-Mimir-Debug-Served-By header.
+	servedByHeader = "X-Mimir-Served-By"
+
+	// servedByAnnotationPrefix is the prefix of the info annotations added by the queriers to list, comma-separated,
+	// the block ULIDs or store-gateway instances which contributed to the query result.
+	servedByAnnotationPrefix = "served by: "
+)
+
+// normalizeServedByAnnotations returns the input infos with the annotations listing the blocks or store-gateways
+// which served the query replaced by a single annotation listing them, sorted and without duplicates, so that the
+// annotations of the responses to split and sharded queries merge cheaply. The input infos are not modified.
+func normalizeServedByAnnotations(infos []string) []string {
+	remaining, servedBy := extractServedBy(infos)
+	if servedBy == nil {
+		return infos
+	}
+	if len(servedBy) == 0 {
+		return remaining
+	}
+	return append(remaining, servedByAnnotationPrefix+strings.Join(servedBy, ","))
+}
+
+// extractServedBy returns the input infos without the annotations listing the blocks or store-gateways which served
+// the query, and the sorted and deduplicated list of them. The input infos are not modified.
+func extractServedBy(infos []string) (remaining, servedBy []string) {
+	found := false
+	for _, info := range infos {
+		if !strings.HasPrefix(info, servedByAnnotationPrefix) {
+			remaining = append(remaining, info)
+			continue
+		}
+		found = true
+		for _, s := range strings.Split(strings.TrimPrefix(info, servedByAnnotationPrefix), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				servedBy = append(servedBy, s)
+			}
+		}
+	}
+	if !found {
+		return infos, nil
+	}
+	if servedBy == nil {
+		return remaining, []string{}
+	}
+
+	slices.Sort(servedBy)
+	return remaining, slices.Compact(servedBy)
+}
+
+// isServedByRequested returns whether the request asks to return the blocks or store-gateways which served the query.
+func isServedByRequested(r *http.Request) bool {
+	requested, err := strconv.ParseBool(r.Header.Get(servedByRequestHeader))
+	return err == nil && requested
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeMetricsQueryResponse_ServedBy(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	body := `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["served by: store-gateway-1, 01HQ4ZJ3E6Y7AQX3P6W0JX8V1N","some info","served by: store-gateway-1,store-gateway-0"]}`
+	httpResponse := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+	}
+
+	resp, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	promResp, ok := resp.GetPrometheusResponse()
+	require.True(t, ok)
+	assert.Equal(t, []string{"some info", "served by: 01HQ4ZJ3E6Y7AQX3P6W0JX8V1N,store-gateway-0,store-gateway-1"}, promResp.Infos)
+}
+
+func TestCodec_EncodeMetricsQueryResponse_ServedByHeader(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	for name, tc := range map[string]struct {
+		infos          []string
+		requested      string
+		expectedHeader []string
+	}{
+		"not requested": {
+			infos: []string{"served by: store-gateway-1", "served by: store-gateway-0"},
+		},
+		"requested": {
+			infos:          []string{"served by: store-gateway-1", "served by: store-gateway-0"},
+			requested:      "true",
+			expectedHeader: []string{"store-gateway-0,store-gateway-1"},
+		},
+		"requested with an invalid value": {
+			infos:     []string{"served by: store-gateway-1"},
+			requested: "yes",
+		},
+		"requested, but no annotation in the response": {
+			requested: "true",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := &PrometheusResponse{
+				Status: statusSuccess,
+				Data:   &PrometheusData{ResultType: "vector", Result: []SampleStream{}},
+				Infos:  append([]string{"some info"}, tc.infos...),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil)
+			req.Header.Set("Accept", jsonMimeType)
+			if tc.requested != "" {
+				req.Header.Set(servedByRequestHeader, tc.requested)
+			}
+
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedHeader, encoded.Header.Values(servedByHeader))
+
+			// The annotations are never returned in the response body.
+			body, err := io.ReadAll(encoded.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["some info"]}`, string(body))
+		})
+	}
+}
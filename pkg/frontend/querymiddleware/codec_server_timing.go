@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// serverTimingHeader is the standard response header holding the breakdown of the time spent serving the request.
+const serverTimingHeader = "Server-Timing"
+
+// WithServerTimingHeader controls whether the metrics query responses include the Server-Timing header, breaking
+// down the query-frontend's contribution to the response time: the time spent decoding the responses received from
+// the downstream queries and encoding the response, along with their sizes. The header can be displayed by browser
+// developer tools and tracing clients without enabling the query statistics. Defaults to disabled.
+func WithServerTimingHeader(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.serverTimingHeader = enabled
+	}
+}
+
+type serverTimingsContextKey int
+
+const serverTimingsKey serverTimingsContextKey = 0
+
+// serverTimings accumulates the time spent decoding the downstream responses of a metrics query request, and their
+// size. The responses can be decoded concurrently.
+type serverTimings struct {
+	decodeDuration atomic.Duration
+	decodedBytes   atomic.Int64
+}
+
+// withServerTimings returns a context accumulating the time spent decoding the downstream responses of the request,
+// if the Server-Timing header is enabled.
+func (c Codec) withServerTimings(ctx context.Context) context.Context {
+	if !c.serverTimingHeader {
+		return ctx
+	}
+	return context.WithValue(ctx, serverTimingsKey, &serverTimings{})
+}
+
+// observeServerTimingsDecode adds the duration and size of a decoded downstream response to the server timings of
+// the context, if any.
+func observeServerTimingsDecode(ctx context.Context, duration time.Duration, bytes int) {
+	timings, ok := ctx.Value(serverTimingsKey).(*serverTimings)
+	if !ok {
+		return
+	}
+	timings.decodeDuration.Add(duration)
+	timings.decodedBytes.Add(int64(bytes))
+}
+
+// setServerTimingHeader sets the Server-Timing header with the decoding time accumulated in the context and the
+// input encoding duration and size, if enabled. The durations are in milliseconds, as required by the header.
+func (c Codec) setServerTimingHeader(ctx context.Context, h http.Header, encodeDuration time.Duration, encodedBytes int) {
+	if !c.serverTimingHeader {
+		return
+	}
+
+	var decodeDuration time.Duration
+	var decodedBytes int64
+	if timings, ok := ctx.Value(serverTimingsKey).(*serverTimings); ok {
+		decodeDuration, decodedBytes = timings.decodeDuration.Load(), timings.decodedBytes.Load()
+	}
+
+	h.Set(serverTimingHeader, fmt.Sprintf(`decode;dur=%.3f;desc="%d bytes", encode;dur=%.3f;desc="%d bytes"`,
+		durationMilliseconds(decodeDuration), decodedBytes, durationMilliseconds(encodeDuration), encodedBytes))
+}
+
+func durationMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_ServerTimingHeader(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"foo"},"value":[1,"1"]}]}}`
+
+	for _, enabled := range []bool{false, true} {
+		t.Run("enabled="+strconv.FormatBool(enabled), func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil, WithServerTimingHeader(enabled))
+			ctx := codec.withServerTimings(context.Background())
+
+			// Decode the same response twice, like the responses of two downstream queries.
+			var decoded Response
+			for i := 0; i < 2; i++ {
+				var err error
+				decoded, err = codec.DecodeMetricsQueryResponse(ctx, &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{jsonMimeType}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+				}, nil, log.NewNopLogger())
+				require.NoError(t, err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=foo&time=1", nil)
+			req.Header.Set("Accept", jsonMimeType)
+			encoded, err := codec.EncodeMetricsQueryResponse(ctx, req, decoded)
+			require.NoError(t, err)
+
+			if !enabled {
+				assert.Empty(t, encoded.Header.Values(serverTimingHeader))
+				return
+			}
+
+			assert.Regexp(t, `^decode;dur=\d+\.\d{3};desc="`+strconv.Itoa(2*len(body))+` bytes", encode;dur=\d+\.\d{3};desc="`+strconv.FormatInt(encoded.ContentLength, 10)+` bytes"$`, encoded.Header.Get(serverTimingHeader))
+		})
+	}
+}
+
+func TestCodec_ServerTimingHeader_WithoutDecodedResponses(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil, WithServerTimingHeader(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=foo&time=1", nil)
+	req.Header.Set("Accept", jsonMimeType)
+	encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: "vector"}})
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^decode;dur=0\.000;desc="0 bytes", encode;dur=\d+\.\d{3};desc="\d+ bytes"$`, encoded.Header.Get(serverTimingHeader))
+}
+
+func TestLimitedRoundTripper_ServerTimingHeader(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+	downstream := RoundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{jsonMimeType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil, WithServerTimingHeader(true))
+	rt := NewLimitedParallelismRoundTripper(downstream, codec, mockLimits{maxQueryParallelism: 1})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=foo&time=1", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+	req.Header.Set("Accept", jsonMimeType)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Regexp(t, `^decode;dur=\d+\.\d{3};desc="`+strconv.Itoa(len(body))+` bytes", encode;dur=`, resp.Header.Get(serverTimingHeader))
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"container/heap"
+	"slices"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithSortedMatrixMerge controls whether MergeResponse merges the series of the input responses with a k-way merge,
+// relying on the series of each response being sorted by labels, instead of grouping them in a map and sorting them
+// afterwards. The k-way merge allocates less memory when merging a large number of responses, each one with many series,
+// at the cost of more label comparisons. Responses whose series aren't sorted are merged with the map-based merge.
+// Defaults to false.
+func WithSortedMatrixMerge(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.sortedMatrixMerge = enabled
+	}
+}
+
+// sortedMatrixMerge merges the input responses, sorted by time, with a k-way merge over their series. It requires
+// the series of each response to be sorted by labels, and falls back to matrixMerge otherwise. The merged series
+// are sorted by labels.
+func sortedMatrixMerge(resps []*PrometheusResponse, dropStaleMarkers bool) []SampleStream {
+	cursors := make(sampleStreamCursors, 0, len(resps))
+	maxSeries := 0
+
+	for idx, resp := range resps {
+		if resp.Data == nil || len(resp.Data.Result) == 0 {
+			continue
+		}
+		if !slices.IsSortedFunc(resp.Data.Result, func(a, b SampleStream) int {
+			return mimirpb.CompareLabelAdapters(a.Labels, b.Labels)
+		}) {
+			return matrixMerge(resps, dropStaleMarkers)
+		}
+
+		cursors = append(cursors, &sampleStreamCursor{respIdx: idx, streams: resp.Data.Result})
+		maxSeries = max(maxSeries, len(resp.Data.Result))
+	}
+
+	heap.Init(&cursors)
+	result := make([]SampleStream, 0, maxSeries)
+
+	for len(cursors) > 0 {
+		cursor := cursors[0]
+		stream := cursor.streams[cursor.pos]
+
+		// Series with the same labels are popped in the order of the responses, so they're merged in time order.
+		if len(result) == 0 || mimirpb.CompareLabelAdapters(result[len(result)-1].Labels, stream.Labels) != 0 {
+			result = append(result, SampleStream{Labels: stream.Labels})
+		}
+		appendSampleStream(&result[len(result)-1], stream, dropStaleMarkers)
+
+		cursor.pos++
+		if cursor.pos == len(cursor.streams) {
+			heap.Pop(&cursors)
+		} else {
+			heap.Fix(&cursors, 0)
+		}
+	}
+
+	return result
+}
+
+// sampleStreamCursor points to the next series to merge of a response.
+type sampleStreamCursor struct {
+	respIdx int
+	streams []SampleStream
+	pos     int
+}
+
+// sampleStreamCursors is a min-heap of cursors, ordered by the labels of their next series and then
+// by the index of their response.
+type sampleStreamCursors []*sampleStreamCursor
+
+func (h sampleStreamCursors) Len() int { return len(h) }
+
+func (h sampleStreamCursors) Less(i, j int) bool {
+	if c := mimirpb.CompareLabelAdapters(h[i].streams[h[i].pos].Labels, h[j].streams[h[j].pos].Labels); c != 0 {
+		return c < 0
+	}
+	return h[i].respIdx < h[j].respIdx
+}
+
+func (h sampleStreamCursors) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *sampleStreamCursors) Push(x any) { *h = append(*h, x.(*sampleStreamCursor)) }
+
+func (h *sampleStreamCursors) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithSortedSeriesLabels controls whether the labels of each series of the decoded query responses are sorted by name,
+// so that their order is deterministic regardless of the downstream which returned them. It adds the cost of checking
+// the order of the labels of each decoded series, and sorting them if needed. Defaults to disabled.
+func WithSortedSeriesLabels(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.sortSeriesLabels = enabled
+	}
+}
+
+// sortQueryResponseLabels sorts the labels of each series in resp by name.
+func sortQueryResponseLabels(resp *PrometheusResponse) {
+	if resp.Data == nil {
+		return
+	}
+
+	compareNames := func(a, b mimirpb.LabelAdapter) int {
+		return strings.Compare(a.Name, b.Name)
+	}
+	for _, series := range resp.Data.Result {
+		if !slices.IsSortedFunc(series.Labels, compareNames) {
+			slices.SortFunc(series.Labels, compareNames)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/prometheus/common/model"
+	v1 "github.com/prometheus/prometheus/web/api/v1"
+)
+
+const (
+	formatSSE = "sse"
+
+	sseMimeType = "text/event-stream"
+
+	// sseSeriesPerEvent is the max number of series encoded in each series event.
+	sseSeriesPerEvent = 100
+
+	// The events of a query result encoded as Server-Sent Events: a start event with the status and result type, a
+	// series event for each batch of series, and an end event with the warnings and infos.
+	sseEventStart  = "start"
+	sseEventSeries = "series"
+	sseEventEnd    = "end"
+)
+
+var errSSEDecodingNotSupported = errors.New("decoding Server-Sent Events responses is not supported")
+
+// sseFormatter encodes the matrix and vector query results as Server-Sent Events, so that clients can render the
+// series while the response is received. It's only selected when the client explicitly accepts text/event-stream,
+// and can't decode responses.
+type sseFormatter struct{}
+
+func (f sseFormatter) Name() string {
+	return formatSSE
+}
+
+func (f sseFormatter) ContentType() v1.MIMEType {
+	return v1.MIMEType{Type: "text", SubType: "event-stream"}
+}
+
+func (f sseFormatter) EncodeQueryResponse(resp *PrometheusResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSSEQueryResponse(&buf, resp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f sseFormatter) EncodeLabelsResponse(*PrometheusLabelsResponse) ([]byte, error) {
+	return nil, errors.New("labels responses can't be encoded as Server-Sent Events")
+}
+
+func (f sseFormatter) EncodeSeriesResponse(*PrometheusSeriesResponse) ([]byte, error) {
+	return nil, errors.New("series responses can't be encoded as Server-Sent Events")
+}
+
+func (f sseFormatter) DecodeQueryResponse([]byte) (*PrometheusResponse, error) {
+	return nil, errSSEDecodingNotSupported
+}
+
+func (f sseFormatter) DecodeLabelsResponse([]byte) (*PrometheusLabelsResponse, error) {
+	return nil, errSSEDecodingNotSupported
+}
+
+func (f sseFormatter) DecodeSeriesResponse([]byte) (*PrometheusSeriesResponse, error) {
+	return nil, errSSEDecodingNotSupported
+}
+
+// isSSESupportedResult returns whether the query result of the response can be encoded as Server-Sent Events.
+func isSSESupportedResult(resp *PrometheusResponse) bool {
+	return resp.Data != nil && (resp.Data.ResultType == model.ValMatrix.String() || resp.Data.ResultType == model.ValVector.String())
+}
+
+// writeSSEQueryResponse writes the matrix or vector query response to w as Server-Sent Events, one event at a time.
+// The series are encoded like in the JSON format.
+func writeSSEQueryResponse(w io.Writer, resp *PrometheusResponse) error {
+	if !isSSESupportedResult(resp) {
+		return fmt.Errorf("the query result can't be encoded as Server-Sent Events")
+	}
+
+	err := writeSSEEvent(w, sseEventStart, struct {
+		Status     string `json:"status"`
+		ResultType string `json:"resultType"`
+	}{
+		Status:     resp.Status,
+		ResultType: resp.Data.ResultType,
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(resp.Data.Result); start += sseSeriesPerEvent {
+		batch := resp.Data.Result[start:min(start+sseSeriesPerEvent, len(resp.Data.Result))]
+
+		var series any = asVectorSampleStreams(batch)
+		if resp.Data.ResultType == model.ValMatrix.String() {
+			series = batch
+		}
+		if err := writeSSEEvent(w, sseEventSeries, series); err != nil {
+			return err
+		}
+	}
+
+	return writeSSEEvent(w, sseEventEnd, struct {
+		Warnings []string `json:"warnings,omitempty"`
+		Infos    []string `json:"infos,omitempty"`
+	}{
+		Warnings: resp.Warnings,
+		Infos:    resp.Infos,
+	})
+}
+
+// writeSSEEvent writes an event with the input type and the JSON encoding of the input data to w. The JSON encoding
+// never contains newlines, so the data fits a single data field.
+func writeSSEEvent(w io.Writer, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: ", event); err != nil {
+		return err
+	}
+	if _, err := w.Write(encoded); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n\n")
+	return err
+}
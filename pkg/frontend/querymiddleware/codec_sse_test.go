@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestCodec_EncodeMetricsQueryResponse_ServerSentEvents(t *testing.T) {
+	newSeries := func(value string, samples ...mimirpb.Sample) SampleStream {
+		return SampleStream{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: value}}, Samples: samples}
+	}
+
+	t.Run("matrix", func(t *testing.T) {
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result: []SampleStream{
+					newSeries("a", mimirpb.Sample{TimestampMs: 0, Value: 1}, mimirpb.Sample{TimestampMs: 1000, Value: 2}),
+					newSeries("b", mimirpb.Sample{TimestampMs: 0, Value: 3}),
+				},
+			},
+			Warnings: []string{"some warning"},
+		}
+
+		body := encodeSSE(t, "text/event-stream", resp)
+		assert.Equal(t, strings.Join([]string{
+			"event: start\ndata: {\"status\":\"success\",\"resultType\":\"matrix\"}\n\n",
+			"event: series\ndata: [{\"metric\":{\"foo\":\"a\"},\"values\":[[0,\"1\"],[1,\"2\"]]},{\"metric\":{\"foo\":\"b\"},\"values\":[[0,\"3\"]]}]\n\n",
+			"event: end\ndata: {\"warnings\":[\"some warning\"]}\n\n",
+		}, ""), body)
+	})
+
+	t.Run("vector", func(t *testing.T) {
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValVector.String(),
+				Result:     []SampleStream{newSeries("a", mimirpb.Sample{TimestampMs: 1000, Value: 1})},
+			},
+		}
+
+		body := encodeSSE(t, "text/event-stream", resp)
+		assert.Equal(t, strings.Join([]string{
+			"event: start\ndata: {\"status\":\"success\",\"resultType\":\"vector\"}\n\n",
+			"event: series\ndata: [{\"metric\":{\"foo\":\"a\"},\"value\":[1,\"1\"]}]\n\n",
+			"event: end\ndata: {}\n\n",
+		}, ""), body)
+	})
+
+	t.Run("empty result", func(t *testing.T) {
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: model.ValMatrix.String()},
+		}
+
+		body := encodeSSE(t, "text/event-stream", resp)
+		assert.Equal(t, "event: start\ndata: {\"status\":\"success\",\"resultType\":\"matrix\"}\n\nevent: end\ndata: {}\n\n", body)
+	})
+
+	t.Run("series are batched", func(t *testing.T) {
+		result := make([]SampleStream, 0, 2*sseSeriesPerEvent+1)
+		for i := 0; i < cap(result); i++ {
+			result = append(result, newSeries(fmt.Sprint(i), mimirpb.Sample{TimestampMs: 0, Value: float64(i)}))
+		}
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: model.ValMatrix.String(), Result: result},
+		}
+
+		body := encodeSSE(t, "text/event-stream", resp)
+		assert.Equal(t, 3, strings.Count(body, "event: series\n"))
+		assert.Equal(t, len(result), strings.Count(body, `"metric"`))
+	})
+
+	t.Run("scalar results are not acceptable", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValScalar.String(),
+				Result:     []SampleStream{{Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}}}},
+			},
+		}
+
+		_, err := codec.EncodeMetricsQueryResponse(context.Background(), &http.Request{Header: http.Header{"Accept": []string{"text/event-stream"}}}, resp)
+		require.Error(t, err)
+		assert.True(t, apierror.IsAPIError(err))
+		assert.Contains(t, err.Error(), "only matrix and vector results can be encoded as text/event-stream")
+	})
+
+	t.Run("only selected when explicitly accepted", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+		resp := &PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: model.ValMatrix.String(), Result: []SampleStream{}},
+		}
+
+		for accept, expectedContentType := range map[string]string{
+			"":                                    jsonMimeType,
+			"*/*":                                 jsonMimeType,
+			"text/*":                              "",
+			"application/json, text/event-stream": jsonMimeType,
+			"text/event-stream, application/json": sseMimeType,
+			"application/json;q=0.5, text/event-stream": sseMimeType,
+		} {
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), &http.Request{Header: http.Header{"Accept": []string{accept}}}, resp)
+			if expectedContentType == "" {
+				require.Error(t, err, "accept: %q", accept)
+				continue
+			}
+			require.NoError(t, err, "accept: %q", accept)
+			assert.Equal(t, expectedContentType, encoded.Header.Get("Content-Type"), "accept: %q", accept)
+		}
+	})
+
+	t.Run("decoding is not supported", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+		body := []byte("event: start\ndata: {\"status\":\"success\",\"resultType\":\"matrix\"}\n\nevent: end\ndata: {}\n\n")
+		httpResponse := &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{sseMimeType}},
+			Body:          io.NopCloser(bytes.NewBuffer(body)),
+			ContentLength: int64(len(body)),
+		}
+
+		_, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, nil, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+// encodeSSE encodes the query response with the input Accept header, and returns the body of the encoded response
+// after checking it's encoded as Server-Sent Events.
+func encodeSSE(t *testing.T, accept string, resp *PrometheusResponse) string {
+	t.Helper()
+
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+	encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), &http.Request{Header: http.Header{"Accept": []string{accept}}}, resp)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, encoded.StatusCode)
+	require.Equal(t, sseMimeType, encoded.Header.Get("Content-Type"))
+
+	body, err := readResponseBody(encoded)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"slices"
+
+	"github.com/prometheus/prometheus/model/value"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithStaleMarkersDropped controls whether MergeResponse drops the samples of the merged series which are Prometheus
+// stale markers, so that clients don't get confused by them. Some clients rely on the stale markers, so they're
+// preserved by default.
+func WithStaleMarkersDropped(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.dropStaleMarkers = enabled
+	}
+}
+
+// dropStaleFloatSamples returns the input samples without the stale markers. The input slice is not modified.
+func dropStaleFloatSamples(samples []mimirpb.Sample) []mimirpb.Sample {
+	if !slices.ContainsFunc(samples, isStaleFloatSample) {
+		return samples
+	}
+
+	output := make([]mimirpb.Sample, 0, len(samples)-1)
+	for _, s := range samples {
+		if !isStaleFloatSample(s) {
+			output = append(output, s)
+		}
+	}
+	return output
+}
+
+func isStaleFloatSample(s mimirpb.Sample) bool {
+	return value.IsStaleNaN(s.Value)
+}
+
+// dropStaleHistogramSamples returns the input histograms without the stale markers. The input slice is not modified.
+func dropStaleHistogramSamples(histograms []mimirpb.FloatHistogramPair) []mimirpb.FloatHistogramPair {
+	if !slices.ContainsFunc(histograms, isStaleHistogramSample) {
+		return histograms
+	}
+
+	output := make([]mimirpb.FloatHistogramPair, 0, len(histograms)-1)
+	for _, h := range histograms {
+		if !isStaleHistogramSample(h) {
+			output = append(output, h)
+		}
+	}
+	return output
+}
+
+func isStaleHistogramSample(h mimirpb.FloatHistogramPair) bool {
+	return h.Histogram != nil && value.IsStaleNaN(h.Histogram.Sum)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// statsParam is the query parameter of metrics queries asking for query statistics.
+	statsParam = "stats"
+
+	// statsAll asks queriers to collect the per-step statistics of the query.
+	statsAll = "all"
+
+	// statsSamples asks for the total number of samples processed by the query only, returned in the
+	// X-Mimir-Samples-Processed response header. It's cheaper than statsAll, because the per-step
+	// statistics aren't collected.
+	statsSamples = "samples"
+
+	// samplesProcessedHeader is the response header carrying the total number of samples processed by the query,
+	// when requested with stats=samples.
+	samplesProcessedHeader = "X-Mimir-Samples-Processed"
+)
+
+// decodeStatsParam returns the value of the stats parameter in the input values, or an error if it's not supported.
+func decodeStatsParam(values url.Values) (string, error) {
+	switch s := values.Get(statsParam); s {
+	case "", statsAll, statsSamples:
+		return s, nil
+	default:
+		err := fmt.Errorf("unknown value %q, supported values are %q and %q", s, statsAll, statsSamples)
+		return "", apierror.New(apierror.TypeBadData, DecorateWithParamName(err, statsParam).Error())
+	}
+}
+
+// addSamplesProcessedHeader sets the X-Mimir-Samples-Processed header of the response to the input request, if it
+// asks for the total number of samples processed by the query with stats=samples and query statistics are tracked.
+func addSamplesProcessedHeader(ctx context.Context, r *http.Request, h http.Header) error {
+	queryStats := stats.FromContext(ctx)
+	if r.URL == nil || queryStats == nil {
+		return nil
+	}
+
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(r)
+	if err != nil {
+		return apierror.New(apierror.TypeBadData, err.Error())
+	}
+	if reqValues.Get(statsParam) != statsSamples {
+		return nil
+	}
+
+	h.Set(samplesProcessedHeader, strconv.FormatUint(queryStats.LoadSamplesProcessed(), 10))
+	return nil
+}
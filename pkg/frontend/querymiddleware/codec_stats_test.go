@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestCodec_DecodeMetricsQueryRequest_Stats(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	for _, path := range []string{"/api/v1/query?query=up&time=60", "/api/v1/query_range?query=up&start=0&end=60&step=15"} {
+		t.Run(path, func(t *testing.T) {
+			for _, value := range []string{"", statsAll, statsSamples} {
+				decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, path+"&stats="+value, nil))
+				require.NoError(t, err)
+				assert.Equal(t, value, decoded.GetStats())
+
+				// The stats parameter is sent downstream as is.
+				encoded, err := codec.EncodeMetricsQueryRequest(user.InjectOrgID(context.Background(), "user-1"), decoded)
+				require.NoError(t, err)
+				assert.Equal(t, value, encoded.URL.Query().Get(statsParam))
+			}
+
+			_, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, path+"&stats=true", nil))
+			require.Error(t, err)
+			assert.True(t, apierror.IsAPIError(err))
+			assert.Contains(t, err.Error(), `invalid parameter "stats": unknown value "true"`)
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_SamplesProcessedHeader(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+	resp := &PrometheusResponse{
+		Status: statusSuccess,
+		Data:   &PrometheusData{ResultType: model.ValVector.String()},
+	}
+
+	for name, tc := range map[string]struct {
+		stats          string
+		statsDisabled  bool
+		expectedHeader []string
+	}{
+		"stats not requested": {},
+		"all stats requested": {
+			stats: statsAll,
+		},
+		"samples stats requested": {
+			stats:          statsSamples,
+			expectedHeader: []string{"1234"},
+		},
+		"samples stats requested, with query stats disabled": {
+			stats:         statsSamples,
+			statsDisabled: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if !tc.statsDisabled {
+				var queryStats *stats.SafeStats
+				queryStats, ctx = stats.ContextWithEmptyStats(ctx)
+				queryStats.AddSamplesProcessed(1234)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up&stats="+tc.stats, nil)
+			req.Header.Set("Accept", jsonMimeType)
+
+			encoded, err := codec.EncodeMetricsQueryResponse(ctx, req, resp)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedHeader, encoded.Header.Values(samplesProcessedHeader))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithStepAlignmentValidation controls whether DecodeMetricsQueryResponse checks that the timestamps of the samples
+// in range query responses are aligned to the start and step of the request, returning an internal error if they aren't.
+// Series missing samples at some steps are valid, only misaligned samples are rejected. Defaults to false.
+func WithStepAlignmentValidation(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.validateStepAlignment = enabled
+	}
+}
+
+// validateStepAlignment checks that the timestamps of all samples in resp are aligned to the start and step
+// of the range query req. Responses to other requests are not checked.
+func validateStepAlignment(req MetricsQueryRequest, resp *PrometheusResponse) error {
+	rangeReq, ok := req.(*PrometheusRangeQueryRequest)
+	if !ok || rangeReq.GetStep() <= 0 || resp.Data == nil || resp.Data.ResultType != model.ValMatrix.String() {
+		return nil
+	}
+
+	start, step := rangeReq.GetStart(), rangeReq.GetStep()
+	misaligned := func(ts int64) bool {
+		return (ts-start)%step != 0
+	}
+
+	for _, series := range resp.Data.Result {
+		for _, s := range series.Samples {
+			if misaligned(s.TimestampMs) {
+				return fmt.Errorf("sample at timestamp %d of series %s is not aligned to the query start %d and step %d", s.TimestampMs, mimirpb.FromLabelAdaptersToString(series.Labels), start, step)
+			}
+		}
+
+		for _, h := range series.Histograms {
+			if misaligned(h.TimestampMs) {
+				return fmt.Errorf("histogram at timestamp %d of series %s is not aligned to the query start %d and step %d", h.TimestampMs, mimirpb.FromLabelAdaptersToString(series.Labels), start, step)
+			}
+		}
+	}
+
+	return nil
+}
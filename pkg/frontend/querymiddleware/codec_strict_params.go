@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/url"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+var (
+	// rangeQueryOnlyParams are the parameters of range queries not supported by instant queries.
+	rangeQueryOnlyParams = []string{"start", "end", "step"}
+
+	// instantQueryOnlyParams are the parameters of instant queries not supported by range queries.
+	instantQueryOnlyParams = []string{"time"}
+)
+
+// WithStrictQueryParams enables the rejection of the instant queries carrying a start, end or step parameter, and
+// of the range queries carrying a time parameter, which are otherwise ignored. It catches the clients sending range
+// queries to the instant query endpoint, or vice versa, which would otherwise get surprising results. Range queries
+// missing the start, end or step parameter are always rejected. Defaults to false.
+func WithStrictQueryParams(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.strictQueryParams = enabled
+	}
+}
+
+// validateRangeQueryParams returns an error if strict query params are enabled and the range query carries a
+// parameter only supported by instant queries.
+func (c Codec) validateRangeQueryParams(reqValues url.Values) error {
+	return c.validateUnsupportedQueryParams(reqValues, instantQueryOnlyParams, "range")
+}
+
+// validateInstantQueryParams returns an error if strict query params are enabled and the instant query carries a
+// parameter only supported by range queries. The parameter configured with WithInstantQueryTimeParamAlias is allowed.
+func (c Codec) validateInstantQueryParams(reqValues url.Values) error {
+	return c.validateUnsupportedQueryParams(reqValues, rangeQueryOnlyParams, "instant")
+}
+
+func (c Codec) validateUnsupportedQueryParams(reqValues url.Values, unsupported []string, queryType string) error {
+	if !c.strictQueryParams {
+		return nil
+	}
+
+	for _, name := range unsupported {
+		if name == c.instantQueryTimeParamAlias {
+			continue
+		}
+		if _, ok := reqValues[name]; ok {
+			return apierror.Newf(apierror.TypeBadData, "invalid parameter %q: not supported by %s queries", name, queryType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestCodec_DecodeMetricsQueryRequest_StrictQueryParams(t *testing.T) {
+	for name, tc := range map[string]struct {
+		path          string
+		expectedError string
+	}{
+		"range query": {
+			path: "/api/v1/query_range?query=up&start=0&end=180&step=60",
+		},
+		"range query with time": {
+			path:          "/api/v1/query_range?query=up&start=0&end=180&step=60&time=180",
+			expectedError: `invalid parameter "time": not supported by range queries`,
+		},
+		"instant query": {
+			path: "/api/v1/query?query=up&time=180",
+		},
+		"instant query with start": {
+			path:          "/api/v1/query?query=up&time=180&start=0",
+			expectedError: `invalid parameter "start": not supported by instant queries`,
+		},
+		"instant query with end": {
+			path:          "/api/v1/query?query=up&end=180",
+			expectedError: `invalid parameter "end": not supported by instant queries`,
+		},
+		"instant query with empty step": {
+			path:          "/api/v1/query?query=up&time=180&step=",
+			expectedError: `invalid parameter "step": not supported by instant queries`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("strict query params disabled", func(t *testing.T) {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+				_, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+				require.NoError(t, err)
+			})
+
+			t.Run("strict query params enabled", func(t *testing.T) {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithStrictQueryParams(true))
+
+				_, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+				if tc.expectedError == "" {
+					require.NoError(t, err)
+					return
+				}
+				require.Error(t, err)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.Contains(t, err.Error(), tc.expectedError)
+			})
+		})
+	}
+
+	t.Run("range query missing a parameter is always rejected", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+		_, err := codec.DecodeMetricsQueryRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/api/v1/query_range?query=up&start=0&end=180", nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `missing required parameter "step"`)
+	})
+}
@@ -11,8 +11,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"slices"
 	"strconv"
@@ -25,8 +27,10 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	v1Client "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/promql/parser"
 	v1API "github.com/prometheus/prometheus/web/api/v1"
 	"github.com/stretchr/testify/assert"
@@ -824,6 +828,50 @@ func TestCodec_EncodeMetricsQueryRequest_ReadConsistency(t *testing.T) {
 	}
 }
 
+func TestCodec_EncodeRequest_DefaultReadConsistency(t *testing.T) {
+	tests := map[string]struct {
+		defaultLevel  string
+		contextLevel  string
+		expectedLevel string
+	}{
+		"no default and no level in the context": {
+			expectedLevel: "",
+		},
+		"default and no level in the context": {
+			defaultLevel:  api.ReadConsistencyStrong,
+			expectedLevel: api.ReadConsistencyStrong,
+		},
+		"level in the context overrides the default": {
+			defaultLevel:  api.ReadConsistencyStrong,
+			contextLevel:  api.ReadConsistencyEventual,
+			expectedLevel: api.ReadConsistencyEventual,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatProtobuf, nil, WithDefaultReadConsistency(testData.defaultLevel))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			if testData.contextLevel != "" {
+				ctx = api.ContextWithReadConsistencyLevel(ctx, testData.contextLevel)
+			}
+
+			metricsReq, err := codec.EncodeMetricsQueryRequest(ctx, &PrometheusInstantQueryRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedLevel, metricsReq.Header.Get(api.ReadConsistencyHeader))
+
+			labelsReq, err := codec.EncodeLabelsSeriesQueryRequest(ctx, &PrometheusLabelNamesQueryRequest{Path: "/api/v1/labels"})
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedLevel, labelsReq.Header.Get(api.ReadConsistencyHeader))
+
+			seriesReq, err := codec.EncodeLabelsSeriesQueryRequest(ctx, &PrometheusSeriesQueryRequest{Path: "/api/v1/series"})
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedLevel, seriesReq.Header.Get(api.ReadConsistencyHeader))
+		})
+	}
+}
+
 func TestCodec_EncodeMetricsQueryRequest_ShouldPropagateHeadersInAllowList(t *testing.T) {
 	const notAllowedHeader = "X-Some-Name"
 
@@ -858,6 +906,60 @@ func TestCodec_EncodeMetricsQueryRequest_ShouldPropagateHeadersInAllowList(t *te
 	}
 }
 
+func TestCodec_EncodeRequest_ShouldCapPropagatedHeaders(t *testing.T) {
+	const (
+		header1 = "X-Header-1"
+		header2 = "X-Header-2"
+		header3 = "X-Header-3"
+	)
+
+	headers := []*PrometheusHeader{
+		{Name: header1, Values: []string{"a", "b", "c"}},
+		{Name: header2, Values: []string{"a"}},
+		{Name: header3, Values: []string{"a"}},
+	}
+
+	for name, tc := range map[string]struct {
+		opts     []CodecOption
+		expected http.Header
+	}{
+		"default limits": {
+			expected: http.Header{header1: {"a", "b", "c"}, header2: {"a"}, header3: {"a"}},
+		},
+		"custom limits": {
+			opts:     []CodecOption{WithPropagatedHeadersLimits(2, 2)},
+			expected: http.Header{header1: {"a", "b"}, header2: {"a"}},
+		},
+		"limits disabled": {
+			opts:     []CodecOption{WithPropagatedHeadersLimits(0, 0)},
+			expected: http.Header{header1: {"a", "b", "c"}, header2: {"a"}, header3: {"a"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, []string{header1, header2, header3}, tc.opts...)
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+
+			propagated := func(req *http.Request) http.Header {
+				h := http.Header{}
+				for _, name := range []string{header1, header2, header3} {
+					if values := req.Header.Values(name); len(values) > 0 {
+						h[name] = values
+					}
+				}
+				return h
+			}
+
+			metricsReq, err := codec.EncodeMetricsQueryRequest(ctx, &PrometheusInstantQueryRequest{headers: headers})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, propagated(metricsReq))
+
+			labelsReq, err := codec.EncodeLabelsSeriesQueryRequest(ctx, &PrometheusLabelNamesQueryRequest{Path: "/api/v1/labels", Headers: headers})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, propagated(labelsReq))
+		})
+	}
+}
+
 func TestCodec_EncodeResponse_ContentNegotiation(t *testing.T) {
 	testResponse := &PrometheusResponse{
 		Status:    statusError,
@@ -1070,6 +1172,404 @@ func TestCodec_DecodeResponse_ContentTypeHandling(t *testing.T) {
 	}
 }
 
+func TestCodec_DecodeResponse_StepAlignmentValidation(t *testing.T) {
+	rangeReq := NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, 10_000, 70_000, 20_000, 0, nil, Options{}, nil, "")
+	instantReq := NewPrometheusInstantQueryRequest("/api/v1/query", nil, 15_000, 0, nil, Options{}, nil, "")
+
+	// Native histograms can't be decoded from JSON, so use protobuf for them.
+	histogramPayload := mimirpb.QueryResponse{
+		Status: mimirpb.QUERY_STATUS_SUCCESS,
+		Data: &mimirpb.QueryResponse_Matrix{Matrix: &mimirpb.MatrixData{Series: []mimirpb.MatrixSeries{{
+			Metric:     []string{"foo", "bar"},
+			Histograms: []mimirpb.FloatHistogramPair{{TimestampMs: 20_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}}},
+		}}}},
+	}
+	histogramBody, err := histogramPayload.Marshal()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name                 string
+		req                  MetricsQueryRequest
+		body                 string
+		contentType          string
+		validationDisabled   bool
+		expectedErrorMessage string
+	}{
+		{
+			name: "samples aligned to start and step",
+			req:  rangeReq,
+			body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[10,"1"],[30,"2"],[70,"3"]]}]}}`,
+		},
+		{
+			name: "misaligned float sample",
+			req:  rangeReq,
+			body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[10,"1"],[35,"2"]]}]}}`,
+
+			expectedErrorMessage: `error decoding response: sample at timestamp 35000 of series {foo="bar"} is not aligned to the query start 10000 and step 20000`,
+		},
+		{
+			name:        "misaligned histogram sample",
+			req:         rangeReq,
+			body:        string(histogramBody),
+			contentType: mimirpb.QueryResponseMimeType,
+
+			expectedErrorMessage: `error decoding response: histogram at timestamp 20000 of series {foo="bar"} is not aligned to the query start 10000 and step 20000`,
+		},
+		{
+			name:               "misaligned float sample with validation disabled",
+			req:                rangeReq,
+			body:               `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[10,"1"],[35,"2"]]}]}}`,
+			validationDisabled: true,
+		},
+		{
+			name: "instant query",
+			req:  instantReq,
+			body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[15,"1"]}]}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithStepAlignmentValidation(!tc.validationDisabled))
+
+			contentType := tc.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			httpResponse := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{contentType}},
+				Body:          io.NopCloser(bytes.NewBufferString(tc.body)),
+				ContentLength: int64(len(tc.body)),
+			}
+
+			_, err := codec.DecodeMetricsQueryResponse(context.Background(), httpResponse, tc.req, log.NewNopLogger())
+			if tc.expectedErrorMessage == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Equal(t, apierror.New(apierror.TypeInternal, tc.expectedErrorMessage), err)
+		})
+	}
+}
+
+func TestCodec_QueryTimeRangeHeaders(t *testing.T) {
+	const (
+		start = int64(3_600_000)
+		end   = int64(7_200_000)
+	)
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 5*time.Minute, formatJSON, nil, WithQueryTimeRangeHeaders(enabled))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			req, err := codec.EncodeMetricsQueryRequest(ctx, NewPrometheusRangeQueryRequest(
+				"/api/v1/query_range", nil, start, end, 60_000, 5*time.Minute, parseQuery(t, `rate(foo[10m] offset 30m)`), Options{}, nil, "",
+			))
+			require.NoError(t, err)
+
+			rt := NewLimitedParallelismRoundTripper(nil, codec, mockLimits{maxQueryParallelism: 1}, MetricsQueryMiddlewareFunc(func(MetricsQueryHandler) MetricsQueryHandler {
+				return HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+					return newEmptyPrometheusResponse(), nil
+				})
+			}))
+
+			res, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			if !enabled {
+				assert.Empty(t, res.Header.Get(queryMinTHeader))
+				assert.Empty(t, res.Header.Get(queryMaxTHeader))
+				return
+			}
+
+			// The min time accounts for both the offset and the range selector.
+			assert.Equal(t, strconv.FormatInt(start-(40*time.Minute).Milliseconds()+1, 10), res.Header.Get(queryMinTHeader))
+			assert.Equal(t, strconv.FormatInt(end-(30*time.Minute).Milliseconds(), 10), res.Header.Get(queryMaxTHeader))
+		})
+	}
+}
+
+func TestCodec_UTF8LabelsValidation(t *testing.T) {
+	const invalid = "foo\xff"
+
+	newHTTPResponse := func(contentType string, body []byte) *http.Response {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{contentType}},
+			Body:          io.NopCloser(bytes.NewBuffer(body)),
+			ContentLength: int64(len(body)),
+		}
+	}
+
+	queryResponse := func(t *testing.T, value string) *http.Response {
+		body, err := protobufFormatter{}.EncodeQueryResponse(&PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValVector.String(),
+				Result: []SampleStream{{
+					Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "up"}, {Name: "job", Value: value}},
+					Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+				}},
+			},
+		})
+		require.NoError(t, err)
+		return newHTTPResponse(mimirpb.QueryResponseMimeType, body)
+	}
+
+	labelsResponse := func(value string) *http.Response {
+		return newHTTPResponse(jsonMimeType, []byte(`{"status":"success","data":["job","`+value+`"]}`))
+	}
+
+	seriesResponse := func(value string) *http.Response {
+		return newHTTPResponse(jsonMimeType, []byte(`{"status":"success","data":[{"__name__":"up","job":"`+value+`"}]}`))
+	}
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithUTF8LabelsValidation(enabled))
+			ctx := context.Background()
+
+			_, err := codec.DecodeMetricsQueryResponse(ctx, queryResponse(t, "bar"), nil, log.NewNopLogger())
+			require.NoError(t, err)
+			_, err = codec.DecodeLabelsSeriesQueryResponse(ctx, labelsResponse("bar"), &PrometheusLabelNamesQueryRequest{}, log.NewNopLogger())
+			require.NoError(t, err)
+			_, err = codec.DecodeLabelsSeriesQueryResponse(ctx, seriesResponse("bar"), &PrometheusSeriesQueryRequest{}, log.NewNopLogger())
+			require.NoError(t, err)
+
+			_, queryErr := codec.DecodeMetricsQueryResponse(ctx, queryResponse(t, invalid), nil, log.NewNopLogger())
+			_, labelsErr := codec.DecodeLabelsSeriesQueryResponse(ctx, labelsResponse(invalid), &PrometheusLabelNamesQueryRequest{}, log.NewNopLogger())
+			_, seriesErr := codec.DecodeLabelsSeriesQueryResponse(ctx, seriesResponse(invalid), &PrometheusSeriesQueryRequest{}, log.NewNopLogger())
+
+			if !enabled {
+				require.NoError(t, queryErr)
+				require.NoError(t, labelsErr)
+				require.NoError(t, seriesErr)
+				return
+			}
+
+			for _, err := range []error{queryErr, labelsErr, seriesErr} {
+				require.Error(t, err)
+				apiErr := &apierror.APIError{}
+				require.ErrorAs(t, err, &apiErr)
+				require.Equal(t, apierror.TypeInternal, apiErr.Type)
+			}
+			require.ErrorContains(t, queryErr, `label "job" of series up{job="foo\xff"} is not valid UTF-8`)
+			require.ErrorContains(t, labelsErr, `label name or value "foo\xff" is not valid UTF-8`)
+			require.ErrorContains(t, seriesErr, `label "job" of series`)
+		})
+	}
+}
+
+func TestCodec_SortedSeriesLabels(t *testing.T) {
+	queryResponse := func(t *testing.T, lbls []mimirpb.LabelAdapter) *http.Response {
+		body, err := protobufFormatter{}.EncodeQueryResponse(&PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: model.ValVector.String(),
+				Result: []SampleStream{{
+					Labels:  lbls,
+					Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+				}},
+			},
+		})
+		require.NoError(t, err)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{mimirpb.QueryResponseMimeType}},
+			Body:          io.NopCloser(bytes.NewBuffer(body)),
+			ContentLength: int64(len(body)),
+		}
+	}
+
+	sorted := []mimirpb.LabelAdapter{{Name: "__name__", Value: "up"}, {Name: "instance", Value: "a"}, {Name: "job", Value: "b"}}
+	unsorted := []mimirpb.LabelAdapter{{Name: "job", Value: "b"}, {Name: "__name__", Value: "up"}, {Name: "instance", Value: "a"}}
+
+	decodedLabels := func(t *testing.T, codec Codec, lbls []mimirpb.LabelAdapter) []mimirpb.LabelAdapter {
+		resp, err := codec.DecodeMetricsQueryResponse(context.Background(), queryResponse(t, lbls), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		result := resp.(*PrometheusResponse).Data.Result
+		require.Len(t, result, 1)
+		return result[0].Labels
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithSortedSeriesLabels(true))
+		assert.Equal(t, sorted, decodedLabels(t, codec, slices.Clone(sorted)))
+		assert.Equal(t, sorted, decodedLabels(t, codec, slices.Clone(unsorted)))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil)
+		assert.Equal(t, unsorted, decodedLabels(t, codec, slices.Clone(unsorted)))
+	})
+}
+
+func TestCodec_LegacyBlockFormatInfo(t *testing.T) {
+	const legacyInfo = "served from legacy block format"
+
+	for _, configured := range []bool{false, true} {
+		t.Run(fmt.Sprintf("configured=%t", configured), func(t *testing.T) {
+			var opts []CodecOption
+			if configured {
+				opts = append(opts, WithLegacyBlockFormatInfo(legacyInfo))
+			}
+			reg := prometheus.NewPedanticRegistry()
+			codec := NewCodec(reg, 0*time.Minute, formatJSON, nil, opts...)
+
+			decode := func(infos ...string) Response {
+				body, err := json.Marshal(PrometheusResponse{
+					Status: statusSuccess,
+					Data:   &PrometheusData{ResultType: model.ValVector.String(), Result: []SampleStream{}},
+					Infos:  infos,
+				})
+				require.NoError(t, err)
+
+				res, err := codec.DecodeMetricsQueryResponse(context.Background(), &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{"Content-Type": []string{jsonMimeType}},
+					Body:          io.NopCloser(bytes.NewBuffer(body)),
+					ContentLength: int64(len(body)),
+				}, nil, log.NewNopLogger())
+				require.NoError(t, err)
+				return res
+			}
+
+			encode := func(res Response) *http.Response {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil)
+				encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, res)
+				require.NoError(t, err)
+				return encoded
+			}
+
+			plain := decode("some other info")
+			legacy := decode("some other info", "PromQL info: "+legacyInfo)
+
+			// The annotation is passed through unaltered.
+			legacyInfos := legacy.(*PrometheusResponse).Infos
+			assert.Equal(t, []string{"some other info", "PromQL info: " + legacyInfo}, legacyInfos)
+
+			assert.Empty(t, encode(plain).Header.Get(legacyBlockFormatHeader))
+			if configured {
+				assert.Equal(t, "true", encode(legacy).Header.Get(legacyBlockFormatHeader))
+			} else {
+				assert.Empty(t, encode(legacy).Header.Get(legacyBlockFormatHeader))
+			}
+
+			expected := 0
+			if configured {
+				expected = 1
+			}
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_frontend_legacy_block_responses_total Total number of decoded query responses annotated as served from a legacy block format.
+				# TYPE cortex_frontend_legacy_block_responses_total counter
+				cortex_frontend_legacy_block_responses_total %d
+			`, expected)), "cortex_frontend_legacy_block_responses_total"))
+		})
+	}
+}
+
+func TestCodec_DecodeMetricsQueryResponse_SamplesMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	codec := NewCodec(reg, 0, formatProtobuf, nil)
+
+	payload := mimirpb.QueryResponse{
+		Status: mimirpb.QUERY_STATUS_SUCCESS,
+		Data: &mimirpb.QueryResponse_Matrix{Matrix: &mimirpb.MatrixData{Series: []mimirpb.MatrixSeries{
+			{
+				Metric:  []string{"foo", "bar"},
+				Samples: []mimirpb.Sample{{TimestampMs: 10_000, Value: 1}, {TimestampMs: 20_000, Value: 2}, {TimestampMs: 30_000, Value: 3}},
+			},
+			{
+				Metric:     []string{"foo", "baz"},
+				Samples:    []mimirpb.Sample{{TimestampMs: 10_000, Value: 1}},
+				Histograms: []mimirpb.FloatHistogramPair{{TimestampMs: 20_000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}}},
+			},
+		}}},
+	}
+	body, err := payload.Marshal()
+	require.NoError(t, err)
+
+	for _, req := range []MetricsQueryRequest{&PrometheusRangeQueryRequest{}, &PrometheusInstantQueryRequest{}} {
+		_, err := codec.DecodeMetricsQueryResponse(context.Background(), &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{mimirpb.QueryResponseMimeType}},
+			Body:          io.NopCloser(bytes.NewBuffer(body)),
+			ContentLength: int64(len(body)),
+		}, req, log.NewNopLogger())
+		require.NoError(t, err)
+	}
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_frontend_query_response_histograms Total number of native histogram samples across all series of the decoded query responses.
+		# TYPE cortex_frontend_query_response_histograms histogram
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="1"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="4"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="16"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="64"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="256"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="1024"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="4096"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="16384"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="65536"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="262144"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="1.048576e+06"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="4.194304e+06"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query",le="+Inf"} 1
+		cortex_frontend_query_response_histograms_sum{operation="query"} 1
+		cortex_frontend_query_response_histograms_count{operation="query"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="1"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="4"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="16"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="64"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="256"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="1024"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="4096"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="16384"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="65536"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="262144"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="1.048576e+06"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="4.194304e+06"} 1
+		cortex_frontend_query_response_histograms_bucket{operation="query_range",le="+Inf"} 1
+		cortex_frontend_query_response_histograms_sum{operation="query_range"} 1
+		cortex_frontend_query_response_histograms_count{operation="query_range"} 1
+		# HELP cortex_frontend_query_response_samples Total number of float samples across all series of the decoded query responses.
+		# TYPE cortex_frontend_query_response_samples histogram
+		cortex_frontend_query_response_samples_bucket{operation="query",le="1"} 0
+		cortex_frontend_query_response_samples_bucket{operation="query",le="4"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="16"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="64"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="256"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="1024"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="4096"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="16384"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="65536"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="262144"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="1.048576e+06"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="4.194304e+06"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query",le="+Inf"} 1
+		cortex_frontend_query_response_samples_sum{operation="query"} 4
+		cortex_frontend_query_response_samples_count{operation="query"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="1"} 0
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="4"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="16"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="64"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="256"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="1024"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="4096"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="16384"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="65536"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="262144"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="1.048576e+06"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="4.194304e+06"} 1
+		cortex_frontend_query_response_samples_bucket{operation="query_range",le="+Inf"} 1
+		cortex_frontend_query_response_samples_sum{operation="query_range"} 4
+		cortex_frontend_query_response_samples_count{operation="query_range"} 1
+	`), "cortex_frontend_query_response_samples", "cortex_frontend_query_response_histograms"))
+}
+
 func TestMergeAPIResponses(t *testing.T) {
 	codec := newTestCodec()
 
@@ -1470,9 +1970,15 @@ func TestMergeAPIResponses(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			output, err := codec.MergeResponse(tc.input...)
-			require.NoError(t, err)
-			requireEqualPrometheusResponse(t, tc.expected, output)
+			for _, sortedMerge := range []bool{false, true} {
+				t.Run(fmt.Sprintf("sorted matrix merge: %t", sortedMerge), func(t *testing.T) {
+					codec := NewCodec(prometheus.NewPedanticRegistry(), 0*time.Minute, formatJSON, nil, WithSortedMatrixMerge(sortedMerge))
+
+					output, err := codec.MergeResponse(tc.input...)
+					require.NoError(t, err)
+					requireEqualPrometheusResponse(t, tc.expected, output)
+				})
+			}
 		})
 	}
 
@@ -1519,6 +2025,154 @@ func TestMergeAPIResponses(t *testing.T) {
 	})
 }
 
+func TestSortedMatrixMerge(t *testing.T) {
+	t.Run("should return the same series of the map-based merge", func(t *testing.T) {
+		resps := generateMatrixMergeResponses(10, 50, 10)
+
+		assert.Equal(t, matrixMerge(resps, false), sortedMatrixMerge(resps, false))
+	})
+
+	t.Run("should fall back to the map-based merge if the series of a response are not sorted", func(t *testing.T) {
+		resps := generateMatrixMergeResponses(3, 5, 2)
+		result := resps[1].Data.Result
+		result[0], result[len(result)-1] = result[len(result)-1], result[0]
+
+		assert.Equal(t, matrixMerge(resps, false), sortedMatrixMerge(resps, false))
+	})
+
+	t.Run("should skip responses with no data", func(t *testing.T) {
+		resps := generateMatrixMergeResponses(3, 5, 2)
+		resps = append(resps, &PrometheusResponse{}, &PrometheusResponse{Data: &PrometheusData{}})
+
+		assert.Equal(t, matrixMerge(resps, false), sortedMatrixMerge(resps, false))
+		assert.Empty(t, sortedMatrixMerge(nil, false))
+	})
+}
+
+func TestCodec_MergeResponse_StaleMarkers(t *testing.T) {
+	staleNaN := math.Float64frombits(value.StaleNaN)
+
+	newResponses := func() []Response {
+		return []Response{
+			&PrometheusResponse{
+				Status: statusSuccess,
+				Data: &PrometheusData{
+					ResultType: matrix,
+					Result: []SampleStream{{
+						Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}},
+						Samples: []mimirpb.Sample{{TimestampMs: 0, Value: 1}, {TimestampMs: 1000, Value: staleNaN}, {TimestampMs: 2000, Value: math.NaN()}},
+					}},
+				},
+			},
+			&PrometheusResponse{
+				Status: statusSuccess,
+				Data: &PrometheusData{
+					ResultType: matrix,
+					Result: []SampleStream{{
+						Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}},
+						Samples: []mimirpb.Sample{{TimestampMs: 3000, Value: staleNaN}, {TimestampMs: 4000, Value: 4}},
+						Histograms: []mimirpb.FloatHistogramPair{
+							{TimestampMs: 3000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}},
+							{TimestampMs: 4000, Histogram: &mimirpb.FloatHistogram{Sum: staleNaN}},
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	for _, sorted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sorted matrix merge=%t", sorted), func(t *testing.T) {
+			t.Run("should preserve stale markers by default", func(t *testing.T) {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithSortedMatrixMerge(sorted))
+
+				merged, err := codec.MergeResponse(newResponses()...)
+				require.NoError(t, err)
+
+				result := merged.(*PrometheusResponseWithFinalizer).Data.Result
+				require.Len(t, result, 1)
+				requireEqualSamples(t, []mimirpb.Sample{{TimestampMs: 0, Value: 1}, {TimestampMs: 1000, Value: math.NaN()}, {TimestampMs: 2000, Value: math.NaN()}, {TimestampMs: 3000, Value: math.NaN()}, {TimestampMs: 4000, Value: 4}}, result[0].Samples)
+				require.True(t, value.IsStaleNaN(result[0].Samples[1].Value))
+				require.Len(t, result[0].Histograms, 2)
+			})
+
+			t.Run("should drop stale markers if enabled", func(t *testing.T) {
+				codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithSortedMatrixMerge(sorted), WithStaleMarkersDropped(true))
+
+				responses := newResponses()
+				merged, err := codec.MergeResponse(responses...)
+				require.NoError(t, err)
+
+				result := merged.(*PrometheusResponseWithFinalizer).Data.Result
+				require.Len(t, result, 1)
+
+				// Only the stale markers are dropped, not the other NaN values.
+				requireEqualSamples(t, []mimirpb.Sample{{TimestampMs: 0, Value: 1}, {TimestampMs: 2000, Value: math.NaN()}, {TimestampMs: 4000, Value: 4}}, result[0].Samples)
+				require.Equal(t, []mimirpb.FloatHistogramPair{{TimestampMs: 3000, Histogram: &mimirpb.FloatHistogram{Count: 1, Sum: 1}}}, result[0].Histograms)
+
+				// The input responses are not modified.
+				require.Len(t, responses[0].(*PrometheusResponse).Data.Result[0].Samples, 3)
+				require.Len(t, responses[1].(*PrometheusResponse).Data.Result[0].Histograms, 2)
+			})
+		})
+	}
+}
+
+func BenchmarkMatrixMerge(b *testing.B) {
+	for _, numResponses := range []int{16, 256, 4096} {
+		for _, numSeries := range []int{10, 1000} {
+			resps := generateMatrixMergeResponses(numResponses, numSeries, 5)
+
+			for name, merge := range map[string]func([]*PrometheusResponse, bool) []SampleStream{
+				"map-based": matrixMerge,
+				"sorted":    sortedMatrixMerge,
+			} {
+				b.Run(fmt.Sprintf("responses: %d, series: %d, merge: %s", numResponses, numSeries, name), func(b *testing.B) {
+					b.ReportAllocs()
+
+					for n := 0; n < b.N; n++ {
+						merge(resps, false)
+					}
+				})
+			}
+		}
+	}
+}
+
+// generateMatrixMergeResponses generates responses sorted by time, each one with its series sorted by labels.
+// Consecutive responses overlap by one sample, and each series is missing from some responses.
+func generateMatrixMergeResponses(numResponses, numSeries, numSamplesPerResponse int) []*PrometheusResponse {
+	resps := make([]*PrometheusResponse, 0, numResponses)
+
+	for r := 0; r < numResponses; r++ {
+		startTs := int64(r * (numSamplesPerResponse - 1))
+		result := make([]SampleStream, 0, numSeries)
+
+		for s := 0; s < numSeries; s++ {
+			if (r+s)%3 == 0 {
+				continue
+			}
+
+			samples := make([]mimirpb.Sample, 0, numSamplesPerResponse)
+			for i := 0; i < numSamplesPerResponse; i++ {
+				samples = append(samples, mimirpb.Sample{TimestampMs: startTs + int64(i), Value: float64(s)})
+			}
+
+			result = append(result, SampleStream{
+				Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "metric"}, {Name: "series", Value: fmt.Sprintf("%06d", s)}},
+				Samples: samples,
+			})
+		}
+
+		resps = append(resps, &PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: matrix, Result: result},
+		})
+	}
+
+	return resps
+}
+
 func requireEqualPrometheusResponse(t *testing.T, expected, actual Response) {
 	prometheusResponse, ok := expected.GetPrometheusResponse()
 	require.True(t, ok)
@@ -1717,6 +2371,71 @@ func TestDecodeRangeQueryTimeParams(t *testing.T) {
 	}
 }
 
+func TestCodec_DecodeInstantQueryTimeParamAlias(t *testing.T) {
+	for name, tt := range map[string]struct {
+		alias        string
+		params       url.Values
+		expectedTime int64
+		expectedErr  string
+	}{
+		"should read the time parameter if no alias is configured": {
+			params:       url.Values{"time": []string{"1000"}, "ts": []string{"2000"}},
+			expectedTime: 1_000_000,
+		},
+		"should ignore the alias if not configured": {
+			params:       url.Values{"ts": []string{"2000"}},
+			expectedTime: -1,
+		},
+		"should read the alias if the time parameter is missing": {
+			alias:        "ts",
+			params:       url.Values{"ts": []string{"2000"}},
+			expectedTime: 2_000_000,
+		},
+		"should prefer the time parameter over the alias": {
+			alias:        "ts",
+			params:       url.Values{"time": []string{"1000"}, "ts": []string{"2000"}},
+			expectedTime: 1_000_000,
+		},
+		"should default to now if both the time parameter and the alias are missing": {
+			alias:        "ts",
+			params:       url.Values{},
+			expectedTime: -1,
+		},
+		"should fail on invalid alias value": {
+			alias:       "ts",
+			params:      url.Values{"ts": []string{"invalid"}},
+			expectedErr: `invalid parameter "ts"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithInstantQueryTimeParamAlias(tt.alias))
+
+			params := url.Values{"query": []string{"up"}}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			before := time.Now()
+			req, err := http.NewRequest(http.MethodGet, "/api/v1/query?"+params.Encode(), nil)
+			require.NoError(t, err)
+
+			decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.expectedTime < 0 {
+				// Defaults to now.
+				assert.GreaterOrEqual(t, decoded.GetStart(), before.UnixMilli())
+				return
+			}
+			assert.Equal(t, tt.expectedTime, decoded.GetStart())
+		})
+	}
+}
+
 func Test_DecodeOptions(t *testing.T) {
 	for _, tt := range []struct {
 		name     string
@@ -1763,6 +2482,55 @@ func Test_DecodeOptions(t *testing.T) {
 				ShardingDisabled: true,
 			},
 		},
+		{
+			name: "allow partial response",
+			input: &http.Request{
+				Header: http.Header{
+					partialResponseControlHeader: []string{"true"},
+				},
+			},
+			expected: &Options{
+				AllowPartialResponse: true,
+			},
+		},
+		{
+			name: "max query parallelism",
+			input: &http.Request{
+				Header: http.Header{
+					maxQueryParallelismHeader: []string{"8"},
+				},
+			},
+			expected: &Options{
+				MaxQueryParallelism: 8,
+			},
+		},
+		{
+			name: "invalid max query parallelism",
+			input: &http.Request{
+				Header: http.Header{
+					maxQueryParallelismHeader: []string{"foo"},
+				},
+			},
+			expected: &Options{},
+		},
+		{
+			name: "non-positive max query parallelism",
+			input: &http.Request{
+				Header: http.Header{
+					maxQueryParallelismHeader: []string{"0"},
+				},
+			},
+			expected: &Options{},
+		},
+		{
+			name: "invalid allow partial response",
+			input: &http.Request{
+				Header: http.Header{
+					partialResponseControlHeader: []string{"foo"},
+				},
+			},
+			expected: &Options{},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
@@ -1796,6 +2564,18 @@ func TestCodec_DecodeEncode_Metrics(t *testing.T) {
 			name:    "cache disabled via header",
 			headers: http.Header{cacheControlHeader: []string{noStoreValue}},
 		},
+		{
+			name:    "partial response allowed via header",
+			headers: http.Header{partialResponseControlHeader: []string{"true"}},
+		},
+		{
+			name:    "streaming disabled via header",
+			headers: http.Header{compat.ForceFallbackHeaderName: []string{"true"}},
+		},
+		{
+			name:    "max query parallelism via header",
+			headers: http.Header{maxQueryParallelismHeader: []string{"16"}},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
@@ -2113,3 +2893,134 @@ func mustSucceed[T any](value T, err error) T {
 
 	return value
 }
+
+func TestCodec_SplitRangeQueryByInterval(t *testing.T) {
+	const lookbackDelta = 5 * time.Minute
+
+	codec := newTestCodec()
+	queryExpr, err := parser.ParseExpr("foo")
+	require.NoError(t, err)
+
+	minutes := func(m int64) int64 {
+		return m * time.Minute.Milliseconds()
+	}
+	newRequest := func(start, end, step int64) MetricsQueryRequest {
+		return NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, start, end, step, lookbackDelta, queryExpr, Options{}, nil, "")
+	}
+
+	testCases := map[string]struct {
+		start, end, step int64
+		interval         time.Duration
+		expected         [][2]int64 // Start and end of each subrequest.
+	}{
+		"should split a query starting on an interval boundary": {
+			start:    0,
+			end:      minutes(120),
+			step:     minutes(30),
+			interval: time.Hour,
+			expected: [][2]int64{{0, minutes(30)}, {minutes(60), minutes(90)}, {minutes(120), minutes(120)}},
+		},
+		"should extend the last subrequest by one step ending on an interval boundary": {
+			start:    0,
+			end:      minutes(60),
+			step:     minutes(1),
+			interval: time.Hour,
+			expected: [][2]int64{{0, minutes(60)}},
+		},
+		"should not extend the last subrequest if the step is large": {
+			start:    0,
+			end:      minutes(60),
+			step:     minutes(10),
+			interval: time.Hour,
+			expected: [][2]int64{{0, minutes(50)}, {minutes(60), minutes(60)}},
+		},
+		"should keep steps aligned to the query start if the step doesn't divide the interval": {
+			start:    minutes(10),
+			end:      minutes(130),
+			step:     minutes(25),
+			interval: time.Hour,
+			expected: [][2]int64{{minutes(10), minutes(35)}, {minutes(60), minutes(110)}},
+		},
+		"should split a query with a step larger than the interval": {
+			start:    0,
+			end:      minutes(180),
+			step:     minutes(90),
+			interval: time.Hour,
+			expected: [][2]int64{{0, 0}, {minutes(90), minutes(90)}, {minutes(180), minutes(180)}},
+		},
+		"should not split a query within a single interval": {
+			start:    minutes(24*60 + 10),
+			end:      minutes(24*60 + 595),
+			step:     minutes(15),
+			interval: 24 * time.Hour,
+			expected: [][2]int64{{minutes(24*60 + 10), minutes(24*60 + 595)}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newRequest(tc.start, tc.end, tc.step)
+
+			splitReqs, err := codec.SplitRangeQueryByInterval(req, tc.interval)
+			require.NoError(t, err)
+
+			actual := make([][2]int64, 0, len(splitReqs))
+			for i, splitReq := range splitReqs {
+				actual = append(actual, [2]int64{splitReq.GetStart(), splitReq.GetEnd()})
+
+				// Each subrequest only covers steps of the original query, and has its minT/maxT recalculated.
+				require.Equal(t, tc.step, splitReq.GetStep())
+				require.Zero(t, (splitReq.GetStart()-tc.start)%tc.step)
+				require.Zero(t, (splitReq.GetEnd()-tc.start)%tc.step)
+				expected := newRequest(splitReq.GetStart(), splitReq.GetEnd(), tc.step)
+				require.Equal(t, expected.GetMinT(), splitReq.GetMinT())
+				require.Equal(t, expected.GetMaxT(), splitReq.GetMaxT())
+
+				// Subrequests are contiguous.
+				if i > 0 {
+					require.Equal(t, splitReqs[i-1].GetEnd()+tc.step, splitReq.GetStart())
+				}
+			}
+			require.Equal(t, tc.expected, actual)
+
+			// The subrequests cover all the steps of the original query.
+			last := splitReqs[len(splitReqs)-1]
+			require.Equal(t, tc.start, splitReqs[0].GetStart())
+			require.Greater(t, last.GetEnd()+tc.step, tc.end)
+		})
+	}
+
+	t.Run("should fail on instant queries", func(t *testing.T) {
+		req := NewPrometheusInstantQueryRequest("/api/v1/query", nil, minutes(60), lookbackDelta, queryExpr, Options{}, nil, "")
+		_, err := codec.SplitRangeQueryByInterval(req, time.Hour)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on a non-positive interval", func(t *testing.T) {
+		_, err := codec.SplitRangeQueryByInterval(newRequest(0, minutes(60), minutes(1)), 0)
+		require.Error(t, err)
+	})
+}
+
+func TestCodec_EstimateResponseSize(t *testing.T) {
+	codec := newTestCodec()
+
+	for name, tc := range map[string]struct {
+		contentLength int64
+		format        string
+		expected      int64
+	}{
+		"json":                   {contentLength: 1000, format: formatJSON, expected: 750},
+		"protobuf":               {contentLength: 1000, format: formatProtobuf, expected: 2500},
+		"unknown format":         {contentLength: 1000, format: "xml", expected: 1000},
+		"empty response":         {contentLength: 0, format: formatProtobuf, expected: 0},
+		"unknown content length": {contentLength: -1, format: formatJSON, expected: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, codec.EstimateResponseSize(tc.contentLength, tc.format))
+		})
+	}
+
+	// Protobuf payloads are expected to expand more than JSON ones when decoded.
+	require.Greater(t, codec.EstimateResponseSize(1000, formatProtobuf), codec.EstimateResponseSize(1000, formatJSON))
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// timeoutParam is the query parameter setting the evaluation timeout of range and instant queries, like in Prometheus.
+const timeoutParam = "timeout"
+
+// WithMaxQueryTimeout configures the max evaluation timeout which can be requested with the timeout parameter of range
+// and instant queries. Greater timeouts are clamped to it, and a warning is logged. Defaults to 0, which disables it.
+func WithMaxQueryTimeout(maxTimeout time.Duration) CodecOption {
+	return func(c *Codec) {
+		c.maxQueryTimeout = maxTimeout
+	}
+}
+
+// decodeTimeoutParam returns the evaluation timeout requested in the input values, 0 if not set, or an error if it's
+// not a positive duration. The timeout is clamped to the max configured with WithMaxQueryTimeout.
+func (c Codec) decodeTimeoutParam(values url.Values) (time.Duration, error) {
+	s := values.Get(timeoutParam)
+	if s == "" {
+		return 0, nil
+	}
+
+	timeoutMs, err := util.ParseDurationMS(s)
+	if err == nil && timeoutMs <= 0 {
+		err = errors.New("timeout must be positive")
+	}
+	if err != nil {
+		return 0, apierror.New(apierror.TypeBadData, DecorateWithParamName(err, timeoutParam).Error())
+	}
+
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if c.maxQueryTimeout > 0 && timeout > c.maxQueryTimeout {
+		level.Warn(c.logger).Log("msg", "clamping the requested query timeout to the max allowed", "timeout", timeout, "max", c.maxQueryTimeout)
+		timeout = c.maxQueryTimeout
+	}
+	return timeout, nil
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestCodec_DecodeMetricsQueryRequest_Timeout(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithMaxQueryTimeout(time.Minute))
+
+	for _, path := range []string{
+		"/api/v1/query_range?query=foo&start=0&end=180&step=60",
+		"/api/v1/query?query=foo&time=180",
+	} {
+		t.Run(path, func(t *testing.T) {
+			for timeout, expected := range map[string]struct {
+				decoded time.Duration
+				encoded string
+			}{
+				"":     {decoded: 0},
+				"30":   {decoded: 30 * time.Second, encoded: "30"},
+				"1.5":  {decoded: 1500 * time.Millisecond, encoded: "1.5"},
+				"45s":  {decoded: 45 * time.Second, encoded: "45"},
+				"2m":   {decoded: time.Minute, encoded: "60"},
+				"3600": {decoded: time.Minute, encoded: "60"},
+			} {
+				req := httptest.NewRequest(http.MethodGet, path+"&timeout="+timeout, nil)
+				decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+				require.NoError(t, err)
+				assert.Equal(t, expected.decoded, decoded.(interface{ GetTimeout() time.Duration }).GetTimeout())
+
+				// The timeout is propagated to the encoded request, so that the querier enforces it.
+				encoded, err := codec.EncodeMetricsQueryRequest(user.InjectOrgID(context.Background(), "user-1"), decoded)
+				require.NoError(t, err)
+				if expected.encoded != "" {
+					assert.Equal(t, expected.encoded, encoded.URL.Query().Get(timeoutParam))
+				} else {
+					assert.False(t, encoded.URL.Query().Has(timeoutParam))
+				}
+
+				// The timeout is propagated to the requests derived with a different time range.
+				derived, err := decoded.WithStartEnd(decoded.GetStart(), decoded.GetEnd())
+				require.NoError(t, err)
+				assert.Equal(t, expected.decoded, derived.(interface{ GetTimeout() time.Duration }).GetTimeout())
+			}
+
+			for _, timeout := range []string{"0", "-1", "foo"} {
+				req := httptest.NewRequest(http.MethodGet, path+"&timeout="+timeout, nil)
+				_, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+				require.Error(t, err)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.Contains(t, err.Error(), `invalid parameter "timeout"`)
+			}
+		})
+	}
+}
+
+func TestCodec_DecodeMetricsQueryRequest_TimeoutWithoutMax(t *testing.T) {
+	codec := newTestCodec()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=foo&time=180&timeout=1h", nil)
+	decoded, err := codec.DecodeMetricsQueryRequest(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, decoded.(interface{ GetTimeout() time.Duration }).GetTimeout())
+}
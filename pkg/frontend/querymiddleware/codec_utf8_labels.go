@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// WithUTF8LabelsValidation controls whether decoded responses are checked for label names and values which aren't
+// valid UTF-8, and rejected with an internal error identifying the offending series. It protects clients from data
+// corruption or encoding bugs of the downstream components, but adds a cost per decoded label. Defaults to disabled.
+func WithUTF8LabelsValidation(enabled bool) CodecOption {
+	return func(c *Codec) {
+		c.validateUTF8Labels = enabled
+	}
+}
+
+// validateUTF8QueryResponseLabels checks that the labels of all series in resp are valid UTF-8.
+func validateUTF8QueryResponseLabels(resp *PrometheusResponse) error {
+	if resp.Data == nil {
+		return nil
+	}
+
+	for _, series := range resp.Data.Result {
+		for _, l := range series.Labels {
+			if !utf8.ValidString(l.Name) || !utf8.ValidString(l.Value) {
+				return fmt.Errorf("label %q of series %s is not valid UTF-8", l.Name, mimirpb.FromLabelAdaptersToString(series.Labels))
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateUTF8LabelsResponse checks that the label names or values in resp are valid UTF-8.
+func validateUTF8LabelsResponse(resp *PrometheusLabelsResponse) error {
+	for _, v := range resp.Data {
+		if !utf8.ValidString(v) {
+			return fmt.Errorf("label name or value %q is not valid UTF-8", v)
+		}
+	}
+
+	return nil
+}
+
+// validateUTF8SeriesResponse checks that the labels of all series in resp are valid UTF-8.
+func validateUTF8SeriesResponse(resp *PrometheusSeriesResponse) error {
+	for _, series := range resp.Data {
+		for name, value := range series {
+			if !utf8.ValidString(name) || !utf8.ValidString(value) {
+				return fmt.Errorf("label %q of series %s is not valid UTF-8", name, labels.FromMap(series).String())
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+)
+
+type instantQueryAsRangeQueryMiddleware struct {
+	next       MetricsQueryHandler
+	rangeQuery MetricsQueryHandler
+	codec      Codec
+}
+
+// newInstantQueryAsRangeQueryMiddleware creates a middleware that converts the instant queries to equivalent
+// single-step range queries with Codec.InstantQueryAsRangeQuery, and runs them through the input range query
+// middleware, so that they can be split and cached like range queries. The instant queries that can't be converted
+// are passed to the next handler unchanged.
+func newInstantQueryAsRangeQueryMiddleware(codec Codec, rangeQueryMiddleware MetricsQueryMiddleware) MetricsQueryMiddleware {
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &instantQueryAsRangeQueryMiddleware{
+			next:       next,
+			rangeQuery: rangeQueryMiddleware.Wrap(next),
+			codec:      codec,
+		}
+	})
+}
+
+func (m *instantQueryAsRangeQueryMiddleware) Do(ctx context.Context, req MetricsQueryRequest) (Response, error) {
+	instantReq, ok := req.(*PrometheusInstantQueryRequest)
+	if !ok {
+		return m.next.Do(ctx, req)
+	}
+
+	rangeReq, ok := m.codec.InstantQueryAsRangeQuery(instantReq)
+	if !ok {
+		return m.next.Do(ctx, req)
+	}
+
+	resp, err := m.rangeQuery.Do(ctx, rangeReq)
+	if err != nil {
+		return nil, err
+	}
+	return m.codec.RangeQueryResponseAsInstantQueryResponse(resp)
+}
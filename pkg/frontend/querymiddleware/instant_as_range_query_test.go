@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestInstantQueryAsRangeQueryMiddleware(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, WithInstantQueriesAsRangeQueries(true))
+
+	var rangeQueryMiddlewareRequests []MetricsQueryRequest
+	rangeQueryMiddleware := MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return HandlerFunc(func(ctx context.Context, req MetricsQueryRequest) (Response, error) {
+			rangeQueryMiddlewareRequests = append(rangeQueryMiddlewareRequests, req)
+			return next.Do(ctx, req)
+		})
+	})
+
+	var downstreamRequests []MetricsQueryRequest
+	downstream := HandlerFunc(func(_ context.Context, req MetricsQueryRequest) (Response, error) {
+		downstreamRequests = append(downstreamRequests, req)
+
+		resultType := model.ValVector
+		if _, ok := req.(*PrometheusRangeQueryRequest); ok {
+			resultType = model.ValMatrix
+		}
+		return &PrometheusResponse{
+			Status: statusSuccess,
+			Data: &PrometheusData{
+				ResultType: resultType.String(),
+				Result: []SampleStream{{
+					Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "up"}},
+					Samples: []mimirpb.Sample{{TimestampMs: 60000, Value: 1}},
+				}},
+			},
+		}, nil
+	})
+
+	handler := newInstantQueryAsRangeQueryMiddleware(codec, rangeQueryMiddleware).Wrap(downstream)
+
+	newInstantRequest := func(t *testing.T, query string) *PrometheusInstantQueryRequest {
+		expr, err := parser.ParseExpr(query)
+		require.NoError(t, err)
+		return NewPrometheusInstantQueryRequest("/prometheus/api/v1/query", nil, 60000, 5*time.Minute, expr, Options{}, nil, "")
+	}
+
+	t.Run("convertible instant query", func(t *testing.T) {
+		rangeQueryMiddlewareRequests, downstreamRequests = nil, nil
+
+		resp, err := handler.Do(context.Background(), newInstantRequest(t, "up"))
+		require.NoError(t, err)
+
+		require.Len(t, rangeQueryMiddlewareRequests, 1)
+		require.Len(t, downstreamRequests, 1)
+		assert.IsType(t, &PrometheusRangeQueryRequest{}, downstreamRequests[0])
+
+		promResp, ok := resp.GetPrometheusResponse()
+		require.True(t, ok)
+		assert.Equal(t, model.ValVector.String(), promResp.Data.ResultType)
+		require.Len(t, promResp.Data.Result, 1)
+	})
+
+	t.Run("non convertible instant query", func(t *testing.T) {
+		rangeQueryMiddlewareRequests, downstreamRequests = nil, nil
+
+		_, err := handler.Do(context.Background(), newInstantRequest(t, "scalar(up)"))
+		require.NoError(t, err)
+
+		assert.Empty(t, rangeQueryMiddlewareRequests)
+		require.Len(t, downstreamRequests, 1)
+		assert.IsType(t, &PrometheusInstantQueryRequest{}, downstreamRequests[0])
+	})
+}
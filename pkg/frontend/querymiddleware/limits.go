@@ -226,7 +226,7 @@ func NewLimitedParallelismRoundTripper(next http.RoundTripper, codec Codec, limi
 }
 
 func (rt limitedParallelismRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	ctx, cancel := context.WithCancelCause(r.Context())
+	ctx, cancel := context.WithCancelCause(rt.codec.withServerTimings(r.Context()))
 	defer cancel(errExecutingParallelQueriesFinished)
 
 	request, err := rt.codec.DecodeMetricsQueryRequest(ctx, r)
@@ -267,7 +267,13 @@ func (rt limitedParallelismRoundTripper) RoundTrip(r *http.Request) (*http.Respo
 	}
 
 	// EncodeMetricsQueryResponse handles closing the response
-	return rt.codec.EncodeMetricsQueryResponse(ctx, r, response)
+	encoded, err := rt.codec.EncodeMetricsQueryResponse(ctx, r, response)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.codec.addQueryTimeRangeHeaders(encoded.Header, request)
+	return encoded, nil
 }
 
 // roundTripperHandler is an adapter that implements the MetricsQueryHandler interface using a http.RoundTripper to perform
@@ -6,8 +6,10 @@
 package querymiddleware
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -17,6 +19,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/tenant"
 	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/promql/parser"
@@ -26,6 +29,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
+	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
@@ -1086,6 +1090,77 @@ func BenchmarkLimitedParallelismRoundTripper(b *testing.B) {
 	}
 }
 
+func TestLimitedRoundTripper_ShouldEncodeResponseInFormatAcceptedByClient(t *testing.T) {
+	expected := mockPrometheusResponseSingleSeries(
+		[]mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}},
+		mimirpb.Sample{TimestampMs: 1000, Value: 1},
+		mimirpb.Sample{TimestampMs: 2000, Value: 2},
+	)
+
+	protobufBody, err := protobufFormatter{}.EncodeQueryResponse(expected)
+	require.NoError(t, err)
+
+	jsonBody, err := jsonFormatter{}.EncodeQueryResponse(expected)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		acceptHeader        string
+		expectedContentType string
+		expectedBody        []byte
+	}{
+		"client accepting only JSON": {
+			acceptHeader:        jsonMimeType,
+			expectedContentType: jsonMimeType,
+			expectedBody:        jsonBody,
+		},
+		"client not expressing a preference": {
+			acceptHeader:        "",
+			expectedContentType: jsonMimeType,
+			expectedBody:        jsonBody,
+		},
+		"client accepting protobuf": {
+			acceptHeader:        mimirpb.QueryResponseMimeType + "," + jsonMimeType,
+			expectedContentType: mimirpb.QueryResponseMimeType,
+			expectedBody:        protobufBody,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// The query-frontend prefers protobuf when querying downstream, regardless of the client.
+			downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, mimirpb.QueryResponseMimeType+","+jsonMimeType, req.Header.Get("Accept"))
+
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{"Content-Type": []string{mimirpb.QueryResponseMimeType}},
+					Body:          io.NopCloser(bytes.NewReader(protobufBody)),
+					ContentLength: int64(len(protobufBody)),
+				}, nil
+			})
+
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatProtobuf, nil)
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+
+			req, err := newTestCodec().EncodeMetricsQueryRequest(ctx, NewPrometheusRangeQueryRequest(
+				"/api/v1/query_range", nil, 1000, 2000, 1000, 0, parseQuery(t, `foo`), Options{}, nil, "",
+			))
+			require.NoError(t, err)
+			req.Header.Set("Accept", testData.acceptHeader)
+
+			res, err := NewLimitedParallelismRoundTripper(downstream, codec, mockLimits{maxQueryParallelism: 1}).RoundTrip(req)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, testData.expectedContentType, res.Header.Get("Content-Type"))
+			assert.Equal(t, testData.expectedBody, body)
+		})
+	}
+}
+
 func TestSmallestPositiveNonZeroDuration(t *testing.T) {
 	assert.Equal(t, time.Duration(0), smallestPositiveNonZeroDuration())
 	assert.Equal(t, time.Duration(0), smallestPositiveNonZeroDuration(0))
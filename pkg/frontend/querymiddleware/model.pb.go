@@ -479,9 +479,11 @@ func (m *Extent) GetSamplesProcessedPerStep() []stats.StepStat {
 }
 
 type Options struct {
-	CacheDisabled    bool  `protobuf:"varint,1,opt,name=CacheDisabled,proto3" json:"CacheDisabled,omitempty"`
-	ShardingDisabled bool  `protobuf:"varint,2,opt,name=ShardingDisabled,proto3" json:"ShardingDisabled,omitempty"`
-	TotalShards      int32 `protobuf:"varint,3,opt,name=TotalShards,proto3" json:"TotalShards,omitempty"`
+	CacheDisabled        bool  `protobuf:"varint,1,opt,name=CacheDisabled,proto3" json:"CacheDisabled,omitempty"`
+	ShardingDisabled     bool  `protobuf:"varint,2,opt,name=ShardingDisabled,proto3" json:"ShardingDisabled,omitempty"`
+	TotalShards          int32 `protobuf:"varint,3,opt,name=TotalShards,proto3" json:"TotalShards,omitempty"`
+	AllowPartialResponse bool  `protobuf:"varint,6,opt,name=AllowPartialResponse,proto3" json:"AllowPartialResponse,omitempty"`
+	MaxQueryParallelism  int32 `protobuf:"varint,8,opt,name=MaxQueryParallelism,proto3" json:"MaxQueryParallelism,omitempty"`
 }
 
 func (m *Options) Reset()      { *m = Options{} }
@@ -537,6 +539,20 @@ func (m *Options) GetTotalShards() int32 {
 	return 0
 }
 
+func (m *Options) GetAllowPartialResponse() bool {
+	if m != nil {
+		return m.AllowPartialResponse
+	}
+	return false
+}
+
+func (m *Options) GetMaxQueryParallelism() int32 {
+	if m != nil {
+		return m.MaxQueryParallelism
+	}
+	return 0
+}
+
 type QueryStatistics struct {
 	EstimatedSeriesCount uint64 `protobuf:"varint,1,opt,name=EstimatedSeriesCount,proto3" json:"EstimatedSeriesCount,omitempty"`
 	UserID               string `protobuf:"bytes,2,opt,name=UserID,proto3" json:"UserID,omitempty"`
@@ -730,77 +746,80 @@ func init() {
 func init() { proto.RegisterFile("model.proto", fileDescriptor_4c16552f9fdb66d8) }
 
 var fileDescriptor_4c16552f9fdb66d8 = []byte{
-	// 1113 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0x4f, 0x8f, 0x1b, 0x35,
-	0x14, 0xcf, 0xe4, 0x7f, 0x5e, 0xb6, 0xbb, 0x91, 0xbb, 0x6a, 0xd3, 0x05, 0x66, 0xa2, 0x11, 0x87,
-	0x05, 0x4a, 0x02, 0x0b, 0xf4, 0x80, 0x28, 0xa2, 0xd9, 0x2e, 0x6a, 0x4b, 0x0b, 0xc1, 0x59, 0x40,
-	0x42, 0x42, 0x91, 0x93, 0x71, 0x27, 0x43, 0x33, 0xe3, 0xc1, 0x76, 0x68, 0x73, 0x43, 0xdc, 0x10,
-	0x12, 0xe2, 0x13, 0x70, 0xe6, 0xc4, 0xe7, 0xe8, 0x71, 0x8f, 0x15, 0x87, 0x11, 0x9b, 0xbd, 0xa0,
-	0x39, 0xf5, 0x03, 0x70, 0x40, 0xb6, 0x67, 0x92, 0x2c, 0xad, 0x2a, 0x2e, 0xc9, 0xf3, 0xcf, 0xbf,
-	0x9f, 0xfd, 0xde, 0xf3, 0x7b, 0x6f, 0xa0, 0x19, 0x32, 0x8f, 0xce, 0xba, 0x31, 0x67, 0x92, 0x21,
-	0xf8, 0x6e, 0x4e, 0xf9, 0x82, 0x93, 0xc8, 0xa7, 0x7b, 0x6f, 0xf9, 0x81, 0x9c, 0xce, 0xc7, 0xdd,
-	0x09, 0x0b, 0x7b, 0x3e, 0x27, 0xf7, 0x49, 0x44, 0x7a, 0x61, 0x10, 0x06, 0xbc, 0x17, 0x3f, 0xf0,
-	0x8d, 0x15, 0x8f, 0xcd, 0xbf, 0x51, 0xef, 0x5d, 0x7b, 0xa1, 0x42, 0x1d, 0x1d, 0x50, 0xde, 0x13,
-	0x92, 0x48, 0x61, 0x7e, 0x33, 0xdd, 0xae, 0xcf, 0x7c, 0xa6, 0xcd, 0x9e, 0xb2, 0x32, 0xf4, 0x8a,
-	0xcf, 0x98, 0x3f, 0xa3, 0x3d, 0xbd, 0x1a, 0xcf, 0xef, 0xf7, 0x48, 0xb4, 0x30, 0x5b, 0xee, 0x5d,
-	0x68, 0x0d, 0x38, 0x0b, 0xa9, 0x9c, 0xd2, 0xb9, 0xb8, 0x45, 0x89, 0x47, 0x39, 0xba, 0x02, 0xe5,
-	0x4f, 0x49, 0x48, 0xdb, 0x56, 0xc7, 0xda, 0x6f, 0xf4, 0x2b, 0x69, 0xe2, 0x58, 0x6f, 0x62, 0x0d,
-	0xa1, 0x57, 0xa0, 0xfa, 0x25, 0x99, 0xcd, 0xa9, 0x68, 0x17, 0x3b, 0xa5, 0xf5, 0x66, 0x06, 0xba,
-	0xff, 0x14, 0x01, 0xad, 0x8f, 0xc3, 0x54, 0xc4, 0x2c, 0x12, 0x14, 0xb9, 0x50, 0x1d, 0x4a, 0x22,
-	0xe7, 0x22, 0x3b, 0x12, 0xd2, 0xc4, 0xa9, 0x0a, 0x8d, 0xe0, 0x6c, 0x07, 0xf5, 0xa1, 0x7c, 0x93,
-	0x48, 0xd2, 0x2e, 0x76, 0xac, 0xfd, 0xe6, 0xc1, 0x5e, 0x77, 0x9d, 0xbe, 0xee, 0xfa, 0x44, 0xc5,
-	0xe8, 0xa3, 0x34, 0x71, 0xb6, 0x3d, 0x22, 0xc9, 0x55, 0x16, 0x06, 0x92, 0x86, 0xb1, 0x5c, 0x60,
-	0xad, 0x45, 0xef, 0x41, 0xe3, 0x88, 0x73, 0xc6, 0x8f, 0x17, 0x31, 0x6d, 0x97, 0xf4, 0x55, 0x97,
-	0xd3, 0xc4, 0xb9, 0x48, 0x73, 0x70, 0x43, 0xb1, 0x66, 0xa2, 0xd7, 0xa0, 0xa2, 0x17, 0xed, 0xb2,
-	0x96, 0x5c, 0x4c, 0x13, 0x67, 0x47, 0x4b, 0x36, 0xe8, 0x86, 0x81, 0xae, 0x43, 0xcd, 0x24, 0x49,
-	0xb4, 0x2b, 0x9d, 0xd2, 0x7e, 0xf3, 0xe0, 0xe5, 0xe7, 0x3b, 0x6a, 0x48, 0x79, 0x7a, 0x72, 0x0d,
-	0x3a, 0x80, 0xfa, 0x57, 0x84, 0x47, 0x41, 0xe4, 0x8b, 0x76, 0x55, 0x27, 0xf0, 0x52, 0x9a, 0x38,
-	0xe8, 0x61, 0x86, 0x6d, 0xdc, 0xb7, 0xe2, 0x29, 0xef, 0x6e, 0x47, 0xf7, 0x99, 0x68, 0xd7, 0xb4,
-	0x40, 0x7b, 0x17, 0x28, 0x60, 0xd3, 0x3b, 0xcd, 0x70, 0x7f, 0xb4, 0x60, 0xfb, 0x7c, 0xb2, 0x50,
-	0x17, 0x00, 0x53, 0x31, 0x9f, 0x49, 0x9d, 0x13, 0x93, 0xfe, 0xed, 0x34, 0x71, 0x80, 0xaf, 0x50,
-	0xbc, 0xc1, 0x40, 0x1f, 0x41, 0xd5, 0xac, 0xf4, 0x03, 0x37, 0x0f, 0xda, 0x9b, 0xf1, 0x0d, 0x49,
-	0x18, 0xcf, 0xe8, 0x50, 0x72, 0x4a, 0xc2, 0xfe, 0xf6, 0xe3, 0xc4, 0x29, 0xa8, 0x87, 0x34, 0x27,
-	0xe1, 0x4c, 0xe7, 0xfe, 0x52, 0x84, 0xad, 0x4d, 0x22, 0x8a, 0xa1, 0x3a, 0x23, 0x63, 0x3a, 0x53,
-	0xaf, 0xaf, 0x8e, 0xbc, 0xd8, 0x9d, 0x30, 0x2e, 0xe9, 0xa3, 0x78, 0xdc, 0xbd, 0xab, 0xf0, 0x01,
-	0x09, 0x78, 0xff, 0x50, 0x9d, 0xf6, 0x67, 0xe2, 0xbc, 0xfd, 0x7f, 0x5a, 0xc5, 0xe8, 0x6e, 0x78,
-	0x24, 0x96, 0x94, 0x2b, 0x17, 0x42, 0x2a, 0x79, 0x30, 0xc1, 0xd9, 0x3d, 0xe8, 0x7d, 0xa8, 0x09,
-	0xed, 0x81, 0xc8, 0xa2, 0x68, 0xad, 0xaf, 0x34, 0xae, 0xad, 0xbd, 0xff, 0x5e, 0x57, 0x2e, 0xce,
-	0x05, 0x68, 0x00, 0x30, 0x0d, 0x84, 0x64, 0x3e, 0x27, 0xa1, 0x68, 0x97, 0xb2, 0x47, 0x5e, 0xc9,
-	0x3f, 0x9e, 0x31, 0x22, 0x6f, 0xe5, 0x04, 0xed, 0x3a, 0xca, 0x8e, 0xda, 0xd0, 0xe1, 0x0d, 0xdb,
-	0xfd, 0xd9, 0x82, 0xe6, 0x21, 0x99, 0x4c, 0xa9, 0x67, 0x6a, 0xe8, 0x0a, 0x94, 0x1e, 0xd0, 0x45,
-	0xf6, 0x16, 0xb5, 0x34, 0x71, 0xd4, 0x12, 0xab, 0x1f, 0xf4, 0x06, 0x34, 0x56, 0xb5, 0xaa, 0x3b,
-	0xa1, 0xd1, 0xbf, 0x90, 0x26, 0xce, 0x1a, 0xc4, 0x6b, 0x13, 0xbd, 0x0b, 0x5b, 0x7a, 0x71, 0x8f,
-	0x0a, 0x41, 0xfc, 0xbc, 0xe0, 0x5b, 0x69, 0xe2, 0x9c, 0xc3, 0xf1, 0xb9, 0x95, 0xfb, 0x2d, 0x6c,
-	0x1b, 0x67, 0x56, 0xdd, 0xf9, 0x02, 0x7f, 0xae, 0x43, 0x8d, 0x3e, 0x92, 0x34, 0x92, 0x79, 0x22,
-	0xd1, 0x66, 0x39, 0x1c, 0xe9, 0xad, 0xfe, 0x4e, 0x16, 0x7f, 0x4e, 0xc5, 0xb9, 0xe1, 0xfe, 0x51,
-	0x84, 0xaa, 0x21, 0x21, 0x07, 0x2a, 0x42, 0x12, 0x2e, 0xf5, 0x35, 0xa5, 0x7e, 0x23, 0x4d, 0x1c,
-	0x03, 0x60, 0xf3, 0xa7, 0xbc, 0xa0, 0x91, 0xa7, 0x83, 0x2e, 0x19, 0x2f, 0x68, 0xe4, 0x61, 0xf5,
-	0x83, 0x3a, 0x50, 0x97, 0x9c, 0x4c, 0xe8, 0x28, 0xf0, 0xb2, 0x16, 0xcd, 0xfb, 0x4a, 0xc3, 0xb7,
-	0x3d, 0xf4, 0x21, 0xd4, 0x79, 0x16, 0x4e, 0xbb, 0xa2, 0x07, 0xc8, 0x6e, 0xd7, 0xcc, 0xbc, 0x6e,
-	0x3e, 0xf3, 0xba, 0x37, 0xa2, 0x45, 0x7f, 0x2b, 0x4d, 0x9c, 0x15, 0x13, 0xaf, 0x2c, 0x74, 0x15,
-	0x90, 0x8e, 0x6b, 0x24, 0x83, 0x90, 0x0a, 0x49, 0xc2, 0x78, 0x14, 0xaa, 0x0e, 0xb5, 0xf6, 0x4b,
-	0xb8, 0xa5, 0x77, 0x8e, 0xf3, 0x8d, 0x7b, 0x02, 0x61, 0xd8, 0xcb, 0xaa, 0x65, 0x14, 0x73, 0x36,
-	0xa1, 0x42, 0x50, 0x6f, 0x14, 0x53, 0x3e, 0x12, 0x92, 0xc6, 0xba, 0x4d, 0x9b, 0x07, 0x3b, 0x5d,
-	0x33, 0x96, 0x87, 0x92, 0xc6, 0x6a, 0xc2, 0xf5, 0xcb, 0x2a, 0x4b, 0xf8, 0x72, 0x26, 0x1c, 0xe4,
-	0xba, 0x01, 0xe5, 0x8a, 0x72, 0xa7, 0x5c, 0x2f, 0xb5, 0xca, 0xee, 0x4f, 0x16, 0xd4, 0x3e, 0x8b,
-	0x65, 0xc0, 0x22, 0x81, 0x5e, 0x85, 0x0b, 0xfa, 0xa1, 0x6e, 0x06, 0x82, 0x8c, 0x67, 0xd4, 0xd3,
-	0x99, 0xab, 0xe3, 0xf3, 0x20, 0x7a, 0x1d, 0x5a, 0xc3, 0x29, 0xe1, 0x5e, 0x10, 0xf9, 0x2b, 0x62,
-	0x51, 0x13, 0x9f, 0xc1, 0x51, 0x07, 0x9a, 0xc7, 0x4c, 0x92, 0x99, 0xde, 0x10, 0xba, 0x5e, 0x2a,
-	0x78, 0x13, 0xba, 0x53, 0xae, 0x97, 0x5b, 0x95, 0x3b, 0xe5, 0x7a, 0xa5, 0x55, 0x75, 0xbf, 0x81,
-	0x9d, 0xcf, 0x55, 0xe4, 0xca, 0xfb, 0x40, 0xc8, 0x60, 0xa2, 0xc6, 0xd7, 0xee, 0x91, 0x90, 0x41,
-	0x48, 0x24, 0xf5, 0x86, 0xea, 0x13, 0x24, 0x0e, 0xd9, 0x3c, 0x32, 0x6f, 0x5a, 0xc6, 0xcf, 0xdd,
-	0x43, 0x97, 0xa0, 0xfa, 0x85, 0xa0, 0xfc, 0xf6, 0x4d, 0x53, 0xcf, 0x38, 0x5b, 0xb9, 0xbf, 0x59,
-	0x80, 0x4c, 0x21, 0xde, 0x3a, 0x3e, 0x1e, 0xac, 0x8a, 0xf1, 0x25, 0x68, 0x4c, 0x14, 0x3a, 0x5a,
-	0x95, 0x24, 0xae, 0x6b, 0xe0, 0x13, 0xba, 0x40, 0x0e, 0x34, 0xcd, 0x57, 0x63, 0x34, 0x61, 0x9e,
-	0x69, 0x90, 0x0a, 0x06, 0x03, 0x1d, 0x32, 0x8f, 0xa2, 0x6b, 0x50, 0x9b, 0x66, 0xe3, 0xb9, 0xf4,
-	0xec, 0x78, 0x5e, 0x5f, 0x67, 0xe6, 0x31, 0xce, 0xc9, 0x08, 0x41, 0x79, 0xcc, 0xbc, 0x85, 0xae,
-	0xae, 0x2d, 0xac, 0x6d, 0xf7, 0x03, 0x68, 0xfd, 0x57, 0xa0, 0x78, 0xd1, 0xea, 0xcb, 0x88, 0xb5,
-	0x8d, 0x76, 0xa1, 0xa2, 0x67, 0x48, 0x16, 0x9f, 0x59, 0xf4, 0x8f, 0x4e, 0x4e, 0xed, 0xc2, 0x93,
-	0x53, 0xbb, 0xf0, 0xf4, 0xd4, 0xb6, 0x7e, 0x58, 0xda, 0xd6, 0xef, 0x4b, 0xdb, 0x7a, 0xbc, 0xb4,
-	0xad, 0x93, 0xa5, 0x6d, 0xfd, 0xb5, 0xb4, 0xad, 0xbf, 0x97, 0x76, 0xe1, 0xe9, 0xd2, 0xb6, 0x7e,
-	0x3d, 0xb3, 0x0b, 0x27, 0x67, 0x76, 0xe1, 0xc9, 0x99, 0x5d, 0xf8, 0x7a, 0x47, 0x7b, 0x1b, 0x06,
-	0x9e, 0x37, 0xa3, 0x0f, 0x09, 0xa7, 0xe3, 0xaa, 0x2e, 0xdf, 0x77, 0xfe, 0x0d, 0x00, 0x00, 0xff,
-	0xff, 0xff, 0x9c, 0x12, 0xaa, 0x5b, 0x08, 0x00, 0x00,
+	// 1153 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcf, 0x6f, 0x1b, 0xc5,
+	0x17, 0xf7, 0xc6, 0x3f, 0xf3, 0x9c, 0x26, 0xd6, 0x24, 0x6a, 0xb7, 0xf9, 0x7e, 0xd9, 0xb5, 0x56,
+	0x1c, 0x02, 0x14, 0xbb, 0x04, 0xe8, 0x01, 0x51, 0x44, 0x9d, 0x06, 0xb5, 0xa5, 0x05, 0x33, 0x09,
+	0x20, 0x21, 0x21, 0x6b, 0xec, 0x9d, 0x6e, 0x96, 0xee, 0xee, 0x2c, 0x33, 0x63, 0x5a, 0xdf, 0x10,
+	0x57, 0x24, 0xc4, 0x5f, 0xc0, 0x99, 0x13, 0x7f, 0x47, 0x8f, 0x3d, 0x56, 0x1c, 0x56, 0xd4, 0xb9,
+	0xa0, 0xbd, 0xd0, 0x3f, 0x80, 0x03, 0x9a, 0x99, 0x5d, 0xdb, 0xa1, 0x51, 0xc5, 0x65, 0xf7, 0xbd,
+	0xcf, 0xfb, 0xbc, 0x37, 0x6f, 0xde, 0xbc, 0x37, 0x03, 0xed, 0x98, 0xf9, 0x34, 0xea, 0xa5, 0x9c,
+	0x49, 0x86, 0xe0, 0xdb, 0x29, 0xe5, 0x33, 0x4e, 0x92, 0x80, 0xee, 0x5e, 0x0d, 0x42, 0x79, 0x32,
+	0x1d, 0xf7, 0x26, 0x2c, 0xee, 0x07, 0x9c, 0xdc, 0x27, 0x09, 0xe9, 0xc7, 0x61, 0x1c, 0xf2, 0x7e,
+	0xfa, 0x20, 0x30, 0x52, 0x3a, 0x36, 0x7f, 0xe3, 0xbd, 0x7b, 0xed, 0xa5, 0x1e, 0x2a, 0x74, 0x48,
+	0x79, 0x5f, 0x48, 0x22, 0x85, 0xf9, 0x16, 0x7e, 0x3b, 0x01, 0x0b, 0x98, 0x16, 0xfb, 0x4a, 0x2a,
+	0xd0, 0xcb, 0x01, 0x63, 0x41, 0x44, 0xfb, 0x5a, 0x1b, 0x4f, 0xef, 0xf7, 0x49, 0x32, 0x33, 0x26,
+	0xef, 0x2e, 0x74, 0x86, 0x9c, 0xc5, 0x54, 0x9e, 0xd0, 0xa9, 0xb8, 0x45, 0x89, 0x4f, 0x39, 0xba,
+	0x0c, 0xb5, 0x4f, 0x48, 0x4c, 0x6d, 0xab, 0x6b, 0xed, 0xad, 0x0f, 0xea, 0x79, 0xe6, 0x5a, 0x6f,
+	0x62, 0x0d, 0xa1, 0x57, 0xa0, 0xf1, 0x05, 0x89, 0xa6, 0x54, 0xd8, 0x6b, 0xdd, 0xea, 0xd2, 0x58,
+	0x80, 0xde, 0xdf, 0x6b, 0x80, 0x96, 0xe1, 0x30, 0x15, 0x29, 0x4b, 0x04, 0x45, 0x1e, 0x34, 0x8e,
+	0x24, 0x91, 0x53, 0x51, 0x84, 0x84, 0x3c, 0x73, 0x1b, 0x42, 0x23, 0xb8, 0xb0, 0xa0, 0x01, 0xd4,
+	0x6e, 0x12, 0x49, 0xec, 0xb5, 0xae, 0xb5, 0xd7, 0xde, 0xdf, 0xed, 0x2d, 0xcb, 0xd7, 0x5b, 0x46,
+	0x54, 0x8c, 0x01, 0xca, 0x33, 0x77, 0xd3, 0x27, 0x92, 0x5c, 0x61, 0x71, 0x28, 0x69, 0x9c, 0xca,
+	0x19, 0xd6, 0xbe, 0xe8, 0x5d, 0x58, 0x3f, 0xe4, 0x9c, 0xf1, 0xe3, 0x59, 0x4a, 0xed, 0xaa, 0x5e,
+	0xea, 0x52, 0x9e, 0xb9, 0xdb, 0xb4, 0x04, 0x57, 0x3c, 0x96, 0x4c, 0xf4, 0x1a, 0xd4, 0xb5, 0x62,
+	0xd7, 0xb4, 0xcb, 0x76, 0x9e, 0xb9, 0x5b, 0xda, 0x65, 0x85, 0x6e, 0x18, 0xe8, 0x3a, 0x34, 0x4d,
+	0x91, 0x84, 0x5d, 0xef, 0x56, 0xf7, 0xda, 0xfb, 0xff, 0x3f, 0x3f, 0x51, 0x43, 0x2a, 0xcb, 0x53,
+	0xfa, 0xa0, 0x7d, 0x68, 0x7d, 0x49, 0x78, 0x12, 0x26, 0x81, 0xb0, 0x1b, 0xba, 0x80, 0x17, 0xf3,
+	0xcc, 0x45, 0x0f, 0x0b, 0x6c, 0x65, 0xbd, 0x05, 0x4f, 0x65, 0x77, 0x3b, 0xb9, 0xcf, 0x84, 0xdd,
+	0xec, 0x56, 0xcb, 0xec, 0x42, 0x05, 0xac, 0x66, 0xa7, 0x19, 0xde, 0x0f, 0x16, 0x6c, 0x9e, 0x2d,
+	0x16, 0xea, 0x01, 0x60, 0x2a, 0xa6, 0x91, 0xd4, 0x35, 0x31, 0xe5, 0xdf, 0xcc, 0x33, 0x17, 0xf8,
+	0x02, 0xc5, 0x2b, 0x0c, 0xf4, 0x21, 0x34, 0x8c, 0xa6, 0x0f, 0xb8, 0xbd, 0x6f, 0xaf, 0xee, 0xef,
+	0x88, 0xc4, 0x69, 0x44, 0x8f, 0x24, 0xa7, 0x24, 0x1e, 0x6c, 0x3e, 0xce, 0xdc, 0x8a, 0x3a, 0x48,
+	0x13, 0x09, 0x17, 0x7e, 0xde, 0x4f, 0x6b, 0xb0, 0xb1, 0x4a, 0x44, 0x29, 0x34, 0x22, 0x32, 0xa6,
+	0x91, 0x3a, 0x7d, 0x15, 0x72, 0xbb, 0x37, 0x61, 0x5c, 0xd2, 0x47, 0xe9, 0xb8, 0x77, 0x57, 0xe1,
+	0x43, 0x12, 0xf2, 0xc1, 0x81, 0x8a, 0xf6, 0x7b, 0xe6, 0xbe, 0xf5, 0x5f, 0x46, 0xc5, 0xf8, 0xdd,
+	0xf0, 0x49, 0x2a, 0x29, 0x57, 0x29, 0xc4, 0x54, 0xf2, 0x70, 0x82, 0x8b, 0x75, 0xd0, 0x7b, 0xd0,
+	0x14, 0x3a, 0x03, 0x51, 0xec, 0xa2, 0xb3, 0x5c, 0xd2, 0xa4, 0xb6, 0xcc, 0xfe, 0x3b, 0xdd, 0xb9,
+	0xb8, 0x74, 0x40, 0x43, 0x80, 0x93, 0x50, 0x48, 0x16, 0x70, 0x12, 0x0b, 0xbb, 0x5a, 0x1c, 0xf2,
+	0xc2, 0xfd, 0xa3, 0x88, 0x11, 0x79, 0xab, 0x24, 0xe8, 0xd4, 0x51, 0x11, 0x6a, 0xc5, 0x0f, 0xaf,
+	0xc8, 0xde, 0x8f, 0x16, 0xb4, 0x0f, 0xc8, 0xe4, 0x84, 0xfa, 0xa6, 0x87, 0x2e, 0x43, 0xf5, 0x01,
+	0x9d, 0x15, 0x67, 0xd1, 0xcc, 0x33, 0x57, 0xa9, 0x58, 0x7d, 0xd0, 0x1b, 0xb0, 0xbe, 0xe8, 0x55,
+	0x3d, 0x09, 0xeb, 0x83, 0x0b, 0x79, 0xe6, 0x2e, 0x41, 0xbc, 0x14, 0xd1, 0x3b, 0xb0, 0xa1, 0x95,
+	0x7b, 0x54, 0x08, 0x12, 0x94, 0x0d, 0xdf, 0xc9, 0x33, 0xf7, 0x0c, 0x8e, 0xcf, 0x68, 0xde, 0x37,
+	0xb0, 0x69, 0x92, 0x59, 0x4c, 0xe7, 0x4b, 0xf2, 0xb9, 0x0e, 0x4d, 0xfa, 0x48, 0xd2, 0x44, 0x96,
+	0x85, 0x44, 0xab, 0xed, 0x70, 0xa8, 0x4d, 0x83, 0xad, 0x62, 0xff, 0x25, 0x15, 0x97, 0x82, 0xf7,
+	0xdb, 0x1a, 0x34, 0x0c, 0x09, 0xb9, 0x50, 0x17, 0x92, 0x70, 0xa9, 0x97, 0xa9, 0x0e, 0xd6, 0xf3,
+	0xcc, 0x35, 0x00, 0x36, 0x3f, 0x95, 0x05, 0x4d, 0x7c, 0xbd, 0xe9, 0xaa, 0xc9, 0x82, 0x26, 0x3e,
+	0x56, 0x1f, 0xd4, 0x85, 0x96, 0xe4, 0x64, 0x42, 0x47, 0xa1, 0x5f, 0x8c, 0x68, 0x39, 0x57, 0x1a,
+	0xbe, 0xed, 0xa3, 0x0f, 0xa0, 0xc5, 0x8b, 0xed, 0xd8, 0x75, 0x7d, 0x81, 0xec, 0xf4, 0xcc, 0x9d,
+	0xd7, 0x2b, 0xef, 0xbc, 0xde, 0x8d, 0x64, 0x36, 0xd8, 0xc8, 0x33, 0x77, 0xc1, 0xc4, 0x0b, 0x09,
+	0x5d, 0x01, 0xa4, 0xf7, 0x35, 0x92, 0x61, 0x4c, 0x85, 0x24, 0x71, 0x3a, 0x8a, 0xd5, 0x84, 0x5a,
+	0x7b, 0x55, 0xdc, 0xd1, 0x96, 0xe3, 0xd2, 0x70, 0x4f, 0x20, 0x0c, 0xbb, 0x45, 0xb7, 0x8c, 0x52,
+	0xce, 0x26, 0x54, 0x08, 0xea, 0x8f, 0x52, 0xca, 0x47, 0x42, 0xd2, 0x54, 0x8f, 0x69, 0x7b, 0x7f,
+	0xab, 0x67, 0xae, 0xe5, 0x23, 0x49, 0x53, 0x75, 0xc3, 0x0d, 0x6a, 0xaa, 0x4a, 0xf8, 0x52, 0xe1,
+	0x38, 0x2c, 0xfd, 0x86, 0x94, 0x2b, 0xca, 0x9d, 0x5a, 0xab, 0xda, 0xa9, 0x79, 0x7f, 0x59, 0xd0,
+	0xfc, 0x34, 0x95, 0x21, 0x4b, 0x04, 0x7a, 0x15, 0x2e, 0xe8, 0x83, 0xba, 0x19, 0x0a, 0x32, 0x8e,
+	0xa8, 0xaf, 0x2b, 0xd7, 0xc2, 0x67, 0x41, 0xf4, 0x3a, 0x74, 0x8e, 0x4e, 0x08, 0xf7, 0xc3, 0x24,
+	0x58, 0x10, 0xd7, 0x34, 0xf1, 0x05, 0x1c, 0x75, 0xa1, 0x7d, 0xcc, 0x24, 0x89, 0xb4, 0x41, 0xe8,
+	0x7e, 0xa9, 0xe3, 0x55, 0x08, 0xed, 0xc3, 0xce, 0x8d, 0x28, 0x62, 0x0f, 0x87, 0x84, 0xcb, 0x90,
+	0x44, 0x65, 0x8b, 0xe8, 0x4a, 0xb4, 0xf0, 0xb9, 0x36, 0x74, 0x15, 0xb6, 0xef, 0x91, 0x47, 0x9f,
+	0xa9, 0x22, 0x0d, 0x09, 0x27, 0x51, 0x44, 0xa3, 0x50, 0xc4, 0x76, 0x4b, 0x47, 0x3f, 0xcf, 0x74,
+	0xa7, 0xd6, 0xaa, 0x75, 0xea, 0x77, 0x6a, 0xad, 0x7a, 0xa7, 0xe1, 0x7d, 0x0d, 0x5b, 0xda, 0xae,
+	0x6a, 0x14, 0x0a, 0x19, 0x4e, 0x74, 0x12, 0x87, 0x42, 0x86, 0x31, 0x91, 0xd4, 0x3f, 0x52, 0x0f,
+	0x9d, 0x38, 0x60, 0xd3, 0xc4, 0x74, 0x4e, 0x0d, 0x9f, 0x6b, 0x43, 0x17, 0xa1, 0xf1, 0xb9, 0xa0,
+	0xfc, 0xf6, 0x4d, 0x33, 0x35, 0xb8, 0xd0, 0xbc, 0x5f, 0x2c, 0x40, 0xa6, 0xdd, 0x6f, 0x1d, 0x1f,
+	0x0f, 0x17, 0x39, 0xff, 0x0f, 0xd6, 0x27, 0x0a, 0x1d, 0x2d, 0x1a, 0x1f, 0xb7, 0x34, 0xf0, 0x31,
+	0x9d, 0x21, 0x17, 0xda, 0xe6, 0x6d, 0x1a, 0x4d, 0x98, 0x6f, 0xc6, 0xb0, 0x8e, 0xc1, 0x40, 0x07,
+	0xcc, 0xa7, 0xe8, 0x1a, 0x34, 0x4f, 0x8a, 0x47, 0xa0, 0xfa, 0xe2, 0x23, 0xb0, 0x5c, 0xce, 0xdc,
+	0xfa, 0xb8, 0x24, 0x23, 0x04, 0xb5, 0x31, 0xf3, 0x67, 0xba, 0x87, 0x37, 0xb0, 0x96, 0xbd, 0xf7,
+	0xa1, 0xf3, 0x6f, 0x07, 0xc5, 0x4b, 0x16, 0xef, 0x2f, 0xd6, 0x32, 0xda, 0x81, 0xba, 0xbe, 0xa9,
+	0x8a, 0xfd, 0x19, 0x65, 0x70, 0xf8, 0xe4, 0x99, 0x53, 0x79, 0xfa, 0xcc, 0xa9, 0x3c, 0x7f, 0xe6,
+	0x58, 0xdf, 0xcf, 0x1d, 0xeb, 0xd7, 0xb9, 0x63, 0x3d, 0x9e, 0x3b, 0xd6, 0x93, 0xb9, 0x63, 0xfd,
+	0x31, 0x77, 0xac, 0x3f, 0xe7, 0x4e, 0xe5, 0xf9, 0xdc, 0xb1, 0x7e, 0x3e, 0x75, 0x2a, 0x4f, 0x4e,
+	0x9d, 0xca, 0xd3, 0x53, 0xa7, 0xf2, 0xd5, 0x96, 0xce, 0x36, 0x0e, 0x7d, 0x3f, 0xa2, 0x0f, 0x09,
+	0xa7, 0xe3, 0x86, 0x1e, 0x92, 0xb7, 0xff, 0x19, 0x00, 0x6a, 0xd4, 0xb6, 0x80, 0xc1, 0x08, 0x00,
+	0x00,
 }
 
 func (this *PrometheusHeader) Equal(that interface{}) bool {
@@ -1103,6 +1122,12 @@ func (this *Options) Equal(that interface{}) bool {
 	if this.TotalShards != that1.TotalShards {
 		return false
 	}
+	if this.AllowPartialResponse != that1.AllowPartialResponse {
+		return false
+	}
+	if this.MaxQueryParallelism != that1.MaxQueryParallelism {
+		return false
+	}
 	return true
 }
 func (this *QueryStatistics) Equal(that interface{}) bool {
@@ -1325,11 +1350,13 @@ func (this *Options) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 9)
 	s = append(s, "&querymiddleware.Options{")
 	s = append(s, "CacheDisabled: "+fmt.Sprintf("%#v", this.CacheDisabled)+",\n")
 	s = append(s, "ShardingDisabled: "+fmt.Sprintf("%#v", this.ShardingDisabled)+",\n")
 	s = append(s, "TotalShards: "+fmt.Sprintf("%#v", this.TotalShards)+",\n")
+	s = append(s, "AllowPartialResponse: "+fmt.Sprintf("%#v", this.AllowPartialResponse)+",\n")
+	s = append(s, "MaxQueryParallelism: "+fmt.Sprintf("%#v", this.MaxQueryParallelism)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1793,6 +1820,21 @@ func (m *Options) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.MaxQueryParallelism != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.MaxQueryParallelism))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.AllowPartialResponse {
+		i--
+		if m.AllowPartialResponse {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.TotalShards != 0 {
 		i = encodeVarintModel(dAtA, i, uint64(m.TotalShards))
 		i--
@@ -2155,6 +2197,12 @@ func (m *Options) Size() (n int) {
 	if m.TotalShards != 0 {
 		n += 1 + sovModel(uint64(m.TotalShards))
 	}
+	if m.AllowPartialResponse {
+		n += 2
+	}
+	if m.MaxQueryParallelism != 0 {
+		n += 1 + sovModel(uint64(m.MaxQueryParallelism))
+	}
 	return n
 }
 
@@ -2349,6 +2397,8 @@ func (this *Options) String() string {
 		`CacheDisabled:` + fmt.Sprintf("%v", this.CacheDisabled) + `,`,
 		`ShardingDisabled:` + fmt.Sprintf("%v", this.ShardingDisabled) + `,`,
 		`TotalShards:` + fmt.Sprintf("%v", this.TotalShards) + `,`,
+		`AllowPartialResponse:` + fmt.Sprintf("%v", this.AllowPartialResponse) + `,`,
+		`MaxQueryParallelism:` + fmt.Sprintf("%v", this.MaxQueryParallelism) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -3622,6 +3672,45 @@ func (m *Options) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AllowPartialResponse", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.AllowPartialResponse = bool(v != 0)
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxQueryParallelism", wireType)
+			}
+			m.MaxQueryParallelism = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxQueryParallelism |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipModel(dAtA[iNdEx:])
@@ -28,11 +28,12 @@ import (
 )
 
 var (
-	json = jsoniter.Config{
+	jsonConfig = jsoniter.Config{
 		EscapeHTML:             false, // No HTML in our responses.
 		SortMapKeys:            true,
 		ValidateJsonRawMessage: true,
-	}.Froze()
+	}
+	json = jsonConfig.Froze()
 )
 
 // newEmptyPrometheusResponse returns an empty successful Prometheus query range response.
@@ -66,6 +67,14 @@ type PrometheusRangeQueryRequest struct {
 	hints *Hints
 	// stats controls query engine stats collection for the request.
 	stats string
+	// limit is the max number of series returned, 0 if unlimited. It's never sent downstream, nor propagated to
+	// the requests derived from this one with a different time range or query, because truncating their partial
+	// results would drop series from the merged and cached results: the merged result is truncated when encoding
+	// the response instead.
+	limit int
+
+	// timeout is the evaluation timeout of the query, 0 if the querier's default applies.
+	timeout time.Duration
 }
 
 func NewPrometheusRangeQueryRequest(
@@ -166,6 +175,16 @@ func (r *PrometheusRangeQueryRequest) GetStats() string {
 	return r.stats
 }
 
+// GetLimit returns the max number of series returned for the request, 0 if unlimited.
+func (r *PrometheusRangeQueryRequest) GetLimit() int {
+	return r.limit
+}
+
+// GetTimeout returns the evaluation timeout of the query, 0 if the querier's default applies.
+func (r *PrometheusRangeQueryRequest) GetTimeout() time.Duration {
+	return r.timeout
+}
+
 // WithID clones the current `PrometheusRangeQueryRequest` with the provided ID.
 func (r *PrometheusRangeQueryRequest) WithID(id int64) (MetricsQueryRequest, error) {
 	newRequest := *r
@@ -180,6 +199,7 @@ func (r *PrometheusRangeQueryRequest) WithStartEnd(start int64, end int64) (Metr
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.start = start
 	newRequest.end = end
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -193,6 +213,7 @@ func (r *PrometheusRangeQueryRequest) WithQuery(query string) (MetricsQueryReque
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.queryExpr = queryExpr
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -208,6 +229,7 @@ func (r *PrometheusRangeQueryRequest) WithExpr(queryExpr parser.Expr) (MetricsQu
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.queryExpr = queryExpr
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -277,6 +299,11 @@ type PrometheusInstantQueryRequest struct {
 	hints *Hints
 	// stats controls stats collection for the request.
 	stats string
+	// limit is the max number of series returned, 0 if unlimited. See PrometheusRangeQueryRequest.limit.
+	limit int
+
+	// timeout is the evaluation timeout of the query, 0 if the querier's default applies.
+	timeout time.Duration
 }
 
 func NewPrometheusInstantQueryRequest(
@@ -379,6 +406,16 @@ func (r *PrometheusInstantQueryRequest) GetStats() string {
 	return r.stats
 }
 
+// GetLimit returns the max number of series returned for the request, 0 if unlimited.
+func (r *PrometheusInstantQueryRequest) GetLimit() int {
+	return r.limit
+}
+
+// GetTimeout returns the evaluation timeout of the query, 0 if the querier's default applies.
+func (r *PrometheusInstantQueryRequest) GetTimeout() time.Duration {
+	return r.timeout
+}
+
 func (r *PrometheusInstantQueryRequest) WithID(id int64) (MetricsQueryRequest, error) {
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
@@ -391,6 +428,7 @@ func (r *PrometheusInstantQueryRequest) WithStartEnd(time int64, _ int64) (Metri
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.time = time
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -404,6 +442,7 @@ func (r *PrometheusInstantQueryRequest) WithQuery(query string) (MetricsQueryReq
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.queryExpr = queryExpr
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -419,6 +458,7 @@ func (r *PrometheusInstantQueryRequest) WithExpr(queryExpr parser.Expr) (Metrics
 	newRequest := *r
 	newRequest.headers = cloneHeaders(r.headers)
 	newRequest.queryExpr = queryExpr
+	newRequest.limit = 0
 	return (&newRequest).updateMinMaxT(), nil
 }
 
@@ -742,6 +782,9 @@ type PrometheusSeriesQueryRequest struct {
 	// Limit the number of label names returned. A value of 0 means no limit
 	Limit   uint64
 	Headers []*PrometheusHeader
+	// MaxSeriesAge asks to only return the series active within this period before the end of the request.
+	// A value of 0 means all the series in the time range are returned.
+	MaxSeriesAge time.Duration
 }
 
 func (r *PrometheusSeriesQueryRequest) GetPath() string {
@@ -768,6 +811,10 @@ func (r *PrometheusSeriesQueryRequest) GetLimit() uint64 {
 	return r.Limit
 }
 
+func (r *PrometheusSeriesQueryRequest) GetMaxSeriesAge() time.Duration {
+	return r.MaxSeriesAge
+}
+
 type PrometheusLabelsResponse struct {
 	Status    string              `json:"status"`
 	Data      []string            `json:"data"`
@@ -875,41 +922,50 @@ func (d *PrometheusData) UnmarshalJSON(b []byte) error {
 }
 
 func (d *PrometheusData) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(d)
+}
+
+func (d *PrometheusData) jsonValue() (any, error) {
 	if d == nil {
-		return []byte("null"), nil
+		return nil, nil
 	}
 
 	switch d.ResultType {
 	case model.ValString.String():
-		return json.Marshal(struct {
+		return struct {
 			Type   model.ValueType     `json:"resultType"`
 			Result stringSampleStreams `json:"result"`
 		}{
 			Type:   model.ValString,
 			Result: d.Result,
-		})
+		}, nil
 
 	case model.ValScalar.String():
-		return json.Marshal(struct {
+		return struct {
 			Type   model.ValueType     `json:"resultType"`
 			Result scalarSampleStreams `json:"result"`
 		}{
 			Type:   model.ValScalar,
 			Result: d.Result,
-		})
+		}, nil
 
 	case model.ValVector.String():
-		return json.Marshal(struct {
+		return struct {
 			Type   model.ValueType      `json:"resultType"`
 			Result []vectorSampleStream `json:"result"`
 		}{
 			Type:   model.ValVector,
 			Result: asVectorSampleStreams(d.Result),
-		})
+		}, nil
 
 	case model.ValMatrix.String():
-		type plain *PrometheusData
-		return json.Marshal(plain(d))
+		return struct {
+			ResultType string         `json:"resultType"`
+			Result     []SampleStream `json:"result"`
+		}{
+			ResultType: d.ResultType,
+			Result:     d.Result,
+		}, nil
 
 	default:
 		return nil, fmt.Errorf("can't marshal prometheus result type %q", d.ResultType)
@@ -951,6 +1007,10 @@ func (sss *stringSampleStreams) UnmarshalJSON(b []byte) error {
 type scalarSampleStreams []SampleStream
 
 func (sss scalarSampleStreams) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(sss)
+}
+
+func (sss scalarSampleStreams) jsonValue() (any, error) {
 	if len(sss) != 1 {
 		return nil, fmt.Errorf("scalar sample streams should have exactly one stream, got %d", len(sss))
 	}
@@ -959,14 +1019,15 @@ func (sss scalarSampleStreams) MarshalJSON() ([]byte, error) {
 		return nil, fmt.Errorf("scalar sample stream should have exactly one sample, got %d", len(ss.Samples))
 	}
 	s := ss.Samples[0]
-	return json.Marshal(model.Scalar{
+	// Encoded like model.Scalar.
+	return model.SamplePair{
 		Timestamp: model.Time(s.TimestampMs),
 		Value:     model.SampleValue(s.Value),
-	})
+	}, nil
 }
 
 func (sss *scalarSampleStreams) UnmarshalJSON(b []byte) error {
-	var sv model.Scalar
+	var sv jsonSamplePair
 	if err := json.Unmarshal(b, &sv); err != nil {
 		return err
 	}
@@ -990,40 +1051,52 @@ func fromVectorSampleStreams(vss []vectorSampleStream) []SampleStream {
 type vectorSampleStream SampleStream
 
 func (vs *vectorSampleStream) UnmarshalJSON(b []byte) error {
-	s := model.Sample{}
+	// Like model.Sample, but the value is a jsonSampleValue.
+	var s struct {
+		Metric    model.Metric              `json:"metric"`
+		Value     jsonSamplePair            `json:"value"`
+		Histogram model.SampleHistogramPair `json:"histogram"`
+	}
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
-	if s.Histogram != nil {
+	if s.Histogram.Histogram != nil {
 		return errors.New("cannot unmarshal native histogram from JSON")
 	}
 
 	*vs = vectorSampleStream{
 		Labels:  mimirpb.FromMetricsToLabelAdapters(s.Metric),
-		Samples: []mimirpb.Sample{{TimestampMs: int64(s.Timestamp), Value: float64(s.Value)}},
+		Samples: []mimirpb.Sample{{TimestampMs: int64(s.Value.Timestamp), Value: float64(s.Value.Value)}},
 	}
 	return nil
 }
 
 func (vs vectorSampleStream) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(vs)
+}
+
+func (vs vectorSampleStream) jsonValue() (any, error) {
 	if (len(vs.Samples) == 1) == (len(vs.Histograms) == 1) { // not XOR
 		return nil, fmt.Errorf("vector sample stream should have exactly one sample or one histogram, got %d samples and %d histograms", len(vs.Samples), len(vs.Histograms))
 	}
-	var sample model.Sample
 	if len(vs.Samples) == 1 {
-		sample = model.Sample{
-			Metric:    mimirpb.FromLabelAdaptersToMetric(vs.Labels),
-			Timestamp: model.Time(vs.Samples[0].TimestampMs),
-			Value:     model.SampleValue(vs.Samples[0].Value),
-		}
-	} else {
-		sample = model.Sample{
-			Metric:    mimirpb.FromLabelAdaptersToMetric(vs.Labels),
-			Timestamp: model.Time(vs.Histograms[0].TimestampMs),
-			Histogram: mimirpb.FromFloatHistogramToPromHistogram(vs.Histograms[0].Histogram.ToPrometheusModel()),
-		}
+		// Encoded like a float model.Sample.
+		return struct {
+			Metric model.Metric     `json:"metric"`
+			Value  model.SamplePair `json:"value"`
+		}{
+			Metric: mimirpb.FromLabelAdaptersToMetric(vs.Labels),
+			Value: model.SamplePair{
+				Timestamp: model.Time(vs.Samples[0].TimestampMs),
+				Value:     model.SampleValue(vs.Samples[0].Value),
+			},
+		}, nil
 	}
-	return json.Marshal(sample)
+	return model.Sample{
+		Metric:    mimirpb.FromLabelAdaptersToMetric(vs.Labels),
+		Timestamp: model.Time(vs.Histograms[0].TimestampMs),
+		Histogram: mimirpb.FromFloatHistogramToPromHistogram(vs.Histograms[0].Histogram.ToPrometheusModel()),
+	}, nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -1048,29 +1121,35 @@ func (s *SampleStream) UnmarshalJSON(data []byte) error {
 
 // MarshalJSON implements json.Marshaler.
 func (s *SampleStream) MarshalJSON() ([]byte, error) {
-	var histograms []mimirpb.SampleHistogramPair
-	if len(s.Histograms) > 0 {
-		histograms = make([]mimirpb.SampleHistogramPair, len(s.Histograms))
-	}
-
-	for i, h := range s.Histograms {
-		histograms[i] = mimirpb.SampleHistogramPair{
-			Timestamp: h.TimestampMs,
-			Histogram: mimirpb.FromFloatHistogramToSampleHistogram(h.Histogram.ToPrometheusModel()),
-		}
-	}
+	return marshalJSONValue(s)
+}
 
-	stream := struct {
+func (s *SampleStream) jsonValue() (any, error) {
+	return struct {
 		Metric     model.Metric                  `json:"metric"`
 		Values     []mimirpb.Sample              `json:"values,omitempty"`
 		Histograms []mimirpb.SampleHistogramPair `json:"histograms,omitempty"`
 	}{
 		Metric:     mimirpb.FromLabelAdaptersToMetric(s.Labels),
 		Values:     s.Samples,
-		Histograms: histograms,
+		Histograms: toSampleHistogramPairs(s.Histograms),
+	}, nil
+}
+
+// toSampleHistogramPairs converts the input histograms to their JSON representation. Returns nil if there are none.
+func toSampleHistogramPairs(histograms []mimirpb.FloatHistogramPair) []mimirpb.SampleHistogramPair {
+	if len(histograms) == 0 {
+		return nil
 	}
 
-	return json.Marshal(stream)
+	pairs := make([]mimirpb.SampleHistogramPair, len(histograms))
+	for i, h := range histograms {
+		pairs[i] = mimirpb.SampleHistogramPair{
+			Timestamp: h.TimestampMs,
+			Histogram: mimirpb.FromFloatHistogramToSampleHistogram(h.Histogram.ToPrometheusModel()),
+		}
+	}
+	return pairs
 }
 
 func (resp *PrometheusResponse) Close() {
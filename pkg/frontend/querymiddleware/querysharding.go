@@ -112,7 +112,7 @@ func (s *querySharding) Do(ctx context.Context, r MetricsQueryRequest) (Response
 		return nil, apierror.New(apierror.TypeBadData, DecorateWithParamName(err, "query").Error())
 	}
 
-	totalShards := s.getShardsForQuery(ctx, tenantIDs, r, queryExpr, log)
+	totalShards, shardingReason := s.getShardsForQuery(ctx, tenantIDs, r, queryExpr, log)
 	if totalShards <= 1 {
 		level.Debug(log).Log("msg", "query sharding is disabled for this query or tenant")
 		return s.next.Do(ctx, r)
@@ -154,7 +154,23 @@ func (s *querySharding) Do(ctx context.Context, r MetricsQueryRequest) (Response
 	annotationAccumulator := NewAnnotationAccumulator()
 	shardedQueryable := NewShardedQueryable(r, annotationAccumulator, s.next, nil)
 
-	return ExecuteQueryOnQueryable(ctx, r, s.engine, shardedQueryable, annotationAccumulator)
+	resp, err := ExecuteQueryOnQueryable(ctx, r, s.engine, shardedQueryable, annotationAccumulator)
+	if err != nil || !isShardingExplainRequested(r) {
+		return resp, err
+	}
+
+	if promResp, ok := resp.GetPrometheusResponse(); ok {
+		explanation := shardingExplanation{
+			totalShards:    totalShards,
+			shardedQueries: shardingStats.GetShardedQueries(),
+			reason:         shardingReason,
+		}
+		if hints := r.GetHints(); hints != nil {
+			explanation.estimatedSeriesCount = hints.GetEstimatedSeriesCount()
+		}
+		promResp.Infos = append(promResp.Infos, explanation.annotation())
+	}
+	return resp, nil
 }
 
 func ExecuteQueryOnQueryable(ctx context.Context, r MetricsQueryRequest, engine promql.QueryEngine, queryable storage.Queryable, annotationAccumulator *AnnotationAccumulator) (Response, error) {
@@ -298,18 +314,20 @@ func (s *querySharding) shardQuery(ctx context.Context, query string, totalShard
 	return shardedQuery.String(), stats, nil
 }
 
-// getShardsForQuery calculates and return the number of shards that should be used to run the query.
-func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []string, r MetricsQueryRequest, queryExpr parser.Expr, spanLog *spanlogger.SpanLogger) int {
+// getShardsForQuery calculates and return the number of shards that should be used to run the query,
+// and the reason of the last adjustment of the number of shards (one of the shardingReason constants).
+func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []string, r MetricsQueryRequest, queryExpr parser.Expr, spanLog *spanlogger.SpanLogger) (int, string) {
 	// Check if sharding is disabled for the given request.
 	if r.GetOptions().ShardingDisabled {
-		return 1
+		return 1, ""
 	}
 
 	// Check the default number of shards configured for the given tenant.
 	totalShards := validation.SmallestPositiveIntPerTenant(tenantIDs, s.limit.QueryShardingTotalShards)
 	if totalShards <= 1 {
-		return 1
+		return 1, ""
 	}
+	reason := shardingReasonTenantLimit
 
 	// Ensure there's no regexp matcher longer than the configured limit.
 	maxRegexpSizeBytes := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limit.QueryShardingMaxRegexpSizeBytes)
@@ -321,13 +339,14 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 				"limit bytes", maxRegexpSizeBytes,
 			)
 
-			return 1
+			return 1, ""
 		}
 	}
 
 	// Honor the number of shards specified in the request (if any).
 	if r.GetOptions().TotalShards > 0 {
 		totalShards = int(r.GetOptions().TotalShards)
+		reason = shardingReasonControlHeader
 	}
 
 	hints := r.GetHints()
@@ -344,6 +363,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		totalShards = min(totalShards, int(seriesCount.EstimatedSeriesCount/s.maxSeriesPerShard)+1)
 
 		if prevTotalShards != totalShards {
+			reason = shardingReasonEstimatedSeriesCount
 			spanLog.DebugLog(
 				"msg", "number of shards has been adjusted to match the estimated series count",
 				"updated total shards", totalShards,
@@ -387,6 +407,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		totalShards = max(1, min(totalShards, (maxShardedQueries/int(totalQueries))/numShardableLegs))
 
 		if prevTotalShards != totalShards {
+			reason = shardingReasonMaxShardedQueries
 			spanLog.DebugLog(
 				"msg", "number of shards has been adjusted to honor the max sharded queries limit",
 				"updated total shards", totalShards,
@@ -425,6 +446,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		}
 
 		if prevTotalShards != totalShards {
+			reason = shardingReasonCompactorShards
 			spanLog.DebugLog("msg", "number of shards has been adjusted to be compatible with compactor shards",
 				"previous total shards", prevTotalShards,
 				"updated total shards", totalShards,
@@ -432,7 +454,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		}
 	}
 
-	return totalShards
+	return totalShards, reason
 }
 
 // promqlResultToSamples transforms a promql query result into a samplestream
@@ -1860,6 +1860,51 @@ func TestQuerySharding_ShouldUseCardinalityEstimate(t *testing.T) {
 	}
 }
 
+func TestQuerySharding_ShouldExplainShardingWhenRequested(t *testing.T) {
+	req := &PrometheusInstantQueryRequest{
+		time:      util.TimeToMillis(start),
+		queryExpr: parseQuery(t, "sum by (foo) (rate(bar{}[1m]))"), // shardable query.
+	}
+	explainReq := mustSucceed(req.WithHeaders([]*PrometheusHeader{{Name: shardingInfoHeader, Values: []string{"true"}}}))
+
+	tests := map[string]struct {
+		req           MetricsQueryRequest
+		expectedInfos []string
+	}{
+		"explanation not requested": {
+			req: req,
+		},
+		"explanation requested": {
+			req:           explainReq,
+			expectedInfos: []string{"sharding explain: total_shards=16 sharded_queries=16 reason=tenant-limit"},
+		},
+		"explanation requested, with cardinality estimate": {
+			req:           mustSucceed(explainReq.WithEstimatedSeriesCountHint(29_000)),
+			expectedInfos: []string{"sharding explain: total_shards=3 sharded_queries=3 reason=estimated-series-count estimated_series_count=29000"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runForEngines(t, func(t *testing.T, _ promql.EngineOpts, eng promql.QueryEngine) {
+				shardingware := newQueryShardingMiddleware(log.NewNopLogger(), eng, mockLimits{totalShards: 16}, 10_000, nil)
+				downstream := &mockHandler{}
+				downstream.On("Do", mock.Anything, mock.Anything).Return(&PrometheusResponse{
+					Status: statusSuccess, Data: &PrometheusData{
+						ResultType: string(parser.ValueTypeVector),
+					},
+				}, nil)
+
+				res, err := shardingware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), tt.req)
+				require.NoError(t, err)
+				shardedPrometheusRes, ok := res.GetPrometheusResponse()
+				require.True(t, ok)
+				assert.ElementsMatch(t, tt.expectedInfos, shardedPrometheusRes.Infos)
+			})
+		})
+	}
+}
+
 func TestQuerySharding_Annotations(t *testing.T) {
 	numSeries := 10
 	endTime := 100
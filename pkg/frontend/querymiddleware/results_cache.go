@@ -232,10 +232,10 @@ func (g DefaultCacheKeyGenerator) QueryRequest(_ context.Context, tenantID strin
 
 	// Use original format for step-aligned request, so that we can use existing cached results for such requests.
 	if stepOffset == 0 {
-		return fmt.Sprintf("%s:%s:%d:%d", tenantID, r.GetQuery(), r.GetStep(), startInterval)
+		return fmt.Sprintf("%s:%s:%d:%d", tenantID, g.codec.CacheKey(r), r.GetStep(), startInterval)
 	}
 
-	return fmt.Sprintf("%s:%s:%d:%d:%d", tenantID, r.GetQuery(), r.GetStep(), startInterval, stepOffset)
+	return fmt.Sprintf("%s:%s:%d:%d:%d", tenantID, g.codec.CacheKey(r), r.GetStep(), startInterval, stepOffset)
 }
 
 func (g DefaultCacheKeyGenerator) QueryRequestError(_ context.Context, tenantID string, r MetricsQueryRequest) string {
@@ -247,7 +247,7 @@ func (g DefaultCacheKeyGenerator) QueryRequestError(_ context.Context, tenantID
 		start = 0
 		end = 0
 	}
-	return fmt.Sprintf("EC:%s:%s:%d:%d:%d", tenantID, r.GetQuery(), start, end, r.GetStep())
+	return fmt.Sprintf("EC:%s:%s:%d:%d:%d", tenantID, g.codec.CacheKey(r), start, end, r.GetStep())
 }
 
 func (g DefaultCacheKeyGenerator) QueryRequestLimiter(_ context.Context, tenantID string, r MetricsQueryRequest) string {
@@ -288,8 +288,12 @@ func isRequestCachable(req MetricsQueryRequest, maxCacheTime int64, cacheUnalign
 }
 
 // isResponseCachable returns true if a response hasn't explicitly disabled caching
-// via an HTTP header, false otherwise.
+// via an HTTP header and isn't a partial response, false otherwise.
 func isResponseCachable(r Response) bool {
+	if isPartialResponse(r) {
+		return false
+	}
+
 	for _, hv := range r.GetHeaders() {
 		if hv.GetName() == cacheControlHeader {
 			return !slices.Contains(hv.GetValues(), noStoreValue)
@@ -381,6 +381,13 @@ func TestIsResponseCachable(t *testing.T) {
 			}),
 			expected: true,
 		},
+		{
+			name: "partial response",
+			response: Response(&PrometheusResponse{
+				Headers: []*PrometheusHeader{{Name: partialResponseFailedHeader, Values: []string{"store-gateway-1"}}},
+			}),
+			expected: false,
+		},
 	} {
 		{
 			t.Run(tc.name, func(t *testing.T) {
@@ -794,6 +801,19 @@ func TestDefaultSplitter_QueryRequest(t *testing.T) {
 	}
 }
 
+func TestDefaultSplitter_QueryRequest_ExtraPropagatedHeaders(t *testing.T) {
+	codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, []string{"X-Tenant-Region", "X-Dashboard-Uid"}, WithCacheKeyIgnoredHeaders([]string{"X-Dashboard-Uid"}))
+	generator := NewDefaultCacheKeyGenerator(codec, 24*time.Hour)
+
+	req := NewPrometheusRangeQueryRequest("/api/v1/query_range", []*PrometheusHeader{
+		{Name: "X-Dashboard-Uid", Values: []string{"abc"}},
+		{Name: "X-Tenant-Region", Values: []string{"eu"}},
+	}, 0, toMs(time.Hour), 10, 0, parseQuery(t, "foo"), Options{}, nil, "")
+
+	assert.Equal(t, "fake:foo:X-Tenant-Region=eu:10:0", generator.QueryRequest(context.Background(), "fake", req))
+	assert.Equal(t, "EC:fake:foo:X-Tenant-Region=eu:0:3600000:10", generator.QueryRequestError(context.Background(), "fake", req))
+}
+
 func TestMergeCacheExtentsForRequest(t *testing.T) {
 	ctx := context.Background()
 	merger := &Codec{}
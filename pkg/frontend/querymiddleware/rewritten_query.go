@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	// rewrittenQueryHeader is the request header asking to return the queries sent downstream by the query-frontend
+	// once rewritten by the middlewares, for example because of query sharding or splitting.
+	rewrittenQueryHeader = "X-Mimir-Return-Rewritten-Query"
+
+	// rewrittenQueryAnnotationPrefix is the prefix of the info annotations carrying the rewritten queries.
+	rewrittenQueryAnnotationPrefix = "rewritten query: "
+
+	// maxRewrittenQueryAnnotations is the max number of distinct rewritten queries returned as info annotations,
+	// so that queries sharded in many shards don't bloat the response.
+	maxRewrittenQueryAnnotations = 50
+)
+
+type rewrittenQueriesContextKey int
+
+const rewrittenQueriesKey rewrittenQueriesContextKey = 0
+
+// rewrittenQueries collects the distinct queries sent downstream, in the order they've been first sent.
+type rewrittenQueries struct {
+	mx      sync.Mutex
+	queries []string
+	seen    map[string]struct{}
+}
+
+func (q *rewrittenQueries) add(query string) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	if _, ok := q.seen[query]; ok {
+		return
+	}
+	q.seen[query] = struct{}{}
+	q.queries = append(q.queries, query)
+}
+
+// annotations returns the info annotations carrying the collected queries.
+func (q *rewrittenQueries) annotations() []string {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	annotations := make([]string, 0, min(len(q.queries), maxRewrittenQueryAnnotations+1))
+	for i, query := range q.queries {
+		if i == maxRewrittenQueryAnnotations {
+			annotations = append(annotations, fmt.Sprintf("%s%d more queries not shown", rewrittenQueryAnnotationPrefix, len(q.queries)-i))
+			break
+		}
+		annotations = append(annotations, rewrittenQueryAnnotationPrefix+query)
+	}
+	return annotations
+}
+
+// newRewrittenQueryMiddleware returns a MetricsQueryMiddleware which, if requested with the
+// X-Mimir-Return-Rewritten-Query header, adds the queries recorded by the middleware returned by
+// newRewrittenQueryRecorderMiddleware to the response, as info annotations. It must run before any
+// middleware rewriting the query. Queries whose results are served by the results cache aren't sent
+// downstream, so they're not returned.
+func newRewrittenQueryMiddleware() MetricsQueryMiddleware {
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &rewrittenQueryMiddleware{next: next}
+	})
+}
+
+type rewrittenQueryMiddleware struct {
+	next MetricsQueryHandler
+}
+
+func (m *rewrittenQueryMiddleware) Do(ctx context.Context, r MetricsQueryRequest) (Response, error) {
+	if !isRewrittenQueryRequested(r) {
+		return m.next.Do(ctx, r)
+	}
+
+	queries := &rewrittenQueries{seen: map[string]struct{}{}}
+	resp, err := m.next.Do(context.WithValue(ctx, rewrittenQueriesKey, queries), r)
+	if err != nil {
+		return resp, err
+	}
+
+	if promResp, ok := resp.GetPrometheusResponse(); ok {
+		promResp.Infos = append(promResp.Infos, queries.annotations()...)
+	}
+	return resp, nil
+}
+
+// newRewrittenQueryRecorderMiddleware returns a MetricsQueryMiddleware recording the queries sent downstream, once
+// rewritten by the previous middlewares, if requested with the X-Mimir-Return-Rewritten-Query header. It must run
+// after any middleware rewriting the query.
+func newRewrittenQueryRecorderMiddleware() MetricsQueryMiddleware {
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &rewrittenQueryRecorderMiddleware{next: next}
+	})
+}
+
+type rewrittenQueryRecorderMiddleware struct {
+	next MetricsQueryHandler
+}
+
+func (m *rewrittenQueryRecorderMiddleware) Do(ctx context.Context, r MetricsQueryRequest) (Response, error) {
+	if queries, ok := ctx.Value(rewrittenQueriesKey).(*rewrittenQueries); ok {
+		queries.add(r.GetQuery())
+	}
+	return m.next.Do(ctx, r)
+}
+
+// isRewrittenQueryRequested returns whether the request asks to return the rewritten queries.
+func isRewrittenQueryRequested(r MetricsQueryRequest) bool {
+	return boolRequestHeader(r, rewrittenQueryHeader)
+}
+
+// boolRequestHeader returns the boolean value of the given header of the request, or false if not set or invalid.
+// If the header has multiple values, the last valid one wins.
+func boolRequestHeader(r MetricsQueryRequest, name string) bool {
+	value := false
+	for _, h := range r.GetHeaders() {
+		if http.CanonicalHeaderKey(h.GetName()) != name {
+			continue
+		}
+		for _, v := range h.GetValues() {
+			if b, err := strconv.ParseBool(v); err == nil {
+				value = b
+			}
+		}
+	}
+	return value
+}
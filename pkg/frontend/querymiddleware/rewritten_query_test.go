@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestRewrittenQueryMiddleware(t *testing.T) {
+	req := &PrometheusInstantQueryRequest{
+		time:      util.TimeToMillis(start),
+		queryExpr: parseQuery(t, "sum(foo)"),
+	}
+	requestedReq := mustSucceed(req.WithHeaders([]*PrometheusHeader{{Name: rewrittenQueryHeader, Values: []string{"true"}}}))
+
+	// rewriter sends the given queries downstream, in place of the original one.
+	rewriter := func(queries ...string) MetricsQueryMiddleware {
+		return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+			return HandlerFunc(func(ctx context.Context, r MetricsQueryRequest) (Response, error) {
+				for _, query := range queries {
+					rewritten, err := r.WithQuery(query)
+					require.NoError(t, err)
+					if _, err := next.Do(ctx, rewritten); err != nil {
+						return nil, err
+					}
+				}
+				return &PrometheusResponse{Status: statusSuccess, Infos: []string{"info"}}, nil
+			})
+		})
+	}
+
+	manyQueries := make([]string, 0, maxRewrittenQueryAnnotations+2)
+	for i := 0; i < maxRewrittenQueryAnnotations+2; i++ {
+		manyQueries = append(manyQueries, fmt.Sprintf(`sum(foo{shard="%d"})`, i))
+	}
+	expectedManyInfos := []string{"info"}
+	for _, query := range manyQueries[:maxRewrittenQueryAnnotations] {
+		expectedManyInfos = append(expectedManyInfos, rewrittenQueryAnnotationPrefix+query)
+	}
+	expectedManyInfos = append(expectedManyInfos, rewrittenQueryAnnotationPrefix+"2 more queries not shown")
+
+	tests := map[string]struct {
+		req           MetricsQueryRequest
+		queries       []string
+		expectedInfos []string
+	}{
+		"rewritten query not requested": {
+			req:           req,
+			queries:       []string{"sum(bar)"},
+			expectedInfos: []string{"info"},
+		},
+		"rewritten query requested": {
+			req:           requestedReq,
+			queries:       []string{"sum(bar)"},
+			expectedInfos: []string{"info", rewrittenQueryAnnotationPrefix + "sum(bar)"},
+		},
+		"rewritten query requested, with multiple queries sent downstream": {
+			req:     requestedReq,
+			queries: []string{"sum(bar)", "sum(baz)", "sum(bar)"},
+			expectedInfos: []string{
+				"info",
+				rewrittenQueryAnnotationPrefix + "sum(bar)",
+				rewrittenQueryAnnotationPrefix + "sum(baz)",
+			},
+		},
+		"rewritten query requested, with more queries sent downstream than returned": {
+			req:           requestedReq,
+			queries:       manyQueries,
+			expectedInfos: expectedManyInfos,
+		},
+		"rewritten query explicitly not requested": {
+			req:           mustSucceed(req.WithHeaders([]*PrometheusHeader{{Name: rewrittenQueryHeader, Values: []string{"false"}}})),
+			queries:       []string{"sum(bar)"},
+			expectedInfos: []string{"info"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := MergeMetricsQueryMiddlewares(
+				newRewrittenQueryMiddleware(),
+				rewriter(tt.queries...),
+				newRewrittenQueryRecorderMiddleware(),
+			).Wrap(mockHandlerWith(&PrometheusResponse{Status: statusSuccess}, nil))
+
+			res, err := handler.Do(user.InjectOrgID(context.Background(), "test"), tt.req)
+			require.NoError(t, err)
+
+			promRes, ok := res.GetPrometheusResponse()
+			require.True(t, ok)
+			assert.Equal(t, tt.expectedInfos, promRes.Infos)
+		})
+	}
+}
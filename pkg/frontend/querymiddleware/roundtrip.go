@@ -26,6 +26,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"go.opentelemetry.io/otel"
 
+	"github.com/grafana/mimir/pkg/querier/api"
 	"github.com/grafana/mimir/pkg/storage/ingest"
 	"github.com/grafana/mimir/pkg/util"
 )
@@ -88,6 +89,34 @@ type Config struct {
 	QueryResultResponseFormat string `yaml:"query_result_response_format"`
 
 	CacheSamplesProcessedStats bool `yaml:"cache_samples_processed_stats"`
+
+	EmptyResultAsNull            bool                      `yaml:"empty_result_as_null" category:"experimental"`
+	StepAlignmentValidation      bool                      `yaml:"step_alignment_validation" category:"experimental"`
+	SortedMatrixMerge            bool                      `yaml:"sorted_matrix_merge" category:"experimental"`
+	QueryTimeRangeHeaders        bool                      `yaml:"query_time_range_headers" category:"experimental"`
+	InstantQueryTimeParamAlias   string                    `yaml:"instant_query_time_param_alias" category:"experimental"`
+	DefaultReadConsistency       string                    `yaml:"default_read_consistency" category:"experimental"`
+	LegacyBlockFormatInfo        string                    `yaml:"legacy_block_format_info" category:"experimental"`
+	UTF8LabelsValidation         bool                      `yaml:"utf8_labels_validation" category:"experimental"`
+	DropStaleMarkers             bool                      `yaml:"drop_stale_markers" category:"experimental"`
+	DeprecatedFunctions          flagext.StringSliceCSV    `yaml:"deprecated_functions" category:"experimental"`
+	DeprecatedFunctionsMode      string                    `yaml:"deprecated_functions_mode" category:"experimental"`
+	SortSeriesLabels             bool                      `yaml:"sort_series_labels" category:"experimental"`
+	MaxQueryTimeout              time.Duration             `yaml:"max_query_timeout" category:"experimental"`
+	QueryCostEstimateHeader      bool                      `yaml:"query_cost_estimate_header" category:"experimental"`
+	JSONFloatFormat              string                    `yaml:"json_float_format" category:"experimental"`
+	OutOfOrderSamplesMode        string                    `yaml:"out_of_order_samples_mode" category:"experimental"`
+	InstantQueriesAsRangeQueries bool                      `yaml:"instant_queries_as_range_queries" category:"experimental"`
+	FormatterFallback            bool                      `yaml:"formatter_fallback" category:"experimental"`
+	DeprecationWarnings          flagext.LimitsMap[string] `yaml:"deprecation_warnings" category:"experimental"`
+	StrictQueryParams            bool                      `yaml:"strict_query_params" category:"experimental"`
+	ResponseSizeWarnThreshold    int                       `yaml:"response_size_warn_threshold_bytes" category:"experimental"`
+	JSONNonFiniteFloats          string                    `yaml:"json_non_finite_floats" category:"experimental"`
+	CanonicalQueries             bool                      `yaml:"canonical_queries" category:"experimental"`
+	ServerTimingHeader           bool                      `yaml:"server_timing_header" category:"experimental"`
+	MaxLabelMatcherSets          int                       `yaml:"max_label_matcher_sets" category:"experimental"`
+	MergedSeriesLimit            bool                      `yaml:"merged_series_limit" category:"experimental"`
+	CacheKeyIgnoredHeaders       flagext.StringSliceCSV    `yaml:"cache_key_ignored_headers" category:"experimental"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -105,6 +134,34 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.ShardActiveSeriesQueries, "query-frontend.shard-active-series-queries", false, "True to enable sharding of active series queries.")
 	f.BoolVar(&cfg.UseActiveSeriesDecoder, "query-frontend.use-active-series-decoder", false, "Set to true to use the zero-allocation response decoder for active series queries.")
 	f.BoolVar(&cfg.CacheSamplesProcessedStats, "query-frontend.cache-samples-processed-stats", false, "Cache statistics of processed samples on results cache.")
+	f.BoolVar(&cfg.EmptyResultAsNull, "query-frontend.empty-result-as-null", false, "True to encode the empty matrix and vector results of the JSON query responses as null instead of an empty array.")
+	f.BoolVar(&cfg.StepAlignmentValidation, "query-frontend.step-alignment-validation", false, "True to check that the timestamps of the samples of the range query responses received from the queriers are aligned to the start and step of the query, and to fail the query if they aren't.")
+	f.BoolVar(&cfg.SortedMatrixMerge, "query-frontend.sorted-matrix-merge", false, "True to merge the series of the range query responses with a k-way merge, relying on the series of each response being sorted by labels. It allocates less memory when merging many responses with many series, at the cost of more label comparisons.")
+	f.BoolVar(&cfg.QueryTimeRangeHeaders, "query-frontend.query-time-range-headers", false, "True to include the X-Mimir-Query-Min-T and X-Mimir-Query-Max-T headers in the responses to metrics queries, holding the min and max time (in milliseconds) of the data queried by the request.")
+	f.StringVar(&cfg.InstantQueryTimeParamAlias, "query-frontend.instant-query-time-param-alias", "", "Name of an alias of the time parameter of instant queries, read when the request has no time parameter. This is a compatibility shim for legacy clients sending the evaluation time under a non-standard parameter name. Empty to disable.")
+	f.StringVar(&cfg.DefaultReadConsistency, "query-frontend.default-read-consistency", "", fmt.Sprintf("Read consistency level set on the requests sent to the queriers when the query doesn't specify one. Supported values: %s. Empty to leave the level to the queriers' default.", strings.Join(api.ReadConsistencies, ", ")))
+	f.StringVar(&cfg.LegacyBlockFormatInfo, "query-frontend.legacy-block-format-info", "", "Info annotation added by the queriers to the responses served from a legacy block format. The responses including it are counted by cortex_frontend_legacy_block_responses_total and include the X-Mimir-Legacy-Block-Format header. Empty to disable.")
+	f.BoolVar(&cfg.UTF8LabelsValidation, "query-frontend.utf8-labels-validation", false, "True to check that the label names and values of the responses received from the queriers are valid UTF-8, and to fail the query if they aren't. It adds a cost per decoded label.")
+	f.BoolVar(&cfg.DropStaleMarkers, "query-frontend.drop-stale-markers", false, "True to drop the samples which are Prometheus stale markers from the series of the merged range query responses.")
+	f.Var(&cfg.DeprecatedFunctions, "query-frontend.deprecated-functions", "Comma-separated list of PromQL functions which are deprecated. The metrics queries using them are handled according to -query-frontend.deprecated-functions-mode.")
+	f.StringVar(&cfg.DeprecatedFunctionsMode, "query-frontend.deprecated-functions-mode", DeprecatedFunctionsModeWarn, fmt.Sprintf("How the metrics queries using a deprecated function are handled. Supported values: %s (the query is rejected), %s (the query is executed and a warning is added to its response).", DeprecatedFunctionsModeReject, DeprecatedFunctionsModeWarn))
+	f.BoolVar(&cfg.SortSeriesLabels, "query-frontend.sort-series-labels", false, "True to sort by name the labels of each series of the query responses received from the queriers, so that their order is deterministic.")
+	f.DurationVar(&cfg.MaxQueryTimeout, "query-frontend.max-query-timeout", 0, "Maximum evaluation timeout which can be requested with the timeout parameter of range and instant queries. Greater timeouts are clamped to it. 0 to disable.")
+	f.BoolVar(&cfg.QueryCostEstimateHeader, "query-frontend.query-cost-estimate-header", false, "True to include the "+queryCostEstimateHeader+" header, holding a static estimate of the cost of the query, in the metrics query requests sent to the queriers.")
+	f.StringVar(&cfg.JSONFloatFormat, "query-frontend.json-float-format", JSONFloatFormatAuto, fmt.Sprintf("Notation of the float sample values of the JSON query responses. %s encodes them like Prometheus does. Supported values: %s.", JSONFloatFormatAuto, strings.Join(jsonFloatFormats, ", ")))
+	f.StringVar(&cfg.OutOfOrderSamplesMode, "query-frontend.out-of-order-samples-mode", OutOfOrderSamplesModeReject, fmt.Sprintf("How the query responses received from the queriers whose series have samples not sorted by timestamp are handled. Supported values: %s (the query fails), %s (the samples are sorted by timestamp, meant to work around a known issue only).", OutOfOrderSamplesModeReject, OutOfOrderSamplesModeRepair))
+	f.BoolVar(&cfg.InstantQueriesAsRangeQueries, "query-frontend.instant-queries-as-range-queries", false, "True to run the instant queries returning an instant vector as equivalent single-step range queries, so that they are split and cached like range queries. Requires the results cache or the splitting of the queries by interval to be enabled.")
+	f.BoolVar(&cfg.FormatterFallback, "query-frontend.formatter-fallback", false, "True to decode the query responses received from the queriers with the other supported formats when they can't be decoded with the format of their content type.")
+	cfg.DeprecationWarnings = flagext.NewLimitsMap[string](validateDeprecationWarning)
+	f.Var(&cfg.DeprecationWarnings, "query-frontend.deprecation-warnings", fmt.Sprintf("The warning added to the responses to the metrics queries using each deprecated feature, keyed by the feature. Supported features: %s (the start, end or time parameter is a Unix timestamp), %s (the response is encoded as JSON).", DeprecatedFeatureUnixTimeParams, DeprecatedFeatureJSONResponse))
+	f.BoolVar(&cfg.StrictQueryParams, "query-frontend.strict-query-params", false, "True to reject the instant queries with a start, end or step parameter, and the range queries with a time parameter, which are otherwise ignored.")
+	f.IntVar(&cfg.ResponseSizeWarnThreshold, "query-frontend.response-size-warn-threshold-bytes", 0, "The size, in bytes, above which a metrics query response is annotated with an info and counted by the cortex_frontend_large_response_total metric. 0 to disable.")
+	f.StringVar(&cfg.JSONNonFiniteFloats, "query-frontend.json-non-finite-floats", JSONNonFiniteFloatsPrometheus, fmt.Sprintf("Representation of the NaN and infinite float sample values of the JSON query responses. %s encodes them like Prometheus does. Supported values: %s.", JSONNonFiniteFloatsPrometheus, strings.Join(jsonNonFiniteFloatsModes, ", ")))
+	f.BoolVar(&cfg.CanonicalQueries, "query-frontend.canonical-queries", false, "True to send the canonical form of the metrics queries downstream and use it in the results cache keys, so that the queries only differing by their formatting share the same cache entries.")
+	f.BoolVar(&cfg.ServerTimingHeader, "query-frontend.server-timing-header", false, "True to add the Server-Timing header to the metrics query responses, breaking down the time spent by the query-frontend decoding and encoding the responses.")
+	f.IntVar(&cfg.MaxLabelMatcherSets, "query-frontend.max-label-matcher-sets", 0, "Maximum number of match[] parameters of the label names, label values and series requests. The requests with more matcher sets are rejected. 0 to disable.")
+	f.BoolVar(&cfg.MergedSeriesLimit, "query-frontend.merged-series-limit", false, "True to truncate the response merged from the responses of the split queries of a metrics query to the limit parameter of the query, keeping the series with the lowest label sets and adding a warning.")
+	f.Var(&cfg.CacheKeyIgnoredHeaders, "query-frontend.cache-key-ignored-headers", "Comma-separated list of the extra propagated request headers which don't change the result of the queries. The values of the other extra propagated headers are part of the results cache keys.")
 	cfg.ResultsCache.RegisterFlags(f)
 }
 
@@ -125,9 +182,63 @@ func (cfg *Config) Validate() error {
 	if !slices.Contains(allFormats, cfg.QueryResultResponseFormat) {
 		return fmt.Errorf("unknown query result response format '%s'. Supported values: %s", cfg.QueryResultResponseFormat, strings.Join(allFormats, ", "))
 	}
+
+	if cfg.DefaultReadConsistency != "" && !api.IsValidReadConsistency(cfg.DefaultReadConsistency) {
+		return fmt.Errorf("unknown default read consistency '%s'. Supported values: %s", cfg.DefaultReadConsistency, strings.Join(api.ReadConsistencies, ", "))
+	}
+
+	if len(cfg.DeprecatedFunctions) > 0 && cfg.DeprecatedFunctionsMode != DeprecatedFunctionsModeReject && cfg.DeprecatedFunctionsMode != DeprecatedFunctionsModeWarn {
+		return fmt.Errorf("unknown deprecated functions mode '%s'. Supported values: %s, %s", cfg.DeprecatedFunctionsMode, DeprecatedFunctionsModeReject, DeprecatedFunctionsModeWarn)
+	}
+
+	if cfg.JSONFloatFormat != "" && !slices.Contains(jsonFloatFormats, cfg.JSONFloatFormat) {
+		return fmt.Errorf("unknown JSON float format '%s'. Supported values: %s", cfg.JSONFloatFormat, strings.Join(jsonFloatFormats, ", "))
+	}
+
+	if cfg.JSONNonFiniteFloats != "" && !slices.Contains(jsonNonFiniteFloatsModes, cfg.JSONNonFiniteFloats) {
+		return fmt.Errorf("unknown JSON non-finite floats mode '%s'. Supported values: %s", cfg.JSONNonFiniteFloats, strings.Join(jsonNonFiniteFloatsModes, ", "))
+	}
+
+	if cfg.OutOfOrderSamplesMode != "" && cfg.OutOfOrderSamplesMode != OutOfOrderSamplesModeReject && cfg.OutOfOrderSamplesMode != OutOfOrderSamplesModeRepair {
+		return fmt.Errorf("unknown out-of-order samples mode '%s'. Supported values: %s, %s", cfg.OutOfOrderSamplesMode, OutOfOrderSamplesModeReject, OutOfOrderSamplesModeRepair)
+	}
 	return nil
 }
 
+// CodecOptions returns the options of the query-frontend Codec configured by the config. The Codec logs with the
+// input logger.
+func (cfg *Config) CodecOptions(logger log.Logger) []CodecOption {
+	return []CodecOption{
+		WithLogger(logger),
+		WithEmptyResultAsNull(cfg.EmptyResultAsNull),
+		WithStepAlignmentValidation(cfg.StepAlignmentValidation),
+		WithSortedMatrixMerge(cfg.SortedMatrixMerge),
+		WithQueryTimeRangeHeaders(cfg.QueryTimeRangeHeaders),
+		WithInstantQueryTimeParamAlias(cfg.InstantQueryTimeParamAlias),
+		WithDefaultReadConsistency(cfg.DefaultReadConsistency),
+		WithLegacyBlockFormatInfo(cfg.LegacyBlockFormatInfo),
+		WithUTF8LabelsValidation(cfg.UTF8LabelsValidation),
+		WithStaleMarkersDropped(cfg.DropStaleMarkers),
+		WithDeprecatedFunctions(cfg.DeprecatedFunctions, cfg.DeprecatedFunctionsMode),
+		WithSortedSeriesLabels(cfg.SortSeriesLabels),
+		WithMaxQueryTimeout(cfg.MaxQueryTimeout),
+		WithQueryCostEstimateHeader(cfg.QueryCostEstimateHeader),
+		WithJSONFloatFormat(cfg.JSONFloatFormat),
+		WithOutOfOrderSamplesMode(cfg.OutOfOrderSamplesMode),
+		WithInstantQueriesAsRangeQueries(cfg.InstantQueriesAsRangeQueries),
+		WithFormatterFallback(cfg.FormatterFallback),
+		WithDeprecationWarnings(cfg.DeprecationWarnings.Read()),
+		WithStrictQueryParams(cfg.StrictQueryParams),
+		WithResponseSizeWarnThreshold(cfg.ResponseSizeWarnThreshold),
+		WithJSONNonFiniteFloats(cfg.JSONNonFiniteFloats),
+		WithCanonicalQueries(cfg.CanonicalQueries),
+		WithServerTimingHeader(cfg.ServerTimingHeader),
+		WithMaxLabelMatcherSets(cfg.MaxLabelMatcherSets),
+		WithMergedSeriesLimit(cfg.MergedSeriesLimit),
+		WithCacheKeyIgnoredHeaders(cfg.CacheKeyIgnoredHeaders),
+	}
+}
+
 func (cfg *Config) cardinalityBasedShardingEnabled() bool {
 	return cfg.TargetSeriesPerShard > 0
 }
@@ -402,7 +513,11 @@ func newQueryMiddlewares(
 		queryBlockerMiddleware,
 	)
 
+	rewrittenQueryMiddleware := newRewrittenQueryMiddleware()
+
 	queryRangeMiddleware = append(queryRangeMiddleware,
+		// Return the rewritten queries, if requested. Added first so that it sees the final response.
+		rewrittenQueryMiddleware,
 		// Track query range statistics. Added first before any subsequent middleware modifies the request.
 		queryStatsMiddleware,
 		newLimitsMiddleware(limits, log),
@@ -416,6 +531,7 @@ func newQueryMiddlewares(
 	)
 
 	queryInstantMiddleware = append(queryInstantMiddleware,
+		rewrittenQueryMiddleware,
 		queryStatsMiddleware,
 		newLimitsMiddleware(limits, log),
 		queryBlockerMiddleware,
@@ -506,6 +622,16 @@ func newQueryMiddlewares(
 		newSpinOffSubqueriesMiddleware(limits, log, engine, registerer, splitAndCacheMiddleware, engineOpts.NoStepSubqueryIntervalFn),
 	)
 
+	// Run the instant queries converted to range queries through the split and cache middleware. Added after the
+	// subquery spin-off, which only handles instant queries.
+	if cfg.InstantQueriesAsRangeQueries && splitAndCacheMiddleware != nil {
+		queryInstantMiddleware = append(
+			queryInstantMiddleware,
+			newInstrumentMiddleware("instant_query_as_range_query", metrics),
+			newInstantQueryAsRangeQueryMiddleware(codec, splitAndCacheMiddleware),
+		)
+	}
+
 	if cfg.ShardedQueries {
 		// Inject the cardinality estimation middleware after time-based splitting and
 		// before query-sharding so that it can operate on the partial queries that are
@@ -544,6 +670,12 @@ func newQueryMiddlewares(
 		)
 	}
 
+	// Record the queries sent downstream once rewritten by all the previous middlewares. Added before
+	// the retry middleware, so that retried queries are recorded once.
+	rewrittenQueryRecorderMiddleware := newRewrittenQueryRecorderMiddleware()
+	queryRangeMiddleware = append(queryRangeMiddleware, rewrittenQueryRecorderMiddleware)
+	queryInstantMiddleware = append(queryInstantMiddleware, rewrittenQueryRecorderMiddleware)
+
 	if cfg.MaxRetries > 0 {
 		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, retryMetrics))
 		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, retryMetrics))
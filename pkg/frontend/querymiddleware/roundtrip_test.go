@@ -614,15 +614,17 @@ func TestMiddlewaresConsistency(t *testing.T) {
 		"remote read": {
 			instances: remoteReadMiddlewares,
 			exceptions: []string{
-				"querySharding",                   // No query sharding support.
-				"splitAndCacheMiddleware",         // No time splitting and results cache support.
-				"stepAlignMiddleware",             // Not applicable because remote read requests don't take step in account when running in Mimir.
-				"pruneMiddleware",                 // No query pruning support.
-				"experimentalFunctionsMiddleware", // No blocking for PromQL experimental functions as it is executed remotely.
-				"durationsMiddleware",             // No duration expressions support.
-				"prom2RangeCompatHandler",         // No rewriting Prometheus 2 subqueries to Prometheus 3
-				"spinOffSubqueriesMiddleware",     // This middleware is only for instant queries.
-				"queryLimiterMiddleware",          // This middleware is only for instant queries.
+				"querySharding",                    // No query sharding support.
+				"splitAndCacheMiddleware",          // No time splitting and results cache support.
+				"stepAlignMiddleware",              // Not applicable because remote read requests don't take step in account when running in Mimir.
+				"pruneMiddleware",                  // No query pruning support.
+				"experimentalFunctionsMiddleware",  // No blocking for PromQL experimental functions as it is executed remotely.
+				"durationsMiddleware",              // No duration expressions support.
+				"prom2RangeCompatHandler",          // No rewriting Prometheus 2 subqueries to Prometheus 3
+				"spinOffSubqueriesMiddleware",      // This middleware is only for instant queries.
+				"queryLimiterMiddleware",           // This middleware is only for instant queries.
+				"rewrittenQueryMiddleware",         // Remote read requests aren't rewritten.
+				"rewrittenQueryRecorderMiddleware", // Remote read requests aren't rewritten.
 			},
 		},
 	}
@@ -687,6 +689,26 @@ func TestConfig_Validate(t *testing.T) {
 			config:        Config{QueryResultResponseFormat: "something-else"},
 			expectedError: errors.New("unknown query result response format 'something-else'. Supported values: json, protobuf"),
 		},
+		"unknown default read consistency": {
+			config:        Config{QueryResultResponseFormat: formatJSON, DefaultReadConsistency: "something-else"},
+			expectedError: errors.New("unknown default read consistency 'something-else'. Supported values: strong, eventual"),
+		},
+		"unknown deprecated functions mode": {
+			config:        Config{QueryResultResponseFormat: formatJSON, DeprecatedFunctions: []string{"holt_winters"}, DeprecatedFunctionsMode: "something-else"},
+			expectedError: errors.New("unknown deprecated functions mode 'something-else'. Supported values: reject, warn"),
+		},
+		"unknown JSON float format": {
+			config:        Config{QueryResultResponseFormat: formatJSON, JSONFloatFormat: "something-else"},
+			expectedError: errors.New("unknown JSON float format 'something-else'. Supported values: auto, decimal, scientific"),
+		},
+		"unknown JSON non-finite floats mode": {
+			config:        Config{QueryResultResponseFormat: formatJSON, JSONNonFiniteFloats: "something-else"},
+			expectedError: errors.New("unknown JSON non-finite floats mode 'something-else'. Supported values: prometheus, javascript, null"),
+		},
+		"unknown out-of-order samples mode": {
+			config:        Config{QueryResultResponseFormat: formatJSON, OutOfOrderSamplesMode: "something-else"},
+			expectedError: errors.New("unknown out-of-order samples mode 'something-else'. Supported values: reject, repair"),
+		},
 	}
 
 	for name, test := range tests {
@@ -697,6 +719,88 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestConfig_CodecOptions(t *testing.T) {
+	t.Run("default config", func(t *testing.T) {
+		cfg := Config{}
+		flagext.DefaultValues(&cfg)
+
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, cfg.CodecOptions(log.NewNopLogger())...)
+		assert.Equal(t, codecOptions{
+			deprecatedFunctions:       map[string]struct{}{},
+			deprecatedFunctionsMode:   DeprecatedFunctionsModeWarn,
+			outOfOrderSamplesMode:     OutOfOrderSamplesModeReject,
+			maxPropagatedHeaders:      defaultMaxPropagatedHeaders,
+			maxPropagatedHeaderValues: defaultMaxPropagatedHeaderValues,
+			deprecationWarnings:       map[string]string{},
+			cacheKeyIgnoredHeaders:    map[string]struct{}{},
+		}, codec.codecOptions)
+	})
+
+	t.Run("custom config", func(t *testing.T) {
+		cfg := Config{}
+		flagext.DefaultValues(&cfg)
+		cfg.EmptyResultAsNull = true
+		cfg.StepAlignmentValidation = true
+		cfg.SortedMatrixMerge = true
+		cfg.QueryTimeRangeHeaders = true
+		cfg.InstantQueryTimeParamAlias = "ts"
+		cfg.DefaultReadConsistency = querierapi.ReadConsistencyStrong
+		cfg.LegacyBlockFormatInfo = "legacy block format"
+		cfg.UTF8LabelsValidation = true
+		cfg.DropStaleMarkers = true
+		cfg.DeprecatedFunctions = []string{"holt_winters"}
+		cfg.DeprecatedFunctionsMode = DeprecatedFunctionsModeReject
+		cfg.SortSeriesLabels = true
+		cfg.MaxQueryTimeout = time.Minute
+		cfg.QueryCostEstimateHeader = true
+		cfg.JSONFloatFormat = JSONFloatFormatScientific
+		cfg.OutOfOrderSamplesMode = OutOfOrderSamplesModeRepair
+		cfg.InstantQueriesAsRangeQueries = true
+		cfg.FormatterFallback = true
+		require.NoError(t, cfg.DeprecationWarnings.Set(`{"json_response": "JSON responses are deprecated"}`))
+		cfg.StrictQueryParams = true
+		cfg.ResponseSizeWarnThreshold = 1024
+		cfg.JSONNonFiniteFloats = JSONNonFiniteFloatsNull
+		cfg.CanonicalQueries = true
+		cfg.ServerTimingHeader = true
+		cfg.MaxLabelMatcherSets = 10
+		cfg.MergedSeriesLimit = true
+		cfg.CacheKeyIgnoredHeaders = []string{"x-dashboard-uid"}
+
+		codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil, cfg.CodecOptions(log.NewNopLogger())...)
+		assert.Equal(t, codecOptions{
+			emptyResultAsNull:            true,
+			validateStepAlignment:        true,
+			sortedMatrixMerge:            true,
+			queryTimeRangeHeaders:        true,
+			instantQueryTimeParamAlias:   "ts",
+			defaultReadConsistency:       querierapi.ReadConsistencyStrong,
+			legacyBlockFormatInfo:        "legacy block format",
+			validateUTF8Labels:           true,
+			sortSeriesLabels:             true,
+			dropStaleMarkers:             true,
+			deprecatedFunctions:          map[string]struct{}{"holt_winters": {}},
+			deprecatedFunctionsMode:      DeprecatedFunctionsModeReject,
+			outOfOrderSamplesMode:        OutOfOrderSamplesModeRepair,
+			maxPropagatedHeaders:         defaultMaxPropagatedHeaders,
+			maxPropagatedHeaderValues:    defaultMaxPropagatedHeaderValues,
+			maxQueryTimeout:              time.Minute,
+			queryCostEstimateHeader:      true,
+			serverTimingHeader:           true,
+			jsonFloats:                   jsonFloatFormatting{format: 'e', nonFinite: JSONNonFiniteFloatsNull},
+			instantQueriesAsRangeQueries: true,
+			formatterFallback:            true,
+			deprecationWarnings:          map[string]string{DeprecatedFeatureJSONResponse: "JSON responses are deprecated"},
+			strictQueryParams:            true,
+			responseSizeWarnThreshold:    1024,
+			canonicalQueries:             true,
+			maxLabelMatcherSets:          10,
+			mergedSeriesLimit:            true,
+			cacheKeyIgnoredHeaders:       map[string]struct{}{"X-Dashboard-Uid": {}},
+		}, codec.codecOptions)
+	})
+}
+
 func TestIsLabelsQuery(t *testing.T) {
 	tests := []struct {
 		path     string
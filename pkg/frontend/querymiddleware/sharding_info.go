@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// shardingInfoHeader is the request header asking the query sharding middleware to explain how a query has been
+	// sharded, and the response header surfacing the explanation: the number of shards and sharded queries, and the
+	// reason of the number of shards. The explanation is never included in the infos of the encoded responses.
+	shardingInfoHeader = "X-Mimir-Sharding-Info"
+
+	// shardingExplainAnnotationPrefix is the prefix of the info annotation explaining how a query has been sharded.
+	shardingExplainAnnotationPrefix = "sharding explain: "
+)
+
+// Reasons of the number of shards used to run a sharded query.
+const (
+	shardingReasonTenantLimit          = "tenant-limit"
+	shardingReasonControlHeader        = "sharding-control-header"
+	shardingReasonEstimatedSeriesCount = "estimated-series-count"
+	shardingReasonMaxShardedQueries    = "max-sharded-queries"
+	shardingReasonCompactorShards      = "compactor-shards"
+)
+
+// shardingExplanation explains how a query has been sharded by the query sharding middleware.
+type shardingExplanation struct {
+	totalShards    int
+	shardedQueries int
+	reason         string
+
+	// The estimated number of series returned by the query, or 0 if unknown.
+	estimatedSeriesCount uint64
+}
+
+// annotation returns the info annotation attached to the response of the sharded query.
+func (e shardingExplanation) annotation() string {
+	s := fmt.Sprintf("%stotal_shards=%d sharded_queries=%d reason=%s", shardingExplainAnnotationPrefix, e.totalShards, e.shardedQueries, e.reason)
+	if e.estimatedSeriesCount > 0 {
+		s += fmt.Sprintf(" estimated_series_count=%d", e.estimatedSeriesCount)
+	}
+	return s
+}
+
+// isShardingExplainRequested returns whether the request asks to explain how the query has been sharded.
+func isShardingExplainRequested(r MetricsQueryRequest) bool {
+	return boolRequestHeader(r, shardingInfoHeader)
+}
+
+// extractShardingExplanations returns the input infos without the annotations explaining how the query
+// has been sharded, and the explanations carried by these annotations. The input infos are not modified.
+func extractShardingExplanations(infos []string) (remaining, explanations []string) {
+	for i, info := range infos {
+		if !strings.HasPrefix(info, shardingExplainAnnotationPrefix) {
+			if explanations != nil {
+				remaining = append(remaining, info)
+			}
+			continue
+		}
+		if explanations == nil {
+			remaining = append(make([]string, 0, len(infos)-1), infos[:i]...)
+		}
+		explanations = append(explanations, strings.TrimPrefix(info, shardingExplainAnnotationPrefix))
+	}
+	if explanations == nil {
+		return infos, nil
+	}
+	return remaining, explanations
+}
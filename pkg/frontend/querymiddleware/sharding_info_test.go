@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractShardingExplanations(t *testing.T) {
+	const explanation = "total_shards=16 sharded_queries=16 reason=tenant-limit"
+
+	for name, tc := range map[string]struct {
+		infos                []string
+		expectedRemaining    []string
+		expectedExplanations []string
+	}{
+		"no infos": {},
+		"no sharding explanation": {
+			infos:             []string{"info 1", "info 2"},
+			expectedRemaining: []string{"info 1", "info 2"},
+		},
+		"only a sharding explanation": {
+			infos:                []string{shardingExplainAnnotationPrefix + explanation},
+			expectedRemaining:    []string{},
+			expectedExplanations: []string{explanation},
+		},
+		"sharding explanations mixed with other infos": {
+			infos:                []string{"info 1", shardingExplainAnnotationPrefix + explanation, "info 2", shardingExplainAnnotationPrefix + "other"},
+			expectedRemaining:    []string{"info 1", "info 2"},
+			expectedExplanations: []string{explanation, "other"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			remaining, explanations := extractShardingExplanations(tc.infos)
+			assert.Equal(t, tc.expectedRemaining, remaining)
+			assert.Equal(t, tc.expectedExplanations, explanations)
+		})
+	}
+}
+
+func TestCodec_EncodeMetricsQueryResponse_ShardingInfoHeader(t *testing.T) {
+	const explanation = "total_shards=16 sharded_queries=16 reason=tenant-limit"
+
+	tests := map[string]struct {
+		infos                []string
+		expectedExplanations []string
+	}{
+		"explained sharded query": {
+			infos:                []string{"info", shardingExplainAnnotationPrefix + explanation},
+			expectedExplanations: []string{explanation},
+		},
+		"not explained query": {
+			infos: []string{"info"},
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			newResponse := func() *PrometheusResponse {
+				return &PrometheusResponse{
+					Status: statusSuccess,
+					Data:   &PrometheusData{ResultType: "vector", Result: []SampleStream{}},
+					Infos:  slices.Clone(testData.infos),
+				}
+			}
+
+			codec := NewCodec(prometheus.NewPedanticRegistry(), 0, formatJSON, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=foo&time=0", nil)
+			req.Header.Set("Accept", jsonMimeType)
+
+			resp := newResponse()
+			encoded, err := codec.EncodeMetricsQueryResponse(context.Background(), req, resp)
+			require.NoError(t, err)
+			body, err := io.ReadAll(encoded.Body)
+			require.NoError(t, err)
+			require.NoError(t, encoded.Body.Close())
+
+			// The explanation is never included in the infos, and the input response is not modified.
+			assert.JSONEq(t, `{"status":"success","data":{"resultType":"vector","result":[]},"infos":["info"]}`, string(body))
+			assert.Equal(t, newResponse(), resp)
+			assert.Equal(t, testData.expectedExplanations, encoded.Header.Values(shardingInfoHeader))
+		})
+	}
+}
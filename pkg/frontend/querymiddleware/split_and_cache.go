@@ -153,7 +153,7 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req MetricsQueryReques
 
 	// Force queier to track PerStepStats, so they could be cached.
 	if s.cacheSamplesProcessedStats {
-		req, err = req.WithStats("all")
+		req, err = req.WithStats(statsAll)
 		if err != nil {
 			return nil, err
 		}
@@ -322,7 +322,7 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req MetricsQueryReques
 		responses = append(responses, splitReq.downstreamResponses...)
 	}
 
-	return s.merger.MergeResponse(responses...)
+	return s.merger.MergeResponseForRequest(req, responses...)
 }
 
 // splitRequestByInterval splits the given MetricsQueryRequest by configured interval. Returns the input request if splitting is disabled.
@@ -446,8 +446,21 @@ func (s Sample) MarshalJSON() ([]byte, error) {
 func (s *Sample) UnmarshalJSON(b []byte) error {
 	var t model.Time
 	var v model.SampleValue
-	vs := [...]stdjson.Unmarshaler{&t, &v}
-	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(b, &vs); err != nil {
+	var raw []stdjson.RawMessage
+	// Decoded with the standard library, which keeps null elements as "null" raw messages.
+	if err := stdjson.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("sample should have 2 elements, got %d", len(raw))
+	}
+	if err := t.UnmarshalJSON(raw[0]); err != nil {
+		return err
+	}
+	// Some clients encode the NaN and infinite values as null, which is decoded as NaN.
+	if string(raw[1]) == "null" {
+		v = model.SampleValue(math.NaN())
+	} else if err := v.UnmarshalJSON(raw[1]); err != nil {
 		return err
 	}
 	s.TimestampMs = int64(t)
@@ -487,12 +500,19 @@ func SampleJsoniterDecode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
 		return
 	}
 
-	bs := iter.ReadStringAsSlice()
-	ss := *(*string)(unsafe.Pointer(&bs))
-	v, err := strconv.ParseFloat(ss, 64)
-	if err != nil {
-		iter.ReportError("mimirpb.Sample", err.Error())
-		return
+	// Some clients encode the NaN and infinite values as null, which is decoded as NaN.
+	var v float64
+	if iter.ReadNil() {
+		v = math.NaN()
+	} else {
+		bs := iter.ReadStringAsSlice()
+		ss := *(*string)(unsafe.Pointer(&bs))
+		var err error
+		v, err = strconv.ParseFloat(ss, 64)
+		if err != nil {
+			iter.ReportError("mimirpb.Sample", err.Error())
+			return
+		}
 	}
 
 	if isTesting && math.IsNaN(v) {
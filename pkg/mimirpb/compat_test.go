@@ -80,6 +80,12 @@ func testUnmarshalling(t *testing.T, unmarshalFn func(data []byte, v interface{}
 	require.NoError(t, err)
 	require.Equal(t, int64(0), sample.TimestampMs)
 	require.True(t, math.IsNaN(sample.Value))
+
+	// A null value is decoded as NaN.
+	err = unmarshalFn([]byte(`[1.5,null]`), &sample)
+	require.NoError(t, err)
+	require.Equal(t, int64(1500), sample.TimestampMs)
+	require.True(t, math.IsNaN(sample.Value))
 }
 
 func TestMarshalSampleHistogramPair(t *testing.T) {
@@ -6,11 +6,16 @@
 package ruler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -21,17 +26,41 @@ import (
 	"github.com/grafana/dskit/tenant"
 	"github.com/pkg/errors"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
 	"google.golang.org/api/googleapi"
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 )
 
+const (
+	ndjsonContentType = "application/x-ndjson"
+
+	// modifiedSinceNotSupportedWarning is the Warning header value set by the list rules API when the modified_since
+	// parameter has been ignored.
+	modifiedSinceNotSupportedWarning = `299 - "modified_since is not supported by the rule store, all rule groups have been returned"`
+
+	// ruleGroupsModTimeGranularity is the coarsest granularity of the rule groups modification times listed by the
+	// object storages: rule groups modified within the same second may be listed with the same modification time.
+	ruleGroupsModTimeGranularity = time.Second
+
+	// maxFailedRuleGroupsInWarning is the max number of rule groups failed to load listed in the warning header
+	// of the list rules API response, when requested with the allow_partial parameter.
+	maxFailedRuleGroupsInWarning = 10
+
+	// defaultSeverityLabel is the label the alerts are counted by when the list rules API is requested
+	// with the include_severity_counts parameter and without the severity_label one.
+	defaultSeverityLabel = "severity"
+)
+
 var (
 	// errNoValidOrgIDFound is returned when no valid org id is found in the request context.
 	errNoValidOrgIDFound = errors.New("no valid org id found")
@@ -84,6 +113,32 @@ type RuleGroup struct {
 	LastEvaluation time.Time `json:"lastEvaluation"`
 	EvaluationTime float64   `json:"evaluationTime"`
 	SourceTenants  []string  `json:"sourceTenants"`
+	// ActiveAlertsCount is the number of active alert instances across all alerting rules of the group.
+	// It's only set when requested with the include_counts parameter.
+	ActiveAlertsCount *int `json:"activeAlertsCount,omitempty"`
+	// EvaluationLatencyP50 and EvaluationLatencyP99 are the 50th and 99th percentiles, in seconds, of the duration
+	// of the recent evaluations of the group. They're only set when requested with the include_latency parameter
+	// and the evaluation history of the group is available.
+	EvaluationLatencyP50 *float64 `json:"evaluationLatencyP50,omitempty"`
+	EvaluationLatencyP99 *float64 `json:"evaluationLatencyP99,omitempty"`
+	// SeverityCounts is the number of pending and firing alert instances of the group, by value of the severity label.
+	// Alert instances without the label are counted under the empty value. It's only set when requested with the
+	// include_severity_counts parameter.
+	SeverityCounts map[string]*alertStateCounts `json:"severityCounts,omitempty"`
+	// Dependencies maps the index, in Rules, of each rule of the group reading the output of recording rules of the
+	// same group to the names of these recording rules. Rules are keyed by index because their names aren't unique
+	// within a group. It's only set when requested with the include_dependencies parameter.
+	Dependencies map[int][]string `json:"dependencies,omitempty"`
+	// ConfigHash is the checksum of the configuration of the group loaded by the ruler evaluating it, the same returned
+	// by the configuration API with the checksums_only parameter until the group is changed in the rule store and the
+	// rulers sync it. It's only set when requested with the include_config_hash parameter.
+	ConfigHash string `json:"configHash,omitempty"`
+}
+
+// alertStateCounts is the number of pending and firing alert instances.
+type alertStateCounts struct {
+	Pending int `json:"pending"`
+	Firing  int `json:"firing"`
 }
 
 type rule interface{}
@@ -182,6 +237,41 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	includeCounts, err := parseBoolParam(req, "include_counts")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid include_counts parameter")
+		return
+	}
+
+	includeLatency, err := parseBoolParam(req, "include_latency")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid include_latency parameter")
+		return
+	}
+
+	includeSeverityCounts, err := parseBoolParam(req, "include_severity_counts")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid include_severity_counts parameter")
+		return
+	}
+
+	includeDependencies, err := parseBoolParam(req, "include_dependencies")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid include_dependencies parameter")
+		return
+	}
+
+	includeConfigHash, err := parseBoolParam(req, "include_config_hash")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid include_config_hash parameter")
+		return
+	}
+
+	severityLabel := req.URL.Query().Get("severity_label")
+	if severityLabel == "" {
+		severityLabel = defaultSeverityLabel
+	}
+
 	var maxGroups int32
 	if maxGroupsVal := req.URL.Query().Get("group_limit"); maxGroupsVal != "" {
 		maxGroupsRaw, err := strconv.ParseInt(maxGroupsVal, 10, 32)
@@ -193,11 +283,12 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 	}
 
 	rulesReq := RulesRequest{
-		Filter:        AnyRule,
-		RuleName:      req.URL.Query()["rule_name"],
-		RuleGroup:     req.URL.Query()["rule_group"],
-		File:          req.URL.Query()["file"],
-		ExcludeAlerts: excludeAlerts,
+		Filter:    AnyRule,
+		RuleName:  req.URL.Query()["rule_name"],
+		RuleGroup: req.URL.Query()["rule_group"],
+		File:      req.URL.Query()["file"],
+		// Alerts are needed to count them by severity, even if they're excluded from the response.
+		ExcludeAlerts: excludeAlerts && !includeSeverityCounts,
 		NextToken:     req.URL.Query().Get("group_next_token"),
 		MaxGroups:     maxGroups,
 	}
@@ -215,17 +306,17 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 		rulesReq.File = req.URL.Query()["file[]"]
 	}
 
-	ruleTypeFilter := strings.ToLower(req.URL.Query().Get("type"))
-	if ruleTypeFilter != "" {
-		switch ruleTypeFilter {
-		case "alert":
-			rulesReq.Filter = AlertingRule
-		case "record":
-			rulesReq.Filter = RecordingRule
-		default:
-			respondInvalidRequest(logger, w, fmt.Sprintf("not supported value %q", ruleTypeFilter))
-			return
-		}
+	// Federated rule groups can be filtered by source tenant. Any of the given
+	// source tenants must be in the group's source tenants for it to match.
+	sourceTenants := req.URL.Query()["source_tenant"]
+	if req.URL.Query().Has("source_tenant[]") {
+		sourceTenants = req.URL.Query()["source_tenant[]"]
+	}
+
+	rulesReq.Filter, err = parseRuleTypeFilter(req)
+	if err != nil {
+		respondInvalidRequest(logger, w, err.Error())
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -241,6 +332,10 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 
 	groups := make([]*RuleGroup, 0, len(rulesResp.Groups))
 	for _, g := range rulesResp.Groups {
+		if len(sourceTenants) > 0 && !hasAnySourceTenant(g.Group.GetSourceTenants(), sourceTenants) {
+			continue
+		}
+
 		grp := RuleGroup{
 			Name:           g.Group.Name,
 			File:           g.Group.Namespace,
@@ -251,8 +346,19 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 			SourceTenants:  g.Group.GetSourceTenants(),
 		}
 
+		activeAlertsCount := 0
+		var severityCounts map[string]*alertStateCounts
+		if includeSeverityCounts {
+			severityCounts = map[string]*alertStateCounts{}
+		}
+
 		for i, rl := range g.ActiveRules {
 			if g.ActiveRules[i].Rule.Alert != "" {
+				activeAlertsCount += int(rl.GetActiveAlertsCount())
+				if includeSeverityCounts {
+					countAlertsBySeverity(severityCounts, rl.Alerts, severityLabel)
+				}
+
 				var alerts []*Alert
 				if !excludeAlerts {
 					alerts = make([]*Alert, 0, len(rl.Alerts))
@@ -289,6 +395,27 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 
+		if includeCounts {
+			grp.ActiveAlertsCount = &activeAlertsCount
+		}
+		if includeSeverityCounts {
+			grp.SeverityCounts = severityCounts
+		}
+		if includeDependencies {
+			grp.Dependencies = ruleGroupDependencies(g.ActiveRules)
+		}
+		if includeConfigHash {
+			grp.ConfigHash = g.GetConfigHash()
+		}
+
+		// The evaluation history isn't available if the group hasn't been evaluated yet by the ruler
+		// owning it: in this case, only the last evaluation time of the group is returned.
+		if includeLatency && g.GetEvaluationLatencyP99() > 0 {
+			p50, p99 := g.GetEvaluationLatencyP50().Seconds(), g.GetEvaluationLatencyP99().Seconds()
+			grp.EvaluationLatencyP50 = &p50
+			grp.EvaluationLatencyP99 = &p99
+		}
+
 		groups = append(groups, &grp)
 	}
 
@@ -310,6 +437,69 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// hasAnySourceTenant returns true if any of the wanted tenants is in the group's source tenants.
+func hasAnySourceTenant(groupSourceTenants, wanted []string) bool {
+	for _, t := range wanted {
+		if slices.Contains(groupSourceTenants, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRuleTypeFilter returns the type of the rules to return, given by the type parameter, or by the complementary
+// exclude_recording and exclude_alerting parameters, which exclude a type of rules without selecting the other.
+func parseRuleTypeFilter(req *http.Request) (RulesRequest_RuleType, error) {
+	filter := AnyRule
+	switch ruleTypeFilter := strings.ToLower(req.URL.Query().Get("type")); ruleTypeFilter {
+	case "":
+	case "alert":
+		filter = AlertingRule
+	case "record":
+		filter = RecordingRule
+	default:
+		return AnyRule, fmt.Errorf("not supported value %q", ruleTypeFilter)
+	}
+
+	excludeRecording, err := parseBoolParam(req, "exclude_recording")
+	if err != nil {
+		return AnyRule, err
+	}
+	excludeAlerting, err := parseBoolParam(req, "exclude_alerting")
+	if err != nil {
+		return AnyRule, err
+	}
+
+	switch {
+	case excludeRecording && excludeAlerting:
+		return AnyRule, errors.New("exclude_recording and exclude_alerting can't be both set, because all rules would be excluded")
+	case excludeRecording && filter == RecordingRule:
+		return AnyRule, errors.New("exclude_recording can't be set when selecting recording rules with the type parameter")
+	case excludeAlerting && filter == AlertingRule:
+		return AnyRule, errors.New("exclude_alerting can't be set when selecting alerting rules with the type parameter")
+	case excludeRecording:
+		return AlertingRule, nil
+	case excludeAlerting:
+		return RecordingRule, nil
+	}
+	return filter, nil
+}
+
+// parseBoolParam returns the value of the input boolean query parameter, false if not set.
+func parseBoolParam(req *http.Request, name string) (bool, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return parsed, nil
+}
+
 func parseExcludeAlerts(req *http.Request) (bool, error) {
 	excludeAlerts := req.URL.Query().Get("exclude_alerts")
 	if excludeAlerts == "" {
@@ -324,6 +514,113 @@ func parseExcludeAlerts(req *http.Request) (bool, error) {
 	return value, nil
 }
 
+// ruleGroupDependencies returns the names of the recording rules each rule of a group depends on, keyed by the index
+// of the dependent rule in the input rules. A rule depends on a recording rule of the same group if its query selects the metric name
+// recorded by it. Selectors not matching the metric name by equality, and rules whose query can't be parsed, are
+// ignored. Rules without dependencies aren't included in the returned map.
+func ruleGroupDependencies(rules []*RuleStateDesc) map[int][]string {
+	recorded := map[string]struct{}{}
+	for _, rl := range rules {
+		if rl.Rule.GetRecord() != "" {
+			recorded[rl.Rule.GetRecord()] = struct{}{}
+		}
+	}
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	var deps map[int][]string
+	for i, rl := range rules {
+		expr, err := parser.ParseExpr(rl.Rule.GetExpr())
+		if err != nil {
+			continue
+		}
+
+		for _, matchers := range parser.ExtractSelectors(expr) {
+			for _, m := range matchers {
+				if m.Name != model.MetricNameLabel || m.Type != labels.MatchEqual {
+					continue
+				}
+				if _, ok := recorded[m.Value]; !ok || m.Value == rl.Rule.GetRecord() || slices.Contains(deps[i], m.Value) {
+					continue
+				}
+				if deps == nil {
+					deps = map[int][]string{}
+				}
+				deps[i] = append(deps[i], m.Value)
+			}
+		}
+	}
+
+	for _, d := range deps {
+		slices.Sort(d)
+	}
+	return deps
+}
+
+// countAlertsBySeverity adds the pending and firing alerts to the counts, by value of the severity label.
+func countAlertsBySeverity(counts map[string]*alertStateCounts, alerts []*AlertStateDesc, severityLabel string) {
+	for _, a := range alerts {
+		pending := a.State == promRules.StatePending.String()
+		if !pending && a.State != promRules.StateFiring.String() {
+			continue
+		}
+
+		severity := ""
+		for _, l := range a.Labels {
+			if l.Name == severityLabel {
+				severity = l.Value
+				break
+			}
+		}
+
+		c := counts[severity]
+		if c == nil {
+			c = &alertStateCounts{}
+			counts[severity] = c
+		}
+
+		if pending {
+			c.Pending++
+		} else {
+			c.Firing++
+		}
+	}
+}
+
+const (
+	groupByNamespace = "namespace"
+	groupByFile      = "file"
+)
+
+// parseGroupByFile returns whether the group_by parameter asks to return the rule groups as a list of rule files,
+// one per namespace, instead of a map keyed by namespace.
+func parseGroupByFile(req *http.Request) (bool, error) {
+	switch groupBy := req.URL.Query().Get("group_by"); groupBy {
+	case "", groupByNamespace:
+		return false, nil
+	case groupByFile:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported group_by value %q", groupBy)
+	}
+}
+
+// parseModifiedSince returns the time set by the modified_since parameter, or the zero time if it's not set.
+func parseModifiedSince(req *http.Request) (time.Time, error) {
+	modifiedSince := req.URL.Query().Get("modified_since")
+	if modifiedSince == "" {
+		return time.Time{}, nil
+	}
+
+	value, err := util.ParseTime(modifiedSince)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse modified_since value %w", err)
+	}
+
+	return time.UnixMilli(value), nil
+}
+
 func (a *API) PrometheusAlerts(w http.ResponseWriter, req *http.Request) {
 	logger, ctx := spanlogger.New(req.Context(), a.logger, tracer, "API.PrometheusAlerts")
 	defer logger.Finish()
@@ -510,28 +807,92 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	checksumsOnly, err := parseBoolParam(req, "checksums_only")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid checksums_only parameter")
+		return
+	}
+
+	modifiedSince, err := parseModifiedSince(req)
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid modified_since parameter")
+		return
+	}
+
+	allowPartial, err := parseBoolParam(req, "allow_partial")
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid allow_partial parameter")
+		return
+	}
+
+	byFile, err := parseGroupByFile(req)
+	if err != nil {
+		respondInvalidRequest(logger, w, "invalid group_by parameter")
+		return
+	}
+
 	level.Debug(logger).Log("msg", "retrieving rule groups with namespace", "userID", userID, "namespace", namespace)
-	// Disable any caching when getting list of all rule groups since listing results
-	// are cached and not invalidated and this API is expected to be strongly consistent.
-	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace, rulestore.WithCacheDisabled())
+	rgs, modTimes, err := a.listRuleGroups(ctx, w, logger, userID, namespace, modifiedSince)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if acceptsNDJSON(req) && !checksumsOnly {
+		a.streamRuleGroupsAsNDJSON(ctx, w, logger, userID, rgs, allowPartial)
+		return
+	}
+
 	if len(rgs) == 0 {
 		level.Info(logger).Log("msg", "no rule groups found", "userID", userID)
-		// No rule groups, short-circuit and just return an empty map with HTTP 200
+		// No rule groups, short-circuit and just return an empty map (or list of rule files) with HTTP 200
+		if byFile && !checksumsOnly {
+			marshalAndSend([]rulespb.RuleFile{}, w, logger)
+			return
+		}
 		marshalAndSend(map[string]interface{}{}, w, logger)
 		return
 	}
 
 	level.Debug(logger).Log("msg", "retrieved rule groups from rule store", "userID", userID, "num_groups", len(rgs))
-	missing, err := a.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: rgs})
+
+	// The ETag is computed from the listing, so that polling clients can skip loading and downloading the rule
+	// groups if they haven't changed since the last request. It's only available if the rule store can tell when
+	// the rule groups have been modified, and not while a rule group could still be modified again without changing
+	// the listing.
+	var etagHeader http.Header
+	if modTimes != nil && !ruleGroupsModifiedRecently(modTimes, time.Now()) {
+		etag := ruleGroupsETag(rgs, modTimes, checksumsOnly, byFile, a.protectedNamespacesHeader(userID, rgs))
+		if etagMatches(req.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		etagHeader = http.Header{"ETag": []string{etag}}
+	}
+
+	missing, failed, err := a.loadRuleGroupsInBatches(ctx, userID, rgs, allowPartial)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if len(failed) > 0 {
+		level.Warn(logger).Log(
+			"msg", "list rules API skipped some rule groups, because they failed to load and a partial response is allowed",
+			"user", userID,
+			"listed_rule_groups", len(rgs),
+			"failed_rule_groups", len(failed),
+			"first_failed_rule_group_namespace", failed[0].Namespace,
+			"first_failed_rule_group_name", failed[0].Name)
+
+		// The rule groups failed to load are filtered out, like the missing ones, and listed in a warning.
+		rgs = filterOutRuleGroups(rgs, failed)
+		w.Header().Add("Warning", ruleGroupsFailedToLoadWarning(failed))
+	}
+	if len(failed) > 0 || len(missing) > 0 {
+		// The response doesn't match the listing the ETag has been computed from.
+		etagHeader = nil
+	}
 	if len(missing) > 0 {
 		// This API is expected to be strongly consistent, but we expect the object storage to be strongly
 		// consistent too. This means that if a rule group existed when we listed the storage but doesn't exist
@@ -571,19 +932,376 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 	}
 
 	numRules := 0
-	protectedNamespaces := map[string]struct{}{}
 	for _, rg := range rgs {
 		numRules += len(rg.Rules)
+	}
+
+	level.Debug(logger).Log("msg", "retrieved rules for rule groups from rule store", "userID", userID, "num_groups", len(rgs), "num_rules", numRules)
+
+	protectedNamespacesHeader := a.protectedNamespacesHeader(userID, rgs)
+
+	if checksumsOnly {
+		checksums, err := rgs.Checksums()
+		if err != nil {
+			level.Error(logger).Log("msg", "error computing rule group checksums", "userID", userID, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshalAndSend(checksums, w, logger, protectedNamespacesHeader, etagHeader)
+		return
+	}
+
+	if byFile {
+		marshalAndSend(rgs.FormattedByFile(), w, logger, protectedNamespacesHeader, etagHeader)
+		return
+	}
+
+	formatted := rgs.Formatted()
+	marshalAndSend(formatted, w, logger, protectedNamespacesHeader, etagHeader)
+}
+
+// listRuleGroups lists the rule groups of the user in the namespace, and their modification times if the rule store
+// can tell when rule groups have been modified (nil otherwise). If modifiedSince is not zero, only the rule groups
+// modified after it are listed, including the ones modified within its second, because the modification times may
+// have been truncated to the second. If the rule store can't tell when rule groups have been modified, all rule groups are
+// listed and a warning header is added to the response, so that clients know the result hasn't been filtered.
+func (a *API) listRuleGroups(ctx context.Context, w http.ResponseWriter, logger log.Logger, userID, namespace string, modifiedSince time.Time) (rulespb.RuleGroupList, []time.Time, error) {
+	// Disable any caching when getting list of all rule groups since listing results
+	// are cached and not invalidated and this API is expected to be strongly consistent.
+	if lister, ok := a.store.(rulestore.ModifiedRuleGroupsLister); ok {
+		rgs, modTimes, err := lister.ListRuleGroupsWithModTime(ctx, userID, namespace, rulestore.WithCacheDisabled())
+		if err == nil {
+			if modifiedSince.IsZero() {
+				return rgs, modTimes, nil
+			}
+
+			filteredRgs := make(rulespb.RuleGroupList, 0, len(rgs))
+			filteredModTimes := make([]time.Time, 0, len(modTimes))
+			for i, rg := range rgs {
+				if !modTimes[i].Before(modifiedSince.Truncate(ruleGroupsModTimeGranularity)) {
+					filteredRgs = append(filteredRgs, rg)
+					filteredModTimes = append(filteredModTimes, modTimes[i])
+				}
+			}
+			return filteredRgs, filteredModTimes, nil
+		}
+		if !errors.Is(err, rulestore.ErrModTimeNotSupported) {
+			return nil, nil, err
+		}
+	}
+
+	if !modifiedSince.IsZero() {
+		level.Debug(logger).Log("msg", "rule store doesn't support listing rule groups by modification time, returning all rule groups", "userID", userID)
+		w.Header().Set("Warning", modifiedSinceNotSupportedWarning)
+	}
 
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace, rulestore.WithCacheDisabled())
+	return rgs, nil, err
+}
+
+// protectedNamespacesHeader returns the header listing the protected namespaces of the input rule groups.
+func (a *API) protectedNamespacesHeader(userID string, rgs rulespb.RuleGroupList) http.Header {
+	protectedNamespaces := map[string]struct{}{}
+	for _, rg := range rgs {
 		if a.ruler.IsNamespaceProtected(userID, rg.Namespace) {
 			protectedNamespaces[rg.Namespace] = struct{}{}
 		}
 	}
+	return ProtectedNamespacesHeaderFromSet(protectedNamespaces)
+}
 
-	level.Debug(logger).Log("msg", "retrieved rules for rule groups from rule store", "userID", userID, "num_groups", len(rgs), "num_rules", numRules)
+// ruleGroupsETag returns a weak ETag of the list rules response for the input rule groups. The ETag is computed
+// over the sorted names and modification times of the listed rule groups and everything else the response depends on,
+// so that it doesn't require loading the rule groups content. It's weak because the listing doesn't provide the hash
+// of the rule groups content.
+func ruleGroupsETag(rgs rulespb.RuleGroupList, modTimes []time.Time, checksumsOnly, byFile bool, protectedNamespacesHeader http.Header) string {
+	entries := make([]string, 0, len(rgs))
+	for i, rg := range rgs {
+		entries = append(entries, rg.Namespace+"\x00"+rg.Name+"\x00"+strconv.FormatInt(modTimes[i].UnixNano(), 10))
+	}
+	slices.Sort(entries)
 
-	formatted := rgs.Formatted()
-	marshalAndSend(formatted, w, logger, ProtectedNamespacesHeaderFromSet(protectedNamespaces))
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "checksums_only=%t\n", checksumsOnly)
+	if byFile && !checksumsOnly {
+		_, _ = h.Write([]byte("group_by=" + groupByFile + "\n"))
+	}
+	_, _ = fmt.Fprintf(h, "protected_namespaces=%s\n", strings.Join(protectedNamespacesHeader.Values(ProtectedNamespacesHeader), ","))
+	for _, e := range entries {
+		_, _ = h.Write([]byte(e + "\n"))
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// ruleGroupsModifiedRecently returns whether any rule group has been modified within ruleGroupsModTimeGranularity
+// before now, or after it. Such a rule group could be modified again without changing its listed modification time.
+func ruleGroupsModifiedRecently(modTimes []time.Time, now time.Time) bool {
+	for _, t := range modTimes {
+		if now.Sub(t) < ruleGroupsModTimeGranularity {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches returns whether the If-None-Match header value matches the ETag, using the weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRuleGroupsInBatches loads the input rule groups, at most -ruler.list-rules-load-batch-size at a time, and returns
+// the ones missing in the rule store. If allowPartial is true, the rule groups failing to load are returned as failed
+// instead of failing the whole load. See loadRuleGroupsBatch. All the loaded rule groups are kept in memory, because
+// the response headers depend on the rule groups failing to load or missing: see streamRuleGroupsAsNDJSON to bound
+// the memory used.
+func (a *API) loadRuleGroupsInBatches(ctx context.Context, userID string, rgs rulespb.RuleGroupList, allowPartial bool) (missing, failed rulespb.RuleGroupList, _ error) {
+	batchSize := a.ruler.cfg.ListRulesLoadBatchSize
+
+	for start := 0; start < len(rgs); start += batchSize {
+		batch := rgs[start:min(start+batchSize, len(rgs))]
+
+		batchMissing, batchFailed, err := a.loadRuleGroupsBatch(ctx, userID, batch, allowPartial)
+		if err != nil {
+			return nil, nil, err
+		}
+		missing = append(missing, batchMissing...)
+		failed = append(failed, batchFailed...)
+	}
+
+	return missing, failed, nil
+}
+
+// loadRuleGroupsBatch loads the input rule groups and returns the ones missing in the rule store. If allowPartial is
+// true and the rule groups fail to load together, they're loaded one at a time, and the ones failing to load are
+// returned as failed instead of failing the whole batch. The content of the failed rule groups must not be used.
+func (a *API) loadRuleGroupsBatch(ctx context.Context, userID string, batch rulespb.RuleGroupList, allowPartial bool) (missing, failed rulespb.RuleGroupList, _ error) {
+	missing, err := a.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: batch})
+	if err == nil || !allowPartial || ctx.Err() != nil {
+		return missing, nil, err
+	}
+
+	missing = nil
+	for _, rg := range batch {
+		groupMissing, err := a.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: {rg}})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			failed = append(failed, rg)
+			continue
+		}
+		missing = append(missing, groupMissing...)
+	}
+
+	return missing, failed, nil
+}
+
+// filterOutRuleGroups returns the input rule groups, except the ones with the same namespace and name of a rule group
+// to remove.
+func filterOutRuleGroups(rgs, toRemove rulespb.RuleGroupList) rulespb.RuleGroupList {
+	removeLookup := make(map[string]struct{}, len(toRemove))
+	for _, rg := range toRemove {
+		removeLookup[rg.GetNamespace()+"\x00"+rg.GetName()] = struct{}{}
+	}
+
+	filtered := make(rulespb.RuleGroupList, 0, len(rgs))
+	for _, rg := range rgs {
+		if _, remove := removeLookup[rg.GetNamespace()+"\x00"+rg.GetName()]; !remove {
+			filtered = append(filtered, rg)
+		}
+	}
+	return filtered
+}
+
+// ruleGroupsFailedToLoadWarning returns the value of the warning header listing the rule groups failed to load.
+func ruleGroupsFailedToLoadWarning(failed rulespb.RuleGroupList) string {
+	names := make([]string, 0, min(len(failed), maxFailedRuleGroupsInWarning))
+	for _, rg := range failed[:min(len(failed), maxFailedRuleGroupsInWarning)] {
+		names = append(names, rg.GetNamespace()+"/"+rg.GetName())
+	}
+	if len(failed) > maxFailedRuleGroupsInWarning {
+		names = append(names, fmt.Sprintf("and %d more", len(failed)-maxFailedRuleGroupsInWarning))
+	}
+
+	return fmt.Sprintf("299 - %q", fmt.Sprintf("%d rule groups failed to load and have been omitted: %s", len(failed), strings.Join(names, ", ")))
+}
+
+// streamRuleGroupsAsNDJSON loads the input rule groups in batches and writes each of them to the response
+// as a JSON object on its own line, so that the whole serialized response is never held in memory.
+func (a *API) streamRuleGroupsAsNDJSON(ctx context.Context, w http.ResponseWriter, logger log.Logger, userID string, rgs rulespb.RuleGroupList, allowPartial bool) {
+	// Headers must be set before writing the first rule group, so protected namespaces are
+	// computed upfront from the listed rule groups.
+	protectedNamespaces := map[string]struct{}{}
+	for _, rg := range rgs {
+		if a.ruler.IsNamespaceProtected(userID, rg.Namespace) {
+			protectedNamespaces[rg.Namespace] = struct{}{}
+		}
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	for headerKey, headerValue := range ProtectedNamespacesHeaderFromSet(protectedNamespaces) {
+		w.Header().Set(headerKey, strings.Join(headerValue, ","))
+	}
+
+	var (
+		enc        = json.NewEncoder(w)
+		flusher, _ = w.(http.Flusher)
+		numWritten int
+		numMissing int
+		numFailed  int
+	)
+
+	batchSize := a.ruler.cfg.ListRulesLoadBatchSize
+	for start := 0; start < len(rgs); start += batchSize {
+		batch := rgs[start:min(start+batchSize, len(rgs))]
+
+		missing, failed, err := a.loadRuleGroupsBatch(ctx, userID, batch, allowPartial)
+		if err != nil {
+			if numWritten == 0 {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			// The response has already been partially sent, so we can't report the error to the client.
+			level.Error(logger).Log("msg", "failed to load rule groups while streaming list rules response", "user", userID, "err", err)
+			return
+		}
+
+		// Rule groups missing when loading them could have been deleted after listing the storage: they're skipped,
+		// like in the non-streaming response. Rule groups failed to load are skipped too, if allowed.
+		missingLookup := make(map[string]struct{}, len(missing))
+		for _, rg := range missing {
+			missingLookup[rg.GetNamespace()+"\x00"+rg.GetName()] = struct{}{}
+		}
+		failedLookup := make(map[string]struct{}, len(failed))
+		for _, rg := range failed {
+			failedLookup[rg.GetNamespace()+"\x00"+rg.GetName()] = struct{}{}
+		}
+
+		for i, rg := range batch {
+			if _, isMissing := missingLookup[rg.GetNamespace()+"\x00"+rg.GetName()]; isMissing {
+				numMissing++
+				continue
+			}
+			if _, isFailed := failedLookup[rg.GetNamespace()+"\x00"+rg.GetName()]; isFailed {
+				numFailed++
+				continue
+			}
+
+			if err := enc.Encode(newRuleGroupNDJSON(rg)); err != nil {
+				level.Error(logger).Log("msg", "error writing ndjson rule group", "user", userID, "err", err)
+				return
+			}
+			numWritten++
+
+			// Release the loaded rule group as soon as it has been written.
+			batch[i] = nil
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if numMissing > 0 {
+		level.Warn(logger).Log(
+			"msg", "list rules API skipped some rule groups, because missing when loading them after listing the storage (this could be due to rule groups deleted between listing the storage and getting rule groups content)",
+			"user", userID,
+			"listed_rule_groups", len(rgs),
+			"missing_rule_groups", numMissing)
+	}
+	if numFailed > 0 {
+		// The response has already been partially sent, so the failed rule groups can only be logged.
+		level.Warn(logger).Log(
+			"msg", "list rules API skipped some rule groups, because they failed to load and a partial response is allowed",
+			"user", userID,
+			"listed_rule_groups", len(rgs),
+			"failed_rule_groups", numFailed)
+	}
+
+	level.Debug(logger).Log("msg", "streamed rule groups from rule store", "userID", userID, "num_groups", numWritten)
+}
+
+// acceptsNDJSON returns whether the client asked for the response to be streamed as newline delimited JSON.
+func acceptsNDJSON(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err == nil && mediaType == ndjsonContentType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ruleGroupNDJSON is the representation of a rule group in the NDJSON list rules response.
+// Field names match the ones used by the YAML response.
+type ruleGroupNDJSON struct {
+	Namespace                     string            `json:"namespace"`
+	Name                          string            `json:"name"`
+	Interval                      model.Duration    `json:"interval,omitempty"`
+	EvaluationDelay               *model.Duration   `json:"evaluation_delay,omitempty"`
+	QueryOffset                   *model.Duration   `json:"query_offset,omitempty"`
+	Limit                         int               `json:"limit,omitempty"`
+	Rules                         []ruleNDJSON      `json:"rules"`
+	Labels                        map[string]string `json:"labels,omitempty"`
+	SourceTenants                 []string          `json:"source_tenants,omitempty"`
+	AlignEvaluationTimeOnInterval bool              `json:"align_evaluation_time_on_interval,omitempty"`
+}
+
+type ruleNDJSON struct {
+	Record        string            `json:"record,omitempty"`
+	Alert         string            `json:"alert,omitempty"`
+	Expr          string            `json:"expr"`
+	For           model.Duration    `json:"for,omitempty"`
+	KeepFiringFor model.Duration    `json:"keep_firing_for,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+func newRuleGroupNDJSON(rg *rulespb.RuleGroupDesc) ruleGroupNDJSON {
+	formatted := rulespb.FromProto(rg)
+
+	out := ruleGroupNDJSON{
+		Namespace:                     rg.GetNamespace(),
+		Name:                          formatted.Name,
+		Interval:                      formatted.Interval,
+		EvaluationDelay:               formatted.EvaluationDelay, //nolint:staticcheck // We want to intentionally access a deprecated field
+		QueryOffset:                   formatted.QueryOffset,
+		Limit:                         formatted.Limit,
+		Rules:                         make([]ruleNDJSON, 0, len(formatted.Rules)),
+		Labels:                        formatted.Labels,
+		SourceTenants:                 formatted.SourceTenants,
+		AlignEvaluationTimeOnInterval: formatted.AlignEvaluationTimeOnInterval,
+	}
+
+	for _, r := range formatted.Rules {
+		out.Rules = append(out.Rules, ruleNDJSON{
+			Record:        r.Record,
+			Alert:         r.Alert,
+			Expr:          r.Expr,
+			For:           r.For,
+			KeepFiringFor: r.KeepFiringFor,
+			Labels:        r.Labels,
+			Annotations:   r.Annotations,
+		})
+	}
+
+	return out
 }
 
 func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
@@ -648,35 +1366,9 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
-
-	rg := rulefmt.RuleGroup{}
-	if err = yaml.Unmarshal(payload, &rg); err != nil {
-		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
-		http.Error(w, ErrBadRuleGroup.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Why do we unmarshal the rule group twice like this?
-	// Prometheus' validation methods require access to the original YAML nodes to produce errors with
-	// position (line and column) information, but we want to work with the non-YAML rulefmt.RuleGroup type.
-	// See https://github.com/prometheus/prometheus/pull/16252 for more discussion of this.
-	node := rulefmt.RuleGroupNode{}
-	if err = yaml.Unmarshal(payload, &node); err != nil {
-		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
-		http.Error(w, ErrBadRuleGroup.Error(), http.StatusBadRequest)
-		return
-	}
-
-	errs := a.ruler.manager.ValidateRuleGroup(userID, rg, node)
-	if len(errs) > 0 {
-		e := []string{}
-		for _, err := range errs {
-			level.Error(logger).Log("msg", "unable to validate rule group payload", "err", err.Error())
-			e = append(e, err.Error())
-		}
-
-		http.Error(w, strings.Join(e, ", "), http.StatusBadRequest)
+	rg, err := a.unmarshalAndValidateRuleGroup(logger, userID, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -738,6 +1430,41 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 	respondAccepted(w, logger)
 }
 
+// unmarshalAndValidateRuleGroup returns the rule group unmarshalled from the input YAML payload, or an error if it
+// can't be unmarshalled or it's invalid.
+func (a *API) unmarshalAndValidateRuleGroup(logger log.Logger, userID string, payload []byte) (rulefmt.RuleGroup, error) {
+	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
+
+	rg := rulefmt.RuleGroup{}
+	if err := yaml.Unmarshal(payload, &rg); err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
+		return rulefmt.RuleGroup{}, ErrBadRuleGroup
+	}
+
+	// Why do we unmarshal the rule group twice like this?
+	// Prometheus' validation methods require access to the original YAML nodes to produce errors with
+	// position (line and column) information, but we want to work with the non-YAML rulefmt.RuleGroup type.
+	// See https://github.com/prometheus/prometheus/pull/16252 for more discussion of this.
+	node := rulefmt.RuleGroupNode{}
+	if err := yaml.Unmarshal(payload, &node); err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
+		return rulefmt.RuleGroup{}, ErrBadRuleGroup
+	}
+
+	errs := a.ruler.manager.ValidateRuleGroup(userID, rg, node)
+	if len(errs) > 0 {
+		e := []string{}
+		for _, err := range errs {
+			level.Error(logger).Log("msg", "unable to validate rule group payload", "err", err.Error())
+			e = append(e, err.Error())
+		}
+
+		return rulefmt.RuleGroup{}, errors.New(strings.Join(e, ", "))
+	}
+
+	return rg, nil
+}
+
 func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 	logger, ctx := spanlogger.New(req.Context(), a.logger, tracer, "API.DeleteNamespace")
 	defer logger.Finish()
@@ -33,7 +33,9 @@ import (
 	"google.golang.org/api/googleapi"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	mimirtest "github.com/grafana/mimir/pkg/util/test"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
@@ -379,6 +381,738 @@ func TestRuler_ListRules(t *testing.T) {
 	}
 }
 
+func TestRuler_ListRules_ChecksumsOnly(t *testing.T) {
+	const (
+		userID   = "user1"
+		interval = time.Minute
+	)
+
+	group1 := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace1",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+		Interval:  interval,
+	}
+	group2 := &rulespb.RuleGroupDesc{
+		Name:      "group2",
+		Namespace: "namespace1",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+		Interval:  interval,
+	}
+	group3 := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace2",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("COUNT_UP_RULE", "count(up)")},
+		Interval:  interval,
+	}
+
+	checksum := func(rg *rulespb.RuleGroupDesc) string {
+		c, err := rulespb.Checksum(rg)
+		require.NoError(t, err)
+		return c
+	}
+
+	testCases := map[string]struct {
+		requestPath        string
+		acceptHeader       string
+		expectedStatusCode int
+		expectedChecksums  map[string]map[string]string
+	}{
+		"should return the checksums of all rule groups of an user": {
+			requestPath:        "/prometheus/config/v1/rules?checksums_only=true",
+			expectedStatusCode: http.StatusOK,
+			expectedChecksums: map[string]map[string]string{
+				"namespace1": {"group1": checksum(group1), "group2": checksum(group2)},
+				"namespace2": {"group1": checksum(group3)},
+			},
+		},
+		"should return the checksums of the rule groups belonging to the input namespace": {
+			requestPath:        "/prometheus/config/v1/rules/namespace2?checksums_only=true",
+			expectedStatusCode: http.StatusOK,
+			expectedChecksums: map[string]map[string]string{
+				"namespace2": {"group1": checksum(group3)},
+			},
+		},
+		"should return the checksums even if the client accepts ndjson": {
+			requestPath:        "/prometheus/config/v1/rules/namespace2?checksums_only=true",
+			acceptHeader:       "application/x-ndjson",
+			expectedStatusCode: http.StatusOK,
+			expectedChecksums: map[string]map[string]string{
+				"namespace2": {"group1": checksum(group3)},
+			},
+		},
+		"should fail on invalid checksums_only parameter": {
+			requestPath:        "/prometheus/config/v1/rules?checksums_only=invalid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+
+			store := newMockRuleStore(map[string]rulespb.RuleGroupList{userID: {group1, group2, group3}})
+
+			r := prepareRuler(t, cfg, store, withStart())
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			router := mux.NewRouter()
+			router.Path("/prometheus/config/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+			router.Path("/prometheus/config/v1/rules/{namespace}").Methods("GET").HandlerFunc(a.ListRules)
+			req := requestFor(t, http.MethodGet, "https://localhost:8080"+tc.requestPath, nil, userID)
+			if tc.acceptHeader != "" {
+				req.Header.Set("Accept", tc.acceptHeader)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			require.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			if tc.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			actual := map[string]map[string]string{}
+			require.NoError(t, yaml.Unmarshal(body, &actual))
+			require.Equal(t, tc.expectedChecksums, actual)
+		})
+	}
+}
+
+func TestRuler_ListRules_GroupByFile(t *testing.T) {
+	const (
+		userID   = "user1"
+		interval = time.Minute
+	)
+
+	group1 := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace1",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+		Interval:  interval,
+	}
+	group2 := &rulespb.RuleGroupDesc{
+		Name:      "group2",
+		Namespace: "namespace1",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+		Interval:  interval,
+	}
+	group3 := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace2",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("COUNT_UP_RULE", "count(up)")},
+		Interval:  interval,
+	}
+
+	testCases := map[string]struct {
+		requestPath        string
+		expectedStatusCode int
+		expectedFiles      []rulespb.RuleFile
+	}{
+		"should return all rule groups of an user grouped by file": {
+			requestPath:        "/prometheus/config/v1/rules?group_by=file",
+			expectedStatusCode: http.StatusOK,
+			expectedFiles: []rulespb.RuleFile{
+				{Namespace: "namespace1", Groups: []rulefmt.RuleGroup{rulespb.FromProto(group1), rulespb.FromProto(group2)}},
+				{Namespace: "namespace2", Groups: []rulefmt.RuleGroup{rulespb.FromProto(group3)}},
+			},
+		},
+		"should return the rule groups belonging to the input namespace grouped by file": {
+			requestPath:        "/prometheus/config/v1/rules/namespace2?group_by=file",
+			expectedStatusCode: http.StatusOK,
+			expectedFiles: []rulespb.RuleFile{
+				{Namespace: "namespace2", Groups: []rulefmt.RuleGroup{rulespb.FromProto(group3)}},
+			},
+		},
+		"should return an empty list if the namespace has no rule groups": {
+			requestPath:        "/prometheus/config/v1/rules/namespace3?group_by=file",
+			expectedStatusCode: http.StatusOK,
+			expectedFiles:      []rulespb.RuleFile{},
+		},
+		"should fail on invalid group_by parameter": {
+			requestPath:        "/prometheus/config/v1/rules?group_by=invalid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+
+			store := newMockRuleStore(map[string]rulespb.RuleGroupList{userID: {group1, group2, group3}})
+
+			r := prepareRuler(t, cfg, store, withStart())
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			router := mux.NewRouter()
+			router.Path("/prometheus/config/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+			router.Path("/prometheus/config/v1/rules/{namespace}").Methods("GET").HandlerFunc(a.ListRules)
+			req := requestFor(t, http.MethodGet, "https://localhost:8080"+tc.requestPath, nil, userID)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			require.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			if tc.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			expectedYAML, err := yaml.Marshal(tc.expectedFiles)
+			require.NoError(t, err)
+			require.YAMLEq(t, string(expectedYAML), string(body))
+		})
+	}
+}
+
+func TestRuler_ListRules_ETag(t *testing.T) {
+	const userID = "user1"
+
+	cfg := defaultRulerConfig(t)
+	r := prepareRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{userID: {
+		&rulespb.RuleGroupDesc{
+			Name:      "group1",
+			Namespace: "namespace1",
+			User:      userID,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+			Interval:  time.Minute,
+		},
+	}}), withStart())
+
+	modTime := time.UnixMilli(1_700_000_000_000)
+	store := &modTimeRuleStore{RuleStore: r.store, modTimes: map[string]time.Time{"group1": modTime}}
+	a := NewAPI(r, store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+
+	listRules := func(requestPath, ifNoneMatch string) *http.Response {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080"+requestPath, nil, userID)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := listRules("/prometheus/config/v1/rules", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	require.Equal(t, 1, store.loads)
+
+	// The listing hasn't changed, so the rule groups aren't loaded.
+	resp = listRules("/prometheus/config/v1/rules", etag)
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Equal(t, etag, resp.Header.Get("ETag"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Empty(t, body)
+	require.Equal(t, 1, store.loads)
+
+	resp = listRules("/prometheus/config/v1/rules", `"other", `+strings.TrimPrefix(etag, "W/"))
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Equal(t, 1, store.loads)
+
+	// The checksums only response is different from the full one.
+	resp = listRules("/prometheus/config/v1/rules?checksums_only=true", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	// The listing has changed.
+	store.modTimes["group1"] = modTime.Add(time.Minute)
+
+	resp = listRules("/prometheus/config/v1/rules", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	// The rule group could be modified again within the granularity of its modification time, so there's no ETag.
+	store.modTimes["group1"] = time.Now()
+	etag = resp.Header.Get("ETag")
+
+	resp = listRules("/prometheus/config/v1/rules", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("ETag"))
+
+	t.Run("rule store not supporting modification times", func(t *testing.T) {
+		a := NewAPI(r, r.store, log.NewNopLogger())
+
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules", nil, userID)
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		a.ListRules(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("ETag"))
+	})
+}
+
+func TestRuler_ListRules_NDJSON(t *testing.T) {
+	const (
+		userID   = "user1"
+		interval = time.Minute
+	)
+
+	const loadBatchSize = 10
+
+	manyRuleGroups := make(rulespb.RuleGroupList, 0, 2*loadBatchSize+1)
+	for i := 0; i < cap(manyRuleGroups); i++ {
+		manyRuleGroups = append(manyRuleGroups, &rulespb.RuleGroupDesc{
+			Name:      fmt.Sprintf("group%d", i),
+			Namespace: "namespace1",
+			User:      userID,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+			Interval:  interval,
+		})
+	}
+
+	testCases := map[string]struct {
+		requestPath     string
+		acceptHeader    string
+		configuredRules rulespb.RuleGroupList
+		missingRules    rulespb.RuleGroupList
+		expectedGroups  []string
+	}{
+		"should stream all rule groups of an user": {
+			requestPath:  "/prometheus/config/v1/rules",
+			acceptHeader: "application/x-ndjson",
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace2",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("COUNT_UP_RULE", "count(up)")},
+					Interval:  interval,
+				},
+			},
+			expectedGroups: []string{"namespace1/group1", "namespace2/group1"},
+		},
+		"should stream rule groups of an user belonging to the input namespace, when ndjson is one of multiple accepted media types": {
+			requestPath:  "/prometheus/config/v1/rules/namespace2",
+			acceptHeader: "application/yaml;q=0.5, application/x-ndjson",
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:  interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace2",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("COUNT_UP_RULE", "count(up)")},
+					Interval:  interval,
+				},
+			},
+			expectedGroups: []string{"namespace2/group1"},
+		},
+		"should skip rule groups missing when loading them": {
+			requestPath:  "/prometheus/config/v1/rules",
+			acceptHeader: "application/x-ndjson",
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:  interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace2",
+					User:      userID,
+				},
+			},
+			missingRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace2",
+					User:      userID,
+				},
+			},
+			expectedGroups: []string{"namespace1/group1"},
+		},
+		"should stream rule groups loaded across multiple batches": {
+			requestPath:     "/prometheus/config/v1/rules",
+			acceptHeader:    "application/x-ndjson",
+			configuredRules: manyRuleGroups,
+			expectedGroups: func() []string {
+				out := make([]string, 0, len(manyRuleGroups))
+				for _, rg := range manyRuleGroups {
+					out = append(out, rg.Namespace+"/"+rg.Name)
+				}
+				return out
+			}(),
+		},
+		"should return an empty body if there are no rule groups": {
+			requestPath:    "/prometheus/config/v1/rules",
+			acceptHeader:   "application/x-ndjson",
+			expectedGroups: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.ListRulesLoadBatchSize = loadBatchSize
+
+			store := newMockRuleStore(map[string]rulespb.RuleGroupList{userID: tc.configuredRules})
+			store.setMissingRuleGroups(tc.missingRules)
+
+			r := prepareRuler(t, cfg, store, withStart())
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			router := mux.NewRouter()
+			router.Path("/prometheus/config/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+			router.Path("/prometheus/config/v1/rules/{namespace}").Methods("GET").HandlerFunc(a.ListRules)
+			req := requestFor(t, http.MethodGet, "https://localhost:8080"+tc.requestPath, nil, userID)
+			req.Header.Set("Accept", tc.acceptHeader)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+			// Build the expected rule groups from the configured ones.
+			expected := map[string]ruleGroupNDJSON{}
+			for _, rg := range tc.configuredRules {
+				expected[rg.Namespace+"/"+rg.Name] = newRuleGroupNDJSON(rg)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			actualGroups := []string{}
+			for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+				if line == "" {
+					continue
+				}
+
+				var actual ruleGroupNDJSON
+				require.NoError(t, json.Unmarshal([]byte(line), &actual))
+
+				key := actual.Namespace + "/" + actual.Name
+				expectedJSON, err := json.Marshal(expected[key])
+				require.NoError(t, err)
+				require.JSONEq(t, string(expectedJSON), line)
+				actualGroups = append(actualGroups, key)
+			}
+
+			require.Equal(t, tc.expectedGroups, actualGroups)
+		})
+	}
+}
+
+func TestRuler_ListRules_ShouldLoadRuleGroupsInBatches(t *testing.T) {
+	const (
+		userID        = "user1"
+		loadBatchSize = 3
+		numGroups     = 2*loadBatchSize + 1
+	)
+
+	ruleGroups := make(rulespb.RuleGroupList, 0, numGroups)
+	for i := 0; i < numGroups; i++ {
+		ruleGroups = append(ruleGroups, &rulespb.RuleGroupDesc{
+			Name:      fmt.Sprintf("group%d", i),
+			Namespace: "namespace1",
+			User:      userID,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+			Interval:  time.Minute,
+		})
+	}
+
+	for _, acceptHeader := range []string{"", "application/x-ndjson"} {
+		t.Run(fmt.Sprintf("accept=%q", acceptHeader), func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.ListRulesLoadBatchSize = loadBatchSize
+
+			r := prepareRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{userID: ruleGroups}), withStart())
+			store := &batchRecordingRuleStore{RuleStore: r.store}
+			a := NewAPI(r, store, log.NewNopLogger())
+
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules", nil, userID)
+			req.Header.Set("Accept", acceptHeader)
+
+			w := httptest.NewRecorder()
+			a.ListRules(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			for _, name := range []string{"group0", fmt.Sprintf("group%d", numGroups-1)} {
+				require.Contains(t, w.Body.String(), name)
+			}
+			require.Equal(t, []int{loadBatchSize, loadBatchSize, 1}, store.batchSizes)
+		})
+	}
+}
+
+func TestRuler_ListRules_ModifiedSince(t *testing.T) {
+	const userID = "user1"
+
+	newGroup := func(name string) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{
+			Name:      name,
+			Namespace: "namespace1",
+			User:      userID,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+			Interval:  time.Minute,
+		}
+	}
+
+	since := time.UnixMilli(1_700_000_000_000)
+
+	for name, tc := range map[string]struct {
+		modifiedErr     error
+		withLister      bool
+		expectedGroups  []string
+		expectedWarning bool
+	}{
+		"rule store supporting modification times": {
+			withLister:     true,
+			expectedGroups: []string{"group2", "group3"},
+		},
+		"rule store not supporting modification times": {
+			expectedGroups:  []string{"group1", "group2", "group3"},
+			expectedWarning: true,
+		},
+		"rule store failing to provide modification times": {
+			withLister:      true,
+			modifiedErr:     rulestore.ErrModTimeNotSupported,
+			expectedGroups:  []string{"group1", "group2", "group3"},
+			expectedWarning: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			r := prepareRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{userID: {newGroup("group1"), newGroup("group2"), newGroup("group3")}}), withStart())
+
+			var store rulestore.RuleStore = r.store
+			if tc.withLister {
+				store = &modTimeRuleStore{
+					RuleStore: r.store,
+					// The group3 has been modified within the same second as the modified_since time, but its
+					// modification time has been truncated to the second.
+					modTimes: map[string]time.Time{"group1": since.Add(-time.Minute), "group2": since.Add(time.Minute), "group3": since},
+					err:      tc.modifiedErr,
+				}
+			}
+			a := NewAPI(r, store, log.NewNopLogger())
+
+			req := requestFor(t, http.MethodGet, fmt.Sprintf("https://localhost:8080/prometheus/config/v1/rules?modified_since=%d", since.Unix()), nil, userID)
+			w := httptest.NewRecorder()
+			a.ListRules(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var groups map[string][]rulefmt.RuleGroup
+			require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &groups))
+			actualGroups := []string{}
+			for _, g := range groups["namespace1"] {
+				actualGroups = append(actualGroups, g.Name)
+			}
+			require.ElementsMatch(t, tc.expectedGroups, actualGroups)
+
+			if tc.expectedWarning {
+				require.Equal(t, modifiedSinceNotSupportedWarning, w.Header().Get("Warning"))
+			} else {
+				require.Empty(t, w.Header().Get("Warning"))
+			}
+		})
+	}
+
+	t.Run("invalid modified_since", func(t *testing.T) {
+		cfg := defaultRulerConfig(t)
+		r := prepareRuler(t, cfg, newMockRuleStore(nil), withStart())
+		a := NewAPI(r, r.store, log.NewNopLogger())
+
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules?modified_since=yesterday", nil, userID)
+		w := httptest.NewRecorder()
+		a.ListRules(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRuler_ListRules_AllowPartial(t *testing.T) {
+	const userID = "user1"
+
+	newGroup := func(name string) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{
+			Name:      name,
+			Namespace: "namespace1",
+			User:      userID,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+			Interval:  time.Minute,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		query           string
+		acceptHeader    string
+		expectedStatus  int
+		expectedGroups  []string
+		expectedWarning string
+	}{
+		"should fail if a rule group fails to load and partial responses are not allowed": {
+			expectedStatus: http.StatusBadRequest,
+		},
+		"should fail if a rule group fails to load and partial responses are disabled": {
+			query:          "?allow_partial=false",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"should return the rule groups loaded if a rule group fails to load and partial responses are allowed": {
+			query:           "?allow_partial=true",
+			expectedStatus:  http.StatusOK,
+			expectedGroups:  []string{"group1", "group3"},
+			expectedWarning: `299 - "1 rule groups failed to load and have been omitted: namespace1/group2"`,
+		},
+		"should stream the rule groups loaded if a rule group fails to load and partial responses are allowed": {
+			query:          "?allow_partial=true",
+			acceptHeader:   "application/x-ndjson",
+			expectedStatus: http.StatusOK,
+			expectedGroups: []string{"group1", "group3"},
+		},
+		"should fail on invalid allow_partial": {
+			query:          "?allow_partial=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			r := prepareRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{userID: {newGroup("group1"), newGroup("group2"), newGroup("group3")}}), withStart())
+			store := &failingRuleGroupsRuleStore{RuleStore: r.store, failing: map[string]struct{}{"group2": {}}}
+			a := NewAPI(r, store, log.NewNopLogger())
+
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules"+tc.query, nil, userID)
+			req.Header.Set("Accept", tc.acceptHeader)
+			w := httptest.NewRecorder()
+			a.ListRules(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedWarning, w.Header().Get("Warning"))
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			actualGroups := []string{}
+			if tc.acceptHeader == "application/x-ndjson" {
+				for _, line := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n") {
+					var actual ruleGroupNDJSON
+					require.NoError(t, json.Unmarshal([]byte(line), &actual))
+					actualGroups = append(actualGroups, actual.Name)
+				}
+			} else {
+				var groups map[string][]rulefmt.RuleGroup
+				require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &groups))
+				for _, g := range groups["namespace1"] {
+					actualGroups = append(actualGroups, g.Name)
+				}
+			}
+			require.ElementsMatch(t, tc.expectedGroups, actualGroups)
+		})
+	}
+}
+
+func TestRuleGroupsFailedToLoadWarning(t *testing.T) {
+	failed := make(rulespb.RuleGroupList, 0, maxFailedRuleGroupsInWarning+2)
+	for i := 0; i < cap(failed); i++ {
+		failed = append(failed, &rulespb.RuleGroupDesc{Namespace: "ns", Name: fmt.Sprintf("group%d", i)})
+	}
+
+	assert.Equal(t, `299 - "1 rule groups failed to load and have been omitted: ns/group0"`, ruleGroupsFailedToLoadWarning(failed[:1]))
+	assert.Equal(t, `299 - "12 rule groups failed to load and have been omitted: ns/group0, ns/group1, ns/group2, ns/group3, ns/group4, ns/group5, ns/group6, ns/group7, ns/group8, ns/group9, and 2 more"`, ruleGroupsFailedToLoadWarning(failed))
+}
+
+// failingRuleGroupsRuleStore wraps a rulestore.RuleStore and fails to load any batch of rule groups containing
+// one of the failing rule group names.
+type failingRuleGroupsRuleStore struct {
+	rulestore.RuleStore
+	failing map[string]struct{}
+}
+
+func (s *failingRuleGroupsRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (rulespb.RuleGroupList, error) {
+	for _, rgs := range groupsToLoad {
+		for _, rg := range rgs {
+			if _, ok := s.failing[rg.GetName()]; ok {
+				return nil, fmt.Errorf("failed to load rule group %s", rg.GetName())
+			}
+		}
+	}
+	return s.RuleStore.LoadRuleGroups(ctx, groupsToLoad)
+}
+
+// modTimeRuleStore wraps a rulestore.RuleStore and implements rulestore.ModifiedRuleGroupsLister, returning the
+// configured modification times (keyed by rule group name) or error. It also counts the calls to LoadRuleGroups.
+type modTimeRuleStore struct {
+	rulestore.RuleStore
+	modTimes map[string]time.Time
+	err      error
+	loads    int
+}
+
+func (s *modTimeRuleStore) ListRuleGroupsWithModTime(ctx context.Context, userID string, namespace string, opts ...rulestore.Option) (rulespb.RuleGroupList, []time.Time, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	rgs, err := s.RuleStore.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modTimes := make([]time.Time, 0, len(rgs))
+	for _, rg := range rgs {
+		modTimes = append(modTimes, s.modTimes[rg.GetName()])
+	}
+	return rgs, modTimes, nil
+}
+
+func (s *modTimeRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (rulespb.RuleGroupList, error) {
+	s.loads++
+	return s.RuleStore.LoadRuleGroups(ctx, groupsToLoad)
+}
+
+// batchRecordingRuleStore wraps a rulestore.RuleStore and records the number of rule groups loaded by each
+// call to LoadRuleGroups.
+type batchRecordingRuleStore struct {
+	rulestore.RuleStore
+	batchSizes []int
+}
+
+func (s *batchRecordingRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (rulespb.RuleGroupList, error) {
+	size := 0
+	for _, rgs := range groupsToLoad {
+		size += len(rgs)
+	}
+	s.batchSizes = append(s.batchSizes, size)
+	return s.RuleStore.LoadRuleGroups(ctx, groupsToLoad)
+}
+
 func TestRuler_PrometheusRules(t *testing.T) {
 	const (
 		userID   = "user1"
@@ -432,6 +1166,16 @@ func TestRuler_PrometheusRules(t *testing.T) {
 		}
 	}
 
+	configHashTestGroup := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace1",
+		User:      userID,
+		Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+		Interval:  interval,
+	}
+	configHashTestGroupChecksum, err := rulespb.Checksum(configHashTestGroup)
+	require.NoError(t, err)
+
 	testCases := map[string]struct {
 		configuredRules    rulespb.RuleGroupList
 		limits             RulesLimits
@@ -478,40 +1222,313 @@ func TestRuler_PrometheusRules(t *testing.T) {
 				},
 			},
 		},
-		"should load and evaluate only recording rules if alerting rules evaluation is disabled for the tenant": {
+		"should include the config hash of each rule group if requested": {
+			configuredRules:    rulespb.RuleGroupList{configHashTestGroup},
+			queryParams:        "?include_config_hash=true",
+			limits:             validation.MockDefaultOverrides(),
+			expectedConfigured: 1,
+			expectedRules: []*RuleGroup{
+				{
+					Name: "group1",
+					File: "namespace1",
+					Rules: []rule{
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
+						},
+					},
+					Interval:   60,
+					ConfigHash: configHashTestGroupChecksum,
+				},
+			},
+		},
+		"should load and evaluate only recording rules if alerting rules evaluation is disabled for the tenant": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 1,
+			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
+				tenantLimits[userID] = validation.MockDefaultLimits()
+				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = true
+				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = false
+			}),
+			expectedRules: []*RuleGroup{
+				{
+					Name: "group1",
+					File: "namespace1",
+					Rules: []rule{
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
+						},
+					},
+					Interval: 60,
+				},
+			},
+			expectedWarnings: []string{errAlertingRulesEvaluationDisabled},
+		},
+		"should load and evaluate only alerting rules if recording rules evaluation is disabled for the tenant": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 1,
+			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
+				tenantLimits[userID] = validation.MockDefaultLimits()
+				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = false
+				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = true
+			}),
+			expectedRules: []*RuleGroup{
+				{
+					Name: "group1",
+					File: "namespace1",
+					Rules: []rule{
+						&alertingRule{
+							Name:   "UP_ALERT",
+							Query:  "up < 1",
+							State:  "inactive",
+							Health: "unknown",
+							Type:   "alerting",
+							Alerts: []*Alert{},
+						},
+					},
+					Interval: 60,
+				},
+			},
+			expectedWarnings: []string{errRulesEvaluationDisabled},
+		},
+		"should load and evaluate no rules if rules evaluation is disabled for the tenant": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 0,
+			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
+				tenantLimits[userID] = validation.MockDefaultLimits()
+				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = false
+				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = false
+			}),
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedErrorType:  v1.ErrExec,
+			expectedRules:      []*RuleGroup{},
+		},
+		"should load and evaluate the configured rules with special characters": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:      ")(_+?/|group1+/?",
+					Namespace: ")(_+?/|namespace1+/?",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 1,
+			limits:             validation.MockDefaultOverrides(),
+			expectedRules: []*RuleGroup{
+				{
+					Name: ")(_+?/|group1+/?",
+					File: ")(_+?/|namespace1+/?",
+					Rules: []rule{
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
+						},
+						&alertingRule{
+							Name:   "UP_ALERT",
+							Query:  "up < 1",
+							State:  "inactive",
+							Health: "unknown",
+							Type:   "alerting",
+							Alerts: []*Alert{},
+						},
+					},
+					Interval: 60,
+				},
+			},
+		},
+		"should support federated rules": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:          "group1",
+					Namespace:     "namespace1",
+					User:          userID,
+					SourceTenants: []string{"tenant-1"},
+					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:      interval,
+				},
+			},
+			expectedConfigured: 1,
+			limits:             validation.MockDefaultOverrides(),
+			expectedRules: []*RuleGroup{
+				{
+					Name:          "group1",
+					File:          "namespace1",
+					SourceTenants: []string{"tenant-1"},
+					Rules: []rule{
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
+						},
+						&alertingRule{
+							Name:   "UP_ALERT",
+							Query:  "up < 1",
+							State:  "inactive",
+							Health: "unknown",
+							Type:   "alerting",
+							Alerts: []*Alert{},
+						},
+					},
+					Interval: 60,
+				},
+			},
+		},
+		"should filter federated rules by source tenant": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:          "group1",
+					Namespace:     "namespace1",
+					User:          userID,
+					SourceTenants: []string{"tenant-1"},
+					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:      interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:          "group2",
+					Namespace:     "namespace1",
+					User:          userID,
+					SourceTenants: []string{"tenant-2", "tenant-3"},
+					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:      interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:      "group3",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 3,
+			limits:             validation.MockDefaultOverrides(),
+			queryParams:        "?source_tenant=tenant-2",
+			expectedRules: []*RuleGroup{
+				{
+					Name:          "group2",
+					File:          "namespace1",
+					SourceTenants: []string{"tenant-2", "tenant-3"},
+					Rules:         []rule{filterTestExpectedRule("UP_RULE")},
+					Interval:      60,
+				},
+			},
+		},
+		"should filter federated rules by any of the source tenants": {
+			configuredRules: rulespb.RuleGroupList{
+				&rulespb.RuleGroupDesc{
+					Name:          "group1",
+					Namespace:     "namespace1",
+					User:          userID,
+					SourceTenants: []string{"tenant-1"},
+					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:      interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:          "group2",
+					Namespace:     "namespace1",
+					User:          userID,
+					SourceTenants: []string{"tenant-2", "tenant-3"},
+					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:      interval,
+				},
+				&rulespb.RuleGroupDesc{
+					Name:      "group3",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
+					Interval:  interval,
+				},
+			},
+			expectedConfigured: 3,
+			limits:             validation.MockDefaultOverrides(),
+			queryParams:        "?source_tenant[]=tenant-1&source_tenant[]=tenant-3",
+			expectedRules: []*RuleGroup{
+				{
+					Name:          "group1",
+					File:          "namespace1",
+					SourceTenants: []string{"tenant-1"},
+					Rules:         []rule{filterTestExpectedRule("UP_RULE")},
+					Interval:      60,
+				},
+				{
+					Name:          "group2",
+					File:          "namespace1",
+					SourceTenants: []string{"tenant-2", "tenant-3"},
+					Rules:         []rule{filterTestExpectedRule("UP_RULE")},
+					Interval:      60,
+				},
+			},
+		},
+		"should load alerting rules with keep_firing_for": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
-					Interval:  interval,
+					Rules: []*rulespb.RuleDesc{{
+						Alert:         "UP_ALERT_WITH_KEEP_FIRING_FOR",
+						Expr:          "up < 1",
+						For:           time.Minute,
+						KeepFiringFor: 2 * time.Minute,
+					}},
+					Interval: interval,
 				},
 			},
 			expectedConfigured: 1,
-			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
-				tenantLimits[userID] = validation.MockDefaultLimits()
-				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = true
-				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = false
-			}),
+			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
 					File: "namespace1",
 					Rules: []rule{
-						&recordingRule{
-							Name:   "UP_RULE",
-							Query:  "up",
-							Health: "unknown",
-							Type:   "recording",
+						&alertingRule{
+							Name:          "UP_ALERT_WITH_KEEP_FIRING_FOR",
+							Query:         "up < 1",
+							State:         "inactive",
+							Health:        "unknown",
+							Type:          "alerting",
+							Duration:      time.Minute.Seconds(),
+							KeepFiringFor: (2 * time.Minute).Seconds(),
+							Alerts:        []*Alert{},
 						},
 					},
 					Interval: 60,
 				},
 			},
-			expectedWarnings: []string{errAlertingRulesEvaluationDisabled},
 		},
-		"should load and evaluate only alerting rules if recording rules evaluation is disabled for the tenant": {
+		"API returns only alerts": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
@@ -522,11 +1539,8 @@ func TestRuler_PrometheusRules(t *testing.T) {
 				},
 			},
 			expectedConfigured: 1,
-			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
-				tenantLimits[userID] = validation.MockDefaultLimits()
-				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = false
-				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = true
-			}),
+			queryParams:        "?type=alert",
+			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
@@ -544,9 +1558,8 @@ func TestRuler_PrometheusRules(t *testing.T) {
 					Interval: 60,
 				},
 			},
-			expectedWarnings: []string{errRulesEvaluationDisabled},
 		},
-		"should load and evaluate no rules if rules evaluation is disabled for the tenant": {
+		"API returns only rules": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
@@ -556,32 +1569,13 @@ func TestRuler_PrometheusRules(t *testing.T) {
 					Interval:  interval,
 				},
 			},
-			expectedConfigured: 0,
-			limits: validation.MockOverrides(func(_ *validation.Limits, tenantLimits map[string]*validation.Limits) {
-				tenantLimits[userID] = validation.MockDefaultLimits()
-				tenantLimits[userID].RulerRecordingRulesEvaluationEnabled = false
-				tenantLimits[userID].RulerAlertingRulesEvaluationEnabled = false
-			}),
-			expectedStatusCode: http.StatusUnprocessableEntity,
-			expectedErrorType:  v1.ErrExec,
-			expectedRules:      []*RuleGroup{},
-		},
-		"should load and evaluate the configured rules with special characters": {
-			configuredRules: rulespb.RuleGroupList{
-				&rulespb.RuleGroupDesc{
-					Name:      ")(_+?/|group1+/?",
-					Namespace: ")(_+?/|namespace1+/?",
-					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
-					Interval:  interval,
-				},
-			},
 			expectedConfigured: 1,
+			queryParams:        "?type=record",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
-					Name: ")(_+?/|group1+/?",
-					File: ")(_+?/|namespace1+/?",
+					Name: "group1",
+					File: "namespace1",
 					Rules: []rule{
 						&recordingRule{
 							Name:   "UP_RULE",
@@ -589,44 +1583,29 @@ func TestRuler_PrometheusRules(t *testing.T) {
 							Health: "unknown",
 							Type:   "recording",
 						},
-						&alertingRule{
-							Name:   "UP_ALERT",
-							Query:  "up < 1",
-							State:  "inactive",
-							Health: "unknown",
-							Type:   "alerting",
-							Alerts: []*Alert{},
-						},
 					},
 					Interval: 60,
 				},
 			},
 		},
-		"should support federated rules": {
+		"API request with exclude_recording=true returns only alerting rules": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
-					Name:          "group1",
-					Namespace:     "namespace1",
-					User:          userID,
-					SourceTenants: []string{"tenant-1"},
-					Rules:         []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
-					Interval:      interval,
+					Name:      "group1",
+					Namespace: "namespace1",
+					User:      userID,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
+			queryParams:        "?exclude_recording=true",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
-					Name:          "group1",
-					File:          "namespace1",
-					SourceTenants: []string{"tenant-1"},
+					Name: "group1",
+					File: "namespace1",
 					Rules: []rule{
-						&recordingRule{
-							Name:   "UP_RULE",
-							Query:  "up",
-							Health: "unknown",
-							Type:   "recording",
-						},
 						&alertingRule{
 							Name:   "UP_ALERT",
 							Query:  "up < 1",
@@ -640,55 +1619,74 @@ func TestRuler_PrometheusRules(t *testing.T) {
 				},
 			},
 		},
-		"should load alerting rules with keep_firing_for": {
+		"API request with exclude_alerting=true returns only recording rules": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules: []*rulespb.RuleDesc{{
-						Alert:         "UP_ALERT_WITH_KEEP_FIRING_FOR",
-						Expr:          "up < 1",
-						For:           time.Minute,
-						KeepFiringFor: 2 * time.Minute,
-					}},
-					Interval: interval,
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
+			queryParams:        "?exclude_alerting=true&type=record",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
 					File: "namespace1",
 					Rules: []rule{
-						&alertingRule{
-							Name:          "UP_ALERT_WITH_KEEP_FIRING_FOR",
-							Query:         "up < 1",
-							State:         "inactive",
-							Health:        "unknown",
-							Type:          "alerting",
-							Duration:      time.Minute.Seconds(),
-							KeepFiringFor: (2 * time.Minute).Seconds(),
-							Alerts:        []*Alert{},
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
 						},
 					},
 					Interval: 60,
 				},
 			},
 		},
-		"API returns only alerts": {
+		"Contradictory type and exclude_alerting params": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?type=alert&exclude_alerting=true",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"Both exclude_recording and exclude_alerting params": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?exclude_recording=true&exclude_alerting=true",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"Invalid exclude_recording param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?exclude_recording=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"API request with exclude_alerts=true returns alerting rules without alerts": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Rules:     []*rulespb.RuleDesc{createAlertingRule("UP_ALERT", "up < 1")},
 					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
-			queryParams:        "?type=alert",
+			queryParams:        "?exclude_alerts=true",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
@@ -701,60 +1699,68 @@ func TestRuler_PrometheusRules(t *testing.T) {
 							State:  "inactive",
 							Health: "unknown",
 							Type:   "alerting",
-							Alerts: []*Alert{},
+							Alerts: nil,
 						},
 					},
 					Interval: 60,
 				},
 			},
 		},
-		"API returns only rules": {
+		"API request with exclude_alerts=false returns alerting rules including alerts": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
+					Rules:     []*rulespb.RuleDesc{createAlertingRule("UP_ALERT", "up < 1")},
 					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
-			queryParams:        "?type=record",
+			queryParams:        "?exclude_alerts=false",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
 					File: "namespace1",
 					Rules: []rule{
-						&recordingRule{
-							Name:   "UP_RULE",
-							Query:  "up",
+						&alertingRule{
+							Name:   "UP_ALERT",
+							Query:  "up < 1",
+							State:  "inactive",
 							Health: "unknown",
-							Type:   "recording",
+							Type:   "alerting",
+							Alerts: []*Alert{},
 						},
 					},
 					Interval: 60,
 				},
 			},
 		},
-		"API request with exclude_alerts=true returns alerting rules without alerts": {
+		"API request with include_counts=true and exclude_alerts=true returns the number of active alerts per group": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createAlertingRule("UP_ALERT", "up < 1")},
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up"), createAlertingRule("UP_ALERT", "up < 1")},
 					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
-			queryParams:        "?exclude_alerts=true",
+			queryParams:        "?include_counts=true&exclude_alerts=true",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
 					File: "namespace1",
 					Rules: []rule{
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
+							Health: "unknown",
+							Type:   "recording",
+						},
 						&alertingRule{
 							Name:   "UP_ALERT",
 							Query:  "up < 1",
@@ -764,41 +1770,85 @@ func TestRuler_PrometheusRules(t *testing.T) {
 							Alerts: nil,
 						},
 					},
-					Interval: 60,
+					Interval:          60,
+					ActiveAlertsCount: pointerOf(0),
 				},
 			},
 		},
-		"API request with exclude_alerts=false returns alerting rules including alerts": {
+		"Invalid include_counts param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?include_counts=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"API request with include_latency=true for groups not evaluated yet returns no evaluation latency": {
 			configuredRules: rulespb.RuleGroupList{
 				&rulespb.RuleGroupDesc{
 					Name:      "group1",
 					Namespace: "namespace1",
 					User:      userID,
-					Rules:     []*rulespb.RuleDesc{createAlertingRule("UP_ALERT", "up < 1")},
+					Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", "up")},
 					Interval:  interval,
 				},
 			},
 			expectedConfigured: 1,
-			queryParams:        "?exclude_alerts=false",
+			queryParams:        "?include_latency=true",
 			limits:             validation.MockDefaultOverrides(),
 			expectedRules: []*RuleGroup{
 				{
 					Name: "group1",
 					File: "namespace1",
 					Rules: []rule{
-						&alertingRule{
-							Name:   "UP_ALERT",
-							Query:  "up < 1",
-							State:  "inactive",
+						&recordingRule{
+							Name:   "UP_RULE",
+							Query:  "up",
 							Health: "unknown",
-							Type:   "alerting",
-							Alerts: []*Alert{},
+							Type:   "recording",
 						},
 					},
 					Interval: 60,
 				},
 			},
 		},
+		"Invalid include_latency param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?include_latency=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"Invalid include_severity_counts param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?include_severity_counts=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"Invalid include_config_hash param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?include_config_hash=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
+		"Invalid include_dependencies param": {
+			configuredRules:    rulespb.RuleGroupList{},
+			expectedConfigured: 0,
+			queryParams:        "?include_dependencies=foo",
+			limits:             validation.MockDefaultOverrides(),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorType:  v1.ErrBadData,
+			expectedRules:      []*RuleGroup{},
+		},
 		"Invalid exclude_alerts param": {
 			configuredRules:    rulespb.RuleGroupList{},
 			expectedConfigured: 0,
@@ -1240,6 +2290,10 @@ func TestRuler_PrometheusRules(t *testing.T) {
 	}
 }
 
+func pointerOf[T any](value T) *T {
+	return &value
+}
+
 func TestRuler_PrometheusAlerts(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -2010,6 +3064,95 @@ func TestAlertStateDescToPrometheusAlert(t *testing.T) {
 	})
 }
 
+func TestCountAlertsBySeverity(t *testing.T) {
+	alert := func(state string, lbls ...string) *AlertStateDesc {
+		a := &AlertStateDesc{State: state}
+		for i := 0; i < len(lbls); i += 2 {
+			a.Labels = append(a.Labels, mimirpb.LabelAdapter{Name: lbls[i], Value: lbls[i+1]})
+		}
+		return a
+	}
+	alerts := []*AlertStateDesc{
+		alert("firing", "alertname", "a", "severity", "critical", "level", "1"),
+		alert("firing", "alertname", "b", "severity", "critical", "level", "2"),
+		alert("pending", "alertname", "c", "severity", "warning", "level", "2"),
+		alert("pending", "alertname", "d"),
+		alert("inactive", "alertname", "e", "severity", "info"),
+	}
+
+	t.Run("by severity", func(t *testing.T) {
+		counts := map[string]*alertStateCounts{}
+		countAlertsBySeverity(counts, alerts, defaultSeverityLabel)
+		// The counts of the alerts of multiple rules add up.
+		countAlertsBySeverity(counts, alerts[:1], defaultSeverityLabel)
+
+		assert.Equal(t, map[string]*alertStateCounts{
+			"critical": {Firing: 3},
+			"warning":  {Pending: 1},
+			"":         {Pending: 1},
+		}, counts)
+	})
+
+	t.Run("by custom label", func(t *testing.T) {
+		counts := map[string]*alertStateCounts{}
+		countAlertsBySeverity(counts, alerts, "level")
+
+		assert.Equal(t, map[string]*alertStateCounts{
+			"1": {Firing: 1},
+			"2": {Firing: 1, Pending: 1},
+			"":  {Pending: 1},
+		}, counts)
+	})
+}
+
+func TestRuleGroupDependencies(t *testing.T) {
+	recording := func(record, expr string) *RuleStateDesc {
+		return &RuleStateDesc{Rule: &rulespb.RuleDesc{Record: record, Expr: expr}}
+	}
+	alerting := func(alert, expr string) *RuleStateDesc {
+		return &RuleStateDesc{Rule: &rulespb.RuleDesc{Alert: alert, Expr: expr}}
+	}
+
+	t.Run("dependencies between rules", func(t *testing.T) {
+		rules := []*RuleStateDesc{
+			recording("job:requests:rate5m", `sum by (job) (rate(requests_total[5m]))`),
+			recording("job:errors:rate5m", `sum by (job) (rate(errors_total[5m]))`),
+			recording("job:error_ratio:rate5m", `job:errors:rate5m / job:requests:rate5m`),
+			alerting("HighErrorRatio", `job:error_ratio:rate5m > 0.1 and on (job) {__name__="job:requests:rate5m"} > 1`),
+			alerting("AnyRequests", `{__name__=~"job:requests:.*"} > 0`),
+			recording("job:requests:rate5m:self", `job:requests:rate5m:self offset 5m`),
+			alerting("Invalid", `job:requests:rate5m >`),
+		}
+
+		assert.Equal(t, map[int][]string{
+			2: {"job:errors:rate5m", "job:requests:rate5m"},
+			3: {"job:error_ratio:rate5m", "job:requests:rate5m"},
+		}, ruleGroupDependencies(rules))
+	})
+
+	t.Run("rules with the same name", func(t *testing.T) {
+		rules := []*RuleStateDesc{
+			recording("job:requests:rate5m", `sum by (job) (rate(requests_total[5m]))`),
+			recording("job:errors:rate5m", `sum by (job) (rate(errors_total[5m]))`),
+			alerting("HighRate", `job:requests:rate5m > 100`),
+			alerting("HighRate", `job:errors:rate5m > 10`),
+		}
+
+		assert.Equal(t, map[int][]string{
+			2: {"job:requests:rate5m"},
+			3: {"job:errors:rate5m"},
+		}, ruleGroupDependencies(rules))
+	})
+
+	t.Run("no recording rules", func(t *testing.T) {
+		assert.Nil(t, ruleGroupDependencies([]*RuleStateDesc{alerting("Up", `up == 0`)}))
+	})
+
+	t.Run("no dependencies", func(t *testing.T) {
+		assert.Nil(t, ruleGroupDependencies([]*RuleStateDesc{recording("job:up:sum", `sum by (job) (up)`), alerting("Up", `up == 0`)}))
+	})
+}
+
 func TestAPIRoutesCorrectlyHandleInvalidTenantID(t *testing.T) {
 	tcs := []struct {
 		route  string
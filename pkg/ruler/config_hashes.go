@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// ruleGroupConfigHashes tracks the checksum of the configuration of the rule groups loaded by the ruler, by tenant,
+// so that the rules API can return it without loading the rule groups from the store again.
+type ruleGroupConfigHashes struct {
+	mtx sync.RWMutex
+	// Checksums by tenant, namespace and rule group name.
+	users map[string]map[string]map[string]string
+}
+
+func newRuleGroupConfigHashes() *ruleGroupConfigHashes {
+	return &ruleGroupConfigHashes{
+		users: map[string]map[string]map[string]string{},
+	}
+}
+
+// update sets the checksums of the rule groups loaded for the input tenants. If the input tenants are empty, the
+// configs hold the rule groups of all the tenants, and the checksums of the tenants not in the configs are removed.
+// The checksums of the tenants whose rule groups can't be hashed are removed, so the rules API omits them.
+func (h *ruleGroupConfigHashes) update(configs map[string]rulespb.RuleGroupList, userIDs []string, logger log.Logger) {
+	users := make(map[string]map[string]map[string]string, len(configs))
+	for userID, groups := range configs {
+		checksums, err := groups.Checksums()
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to compute the checksums of the rule groups", "user", userID, "err", err)
+			continue
+		}
+		users[userID] = checksums
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if len(userIDs) == 0 {
+		h.users = users
+		return
+	}
+	for _, userID := range userIDs {
+		if checksums, ok := users[userID]; ok {
+			h.users[userID] = checksums
+		} else {
+			delete(h.users, userID)
+		}
+	}
+}
+
+// get returns the checksum of the configuration of the tenant's rule group, or an empty string if it's unknown.
+func (h *ruleGroupConfigHashes) get(userID, namespace, group string) string {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	return h.users[userID][namespace][group]
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuleGroupConfigHashes(t *testing.T) {
+	newGroup := func(user, namespace, name string) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{
+			Name:      name,
+			Namespace: namespace,
+			User:      user,
+			Interval:  time.Minute,
+			Rules:     []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}},
+		}
+	}
+	checksum := func(g *rulespb.RuleGroupDesc) string {
+		c, err := rulespb.Checksum(g)
+		require.NoError(t, err)
+		return c
+	}
+
+	user1Group := newGroup("user-1", "namespace", "group-1")
+	user2Group := newGroup("user-2", "namespace", "group-2")
+
+	hashes := newRuleGroupConfigHashes()
+	hashes.update(map[string]rulespb.RuleGroupList{
+		"user-1": {user1Group},
+		"user-2": {user2Group},
+	}, nil, log.NewNopLogger())
+
+	assert.Equal(t, checksum(user1Group), hashes.get("user-1", "namespace", "group-1"))
+	assert.Equal(t, checksum(user2Group), hashes.get("user-2", "namespace", "group-2"))
+	assert.Empty(t, hashes.get("user-1", "namespace", "group-2"))
+	assert.Empty(t, hashes.get("user-3", "namespace", "group-1"))
+
+	t.Run("partial sync only updates the input tenants", func(t *testing.T) {
+		updatedUser1Group := newGroup("user-1", "namespace", "group-1")
+		updatedUser1Group.Interval = 2 * time.Minute
+
+		hashes.update(map[string]rulespb.RuleGroupList{"user-1": {updatedUser1Group}}, []string{"user-1", "user-3"}, log.NewNopLogger())
+
+		assert.Equal(t, checksum(updatedUser1Group), hashes.get("user-1", "namespace", "group-1"))
+		assert.NotEqual(t, checksum(user1Group), hashes.get("user-1", "namespace", "group-1"))
+		assert.Equal(t, checksum(user2Group), hashes.get("user-2", "namespace", "group-2"))
+	})
+
+	t.Run("partial sync removes the input tenants without rule groups", func(t *testing.T) {
+		hashes.update(nil, []string{"user-1"}, log.NewNopLogger())
+
+		assert.Empty(t, hashes.get("user-1", "namespace", "group-1"))
+		assert.Equal(t, checksum(user2Group), hashes.get("user-2", "namespace", "group-2"))
+	})
+
+	t.Run("full sync removes the tenants not in the configs", func(t *testing.T) {
+		hashes.update(map[string]rulespb.RuleGroupList{"user-1": {user1Group}}, nil, log.NewNopLogger())
+
+		assert.Equal(t, checksum(user1Group), hashes.get("user-1", "namespace", "group-1"))
+		assert.Empty(t, hashes.get("user-2", "namespace", "group-2"))
+	})
+}
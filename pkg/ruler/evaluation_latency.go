@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	promRules "github.com/prometheus/prometheus/rules"
+)
+
+// groupEvaluationLatencyHistorySize is the number of recent evaluations of each rule group
+// used to compute the evaluation latency percentiles.
+const groupEvaluationLatencyHistorySize = 100
+
+// groupEvaluationLatencies tracks the duration of the recent evaluations of the rule groups, by tenant.
+type groupEvaluationLatencies struct {
+	mtx sync.Mutex
+	// Recent evaluation durations by tenant and group key.
+	users map[string]map[string]*evaluationLatencyHistory
+}
+
+func newGroupEvaluationLatencies() *groupEvaluationLatencies {
+	return &groupEvaluationLatencies{
+		users: map[string]map[string]*evaluationLatencyHistory{},
+	}
+}
+
+// evalIterationFunc returns a rules.GroupEvalIterationFunc evaluating the rule groups of the tenant like
+// the default one does, and tracking the duration of each evaluation.
+func (l *groupEvaluationLatencies) evalIterationFunc(userID string) promRules.GroupEvalIterationFunc {
+	return func(ctx context.Context, g *promRules.Group, evalTimestamp time.Time) {
+		promRules.DefaultEvalIterationFunc(ctx, g, evalTimestamp)
+		l.observe(userID, promRules.GroupKey(g.File(), g.Name()), g.GetEvaluationTime())
+	}
+}
+
+func (l *groupEvaluationLatencies) observe(userID, groupKey string, d time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	groups := l.users[userID]
+	if groups == nil {
+		groups = map[string]*evaluationLatencyHistory{}
+		l.users[userID] = groups
+	}
+
+	history := groups[groupKey]
+	if history == nil {
+		history = &evaluationLatencyHistory{}
+		groups[groupKey] = history
+	}
+	history.add(d)
+}
+
+// percentiles returns the 50th and 99th percentiles of the duration of the recent evaluations of the group.
+// Both are zero if no evaluation of the group has been tracked.
+func (l *groupEvaluationLatencies) percentiles(userID, groupKey string) (p50, p99 time.Duration) {
+	l.mtx.Lock()
+	history := l.users[userID][groupKey]
+	if history == nil {
+		l.mtx.Unlock()
+		return 0, 0
+	}
+	durations := slices.Clone(history.durations)
+	l.mtx.Unlock()
+
+	slices.Sort(durations)
+	return durationsPercentile(durations, 0.5), durationsPercentile(durations, 0.99)
+}
+
+// retainGroups removes the evaluation history of the tenant's rule groups not in the input groups.
+func (l *groupEvaluationLatencies) retainGroups(userID string, groups []*promRules.Group) {
+	keys := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		keys[promRules.GroupKey(g.File(), g.Name())] = struct{}{}
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for key := range l.users[userID] {
+		if _, ok := keys[key]; !ok {
+			delete(l.users[userID], key)
+		}
+	}
+}
+
+// removeUser removes the evaluation history of all the rule groups of the tenant.
+func (l *groupEvaluationLatencies) removeUser(userID string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	delete(l.users, userID)
+}
+
+// evaluationLatencyHistory is a ring buffer of the most recent evaluation durations of a rule group.
+type evaluationLatencyHistory struct {
+	durations []time.Duration
+	next      int
+}
+
+func (h *evaluationLatencyHistory) add(d time.Duration) {
+	if len(h.durations) < groupEvaluationLatencyHistorySize {
+		h.durations = append(h.durations, d)
+		return
+	}
+
+	h.durations[h.next] = d
+	h.next = (h.next + 1) % groupEvaluationLatencyHistorySize
+}
+
+// durationsPercentile returns the q percentile of the input sorted durations, using the nearest-rank method.
+func durationsPercentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
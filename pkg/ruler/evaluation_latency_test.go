@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupEvaluationLatencies(t *testing.T) {
+	l := newGroupEvaluationLatencies()
+
+	// No history.
+	p50, p99 := l.percentiles("user-1", "group-1")
+	assert.Equal(t, time.Duration(0), p50)
+	assert.Equal(t, time.Duration(0), p99)
+
+	for i := 1; i <= 100; i++ {
+		l.observe("user-1", "group-1", time.Duration(i)*time.Millisecond)
+	}
+	l.observe("user-1", "group-2", time.Second)
+	l.observe("user-2", "group-1", time.Second)
+
+	p50, p99 = l.percentiles("user-1", "group-1")
+	assert.Equal(t, 50*time.Millisecond, p50)
+	assert.Equal(t, 99*time.Millisecond, p99)
+
+	p50, p99 = l.percentiles("user-1", "group-2")
+	assert.Equal(t, time.Second, p50)
+	assert.Equal(t, time.Second, p99)
+
+	// Only the most recent evaluations are kept.
+	for i := 0; i < groupEvaluationLatencyHistorySize; i++ {
+		l.observe("user-1", "group-1", time.Minute)
+	}
+	p50, p99 = l.percentiles("user-1", "group-1")
+	assert.Equal(t, time.Minute, p50)
+	assert.Equal(t, time.Minute, p99)
+
+	// Removing the groups which don't exist anymore.
+	l.retainGroups("user-1", nil)
+	p50, _ = l.percentiles("user-1", "group-1")
+	assert.Equal(t, time.Duration(0), p50)
+	p50, _ = l.percentiles("user-2", "group-1")
+	assert.Equal(t, time.Second, p50)
+
+	l.removeUser("user-2")
+	p50, _ = l.percentiles("user-2", "group-1")
+	assert.Equal(t, time.Duration(0), p50)
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -49,6 +50,9 @@ type DefaultMultiTenantManager struct {
 	// Prometheus rules managers metrics.
 	userManagerMetrics *ManagerMetrics
 
+	// Duration of the recent evaluations of the rule groups.
+	evaluationLatencies *groupEvaluationLatencies
+
 	// Per-user notifiers with separate queues.
 	notifiersMtx sync.Mutex
 	notifiers    map[string]*rulerNotifier
@@ -72,15 +76,16 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 	}
 
 	return &DefaultMultiTenantManager{
-		cfg:                cfg,
-		managerFactory:     managerFactory,
-		limits:             limits,
-		dnsResolver:        dnsResolver,
-		refreshMetrics:     refreshMetrics,
-		notifiers:          map[string]*rulerNotifier{},
-		mapper:             newMapper(cfg.RulePath, logger),
-		userManagers:       map[string]RulesManager{},
-		userManagerMetrics: userManagerMetrics,
+		cfg:                 cfg,
+		managerFactory:      managerFactory,
+		limits:              limits,
+		dnsResolver:         dnsResolver,
+		refreshMetrics:      refreshMetrics,
+		notifiers:           map[string]*rulerNotifier{},
+		mapper:              newMapper(cfg.RulePath, logger),
+		userManagers:        map[string]RulesManager{},
+		userManagerMetrics:  userManagerMetrics,
+		evaluationLatencies: newGroupEvaluationLatencies(),
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Name: "cortex_ruler_managers_total",
 			Help: "Total number of managers registered and running in the ruler",
@@ -213,13 +218,16 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(user string, groups rules
 	level.Debug(r.logger).Log("msg", "updating rules", "user", user)
 	r.configUpdatesTotal.WithLabelValues(user).Inc()
 
-	err = manager.Update(r.cfg.EvaluationInterval, files, labels.EmptyLabels(), r.cfg.ExternalURL.String(), nil)
+	err = manager.Update(r.cfg.EvaluationInterval, files, labels.EmptyLabels(), r.cfg.ExternalURL.String(), r.evaluationLatencies.evalIterationFunc(user))
 	if err != nil {
 		r.lastReloadSuccessful.WithLabelValues(user).Set(0)
 		level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 		return
 	}
 
+	// Forget the evaluation history of the rule groups which have been removed.
+	r.evaluationLatencies.retainGroups(user, manager.RuleGroups())
+
 	r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 	r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
 }
@@ -356,6 +364,7 @@ func (r *DefaultMultiTenantManager) removeUsersIf(shouldRemove func(userID strin
 		r.lastReloadSuccessfulTimestamp.DeleteLabelValues(userID)
 		r.configUpdatesTotal.DeleteLabelValues(userID)
 		r.userManagerMetrics.RemoveUserRegistry(userID)
+		r.evaluationLatencies.removeUser(userID)
 		level.Info(r.logger).Log("msg", "deleted rule manager and local rule files", "user", userID)
 	}
 
@@ -377,6 +386,11 @@ func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	return nil
 }
 
+// GetRuleGroupEvaluationLatency implements MultiTenantManager.
+func (r *DefaultMultiTenantManager) GetRuleGroupEvaluationLatency(userID string, group *promRules.Group) (p50, p99 time.Duration) {
+	return r.evaluationLatencies.percentiles(userID, promRules.GroupKey(group.File(), group.Name()))
+}
+
 func (r *DefaultMultiTenantManager) Stop() {
 	level.Info(r.logger).Log("msg", "stopping user managers")
 	wg := sync.WaitGroup{}
@@ -57,6 +57,7 @@ import (
 var (
 	errInvalidTenantShardSize                                 = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
 	errInnvalidRuleEvaluationConcurrencyMinDurationPercentage = errors.New("invalid tenant minimum duration percentage for rule evaluation concurrency, the value must be greater or equal to 0")
+	errInvalidListRulesLoadBatchSize                          = errors.New("invalid list rules load batch size, the value must be greater than 0")
 )
 
 const (
@@ -156,6 +157,8 @@ type Config struct {
 	IndependentRuleEvaluationConcurrencyMinDurationPercentage float64 `yaml:"independent_rule_evaluation_concurrency_min_duration_percentage" category:"experimental"`
 
 	RuleEvaluationWriteEnabled bool `yaml:"rule_evaluation_write_enabled" category:"experimental"`
+
+	ListRulesLoadBatchSize int `yaml:"list_rules_load_batch_size" category:"experimental"`
 }
 
 // Validate config and returns error on failure
@@ -176,6 +179,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return errInnvalidRuleEvaluationConcurrencyMinDurationPercentage
 	}
 
+	if cfg.ListRulesLoadBatchSize <= 0 {
+		return errInvalidListRulesLoadBatchSize
+	}
+
 	return nil
 }
 
@@ -218,6 +225,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 
 	f.BoolVar(&cfg.RuleEvaluationWriteEnabled, "ruler.rule-evaluation-write-enabled", true, "Writes the results of rule evaluation to ingesters or ingest storage when enabled. Use this option for testing purposes. To disable, set to false.")
 
+	f.IntVar(&cfg.ListRulesLoadBatchSize, "ruler.list-rules-load-batch-size", 100, "Maximum number of rule groups loaded from the rule store at once by the list rules API. Rule groups are loaded in batches of this size. The memory used to list a tenant with a large number of rule groups is only bounded when the response is streamed as NDJSON, because each batch is written to the response before loading the next one. The other response formats keep all the rule groups in memory until the response is written.")
+
 	f.DurationVar(&cfg.OutboundSyncQueuePollInterval, "ruler.outbound-sync-queue-poll-interval", defaultRulerSyncPollFrequency, `Interval between sending queued rule sync requests to ruler replicas.`)
 	f.DurationVar(&cfg.InboundSyncQueuePollInterval, "ruler.inbound-sync-queue-poll-interval", defaultRulerSyncPollFrequency, `Interval between applying queued incoming rule sync requests.`)
 
@@ -287,6 +296,10 @@ type MultiTenantManager interface {
 	// GetRules fetches rules for a particular tenant (userID).
 	GetRules(userID string) []*promRules.Group
 
+	// GetRuleGroupEvaluationLatency returns the 50th and 99th percentiles of the duration of the recent
+	// evaluations of the tenant's rule group. Both are zero if the evaluation history isn't available.
+	GetRuleGroupEvaluationLatency(userID string, group *promRules.Group) (p50, p99 time.Duration)
+
 	// Stop stops all Manager components.
 	Stop()
 
@@ -353,6 +366,9 @@ type Ruler struct {
 
 	allowedTenants *util.AllowList
 
+	// Checksums of the configuration of the rule groups loaded by the last sync.
+	configHashes *ruleGroupConfigHashes
+
 	syncBackoffConfig backoff.Config
 
 	registry prometheus.Registerer
@@ -377,6 +393,7 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		inboundSyncQueue:  newRulerSyncQueue(cfg.InboundSyncQueuePollInterval),
 		allowedTenants:    util.NewAllowList(cfg.EnabledTenants, cfg.DisabledTenants),
 		metrics:           newRulerMetrics(reg),
+		configHashes:      newRuleGroupConfigHashes(),
 		syncBackoffConfig: backoff.Config{
 			MinBackoff: 3 * time.Second,
 			MaxBackoff: 15 * time.Second,
@@ -640,6 +657,9 @@ func (r *Ruler) syncRules(ctx context.Context, userIDs []string, reason rulesSyn
 		return fmt.Errorf("load rules to sync: %w", err)
 	}
 
+	// Hash the rule groups as they're stored, before they're changed by the filters and the limits below.
+	r.configHashes.update(configs, userIDs, r.logger)
+
 	// Filter out all rules for which their evaluation has been disabled for the given tenant.
 	configs = filterRuleGroupsByEnabled(configs, r.limits, r.logger)
 	// Apply any changes required by tenant limits.
@@ -1251,6 +1271,8 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 			EvaluationTimestamp: group.GetLastEvaluation(),
 			EvaluationDuration:  group.GetEvaluationTime(),
 		}
+		groupDesc.EvaluationLatencyP50, groupDesc.EvaluationLatencyP99 = r.manager.GetRuleGroupEvaluationLatency(userID, group)
+		groupDesc.ConfigHash = r.configHashes.get(userID, decodedNamespace, group.Name())
 		for _, r := range group.Rules() {
 			if ruleSet.IsFiltered(r.Name()) {
 				continue
@@ -1269,7 +1291,10 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 				}
 
 				var alerts []*AlertStateDesc
-				if !req.ExcludeAlerts {
+				activeAlertsCount := 0
+				if req.ExcludeAlerts {
+					activeAlertsCount = rule.ActiveAlertsCount()
+				} else {
 					activeAlerts := rule.ActiveAlerts()
 					alerts = make([]*AlertStateDesc, 0, len(activeAlerts))
 					for _, a := range activeAlerts {
@@ -1286,6 +1311,7 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 							KeepFiringSince: a.KeepFiringSince,
 						})
 					}
+					activeAlertsCount = len(alerts)
 				}
 				ruleDesc = &RuleStateDesc{
 					Rule: &rulespb.RuleDesc{
@@ -1302,6 +1328,7 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 					Alerts:              alerts,
 					EvaluationTimestamp: rule.GetEvaluationTimestamp(),
 					EvaluationDuration:  rule.GetEvaluationDuration(),
+					ActiveAlertsCount:   int64(activeAlertsCount),
 				}
 			case *promRules.RecordingRule:
 				if !getRecordingRules {
@@ -295,6 +295,12 @@ type GroupStateDesc struct {
 	ActiveRules         []*RuleStateDesc       `protobuf:"bytes,2,rep,name=active_rules,json=activeRules,proto3" json:"active_rules,omitempty"`
 	EvaluationTimestamp time.Time              `protobuf:"bytes,3,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration          `protobuf:"bytes,4,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	// The 50th and 99th percentiles of the duration of the recent evaluations of the group.
+	// Zero if the evaluation history of the group is not available.
+	EvaluationLatencyP50 time.Duration `protobuf:"bytes,5,opt,name=evaluationLatencyP50,proto3,stdduration" json:"evaluationLatencyP50"`
+	EvaluationLatencyP99 time.Duration `protobuf:"bytes,6,opt,name=evaluationLatencyP99,proto3,stdduration" json:"evaluationLatencyP99"`
+	// The checksum of the configuration of the group loaded by the ruler, the same returned by the configuration API.
+	ConfigHash string `protobuf:"bytes,7,opt,name=configHash,proto3" json:"configHash,omitempty"`
 }
 
 func (m *GroupStateDesc) Reset()      { *m = GroupStateDesc{} }
@@ -357,6 +363,27 @@ func (m *GroupStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *GroupStateDesc) GetEvaluationLatencyP50() time.Duration {
+	if m != nil {
+		return m.EvaluationLatencyP50
+	}
+	return 0
+}
+
+func (m *GroupStateDesc) GetEvaluationLatencyP99() time.Duration {
+	if m != nil {
+		return m.EvaluationLatencyP99
+	}
+	return 0
+}
+
+func (m *GroupStateDesc) GetConfigHash() string {
+	if m != nil {
+		return m.ConfigHash
+	}
+	return ""
+}
+
 // RuleStateDesc is a proto representation of a Prometheus Rule
 type RuleStateDesc struct {
 	Rule                *rulespb.RuleDesc `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
@@ -366,6 +393,8 @@ type RuleStateDesc struct {
 	Alerts              []*AlertStateDesc `protobuf:"bytes,5,rep,name=alerts,proto3" json:"alerts,omitempty"`
 	EvaluationTimestamp time.Time         `protobuf:"bytes,6,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration     `protobuf:"bytes,7,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	// The number of active alerts of the alerting rule, set even if the alerts are excluded.
+	ActiveAlertsCount int64 `protobuf:"varint,8,opt,name=activeAlertsCount,proto3" json:"activeAlertsCount,omitempty"`
 }
 
 func (m *RuleStateDesc) Reset()      { *m = RuleStateDesc{} }
@@ -449,6 +478,13 @@ func (m *RuleStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *RuleStateDesc) GetActiveAlertsCount() int64 {
+	if m != nil {
+		return m.ActiveAlertsCount
+	}
+	return 0
+}
+
 type AlertStateDesc struct {
 	State           string                                              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
 	Labels          []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,2,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"labels"`
@@ -564,66 +600,70 @@ func init() {
 func init() { proto.RegisterFile("ruler.proto", fileDescriptor_9ecbec0a4cfddea6) }
 
 var fileDescriptor_9ecbec0a4cfddea6 = []byte{
-	// 936 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0x4f, 0x6f, 0x1b, 0x45,
-	0x14, 0xf7, 0x3a, 0xf1, 0x9f, 0x7d, 0x4e, 0xd2, 0x64, 0x12, 0x60, 0x6b, 0xca, 0xc6, 0x32, 0x42,
-	0x8a, 0x90, 0x6a, 0x43, 0x88, 0x40, 0x48, 0x48, 0xe0, 0xa8, 0x2d, 0x42, 0x42, 0xa8, 0x5a, 0x07,
-	0x0e, 0x5c, 0xac, 0xb1, 0x3d, 0xde, 0x8c, 0xb2, 0x3b, 0xbb, 0xcc, 0xcc, 0x06, 0xe7, 0x04, 0x1f,
-	0xa1, 0x47, 0xce, 0x9c, 0xf8, 0x06, 0xdc, 0x39, 0xf5, 0x98, 0x63, 0x85, 0x50, 0x21, 0xce, 0x85,
-	0x63, 0x3f, 0x02, 0x9a, 0x37, 0xbb, 0xb1, 0xd3, 0x06, 0x54, 0x0b, 0xb8, 0xc4, 0xf3, 0xde, 0xbc,
-	0xdf, 0xef, 0xfd, 0xfb, 0xed, 0x04, 0x1a, 0x32, 0x8b, 0x98, 0xec, 0xa4, 0x32, 0xd1, 0x09, 0xa9,
-	0xa0, 0xd1, 0x7c, 0x27, 0xe4, 0xfa, 0x38, 0x1b, 0x76, 0x46, 0x49, 0xdc, 0x0d, 0x25, 0x9d, 0x50,
-	0x41, 0xbb, 0x31, 0x8f, 0xb9, 0xec, 0xa6, 0x27, 0xa1, 0x3d, 0xa5, 0x43, 0xfb, 0x6b, 0x81, 0xcd,
-	0xf7, 0xff, 0x11, 0x81, 0xac, 0xf8, 0x57, 0xa5, 0x43, 0xfb, 0x9b, 0xe3, 0x76, 0xc2, 0x24, 0x4c,
-	0xf0, 0xd8, 0x35, 0xa7, 0xdc, 0xeb, 0x87, 0x49, 0x12, 0x46, 0xac, 0x8b, 0xd6, 0x30, 0x9b, 0x74,
-	0xc7, 0x99, 0xa4, 0x9a, 0x27, 0x22, 0xbf, 0xdf, 0x7d, 0xfe, 0x5e, 0xf3, 0x98, 0x29, 0x4d, 0xe3,
-	0xd4, 0x06, 0xb4, 0x7f, 0x2e, 0xc3, 0x5a, 0x60, 0xd2, 0x04, 0xec, 0x9b, 0x8c, 0x29, 0x4d, 0x0e,
-	0xa0, 0x3a, 0xe1, 0x91, 0x66, 0xd2, 0x73, 0x5a, 0xce, 0xde, 0xc6, 0xfe, 0x9d, 0x8e, 0x6d, 0x7b,
-	0x31, 0x08, 0x8d, 0xa3, 0xb3, 0x94, 0x05, 0x79, 0x2c, 0x79, 0x1d, 0x5c, 0x13, 0x36, 0x10, 0x34,
-	0x66, 0x5e, 0xb9, 0xb5, 0xb2, 0xe7, 0x06, 0x75, 0xe3, 0xf8, 0x82, 0xc6, 0x8c, 0xbc, 0x01, 0x80,
-	0x97, 0xa1, 0x4c, 0xb2, 0xd4, 0x5b, 0xc1, 0x5b, 0x0c, 0xff, 0xd4, 0x38, 0x08, 0x81, 0xd5, 0x09,
-	0x8f, 0x98, 0xb7, 0x8a, 0x17, 0x78, 0x26, 0x6f, 0xc1, 0x06, 0x9b, 0x8e, 0xa2, 0x6c, 0xcc, 0x06,
-	0x34, 0x62, 0x52, 0x2b, 0xaf, 0xd2, 0x72, 0xf6, 0xea, 0xc1, 0x7a, 0xee, 0xed, 0xa1, 0xd3, 0x30,
-	0xc7, 0x74, 0x6a, 0x89, 0x95, 0x57, 0x6d, 0x39, 0x7b, 0x95, 0xc0, 0x8d, 0xe9, 0x14, 0x89, 0xf1,
-	0x5a, 0xb0, 0xa9, 0x1e, 0xe8, 0xe4, 0x84, 0x09, 0xaf, 0xd6, 0x72, 0x4c, 0x62, 0xe3, 0x39, 0x32,
-	0x8e, 0xf6, 0x47, 0x50, 0x2f, 0x1a, 0x21, 0x0d, 0xa8, 0xf5, 0xc4, 0x99, 0x31, 0x37, 0x4b, 0x64,
-	0x13, 0xd6, 0x30, 0x01, 0x17, 0x21, 0x7a, 0x1c, 0xb2, 0x05, 0xeb, 0x01, 0x1b, 0x25, 0x72, 0x5c,
-	0xb8, 0xca, 0xed, 0xaf, 0x61, 0x3d, 0x9f, 0x89, 0x4a, 0x13, 0xa1, 0x18, 0xb9, 0x0b, 0xd5, 0xbc,
-	0x10, 0xa7, 0xb5, 0xb2, 0xd7, 0xd8, 0x7f, 0x25, 0x9f, 0x1c, 0x16, 0xd3, 0xd7, 0x54, 0xb3, 0x7b,
-	0x4c, 0x8d, 0x82, 0x3c, 0x88, 0x34, 0xa1, 0xfe, 0x2d, 0x95, 0x82, 0x8b, 0x50, 0x15, 0x13, 0x2b,
-	0xec, 0xf6, 0x5d, 0xd8, 0xec, 0x9f, 0x89, 0xd1, 0xb5, 0xc5, 0xdc, 0x86, 0x7a, 0xa6, 0x98, 0x1c,
-	0xf0, 0xb1, 0x4d, 0xe0, 0x06, 0x35, 0x63, 0x7f, 0x36, 0x56, 0xed, 0x6d, 0xd8, 0x5a, 0x08, 0xb7,
-	0xe5, 0xb4, 0x7f, 0x2c, 0xc3, 0xc6, 0xf5, 0xd4, 0xe4, 0x6d, 0xa8, 0xd8, 0x1d, 0x98, 0xd5, 0x36,
-	0xf6, 0x77, 0x3a, 0x56, 0x60, 0x41, 0xb1, 0x0a, 0xac, 0xcf, 0x86, 0x90, 0x0f, 0x60, 0x8d, 0x8e,
-	0x34, 0x3f, 0x65, 0x03, 0x0c, 0xc2, 0x12, 0x0b, 0x88, 0x55, 0xc3, 0xbc, 0xa5, 0x86, 0x8d, 0xc4,
-	0xfc, 0xe4, 0x2b, 0xd8, 0x66, 0xa7, 0x34, 0xca, 0x50, 0x86, 0x47, 0x85, 0xdc, 0xbc, 0x15, 0x4c,
-	0xd9, 0xec, 0x58, 0x41, 0x76, 0x0a, 0x41, 0x76, 0xae, 0x22, 0x0e, 0xeb, 0x8f, 0x9f, 0xee, 0x96,
-	0x1e, 0xfd, 0xbe, 0xeb, 0x04, 0x37, 0x11, 0x90, 0x3e, 0x90, 0xb9, 0xfb, 0x5e, 0x2e, 0x73, 0x6f,
-	0x15, 0x69, 0x6f, 0xbf, 0x40, 0x5b, 0x04, 0x58, 0xd6, 0x1f, 0x0c, 0xeb, 0x0d, 0xf0, 0xf6, 0x6f,
-	0x65, 0xbb, 0xc5, 0xf9, 0x8c, 0xde, 0x84, 0x55, 0xd3, 0x62, 0x3e, 0xa2, 0x5b, 0x0b, 0x23, 0xc2,
-	0x56, 0xf1, 0x92, 0xec, 0x40, 0x45, 0x19, 0x84, 0x57, 0x46, 0x4d, 0x59, 0x83, 0xbc, 0x0a, 0xd5,
-	0x63, 0x46, 0x23, 0x7d, 0x8c, 0xcd, 0xba, 0x41, 0x6e, 0x91, 0x3b, 0xe0, 0x46, 0x54, 0xe9, 0xfb,
-	0x52, 0x26, 0x12, 0x0b, 0x76, 0x83, 0xb9, 0xc3, 0xc8, 0xe6, 0x4a, 0xe2, 0x8b, 0xb2, 0x41, 0x05,
-	0x2e, 0xc8, 0xc6, 0x06, 0xfd, 0xdd, 0x78, 0xab, 0xff, 0xcf, 0x78, 0x6b, 0xff, 0x6e, 0xbc, 0xbf,
-	0x54, 0x60, 0xe3, 0x7a, 0x1f, 0xf3, 0xd1, 0x39, 0x8b, 0xa3, 0x9b, 0x40, 0x35, 0xa2, 0x43, 0x16,
-	0x15, 0x3a, 0xdb, 0xee, 0x8c, 0x12, 0xa9, 0xd9, 0x34, 0x1d, 0x76, 0x3e, 0x37, 0xfe, 0x87, 0x94,
-	0xcb, 0xc3, 0x0f, 0x4d, 0xae, 0x5f, 0x9f, 0xee, 0xbe, 0xfb, 0x32, 0x8f, 0xae, 0xc5, 0xf5, 0xc6,
-	0x34, 0xd5, 0x4c, 0x06, 0x39, 0x3b, 0x49, 0xa1, 0x41, 0x85, 0x48, 0x34, 0x96, 0xa7, 0xf0, 0x2d,
-	0xfa, 0xef, 0x93, 0x2d, 0xa6, 0x30, 0xfd, 0x9a, 0xb9, 0x30, 0x5c, 0xbc, 0x13, 0x58, 0x83, 0xf4,
-	0xc0, 0xcd, 0xbf, 0x2e, 0xaa, 0xf1, 0x69, 0x7b, 0xd9, 0xdd, 0xd5, 0x2d, 0xac, 0xa7, 0xc9, 0xc7,
-	0x50, 0x9f, 0x70, 0xc9, 0xc6, 0x86, 0x61, 0x99, 0xed, 0xd7, 0x10, 0xd5, 0xd3, 0xe4, 0x3e, 0x34,
-	0x24, 0x53, 0x49, 0x74, 0x6a, 0x39, 0x6a, 0x4b, 0x70, 0x40, 0x01, 0xec, 0x69, 0xf2, 0x00, 0xd6,
-	0x8c, 0x98, 0x07, 0x8a, 0x09, 0x6d, 0x78, 0xea, 0xcb, 0xf0, 0x18, 0x64, 0x9f, 0x09, 0x6d, 0xcb,
-	0x39, 0xa5, 0x11, 0x1f, 0x0f, 0x32, 0xa1, 0x79, 0xe4, 0xb9, 0xcb, 0xd0, 0x20, 0xf0, 0x4b, 0x83,
-	0x23, 0x0f, 0x61, 0xeb, 0x84, 0xb1, 0x74, 0x30, 0xe1, 0x92, 0x8b, 0x70, 0xa0, 0xb8, 0x18, 0x31,
-	0x0f, 0x96, 0x20, 0xbb, 0x65, 0xe0, 0x0f, 0x10, 0xdd, 0x37, 0xe0, 0xfd, 0xef, 0xa0, 0x62, 0x3e,
-	0x7f, 0x49, 0x0e, 0xec, 0x41, 0x91, 0xed, 0x1b, 0xfe, 0x27, 0x36, 0x77, 0xae, 0x3b, 0xf3, 0x57,
-	0xb8, 0x44, 0x3e, 0x01, 0xf7, 0xea, 0x71, 0x26, 0xaf, 0xe5, 0x41, 0xcf, 0xbf, 0xee, 0x4d, 0xef,
-	0xc5, 0x8b, 0x82, 0xe1, 0xf0, 0xe0, 0xfc, 0xc2, 0x2f, 0x3d, 0xb9, 0xf0, 0x4b, 0xcf, 0x2e, 0x7c,
-	0xe7, 0xfb, 0x99, 0xef, 0xfc, 0x34, 0xf3, 0x9d, 0xc7, 0x33, 0xdf, 0x39, 0x9f, 0xf9, 0xce, 0x1f,
-	0x33, 0xdf, 0xf9, 0x73, 0xe6, 0x97, 0x9e, 0xcd, 0x7c, 0xe7, 0xd1, 0xa5, 0x5f, 0x3a, 0xbf, 0xf4,
-	0x4b, 0x4f, 0x2e, 0xfd, 0xd2, 0xb0, 0x8a, 0x5d, 0xbe, 0xf7, 0x57, 0x00, 0x00, 0x00, 0xff, 0xff,
-	0x7f, 0xbc, 0x63, 0x58, 0xb7, 0x08, 0x00, 0x00,
+	// 1003 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0x4f, 0x6f, 0x1b, 0x45,
+	0x14, 0xf7, 0xc6, 0xf1, 0x9f, 0x7d, 0x4e, 0xd2, 0x64, 0x62, 0x60, 0x6b, 0xca, 0xc6, 0x32, 0x42,
+	0xb2, 0x10, 0xb5, 0x4b, 0x08, 0x20, 0x4b, 0x48, 0xe0, 0xd0, 0x16, 0x90, 0x2a, 0x14, 0xad, 0x03,
+	0x48, 0x5c, 0x56, 0xe3, 0xf5, 0x78, 0xb3, 0xca, 0x7a, 0x76, 0x99, 0x99, 0x0d, 0xce, 0x09, 0xce,
+	0x9c, 0x7a, 0xe4, 0x23, 0xf0, 0x0d, 0xb8, 0x73, 0xea, 0x31, 0xc7, 0x8a, 0x43, 0x21, 0xce, 0x85,
+	0x63, 0x0f, 0x7c, 0x00, 0x34, 0x33, 0xbb, 0xb1, 0xdd, 0x98, 0x2a, 0x56, 0x95, 0x4b, 0x3c, 0xef,
+	0xcf, 0xef, 0xf7, 0x66, 0xde, 0xfb, 0xcd, 0x4e, 0xa0, 0xc2, 0x92, 0x90, 0xb0, 0x56, 0xcc, 0x22,
+	0x11, 0xa1, 0x82, 0x32, 0x6a, 0xf7, 0xfc, 0x40, 0x1c, 0x25, 0xfd, 0x96, 0x17, 0x8d, 0xda, 0x3e,
+	0xc3, 0x43, 0x4c, 0x71, 0x7b, 0x14, 0x8c, 0x02, 0xd6, 0x8e, 0x8f, 0x7d, 0xbd, 0x8a, 0xfb, 0xfa,
+	0x57, 0x03, 0x6b, 0x1f, 0xbd, 0x14, 0xa1, 0x58, 0xd5, 0x5f, 0x1e, 0xf7, 0xf5, 0x6f, 0x8a, 0xab,
+	0xfa, 0x91, 0x1f, 0xa9, 0x65, 0x5b, 0xae, 0x52, 0xaf, 0xed, 0x47, 0x91, 0x1f, 0x92, 0xb6, 0xb2,
+	0xfa, 0xc9, 0xb0, 0x3d, 0x48, 0x18, 0x16, 0x41, 0x44, 0xd3, 0xf8, 0xce, 0x8b, 0x71, 0x11, 0x8c,
+	0x08, 0x17, 0x78, 0x14, 0xeb, 0x84, 0xc6, 0xef, 0x2b, 0xb0, 0xe6, 0xc8, 0x32, 0x0e, 0xf9, 0x21,
+	0x21, 0x5c, 0xa0, 0x3d, 0x28, 0x0e, 0x83, 0x50, 0x10, 0x66, 0x19, 0x75, 0xa3, 0xb9, 0xb1, 0x7b,
+	0xa7, 0xa5, 0x8f, 0x3d, 0x9b, 0xa4, 0x8c, 0xc3, 0xd3, 0x98, 0x38, 0x69, 0x2e, 0x7a, 0x13, 0x4c,
+	0x99, 0xe6, 0x52, 0x3c, 0x22, 0xd6, 0x4a, 0x3d, 0xdf, 0x34, 0x9d, 0xb2, 0x74, 0x7c, 0x8d, 0x47,
+	0x04, 0xbd, 0x05, 0xa0, 0x82, 0x3e, 0x8b, 0x92, 0xd8, 0xca, 0xab, 0xa8, 0x4a, 0xff, 0x42, 0x3a,
+	0x10, 0x82, 0xd5, 0x61, 0x10, 0x12, 0x6b, 0x55, 0x05, 0xd4, 0x1a, 0xbd, 0x03, 0x1b, 0x64, 0xec,
+	0x85, 0xc9, 0x80, 0xb8, 0x38, 0x24, 0x4c, 0x70, 0xab, 0x50, 0x37, 0x9a, 0x65, 0x67, 0x3d, 0xf5,
+	0x76, 0x95, 0x53, 0x32, 0x8f, 0xf0, 0x58, 0x13, 0x73, 0xab, 0x58, 0x37, 0x9a, 0x05, 0xc7, 0x1c,
+	0xe1, 0xb1, 0x22, 0x56, 0x61, 0x4a, 0xc6, 0xc2, 0x15, 0xd1, 0x31, 0xa1, 0x56, 0xa9, 0x6e, 0xc8,
+	0xc2, 0xd2, 0x73, 0x28, 0x1d, 0x8d, 0x4f, 0xa0, 0x9c, 0x1d, 0x04, 0x55, 0xa0, 0xd4, 0xa5, 0xa7,
+	0xd2, 0xdc, 0xcc, 0xa1, 0x4d, 0x58, 0x53, 0x05, 0x02, 0xea, 0x2b, 0x8f, 0x81, 0xb6, 0x60, 0xdd,
+	0x21, 0x5e, 0xc4, 0x06, 0x99, 0x6b, 0xa5, 0xf1, 0x3d, 0xac, 0xa7, 0x3d, 0xe1, 0x71, 0x44, 0x39,
+	0x41, 0x77, 0xa1, 0x98, 0x6e, 0xc4, 0xa8, 0xe7, 0x9b, 0x95, 0xdd, 0xd7, 0xd2, 0xce, 0xa9, 0xcd,
+	0xf4, 0x04, 0x16, 0xe4, 0x3e, 0xe1, 0x9e, 0x93, 0x26, 0xa1, 0x1a, 0x94, 0x7f, 0xc4, 0x8c, 0x06,
+	0xd4, 0xe7, 0x59, 0xc7, 0x32, 0xbb, 0x71, 0x17, 0x36, 0x7b, 0xa7, 0xd4, 0x9b, 0x1b, 0xcc, 0x6d,
+	0x28, 0x27, 0x9c, 0x30, 0x37, 0x18, 0xe8, 0x02, 0xa6, 0x53, 0x92, 0xf6, 0x57, 0x03, 0xde, 0xd8,
+	0x86, 0xad, 0x99, 0x74, 0xbd, 0x9d, 0xc6, 0xbf, 0x79, 0xd8, 0x98, 0x2f, 0x8d, 0xde, 0x85, 0x82,
+	0x9e, 0x81, 0x1c, 0x6d, 0x65, 0xb7, 0xda, 0xd2, 0x02, 0x73, 0xb2, 0x51, 0xa8, 0xfd, 0xe9, 0x14,
+	0xf4, 0x31, 0xac, 0x61, 0x4f, 0x04, 0x27, 0xc4, 0x55, 0x49, 0x6a, 0x8b, 0x19, 0x44, 0xab, 0x61,
+	0x7a, 0xa4, 0x8a, 0xce, 0x54, 0xf5, 0xd1, 0xb7, 0xb0, 0x4d, 0x4e, 0x70, 0x98, 0x28, 0x19, 0x1e,
+	0x66, 0x72, 0xb3, 0xf2, 0xaa, 0x64, 0xad, 0xa5, 0x05, 0xd9, 0xca, 0x04, 0xd9, 0xba, 0xcc, 0xd8,
+	0x2f, 0x3f, 0x79, 0xb6, 0x93, 0x7b, 0xfc, 0xd7, 0x8e, 0xe1, 0x2c, 0x22, 0x40, 0x3d, 0x40, 0x53,
+	0xf7, 0xfd, 0x54, 0xe6, 0xd6, 0xaa, 0xa2, 0xbd, 0x7d, 0x85, 0x36, 0x4b, 0xd0, 0xac, 0xbf, 0x4a,
+	0xd6, 0x05, 0x70, 0xf4, 0x1d, 0x54, 0xa7, 0xde, 0x47, 0x58, 0x10, 0xea, 0x9d, 0x1e, 0x7c, 0x78,
+	0xcf, 0x2a, 0x5c, 0x9f, 0x76, 0x21, 0xc1, 0x62, 0xe2, 0x4e, 0xc7, 0x2a, 0xbe, 0x12, 0x71, 0xa7,
+	0x83, 0x6c, 0x00, 0x2f, 0xa2, 0xc3, 0xc0, 0xff, 0x12, 0xf3, 0xa3, 0x54, 0xd3, 0x33, 0x9e, 0xc6,
+	0x2f, 0x79, 0x58, 0x9f, 0x9b, 0x0e, 0x7a, 0x1b, 0x56, 0xe5, 0xd0, 0xd2, 0xa1, 0xdf, 0x9a, 0x19,
+	0xba, 0x1a, 0x9e, 0x0a, 0xa2, 0x2a, 0x14, 0xb8, 0x44, 0x58, 0x2b, 0x8a, 0x51, 0x1b, 0xe8, 0x75,
+	0x28, 0x1e, 0x11, 0x1c, 0x8a, 0x23, 0x35, 0x3e, 0xd3, 0x49, 0x2d, 0x74, 0x07, 0xcc, 0x10, 0x73,
+	0xf1, 0x80, 0xb1, 0x88, 0xa9, 0x11, 0x98, 0xce, 0xd4, 0x21, 0x2f, 0xc2, 0xe5, 0xa5, 0x9d, 0xbd,
+	0x08, 0xea, 0x4e, 0xcd, 0x5c, 0x04, 0x9d, 0xf4, 0x7f, 0x82, 0x29, 0xde, 0x8c, 0x60, 0x4a, 0xaf,
+	0x26, 0x98, 0xf7, 0x60, 0x4b, 0x8b, 0x5d, 0x7f, 0x81, 0x3e, 0x8f, 0x12, 0x2a, 0xac, 0x72, 0xdd,
+	0x68, 0xe6, 0x9d, 0xab, 0x81, 0xc6, 0x1f, 0x05, 0xd8, 0x98, 0x3f, 0xf5, 0xb4, 0xd1, 0xc6, 0x6c,
+	0xa3, 0x87, 0x50, 0x0c, 0x71, 0x9f, 0x84, 0xd9, 0x3d, 0xdb, 0x6e, 0x79, 0x11, 0x13, 0x64, 0x1c,
+	0xf7, 0x5b, 0x8f, 0xa4, 0xff, 0x00, 0x07, 0x6c, 0xbf, 0x23, 0x77, 0xf6, 0xe7, 0xb3, 0x9d, 0xf7,
+	0xaf, 0xf3, 0xe8, 0x68, 0x5c, 0x77, 0x80, 0x63, 0x41, 0x98, 0x93, 0xb2, 0xa3, 0x18, 0x2a, 0x98,
+	0xd2, 0x48, 0xa8, 0xc3, 0x70, 0x2b, 0x7f, 0x23, 0xc5, 0x66, 0x4b, 0xc8, 0xf3, 0xca, 0x2e, 0x12,
+	0x25, 0x13, 0xc3, 0xd1, 0x06, 0xea, 0x82, 0x99, 0x7e, 0x5d, 0xb0, 0xb0, 0x0a, 0x4b, 0x4c, 0xba,
+	0x9c, 0x36, 0x59, 0xa0, 0x4f, 0xa1, 0x3c, 0x0c, 0x18, 0x19, 0x48, 0x86, 0x65, 0xb4, 0x52, 0x52,
+	0xa8, 0xae, 0x40, 0x0f, 0xa0, 0xc2, 0x08, 0x8f, 0xc2, 0x13, 0xcd, 0x51, 0x5a, 0x82, 0x03, 0x32,
+	0x60, 0x57, 0xa0, 0x87, 0xb0, 0x26, 0xa5, 0xef, 0x72, 0x42, 0x85, 0x8b, 0xb5, 0x18, 0xae, 0xcd,
+	0x23, 0x91, 0x3d, 0x42, 0x85, 0xde, 0xce, 0x09, 0x0e, 0x83, 0x81, 0x9b, 0x50, 0x11, 0x84, 0x96,
+	0xb9, 0x0c, 0x8d, 0x02, 0x7e, 0x23, 0x71, 0xe8, 0x00, 0xb6, 0x8e, 0x09, 0x89, 0xdd, 0x61, 0xc0,
+	0x02, 0xea, 0xbb, 0x3c, 0xa0, 0x1e, 0xb1, 0x60, 0x09, 0xb2, 0x5b, 0x12, 0xfe, 0x50, 0xa1, 0x7b,
+	0x12, 0xbc, 0xfb, 0x13, 0x14, 0xe4, 0xc7, 0x82, 0xa1, 0x3d, 0xbd, 0xe0, 0x68, 0x7b, 0xc1, 0xff,
+	0x04, 0xb5, 0xea, 0xbc, 0x33, 0x7d, 0x85, 0x72, 0xe8, 0x33, 0x30, 0x2f, 0x1f, 0x27, 0xf4, 0x46,
+	0x9a, 0xf4, 0xe2, 0xeb, 0x56, 0xb3, 0xae, 0x06, 0x32, 0x86, 0xfd, 0xbd, 0xb3, 0x73, 0x3b, 0xf7,
+	0xf4, 0xdc, 0xce, 0x3d, 0x3f, 0xb7, 0x8d, 0x9f, 0x27, 0xb6, 0xf1, 0xdb, 0xc4, 0x36, 0x9e, 0x4c,
+	0x6c, 0xe3, 0x6c, 0x62, 0x1b, 0x7f, 0x4f, 0x6c, 0xe3, 0x9f, 0x89, 0x9d, 0x7b, 0x3e, 0xb1, 0x8d,
+	0xc7, 0x17, 0x76, 0xee, 0xec, 0xc2, 0xce, 0x3d, 0xbd, 0xb0, 0x73, 0xfd, 0xa2, 0x3a, 0xe5, 0x07,
+	0xff, 0x0d, 0x00, 0x21, 0xa1, 0x41, 0xc3, 0xb7, 0x09, 0x00, 0x00,
 }
 
 func (x RulesRequest_RuleType) String() string {
@@ -813,6 +853,15 @@ func (this *GroupStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if this.EvaluationLatencyP50 != that1.EvaluationLatencyP50 {
+		return false
+	}
+	if this.EvaluationLatencyP99 != that1.EvaluationLatencyP99 {
+		return false
+	}
+	if this.ConfigHash != that1.ConfigHash {
+		return false
+	}
 	return true
 }
 func (this *RuleStateDesc) Equal(that interface{}) bool {
@@ -860,6 +909,9 @@ func (this *RuleStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if this.ActiveAlertsCount != that1.ActiveAlertsCount {
+		return false
+	}
 	return true
 }
 func (this *AlertStateDesc) Equal(that interface{}) bool {
@@ -975,7 +1027,7 @@ func (this *GroupStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 10)
 	s = append(s, "&ruler.GroupStateDesc{")
 	if this.Group != nil {
 		s = append(s, "Group: "+fmt.Sprintf("%#v", this.Group)+",\n")
@@ -985,6 +1037,9 @@ func (this *GroupStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "EvaluationLatencyP50: "+fmt.Sprintf("%#v", this.EvaluationLatencyP50)+",\n")
+	s = append(s, "EvaluationLatencyP99: "+fmt.Sprintf("%#v", this.EvaluationLatencyP99)+",\n")
+	s = append(s, "ConfigHash: "+fmt.Sprintf("%#v", this.ConfigHash)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -992,7 +1047,7 @@ func (this *RuleStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 12)
 	s = append(s, "&ruler.RuleStateDesc{")
 	if this.Rule != nil {
 		s = append(s, "Rule: "+fmt.Sprintf("%#v", this.Rule)+",\n")
@@ -1005,6 +1060,7 @@ func (this *RuleStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "ActiveAlertsCount: "+fmt.Sprintf("%#v", this.ActiveAlertsCount)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1354,21 +1410,44 @@ func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if len(m.ConfigHash) > 0 {
+		i -= len(m.ConfigHash)
+		copy(dAtA[i:], m.ConfigHash)
+		i = encodeVarintRuler(dAtA, i, uint64(len(m.ConfigHash)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationLatencyP99, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationLatencyP99):])
 	if err1 != nil {
 		return 0, err1
 	}
 	i -= n1
 	i = encodeVarintRuler(dAtA, i, uint64(n1))
 	i--
-	dAtA[i] = 0x22
-	n2, err2 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	dAtA[i] = 0x32
+	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationLatencyP50, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationLatencyP50):])
 	if err2 != nil {
 		return 0, err2
 	}
 	i -= n2
 	i = encodeVarintRuler(dAtA, i, uint64(n2))
 	i--
+	dAtA[i] = 0x2a
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintRuler(dAtA, i, uint64(n3))
+	i--
+	dAtA[i] = 0x22
+	n4, err4 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err4 != nil {
+		return 0, err4
+	}
+	i -= n4
+	i = encodeVarintRuler(dAtA, i, uint64(n4))
+	i--
 	dAtA[i] = 0x1a
 	if len(m.ActiveRules) > 0 {
 		for iNdEx := len(m.ActiveRules) - 1; iNdEx >= 0; iNdEx-- {
@@ -1419,20 +1498,25 @@ func (m *RuleStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
-	if err4 != nil {
-		return 0, err4
+	if m.ActiveAlertsCount != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.ActiveAlertsCount))
+		i--
+		dAtA[i] = 0x40
 	}
-	i -= n4
-	i = encodeVarintRuler(dAtA, i, uint64(n4))
+	n6, err6 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if err6 != nil {
+		return 0, err6
+	}
+	i -= n6
+	i = encodeVarintRuler(dAtA, i, uint64(n6))
 	i--
 	dAtA[i] = 0x3a
-	n5, err5 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
-	if err5 != nil {
-		return 0, err5
+	n7, err7 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err7 != nil {
+		return 0, err7
 	}
-	i -= n5
-	i = encodeVarintRuler(dAtA, i, uint64(n5))
+	i -= n7
+	i = encodeVarintRuler(dAtA, i, uint64(n7))
 	i--
 	dAtA[i] = 0x32
 	if len(m.Alerts) > 0 {
@@ -1505,53 +1589,53 @@ func (m *AlertStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n7, err7 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
-	if err7 != nil {
-		return 0, err7
-	}
-	i -= n7
-	i = encodeVarintRuler(dAtA, i, uint64(n7))
-	i--
-	dAtA[i] = 0x52
-	n8, err8 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
-	if err8 != nil {
-		return 0, err8
-	}
-	i -= n8
-	i = encodeVarintRuler(dAtA, i, uint64(n8))
-	i--
-	dAtA[i] = 0x4a
-	n9, err9 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
+	n9, err9 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
 	if err9 != nil {
 		return 0, err9
 	}
 	i -= n9
 	i = encodeVarintRuler(dAtA, i, uint64(n9))
 	i--
-	dAtA[i] = 0x42
-	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
+	dAtA[i] = 0x52
+	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
 	if err10 != nil {
 		return 0, err10
 	}
 	i -= n10
 	i = encodeVarintRuler(dAtA, i, uint64(n10))
 	i--
-	dAtA[i] = 0x3a
-	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	dAtA[i] = 0x4a
+	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
 	if err11 != nil {
 		return 0, err11
 	}
 	i -= n11
 	i = encodeVarintRuler(dAtA, i, uint64(n11))
 	i--
-	dAtA[i] = 0x32
-	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	dAtA[i] = 0x42
+	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
 	if err12 != nil {
 		return 0, err12
 	}
 	i -= n12
 	i = encodeVarintRuler(dAtA, i, uint64(n12))
 	i--
+	dAtA[i] = 0x3a
+	n13, err13 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	if err13 != nil {
+		return 0, err13
+	}
+	i -= n13
+	i = encodeVarintRuler(dAtA, i, uint64(n13))
+	i--
+	dAtA[i] = 0x32
+	n14, err14 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	if err14 != nil {
+		return 0, err14
+	}
+	i -= n14
+	i = encodeVarintRuler(dAtA, i, uint64(n14))
+	i--
 	dAtA[i] = 0x2a
 	if m.Value != 0 {
 		i -= 8
@@ -1713,6 +1797,14 @@ func (m *GroupStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationLatencyP50)
+	n += 1 + l + sovRuler(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationLatencyP99)
+	n += 1 + l + sovRuler(uint64(l))
+	l = len(m.ConfigHash)
+	if l > 0 {
+		n += 1 + l + sovRuler(uint64(l))
+	}
 	return n
 }
 
@@ -1748,6 +1840,9 @@ func (m *RuleStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	if m.ActiveAlertsCount != 0 {
+		n += 1 + sovRuler(uint64(m.ActiveAlertsCount))
+	}
 	return n
 }
 
@@ -1862,6 +1957,9 @@ func (this *GroupStateDesc) String() string {
 		`ActiveRules:` + repeatedStringForActiveRules + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamppb.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`EvaluationLatencyP50:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationLatencyP50), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`EvaluationLatencyP99:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationLatencyP99), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`ConfigHash:` + fmt.Sprintf("%v", this.ConfigHash) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1883,6 +1981,7 @@ func (this *RuleStateDesc) String() string {
 		`Alerts:` + repeatedStringForAlerts + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamppb.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`ActiveAlertsCount:` + fmt.Sprintf("%v", this.ActiveAlertsCount) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2563,6 +2662,104 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationLatencyP50", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationLatencyP50, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationLatencyP99", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationLatencyP99, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConfigHash", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ConfigHash = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
@@ -2845,6 +3042,25 @@ func (m *RuleStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveAlertsCount", wireType)
+			}
+			m.ActiveAlertsCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ActiveAlertsCount |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
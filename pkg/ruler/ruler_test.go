@@ -432,6 +432,8 @@ func TestRuler_ExcludeAlerts(t *testing.T) {
 				for _, ruleGroup := range rls.Groups {
 					for _, activeRule := range ruleGroup.ActiveRules {
 						assert.Len(c, activeRule.Alerts, tc.expectedAlertsCount)
+						// The number of active alerts is returned even if the alerts are excluded.
+						assert.Equal(c, int64(1), activeRule.ActiveAlertsCount)
 					}
 				}
 			}, time.Second*5, 1*time.Second)
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// RuleGroupDiff describes the changes a proposed rule group would make to the stored one.
+type RuleGroupDiff struct {
+	Namespace string `yaml:"namespace"`
+	Group     string `yaml:"group"`
+
+	// Exists is whether the rule group is stored. If not, the proposed rule group is diffed against an empty one.
+	Exists bool `yaml:"exists"`
+
+	// ChangedFields are the YAML names of the rule group fields, other than the rules, whose value would change.
+	ChangedFields []string `yaml:"changed_fields,omitempty"`
+
+	Added    []rulefmt.Rule     `yaml:"added,omitempty"`
+	Removed  []rulefmt.Rule     `yaml:"removed,omitempty"`
+	Modified []RuleModification `yaml:"modified,omitempty"`
+}
+
+// RuleModification is a rule whose definition would change.
+type RuleModification struct {
+	Before rulefmt.Rule `yaml:"before"`
+	After  rulefmt.Rule `yaml:"after"`
+}
+
+// DiffRuleGroup returns the changes the rule group in the request body would make to the stored rule group with the
+// same name in the namespace, without storing it. The proposed rule group is validated like when it's stored.
+func (a *API) DiffRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger, ctx := spanlogger.New(req.Context(), a.logger, tracer, "API.DiffRuleGroup")
+	defer logger.Finish()
+
+	userID, namespace, _, err := a.parseRequest(req, true, false)
+	if err != nil {
+		if errors.Is(err, errNoValidOrgIDFound) {
+			respondInvalidRequest(logger, w, err.Error())
+			return
+		}
+		respondServerError(logger, w, err.Error())
+		return
+	}
+
+	payload, err := io.ReadAll(req.Body)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read rule group payload", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rg, err := a.unmarshalAndValidateRuleGroup(logger, userID, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The proposed rule group is converted like when it's stored, so that it's compared to the stored one in the
+	// same canonical form.
+	proposed := rulespb.FromProto(rulespb.ToProto(userID, namespace, rg))
+
+	var stored *rulefmt.RuleGroup
+	storedDesc, err := a.store.GetRuleGroup(ctx, userID, namespace, proposed.Name)
+	switch {
+	case err == nil:
+		formatted := rulespb.FromProto(storedDesc)
+		stored = &formatted
+	case errors.Is(err, rulestore.ErrGroupNotFound):
+		// The proposed rule group would be created: it's diffed against an empty one.
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diff, err := diffRuleGroups(namespace, stored, proposed)
+	if err != nil {
+		respondServerError(logger, w, err.Error())
+		return
+	}
+
+	var header http.Header
+	if a.ruler.IsNamespaceProtected(userID, namespace) {
+		header = ProtectedNamespacesHeaderFromString(namespace)
+	}
+
+	marshalAndSend(diff, w, logger, header)
+}
+
+// diffRuleGroups returns the changes from the stored rule group, or nil if not stored, to the proposed one.
+// Rules are matched by their kind and name, and by position among the rules with the same kind and name:
+// changing the order of the rules with different names isn't reported.
+func diffRuleGroups(namespace string, stored *rulefmt.RuleGroup, proposed rulefmt.RuleGroup) (RuleGroupDiff, error) {
+	diff := RuleGroupDiff{
+		Namespace: namespace,
+		Group:     proposed.Name,
+		Exists:    stored != nil,
+	}
+
+	before := rulefmt.RuleGroup{Name: proposed.Name}
+	if stored != nil {
+		before = *stored
+	}
+
+	changedFields, err := changedRuleGroupFields(before, proposed)
+	if err != nil {
+		return RuleGroupDiff{}, err
+	}
+	diff.ChangedFields = changedFields
+
+	beforeRules := map[ruleKey]rulefmt.Rule{}
+	for key, rule := range keyedRules(before.Rules) {
+		beforeRules[key] = rule
+	}
+
+	for key, rule := range keyedRules(proposed.Rules) {
+		beforeRule, ok := beforeRules[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, rule)
+		case !reflect.DeepEqual(beforeRule, rule):
+			diff.Modified = append(diff.Modified, RuleModification{Before: beforeRule, After: rule})
+		}
+		delete(beforeRules, key)
+	}
+
+	for key, rule := range keyedRules(before.Rules) {
+		if _, ok := beforeRules[key]; ok {
+			diff.Removed = append(diff.Removed, rule)
+		}
+	}
+
+	return diff, nil
+}
+
+// ruleKey identifies a rule in a rule group: the index is the position of the rule among the rules with the same kind
+// and name, which are allowed.
+type ruleKey struct {
+	alert, record string
+	index         int
+}
+
+// keyedRules returns an iterator over the input rules, in order, and their key.
+func keyedRules(rules []rulefmt.Rule) func(yield func(ruleKey, rulefmt.Rule) bool) {
+	return func(yield func(ruleKey, rulefmt.Rule) bool) {
+		seen := map[ruleKey]int{}
+		for _, rule := range rules {
+			key := ruleKey{alert: rule.Alert, record: rule.Record}
+			key.index = seen[key]
+			seen[ruleKey{alert: rule.Alert, record: rule.Record}]++
+
+			if !yield(key, rule) {
+				return
+			}
+		}
+	}
+}
+
+// changedRuleGroupFields returns the sorted YAML names of the fields, other than the rules, whose value differs
+// between the input rule groups.
+func changedRuleGroupFields(before, after rulefmt.RuleGroup) ([]string, error) {
+	beforeFields, err := ruleGroupFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := ruleGroupFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, name := range slices.Sorted(maps.Keys(afterFields)) {
+		if !reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(beforeFields)) {
+		if _, ok := afterFields[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// ruleGroupFields returns the fields of the rule group, other than the rules, as encoded in YAML, keyed by their
+// YAML name. Fields omitted when empty are not returned.
+func ruleGroupFields(rg rulefmt.RuleGroup) (map[string]any, error) {
+	rg.Rules = nil
+
+	out, err := yaml.Marshal(rg)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]any{}
+	if err := yaml.Unmarshal(out, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "rules")
+	return fields, nil
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestRuler_DiffRuleGroup(t *testing.T) {
+	const userID = "user1"
+
+	storedGroup := &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace1",
+		User:      userID,
+		Interval:  time.Minute,
+		Rules: []*rulespb.RuleDesc{
+			createRecordingRule("kept_rule", "up"),
+			createRecordingRule("modified_rule", "up == 1"),
+			createAlertingRule("RemovedAlert", "up == 0"),
+		},
+	}
+
+	cfg := defaultRulerConfig(t)
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		userID: {storedGroup, &rulespb.RuleGroupDesc{
+			Name:      "group1",
+			Namespace: "team/protected",
+			User:      userID,
+			Interval:  time.Minute,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("kept_rule", "up")},
+		}},
+	})
+
+	r := prepareRuler(t, cfg, store, withStart(), withLimits(validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+		defaults.RulerProtectedNamespaces = []string{"team/protected"}
+	})))
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	diff := func(t *testing.T, namespace, payload string) *httptest.ResponseRecorder {
+		req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules-diff/"+namespace, strings.NewReader(payload), userID)
+		req = mux.SetURLVars(req, map[string]string{"namespace": namespace})
+		w := httptest.NewRecorder()
+		a.DiffRuleGroup(w, req)
+		return w
+	}
+
+	parseDiff := func(t *testing.T, w *httptest.ResponseRecorder) RuleGroupDiff {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var d RuleGroupDiff
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &d))
+		return d
+	}
+
+	t.Run("existing rule group", func(t *testing.T) {
+		w := diff(t, "namespace1", `
+name: group1
+interval: 30s
+rules:
+- record: kept_rule
+  expr: up
+- record: modified_rule
+  expr: up == 2
+- alert: AddedAlert
+  expr: up > 1
+`)
+		d := parseDiff(t, w)
+		assert.Empty(t, w.Header().Get(ProtectedNamespacesHeader))
+
+		assert.Equal(t, "namespace1", d.Namespace)
+		assert.Equal(t, "group1", d.Group)
+		assert.True(t, d.Exists)
+		assert.Equal(t, []string{"interval"}, d.ChangedFields)
+
+		require.Len(t, d.Added, 1)
+		assert.Equal(t, "AddedAlert", d.Added[0].Alert)
+		require.Len(t, d.Removed, 1)
+		assert.Equal(t, "RemovedAlert", d.Removed[0].Alert)
+		require.Len(t, d.Modified, 1)
+		assert.Equal(t, "up == 1", d.Modified[0].Before.Expr)
+		assert.Equal(t, "up == 2", d.Modified[0].After.Expr)
+
+		// Nothing is written.
+		stored, err := store.GetRuleGroup(t.Context(), userID, "namespace1", "group1")
+		require.NoError(t, err)
+		assert.Equal(t, storedGroup, stored)
+	})
+
+	t.Run("unchanged rule group", func(t *testing.T) {
+		d := parseDiff(t, diff(t, "namespace1", `
+name: group1
+interval: 1m
+rules:
+- record: kept_rule
+  expr: up
+- record: modified_rule
+  expr: up == 1
+- alert: RemovedAlert
+  expr: up == 0
+`))
+		assert.True(t, d.Exists)
+		assert.Empty(t, d.ChangedFields)
+		assert.Empty(t, d.Added)
+		assert.Empty(t, d.Removed)
+		assert.Empty(t, d.Modified)
+	})
+
+	t.Run("rule group not stored", func(t *testing.T) {
+		d := parseDiff(t, diff(t, "namespace1", `
+name: new_group
+rules:
+- record: new_rule
+  expr: up
+`))
+		assert.False(t, d.Exists)
+		assert.Equal(t, []rulefmt.Rule{{Record: "new_rule", Expr: "up"}}, d.Added)
+		assert.Empty(t, d.Removed)
+		assert.Empty(t, d.Modified)
+	})
+
+	t.Run("rules with the same name are matched by position", func(t *testing.T) {
+		d := parseDiff(t, diff(t, "namespace1", `
+name: group1
+interval: 1m
+rules:
+- record: kept_rule
+  expr: up
+- record: kept_rule
+  expr: up
+- record: modified_rule
+  expr: up == 1
+- alert: RemovedAlert
+  expr: up == 0
+`))
+		require.Len(t, d.Added, 1)
+		assert.Equal(t, "kept_rule", d.Added[0].Record)
+		assert.Empty(t, d.Removed)
+		assert.Empty(t, d.Modified)
+	})
+
+	t.Run("protected namespace", func(t *testing.T) {
+		w := diff(t, "team/protected", `
+name: group1
+interval: 1m
+rules:
+- record: kept_rule
+  expr: up
+`)
+		d := parseDiff(t, w)
+		assert.True(t, d.Exists)
+		assert.Equal(t, "team/protected", w.Header().Get(ProtectedNamespacesHeader))
+	})
+
+	t.Run("invalid rule group", func(t *testing.T) {
+		w := diff(t, "namespace1", `
+name: group1
+rules:
+- record: invalid rule name
+  expr: up
+`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
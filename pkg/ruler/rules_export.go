@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const (
+	rulesExportContentType      = "application/gzip"
+	rulesExportManifestFilename = "manifest.yaml"
+	rulesExportNamespacesDir    = "namespaces"
+)
+
+// rulesExportManifest describes the content of a rules export archive.
+type rulesExportManifest struct {
+	Tenant     string                 `yaml:"tenant"`
+	ExportedAt time.Time              `yaml:"exported_at"`
+	Namespaces []rulesExportNamespace `yaml:"namespaces"`
+}
+
+type rulesExportNamespace struct {
+	Name      string `yaml:"name"`
+	File      string `yaml:"file"`
+	Protected bool   `yaml:"protected"`
+}
+
+// rulesExportNamespaceFile returns the path, within the archive, of the file storing the rule groups of the namespace.
+// The namespace is escaped, because it can contain any character.
+func rulesExportNamespaceFile(namespace string) string {
+	return path.Join(rulesExportNamespacesDir, url.PathEscape(namespace)+".yaml")
+}
+
+// ExportRules returns all the rule groups of the tenant, across all namespaces, as a tar.gz archive. The archive contains
+// a manifest, listing the namespaces and whether they're protected, and a YAML file for each namespace, in the same
+// format accepted by Prometheus. The archive is streamed one namespace at a time, so rule groups of different
+// namespaces are never loaded at the same time.
+func (a *API) ExportRules(w http.ResponseWriter, req *http.Request) {
+	logger, ctx := spanlogger.New(req.Context(), a.logger, tracer, "API.ExportRules")
+	defer logger.Finish()
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondInvalidRequest(logger, w, errNoValidOrgIDFound.Error())
+		return
+	}
+
+	// Disable any caching when listing rule groups, like the list rules API does, because the export is expected
+	// to be strongly consistent.
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, "", rulestore.WithCacheDisabled())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgsByNamespace := map[string]rulespb.RuleGroupList{}
+	for _, rg := range rgs {
+		rgsByNamespace[rg.Namespace] = append(rgsByNamespace[rg.Namespace], rg)
+	}
+
+	manifest := rulesExportManifest{Tenant: userID, ExportedAt: time.Now().UTC()}
+	for _, namespace := range slices.Sorted(maps.Keys(rgsByNamespace)) {
+		manifest.Namespaces = append(manifest.Namespaces, rulesExportNamespace{
+			Name:      namespace,
+			File:      rulesExportNamespaceFile(namespace),
+			Protected: a.ruler.IsNamespaceProtected(userID, namespace),
+		})
+	}
+
+	w.Header().Set("Content-Type", rulesExportContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "rules-" + userID + ".tar.gz"}))
+
+	var (
+		gzw        = gzip.NewWriter(w)
+		tw         = tar.NewWriter(gzw)
+		flusher, _ = w.(http.Flusher)
+	)
+
+	// If the export fails after the response has been partially sent, the error can't be reported to the client.
+	// In this case, the archive isn't closed, so that the client gets a truncated archive instead of a valid one
+	// missing some namespaces.
+	if err := writeRulesExportFile(tw, rulesExportManifestFilename, manifest); err != nil {
+		level.Error(logger).Log("msg", "error writing rules export manifest", "user", userID, "err", err)
+		return
+	}
+
+	numGroups := 0
+	for _, ns := range manifest.Namespaces {
+		nsRuleGroups := rgsByNamespace[ns.Name]
+
+		missing, _, err := a.loadRuleGroupsInBatches(ctx, userID, nsRuleGroups, false)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to load rule groups while exporting rules", "user", userID, "namespace", ns.Name, "err", err)
+			return
+		}
+
+		// Rule groups missing when loading them could have been deleted after listing the storage: they're skipped,
+		// like in the list rules API.
+		missingLookup := make(map[string]struct{}, len(missing))
+		for _, rg := range missing {
+			missingLookup[rg.GetName()] = struct{}{}
+		}
+
+		content := rulefmt.RuleGroups{Groups: make([]rulefmt.RuleGroup, 0, len(nsRuleGroups))}
+		for _, rg := range nsRuleGroups {
+			if _, isMissing := missingLookup[rg.GetName()]; !isMissing {
+				content.Groups = append(content.Groups, rulespb.FromProto(rg))
+			}
+		}
+
+		if err := writeRulesExportFile(tw, ns.File, content); err != nil {
+			level.Error(logger).Log("msg", "error writing rules export namespace", "user", userID, "namespace", ns.Name, "err", err)
+			return
+		}
+		numGroups += len(content.Groups)
+
+		// Release the loaded rule groups as soon as they've been written.
+		delete(rgsByNamespace, ns.Name)
+
+		if err := gzw.Flush(); err == nil && flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		level.Error(logger).Log("msg", "error closing rules export archive", "user", userID, "err", err)
+		return
+	}
+	if err := gzw.Close(); err != nil {
+		level.Error(logger).Log("msg", "error closing rules export archive", "user", userID, "err", err)
+		return
+	}
+
+	level.Debug(logger).Log("msg", "exported rule groups", "userID", userID, "num_namespaces", len(manifest.Namespaces), "num_groups", numGroups)
+}
+
+// writeRulesExportFile writes the input content, marshalled as YAML, as a file of the archive.
+func writeRulesExportFile(tw *tar.Writer, name string, content any) error {
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestRuler_ExportRules(t *testing.T) {
+	const userID = "user1"
+
+	newGroup := func(user, namespace, name, expr string) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{
+			Name:      name,
+			Namespace: namespace,
+			User:      user,
+			Rules:     []*rulespb.RuleDesc{createRecordingRule("UP_RULE", expr)},
+			Interval:  time.Minute,
+		}
+	}
+
+	cfg := defaultRulerConfig(t)
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		userID: {
+			newGroup(userID, "namespace1", "group1", "up"),
+			newGroup(userID, "namespace1", "group2", "up == 1"),
+			newGroup(userID, "team/namespace2", "group1", "up == 0"),
+		},
+		"user2": {
+			newGroup("user2", "namespace3", "group1", "up"),
+		},
+	})
+
+	r := prepareRuler(t, cfg, store, withStart(), withLimits(validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+		defaults.RulerProtectedNamespaces = []string{"team/namespace2"}
+	})))
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules-export", nil, userID)
+	w := httptest.NewRecorder()
+	a.ExportRules(w, req)
+
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+	require.Equal(t, `attachment; filename=rules-user1.tar.gz`, resp.Header.Get("Content-Disposition"))
+
+	files := readTarGz(t, resp.Body)
+	require.Len(t, files, 3)
+
+	var manifest rulesExportManifest
+	require.NoError(t, yaml.Unmarshal(files["manifest.yaml"], &manifest))
+	assert.Equal(t, userID, manifest.Tenant)
+	assert.Equal(t, []rulesExportNamespace{
+		{Name: "namespace1", File: "namespaces/namespace1.yaml"},
+		{Name: "team/namespace2", File: "namespaces/team%2Fnamespace2.yaml", Protected: true},
+	}, manifest.Namespaces)
+
+	readGroups := func(file string) []rulefmt.RuleGroup {
+		var groups rulefmt.RuleGroups
+		require.NoError(t, yaml.Unmarshal(files[file], &groups))
+		return groups.Groups
+	}
+
+	namespace1 := readGroups("namespaces/namespace1.yaml")
+	require.Len(t, namespace1, 2)
+	assert.Equal(t, "group1", namespace1[0].Name)
+	assert.Equal(t, "up", namespace1[0].Rules[0].Expr)
+	assert.Equal(t, "group2", namespace1[1].Name)
+	assert.Equal(t, "up == 1", namespace1[1].Rules[0].Expr)
+
+	namespace2 := readGroups("namespaces/team%2Fnamespace2.yaml")
+	require.Len(t, namespace2, 1)
+	assert.Equal(t, "up == 0", namespace2[0].Rules[0].Expr)
+}
+
+func TestRuler_ExportRules_NoRuleGroups(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	r := prepareRuler(t, cfg, newMockRuleStore(nil), withStart())
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	w := httptest.NewRecorder()
+	a.ExportRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules-export", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	files := readTarGz(t, w.Body)
+	require.Len(t, files, 1)
+
+	var manifest rulesExportManifest
+	require.NoError(t, yaml.Unmarshal(files["manifest.yaml"], &manifest))
+	assert.Empty(t, manifest.Namespaces)
+}
+
+// readTarGz returns the content of the files in the input tar.gz archive, by file name.
+func readTarGz(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+
+	gzr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = content
+	}
+
+	require.NoError(t, gzr.Close())
+	return files
+}
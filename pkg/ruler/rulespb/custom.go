@@ -5,7 +5,14 @@
 
 package rulespb
 
-import "github.com/prometheus/prometheus/model/rulefmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+)
 
 // RuleGroupList contains a set of rule groups
 type RuleGroupList []*RuleGroupDesc
@@ -24,3 +31,53 @@ func (l RuleGroupList) Formatted() map[string][]rulefmt.RuleGroup {
 	}
 	return ruleMap
 }
+
+// RuleFile is a set of formatted rule groups of a namespace, in the rule file format used to upload them.
+type RuleFile struct {
+	Namespace string              `yaml:"namespace"`
+	Groups    []rulefmt.RuleGroup `yaml:"groups"`
+}
+
+// FormattedByFile returns the rule group list as a list of rule files, one per namespace,
+// sorted by namespace. The rule groups of each namespace keep their order in the list.
+func (l RuleGroupList) FormattedByFile() []RuleFile {
+	files := []RuleFile{}
+	for namespace, groups := range l.Formatted() {
+		files = append(files, RuleFile{Namespace: namespace, Groups: groups})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Namespace < files[j].Namespace
+	})
+	return files
+}
+
+// Checksums returns the checksum of each rule group in the list, mapped by namespace and rule group name.
+func (l RuleGroupList) Checksums() (map[string]map[string]string, error) {
+	checksums := map[string]map[string]string{}
+	for _, g := range l {
+		checksum, err := Checksum(g)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := checksums[g.Namespace]; !exists {
+			checksums[g.Namespace] = map[string]string{}
+		}
+		checksums[g.Namespace][g.Name] = checksum
+	}
+	return checksums, nil
+}
+
+// Checksum returns a hex-encoded SHA-256 hash of the rule group content. The hash is computed
+// over the YAML serialization of the formatted rule group, so it's stable across calls and only
+// changes when the rule group returned by the configuration API changes.
+func Checksum(g *RuleGroupDesc) (string, error) {
+	formatted := FromProto(g)
+	d, err := yaml.Marshal(&formatted)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(d)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb" //lint:ignore faillint allowed to import other protobuf
+)
+
+func TestChecksum(t *testing.T) {
+	newGroup := func(expr string) *RuleGroupDesc {
+		return &RuleGroupDesc{
+			Name:      "group",
+			Namespace: "namespace",
+			User:      "user",
+			Interval:  time.Minute,
+			Rules: []*RuleDesc{{
+				Record: "UP_RULE",
+				Expr:   expr,
+				Labels: []mimirpb.LabelAdapter{{Name: "b", Value: "2"}, {Name: "a", Value: "1"}},
+			}},
+		}
+	}
+
+	original, err := Checksum(newGroup("up"))
+	require.NoError(t, err)
+	assert.Len(t, original, 64)
+
+	// The checksum is stable across calls.
+	same, err := Checksum(newGroup("up"))
+	require.NoError(t, err)
+	assert.Equal(t, original, same)
+
+	// The checksum changes when the rule group content changes.
+	changed, err := Checksum(newGroup("count(up)"))
+	require.NoError(t, err)
+	assert.NotEqual(t, original, changed)
+}
+
+func TestRuleGroupList_Checksums(t *testing.T) {
+	list := RuleGroupList{
+		{Name: "group1", Namespace: "namespace1", Interval: time.Minute},
+		{Name: "group2", Namespace: "namespace1", Interval: 2 * time.Minute},
+		{Name: "group1", Namespace: "namespace2", Interval: time.Minute},
+	}
+
+	checksums, err := list.Checksums()
+	require.NoError(t, err)
+	require.Len(t, checksums, 2)
+	require.Len(t, checksums["namespace1"], 2)
+	require.Len(t, checksums["namespace2"], 1)
+
+	// Rule groups with the same content have the same checksum, whatever the namespace.
+	assert.Equal(t, checksums["namespace1"]["group1"], checksums["namespace2"]["group1"])
+	assert.NotEqual(t, checksums["namespace1"]["group1"], checksums["namespace1"]["group2"])
+}
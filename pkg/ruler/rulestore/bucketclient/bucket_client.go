@@ -11,8 +11,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -161,6 +163,58 @@ func (b *BucketRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context,
 	return groupList, nil
 }
 
+// ListRuleGroupsWithModTime implements rulestore.ModifiedRuleGroupsLister.
+func (b *BucketRuleStore) ListRuleGroupsWithModTime(ctx context.Context, userID string, namespace string, opts ...rulestore.Option) (rulespb.RuleGroupList, []time.Time, error) {
+	logger, ctx := spanlogger.New(ctx, b.logger, tracer, "BucketRuleStore.ListRuleGroupsWithModTime")
+	defer logger.Finish()
+
+	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	if !slices.Contains(userBucket.SupportedIterOptions(), objstore.UpdatedAt) {
+		return nil, nil, rulestore.ErrModTimeNotSupported
+	}
+
+	groupList := rulespb.RuleGroupList{}
+	var modTimes []time.Time
+
+	options := rulestore.CollectOptions(opts...)
+	if options.DisableCache {
+		ctx = bucketcache.WithCacheLookupEnabled(ctx, false)
+	}
+
+	prefix := ""
+	if namespace != "" {
+		prefix = getNamespacePrefix(namespace)
+	}
+
+	err := userBucket.IterWithAttributes(ctx, prefix, func(attrs objstore.IterObjectAttributes) error {
+		namespace, group, err := parseRuleGroupObjectKey(attrs.Name)
+		if err != nil {
+			level.Warn(logger).Log("msg", "invalid rule group object key found while listing rule groups", "user", userID, "key", attrs.Name, "err", err)
+
+			// Do not fail just because of a spurious item in the bucket.
+			return nil
+		}
+
+		lastModified, ok := attrs.LastModified()
+		if !ok {
+			return rulestore.ErrModTimeNotSupported
+		}
+
+		groupList = append(groupList, &rulespb.RuleGroupDesc{
+			User:      userID,
+			Namespace: namespace,
+			Name:      group,
+		})
+		modTimes = append(modTimes, lastModified)
+		return nil
+	}, objstore.WithRecursiveIter(), objstore.WithUpdatedAt())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groupList, modTimes, nil
+}
+
 // LoadRuleGroups implements rules.RuleStore.
 func (b *BucketRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (missing rulespb.RuleGroupList, err error) {
 	logger, ctx := spanlogger.New(ctx, b.logger, tracer, "BucketRuleStore.LoadRuleGroups")
@@ -95,6 +95,75 @@ func TestListRules(t *testing.T) {
 	}
 }
 
+func TestListRuleGroupsWithModTime(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	setRuleGroup := func(namespace, name string) {
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", namespace, rulespb.ToProto("user1", namespace, rulefmt.RuleGroup{Name: name})))
+	}
+
+	setRuleGroup("hello", "first testGroup")
+	setRuleGroup("world", "another namespace testGroup")
+
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	setRuleGroup("hello", "second testGroup")
+	setRuleGroup("world", "another namespace testGroup")
+
+	modifiedSince := func(groups rulespb.RuleGroupList, modTimes []time.Time) rulespb.RuleGroupList {
+		require.Len(t, modTimes, len(groups))
+
+		modified := rulespb.RuleGroupList{}
+		for i, g := range groups {
+			if modTimes[i].After(since) {
+				modified = append(modified, g)
+			}
+		}
+		return modified
+	}
+
+	{
+		groups, modTimes, err := rs.ListRuleGroupsWithModTime(ctx, "user1", "")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
+			{User: "user1", Namespace: "hello", Name: "first testGroup"},
+			{User: "user1", Namespace: "hello", Name: "second testGroup"},
+			{User: "user1", Namespace: "world", Name: "another namespace testGroup"},
+		}, groups)
+		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
+			{User: "user1", Namespace: "hello", Name: "second testGroup"},
+			{User: "user1", Namespace: "world", Name: "another namespace testGroup"},
+		}, modifiedSince(groups, modTimes))
+	}
+
+	{
+		groups, modTimes, err := rs.ListRuleGroupsWithModTime(ctx, "user1", "hello")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
+			{User: "user1", Namespace: "hello", Name: "second testGroup"},
+		}, modifiedSince(groups, modTimes))
+	}
+
+	{
+		rs := NewBucketRuleStore(noUpdatedAtBucket{Bucket: bkt}, nil, log.NewNopLogger())
+		_, _, err := rs.ListRuleGroupsWithModTime(ctx, "user1", "")
+		require.ErrorIs(t, err, rulestore.ErrModTimeNotSupported)
+	}
+}
+
+// noUpdatedAtBucket is a bucket which doesn't support the objstore.UpdatedAt iter option.
+type noUpdatedAtBucket struct {
+	objstore.Bucket
+}
+
+func (noUpdatedAtBucket) SupportedIterOptions() []objstore.IterOptionType {
+	return []objstore.IterOptionType{objstore.Recursive}
+}
+
 func TestLoadRules(t *testing.T) {
 	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
 	groups := []testGroup{
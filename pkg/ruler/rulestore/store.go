@@ -8,6 +8,7 @@ package rulestore
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -19,6 +20,8 @@ var (
 	ErrGroupNamespaceNotFound = errors.New("group namespace does not exist")
 	// ErrUserNotFound is returned if the user does not currently exist
 	ErrUserNotFound = errors.New("no rule groups found for user")
+	// ErrModTimeNotSupported is returned if the rule store can't tell when rule groups have been modified
+	ErrModTimeNotSupported = errors.New("rule groups modification time is not supported by the rule store")
 )
 
 // Options are per-call options that can be used to modify the behavior of RuleStore methods.
@@ -86,3 +89,12 @@ type RuleStore interface {
 	// If namespace is empty, deletes all rule groups for user.
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
 }
+
+// ModifiedRuleGroupsLister is an optional interface implemented by the rule stores which can tell when rule groups
+// have been modified.
+type ModifiedRuleGroupsLister interface {
+	// ListRuleGroupsWithModTime is like RuleStore.ListRuleGroupsForUserAndNamespace, but also returns the
+	// modification time of each rule group, in the same order as the rule groups.
+	// It returns ErrModTimeNotSupported if the modification time of rule groups is not available.
+	ListRuleGroupsWithModTime(ctx context.Context, userID string, namespace string, opts ...Option) (rulespb.RuleGroupList, []time.Time, error)
+}
@@ -6,8 +6,10 @@
 package azure
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/go-kit/log"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/azure"
@@ -35,3 +37,12 @@ func newBucketClient(cfg Config, name string, logger log.Logger, factory func(lo
 
 	return factory(logger, bucketConfig, name, nil)
 }
+
+// IsThrottlingErr returns whether the error has been returned by Azure because the request has been rate limited.
+func IsThrottlingErr(err error) bool {
+	var azureErr *azcore.ResponseError
+	if !errors.As(err, &azureErr) {
+		return false
+	}
+	return azureErr.StatusCode == http.StatusTooManyRequests || azureErr.StatusCode == http.StatusServiceUnavailable
+}
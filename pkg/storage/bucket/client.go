@@ -202,6 +202,12 @@ func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger,
 	return instrumentedClient, nil
 }
 
+// IsThrottlingErr returns whether the error has been returned by any of the supported object storage backends
+// because the request has been rate limited.
+func IsThrottlingErr(err error) bool {
+	return s3.IsThrottlingErr(err) || gcs.IsThrottlingErr(err) || azure.IsThrottlingErr(err) || swift.IsThrottlingErr(err)
+}
+
 func bucketWithMetrics(bucketClient objstore.Bucket, name string, reg prometheus.Registerer) objstore.Bucket {
 	if reg == nil {
 		return bucketClient
@@ -7,16 +7,23 @@ package bucket
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/grafana/dskit/flagext"
+	"github.com/minio/minio-go/v7"
+	"github.com/ncw/swift"
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
@@ -235,3 +242,89 @@ func TestNewPrefixedBucketClient(t *testing.T) {
 		assert.Equal(t, "content", string(b))
 	})
 }
+
+func TestIsThrottlingErr(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"generic error": {
+			err:      errors.New("failed"),
+			expected: false,
+		},
+		"S3 too many requests": {
+			err:      minio.ErrorResponse{Code: "TooManyRequests", StatusCode: http.StatusTooManyRequests},
+			expected: true,
+		},
+		"S3 slow down": {
+			err:      minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable},
+			expected: true,
+		},
+		"S3 access denied": {
+			err:      minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden},
+			expected: false,
+		},
+		"GCS too many requests": {
+			err:      &googleapi.Error{Code: http.StatusTooManyRequests},
+			expected: true,
+		},
+		"GCS service unavailable": {
+			err:      &googleapi.Error{Code: http.StatusServiceUnavailable},
+			expected: true,
+		},
+		"GCS not found": {
+			err:      &googleapi.Error{Code: http.StatusNotFound},
+			expected: false,
+		},
+		"Azure too many requests": {
+			err:      &azcore.ResponseError{ErrorCode: "TooManyRequests", StatusCode: http.StatusTooManyRequests},
+			expected: true,
+		},
+		"Azure server busy": {
+			err:      &azcore.ResponseError{ErrorCode: "ServerBusy", StatusCode: http.StatusServiceUnavailable},
+			expected: true,
+		},
+		"Azure blob not found": {
+			err:      &azcore.ResponseError{ErrorCode: "BlobNotFound", StatusCode: http.StatusNotFound},
+			expected: false,
+		},
+		"Swift too many requests": {
+			err:      swift.TooManyRequests,
+			expected: true,
+		},
+		"Swift rate limit": {
+			err:      swift.RateLimit,
+			expected: true,
+		},
+		"Swift service unavailable": {
+			err:      &swift.Error{StatusCode: http.StatusServiceUnavailable, Text: "Service Unavailable"},
+			expected: true,
+		},
+		"Swift object not found": {
+			err:      swift.ObjectNotFound,
+			expected: false,
+		},
+		"wrapped S3 error": {
+			err:      pkgerrors.Wrap(minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}, "iter"),
+			expected: true,
+		},
+		"wrapped GCS error": {
+			err:      pkgerrors.Wrap(&googleapi.Error{Code: http.StatusTooManyRequests}, "iter"),
+			expected: true,
+		},
+		"wrapped Azure error": {
+			err:      pkgerrors.Wrap(&azcore.ResponseError{ErrorCode: "ServerBusy", StatusCode: http.StatusServiceUnavailable}, "iter"),
+			expected: true,
+		},
+		"wrapped Swift error": {
+			err:      pkgerrors.Wrap(swift.RateLimit, "iter"),
+			expected: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, IsThrottlingErr(testData.err))
+		})
+	}
+}
@@ -7,10 +7,13 @@ package gcs
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"github.com/go-kit/log"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/gcs"
+	"google.golang.org/api/googleapi"
 )
 
 // NewBucketClient creates a new GCS bucket client
@@ -22,3 +25,12 @@ func NewBucketClient(ctx context.Context, cfg Config, name string, logger log.Lo
 	}
 	return gcs.NewBucketWithConfig(ctx, logger, bucketConfig, name, nil)
 }
+
+// IsThrottlingErr returns whether the error has been returned by GCS because the request has been rate limited.
+func IsThrottlingErr(err error) bool {
+	var gcsErr *googleapi.Error
+	if !errors.As(err, &gcsErr) {
+		return false
+	}
+	return gcsErr.Code == http.StatusTooManyRequests || gcsErr.Code == http.StatusServiceUnavailable
+}
@@ -6,7 +6,11 @@
 package s3
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/go-kit/log"
+	"github.com/minio/minio-go/v7"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/s3"
 )
@@ -73,3 +77,12 @@ func newS3Config(cfg Config) (s3.Config, error) {
 		STSEndpoint: cfg.STSEndpoint,
 	}, nil
 }
+
+// IsThrottlingErr returns whether the error has been returned by S3 because the request has been rate limited.
+func IsThrottlingErr(err error) bool {
+	var s3Err minio.ErrorResponse
+	if !errors.As(err, &s3Err) {
+		return false
+	}
+	return s3Err.StatusCode == http.StatusTooManyRequests || s3Err.StatusCode == http.StatusServiceUnavailable
+}
@@ -6,7 +6,11 @@
 package swift
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/go-kit/log"
+	ncwswift "github.com/ncw/swift"
 	"github.com/prometheus/common/model"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/objstore/providers/swift"
@@ -52,3 +56,18 @@ func NewBucketClient(cfg Config, _ string, logger log.Logger) (objstore.Bucket,
 
 	return swift.NewContainer(logger, serialized, nil)
 }
+
+// IsThrottlingErr returns whether the error has been returned by Swift because the request has been rate limited.
+// Besides the standard status codes, Swift returns the non-standard 498 status code when rate limiting.
+func IsThrottlingErr(err error) bool {
+	var swiftErr *ncwswift.Error
+	if !errors.As(err, &swiftErr) {
+		return false
+	}
+	switch swiftErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, ncwswift.RateLimit.StatusCode:
+		return true
+	default:
+		return false
+	}
+}
@@ -659,6 +659,9 @@ type MetaCache struct {
 	minCompactionLevel int
 	minSources         int
 
+	// shadow controls whether the cache only tracks the IDs of the *Meta objects, without caching them.
+	shadow bool
+
 	lru    *lru.Cache[ulid.ULID, *Meta]
 	hits   atomic.Int64
 	misses atomic.Int64
@@ -681,10 +684,24 @@ func NewMetaCache(maxSize, minCompactionLevel, minSources int) *MetaCache {
 	}
 }
 
+// NewShadowMetaCache creates new *MetaCache which never returns any *Meta object, but tracks the hits and misses a
+// *MetaCache created by NewMetaCache with the same parameters would have had. Only the IDs of the *Meta objects are
+// kept in memory.
+func NewShadowMetaCache(maxSize, minCompactionLevel, minSources int) *MetaCache {
+	mc := NewMetaCache(maxSize, minCompactionLevel, minSources)
+	mc.shadow = true
+	return mc
+}
+
 func (mc *MetaCache) MaxSize() int {
 	return mc.maxSize
 }
 
+// Shadow returns whether the cache has been created by NewShadowMetaCache.
+func (mc *MetaCache) Shadow() bool {
+	return mc.shadow
+}
+
 func (mc *MetaCache) Put(meta *Meta) {
 	if meta == nil {
 		return
@@ -698,6 +715,10 @@ func (mc *MetaCache) Put(meta *Meta) {
 		return
 	}
 
+	if mc.shadow {
+		mc.lru.Add(meta.ULID, nil)
+		return
+	}
 	mc.lru.Add(meta.ULID, meta)
 }
 
@@ -711,11 +732,15 @@ func (mc *MetaCache) Get(id ulid.ULID) *Meta {
 	return val
 }
 
+// Stats returns the number of items in the cache, their size in bytes, and the number of hits and misses.
+// The size of the *Meta objects is not included for a shadow cache, which doesn't keep them.
 func (mc *MetaCache) Stats() (items int, bytesSize int64, hits, misses int) {
 	for _, m := range mc.lru.Values() {
 		items++
 		bytesSize += sizeOfUlid // for a key
-		bytesSize += MetaBytesSize(m)
+		if m != nil {
+			bytesSize += MetaBytesSize(m)
+		}
 	}
 	return items, bytesSize, int(mc.hits.Load()), int(mc.misses.Load())
 }
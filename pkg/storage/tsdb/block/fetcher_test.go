@@ -626,6 +626,37 @@ func TestMetaCache(t *testing.T) {
 	require.Equal(t, 3, misses)
 }
 
+func TestShadowMetaCache(t *testing.T) {
+	meta1 := Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:       ulid.MustNew(ulid.Now(), crypto_rand.Reader),
+			Compaction: tsdb.BlockMetaCompaction{Level: 5, Sources: generateULIDs(10)},
+		},
+	}
+
+	levelTooLow := Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:       ulid.MustNew(ulid.Now(), crypto_rand.Reader),
+			Compaction: tsdb.BlockMetaCompaction{Level: 2, Sources: generateULIDs(10)},
+		},
+	}
+
+	cache := NewShadowMetaCache(10, 3, 5)
+	require.True(t, cache.Shadow())
+	cache.Put(&meta1)
+	cache.Put(&levelTooLow)
+
+	// The shadow cache never returns any meta, but counts a hit for the ones a cache would have returned.
+	require.Nil(t, cache.Get(meta1.ULID))
+	require.Nil(t, cache.Get(levelTooLow.ULID))
+
+	items, size, hits, misses := cache.Stats()
+	require.Equal(t, 1, items)
+	require.Equal(t, sizeOfUlid, size)
+	require.Equal(t, 1, hits)
+	require.Equal(t, 1, misses)
+}
+
 func generateULIDs(count int) []ulid.ULID {
 	result := make([]ulid.ULID, 0, count)
 	for i := 0; i < count; i++ {
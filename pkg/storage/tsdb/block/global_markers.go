@@ -86,3 +86,24 @@ func ListBlockDeletionMarks(ctx context.Context, bkt objstore.BucketReader) (map
 
 	return discovered, errors.Wrap(err, "list block deletion marks")
 }
+
+// ListBlockNoCompactMarks looks for block no-compact marks in the global markers location
+// and returns a map containing all blocks having a no-compact mark.
+func ListBlockNoCompactMarks(ctx context.Context, bkt objstore.BucketReader) (map[ulid.ULID]struct{}, error) {
+	discovered := map[ulid.ULID]struct{}{}
+
+	// Find all markers in the storage.
+	err := bkt.Iter(ctx, MarkersPathname+"/", func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if blockID, ok := IsNoCompactMarkFilename(path.Base(name)); ok {
+			discovered[blockID] = struct{}{}
+		}
+
+		return nil
+	})
+
+	return discovered, errors.Wrap(err, "list block no-compact marks")
+}
@@ -94,3 +94,35 @@ func TestListBlockDeletionMarks(t *testing.T) {
 		}, actualMarks)
 	})
 }
+
+func TestListBlockNoCompactMarks(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+	)
+
+	t.Run("should return an empty map on empty bucket", func(t *testing.T) {
+		bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		actualMarks, actualErr := ListBlockNoCompactMarks(ctx, bkt)
+		require.NoError(t, actualErr)
+		assert.Empty(t, actualMarks)
+	})
+
+	t.Run("should return a map with the blocks having a no-compact mark", func(t *testing.T) {
+		bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		require.NoError(t, bkt.Upload(ctx, NoCompactMarkFilepath(block1), strings.NewReader("{}")))
+		require.NoError(t, bkt.Upload(ctx, DeletionMarkFilepath(block2), strings.NewReader("{}")))
+		require.NoError(t, bkt.Upload(ctx, NoCompactMarkFilepath(block3), strings.NewReader("{}")))
+
+		actualMarks, actualErr := ListBlockNoCompactMarks(ctx, bkt)
+		require.NoError(t, actualErr)
+		assert.Equal(t, map[ulid.ULID]struct{}{
+			block1: {},
+			block3: {},
+		}, actualMarks)
+	})
+}
@@ -66,6 +66,9 @@ type HealthStats struct {
 	// OutOfOrderLabels represents the number of postings that contained out
 	// of order labels, a bug present in Prometheus 2.8.0 and below.
 	OutOfOrderLabels int
+
+	// SymbolTableSize is the size in bytes of the symbol table of the index.
+	SymbolTableSize uint64
 }
 
 // OutOfOrderLabelsErr returns an error if the HealthStats object indicates
@@ -162,6 +165,8 @@ func GatherBlockHealthStats(ctx context.Context, logger log.Logger, blockDir str
 	}
 	defer runutil.CloseWithErrCapture(&err, r, "gather index issue file reader")
 
+	stats.SymbolTableSize = r.SymbolTableSize()
+
 	n, v := index.AllPostingsKey()
 	p, err := r.Postings(ctx, n, v)
 	if err != nil {
@@ -76,6 +76,11 @@ const (
 	OutOfOrderChunksNoCompactReason = "block-index-out-of-order-chunk"
 	// CriticalNoCompactReason is a reason of to no compact block that has some critical issue (e.g. corrupted index).
 	CriticalNoCompactReason = "critical"
+	// FutureTimestampsNoCompactReason is a reason to not compact a block whose samples are too far in the future, e.g. because of clock skew.
+	FutureTimestampsNoCompactReason = "future-timestamps"
+	// AnomalousBlockNoCompactReason is a reason to not compact a block whose samples start too far after the ones of
+	// the other compacted blocks, which may indicate an ingestion anomaly to be reviewed.
+	AnomalousBlockNoCompactReason = "anomalous-block"
 )
 
 // NoCompactMark marker stores reason of block being excluded from compaction if needed.
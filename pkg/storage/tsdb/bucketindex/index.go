@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -85,6 +86,19 @@ type Block struct {
 	SegmentsFormat string `json:"segments_format,omitempty"`
 	SegmentsNum    int    `json:"segments_num,omitempty"`
 
+	// SizeBytes is the total size of the block files listed in the block meta.json. It's zero if the block
+	// meta.json doesn't list the files, or if the block has been added to the index before the size was tracked
+	// and hasn't been backfilled yet.
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+
+	// NumSeries is the number of series in the block, as listed in the block meta.json. It's zero if the block
+	// has been added to the index before the number of series was tracked and hasn't been backfilled yet.
+	NumSeries uint64 `json:"num_series,omitempty"`
+
+	// HasStats is whether SizeBytes and NumSeries have been read from the block meta.json. It's false if the block
+	// has been added to the index before they were tracked and hasn't been backfilled yet.
+	HasStats bool `json:"has_stats,omitempty"`
+
 	// UploadedAt is a unix timestamp (seconds precision) of when the block has been completed to be uploaded
 	// to the storage.
 	UploadedAt int64 `json:"uploaded_at"`
@@ -99,6 +113,15 @@ type Block struct {
 	// Whether the block was from out of order samples
 	OutOfOrder bool `json:"out_of_order,omitempty"`
 
+	// Sources are the IDs of the blocks uploaded by the ingesters the block has been compacted from, as listed
+	// in the block meta.json, or the block's own ID if the meta.json doesn't list any. It's nil if the block has
+	// been added to the index before the sources were tracked and hasn't been backfilled yet.
+	Sources []ulid.ULID `json:"sources,omitempty"`
+
+	// Whether the block is marked for no-compaction. It's reconciled with the no-compact marks by the
+	// compactor's blocks cleaner, so it may lag behind the marks.
+	NoCompact bool `json:"no_compact,omitempty"`
+
 	// Labels contains the external labels from the block's metadata.
 	Labels map[string]string `json:"labels,omitempty"`
 }
@@ -138,8 +161,9 @@ func (m *Block) ThanosMeta() *block.Meta {
 			MaxTime: m.MaxTime,
 			Version: block.TSDBVersion1,
 			Compaction: tsdb.BlockMetaCompaction{
-				Level: m.CompactionLevel,
-				Hints: compactionHints,
+				Level:   m.CompactionLevel,
+				Sources: slices.Clone(m.Sources),
+				Hints:   compactionHints,
 			},
 		},
 		Thanos: block.ThanosMeta{
@@ -176,20 +200,38 @@ func (m *Block) String() string {
 func BlockFromThanosMeta(meta block.Meta) *Block {
 	segmentsFormat, segmentsNum := detectBlockSegmentsFormat(meta)
 
+	sources := slices.Clone(meta.Compaction.Sources)
+	if len(sources) == 0 {
+		sources = []ulid.ULID{meta.ULID}
+	}
+
 	return &Block{
 		ID:               meta.ULID,
 		MinTime:          meta.MinTime,
 		MaxTime:          meta.MaxTime,
 		SegmentsFormat:   segmentsFormat,
 		SegmentsNum:      segmentsNum,
+		SizeBytes:        blockSizeBytes(meta),
+		NumSeries:        meta.Stats.NumSeries,
+		HasStats:         true,
 		CompactorShardID: meta.Thanos.Labels[mimir_tsdb.CompactorShardIDExternalLabel],
 		Source:           string(meta.Thanos.Source),
 		CompactionLevel:  meta.Compaction.Level,
 		OutOfOrder:       meta.Compaction.FromOutOfOrder(),
+		Sources:          sources,
 		Labels:           maps.Clone(meta.Thanos.Labels),
 	}
 }
 
+// blockSizeBytes returns the total size of the files listed in the block meta.
+func blockSizeBytes(meta block.Meta) int64 {
+	var size int64
+	for _, f := range meta.Thanos.Files {
+		size += f.SizeBytes
+	}
+	return size
+}
+
 func detectBlockSegmentsFormat(meta block.Meta) (string, int) {
 	if num, ok := detectBlockSegmentsFormat1Based6Digits(meta); ok {
 		return SegmentsFormat1Based6Digits, num
@@ -158,6 +158,8 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 			},
 			expected: Block{
+				HasStats:        true,
+				Sources:         []ulid.ULID{blockID},
 				ID:              blockID,
 				MinTime:         10,
 				MaxTime:         20,
@@ -167,6 +169,31 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				CompactionLevel: 1,
 			},
 		},
+		"meta.json with compaction sources": {
+			meta: block.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Compaction: tsdb.BlockMetaCompaction{
+						Level:   2,
+						Sources: []ulid.ULID{ulid.MustNew(2, nil), ulid.MustNew(3, nil)},
+					},
+				},
+				Thanos: block.ThanosMeta{
+					Source: block.SourceType("test"),
+				},
+			},
+			expected: Block{
+				HasStats:        true,
+				ID:              blockID,
+				MinTime:         10,
+				MaxTime:         20,
+				Source:          "test",
+				CompactionLevel: 2,
+				Sources:         []ulid.ULID{ulid.MustNew(2, nil), ulid.MustNew(3, nil)},
+			},
+		},
 		"meta.json with SegmentFiles": {
 			meta: block.Meta{
 				BlockMeta: tsdb.BlockMeta{
@@ -188,6 +215,8 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 			},
 			expected: Block{
+				HasStats:        true,
+				Sources:         []ulid.ULID{blockID},
 				ID:              blockID,
 				MinTime:         10,
 				MaxTime:         20,
@@ -207,20 +236,45 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 				Thanos: block.ThanosMeta{
 					Files: []block.File{
-						{RelPath: "index"},
-						{RelPath: "chunks/000001"},
-						{RelPath: "chunks/000002"},
-						{RelPath: "chunks/000003"},
+						{RelPath: "index", SizeBytes: 100},
+						{RelPath: "chunks/000001", SizeBytes: 1000},
+						{RelPath: "chunks/000002", SizeBytes: 1000},
+						{RelPath: "chunks/000003", SizeBytes: 500},
 						{RelPath: "tombstone"},
 					},
 				},
 			},
 			expected: Block{
+				HasStats:       true,
+				Sources:        []ulid.ULID{blockID},
 				ID:             blockID,
 				MinTime:        10,
 				MaxTime:        20,
 				SegmentsFormat: SegmentsFormat1Based6Digits,
 				SegmentsNum:    3,
+				SizeBytes:      2600,
+			},
+		},
+		"meta.json with Stats": {
+			meta: block.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Stats: tsdb.BlockStats{
+						NumSeries: 1234,
+					},
+				},
+			},
+			expected: Block{
+				HasStats:       true,
+				Sources:        []ulid.ULID{blockID},
+				ID:             blockID,
+				MinTime:        10,
+				MaxTime:        20,
+				SegmentsFormat: SegmentsFormatUnknown,
+				SegmentsNum:    0,
+				NumSeries:      1234,
 			},
 		},
 		"meta.json with external labels, no compactor shard ID": {
@@ -238,9 +292,11 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 			},
 			expected: Block{
-				ID:      blockID,
-				MinTime: 10,
-				MaxTime: 20,
+				HasStats: true,
+				Sources:  []ulid.ULID{blockID},
+				ID:       blockID,
+				MinTime:  10,
+				MaxTime:  20,
 				Labels: map[string]string{
 					"a": "b",
 					"c": "d",
@@ -263,6 +319,8 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 			},
 			expected: Block{
+				HasStats:         true,
+				Sources:          []ulid.ULID{blockID},
 				ID:               blockID,
 				MinTime:          10,
 				MaxTime:          20,
@@ -290,6 +348,8 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				},
 			},
 			expected: Block{
+				HasStats:         true,
+				Sources:          []ulid.ULID{blockID},
 				ID:               blockID,
 				MinTime:          10,
 				MaxTime:          20,
@@ -372,6 +432,7 @@ func TestBlock_ThanosMeta(t *testing.T) {
 				Source:          "test",
 				CompactionLevel: 1,
 				OutOfOrder:      true,
+				Sources:         []ulid.ULID{blockID},
 			},
 			expected: &block.Meta{
 				BlockMeta: tsdb.BlockMeta{
@@ -380,8 +441,9 @@ func TestBlock_ThanosMeta(t *testing.T) {
 					MaxTime: 20,
 					Version: block.TSDBVersion1,
 					Compaction: tsdb.BlockMetaCompaction{
-						Level: 1,
-						Hints: []string{tsdb.CompactionHintFromOutOfOrder},
+						Level:   1,
+						Sources: []ulid.ULID{blockID},
+						Hints:   []string{tsdb.CompactionHintFromOutOfOrder},
 					},
 				},
 				Thanos: block.ThanosMeta{
@@ -6,10 +6,12 @@
 package bucketindex
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/go-kit/log"
@@ -44,16 +46,22 @@ func ReadIndex(ctx context.Context, bkt objstore.Bucket, userID string, cfgProvi
 	}
 	defer runutil.CloseWithLogOnErr(logger, reader, "close bucket index reader")
 
-	// Read all the content.
-	gzipReader, err := gzip.NewReader(reader)
-	if err != nil {
-		return nil, ErrIndexCorrupted
+	// The index is written gzip-compressed, but we also support reading an uncompressed
+	// index, detected by the lack of the gzip header.
+	bufReader := bufio.NewReader(reader)
+	content := io.Reader(bufReader)
+	if isGzip(bufReader) {
+		gzipReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return nil, ErrIndexCorrupted
+		}
+		defer runutil.CloseWithLogOnErr(logger, gzipReader, "close bucket index gzip reader")
+		content = gzipReader
 	}
-	defer runutil.CloseWithLogOnErr(logger, gzipReader, "close bucket index gzip reader")
 
 	// Deserialize it.
 	index := &Index{}
-	d := json.NewDecoder(gzipReader)
+	d := json.NewDecoder(content)
 	if err := d.Decode(index); err != nil {
 		return nil, ErrIndexCorrupted
 	}
@@ -61,6 +69,12 @@ func ReadIndex(ctx context.Context, bkt objstore.Bucket, userID string, cfgProvi
 	return index, nil
 }
 
+// isGzip returns whether the content read by r starts with the gzip header, without consuming it.
+func isGzip(r *bufio.Reader) bool {
+	header, err := r.Peek(2)
+	return err == nil && header[0] == 0x1f && header[1] == 0x8b
+}
+
 // WriteIndex uploads the provided index to the storage.
 func WriteIndex(ctx context.Context, bkt objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, idx *Index) error {
 	bkt = bucket.NewUserBucketClient(userID, bkt, cfgProvider)
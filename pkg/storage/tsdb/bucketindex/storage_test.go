@@ -6,7 +6,9 @@
 package bucketindex
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"path"
 	"strings"
 	"testing"
@@ -67,6 +69,33 @@ func TestReadIndex_ShouldReturnTheParsedIndexOnSuccess(t *testing.T) {
 	assert.Equal(t, expectedIdx, actualIdx)
 }
 
+func TestReadIndex_ShouldReturnTheParsedIndexIfUncompressed(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	// Mock some blocks in the storage.
+	bkt = block.BucketWithGlobalMarkers(bkt)
+	block.MockStorageBlock(t, bkt, userID, 10, 20)
+	block.MockStorageDeletionMark(t, bkt, userID, block.MockStorageBlock(t, bkt, userID, 20, 30))
+
+	// Write the index uncompressed.
+	u := NewUpdater(bkt, userID, nil, 16, 16, logger)
+	expectedIdx, _, err := u.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	content, err := json.Marshal(expectedIdx)
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, IndexCompressedFilename), bytes.NewReader(content)))
+
+	// Read it back and compare.
+	actualIdx, err := ReadIndex(ctx, bkt, userID, nil, logger)
+	require.NoError(t, err)
+	assert.Equal(t, expectedIdx, actualIdx)
+}
+
 func BenchmarkReadIndex(b *testing.B) {
 	const (
 		numBlocks             = 1000
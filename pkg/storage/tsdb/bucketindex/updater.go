@@ -33,6 +33,10 @@ var (
 	errStopIter                   = errors.New("stop iteration")
 )
 
+// maxBackfilledBlocksPerUpdate is the maximum number of blocks, already in the index, whose meta.json is fetched
+// again on each update to backfill the fields added to the index after the blocks have been added to it.
+const maxBackfilledBlocksPerUpdate = 1000
+
 // Updater is responsible to generate an update in-memory bucket index.
 type Updater struct {
 	bkt                           objstore.InstrumentedBucket
@@ -129,6 +133,10 @@ func (w *Updater) updateBlocks(ctx context.Context, old []*Block) (blocks []*Blo
 
 	level.Info(w.logger).Log("msg", "listed all blocks in storage", "newly_discovered", len(discovered), "existing", len(old))
 
+	if err := w.backfillBlocks(ctx, blocks); err != nil {
+		return nil, nil, err
+	}
+
 	// Remaining blocks are new ones and we have to fetch the meta.json for each of them, in order
 	// to find out if their upload has been completed (meta.json is uploaded last) and get the block
 	// information to store in the bucket index.
@@ -168,6 +176,51 @@ func (w *Updater) updateBlocks(ctx context.Context, old []*Block) (blocks []*Blo
 	return blocks, partials, nil
 }
 
+// backfillBlocks fetches again the meta.json of the blocks added to the index before the number of series, the size
+// or the sources of the blocks were tracked, and replaces them with an entry including these fields. Up to
+// maxBackfilledBlocksPerUpdate blocks are backfilled on each update, so that the fields of the existing
+// indexes are progressively backfilled without reading all the meta.json files at once.
+func (w *Updater) backfillBlocks(ctx context.Context, blocks []*Block) error {
+	var indexes []int
+	for i, b := range blocks {
+		if !b.HasStats || b.Sources == nil {
+			indexes = append(indexes, i)
+		}
+		if len(indexes) >= maxBackfilledBlocksPerUpdate {
+			break
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	err := concurrency.ForEachJob(ctx, len(indexes), w.updateBlocksConcurrency, func(ctx context.Context, idx int) error {
+		b := blocks[indexes[idx]]
+
+		updated, err := w.updateBlockIndexEntry(ctx, b.ID)
+		if err != nil {
+			// The block entry is kept as is, and its backfilling is retried on the next update.
+			level.Warn(w.logger).Log("msg", "failed to backfill block when updating bucket index", "block", b.ID.String(), "err", err)
+			return nil
+		}
+
+		// The old index may be shared, so its entries are copied instead of modified.
+		backfilled := *b
+		backfilled.SizeBytes = updated.SizeBytes
+		backfilled.NumSeries = updated.NumSeries
+		backfilled.HasStats = true
+		backfilled.Sources = updated.Sources
+		blocks[indexes[idx]] = &backfilled
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	level.Info(w.logger).Log("msg", "backfilled existing blocks", "count", len(indexes))
+	return nil
+}
+
 func (w *Updater) updateBlockIndexEntry(ctx context.Context, id ulid.ULID) (*Block, error) {
 	// Set a generous timeout for fetching the meta.json and getting the attributes of the same file.
 	// This protects against operations that can take unbounded time.
@@ -8,6 +8,7 @@ package bucketindex
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"maps"
 	"path"
 	"testing"
@@ -233,6 +234,128 @@ func TestUpdater_UpdateIndexFromVersion1ToVersion2(t *testing.T) {
 		[]*block.DeletionMark{})
 }
 
+func TestUpdater_UpdateIndex_ShouldBackfillNumSeriesAndSizeOfExistingBlocks(t *testing.T) {
+	const userID = "user-1"
+
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	// Generate a block whose meta.json lists the block stats and files.
+	meta := block.MockStorageBlockWithExtLabels(t, bkt, userID, 10, 20, nil)
+	meta.Stats.NumSeries = 100
+	meta.Thanos.Files = []block.File{{RelPath: block.IndexFilename, SizeBytes: 1024}}
+	metaContent, err := json.Marshal(meta)
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, meta.ULID.String(), block.MetaFilename), bytes.NewReader(metaContent)))
+
+	w := NewUpdater(bkt, userID, nil, 16, 16, logger)
+	returnedIdx, _, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, returnedIdx.Blocks, 1)
+	assert.Equal(t, uint64(100), returnedIdx.Blocks[0].NumSeries)
+	assert.Equal(t, int64(1024), returnedIdx.Blocks[0].SizeBytes)
+
+	// Simulate an index written by a version which didn't track the number of series, the size and the sources
+	// of the blocks.
+	oldBlock := *returnedIdx.Blocks[0]
+	oldBlock.NumSeries = 0
+	oldBlock.SizeBytes = 0
+	oldBlock.HasStats = false
+	oldBlock.Sources = nil
+	oldIdx := &Index{Version: IndexVersion2, Blocks: []*Block{&oldBlock}}
+
+	// Rerunning the updater should backfill the missing fields, without modifying the old index.
+	returnedIdx, _, err = w.UpdateIndex(ctx, oldIdx)
+	require.NoError(t, err)
+	assert.Equal(t, IndexVersion2, returnedIdx.Version)
+	require.Len(t, returnedIdx.Blocks, 1)
+	assert.Equal(t, uint64(100), returnedIdx.Blocks[0].NumSeries)
+	assert.Equal(t, int64(1024), returnedIdx.Blocks[0].SizeBytes)
+	assert.Equal(t, oldBlock.UploadedAt, returnedIdx.Blocks[0].UploadedAt)
+	assert.True(t, returnedIdx.Blocks[0].HasStats)
+	assert.Equal(t, meta.Compaction.Sources, returnedIdx.Blocks[0].Sources)
+	assert.Zero(t, oldBlock.NumSeries)
+	assert.False(t, oldBlock.HasStats)
+}
+
+func TestUpdater_UpdateIndex_ShouldBackfillBlocksWithoutSeriesOnlyOnce(t *testing.T) {
+	const userID = "user-1"
+
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	// Generate a block whose meta.json doesn't list the number of series.
+	meta := block.MockStorageBlockWithExtLabels(t, bkt, userID, 10, 20, nil)
+	oldBlock := BlockFromThanosMeta(meta)
+	oldBlock.HasStats = false
+	oldIdx := &Index{Version: IndexVersion2, Blocks: []*Block{oldBlock}}
+
+	w := NewUpdater(bkt, userID, nil, 16, 16, logger)
+	returnedIdx, _, err := w.UpdateIndex(ctx, oldIdx)
+	require.NoError(t, err)
+	require.Len(t, returnedIdx.Blocks, 1)
+	assert.Zero(t, returnedIdx.Blocks[0].NumSeries)
+	assert.True(t, returnedIdx.Blocks[0].HasStats)
+
+	// Change the meta.json: the block has already been backfilled, so it shouldn't be fetched again.
+	meta.Stats.NumSeries = 100
+	metaContent, err := json.Marshal(meta)
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, meta.ULID.String(), block.MetaFilename), bytes.NewReader(metaContent)))
+
+	returnedIdx, _, err = w.UpdateIndex(ctx, returnedIdx)
+	require.NoError(t, err)
+	require.Len(t, returnedIdx.Blocks, 1)
+	assert.Zero(t, returnedIdx.Blocks[0].NumSeries)
+}
+
+func TestUpdater_UpdateIndex_ShouldBackfillALimitedNumberOfBlocksPerUpdate(t *testing.T) {
+	const userID = "user-1"
+
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	// Generate more blocks than the number of blocks backfilled on each update.
+	oldIdx := &Index{Version: IndexVersion2}
+	for i := 0; i < maxBackfilledBlocksPerUpdate+10; i++ {
+		meta := block.MockStorageBlockWithExtLabels(t, bkt, userID, int64(i*10), int64(i*10+10), nil)
+		oldBlock := BlockFromThanosMeta(meta)
+		oldBlock.HasStats = false
+		oldIdx.Blocks = append(oldIdx.Blocks, oldBlock)
+
+		meta.Stats.NumSeries = 100
+		metaContent, err := json.Marshal(meta)
+		require.NoError(t, err)
+		require.NoError(t, bkt.Upload(ctx, path.Join(userID, meta.ULID.String(), block.MetaFilename), bytes.NewReader(metaContent)))
+	}
+
+	countBackfilled := func(idx *Index) int {
+		count := 0
+		for _, b := range idx.Blocks {
+			if b.NumSeries > 0 {
+				count++
+			}
+		}
+		return count
+	}
+
+	w := NewUpdater(bkt, userID, nil, 16, 16, logger)
+	returnedIdx, _, err := w.UpdateIndex(ctx, oldIdx)
+	require.NoError(t, err)
+	assert.Equal(t, maxBackfilledBlocksPerUpdate, countBackfilled(returnedIdx))
+
+	// The remaining blocks are backfilled on the next update.
+	returnedIdx, _, err = w.UpdateIndex(ctx, returnedIdx)
+	require.NoError(t, err)
+	assert.Equal(t, len(returnedIdx.Blocks), countBackfilled(returnedIdx))
+}
+
 func getBlockUploadedAt(t testing.TB, bkt objstore.Bucket, userID string, blockID ulid.ULID) int64 {
 	metaFile := path.Join(userID, blockID.String(), block.MetaFilename)
 
@@ -258,6 +381,8 @@ func assertBucketIndexEqual(t testing.TB, idx *Index, bkt objstore.Bucket, userI
 			Source:           "test",
 			CompactionLevel:  1,
 			OutOfOrder:       false,
+			HasStats:         true,
+			Sources:          b.Compaction.Sources,
 			Labels:           b.Thanos.Labels,
 		})
 	}
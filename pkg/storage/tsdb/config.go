@@ -9,7 +9,6 @@ import (
 	"flag"
 	"fmt"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/alecthomas/units"
@@ -22,6 +21,7 @@ import (
 	"github.com/grafana/mimir/pkg/ingester/activeseries"
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/indexheader"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 const (
@@ -145,42 +145,9 @@ type BlocksStorageConfig struct {
 	TSDB        TSDBConfig        `yaml:"tsdb"`
 }
 
-// DurationList is the block ranges for a tsdb
-type DurationList []time.Duration
-
-// String implements the flag.Value interface
-func (d *DurationList) String() string {
-	values := make([]string, 0, len(*d))
-	for _, v := range *d {
-		values = append(values, v.String())
-	}
-
-	return strings.Join(values, ",")
-}
-
-// Set implements the flag.Value interface
-func (d *DurationList) Set(s string) error {
-	values := strings.Split(s, ",")
-	*d = make([]time.Duration, 0, len(values)) // flag.Parse may be called twice, so overwrite instead of append
-	for _, v := range values {
-		t, err := time.ParseDuration(v)
-		if err != nil {
-			return err
-		}
-		*d = append(*d, t)
-	}
-	return nil
-}
-
-// ToMilliseconds returns the duration list in milliseconds
-func (d *DurationList) ToMilliseconds() []int64 {
-	values := make([]int64, 0, len(*d))
-	for _, t := range *d {
-		values = append(values, t.Milliseconds())
-	}
-
-	return values
-}
+// DurationList is the block ranges for a tsdb. It's defined in the util package, so that it
+// can be used by per-tenant limits too.
+type DurationList = util.DurationList
 
 // RegisterFlags registers the TSDB flags
 func (cfg *BlocksStorageConfig) RegisterFlags(f *flag.FlagSet) {
@@ -281,6 +248,10 @@ type TSDBConfig struct {
 	// HeadCompactionIntervalWhileStarting setting is hardcoded, but allowed to overwrite it in tests.
 	HeadCompactionIntervalWhileStarting time.Duration `yaml:"-"`
 
+	// WALCompressionTypeOverride, if set, overrides the WAL compression type selected by WALCompressionEnabled.
+	// Only for testing and benchmarking.
+	WALCompressionTypeOverride compression.Type `yaml:"-"`
+
 	// TimelyHeadCompaction allows head compaction to happen when min block range can no longer be appended,
 	// without requiring 1.5x the chunk range worth of data in the head.
 	TimelyHeadCompaction bool `yaml:"timely_head_compaction_enabled" category:"experimental"`
@@ -386,6 +357,10 @@ func (cfg *TSDBConfig) Validate(activeSeriesCfg activeseries.Config) error {
 }
 
 func (cfg *TSDBConfig) WALCompressionType() compression.Type {
+	if cfg.WALCompressionTypeOverride != "" {
+		return cfg.WALCompressionTypeOverride
+	}
+
 	if cfg.WALCompressionEnabled {
 		return compression.Snappy
 	}
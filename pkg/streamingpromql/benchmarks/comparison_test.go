@@ -9,6 +9,8 @@ import (
 	"context"
 	"math"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,7 +29,6 @@ import (
 
 	"github.com/grafana/mimir/pkg/distributor"
 	"github.com/grafana/mimir/pkg/ingester"
-	"github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/querier"
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/streamingpromql"
@@ -57,6 +58,9 @@ func BenchmarkQuery(b *testing.B) {
 	// Don't compare results when we're running under tools/benchmark-query-engine, as that will skew peak memory utilisation.
 	skipCompareResults := os.Getenv("MIMIR_PROMQL_ENGINE_BENCHMARK_SKIP_COMPARE_RESULTS") == "true"
 
+	// Reading the GC statistics stops the world, so only do it when requested by tools/benchmark-query-engine.
+	reportGC := os.Getenv("MIMIR_PROMQL_ENGINE_BENCHMARK_REPORT_GC") == "true"
+
 	for _, c := range cases {
 		start := time.Unix(int64((NumIntervals-c.Steps)*intervalSeconds), 0)
 		end := time.Unix(int64(NumIntervals*intervalSeconds), 0)
@@ -75,6 +79,12 @@ func BenchmarkQuery(b *testing.B) {
 
 			for name, engine := range engines {
 				b.Run("engine="+name, func(b *testing.B) {
+					var before runtime.MemStats
+					if reportGC {
+						runtime.ReadMemStats(&before)
+						b.ResetTimer()
+					}
+
 					for i := 0; i < b.N; i++ {
 						res, cleanup := c.Run(ctx, b, start, end, interval, engine, q)
 
@@ -82,12 +92,26 @@ func BenchmarkQuery(b *testing.B) {
 							cleanup()
 						}
 					}
+
+					if reportGC {
+						b.StopTimer()
+						reportGCMetrics(b, before)
+					}
 				})
 			}
 		})
 	}
 }
 
+// reportGCMetrics reports the number of GC cycles and the GC pause time per operation since before was read.
+func reportGCMetrics(b *testing.B, before runtime.MemStats) {
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+}
+
 func TestBothEnginesReturnSameResultsForBenchmarkQueries(t *testing.T) {
 	metricSizes := []int{1, 100} // Don't bother with 2000 series test here: these test cases take a while and they're most interesting as benchmarks, not correctness tests.
 	q := createBenchmarkQueryable(t, metricSizes)
@@ -182,7 +206,7 @@ func createBenchmarkQueryable(t testing.TB, metricSizes []int) storage.Queryable
 	if addr == "" {
 		var err error
 		var cleanup func()
-		addr, cleanup, err = StartIngesterAndLoadData(t.TempDir(), metricSizes)
+		addr, cleanup, err = StartIngesterAndLoadData(t.TempDir(), metricSizes, StorageOptions{})
 		require.NoError(t, err)
 		t.Cleanup(cleanup)
 	}
@@ -225,9 +249,17 @@ func createIngesterQueryable(t testing.TB, address string) storage.Queryable {
 	test.Poll(t, time.Second, 1, func() interface{} { return ingestersRing.InstancesCount() })
 
 	distributorCfg := distributor.Config{}
-	clientCfg := client.Config{}
 	querierCfg := querier.Config{}
-	flagext.DefaultValues(&distributorCfg, &clientCfg, &querierCfg)
+	flagext.DefaultValues(&distributorCfg, &querierCfg)
+
+	// tools/benchmark-query-engine forwards the ingester client flags it was given, eg. to connect to a remote ingester over TLS.
+	var clientArgs []string
+	if args := os.Getenv("MIMIR_PROMQL_ENGINE_BENCHMARK_INGESTER_CLIENT_ARGS"); args != "" {
+		clientArgs = strings.Split(args, "\n")
+	}
+
+	clientCfg, err := IngesterClientConfigFromArgs(clientArgs)
+	require.NoError(t, err)
 
 	// The default value for this option is defined in the querier config and applied to the distributor config struct,
 	// so we have to copy it over ourselves.
@@ -24,7 +24,9 @@ import (
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/util/compression"
 	"google.golang.org/grpc"
 
 	"github.com/grafana/mimir/pkg/ingester"
@@ -40,8 +42,21 @@ const NumIntervals = 10000 + int(time.Minute/interval) + 1 // The longest-range
 
 const UserID = "benchmark-tenant"
 
-func StartIngesterAndLoadData(rootDataDir string, metricSizes []int) (string, func(), error) {
-	ing, addr, cleanup, err := startBenchmarkIngester(rootDataDir)
+const histogramBuckets = 5
+
+// StorageOptions configures how the benchmark ingester stores data.
+// The zero value keeps the ingester defaults.
+type StorageOptions struct {
+	// WALCompression is the TSDB WAL compression type, one of compression.Types().
+	// If empty, the ingester default is used.
+	WALCompression compression.Type
+
+	// OutOfOrderTimeWindow is the out-of-order time window of the benchmark tenant. 0 disables out-of-order ingestion.
+	OutOfOrderTimeWindow time.Duration
+}
+
+func StartIngesterAndLoadData(rootDataDir string, metricSizes []int, storageOpts StorageOptions) (string, func(), error) {
+	ing, addr, cleanup, err := startBenchmarkIngester(rootDataDir, storageOpts)
 
 	if err != nil {
 		return "", nil, fmt.Errorf("could not start ingester: %w", err)
@@ -55,7 +70,7 @@ func StartIngesterAndLoadData(rootDataDir string, metricSizes []int) (string, fu
 	return addr, cleanup, nil
 }
 
-func startBenchmarkIngester(rootDataDir string) (*ingester.Ingester, string, func(), error) {
+func startBenchmarkIngester(rootDataDir string, storageOpts StorageOptions) (*ingester.Ingester, string, func(), error) {
 	var cleanupFuncs []func() error
 	cleanup := func() {
 		for i := len(cleanupFuncs) - 1; i >= 0; i-- {
@@ -65,6 +80,7 @@ func startBenchmarkIngester(rootDataDir string) (*ingester.Ingester, string, fun
 
 	limits := defaultLimitsTestConfig()
 	limits.NativeHistogramsIngestionEnabled = true
+	limits.OutOfOrderTimeWindow = model.Duration(storageOpts.OutOfOrderTimeWindow)
 
 	overrides := validation.NewOverrides(limits, nil)
 
@@ -83,6 +99,14 @@ func startBenchmarkIngester(rootDataDir string) (*ingester.Ingester, string, fun
 	ingesterCfg.BlocksStorageConfig.TSDB.HeadCompactionIntervalJitterEnabled = false
 	ingesterCfg.BlocksStorageConfig.TSDB.HeadCompactionIdleTimeout = 0
 
+	ingesterCfg.BlocksStorageConfig.TSDB.WALCompressionTypeOverride = storageOpts.WALCompression
+
+	slog.Info(
+		"ingester storage configuration",
+		"wal_compression", ingesterCfg.BlocksStorageConfig.TSDB.WALCompressionType(),
+		"out_of_order_time_window", time.Duration(limits.OutOfOrderTimeWindow),
+	)
+
 	ingestersRing, err := createAndStartRing(ingesterCfg.IngesterRing.ToRingConfig())
 	if err != nil {
 		cleanup()
@@ -108,7 +132,7 @@ func startBenchmarkIngester(rootDataDir string) (*ingester.Ingester, string, fun
 		return services.StopAndAwaitTerminated(context.Background(), ing)
 	})
 
-	serv := grpc.NewServer(grpc.StreamInterceptor(middleware.StreamServerUserHeaderInterceptor))
+	serv := grpc.NewServer(grpc.UnaryInterceptor(middleware.ServerUserHeaderInterceptor), grpc.StreamInterceptor(middleware.StreamServerUserHeaderInterceptor))
 	client.RegisterIngesterServer(serv, ing)
 	cleanupFuncs = append(cleanupFuncs, func() error {
 		serv.GracefulStop()
@@ -167,15 +191,7 @@ func createAndStartRing(ringConfig ring.Config) (*ring.Ring, error) {
 }
 
 func pushTestData(ing *ingester.Ingester, metricSizes []int) error {
-	const histogramBuckets = 5
-
-	totalMetrics := 0
-
-	for _, size := range metricSizes {
-		totalMetrics += (2 + histogramBuckets + 1 + 1) * size // 2 non-histogram metrics + 5 metrics for histogram buckets + 1 metric for +Inf histogram bucket + 1 metric for native-histograms
-	}
-
-	metrics := make([]labels.Labels, 0, totalMetrics)
+	metrics := make([]labels.Labels, 0, ExpectedSeriesCount(metricSizes))
 
 	for _, size := range metricSizes {
 		aName := "a_" + strconv.Itoa(size)
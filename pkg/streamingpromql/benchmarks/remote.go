@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package benchmarks
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/ingester/client"
+)
+
+// IngesterClientFlagsPrefix is the prefix of the flags configuring the client used to query the benchmark ingester.
+const IngesterClientFlagsPrefix = "ingester.client"
+
+// IngesterClientConfigFromArgs returns the default ingester client config, overridden by args.
+// args are command-line flags registered with IngesterClientFlagsPrefix, eg. "-ingester.client.tls-enabled=true".
+func IngesterClientConfigFromArgs(args []string) (client.Config, error) {
+	cfg := client.Config{}
+	fs := flag.NewFlagSet("ingester-client", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.RegisterFlagsWithPrefix(IngesterClientFlagsPrefix, fs)
+
+	if err := fs.Parse(args); err != nil {
+		return client.Config{}, fmt.Errorf("invalid ingester client flags: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return client.Config{}, fmt.Errorf("invalid ingester client config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// ExpectedSeriesCount returns the number of series loaded into the benchmark ingester for metricSizes.
+func ExpectedSeriesCount(metricSizes []int) int {
+	count := 0
+
+	for _, size := range metricSizes {
+		count += (2 + histogramBuckets + 1 + 1) * size // 2 non-histogram metrics + 5 metrics for histogram buckets + 1 metric for +Inf histogram bucket + 1 metric for native-histograms
+	}
+
+	return count
+}
+
+// CountIngesterSeries returns the number of series of the benchmark tenant in the ingester at address, over the
+// time range loaded for benchmarks.
+func CountIngesterSeries(ctx context.Context, address string, clientCfg client.Config) (int, error) {
+	c, err := client.MakeIngesterClient(ring.InstanceDesc{Addr: address}, clientCfg, client.NewMetrics(nil), log.NewNopLogger())
+	if err != nil {
+		return 0, fmt.Errorf("could not create ingester client: %w", err)
+	}
+	defer c.Close() //nolint:errcheck
+
+	// The loaded data is flushed to blocks, so the series can't be counted from the TSDB head statistics.
+	end := model.Time(int64(NumIntervals) * interval.Milliseconds())
+	req, err := client.ToMetricsForLabelMatchersRequest(0, end, nil, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabel, ".+"),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.MetricsForLabelMatchers(user.InjectOrgID(ctx, UserID), req)
+	if err != nil {
+		return 0, fmt.Errorf("could not query ingester for benchmark tenant series: %w", err)
+	}
+
+	return len(resp.Metric), nil
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package benchmarks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountIngesterSeries(t *testing.T) {
+	metricSizes := []int{1, 10}
+
+	addr, cleanup, err := StartIngesterAndLoadData(t.TempDir(), metricSizes, StorageOptions{})
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	clientCfg, err := IngesterClientConfigFromArgs(nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	actual, err := CountIngesterSeries(ctx, addr, clientCfg)
+	require.NoError(t, err)
+	require.Equal(t, ExpectedSeriesCount(metricSizes), actual)
+}
+
+func TestIngesterClientConfigFromArgs(t *testing.T) {
+	cfg, err := IngesterClientConfigFromArgs([]string{"-ingester.client.tls-enabled=true", "-ingester.client.connect-timeout=1m"})
+	require.NoError(t, err)
+	require.True(t, cfg.GRPCClientConfig.TLSEnabled)
+	require.Equal(t, time.Minute, cfg.GRPCClientConfig.ConnectTimeout)
+
+	_, err = IngesterClientConfigFromArgs([]string{"-ingester.client.unknown=true"})
+	require.Error(t, err)
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -175,3 +176,40 @@ func UnixSecondsFromTime(t time.Time) UnixSeconds {
 func (t UnixSeconds) Time() time.Time {
 	return time.Unix(int64(t), 0)
 }
+
+// DurationList is a list of durations, configurable as a comma-separated list.
+type DurationList []time.Duration
+
+// String implements the flag.Value interface
+func (d *DurationList) String() string {
+	values := make([]string, 0, len(*d))
+	for _, v := range *d {
+		values = append(values, v.String())
+	}
+
+	return strings.Join(values, ",")
+}
+
+// Set implements the flag.Value interface
+func (d *DurationList) Set(s string) error {
+	values := strings.Split(s, ",")
+	*d = make([]time.Duration, 0, len(values)) // flag.Parse may be called twice, so overwrite instead of append
+	for _, v := range values {
+		t, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = append(*d, t)
+	}
+	return nil
+}
+
+// ToMilliseconds returns the duration list in milliseconds
+func (d *DurationList) ToMilliseconds() []int64 {
+	values := make([]int64, 0, len(*d))
+	for _, t := range *d {
+		values = append(values, t.Milliseconds())
+	}
+
+	return values
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily time window in UTC, from Start included to End excluded, both expressed as the time elapsed
+// since midnight. A window whose End is before its Start spans midnight.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseTimeWindow parses a time window in the HH:MM-HH:MM format.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: expected format is HH:MM-HH:MM", s)
+	}
+
+	start, err := parseTimeOfDay(startStr)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", s, err)
+	}
+	end, err := parseTimeOfDay(endStr)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", s, err)
+	}
+	if start == end {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: start and end must be different", s)
+	}
+
+	return TimeWindow{Start: start, End: end}, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: expected format is HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns whether the time of day of t, in UTC, is within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	sinceMidnight := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+
+	if w.Start < w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	// The window spans midnight.
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", int(w.Start.Hours()), int(w.Start.Minutes())%60, int(w.End.Hours()), int(w.End.Minutes())%60)
+}
+
+// TimeWindows is a list of daily time windows in UTC, configurable as a comma-separated list of HH:MM-HH:MM windows.
+type TimeWindows []TimeWindow
+
+// Contains returns whether the time of day of t, in UTC, is within any of the windows.
+func (w TimeWindows) Contains(t time.Time) bool {
+	for _, window := range w {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements the flag.Value interface.
+func (w *TimeWindows) String() string {
+	values := make([]string, 0, len(*w))
+	for _, window := range *w {
+		values = append(values, window.String())
+	}
+	return strings.Join(values, ",")
+}
+
+// Set implements the flag.Value interface.
+func (w *TimeWindows) Set(s string) error {
+	// flag.Parse may be called twice, so overwrite instead of append.
+	*w = nil
+	if s == "" {
+		return nil
+	}
+
+	for _, v := range strings.Split(s, ",") {
+		window, err := ParseTimeWindow(v)
+		if err != nil {
+			return err
+		}
+		*w = append(*w, window)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so that the windows are marshalled as a comma-separated list.
+func (w TimeWindows) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (w *TimeWindows) UnmarshalText(text []byte) error {
+	return w.Set(string(text))
+}
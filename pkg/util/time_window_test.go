@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTimeWindows_Set(t *testing.T) {
+	for input, tc := range map[string]struct {
+		expected    TimeWindows
+		expectedErr string
+	}{
+		"": {},
+		"01:00-05:30": {
+			expected: TimeWindows{{Start: time.Hour, End: 5*time.Hour + 30*time.Minute}},
+		},
+		"22:00-06:00, 12:00-13:00": {
+			expected: TimeWindows{{Start: 22 * time.Hour, End: 6 * time.Hour}, {Start: 12 * time.Hour, End: 13 * time.Hour}},
+		},
+		"01:00": {
+			expectedErr: `invalid time window "01:00": expected format is HH:MM-HH:MM`,
+		},
+		"01:00-25:00": {
+			expectedErr: `invalid time window "01:00-25:00": invalid time of day "25:00": expected format is HH:MM`,
+		},
+		"01:00-01:00": {
+			expectedErr: `invalid time window "01:00-01:00": start and end must be different`,
+		},
+	} {
+		t.Run(input, func(t *testing.T) {
+			var windows TimeWindows
+			err := windows.Set(input)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, windows)
+		})
+	}
+}
+
+func TestTimeWindows_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	var windows TimeWindows
+	require.NoError(t, windows.Set("22:00-06:00,12:00-13:00"))
+
+	assert.True(t, windows.Contains(at(23, 0)))
+	assert.True(t, windows.Contains(at(0, 0)))
+	assert.True(t, windows.Contains(at(5, 59)))
+	assert.False(t, windows.Contains(at(6, 0)))
+	assert.True(t, windows.Contains(at(12, 0)))
+	assert.False(t, windows.Contains(at(13, 0)))
+	assert.False(t, windows.Contains(at(21, 59)))
+
+	// The windows are in UTC.
+	assert.True(t, windows.Contains(at(12, 30).In(time.FixedZone("UTC+5", 5*60*60))))
+
+	assert.False(t, TimeWindows{}.Contains(at(12, 0)))
+}
+
+func TestTimeWindows_YAML(t *testing.T) {
+	type config struct {
+		Windows TimeWindows `yaml:"windows"`
+	}
+
+	var cfg config
+	require.NoError(t, yaml.Unmarshal([]byte(`windows: 22:00-06:00,12:00-13:00`), &cfg))
+	assert.Equal(t, TimeWindows{{Start: 22 * time.Hour, End: 6 * time.Hour}, {Start: 12 * time.Hour, End: 13 * time.Hour}}, cfg.Windows)
+
+	out, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "windows: 22:00-06:00,12:00-13:00\n", string(out))
+
+	require.Error(t, yaml.Unmarshal([]byte(`windows: 22:00`), &cfg))
+}
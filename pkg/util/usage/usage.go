@@ -164,11 +164,12 @@ func parseStructure(structure interface{}, fields map[uintptr]reflect.StructFiel
 	return nil
 }
 
-// Descending into some structs breaks check for "advanced" category for some fields (eg. flagext.Secret),
+// Descending into some structs breaks check for "advanced" category for some fields (eg. flagext.Secret or flagext.Time),
 // because field itself is at the same memory address as the internal field in the struct, and advanced-category-check
 // then gets confused.
 var ignoredStructTypes = []reflect.Type{
 	reflect.TypeOf(flagext.Secret{}),
+	reflect.TypeOf(flagext.Time{}),
 	reflect.TypeOf(asmodel.CustomTrackersConfig{}),
 }
 
@@ -223,7 +224,7 @@ func getFlagName(fl *flag.Flag) string {
 			return "string"
 		case "*model.Duration":
 			return "duration"
-		case "*tsdb.DurationList":
+		case "*util.DurationList":
 			return "comma-separated list of durations"
 		}
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/tsdb/chunks"
 	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
@@ -71,15 +72,29 @@ const (
 
 	// MinCompactorPartialBlockDeletionDelay is the minimum partial blocks deletion delay that can be configured in Mimir.
 	MinCompactorPartialBlockDeletionDelay = 4 * time.Hour
+
+	// MinCompactorMaxBlockChunkSegmentSize and MaxCompactorMaxBlockChunkSegmentSize are the bounds of the max chunk
+	// segment size of the compacted blocks. The lower bound is the same enforced by Prometheus, while the upper bound
+	// is the max offset of a chunk within a segment file.
+	MinCompactorMaxBlockChunkSegmentSize = 1024 * 1024
+	MaxCompactorMaxBlockChunkSegmentSize = math.MaxUint32
 )
 
 var (
-	errInvalidIngestStorageReadConsistency         = fmt.Errorf("invalid ingest storage read consistency (supported values: %s)", strings.Join(api.ReadConsistencies, ", "))
-	errInvalidMaxEstimatedChunksPerQueryMultiplier = errors.New("invalid value for -" + MaxEstimatedChunksPerQueryMultiplierFlag + ": must be 0 or greater than or equal to 1")
-	errNegativeUpdateTimeoutJitterMax              = errors.New("HA tracker max update timeout jitter shouldn't be negative")
+	errInvalidIngestStorageReadConsistency          = fmt.Errorf("invalid ingest storage read consistency (supported values: %s)", strings.Join(api.ReadConsistencies, ", "))
+	errInvalidMaxEstimatedChunksPerQueryMultiplier  = errors.New("invalid value for -" + MaxEstimatedChunksPerQueryMultiplierFlag + ": must be 0 or greater than or equal to 1")
+	errNegativeUpdateTimeoutJitterMax               = errors.New("HA tracker max update timeout jitter shouldn't be negative")
+	errNegativeBlockUploadValidationConcurrency     = errors.New("invalid value for -compactor.block-upload-validation-concurrency: must be greater than or equal to 0")
+	errNegativeCompactorTenantCompactionRetries     = errors.New("invalid value for -compactor.tenant-compaction-retries: must be greater than or equal to 0")
+	errNegativeCompactorMaxSeries                   = errors.New("invalid value for -compactor.max-series: must be greater than or equal to 0")
+	errNegativeCompactedBlocksValidationConcurrency = errors.New("invalid value for -compactor.compacted-blocks-validation-concurrency: must be greater than or equal to 0")
+	errInvalidCompactorMaxBlockChunkSegmentSize     = fmt.Errorf("invalid value for -compactor.max-block-chunk-segment-size: must be 0 or between %d and %d", MinCompactorMaxBlockChunkSegmentSize, MaxCompactorMaxBlockChunkSegmentSize)
 )
 
-const errInvalidFailoverTimeout = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
+const (
+	errInvalidFailoverTimeout            = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
+	errInvalidCompactorTenantBlockRanges = "invalid value for -compactor.tenant-block-ranges: block range periods should be divisible by the previous one, but %s is not divisible by %s"
+)
 
 // LimitError is a marker interface for the errors that do not comply with the specified limits.
 type LimitError interface {
@@ -245,18 +260,33 @@ type Limits struct {
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
 
 	// Compactor.
-	CompactorBlocksRetentionPeriod        model.Duration `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
-	CompactorSplitAndMergeShards          int            `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
-	CompactorSplitGroups                  int            `yaml:"compactor_split_groups" json:"compactor_split_groups"`
-	CompactorTenantShardSize              int            `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
-	CompactorPartialBlockDeletionDelay    model.Duration `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
-	CompactorBlockUploadEnabled           bool           `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
-	CompactorBlockUploadValidationEnabled bool           `yaml:"compactor_block_upload_validation_enabled" json:"compactor_block_upload_validation_enabled"`
-	CompactorBlockUploadVerifyChunks      bool           `yaml:"compactor_block_upload_verify_chunks" json:"compactor_block_upload_verify_chunks"`
-	CompactorBlockUploadMaxBlockSizeBytes int64          `yaml:"compactor_block_upload_max_block_size_bytes" json:"compactor_block_upload_max_block_size_bytes" category:"advanced"`
-	CompactorInMemoryTenantMetaCacheSize  int            `yaml:"compactor_in_memory_tenant_meta_cache_size" json:"compactor_in_memory_tenant_meta_cache_size" category:"experimental" doc:"hidden"`
-	CompactorMaxLookback                  model.Duration `yaml:"compactor_max_lookback" json:"compactor_max_lookback" category:"experimental"`
-	CompactorMaxPerBlockUploadConcurrency int            `yaml:"compactor_max_per_block_upload_concurrency" json:"compactor_max_per_block_upload_concurrency" category:"advanced"`
+	CompactorBlocksRetentionPeriod                model.Duration         `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
+	CompactorSplitAndMergeShards                  int                    `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
+	CompactorSplitGroups                          int                    `yaml:"compactor_split_groups" json:"compactor_split_groups"`
+	CompactorTenantShardSize                      int                    `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
+	CompactorPartialBlockDeletionDelay            model.Duration         `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
+	CompactorBlockUploadEnabled                   bool                   `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
+	CompactorBlockUploadValidationEnabled         bool                   `yaml:"compactor_block_upload_validation_enabled" json:"compactor_block_upload_validation_enabled"`
+	CompactorBlockUploadValidationConcurrency     int                    `yaml:"compactor_block_upload_validation_concurrency" json:"compactor_block_upload_validation_concurrency" category:"experimental"`
+	CompactorBlockUploadVerifyChunks              bool                   `yaml:"compactor_block_upload_verify_chunks" json:"compactor_block_upload_verify_chunks"`
+	CompactorBlockUploadMaxBlockSizeBytes         int64                  `yaml:"compactor_block_upload_max_block_size_bytes" json:"compactor_block_upload_max_block_size_bytes" category:"advanced"`
+	CompactorBlockUploadMaxFiles                  int                    `yaml:"compactor_block_upload_max_files" json:"compactor_block_upload_max_files" category:"advanced"`
+	CompactorInMemoryTenantMetaCacheSize          int                    `yaml:"compactor_in_memory_tenant_meta_cache_size" json:"compactor_in_memory_tenant_meta_cache_size" category:"experimental" doc:"hidden"`
+	CompactorInMemoryTenantMetaCacheShadowSize    int                    `yaml:"compactor_in_memory_tenant_meta_cache_shadow_size" json:"compactor_in_memory_tenant_meta_cache_shadow_size" category:"experimental" doc:"hidden"`
+	CompactorMaxLookback                          model.Duration         `yaml:"compactor_max_lookback" json:"compactor_max_lookback" category:"experimental"`
+	CompactorMaxPerBlockUploadConcurrency         int                    `yaml:"compactor_max_per_block_upload_concurrency" json:"compactor_max_per_block_upload_concurrency" category:"advanced"`
+	CompactorRequiredGroupingLabels               flagext.StringSliceCSV `yaml:"compactor_required_grouping_labels" json:"compactor_required_grouping_labels" category:"experimental"`
+	CompactorTenantDiskQuotaBytes                 int64                  `yaml:"compactor_tenant_disk_quota_bytes" json:"compactor_tenant_disk_quota_bytes" category:"experimental"`
+	CompactorTenantCompactionMemoryBytes          int64                  `yaml:"compactor_tenant_compaction_memory_bytes" json:"compactor_tenant_compaction_memory_bytes" category:"experimental"`
+	CompactorMaxBlockChunkSegmentSize             int64                  `yaml:"compactor_max_block_chunk_segment_size" json:"compactor_max_block_chunk_segment_size" category:"experimental"`
+	CompactorCompactedBlocksValidationConcurrency int                    `yaml:"compactor_compacted_blocks_validation_concurrency" json:"compactor_compacted_blocks_validation_concurrency" category:"experimental"`
+	CompactorMaxSeries                            int64                  `yaml:"compactor_max_series" json:"compactor_max_series" category:"experimental"`
+	CompactorNoBlocksFileCleanupEnabled           bool                   `yaml:"compactor_no_blocks_file_cleanup_enabled" json:"compactor_no_blocks_file_cleanup_enabled" category:"experimental"`
+	CompactorLogOverlappingBlocks                 bool                   `yaml:"compactor_log_overlapping_blocks" json:"compactor_log_overlapping_blocks" category:"experimental"`
+	CompactorTenantCompactionRetries              int                    `yaml:"compactor_tenant_compaction_retries" json:"compactor_tenant_compaction_retries" category:"experimental"`
+	CompactorTenantBlockRanges                    util.DurationList      `yaml:"compactor_tenant_block_ranges" json:"compactor_tenant_block_ranges" category:"experimental"`
+	CompactorTenantSchedulingWindows              util.TimeWindows       `yaml:"compactor_tenant_scheduling_windows" json:"compactor_tenant_scheduling_windows" category:"experimental"`
+	CompactorUploadSparseIndexHeaders             bool                   `yaml:"compactor_upload_sparse_index_headers" json:"compactor_upload_sparse_index_headers" category:"experimental"`
 
 	// This config doesn't have a CLI flag registered here because they're registered in
 	// their own original config struct.
@@ -432,11 +462,26 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.CompactorPartialBlockDeletionDelay, "compactor.partial-block-deletion-delay", fmt.Sprintf("If a partial block (unfinished block without %s file) hasn't been modified for this time, it will be marked for deletion. The minimum accepted value is %s: a lower value will be ignored and the feature disabled. 0 to disable.", block.MetaFilename, MinCompactorPartialBlockDeletionDelay.String()))
 	f.BoolVar(&l.CompactorBlockUploadEnabled, "compactor.block-upload-enabled", false, "Enable block upload API for the tenant.")
 	f.BoolVar(&l.CompactorBlockUploadValidationEnabled, "compactor.block-upload-validation-enabled", true, "Enable block upload validation for the tenant.")
+	f.IntVar(&l.CompactorBlockUploadValidationConcurrency, "compactor.block-upload-validation-concurrency", 0, "Max number of uploaded blocks of the tenant that can be validated concurrently. This limit is enforced on the tenant's validations only, in addition to -compactor.max-block-upload-validation-concurrency. 0 = no limit.")
 	f.BoolVar(&l.CompactorBlockUploadVerifyChunks, "compactor.block-upload-verify-chunks", true, "Verify chunks when uploading blocks via the upload API for the tenant.")
 	f.Int64Var(&l.CompactorBlockUploadMaxBlockSizeBytes, "compactor.block-upload-max-block-size-bytes", 0, "Maximum size in bytes of a block that is allowed to be uploaded or validated. 0 = no limit.")
+	f.IntVar(&l.CompactorBlockUploadMaxFiles, "compactor.block-upload-max-files", 0, "Maximum number of files of a block that is allowed to be uploaded. Blocks whose metadata declares more files are rejected when the upload starts. 0 = no limit.")
 	f.IntVar(&l.CompactorInMemoryTenantMetaCacheSize, "compactor.in-memory-tenant-meta-cache-size", 0, "Size of per-tenant in-memory cache for parsed meta.json files. This is useful when meta.json files are big and parsing is expensive. Small meta.json files are not cached. 0 means this cache is disabled.")
+	f.IntVar(&l.CompactorInMemoryTenantMetaCacheShadowSize, "compactor.in-memory-tenant-meta-cache-shadow-size", 0, "When the per-tenant in-memory cache for parsed meta.json files is disabled, track the hits and misses a cache of this size would have had, and log its hit ratio after compacting the tenant. Only the IDs of the blocks are kept in memory. This is useful to find the tenants which would benefit from enabling the cache. 0 means the tracking is disabled.")
 	f.Var(&l.CompactorMaxLookback, "compactor.max-lookback", "Blocks uploaded before the lookback aren't considered in compactor cycles. If set, this value should be larger than all values in `-blocks-storage.tsdb.block-ranges-period`. A value of 0s means that all blocks are considered regardless of their upload time.")
 	f.IntVar(&l.CompactorMaxPerBlockUploadConcurrency, "compactor.max-per-block-upload-concurrency", 8, "Maximum number of TSDB segment files that the compactor can upload concurrently per block.")
+	f.Int64Var(&l.CompactorTenantDiskQuotaBytes, "compactor.tenant-disk-quota-bytes", 0, "Maximum disk space in bytes that the tenant's compaction working directory can use. The compactor defers the tenant's compaction jobs whose source blocks would exceed it once downloaded, given the blocks of the tenant's jobs already running. Requires -compactor.tenant-data-dir-isolation-enabled. 0 = no limit.")
+	f.Int64Var(&l.CompactorTenantCompactionMemoryBytes, "compactor.tenant-compaction-memory-bytes", 0, "Maximum estimated memory of the compaction jobs of the tenant run at the same time. Jobs which would exceed it given the tenant's jobs currently running are deferred until the running jobs complete. A job larger than the limit runs once no other job of the tenant is running. 0 = no limit.")
+	f.Int64Var(&l.CompactorMaxBlockChunkSegmentSize, "compactor.max-block-chunk-segment-size", 0, fmt.Sprintf("Maximum size in bytes of the chunk segment files of the blocks compacted for the tenant. Larger segments reduce the number of files of large blocks. Must be between %d and %d. 0 to use the TSDB default of %d.", MinCompactorMaxBlockChunkSegmentSize, MaxCompactorMaxBlockChunkSegmentSize, chunks.DefaultChunkSegmentSize))
+	f.IntVar(&l.CompactorCompactedBlocksValidationConcurrency, "compactor.compacted-blocks-validation-concurrency", 0, "Max number of blocks output by a compaction job of the tenant that can be validated concurrently before being uploaded. When set, this limit replaces -compactor.block-sync-concurrency for the validation of the tenant's compacted blocks. 0 to use -compactor.block-sync-concurrency.")
+	f.Int64Var(&l.CompactorMaxSeries, "compactor.max-series", 0, "Maximum number of series stored in the compacted blocks of the tenant, counting each series once per block. The blocks uploaded by the ingesters aren't counted, because they overlap with each other. When exceeded, the compactor marks the oldest compacted blocks for deletion, keeping the newest blocks whose series fit within the limit, and always the blocks of the newest time range. The compacted blocks whose number of series is unknown are counted as empty. 0 = no limit.")
+	f.BoolVar(&l.CompactorNoBlocksFileCleanupEnabled, "compactor.tenant-no-blocks-file-cleanup-enabled", true, "If disabled, the compactor doesn't delete the bucket-index, markers and debug files in the tenant bucket when there are no blocks left in the index, even if -compactor.no-blocks-file-cleanup-enabled is enabled.")
+	f.BoolVar(&l.CompactorLogOverlappingBlocks, "compactor.log-overlapping-blocks", true, "If disabled, the compactor doesn't log the overlapping blocks found while compacting the tenant's blocks. They're still counted by the prometheus_tsdb_vertical_compactions_total metric. Useful for tenants whose blocks are expected to overlap, for example when backfilling.")
+	f.IntVar(&l.CompactorTenantCompactionRetries, "compactor.tenant-compaction-retries", 0, "How many times to retry a failed compaction of the tenant within a single compaction run. When set, this limit replaces -compactor.compaction-retries for the tenant. 0 to use -compactor.compaction-retries.")
+	f.BoolVar(&l.CompactorUploadSparseIndexHeaders, "compactor.upload-sparse-index-headers", false, "If enabled, the compactor constructs and uploads sparse index headers of the tenant's blocks to object storage during each compaction cycle. This allows store-gateway instances to use the sparse headers from object storage instead of recreating them locally.")
+	f.Var(&l.CompactorTenantBlockRanges, "compactor.tenant-block-ranges", "List of compaction time ranges for the tenant. When set, this limit replaces -compactor.block-ranges for the tenant. Each range must be divisible by the previous one.")
+	f.Var(&l.CompactorTenantSchedulingWindows, "compactor.tenant-scheduling-windows", "Comma-separated list of daily time windows, in the HH:MM-HH:MM format and in UTC, during which the compactor compacts the tenant. Outside of them, the tenant is skipped. A window whose end is before its start spans midnight. If empty, the tenant is compacted at any time.")
+	f.Var(&l.CompactorRequiredGroupingLabels, "compactor.required-grouping-labels", "Comma-separated list of external labels the compactor always takes into account when grouping blocks, even if they would otherwise be ignored. Blocks with different values for any of these labels are never compacted together.")
 
 	// Query-frontend.
 	f.Var(&l.MaxTotalQueryLength, MaxTotalQueryLengthFlag, "Limit the total query time range (end - start time). This limit is enforced in the query-frontend on the received instant, range or remote read query.")
@@ -585,6 +630,32 @@ func (l *Limits) validate() error {
 		return errInvalidIngestStorageReadConsistency
 	}
 
+	if l.CompactorBlockUploadValidationConcurrency < 0 {
+		return errNegativeBlockUploadValidationConcurrency
+	}
+
+	if l.CompactorTenantCompactionRetries < 0 {
+		return errNegativeCompactorTenantCompactionRetries
+	}
+
+	if l.CompactorMaxSeries < 0 {
+		return errNegativeCompactorMaxSeries
+	}
+
+	if l.CompactorCompactedBlocksValidationConcurrency < 0 {
+		return errNegativeCompactedBlocksValidationConcurrency
+	}
+
+	if size := l.CompactorMaxBlockChunkSegmentSize; size != 0 && (size < MinCompactorMaxBlockChunkSegmentSize || size > MaxCompactorMaxBlockChunkSegmentSize) {
+		return errInvalidCompactorMaxBlockChunkSegmentSize
+	}
+
+	for i := 1; i < len(l.CompactorTenantBlockRanges); i++ {
+		if l.CompactorTenantBlockRanges[i]%l.CompactorTenantBlockRanges[i-1] != 0 {
+			return fmt.Errorf(errInvalidCompactorTenantBlockRanges, l.CompactorTenantBlockRanges[i].String(), l.CompactorTenantBlockRanges[i-1].String())
+		}
+	}
+
 	if l.HATrackerUpdateTimeoutJitterMax < 0 {
 		return errNegativeUpdateTimeoutJitterMax
 	}
@@ -998,10 +1069,76 @@ func (o *Overrides) CompactorInMemoryTenantMetaCacheSize(userID string) int {
 	return o.getOverridesForUser(userID).CompactorInMemoryTenantMetaCacheSize
 }
 
+func (o *Overrides) CompactorInMemoryTenantMetaCacheShadowSize(userID string) int {
+	return o.getOverridesForUser(userID).CompactorInMemoryTenantMetaCacheShadowSize
+}
+
 func (o *Overrides) CompactorMaxPerBlockUploadConcurrency(userID string) int {
 	return o.getOverridesForUser(userID).CompactorMaxPerBlockUploadConcurrency
 }
 
+func (o *Overrides) CompactorRequiredGroupingLabels(userID string) []string {
+	return o.getOverridesForUser(userID).CompactorRequiredGroupingLabels
+}
+
+// CompactorTenantDiskQuotaBytes returns the maximum disk space the tenant's compaction working directory can use.
+func (o *Overrides) CompactorTenantDiskQuotaBytes(userID string) int64 {
+	return o.getOverridesForUser(userID).CompactorTenantDiskQuotaBytes
+}
+
+// CompactorMaxBlockChunkSegmentSize returns the max size of the chunk segment files of the blocks compacted for a given user.
+func (o *Overrides) CompactorMaxBlockChunkSegmentSize(userID string) int64 {
+	return o.getOverridesForUser(userID).CompactorMaxBlockChunkSegmentSize
+}
+
+// CompactorCompactedBlocksValidationConcurrency returns the max number of blocks output by a compaction job of a given
+// user that can be validated concurrently. 0 means -compactor.block-sync-concurrency applies.
+func (o *Overrides) CompactorCompactedBlocksValidationConcurrency(userID string) int {
+	return o.getOverridesForUser(userID).CompactorCompactedBlocksValidationConcurrency
+}
+
+// CompactorMaxSeries returns the max number of series stored in the compacted blocks of a given user.
+func (o *Overrides) CompactorMaxSeries(userID string) int64 {
+	return o.getOverridesForUser(userID).CompactorMaxSeries
+}
+
+// CompactorTenantCompactionMemoryBytes returns the maximum estimated memory of the compaction jobs of the tenant run at the same time.
+func (o *Overrides) CompactorTenantCompactionMemoryBytes(userID string) int64 {
+	return o.getOverridesForUser(userID).CompactorTenantCompactionMemoryBytes
+}
+
+// CompactorNoBlocksFileCleanupEnabled returns whether the files left in the tenant bucket can be deleted when the tenant has no blocks.
+func (o *Overrides) CompactorNoBlocksFileCleanupEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorNoBlocksFileCleanupEnabled
+}
+
+// CompactorLogOverlappingBlocks returns whether the overlapping blocks found while compacting the tenant's blocks are logged.
+func (o *Overrides) CompactorLogOverlappingBlocks(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorLogOverlappingBlocks
+}
+
+// CompactorTenantCompactionRetries returns how many times a failed compaction of the tenant is retried within a single compaction run.
+// 0 means the global -compactor.compaction-retries applies.
+func (o *Overrides) CompactorTenantCompactionRetries(userID string) int {
+	return o.getOverridesForUser(userID).CompactorTenantCompactionRetries
+}
+
+// CompactorTenantBlockRanges returns the compaction time ranges of the tenant.
+// An empty list means the global -compactor.block-ranges applies.
+func (o *Overrides) CompactorTenantBlockRanges(userID string) util.DurationList {
+	return o.getOverridesForUser(userID).CompactorTenantBlockRanges
+}
+
+// CompactorTenantSchedulingWindows returns the daily time windows during which the tenant is compacted.
+// An empty list means the tenant is compacted at any time.
+func (o *Overrides) CompactorTenantSchedulingWindows(userID string) util.TimeWindows {
+	return o.getOverridesForUser(userID).CompactorTenantSchedulingWindows
+}
+
+func (o *Overrides) CompactorUploadSparseIndexHeaders(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorUploadSparseIndexHeaders
+}
+
 // EvaluationDelay returns the rules evaluation delay for a given user.
 func (o *Overrides) EvaluationDelay(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).RulerEvaluationDelay)
@@ -1052,6 +1189,12 @@ func (o *Overrides) CompactorBlockUploadValidationEnabled(tenantID string) bool
 	return o.getOverridesForUser(tenantID).CompactorBlockUploadValidationEnabled
 }
 
+// CompactorTenantBlockUploadValidationConcurrency returns the max number of uploaded blocks of a certain tenant
+// that can be validated concurrently, in addition to the global limit. 0 means no limit.
+func (o *Overrides) CompactorTenantBlockUploadValidationConcurrency(tenantID string) int {
+	return o.getOverridesForUser(tenantID).CompactorBlockUploadValidationConcurrency
+}
+
 // CompactorBlockUploadVerifyChunks returns whether compaction chunk verification is enabled for a certain tenant.
 func (o *Overrides) CompactorBlockUploadVerifyChunks(tenantID string) bool {
 	return o.getOverridesForUser(tenantID).CompactorBlockUploadVerifyChunks
@@ -1062,6 +1205,11 @@ func (o *Overrides) CompactorBlockUploadMaxBlockSizeBytes(userID string) int64 {
 	return o.getOverridesForUser(userID).CompactorBlockUploadMaxBlockSizeBytes
 }
 
+// CompactorBlockUploadMaxFiles returns the maximum number of files of a block that is allowed to be uploaded for a given user.
+func (o *Overrides) CompactorBlockUploadMaxFiles(userID string) int {
+	return o.getOverridesForUser(userID).CompactorBlockUploadMaxFiles
+}
+
 // MetricRelabelConfigs returns the metric relabel configs for a given user.
 func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	relabelConfigs := o.getOverridesForUser(userID).MetricRelabelConfigs
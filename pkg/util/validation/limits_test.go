@@ -26,6 +26,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ruler/notifier"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 func TestMain(m *testing.M) {
@@ -1501,6 +1502,14 @@ metric_relabel_configs:
 			cfg:         `ingest_storage_read_consistency: xyz`,
 			expectedErr: errInvalidIngestStorageReadConsistency.Error(),
 		},
+		"should fail on compactor_tenant_block_ranges not divisible by the previous one": {
+			cfg:         `compactor_tenant_block_ranges: [2h, 3h]`,
+			expectedErr: fmt.Sprintf(errInvalidCompactorTenantBlockRanges, "3h0m0s", "2h0m0s"),
+		},
+		"should pass on compactor_tenant_block_ranges divisible by the previous one": {
+			cfg:         `compactor_tenant_block_ranges: [2h, 12h]`,
+			expectedErr: "",
+		},
 	}
 
 	for testName, testData := range tests {
@@ -1559,6 +1568,86 @@ func TestLimits_validate(t *testing.T) {
 			}(),
 			expectedErr: errNegativeUpdateTimeoutJitterMax,
 		},
+		"should fail if the tenant block upload validation concurrency is negative": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorBlockUploadValidationConcurrency = -1
+
+				return cfg
+			}(),
+			expectedErr: errNegativeBlockUploadValidationConcurrency,
+		},
+		"should fail if the tenant compacted blocks validation concurrency is negative": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorCompactedBlocksValidationConcurrency = -1
+
+				return cfg
+			}(),
+			expectedErr: errNegativeCompactedBlocksValidationConcurrency,
+		},
+		"should fail if the tenant compactor max series is negative": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorMaxSeries = -1
+
+				return cfg
+			}(),
+			expectedErr: errNegativeCompactorMaxSeries,
+		},
+		"should fail if the tenant max block chunk segment size is lower than the minimum": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorMaxBlockChunkSegmentSize = MinCompactorMaxBlockChunkSegmentSize - 1
+
+				return cfg
+			}(),
+			expectedErr: errInvalidCompactorMaxBlockChunkSegmentSize,
+		},
+		"should fail if the tenant max block chunk segment size is greater than the maximum": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorMaxBlockChunkSegmentSize = MaxCompactorMaxBlockChunkSegmentSize + 1
+
+				return cfg
+			}(),
+			expectedErr: errInvalidCompactorMaxBlockChunkSegmentSize,
+		},
+		"should pass if the tenant max block chunk segment size is within the bounds": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorMaxBlockChunkSegmentSize = MinCompactorMaxBlockChunkSegmentSize
+
+				return cfg
+			}(),
+			expectedErr: nil,
+		},
+		"should fail if the tenant compactor block ranges are not divisible by the previous one": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorTenantBlockRanges = util.DurationList{2 * time.Hour, 3 * time.Hour}
+
+				return cfg
+			}(),
+			expectedErr: fmt.Errorf(errInvalidCompactorTenantBlockRanges, "3h0m0s", "2h0m0s"),
+		},
+		"should pass if the tenant compactor block ranges are divisible by the previous one": {
+			cfg: func() Limits {
+				cfg := Limits{}
+				flagext.DefaultValues(&cfg)
+				cfg.CompactorTenantBlockRanges = util.DurationList{2 * time.Hour, 12 * time.Hour}
+
+				return cfg
+			}(),
+			expectedErr: nil,
+		},
 		"should fail if failover timeout is < update timeout + jitter + 1 sec": {
 			cfg: func() Limits {
 				cfg := Limits{}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/pprof/profile"
+)
+
+const allocSpaceSampleType = "alloc_space"
+
+// runAllocDiff runs the given benchmark case with both the Mimir and Prometheus engines, capturing a memory
+// profile for each, and writes the call sites with the largest difference in bytes allocated per operation
+// to the configured output file.
+func (a *app) runAllocDiff(caseName string) error {
+	if err := a.buildAndValidateBinary(); err != nil {
+		return err
+	}
+
+	slog.Info("running benchmarks for allocation diff...")
+
+	mimir, err := a.runBenchmarkForAllocs(benchmark{caseName: caseName, engine: "Mimir"}, true)
+	if err != nil {
+		return err
+	}
+
+	prometheus, err := a.runBenchmarkForAllocs(benchmark{caseName: caseName, engine: "Prometheus"}, false)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(a.outputPath)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+
+	if err := writeAllocDiff(f, caseName, mimir, prometheus, a.allocDiffTopN); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing allocation diff failed: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close output file: %w", err)
+	}
+
+	slog.Info("allocation diff written", "path", a.outputPath)
+
+	return nil
+}
+
+// runBenchmarkForAllocs runs the benchmark and returns the bytes allocated per operation, by call site.
+func (a *app) runBenchmarkForAllocs(b benchmark, printBenchmarkHeader bool) (map[string]float64, error) {
+	profilePath := filepath.Join(a.tempDir, fmt.Sprintf("memprofile-%v.pprof", b.engine))
+
+	if _, err := a.runBenchmark(b, printBenchmarkHeader, profilePath); err != nil {
+		return nil, fmt.Errorf("running benchmark '%v' failed: %w", b.FullName(), err)
+	}
+
+	allocs, err := allocsByCallSite(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading memory profile for benchmark '%v' failed: %w", b.FullName(), err)
+	}
+
+	iterations := float64(profiledIterations(a.allocDiffIters))
+	for site, bytes := range allocs {
+		allocs[site] = bytes / iterations
+	}
+
+	return allocs, nil
+}
+
+// profiledIterations returns the number of iterations covered by the memory profile of a benchmark run with
+// -test.benchtime=<n>x: the testing package always runs a single iteration first, and then runs the n requested
+// iterations unless n is 1.
+func profiledIterations(n int) int {
+	if n > 1 {
+		return n + 1
+	}
+	return 1
+}
+
+// allocsByCallSite returns the total bytes allocated in the profile at path, grouped by the innermost call site.
+func allocsByCallSite(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx := slices.IndexFunc(p.SampleType, func(t *profile.ValueType) bool { return t.Type == allocSpaceSampleType })
+	if valueIdx == -1 {
+		return nil, fmt.Errorf("profile does not contain '%v' samples", allocSpaceSampleType)
+	}
+
+	allocs := map[string]float64{}
+
+	for _, s := range p.Sample {
+		allocs[callSite(s)] += float64(s.Value[valueIdx])
+	}
+
+	return allocs, nil
+}
+
+func callSite(s *profile.Sample) string {
+	if len(s.Location) == 0 || len(s.Location[0].Line) == 0 || s.Location[0].Line[0].Function == nil {
+		return "<unknown>"
+	}
+
+	// The first line of the first location is the innermost frame, taking inlining into account.
+	l := s.Location[0].Line[0]
+	return fmt.Sprintf("%v (%v:%v)", l.Function.Name, filepath.Base(l.Function.Filename), l.Line)
+}
+
+func writeAllocDiff(w io.Writer, caseName string, mimir, prometheus map[string]float64, topN int) error {
+	type diff struct {
+		site             string
+		mimir, prom, abs float64
+	}
+
+	diffs := make([]diff, 0, len(mimir)+len(prometheus))
+
+	for site, m := range mimir {
+		diffs = append(diffs, diff{site: site, mimir: m, prom: prometheus[site]})
+	}
+
+	for site, p := range prometheus {
+		if _, ok := mimir[site]; !ok {
+			diffs = append(diffs, diff{site: site, prom: p})
+		}
+	}
+
+	for i := range diffs {
+		diffs[i].abs = math.Abs(diffs[i].mimir - diffs[i].prom)
+	}
+
+	slices.SortFunc(diffs, func(a, b diff) int {
+		if c := cmp.Compare(b.abs, a.abs); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.site, b.site)
+	})
+
+	if len(diffs) > topN {
+		diffs = diffs[:topN]
+	}
+
+	if _, err := fmt.Fprintf(w, "Allocation diff for %v, in bytes allocated per operation (top %v call sites by absolute difference)\n\n", caseName, topN); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if _, err := fmt.Fprintln(tw, "Mimir\tPrometheus\tMimir - Prometheus\t\tCall site"); err != nil {
+		return err
+	}
+
+	for _, d := range diffs {
+		if _, err := fmt.Fprintf(tw, "%.0f\t%.0f\t%+.0f\t\t%v\n", d.mimir, d.prom, d.mimir-d.prom, d.site); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -13,13 +14,18 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/pprof"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/regexp"
+	"github.com/prometheus/prometheus/util/compression"
 
+	"github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/streamingpromql/benchmarks"
 )
 
@@ -47,7 +53,20 @@ type app struct {
 	justRunIngester bool
 	cpuProfilePath  string
 	memProfilePath  string
+	loadProfilePath string
 	benchtime       string
+	allocDiff       bool
+	allocDiffTopN   int
+	allocDiffIters  int
+	outputPath      string
+	reportGC        bool
+
+	walCompression       string
+	outOfOrderTimeWindow time.Duration
+
+	ingesterClientCfg    client.Config
+	ingesterClientArgs   []string
+	ingesterCheckTimeout time.Duration
 }
 
 func (a *app) run() error {
@@ -76,6 +95,16 @@ func (a *app) run() error {
 		}
 	}
 
+	if a.allocDiff {
+		if a.count != 1 {
+			return fmt.Errorf("must run exactly one iteration when diffing allocations, but have -count=%d", a.count)
+		}
+
+		if cases := uniqueCaseNames(filteredBenchmarks); len(cases) != 1 {
+			return fmt.Errorf("must select exactly one benchmark case with -bench when diffing allocations, but have %v cases selected", len(cases))
+		}
+	}
+
 	if err := a.findBenchmarkPackageDir(); err != nil {
 		return fmt.Errorf("could not find engine package directory: %w", err)
 	}
@@ -95,6 +124,10 @@ func (a *app) run() error {
 		return a.waitForExit()
 	}
 
+	if a.allocDiff {
+		return a.runAllocDiff(filteredBenchmarks[0].caseName)
+	}
+
 	if err := a.runBenchmarks(filteredBenchmarks); err != nil {
 		return err
 	}
@@ -102,7 +135,7 @@ func (a *app) run() error {
 	return nil
 }
 
-func (a *app) runBenchmarks(filteredBenchmarks []benchmark) error {
+func (a *app) buildAndValidateBinary() error {
 	if err := a.buildBinary(); err != nil {
 		return fmt.Errorf("building binary failed: %w", err)
 	}
@@ -111,13 +144,21 @@ func (a *app) runBenchmarks(filteredBenchmarks []benchmark) error {
 		return fmt.Errorf("benchmark binary failed validation: %w", err)
 	}
 
+	return nil
+}
+
+func (a *app) runBenchmarks(filteredBenchmarks []benchmark) error {
+	if err := a.buildAndValidateBinary(); err != nil {
+		return err
+	}
+
 	slog.Info("running benchmarks...")
 
 	haveRunAnyTests := false
 
 	for _, benchmark := range filteredBenchmarks {
 		for i := uint(0); i < a.count; i++ {
-			if err := a.runBenchmark(benchmark, !haveRunAnyTests); err != nil {
+			if _, err := a.runBenchmark(benchmark, !haveRunAnyTests, a.memProfilePath); err != nil {
 				return fmt.Errorf("running benchmark '%v' failed: %w", benchmark.FullName(), err)
 			}
 
@@ -152,7 +193,17 @@ func (a *app) parseArgs() error {
 	flag.StringVar(&a.ingesterAddress, "use-existing-ingester", "", "use existing ingester rather than creating a new one")
 	flag.StringVar(&a.cpuProfilePath, "cpuprofile", "", "write CPU profile to file, only supported when running a single iteration of one benchmark")
 	flag.StringVar(&a.memProfilePath, "memprofile", "", "write memory profile to file, only supported when running a single iteration of one benchmark")
+	flag.StringVar(&a.loadProfilePath, "profile-load", "", "write CPU profile of the ingester data loading phase to file")
 	flag.StringVar(&a.benchtime, "benchtime", "", "value passed to benchmark binary as -benchtime flag")
+	flag.BoolVar(&a.allocDiff, "allocdiff", false, "run a single benchmark case with both engines and write the difference in allocations by call site to the file given by -out")
+	flag.IntVar(&a.allocDiffTopN, "allocdiff-top", 20, "number of call sites to include in the allocation diff")
+	flag.IntVar(&a.allocDiffIters, "allocdiff-iterations", 100, "number of iterations to run each engine for when using -allocdiff")
+	flag.StringVar(&a.outputPath, "out", "", "file to write the allocation diff to, required when using -allocdiff")
+	flag.BoolVar(&a.reportGC, "report-gc", false, "report the number of GC cycles and the GC pause time per operation of each benchmark")
+	flag.StringVar(&a.walCompression, "wal-compression", "", fmt.Sprintf("WAL compression used by the ingester, one of: %v (default: the ingester default)", strings.Join(compression.Types(), ", ")))
+	flag.DurationVar(&a.outOfOrderTimeWindow, "out-of-order-time-window", 0, "out-of-order time window used by the ingester, 0 to disable out-of-order ingestion")
+	flag.DurationVar(&a.ingesterCheckTimeout, "existing-ingester-check-timeout", 30*time.Second, "timeout of the check that the existing ingester given with '-use-existing-ingester' holds the data required for benchmarks")
+	a.ingesterClientCfg.RegisterFlagsWithPrefix(benchmarks.IngesterClientFlagsPrefix, flag.CommandLine)
 
 	if err := flagext.ParseFlagsWithoutArguments(flag.CommandLine); err != nil {
 		fmt.Printf("%v\n", err)
@@ -164,6 +215,63 @@ func (a *app) parseArgs() error {
 		return errors.New("cannot specify both '-start-ingester' and an existing ingester address with '-use-existing-ingester'")
 	}
 
+	if a.ingesterAddress != "" && a.loadProfilePath != "" {
+		return errors.New("cannot specify '-profile-load' when using an existing ingester with '-use-existing-ingester'")
+	}
+
+	if a.ingesterAddress != "" && (a.walCompression != "" || a.outOfOrderTimeWindow != 0) {
+		return errors.New("cannot specify ingester storage options with '-wal-compression' or '-out-of-order-time-window' when using an existing ingester with '-use-existing-ingester'")
+	}
+
+	// Forward the ingester client flags explicitly set to the benchmark binary, which builds its own client.
+	flag.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, benchmarks.IngesterClientFlagsPrefix+".") {
+			a.ingesterClientArgs = append(a.ingesterClientArgs, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	if a.ingesterAddress == "" && len(a.ingesterClientArgs) > 0 {
+		return fmt.Errorf("cannot specify ingester client options with '-%v.*' when not using an existing ingester with '-use-existing-ingester'", benchmarks.IngesterClientFlagsPrefix)
+	}
+
+	if err := a.ingesterClientCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid ingester client options: %w", err)
+	}
+
+	if a.ingesterCheckTimeout <= 0 {
+		return errors.New("'-existing-ingester-check-timeout' must be greater than 0")
+	}
+
+	if a.walCompression != "" && !slices.Contains(compression.Types(), a.walCompression) {
+		return fmt.Errorf("invalid '-wal-compression' value '%v', must be one of: %v", a.walCompression, strings.Join(compression.Types(), ", "))
+	}
+
+	if a.outOfOrderTimeWindow < 0 {
+		return errors.New("'-out-of-order-time-window' must not be negative")
+	}
+
+	if a.allocDiff {
+		if a.outputPath == "" {
+			return errors.New("must specify an output file with '-out' when using '-allocdiff'")
+		}
+
+		if a.cpuProfilePath != "" || a.memProfilePath != "" {
+			return errors.New("cannot specify '-cpuprofile' or '-memprofile' when using '-allocdiff'")
+		}
+
+		if a.allocDiffTopN <= 0 {
+			return errors.New("'-allocdiff-top' must be greater than 0")
+		}
+
+		if a.allocDiffIters <= 0 {
+			return errors.New("'-allocdiff-iterations' must be greater than 0")
+		}
+
+		if a.benchtime != "" {
+			return errors.New("cannot specify '-benchtime' when using '-allocdiff', use '-allocdiff-iterations' instead")
+		}
+	}
+
 	return nil
 }
 
@@ -242,16 +350,26 @@ func (a *app) validateBinary() error {
 
 func (a *app) startIngesterAndLoadData() error {
 	if a.ingesterAddress != "" {
-		slog.Warn("using existing ingester; not checking data required for benchmark is present", "address", a.ingesterAddress)
 		a.cleanup = func() {
 			// Nothing to do.
 		}
-		return nil
+		return a.checkExistingIngesterData()
+	}
+
+	if a.loadProfilePath != "" {
+		stopProfiling, err := startCPUProfile(a.loadProfilePath)
+		if err != nil {
+			return err
+		}
+		defer stopProfiling()
 	}
 
 	slog.Info("starting ingester and loading data...")
 
-	address, cleanup, err := benchmarks.StartIngesterAndLoadData(a.dataDir, benchmarks.MetricSizes)
+	address, cleanup, err := benchmarks.StartIngesterAndLoadData(a.dataDir, benchmarks.MetricSizes, benchmarks.StorageOptions{
+		WALCompression:       a.walCompression,
+		OutOfOrderTimeWindow: a.outOfOrderTimeWindow,
+	})
 	if err != nil {
 		return err
 	}
@@ -264,6 +382,53 @@ func (a *app) startIngesterAndLoadData() error {
 	return nil
 }
 
+// checkExistingIngesterData checks the existing ingester is reachable, and warns if it doesn't hold the number of
+// series loaded for benchmarks, as results obtained against different data aren't comparable.
+func (a *app) checkExistingIngesterData() error {
+	slog.Info("checking data in existing ingester...", "address", a.ingesterAddress)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.ingesterCheckTimeout)
+	defer cancel()
+
+	actual, err := benchmarks.CountIngesterSeries(ctx, a.ingesterAddress, a.ingesterClientCfg)
+	if err != nil {
+		return fmt.Errorf("could not check data in existing ingester %v: %w", a.ingesterAddress, err)
+	}
+
+	expected := benchmarks.ExpectedSeriesCount(benchmarks.MetricSizes)
+	if actual != expected {
+		slog.Warn("!!! existing ingester does not hold the data expected by benchmarks, results will not be comparable with other runs !!!", "address", a.ingesterAddress, "tenant", benchmarks.UserID, "expected_series", expected, "actual_series", actual)
+		return nil
+	}
+
+	slog.Info("existing ingester holds the data expected by benchmarks", "series", actual)
+	return nil
+}
+
+// startCPUProfile starts writing a CPU profile of this process to path, and returns a function to stop it.
+func startCPUProfile(path string) (func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create CPU profile file: %w", err)
+	}
+
+	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("could not start CPU profile: %w", err)
+	}
+
+	return func() {
+		pprof.StopCPUProfile()
+
+		if err := f.Close(); err != nil {
+			slog.Error("could not close CPU profile file", "err", err)
+			return
+		}
+
+		slog.Info("wrote CPU profile of data loading", "path", path)
+	}, nil
+}
+
 func (a *app) printBenchmarks(benchmarks []benchmark) {
 	for _, b := range benchmarks {
 		println(b.FullName())
@@ -285,6 +450,18 @@ func (a *app) allBenchmarks() []benchmark {
 	return names
 }
 
+func uniqueCaseNames(benchmarks []benchmark) []string {
+	names := make([]string, 0, len(benchmarks))
+
+	for _, b := range benchmarks {
+		if !slices.Contains(names, b.caseName) {
+			names = append(names, b.caseName)
+		}
+	}
+
+	return names
+}
+
 func (a *app) filteredBenchmarks() ([]benchmark, error) {
 	regex, err := regexp.Compile(a.benchmarkFilter)
 	if err != nil {
@@ -307,7 +484,8 @@ func (a *app) filteredBenchmarks() ([]benchmark, error) {
 	return filtered, nil
 }
 
-func (a *app) runBenchmark(b benchmark, printBenchmarkHeader bool) error {
+// runBenchmark runs a single benchmark and returns the number of iterations it ran for.
+func (a *app) runBenchmark(b benchmark, printBenchmarkHeader bool, memProfilePath string) (int, error) {
 	args := []string{
 		"-test.bench=" + b.Pattern(), "-test.run=NoTestsWillMatchThisPattern", "-test.benchmem",
 	}
@@ -316,11 +494,16 @@ func (a *app) runBenchmark(b benchmark, printBenchmarkHeader bool) error {
 		args = append(args, "-test.cpuprofile="+a.cpuProfilePath)
 	}
 
-	if a.memProfilePath != "" {
-		args = append(args, "-test.memprofile="+a.memProfilePath)
+	if memProfilePath != "" {
+		args = append(args, "-test.memprofile="+memProfilePath)
 	}
 
-	if a.benchtime != "" {
+	if a.allocDiff {
+		// Record every allocation, so that call sites with small allocations aren't missed or misrepresented.
+		// The memory profile covers all the iterations run, so run a fixed number of them rather than letting
+		// the benchmark ramp up to the benchmark time.
+		args = append(args, "-test.memprofilerate=1", fmt.Sprintf("-test.benchtime=%vx", a.allocDiffIters))
+	} else if a.benchtime != "" {
 		args = append(args, "-test.benchtime="+a.benchtime)
 	}
 
@@ -331,13 +514,22 @@ func (a *app) runBenchmark(b benchmark, printBenchmarkHeader bool) error {
 	cmd.Env = append(cmd.Env, "MIMIR_PROMQL_ENGINE_BENCHMARK_INGESTER_ADDR="+a.ingesterAddress)
 	cmd.Env = append(cmd.Env, "MIMIR_PROMQL_ENGINE_BENCHMARK_SKIP_COMPARE_RESULTS=true")
 
+	if len(a.ingesterClientArgs) > 0 {
+		cmd.Env = append(cmd.Env, "MIMIR_PROMQL_ENGINE_BENCHMARK_INGESTER_CLIENT_ARGS="+strings.Join(a.ingesterClientArgs, "\n"))
+	}
+
+	if a.reportGC {
+		cmd.Env = append(cmd.Env, "MIMIR_PROMQL_ENGINE_BENCHMARK_REPORT_GC=true")
+	}
+
 	if err := cmd.Run(); err != nil {
 		slog.Warn("output from failed command", "output", buf.String())
-		return fmt.Errorf("executing command failed: %w", err)
+		return 0, fmt.Errorf("executing command failed: %w", err)
 	}
 
 	usage := cmd.ProcessState.SysUsage().(*syscall.Rusage)
 	outputLines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	iterations := 0
 
 	for _, l := range outputLines {
 		isBenchmarkHeaderLine := strings.HasPrefix(l, "goos") || strings.HasPrefix(l, "goarch") || strings.HasPrefix(l, "pkg") || strings.HasPrefix(l, "cpu")
@@ -351,12 +543,19 @@ func (a *app) runBenchmark(b benchmark, printBenchmarkHeader bool) error {
 		} else if isBenchmarkLine {
 			fmt.Print(l)
 			fmt.Printf("     %v B\n", maxRSSInBytes(usage))
+
+			// Benchmark lines look like "BenchmarkQuery/<case>/engine=<engine>-8    123    456 ns/op ...".
+			if fields := strings.Fields(l); len(fields) > 1 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					iterations = n
+				}
+			}
 		} else if !isPassLine {
 			fmt.Println(l)
 		}
 	}
 
-	return nil
+	return iterations, nil
 }
 
 func maxRSSInBytes(usage *syscall.Rusage) int64 {
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/grafana/mimir/pkg/compactor"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+type config struct {
+	bucket               bucket.Config
+	userID               string
+	blocks               flagext.StringSliceCSV
+	splitShards          int
+	outputDir            string
+	blockSyncConcurrency int
+}
+
+func (c *config) registerFlags(f *flag.FlagSet) {
+	c.bucket.RegisterFlags(f)
+	f.StringVar(&c.userID, "user", "", "The tenant the blocks belong to")
+	f.Var(&c.blocks, "blocks", "Comma separated list of the IDs of the blocks compacted by the job")
+	f.IntVar(&c.splitShards, "split-shards", 0, "Number of shards the compacted block is split into. Blocks are merged without splitting if 0")
+	f.StringVar(&c.outputDir, "output-dir", "", "The local directory where the blocks are downloaded and compacted, and the diagnostics are written")
+	f.IntVar(&c.blockSyncConcurrency, "block-sync-concurrency", 8, "How many blocks are downloaded concurrently")
+}
+
+func (c *config) validate() error {
+	if c.userID == "" {
+		return fmt.Errorf("user is required")
+	}
+	if len(c.blocks) == 0 {
+		return fmt.Errorf("blocks are required")
+	}
+	if c.outputDir == "" {
+		return fmt.Errorf("output-dir is required")
+	}
+	if c.splitShards < 0 {
+		return fmt.Errorf("split-shards must be non-negative")
+	}
+	if c.blockSyncConcurrency < 1 {
+		return fmt.Errorf("block-sync-concurrency must be positive")
+	}
+	return nil
+}
+
+func main() {
+	// Clean up all flags registered via init() methods of 3rd-party libraries.
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	cfg := config{}
+	cfg.registerFlags(flag.CommandLine)
+
+	// Parse CLI arguments.
+	if err := flagext.ParseFlagsWithoutArguments(flag.CommandLine); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(cfg config) error {
+	blockIDs := make([]ulid.ULID, 0, len(cfg.blocks))
+	for _, b := range cfg.blocks {
+		blockID, err := ulid.Parse(b)
+		if err != nil {
+			return errors.Wrapf(err, "a block ID in -blocks was invalid: %s", b)
+		}
+		blockIDs = append(blockIDs, blockID)
+	}
+
+	if err := os.MkdirAll(cfg.outputDir, 0750); err != nil {
+		return errors.Wrap(err, "failed to create output directory")
+	}
+
+	// Verbose diagnostics are written both to stdout and to a log file in the output directory.
+	logFile, err := os.Create(filepath.Join(cfg.outputDir, "replay.log"))
+	if err != nil {
+		return errors.Wrap(err, "failed to create log file")
+	}
+	defer logFile.Close()
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(io.MultiWriter(os.Stdout, logFile)))
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	bkt, err := bucket.NewClient(ctx, cfg.bucket, "bucket", logger, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create bucket")
+	}
+
+	// Same compactor as the one used by the split-and-merge compactor. The block ranges are only used for planning.
+	comp, err := tsdb.NewLeveledCompactor(ctx, nil, util_log.SlogFromGoKit(logger), []int64{1}, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create compactor")
+	}
+
+	compIDs, err := compactor.ReplayCompactionJob(ctx, compactor.ReplayJobConfig{
+		UserID:               cfg.userID,
+		BlockIDs:             blockIDs,
+		SplitShards:          uint32(cfg.splitShards),
+		OutputDir:            cfg.outputDir,
+		BlockSyncConcurrency: cfg.blockSyncConcurrency,
+	}, bkt, comp, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "compaction job failed", "err", err)
+		return err
+	}
+
+	level.Info(logger).Log("msg", "compaction job succeeded", "new_blocks", fmt.Sprintf("%v", compIDs))
+	return nil
+}
@@ -4,11 +4,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"text/tabwriter"
@@ -38,6 +40,7 @@ func main() {
 		shardCount  int
 		splitGroups int
 		sorting     string
+		output      string
 	}{}
 
 	logger := gokitlog.NewNopLogger()
@@ -50,6 +53,7 @@ func main() {
 	flag.IntVar(&cfg.shardCount, "shard-count", 4, "Shard count")
 	flag.IntVar(&cfg.splitGroups, "split-groups", 4, "Split groups")
 	flag.StringVar(&cfg.sorting, "sorting", compactor.CompactionOrderOldestFirst, "One of: "+strings.Join(compactor.CompactionOrders, ", ")+".")
+	flag.StringVar(&cfg.output, "output", outputTable, "Output format of the compaction plan. One of: "+strings.Join(outputFormats, ", ")+".")
 
 	// Parse CLI arguments.
 	if err := flagext.ParseFlagsWithoutArguments(flag.CommandLine); err != nil {
@@ -59,6 +63,9 @@ func main() {
 	if cfg.userID == "" {
 		log.Fatalln("no user specified")
 	}
+	if !slices.Contains(outputFormats, cfg.output) {
+		log.Fatalln("unknown output format:", cfg.output)
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT)
 	defer cancel()
@@ -91,11 +98,6 @@ func main() {
 		}
 	}
 
-	tabber := tabwriter.NewWriter(os.Stdout, 1, 4, 3, ' ', 0)
-	defer tabber.Flush()
-
-	fmt.Fprintf(tabber, "Job No.\tStart Time\tEnd Time\tBlocks\tJob Key\n")
-
 	grouper := compactor.NewSplitAndMergeGrouper(cfg.userID, cfg.blockRanges.ToMilliseconds(), uint32(cfg.shardCount), uint32(cfg.splitGroups), logger)
 	jobs, err := grouper.Groups(metas)
 	if err != nil {
@@ -109,14 +111,95 @@ func main() {
 		log.Println("unknown sorting, jobs will be unsorted")
 	}
 
+	switch cfg.output {
+	case outputJSON:
+		err = printJSON(cfg.userID, time.Unix(idx.UpdatedAt, 0), cfg.blockRanges, jobs)
+	default:
+		err = printTable(jobs)
+	}
+	if err != nil {
+		log.Fatalln("failed to print compaction plan:", err)
+	}
+}
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var outputFormats = []string{outputTable, outputJSON}
+
+// plan is the JSON representation of the compaction plan of a tenant, suitable for offline analysis.
+type plan struct {
+	User               string   `json:"user"`
+	BucketIndexUpdated string   `json:"bucket_index_updated"`
+	BlockRanges        []string `json:"block_ranges"`
+	Jobs               []job    `json:"jobs"`
+}
+
+// job is the JSON representation of a planned compaction job. The output blocks of the job are expected to cover
+// the time range between MinTime and MaxTime.
+type job struct {
+	Key             string   `json:"key"`
+	Stage           string   `json:"stage"`
+	SplittingShards uint32   `json:"splitting_shards,omitempty"`
+	MinTime         string   `json:"min_time"`
+	MaxTime         string   `json:"max_time"`
+	Blocks          []string `json:"blocks"`
+}
+
+func printJSON(userID string, indexUpdated time.Time, blockRanges mimir_tsdb.DurationList, jobs []*compactor.Job) error {
+	p := plan{
+		User:               userID,
+		BucketIndexUpdated: indexUpdated.UTC().Format(time.RFC3339),
+		BlockRanges:        make([]string, 0, len(blockRanges)),
+		Jobs:               make([]job, 0, len(jobs)),
+	}
+	for _, r := range blockRanges {
+		p.BlockRanges = append(p.BlockRanges, r.String())
+	}
+
+	for _, j := range jobs {
+		out := job{
+			Key:     j.Key(),
+			Stage:   "merge",
+			MinTime: formatTime(j.MinTime()),
+			MaxTime: formatTime(j.MaxTime()),
+			Blocks:  make([]string, 0, len(j.IDs())),
+		}
+		if j.UseSplitting() {
+			out.Stage = "split"
+			out.SplittingShards = j.SplittingShards()
+		}
+		for _, id := range j.IDs() {
+			out.Blocks = append(out.Blocks, id.String())
+		}
+		p.Jobs = append(p.Jobs, out)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func printTable(jobs []*compactor.Job) error {
+	tabber := tabwriter.NewWriter(os.Stdout, 1, 4, 3, ' ', 0)
+
+	fmt.Fprintf(tabber, "Job No.\tStart Time\tEnd Time\tBlocks\tJob Key\n")
 	for ix, j := range jobs {
 		fmt.Fprintf(tabber,
 			"%d\t%s\t%s\t%d\t%s\n",
 			ix+1,
-			timestamp.Time(j.MinTime()).UTC().Format(time.RFC3339),
-			timestamp.Time(j.MaxTime()).UTC().Format(time.RFC3339),
+			formatTime(j.MinTime()),
+			formatTime(j.MaxTime()),
 			len(j.IDs()),
 			j.Key(),
 		)
 	}
+
+	return tabber.Flush()
+}
+
+func formatTime(ts int64) string {
+	return timestamp.Time(ts).UTC().Format(time.RFC3339)
 }
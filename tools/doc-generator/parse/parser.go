@@ -26,6 +26,7 @@ import (
 	asmodel "github.com/grafana/mimir/pkg/ingester/activeseries/model"
 	"github.com/grafana/mimir/pkg/ruler/notifier"
 	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/configdoc"
 )
 
@@ -380,6 +381,8 @@ func getFieldCustomType(t reflect.Type) (string, bool) {
 		return "string", true
 	case reflect.TypeOf(flagext.CIDRSliceCSV{}).String():
 		return "string", true
+	case reflect.TypeOf(util.TimeWindows{}).String():
+		return "string", true
 	case reflect.TypeOf([]*relabel.Config{}).String():
 		return "relabel_config...", true
 	case reflect.TypeOf(asmodel.CustomTrackersConfig{}).String():
@@ -466,6 +469,8 @@ func getCustomFieldType(t reflect.Type) (string, bool) {
 		return "string", true
 	case reflect.TypeOf(flagext.CIDRSliceCSV{}).String():
 		return "string", true
+	case reflect.TypeOf(util.TimeWindows{}).String():
+		return "string", true
 	case reflect.TypeOf([]*relabel.Config{}).String():
 		return "relabel_config...", true
 	case reflect.TypeOf(asmodel.CustomTrackersConfig{}).String():